@@ -32,6 +32,7 @@ import (
 	"SteadyDNS/core/database"
 	"SteadyDNS/core/plugin"
 	"SteadyDNS/core/plugin/plugins"
+	"SteadyDNS/core/sdns"
 	"SteadyDNS/core/webapi/api"
 
 	"github.com/gin-gonic/gin"
@@ -303,6 +304,10 @@ func runService(daemonManager *common.DaemonManager) error {
 		logger.Warn("数据库初始化完成")
 	} else {
 		logger.Info("数据库文件已存在，使用现有数据库")
+		// 迁移数据表结构，确保新版本增加的表和字段存在
+		if err := database.MigrateSchema(); err != nil {
+			log.Fatalf("数据库迁移失败: %v", err)
+		}
 	}
 
 	// 初始化插件系统
@@ -326,15 +331,20 @@ func runService(daemonManager *common.DaemonManager) error {
 		logger.Info("BIND插件已禁用")
 	}
 
+	// 注册日志分析插件
+	logAnalysisPlugin := plugins.NewLogAnalysisPlugin()
+	if err := pm.RegisterPlugin(logAnalysisPlugin); err != nil {
+		logger.Warn("注册日志分析插件失败: %v", err)
+	}
+	logAnalysisEnabled := common.GetConfigBool("Plugins", "LOG_ANALYSIS_ENABLED", false)
+	pm.SetPluginEnabled(plugin.PluginNameLogAnalysis, logAnalysisEnabled)
+	logger.Info("日志分析插件状态: %v", logAnalysisEnabled)
+
 	// 设置预留插件状态（功能暂未实现）
 	dnsRulesEnabled := common.GetConfigBool("Plugins", "DNS_RULES_ENABLED", false)
 	pm.SetPluginEnabled(plugin.PluginNameDNSRules, dnsRulesEnabled)
 	logger.Info("DNS规则插件状态: %v (预留功能)", dnsRulesEnabled)
 
-	logAnalysisEnabled := common.GetConfigBool("Plugins", "LOG_ANALYSIS_ENABLED", false)
-	pm.SetPluginEnabled(plugin.PluginNameLogAnalysis, logAnalysisEnabled)
-	logger.Info("日志分析插件状态: %v (预留功能)", logAnalysisEnabled)
-
 	// 初始化所有启用的插件
 	if err := pm.InitializeEnabledPlugins(); err != nil {
 		logger.Warn("初始化插件失败: %v", err)
@@ -342,6 +352,9 @@ func runService(daemonManager *common.DaemonManager) error {
 		logger.Info("插件系统初始化完成")
 	}
 
+	// 启动事件管理器
+	sdns.GetEventManager().Start()
+
 	// 获取ServerManager实例
 	serverManager := api.GetServerManager()
 	if err := serverManager.StartDNSServer(); err != nil {
@@ -356,6 +369,12 @@ func runService(daemonManager *common.DaemonManager) error {
 		logger.Info("BIND服务状态检查完成")
 	}
 
+	// 启动BIND状态和DNSSEC签名监控
+	if pm.IsPluginEnabled("bind") {
+		sdns.GetEventManager().StartBindMonitor()
+		sdns.GetEventManager().StartDNSSECMonitor()
+	}
+
 	// 获取服务器管理器实例
 	httpServerInstance := api.GetHTTPServer()
 
@@ -486,6 +505,9 @@ func initLogger() error {
 func cleanup() {
 	logger.Info("正在关闭服务...")
 
+	// 停止事件管理器
+	sdns.GetEventManager().Stop()
+
 	// 删除PID文件
 	os.Remove(cliConfig.PIDFile)
 
@@ -26,9 +26,11 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"SteadyDNS/core/common"
@@ -161,22 +163,43 @@ func (bm *BindManager) GetAuthZones() ([]AuthZone, error) {
 	}
 	content := string(contentBytes)
 
-	// 放宽正则表达式，允许缺少allow-query配置
-	zoneRegex := regexp.MustCompile(`zone\s+"([^"]+)"\s+IN\s+\{[^\}]*type\s+master;[^\}]*file\s+"([^"]+)"[^\}]*\}`)
+	// 按zone声明定位配置块，再从块内提取类型和文件，允许缺少allow-query配置
+	zoneRegex := regexp.MustCompile(`zone\s+"([^"]+)"\s+IN\s*\{`)
+	typeRegex := regexp.MustCompile(`(?:^|[\s;{])type\s+(\w+)\s*;`)
+	fileRegex := regexp.MustCompile(`(?:^|[\s;{])file\s+"([^"]+)"`)
+	allowQueryRegex := regexp.MustCompile(`allow-query\s+\{\s*([^\}]+)\s*\}`)
 	matches := zoneRegex.FindAllStringSubmatchIndex(content, -1)
 
+	// 自定义DNSSEC策略的密钥参数保存在受管策略文件中
+	dnssecPolicies, err := bm.loadDNSSECPolicies()
+	if err != nil {
+		bm.logger.Warn("%v", err)
+	}
+	// 自动PTR默认设置保存在数据库中
+	autoPTRSettings := bm.loadZoneAutoPTR()
+
 	for _, match := range matches {
-		if len(match) < 6 {
+		if len(match) < 4 {
 			continue
 		}
 
-		// 提取域名和文件路径
+		// 提取域名、区域类型和文件路径，仅管理主区域和辅区域
 		domain := content[match[2]:match[3]]
-		zoneFile := content[match[4]:match[5]]
+		zoneBlock := extractZoneBlock(content, match[0])
+		typeMatch := typeRegex.FindStringSubmatch(zoneBlock)
+		fileMatch := fileRegex.FindStringSubmatch(zoneBlock)
+		if typeMatch == nil || fileMatch == nil {
+			continue
+		}
+		zoneType, err := NormalizeZoneType(typeMatch[1])
+		if err != nil {
+			continue
+		}
+		zoneFile := fileMatch[1]
 
 		zone := AuthZone{
 			Domain: domain,
-			Type:   "master",
+			Type:   zoneType,
 			File:   zoneFile,
 		}
 
@@ -184,9 +207,20 @@ func (bm *BindManager) GetAuthZones() ([]AuthZone, error) {
 		zoneStartIndex := match[0]
 		zone.Comment = extractZoneComment(content, zoneStartIndex)
 
+		// 提取allow-transfer、allow-update和update-policy配置（如果存在）
+		zone.AllowTransfer = extractZoneClause(zoneBlock, "allow-transfer")
+		zone.AllowUpdate = extractZoneClause(zoneBlock, "allow-update")
+		zone.UpdatePolicy = extractZoneClause(zoneBlock, "update-policy")
+		zone.DNSSEC = parseZoneDNSSEC(zoneBlock, dnssecPolicies)
+		if autoPTR, ok := autoPTRSettings[domain]; ok {
+			zone.AutoPTR = &autoPTR
+		}
+		if zone.Type == ZoneTypeSlave {
+			zone.Primaries, zone.PrimaryKey = parsePrimaries(zoneBlock)
+		}
+
 		// 提取allow-query配置（如果存在）
-		allowQueryRegex := regexp.MustCompile(fmt.Sprintf(`zone\s+"%s"\s+IN\s+\{[^\}]*allow-query\s+\{\s*([^\}]+)\s*\}[^\}]*\}`, regexp.QuoteMeta(domain)))
-		allowQueryMatch := allowQueryRegex.FindStringSubmatch(content)
+		allowQueryMatch := allowQueryRegex.FindStringSubmatch(zoneBlock)
 		if len(allowQueryMatch) > 1 {
 			zone.AllowQuery = strings.TrimSpace(allowQueryMatch[1])
 		} else {
@@ -203,12 +237,17 @@ func (bm *BindManager) GetAuthZones() ([]AuthZone, error) {
 		// 读取zone文件获取详细信息
 		zoneFilePath := filepath.Join(bm.config.ZoneFilePath, filepath.Base(zoneFile))
 		zoneDetail, err := bm.parseZoneFile(zoneFilePath, zone.Domain)
-		if err != nil {
+		if err != nil && zone.Type == ZoneTypeSlave {
+			// 辅区域在首次传送完成前没有zone文件，仍然列出
+			bm.logger.Debug("辅区域zone文件尚不可用: %v", err)
+			zoneDetail = &AuthZone{Records: make([]Record, 0)}
+		} else if err != nil {
 			bm.logger.Warn("解析zone文件失败: %v, 跳过该域", err)
 			continue
 		}
 
 		// 合并信息
+		zone.DefaultTTL = zoneDetail.DefaultTTL
 		zone.SOA = zoneDetail.SOA
 		zone.Records = zoneDetail.Records
 
@@ -263,13 +302,46 @@ func (bm *BindManager) getAllZoneFiles() ([]string, error) {
 
 // CreateAuthZone 创建权威域
 func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
+	return bm.createAuthZone(zone, false)
+}
+
+// createAuthZone 创建权威域，imported为true时表示导入的区域：
+// 保留原有SOA序列号，不补充默认的NS和A记录
+func (bm *BindManager) createAuthZone(zone AuthZone, imported bool) error {
 	// 检查是否为系统区域
 	if isSystemZone(zone.Domain) {
 		return fmt.Errorf("不能创建系统区域: %s", zone.Domain)
 	}
 
-	// 自动生成SOA序列号，忽略前端传入的值
-	zone.SOA.Serial = generateSerial()
+	// 辅区域只写入named.conf，记录由BIND从主服务器传送
+	zoneType, err := NormalizeZoneType(zone.Type)
+	if err != nil {
+		return err
+	}
+	zone.Type = zoneType
+	if zone.Type == ZoneTypeSlave {
+		return bm.createSecondaryZone(zone)
+	}
+
+	// 校验区域传送和动态更新配置
+	if err := ValidateZoneAccessClauses(zone); err != nil {
+		return err
+	}
+	dnssec, err := resolveDNSSEC(zone.Domain, zone.DNSSEC, nil)
+	if err != nil {
+		return err
+	}
+	zone.DNSSEC = dnssec
+
+	// 自动生成SOA序列号，忽略前端传入的值（导入的区域保留原序列号，避免辅服务器序列号回退）
+	if !imported || zone.SOA.Serial == "" {
+		zone.SOA.Serial = generateSerial()
+	}
+
+	// 校验记录并生成结构化类型的表示格式
+	if err := NormalizeRecords(&zone); err != nil {
+		return err
+	}
 
 	// 为所有记录生成唯一ID（如果没有的话）
 	for i, record := range zone.Records {
@@ -299,7 +371,7 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 		}
 	}
 
-	if !hasNSRecord {
+	if !hasNSRecord && !imported {
 		// 使用SOA记录中的PrimaryNS作为默认NS记录
 		defaultNS := Record{
 			Name:  "@",
@@ -309,11 +381,6 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 		zone.Records = append(zone.Records, defaultNS)
 	}
 
-	// 检查CNAME冲突
-	if err := CheckCNAMEConflicts(zone); err != nil {
-		return err
-	}
-
 	// 检查NS记录中使用的主机名是否有对应的A或AAAA记录
 	for _, record := range zone.Records {
 		if record.Type != "NS" {
@@ -338,7 +405,7 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 		}
 
 		// 如果NS记录指向zone本身，并且没有对应的A或AAAA记录，添加一条默认的A记录
-		if isSelfReference && !hasAddressRecord {
+		if isSelfReference && !hasAddressRecord && !imported {
 			defaultA := Record{
 				Name:  "@",
 				Type:  "A",
@@ -349,8 +416,16 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 		}
 	}
 
+	// 检查区域数据
+	if err := bm.lintBeforeSave(zone); err != nil {
+		return err
+	}
+
 	// 生成zone文件内容
-	zoneContent := bm.generateZoneContent(zone)
+	zoneContent, err := bm.generateZoneContent(zone)
+	if err != nil {
+		return fmt.Errorf("生成zone文件内容失败: %v", err)
+	}
 
 	// 生成zone文件路径
 	zoneFileName := fmt.Sprintf("%s.zone", zone.Domain)
@@ -420,9 +495,22 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 		}
 	}
 
+	// 写入区域专用的DNSSEC策略
+	restoreDNSSEC, err := bm.syncDNSSECPolicy(zone.Domain, zone.DNSSEC)
+	if err != nil {
+		os.Remove(zoneFilePath)
+		if backupID > 0 {
+			bm.HistoryMgr.DeleteBackupRecord(backupID)
+		}
+		bm.logger.Error("更新DNSSEC策略失败: %v", err)
+		return fmt.Errorf("更新DNSSEC策略失败: %v", err)
+	}
+
 	// 更新named.conf文件
-	if err := bm.addZoneToNamedConf(namedConfPath, zone.Domain, zoneFileName, zone.AllowQuery, zone.Comment); err != nil {
-		// 回滚：删除创建的zone文件
+	if err := bm.addZoneToNamedConf(namedConfPath, zone, zoneFileName); err != nil {
+		// 回滚：恢复DNSSEC策略和named.conf，删除创建的zone文件
+		restoreDNSSEC()
+		os.WriteFile(namedConfPath, originalNamedConf, 0644)
 		os.Remove(zoneFilePath)
 		// 操作失败，删除备份记录
 		if backupID > 0 {
@@ -434,8 +522,9 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 
 	// 验证named.conf配置
 	if err := bm.ValidateConfig(); err != nil {
-		// 回滚：恢复原始named.conf内容
+		// 回滚：恢复原始named.conf内容和DNSSEC策略
 		os.WriteFile(namedConfPath, originalNamedConf, 0644)
+		restoreDNSSEC()
 		// 回滚：删除创建的zone文件
 		os.Remove(zoneFilePath)
 		// 操作失败，删除备份记录
@@ -448,8 +537,9 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 
 	// 验证zone文件
 	if err := bm.ValidateZone(zone.Domain); err != nil {
-		// 回滚：恢复原始named.conf内容
+		// 回滚：恢复原始named.conf内容和DNSSEC策略
 		os.WriteFile(namedConfPath, originalNamedConf, 0644)
+		restoreDNSSEC()
 		// 回滚：删除创建的zone文件
 		os.Remove(zoneFilePath)
 		// 操作失败，删除备份记录
@@ -466,19 +556,68 @@ func (bm *BindManager) CreateAuthZone(zone AuthZone) error {
 		// 不回滚，因为配置本身是有效的，只是刷新失败
 	}
 
+	if zone.AutoPTR != nil {
+		bm.saveZoneAutoPTR(zone.Domain, *zone.AutoPTR)
+	}
+
 	// 操作成功，保留备份记录
 	return nil
 }
 
+// zoneFileMu 串行化zone文件的读取-修改-写入
+// API和DNS动态更新各自持有BindManager实例，因此使用包级锁，保证记录级修改的并发校验有效
+var zoneFileMu sync.Mutex
+
 // UpdateAuthZone 更新权威域
+// 启用自动PTR（区域的auto_ptr或记录的auto_ptr）时，A/AAAA记录的增删同步到覆盖该地址的反向区域
 func (bm *BindManager) UpdateAuthZone(zone AuthZone) error {
+	zoneFileMu.Lock()
+	defer zoneFileMu.Unlock()
+	defer bm.freezeDynamicZones()()
+
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		return fmt.Errorf("获取现有权威域失败: %v", err)
+	}
+	var existingZone *AuthZone
+	for i := range zones {
+		if zones[i].Domain == zone.Domain {
+			existingZone = &zones[i]
+			break
+		}
+	}
+	if existingZone == nil || existingZone.Type == ZoneTypeSlave {
+		return bm.updateAuthZone(zone, true)
+	}
+
+	if err := NormalizeRecords(&zone); err != nil {
+		return err
+	}
+	autoPTR := existingZone.AutoPTR != nil && *existingZone.AutoPTR
+	if zone.AutoPTR != nil {
+		autoPTR = *zone.AutoPTR
+	}
+	// 请求未显式关闭自动PTR时，清理与被移除记录完全一致的PTR
+	cleanup := zone.AutoPTR == nil || *zone.AutoPTR
+	if _, err := bm.writeZoneWithPTR(zone, existingZone.Records, zones, autoPTR, cleanup); err != nil {
+		return err
+	}
+	if zone.AutoPTR != nil {
+		bm.saveZoneAutoPTR(zone.Domain, *zone.AutoPTR)
+	}
+	return nil
+}
+
+// updateAuthZone 更新权威域，调用方需持有zoneFileMu，并在读取zone文件前冻结动态区域
+// backup为false时不创建备份，由调用方在同一个备份中写入多个区域
+func (bm *BindManager) updateAuthZone(zone AuthZone, backup bool) error {
 	// 检查是否为系统区域
 	if isSystemZone(zone.Domain) {
 		return fmt.Errorf("不能更新系统区域: %s", zone.Domain)
 	}
 
-	// 检查CNAME冲突
-	if err := CheckCNAMEConflicts(zone); err != nil {
+	// 校验记录并生成结构化类型的表示格式
+	if err := NormalizeRecords(&zone); err != nil {
 		return err
 	}
 
@@ -507,19 +646,74 @@ func (bm *BindManager) UpdateAuthZone(zone AuthZone) error {
 		return fmt.Errorf("权威域不存在: %s", zone.Domain)
 	}
 
-	// 检查是否需要更新 named.conf（allow-query 或 comment 变更）
+	// 区域类型不能通过更新修改，辅区域只更新named.conf配置
+	if zone.Type != "" {
+		zoneType, err := NormalizeZoneType(zone.Type)
+		if err != nil {
+			return err
+		}
+		if zoneType != existingZone.Type {
+			return fmt.Errorf("不支持修改区域类型，请删除后重新创建")
+		}
+	}
+	zone.Type = existingZone.Type
+	if zone.Type == ZoneTypeSlave {
+		return bm.updateSecondaryZone(zone, existingZone)
+	}
+
+	// 检查区域数据，未提供$TTL时按现有默认TTL比较记录TTL
+	lintZone := zone
+	if lintZone.DefaultTTL == 0 {
+		lintZone.DefaultTTL = existingZone.DefaultTTL
+	}
+	if err := bm.lintBeforeSave(lintZone); err != nil {
+		return err
+	}
+
+	// 检查是否需要更新 named.conf（allow-query、allow-transfer、allow-update、update-policy 或 comment 变更）
 	needUpdateNamedConf := false
 	if zone.AllowQuery != "" && zone.AllowQuery != existingZone.AllowQuery {
 		needUpdateNamedConf = true
 	}
+	if zone.AllowTransfer != "" && zone.AllowTransfer != existingZone.AllowTransfer {
+		needUpdateNamedConf = true
+	}
+	if zone.AllowUpdate != "" && zone.AllowUpdate != existingZone.AllowUpdate {
+		needUpdateNamedConf = true
+	}
+	if zone.UpdatePolicy != "" && zone.UpdatePolicy != existingZone.UpdatePolicy {
+		needUpdateNamedConf = true
+	}
 	if zone.Comment != existingZone.Comment {
 		needUpdateNamedConf = true
 	}
 
-	// 如果前端未提供 AllowQuery，使用现有值
+	// 如果前端未提供 AllowQuery、AllowTransfer、AllowUpdate 或 UpdatePolicy，使用现有值
 	if zone.AllowQuery == "" {
 		zone.AllowQuery = existingZone.AllowQuery
 	}
+	if zone.AllowTransfer == "" {
+		zone.AllowTransfer = existingZone.AllowTransfer
+	}
+	if zone.AllowUpdate == "" {
+		zone.AllowUpdate = existingZone.AllowUpdate
+	}
+	if zone.UpdatePolicy == "" {
+		zone.UpdatePolicy = existingZone.UpdatePolicy
+	}
+	if err := ValidateZoneAccessClauses(zone); err != nil {
+		return err
+	}
+
+	// DNSSEC配置为空时保持不变，变更时需要更新 named.conf
+	dnssec, err := resolveDNSSEC(zone.Domain, zone.DNSSEC, existingZone.DNSSEC)
+	if err != nil {
+		return err
+	}
+	zone.DNSSEC = dnssec
+	if !reflect.DeepEqual(zone.DNSSEC, existingZone.DNSSEC) {
+		needUpdateNamedConf = true
+	}
 
 	// 保持 file 字段一致
 	zoneFileName := filepath.Base(existingZone.File)
@@ -587,20 +781,26 @@ func (bm *BindManager) UpdateAuthZone(zone AuthZone) error {
 		}
 	}
 
-	// 生成zone文件内容
-	zoneContent := bm.generateZoneContent(zone)
+	// 在原zone文件基础上生成新内容，保留注释、顺序和指令
+	zoneContent, err := bm.updateZoneContent(originalZoneContent, zone, zoneFilePath)
+	if err != nil {
+		return fmt.Errorf("生成zone文件内容失败: %v", err)
+	}
 
 	// 获取named.conf路径
 	namedConfPath := bm.config.NamedConfPath
 
 	// 在操作前创建全量备份（named.conf + 所有zone文件）
-	allZoneFiles, _ := bm.getAllZoneFiles()
-	files := append([]string{namedConfPath}, allZoneFiles...)
-	zoneJSON, _ := json.Marshal(zone)
-	backupID, err := bm.HistoryMgr.CreateBackup(OperationUpdate, zone.Domain, zoneJSON, files)
-	if err != nil {
-		bm.logger.Warn("创建备份失败: %v", err)
-		backupID = 0
+	var backupID uint64
+	if backup {
+		allZoneFiles, _ := bm.getAllZoneFiles()
+		files := append([]string{namedConfPath}, allZoneFiles...)
+		zoneJSON, _ := json.Marshal(zone)
+		backupID, err = bm.HistoryMgr.CreateBackup(OperationUpdate, zone.Domain, zoneJSON, files)
+		if err != nil {
+			bm.logger.Warn("创建备份失败: %v", err)
+			backupID = 0
+		}
 	}
 
 	// 写入zone文件
@@ -661,16 +861,22 @@ func (bm *BindManager) UpdateAuthZone(zone AuthZone) error {
 	// 如果需要更新 named.conf
 	if needUpdateNamedConf {
 		namedConfPath := filepath.Join(bm.config.NamedConfPath, "named.conf")
-		if err := bm.updateZoneInNamedConf(namedConfPath, zone.Domain, zoneFileName, zone.AllowQuery, zone.Comment); err != nil {
+		restoreDNSSEC, err := bm.syncDNSSECPolicy(zone.Domain, zone.DNSSEC)
+		if err != nil {
+			bm.logger.Error("更新DNSSEC策略失败: %v", err)
+			return fmt.Errorf("更新DNSSEC策略失败: %v", err)
+		}
+		if err := bm.updateZoneInNamedConf(namedConfPath, zone, zoneFileName); err != nil {
+			restoreDNSSEC()
 			bm.logger.Error("更新named.conf文件失败: %v", err)
 			return fmt.Errorf("更新named.conf文件失败: %v", err)
 		}
 
 		// 验证named.conf配置
 		if err := bm.ValidateConfig(); err != nil {
-			// 回滚：恢复原始named.conf
-			bm.updateZoneInNamedConf(namedConfPath, existingZone.Domain,
-				filepath.Base(existingZone.File), existingZone.AllowQuery, existingZone.Comment)
+			// 回滚：恢复原始named.conf和DNSSEC策略
+			bm.updateZoneInNamedConf(namedConfPath, *existingZone, filepath.Base(existingZone.File))
+			restoreDNSSEC()
 			bm.logger.Error("验证named.conf配置失败: %v", err)
 			return fmt.Errorf("验证named.conf配置失败: %v", err)
 		}
@@ -751,6 +957,12 @@ func (bm *BindManager) DeleteAuthZone(domain string) error {
 		return fmt.Errorf("删除zone文件失败: %v", err)
 	}
 
+	// 移除区域专用的DNSSEC策略，策略已不再被引用，失败时仅记录警告
+	if _, err := bm.syncDNSSECPolicy(domain, nil); err != nil {
+		bm.logger.Warn("移除DNSSEC策略失败: %v", err)
+	}
+	bm.deleteZoneAutoPTR(domain)
+
 	// 刷新BIND服务器
 	if err := bm.ReloadBind(); err != nil {
 		bm.logger.Error("刷新BIND服务器失败: %v", err)
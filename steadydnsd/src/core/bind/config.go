@@ -30,13 +30,13 @@ import (
 // addZoneToNamedConf 向named.conf添加zone配置
 // 参数:
 //   - filePath: named.conf 文件路径
-//   - domain: 域名
+//   - zone: 权威域信息，使用其中的域名、allow-query、allow-transfer、allow-update、update-policy、dnssec-policy 和前置注释
 //   - zoneFile: zone 文件名
-//   - allowQuery: 允许查询的地址
-//   - comment: zone 的前置注释（可选）
-func (bm *BindManager) addZoneToNamedConf(filePath, domain, zoneFile, allowQuery, comment string) error {
+func (bm *BindManager) addZoneToNamedConf(filePath string, zone AuthZone, zoneFile string) error {
+	domain := zone.Domain
+	comment := zone.Comment
 	// 清理 allowQuery 中的分号，避免格式错误
-	allowQuery = strings.TrimSuffix(allowQuery, ";")
+	allowQuery := strings.TrimSuffix(zone.AllowQuery, ";")
 
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -65,9 +65,33 @@ func (bm *BindManager) addZoneToNamedConf(filePath, domain, zoneFile, allowQuery
 
 	// 添加zone配置块
 	zoneConfig.WriteString(fmt.Sprintf("zone \"%s\" IN {\n", domain))
-	zoneConfig.WriteString(fmt.Sprintf("    type master;\n"))
-	zoneConfig.WriteString(fmt.Sprintf("    file \"%s\";\n", zoneFile))
+	if zone.Type == ZoneTypeSlave {
+		// 辅区域以文本格式保存传送结果，便于只读查看记录
+		zoneConfig.WriteString("    type slave;\n")
+		zoneConfig.WriteString(fmt.Sprintf("    file \"%s\";\n", zoneFile))
+		zoneConfig.WriteString("    masterfile-format text;\n")
+		zoneConfig.WriteString(fmt.Sprintf("    masters { %s };\n", renderPrimaries(zone.Primaries, zone.PrimaryKey)))
+	} else {
+		zoneConfig.WriteString(fmt.Sprintf("    type master;\n"))
+		zoneConfig.WriteString(fmt.Sprintf("    file \"%s\";\n", zoneFile))
+	}
 	zoneConfig.WriteString(fmt.Sprintf("    allow-query { %s; };\n", allowQuery))
+	if allowTransfer := trimClauseValue(zone.AllowTransfer); allowTransfer != "" {
+		zoneConfig.WriteString(fmt.Sprintf("    allow-transfer { %s; };\n", allowTransfer))
+	}
+	if allowUpdate := trimClauseValue(zone.AllowUpdate); allowUpdate != "" {
+		zoneConfig.WriteString(fmt.Sprintf("    allow-update { %s; };\n", allowUpdate))
+	}
+	switch updatePolicy := trimClauseValue(zone.UpdatePolicy); updatePolicy {
+	case "", "none":
+	case "local":
+		zoneConfig.WriteString("    update-policy local;\n")
+	default:
+		zoneConfig.WriteString(fmt.Sprintf("    update-policy { %s; };\n", updatePolicy))
+	}
+	if zone.Type != ZoneTypeSlave {
+		zoneConfig.WriteString(renderDNSSECClauses(zone.DNSSEC))
+	}
 	zoneConfig.WriteString("};\n")
 
 	// 检查文件末尾的换行符情况
@@ -218,24 +242,99 @@ func (bm *BindManager) removeZoneFromNamedConf(filePath, domain string) error {
 // updateZoneInNamedConf 更新 named.conf 中的 zone 配置（包括注释）
 // 参数:
 //   - filePath: named.conf 文件路径
-//   - domain: 域名
+//   - zone: 权威域信息
 //   - zoneFile: zone 文件名
-//   - allowQuery: 允许查询的地址
-//   - comment: zone 的前置注释（可选）
-func (bm *BindManager) updateZoneInNamedConf(filePath, domain, zoneFile, allowQuery, comment string) error {
+func (bm *BindManager) updateZoneInNamedConf(filePath string, zone AuthZone, zoneFile string) error {
 	// 先移除旧的 zone 配置（包括注释）
-	if err := bm.removeZoneFromNamedConf(filePath, domain); err != nil {
+	if err := bm.removeZoneFromNamedConf(filePath, zone.Domain); err != nil {
 		return fmt.Errorf("移除旧zone配置失败: %v", err)
 	}
 
 	// 添加新的 zone 配置（包含新注释）
-	if err := bm.addZoneToNamedConf(filePath, domain, zoneFile, allowQuery, comment); err != nil {
+	if err := bm.addZoneToNamedConf(filePath, zone, zoneFile); err != nil {
 		return fmt.Errorf("添加新zone配置失败: %v", err)
 	}
 
 	return nil
 }
 
+// trimClauseValue 清理地址匹配列表或update-policy规则两端的空白和分号
+func trimClauseValue(value string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(value), ";"))
+}
+
+// ValidateZoneAccessClauses 校验区域的allow-transfer、allow-update和update-policy配置
+func ValidateZoneAccessClauses(zone AuthZone) error {
+	for name, value := range map[string]string{
+		"allow-transfer": zone.AllowTransfer,
+		"allow-update":   zone.AllowUpdate,
+		"update-policy":  zone.UpdatePolicy,
+	} {
+		if strings.ContainsAny(value, "{}\"#") || strings.Contains(value, "//") {
+			return fmt.Errorf("%s 配置包含非法字符", name)
+		}
+	}
+
+	allowUpdate := trimClauseValue(zone.AllowUpdate)
+	updatePolicy := trimClauseValue(zone.UpdatePolicy)
+	if allowUpdate != "" && allowUpdate != "none" && updatePolicy != "" && updatePolicy != "none" {
+		return fmt.Errorf("allow-update 与 update-policy 不能同时配置")
+	}
+
+	if updatePolicy != "" && updatePolicy != "none" && updatePolicy != "local" {
+		for _, rule := range strings.Split(updatePolicy, ";") {
+			fields := strings.Fields(rule)
+			if len(fields) == 0 {
+				continue
+			}
+			if (fields[0] != "grant" && fields[0] != "deny") || len(fields) < 3 {
+				return fmt.Errorf("update-policy 规则无效: %s", strings.TrimSpace(rule))
+			}
+		}
+	}
+	return nil
+}
+
+// extractZoneBlock 从zone声明起始位置按大括号匹配提取完整的zone配置块
+func extractZoneBlock(content string, start int) string {
+	open := strings.Index(content[start:], "{")
+	if open == -1 {
+		return ""
+	}
+	depth := 0
+	for i := start + open; i < len(content); i++ {
+		switch content[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return content[start : i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// extractZoneClause 提取zone配置块中列表型子句（如 allow-transfer { ... };）的内容
+func extractZoneClause(block, name string) string {
+	clauseRegex := regexp.MustCompile(fmt.Sprintf(`(?:^|[\s;{])%s\s*\{([^\}]*)\}`, regexp.QuoteMeta(name)))
+	if m := clauseRegex.FindStringSubmatch(block); len(m) > 1 {
+		parts := strings.Split(m[1], ";")
+		values := make([]string, 0, len(parts))
+		for _, part := range parts {
+			if part = strings.Join(strings.Fields(part), " "); part != "" {
+				values = append(values, part)
+			}
+		}
+		return strings.Join(values, "; ")
+	}
+	if name == "update-policy" && regexp.MustCompile(`update-policy\s+local\s*;`).MatchString(block) {
+		return "local"
+	}
+	return ""
+}
+
 // cleanupExtraNewlines 清理多余的空行
 func cleanupExtraNewlines(content string) string {
 	lines := strings.Split(content, "\n")
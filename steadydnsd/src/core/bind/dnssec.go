@@ -0,0 +1,552 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/dnssec.go
+// DNSSEC签名策略管理：按区域写入dnssec-policy，读取密钥状态、DS记录和签名有效期
+
+package bind
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/bind/namedconf"
+	"SteadyDNS/core/common"
+
+	"github.com/miekg/dns"
+)
+
+// DNSSECPoliciesFileName 由SteadyDNS维护的dnssec-policy配置文件名，位于named.conf同目录并通过include引入
+const DNSSECPoliciesFileName = "steadydns-dnssec.conf"
+
+// 内置的dnssec-policy
+const (
+	DNSSECPolicyDefault  = "default"  // BIND内置策略：单个CSK，ECDSAP256SHA256，密钥不轮换
+	DNSSECPolicyInsecure = "insecure" // 逐步撤销签名，用于安全地关闭DNSSEC
+	DNSSECPolicyNone     = "none"     // 不签名，表示移除区域的dnssec-policy配置
+)
+
+// managedDNSSECPolicyPrefix 自定义密钥参数时为区域生成的策略名称前缀
+const managedDNSSECPolicyPrefix = "steadydns-"
+
+// DNSSECAlgorithms 支持的DNSSEC签名算法
+var DNSSECAlgorithms = map[string]bool{
+	"rsasha256":       true,
+	"rsasha512":       true,
+	"ecdsap256sha256": true,
+	"ecdsap384sha384": true,
+	"ed25519":         true,
+	"ed448":           true,
+}
+
+// DNSSECConfig 区域的DNSSEC配置
+type DNSSECConfig struct {
+	Enabled bool           `json:"enabled"`
+	Policy  string         `json:"policy,omitempty"` // dnssec-policy名称，启用且未指定密钥参数时默认为default
+	KSK     *DNSSECKeySpec `json:"ksk,omitempty"`    // 自定义KSK参数，与ZSK同时指定时为区域生成专用策略
+	ZSK     *DNSSECKeySpec `json:"zsk,omitempty"`    // 自定义ZSK参数
+}
+
+// DNSSECKeySpec 自定义策略中的密钥参数
+type DNSSECKeySpec struct {
+	Algorithm string `json:"algorithm"`
+	Lifetime  string `json:"lifetime"` // ISO 8601时长（如 P1Y、P90D）或 unlimited
+}
+
+// DNSSECKeyStatus rndc dnssec -status 输出中的单个密钥状态
+type DNSSECKeyStatus struct {
+	KeyTag       int               `json:"key_tag"`
+	Algorithm    string            `json:"algorithm"`
+	Role         string            `json:"role"` // KSK、ZSK或CSK
+	Published    string            `json:"published,omitempty"`
+	KeySigning   string            `json:"key_signing,omitempty"`
+	ZoneSigning  string            `json:"zone_signing,omitempty"`
+	NextRollover string            `json:"next_rollover,omitempty"`
+	States       map[string]string `json:"states,omitempty"` // goal、dnskey、ds、zone rrsig、key rrsig等状态
+	DS           []string          `json:"ds,omitempty"`     // 需提交至父区域的DS记录（仅KSK/CSK）
+}
+
+// DNSSECStatus 区域的DNSSEC签名状态
+type DNSSECStatus struct {
+	Domain              string            `json:"domain"`
+	Enabled             bool              `json:"enabled"`
+	Policy              string            `json:"policy,omitempty"`
+	Keys                []DNSSECKeyStatus `json:"keys"`
+	DS                  []string          `json:"ds,omitempty"`                   // 区域全部DS记录，用于提交至父区域
+	SignatureExpiration *time.Time        `json:"signature_expiration,omitempty"` // 区域顶点签名中最早的过期时间
+	ExpiringSoon        bool              `json:"expiring_soon"`
+	Warnings            []string          `json:"warnings"`
+	Error               string            `json:"error,omitempty"`
+	Details             string            `json:"details,omitempty"` // rndc原始输出
+}
+
+var (
+	dnssecPolicyNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	dnssecLifetimeRegex   = regexp.MustCompile(`^P(\d+Y)?(\d+M)?(\d+W)?(\d+D)?(T(\d+H)?(\d+M)?(\d+S)?)?$`)
+	dnssecPolicyRegex     = regexp.MustCompile(`(?:^|[\s;{])dnssec-policy\s+"?([^";\s]+)"?\s*;`)
+	dnssecKeyLineRegex    = regexp.MustCompile(`^key:\s+(\d+)\s+\(([^)]+)\),\s+(\w+)`)
+)
+
+// managedDNSSECPolicyName 返回区域专用策略的名称
+func managedDNSSECPolicyName(domain string) string {
+	return managedDNSSECPolicyPrefix + strings.TrimSuffix(strings.ToLower(domain), ".")
+}
+
+// normalizeDNSSECKeySpec 规范化并校验密钥参数
+func normalizeDNSSECKeySpec(role string, spec *DNSSECKeySpec) (*DNSSECKeySpec, error) {
+	algorithm := strings.ToLower(strings.TrimSpace(spec.Algorithm))
+	if !DNSSECAlgorithms[algorithm] {
+		return nil, fmt.Errorf("%s算法不受支持: %s", role, spec.Algorithm)
+	}
+	lifetime := strings.ToUpper(strings.TrimSpace(spec.Lifetime))
+	if lifetime == "" || lifetime == "UNLIMITED" {
+		lifetime = "unlimited"
+	} else if !dnssecLifetimeRegex.MatchString(lifetime) || lifetime == "P" || strings.HasSuffix(lifetime, "T") {
+		return nil, fmt.Errorf("%s生命周期无效: %s", role, spec.Lifetime)
+	}
+	return &DNSSECKeySpec{Algorithm: algorithm, Lifetime: lifetime}, nil
+}
+
+// resolveDNSSEC 校验并规范化区域的DNSSEC配置，返回应写入named.conf的配置（nil表示不写入dnssec-policy）
+// 参数:
+//   - domain: 区域名称
+//   - cfg: 请求中的配置，为nil时保持现有配置不变
+//   - existing: 区域当前配置，新建区域时为nil
+func resolveDNSSEC(domain string, cfg, existing *DNSSECConfig) (*DNSSECConfig, error) {
+	if cfg == nil {
+		return existing, nil
+	}
+	policy := strings.TrimSpace(cfg.Policy)
+
+	if !cfg.Enabled {
+		if policy == DNSSECPolicyNone || existing == nil {
+			return nil, nil
+		}
+		// 已签名区域先切换到insecure，待父区域撤销DS后再移除策略，避免解析验证失败
+		return &DNSSECConfig{Policy: DNSSECPolicyInsecure}, nil
+	}
+
+	if policy == DNSSECPolicyInsecure || policy == DNSSECPolicyNone {
+		return nil, fmt.Errorf("启用DNSSEC时策略不能为 %s", policy)
+	}
+
+	managed := managedDNSSECPolicyName(domain)
+	if cfg.KSK == nil && cfg.ZSK == nil {
+		if policy == "" {
+			policy = DNSSECPolicyDefault
+		}
+		if strings.HasPrefix(policy, managedDNSSECPolicyPrefix) {
+			return nil, fmt.Errorf("策略名称前缀 %s 为SteadyDNS保留，自定义策略请指定KSK和ZSK参数", managedDNSSECPolicyPrefix)
+		}
+		if !dnssecPolicyNameRegex.MatchString(policy) {
+			return nil, fmt.Errorf("DNSSEC策略名称无效: %s", policy)
+		}
+		return &DNSSECConfig{Enabled: true, Policy: policy}, nil
+	}
+
+	if cfg.KSK == nil || cfg.ZSK == nil {
+		return nil, fmt.Errorf("自定义DNSSEC策略需同时指定KSK和ZSK参数")
+	}
+	if policy != "" && policy != managed {
+		return nil, fmt.Errorf("指定KSK和ZSK参数时不能同时指定策略名称")
+	}
+	ksk, err := normalizeDNSSECKeySpec("KSK", cfg.KSK)
+	if err != nil {
+		return nil, err
+	}
+	zsk, err := normalizeDNSSECKeySpec("ZSK", cfg.ZSK)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSSECConfig{Enabled: true, Policy: managed, KSK: ksk, ZSK: zsk}, nil
+}
+
+// ValidateDNSSECConfig 校验区域的DNSSEC配置
+func ValidateDNSSECConfig(domain string, cfg *DNSSECConfig) error {
+	_, err := resolveDNSSEC(domain, cfg, nil)
+	return err
+}
+
+// renderDNSSECClauses 使用namedconf生成器渲染zone配置块中的dnssec-policy子句（已缩进）
+func renderDNSSECClauses(cfg *DNSSECConfig) string {
+	if cfg == nil || cfg.Policy == "" || cfg.Policy == DNSSECPolicyNone {
+		return ""
+	}
+	root := &namedconf.ConfigElement{
+		Type: "root",
+		ChildElements: []namedconf.ConfigElement{
+			{Name: "dnssec-policy", Type: "simple", Value: cfg.Policy},
+			// 内联签名使zone文件保持未签名内容，签名结果由BIND写入单独的.signed文件
+			{Name: "inline-signing", Type: "keyword", Value: "yes"},
+		},
+	}
+	content, _ := namedconf.NewGenerator().Generate(root)
+
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		sb.WriteString("    " + line + "\n")
+	}
+	return sb.String()
+}
+
+// parseZoneDNSSEC 从zone配置块中提取dnssec-policy，自定义策略的密钥参数从受管策略文件中获取
+func parseZoneDNSSEC(block string, policies map[string]DNSSECConfig) *DNSSECConfig {
+	m := dnssecPolicyRegex.FindStringSubmatch(block)
+	if m == nil || m[1] == DNSSECPolicyNone {
+		return nil
+	}
+	cfg := &DNSSECConfig{Enabled: m[1] != DNSSECPolicyInsecure, Policy: m[1]}
+	if policy, ok := policies[m[1]]; ok {
+		cfg.KSK, cfg.ZSK = policy.KSK, policy.ZSK
+	}
+	return cfg
+}
+
+// RenderDNSSECPolicies 使用namedconf生成器将自定义策略渲染为dnssec-policy配置
+func RenderDNSSECPolicies(policies map[string]DNSSECConfig) (string, error) {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	root := &namedconf.ConfigElement{
+		Type:     "root",
+		Comments: []string{"由SteadyDNS管理，请勿手动修改"},
+	}
+	for _, name := range names {
+		policy := policies[name]
+		var keys []namedconf.ConfigElement
+		for _, key := range []struct {
+			role string
+			spec *DNSSECKeySpec
+		}{{"ksk", policy.KSK}, {"zsk", policy.ZSK}} {
+			if key.spec == nil {
+				continue
+			}
+			keys = append(keys, namedconf.ConfigElement{
+				Name:  key.role,
+				Type:  "keyword",
+				Value: fmt.Sprintf("lifetime %s algorithm %s", key.spec.Lifetime, key.spec.Algorithm),
+			})
+		}
+		root.ChildElements = append(root.ChildElements, namedconf.ConfigElement{
+			Name:  "dnssec-policy",
+			Type:  "block",
+			Value: name,
+			ChildElements: []namedconf.ConfigElement{
+				{Name: "keys", Type: "block", Value: "", ChildElements: keys},
+			},
+		})
+	}
+	return namedconf.NewGenerator().Generate(root)
+}
+
+// parseDNSSECPolicies 从解析后的配置中提取自定义策略的KSK和ZSK参数
+func parseDNSSECPolicies(root *namedconf.ConfigElement) map[string]DNSSECConfig {
+	policies := make(map[string]DNSSECConfig)
+	for _, element := range root.ChildElements {
+		name, _ := element.Value.(string)
+		if element.Name != "dnssec-policy" || element.Type != "block" || name == "" {
+			continue
+		}
+		policy := DNSSECConfig{Enabled: true, Policy: name}
+		for _, child := range element.ChildElements {
+			if child.Name != "keys" {
+				continue
+			}
+			for _, key := range child.ChildElements {
+				value, _ := key.Value.(string)
+				fields := strings.Fields(value)
+				spec := &DNSSECKeySpec{}
+				for i := 0; i+1 < len(fields); i += 2 {
+					switch fields[i] {
+					case "lifetime":
+						spec.Lifetime = fields[i+1]
+					case "algorithm":
+						spec.Algorithm = fields[i+1]
+					}
+				}
+				switch key.Name {
+				case "ksk":
+					policy.KSK = spec
+				case "zsk":
+					policy.ZSK = spec
+				}
+			}
+		}
+		policies[name] = policy
+	}
+	return policies
+}
+
+// dnssecPoliciesFilePath 返回受管dnssec-policy配置文件路径
+func (bm *BindManager) dnssecPoliciesFilePath() string {
+	return filepath.Join(bm.config.NamedConfPath, DNSSECPoliciesFileName)
+}
+
+// loadDNSSECPolicies 读取受管策略文件中的自定义策略，文件不存在时返回空集合
+func (bm *BindManager) loadDNSSECPolicies() (map[string]DNSSECConfig, error) {
+	path := bm.dnssecPoliciesFilePath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return make(map[string]DNSSECConfig), nil
+	}
+	root, err := namedconf.NewParser(path).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("解析DNSSEC策略文件失败: %v", err)
+	}
+	return parseDNSSECPolicies(root), nil
+}
+
+// syncDNSSECPolicy 按区域的DNSSEC配置更新受管策略文件，并确保named.conf引入该文件
+// 返回的恢复函数将策略文件还原为修改前的内容，named.conf由调用方负责回滚
+func (bm *BindManager) syncDNSSECPolicy(domain string, cfg *DNSSECConfig) (func(), error) {
+	policies, err := bm.loadDNSSECPolicies()
+	if err != nil {
+		return nil, err
+	}
+	name := managedDNSSECPolicyName(domain)
+	_, exists := policies[name]
+	custom := cfg != nil && cfg.Policy == name
+	if !custom && !exists {
+		return func() {}, nil
+	}
+	if custom {
+		policies[name] = *cfg
+	} else {
+		delete(policies, name)
+	}
+
+	content, err := RenderDNSSECPolicies(policies)
+	if err != nil {
+		return nil, fmt.Errorf("生成DNSSEC策略配置失败: %v", err)
+	}
+	path := bm.dnssecPoliciesFilePath()
+	original, readErr := os.ReadFile(path)
+	restore := func() {
+		if readErr != nil {
+			// 文件此前不存在，写入空策略集合，保证named.conf中的include仍然有效
+			empty, _ := RenderDNSSECPolicies(nil)
+			original = []byte(empty)
+		}
+		os.WriteFile(path, original, 0644)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return nil, fmt.Errorf("写入DNSSEC策略文件失败: %v", err)
+	}
+	bm.chownToBind(path)
+
+	namedConfPath := filepath.Join(bm.config.NamedConfPath, "named.conf")
+	if err := bm.ensureManagedInclude(namedConfPath, path); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
+
+// parseDNSSECStatus 解析 rndc dnssec -status 的输出
+func parseDNSSECStatus(output string) []DNSSECKeyStatus {
+	keys := make([]DNSSECKeyStatus, 0)
+	var current *DNSSECKeyStatus
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m := dnssecKeyLineRegex.FindStringSubmatch(line); m != nil {
+			tag, _ := strconv.Atoi(m[1])
+			keys = append(keys, DNSSECKeyStatus{KeyTag: tag, Algorithm: m[2], Role: m[3], States: make(map[string]string)})
+			current = &keys[len(keys)-1]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Next rollover scheduled on "):
+			current.NextRollover = strings.TrimPrefix(line, "Next rollover scheduled on ")
+		case strings.HasPrefix(line, "- "):
+			if key, value, ok := strings.Cut(strings.TrimPrefix(line, "- "), ":"); ok {
+				current.States[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch strings.TrimSpace(key) {
+			case "published":
+				current.Published = value
+			case "key signing":
+				current.KeySigning = value
+			case "zone signing":
+				current.ZoneSigning = value
+			}
+		}
+	}
+	return keys
+}
+
+// dnssecExpiryWarning 返回签名过期告警阈值
+func dnssecExpiryWarning() time.Duration {
+	return time.Duration(common.GetConfigInt("BIND", "DNSSEC_EXPIRY_WARNING_HOURS", 72)) * time.Hour
+}
+
+// queryZoneApex 向BIND查询区域顶点记录并请求DNSSEC签名
+func (bm *BindManager) queryZoneApex(domain string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(domain), qtype)
+	msg.SetEdns0(4096, true)
+	// DNSKEY应答可能较大，使用TCP避免截断
+	client := &dns.Client{Net: "tcp", Timeout: 3 * time.Second}
+	resp, _, err := client.Exchange(msg, bm.config.Address)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("查询返回 %s", dns.RcodeToString[resp.Rcode])
+	}
+	return resp, nil
+}
+
+// checkDNSSECSignatures 查询区域的DNSKEY和SOA签名，生成DS记录并获取最早的签名过期时间
+func (bm *BindManager) checkDNSSECSignatures(status *DNSSECStatus) error {
+	var rrs []dns.RR
+	for _, qtype := range []uint16{dns.TypeDNSKEY, dns.TypeSOA} {
+		resp, err := bm.queryZoneApex(status.Domain, qtype)
+		if err != nil {
+			return err
+		}
+		rrs = append(rrs, resp.Answer...)
+	}
+
+	for _, rr := range rrs {
+		switch v := rr.(type) {
+		case *dns.DNSKEY:
+			if v.Flags&dns.SEP == 0 {
+				continue
+			}
+			ds := v.ToDS(dns.SHA256)
+			if ds == nil {
+				continue
+			}
+			record := ds.String()
+			status.DS = append(status.DS, record)
+			for i := range status.Keys {
+				if status.Keys[i].KeyTag == int(v.KeyTag()) {
+					status.Keys[i].DS = append(status.Keys[i].DS, record)
+				}
+			}
+		case *dns.RRSIG:
+			// 签名时间按RFC 4034为32位秒数，2106年前可直接换算
+			expiration := time.Unix(int64(v.Expiration), 0)
+			if status.SignatureExpiration == nil || expiration.Before(*status.SignatureExpiration) {
+				status.SignatureExpiration = &expiration
+			}
+		}
+	}
+	return nil
+}
+
+// dnssecWarnings 根据签名状态生成告警信息
+func dnssecWarnings(status *DNSSECStatus, now time.Time, threshold time.Duration) []string {
+	warnings := make([]string, 0)
+	if !status.Enabled {
+		return warnings
+	}
+	if exp := status.SignatureExpiration; exp != nil {
+		if exp.Sub(now) < threshold {
+			status.ExpiringSoon = true
+			warnings = append(warnings, fmt.Sprintf("区域签名将于 %s 过期", exp.Local().Format("2006-01-02 15:04:05")))
+		}
+	}
+	for _, key := range status.Keys {
+		if key.Role != "KSK" && key.Role != "CSK" {
+			continue
+		}
+		if ds := key.States["ds"]; ds != "" && ds != "omnipresent" {
+			warnings = append(warnings, fmt.Sprintf("密钥 %d 的DS记录尚未在父区域生效（%s），请将DS记录提交至父区域后执行 rndc dnssec -checkds published", key.KeyTag, ds))
+		}
+	}
+	return warnings
+}
+
+// dnssecStatus 获取区域的DNSSEC签名状态
+func (bm *BindManager) dnssecStatus(zone AuthZone) *DNSSECStatus {
+	status := &DNSSECStatus{
+		Domain:   zone.Domain,
+		Keys:     make([]DNSSECKeyStatus, 0),
+		Warnings: make([]string, 0),
+	}
+	if zone.DNSSEC == nil {
+		return status
+	}
+	status.Enabled = zone.DNSSEC.Enabled
+	status.Policy = zone.DNSSEC.Policy
+
+	output, err := bm.runRndc("dnssec", "-status", zone.Domain)
+	status.Details = output
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Keys = parseDNSSECStatus(output)
+	}
+
+	if !status.Enabled {
+		return status
+	}
+	if err := bm.checkDNSSECSignatures(status); err != nil {
+		status.Warnings = append(status.Warnings, fmt.Sprintf("查询区域签名失败: %v", err))
+		return status
+	}
+	status.Warnings = append(status.Warnings, dnssecWarnings(status, time.Now(), dnssecExpiryWarning())...)
+	if status.SignatureExpiration == nil {
+		status.Warnings = append(status.Warnings, "未查询到区域签名，区域可能尚未完成签名")
+	}
+	return status
+}
+
+// GetDNSSECStatus 获取单个区域的DNSSEC密钥状态、DS记录和签名有效期
+func (bm *BindManager) GetDNSSECStatus(domain string) (*DNSSECStatus, error) {
+	zone, err := bm.GetAuthZone(domain)
+	if err != nil {
+		return nil, err
+	}
+	return bm.dnssecStatus(*zone), nil
+}
+
+// GetDNSSECStatuses 获取所有启用DNSSEC的区域的签名状态
+func (bm *BindManager) GetDNSSECStatuses() ([]DNSSECStatus, error) {
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]DNSSECStatus, 0)
+	for _, zone := range zones {
+		if zone.DNSSEC == nil || !zone.DNSSEC.Enabled {
+			continue
+		}
+		statuses = append(statuses, *bm.dnssecStatus(zone))
+	}
+	return statuses, nil
+}
@@ -0,0 +1,240 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/dnssec_test.go
+// DNSSEC策略校验、配置渲染解析和rndc dnssec -status解析测试
+
+package bind
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"SteadyDNS/core/bind/namedconf"
+	"SteadyDNS/core/common"
+)
+
+// TestResolveDNSSEC 测试DNSSEC配置的校验和规范化
+func TestResolveDNSSEC(t *testing.T) {
+	existing := &DNSSECConfig{Enabled: true, Policy: DNSSECPolicyDefault}
+	custom := &DNSSECConfig{
+		Enabled: true,
+		KSK:     &DNSSECKeySpec{Algorithm: "ECDSAP256SHA256", Lifetime: "unlimited"},
+		ZSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "p90d"},
+	}
+	cases := []struct {
+		name     string
+		cfg      *DNSSECConfig
+		existing *DNSSECConfig
+		want     *DNSSECConfig
+		ok       bool
+	}{
+		{"为空时保持不变", nil, existing, existing, true},
+		{"启用默认策略", &DNSSECConfig{Enabled: true}, nil, &DNSSECConfig{Enabled: true, Policy: DNSSECPolicyDefault}, true},
+		{"自定义密钥参数", custom, nil, &DNSSECConfig{
+			Enabled: true,
+			Policy:  "steadydns-example.com",
+			KSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "unlimited"},
+			ZSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "P90D"},
+		}, true},
+		{"关闭已签名区域过渡到insecure", &DNSSECConfig{}, existing, &DNSSECConfig{Policy: DNSSECPolicyInsecure}, true},
+		{"关闭未签名区域", &DNSSECConfig{}, nil, nil, true},
+		{"显式移除策略", &DNSSECConfig{Policy: DNSSECPolicyNone}, existing, nil, true},
+		{"启用时策略为insecure", &DNSSECConfig{Enabled: true, Policy: "insecure"}, nil, nil, false},
+		{"保留的策略前缀", &DNSSECConfig{Enabled: true, Policy: "steadydns-other"}, nil, nil, false},
+		{"只指定KSK", &DNSSECConfig{Enabled: true, KSK: custom.KSK}, nil, nil, false},
+		{"不支持的算法", &DNSSECConfig{Enabled: true, KSK: &DNSSECKeySpec{Algorithm: "rsamd5"}, ZSK: custom.ZSK}, nil, nil, false},
+		{"无效的生命周期", &DNSSECConfig{Enabled: true, KSK: custom.KSK, ZSK: &DNSSECKeySpec{Algorithm: "ed25519", Lifetime: "90 days"}}, nil, nil, false},
+	}
+	for _, c := range cases {
+		got, err := resolveDNSSEC("example.com", c.cfg, c.existing)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: err = %v", c.name, err)
+			continue
+		}
+		if c.ok && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v，期望 %+v", c.name, got, c.want)
+		}
+	}
+}
+
+// TestDNSSECPoliciesRoundTrip 测试自定义策略渲染后可被namedconf解析器读回
+func TestDNSSECPoliciesRoundTrip(t *testing.T) {
+	policies := map[string]DNSSECConfig{
+		"steadydns-example.com": {
+			Enabled: true,
+			Policy:  "steadydns-example.com",
+			KSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "P1Y"},
+			ZSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "P90D"},
+		},
+	}
+	content, err := RenderDNSSECPolicies(policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`dnssec-policy "steadydns-example.com" {`,
+		"        ksk lifetime P1Y algorithm ecdsap256sha256;\n",
+		"        zsk lifetime P90D algorithm ecdsap256sha256;\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("策略配置缺少 %q:\n%s", want, content)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), DNSSECPoliciesFileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root, err := namedconf.NewParser(path).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseDNSSECPolicies(root); !reflect.DeepEqual(got, policies) {
+		t.Errorf("解析结果 %+v 与渲染前不一致", got)
+	}
+}
+
+// TestZoneDNSSECClauses 测试zone配置块中dnssec-policy子句的写入和读回
+func TestZoneDNSSECClauses(t *testing.T) {
+	clauses := renderDNSSECClauses(&DNSSECConfig{Enabled: true, Policy: "default"})
+	if clauses != "    dnssec-policy \"default\";\n    inline-signing yes;\n" {
+		t.Errorf("dnssec-policy子句不符合预期:\n%s", clauses)
+	}
+	if renderDNSSECClauses(nil) != "" {
+		t.Error("未配置DNSSEC时不应写入子句")
+	}
+
+	block := "zone \"example.com\" IN {\n    type master;\n" + clauses + "};"
+	cfg := parseZoneDNSSEC(block, nil)
+	if cfg == nil || !cfg.Enabled || cfg.Policy != "default" {
+		t.Errorf("parseZoneDNSSEC = %+v", cfg)
+	}
+	cfg = parseZoneDNSSEC(`zone "example.com" IN { dnssec-policy insecure; };`, nil)
+	if cfg == nil || cfg.Enabled || cfg.Policy != DNSSECPolicyInsecure {
+		t.Errorf("insecure策略应解析为未启用: %+v", cfg)
+	}
+	if cfg := parseZoneDNSSEC(`zone "example.com" IN { type master; };`, nil); cfg != nil {
+		t.Errorf("未配置dnssec-policy时应返回nil: %+v", cfg)
+	}
+}
+
+// TestDNSSECZoneNamedConf 测试自定义策略写入受管文件和zone配置块，并通过GetAuthZones读回
+func TestDNSSECZoneNamedConf(t *testing.T) {
+	dir := t.TempDir()
+	namedConf := filepath.Join(dir, "named.conf")
+	if err := os.WriteFile(namedConf, []byte("// named.conf\n\noptions {\n};\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zoneContent := "$TTL 3600\n@ IN SOA ns1.example.com. admin.example.com. 2026101701 3600 900 604800 300\n@ IN NS ns1.example.com.\nns1 IN A 192.0.2.1\n"
+	if err := os.WriteFile(filepath.Join(dir, "example.com.zone"), []byte(zoneContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bm := &BindManager{
+		config: BindConfig{NamedConfPath: dir, ZoneFilePath: dir},
+		logger: common.NewLoggerWithLevel(common.ERROR),
+	}
+
+	dnssec, err := resolveDNSSEC("example.com", &DNSSECConfig{
+		Enabled: true,
+		KSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "P1Y"},
+		ZSK:     &DNSSECKeySpec{Algorithm: "ecdsap256sha256", Lifetime: "P90D"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := AuthZone{Domain: "example.com", AllowQuery: "any", DNSSEC: dnssec}
+	if _, err := bm.syncDNSSECPolicy(zone.Domain, zone.DNSSEC); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.addZoneToNamedConf(namedConf, zone, "example.com.zone"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(namedConf)
+	if !strings.Contains(string(content), `include "`+bm.dnssecPoliciesFilePath()+`";`) ||
+		!strings.Contains(string(content), `dnssec-policy "steadydns-example.com";`) {
+		t.Errorf("named.conf缺少DNSSEC配置:\n%s", content)
+	}
+
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 || !reflect.DeepEqual(zones[0].DNSSEC, dnssec) {
+		t.Fatalf("读回的DNSSEC配置不符合预期: %+v", zones)
+	}
+
+	// 删除区域后移除专用策略
+	if _, err := bm.syncDNSSECPolicy(zone.Domain, nil); err != nil {
+		t.Fatal(err)
+	}
+	if policies, _ := bm.loadDNSSECPolicies(); len(policies) != 0 {
+		t.Errorf("专用策略未被移除: %+v", policies)
+	}
+}
+
+// TestParseDNSSECStatus 测试rndc dnssec -status输出解析
+func TestParseDNSSECStatus(t *testing.T) {
+	output := `dnssec-policy: steadydns-example.com
+current time:  Sat Oct 17 10:00:00 2026
+
+key: 21416 (ECDSAP256SHA256), KSK
+  published:      yes - since Sat Oct 10 09:50:13 2026
+  key signing:    yes - since Sat Oct 10 09:50:13 2026
+
+  No rollover scheduled
+  - goal:           omnipresent
+  - dnskey:         omnipresent
+  - ds:             rumoured
+  - key rrsig:      omnipresent
+
+key: 41134 (ECDSAP256SHA256), ZSK
+  published:      yes - since Sat Oct 10 09:50:13 2026
+  zone signing:   yes - since Sat Oct 10 09:50:13 2026
+
+  Next rollover scheduled on Fri Jan 08 09:50:13 2027
+  - goal:           omnipresent
+  - dnskey:         omnipresent
+  - zone rrsig:     omnipresent
+`
+	keys := parseDNSSECStatus(output)
+	if len(keys) != 2 {
+		t.Fatalf("解析到 %d 个密钥，期望 2", len(keys))
+	}
+	ksk, zsk := keys[0], keys[1]
+	if ksk.KeyTag != 21416 || ksk.Role != "KSK" || ksk.Algorithm != "ECDSAP256SHA256" || ksk.States["ds"] != "rumoured" {
+		t.Errorf("KSK解析结果不符合预期: %+v", ksk)
+	}
+	if !strings.HasPrefix(ksk.KeySigning, "yes") || ksk.NextRollover != "" {
+		t.Errorf("KSK签名状态解析错误: %+v", ksk)
+	}
+	if zsk.Role != "ZSK" || zsk.NextRollover != "Fri Jan 08 09:50:13 2027" || zsk.States["zone rrsig"] != "omnipresent" {
+		t.Errorf("ZSK解析结果不符合预期: %+v", zsk)
+	}
+
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	expiration := now.Add(24 * time.Hour)
+	status := &DNSSECStatus{Enabled: true, Keys: keys, SignatureExpiration: &expiration}
+	warnings := dnssecWarnings(status, now, 72*time.Hour)
+	if !status.ExpiringSoon || len(warnings) != 2 {
+		t.Errorf("签名即将过期且DS未生效时应产生2条告警: %v", warnings)
+	}
+}
@@ -0,0 +1,499 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/dynupdate.go
+// RFC 2136 动态更新：前提条件检查、按密钥的授权策略和记录变更
+
+package bind
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// 授权规则的名称匹配方式，与BIND update-policy一致
+const (
+	UpdateMatchName      = "name"      // 名称完全相同
+	UpdateMatchSubdomain = "subdomain" // 名称本身及其下级
+	UpdateMatchWildcard  = "wildcard"  // *.名称，仅匹配下级
+	UpdateMatchZonesub   = "zonesub"   // 指定区域内的任意名称
+)
+
+// UpdateGrant 动态更新授权规则
+type UpdateGrant struct {
+	Deny  bool     // true为deny规则
+	Key   string   // TSIG密钥名（绝对形式，小写）
+	Match string   // 名称匹配方式
+	Name  string   // 匹配的名称（绝对形式，小写），zonesub时为规则适用的区域
+	Types []uint16 // 允许的记录类型，为空时允许除SOA、NS和DNSSEC记录外的所有类型
+}
+
+// UpdatePolicy 动态更新授权策略，按顺序取第一条匹配的规则，无匹配时拒绝
+type UpdatePolicy struct {
+	Grants []UpdateGrant
+}
+
+// DynamicUpdate 一次RFC 2136动态更新请求
+type DynamicUpdate struct {
+	Zone    string   // 区域段中的区域名
+	Prereqs []dns.RR // 前提条件段
+	Updates []dns.RR // 更新段
+	Key     string   // 已通过TSIG校验的密钥名
+}
+
+// DynamicUpdateResult 动态更新结果
+type DynamicUpdateResult struct {
+	Rcode   int      // 响应码
+	Changed bool     // zone文件是否被修改
+	Names   []string // 发生变更的名称（绝对形式），用于清除缓存
+}
+
+// excludedUpdateTypes 类型为空的授权规则不包含的记录类型
+var excludedUpdateTypes = map[uint16]bool{
+	dns.TypeSOA:        true,
+	dns.TypeNS:         true,
+	dns.TypeRRSIG:      true,
+	dns.TypeNSEC:       true,
+	dns.TypeNSEC3:      true,
+	dns.TypeNSEC3PARAM: true,
+	dns.TypeDNSKEY:     true,
+}
+
+// ParseUpdatePolicy 解析授权策略，规则之间以分号分隔，格式参照BIND update-policy：
+//
+//	grant|deny <密钥名> name|subdomain|wildcard <名称> [类型...]
+//	grant|deny <密钥名> zonesub <区域> [类型...]
+//
+// 策略对所有区域生效，因此zonesub规则需指明区域，只授权该区域内的名称
+func ParseUpdatePolicy(text string) (*UpdatePolicy, error) {
+	policy := &UpdatePolicy{}
+	for _, rule := range strings.Split(text, ";") {
+		fields := strings.Fields(rule)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("授权规则格式不正确: %s", strings.TrimSpace(rule))
+		}
+
+		grant := UpdateGrant{Key: dns.CanonicalName(fields[1]), Match: strings.ToLower(fields[2])}
+		switch strings.ToLower(fields[0]) {
+		case "grant":
+		case "deny":
+			grant.Deny = true
+		default:
+			return nil, fmt.Errorf("授权规则必须以grant或deny开头: %s", strings.TrimSpace(rule))
+		}
+
+		switch grant.Match {
+		case UpdateMatchName, UpdateMatchSubdomain, UpdateMatchWildcard, UpdateMatchZonesub:
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("授权规则缺少名称: %s", strings.TrimSpace(rule))
+			}
+			grant.Name = dns.CanonicalName(fields[3])
+			if _, ok := dns.IsDomainName(grant.Name); !ok {
+				return nil, fmt.Errorf("授权规则名称无效: %s", fields[3])
+			}
+			if grant.Match == UpdateMatchWildcard {
+				if !strings.HasPrefix(grant.Name, "*.") {
+					return nil, fmt.Errorf("wildcard规则的名称必须以*.开头: %s", fields[3])
+				}
+				grant.Name = grant.Name[2:]
+			}
+		default:
+			return nil, fmt.Errorf("不支持的名称匹配方式: %s", fields[2])
+		}
+
+		for _, t := range fields[4:] {
+			rrtype, ok := dns.StringToType[strings.ToUpper(t)]
+			if !ok {
+				return nil, fmt.Errorf("授权规则中的记录类型无效: %s", t)
+			}
+			grant.Types = append(grant.Types, rrtype)
+		}
+		policy.Grants = append(policy.Grants, grant)
+	}
+	return policy, nil
+}
+
+// matchName 判断规则的名称部分是否匹配
+func (g UpdateGrant) matchName(zone, name string) bool {
+	switch g.Match {
+	case UpdateMatchName:
+		return name == g.Name
+	case UpdateMatchSubdomain:
+		return dns.IsSubDomain(g.Name, name)
+	case UpdateMatchWildcard:
+		return name != g.Name && dns.IsSubDomain(g.Name, name)
+	case UpdateMatchZonesub:
+		return zone == g.Name && dns.IsSubDomain(zone, name)
+	}
+	return false
+}
+
+// matchType 判断规则的类型部分是否匹配，ANY只匹配不限类型或显式列出ANY的规则
+func (g UpdateGrant) matchType(rrtype uint16) bool {
+	if len(g.Types) == 0 {
+		return !excludedUpdateTypes[rrtype]
+	}
+	return slices.Contains(g.Types, rrtype) || slices.Contains(g.Types, dns.TypeANY)
+}
+
+// Allows 判断密钥是否可以修改区域zone中名称name的rrtype类型记录
+func (p *UpdatePolicy) Allows(key, zone, name string, rrtype uint16) bool {
+	if p == nil {
+		return false
+	}
+	key, zone, name = dns.CanonicalName(key), dns.CanonicalName(zone), dns.CanonicalName(name)
+	for _, g := range p.Grants {
+		if g.Key == key && g.matchName(zone, name) && g.matchType(rrtype) {
+			return !g.Deny
+		}
+	}
+	return false
+}
+
+// updateState 动态更新处理过程中的区域数据
+type updateState struct {
+	apex    string
+	soa     SOARecord
+	records []Record
+	names   map[string]bool
+}
+
+// owner 记录的绝对名称（小写）
+func (s *updateState) owner(r Record) string {
+	return dns.CanonicalName(absoluteName(r.Name, s.apex))
+}
+
+// nameInUse 判断名称下是否存在任意记录，区域顶点始终存在SOA
+func (s *updateState) nameInUse(name string) bool {
+	if name == s.apex {
+		return true
+	}
+	return slices.ContainsFunc(s.records, func(r Record) bool { return s.owner(r) == name })
+}
+
+// rrset 返回名称下指定类型的记录，区域顶点的SOA由zone.SOA生成
+func (s *updateState) rrset(name string, rrtype uint16) []Record {
+	var set []Record
+	if rrtype == dns.TypeSOA {
+		if name == s.apex {
+			set = append(set, Record{Name: "@", Type: "SOA", Value: fmt.Sprintf("%s %s %s %s %s %s %s",
+				dns.Fqdn(s.soa.PrimaryNS), dns.Fqdn(s.soa.AdminEmail), s.soa.Serial, s.soa.Refresh, s.soa.Retry, s.soa.Expire, s.soa.MinimumTTL)})
+		}
+		return set
+	}
+	for _, r := range s.records {
+		if s.owner(r) == name && strings.EqualFold(r.Type, dns.Type(rrtype).String()) {
+			set = append(set, r)
+		}
+	}
+	return set
+}
+
+// recordFor 将更新段的资源记录转换为Record
+func (s *updateState) recordFor(rr dns.RR) Record {
+	rec := recordFromRR(rr, s.apex)
+	rec.TTL = int(rr.Header().Ttl)
+	return rec
+}
+
+// checkPrereqs 按RFC 2136 3.2节检查前提条件
+func (s *updateState) checkPrereqs(prereqs []dns.RR) int {
+	type rrsetKey struct {
+		name   string
+		rrtype uint16
+	}
+	valueSets := make(map[rrsetKey][]Record)
+	var order []rrsetKey
+
+	for _, rr := range prereqs {
+		hdr := rr.Header()
+		name := dns.CanonicalName(hdr.Name)
+		if hdr.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(s.apex, name) {
+			return dns.RcodeNotZone
+		}
+		_, empty := rr.(*dns.RR_Header)
+
+		switch hdr.Class {
+		case dns.ClassANY:
+			if !empty {
+				return dns.RcodeFormatError
+			}
+			if hdr.Rrtype == dns.TypeANY {
+				if !s.nameInUse(name) {
+					return dns.RcodeNameError
+				}
+			} else if len(s.rrset(name, hdr.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if !empty {
+				return dns.RcodeFormatError
+			}
+			if hdr.Rrtype == dns.TypeANY {
+				if s.nameInUse(name) {
+					return dns.RcodeYXDomain
+				}
+			} else if len(s.rrset(name, hdr.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			if empty || hdr.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+			key := rrsetKey{name, hdr.Rrtype}
+			if _, ok := valueSets[key]; !ok {
+				order = append(order, key)
+			}
+			valueSets[key] = append(valueSets[key], s.recordFor(rr))
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	// 值相关的前提条件：RRset必须与给定的记录集合完全相同
+	for _, key := range order {
+		want := valueSets[key]
+		have := s.rrset(key.name, key.rrtype)
+		for _, w := range want {
+			if !slices.ContainsFunc(have, func(h Record) bool { return sameRRSet(h, w) }) {
+				return dns.RcodeNXRrset
+			}
+		}
+		for _, h := range have {
+			if !slices.ContainsFunc(want, func(w Record) bool { return sameRRSet(h, w) }) {
+				return dns.RcodeNXRrset
+			}
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// prescan 按RFC 2136 3.4.1节检查更新段
+func (s *updateState) prescan(updates []dns.RR) int {
+	for _, rr := range updates {
+		hdr := rr.Header()
+		if !dns.IsSubDomain(s.apex, dns.CanonicalName(hdr.Name)) {
+			return dns.RcodeNotZone
+		}
+		switch hdr.Rrtype {
+		case dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB, dns.TypeOPT, dns.TypeTSIG:
+			return dns.RcodeFormatError
+		}
+		_, empty := rr.(*dns.RR_Header)
+
+		switch hdr.Class {
+		case dns.ClassINET:
+			if empty || hdr.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassANY:
+			if hdr.Ttl != 0 || !empty {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if hdr.Ttl != 0 || empty || hdr.Rrtype == dns.TypeANY {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// apply 按RFC 2136 3.4.2节执行更新段，返回是否有变更
+func (s *updateState) apply(updates []dns.RR, allows func(name string, rrtype uint16) bool) (bool, error) {
+	changed := false
+	touch := func(name string) {
+		changed = true
+		s.names[name] = true
+	}
+
+	for _, rr := range updates {
+		hdr := rr.Header()
+		name := dns.CanonicalName(hdr.Name)
+
+		switch hdr.Class {
+		case dns.ClassINET:
+			// SOA由SteadyDNS维护，序列号在写入时自动递增
+			if hdr.Rrtype == dns.TypeSOA {
+				continue
+			}
+			rec, err := canonicalRecord(s.recordFor(rr), s.apex)
+			if err != nil {
+				return false, err
+			}
+			// CNAME不能与其他数据共存，冲突的添加被忽略
+			isCNAME := hdr.Rrtype == dns.TypeCNAME
+			conflict := slices.ContainsFunc(s.records, func(r Record) bool {
+				return s.owner(r) == name && (r.Type == "CNAME") != isCNAME
+			})
+			if conflict {
+				continue
+			}
+			if i := slices.IndexFunc(s.records, func(r Record) bool { return s.owner(r) == name && sameRRSet(r, rec) }); i >= 0 {
+				if s.records[i].TTL != rec.TTL {
+					if s.records[i].Source != "" {
+						return false, fmt.Errorf("%w: 记录来自$INCLUDE文件%s，只读", ErrInvalidRecord, s.records[i].Source)
+					}
+					s.records[i].TTL = rec.TTL
+					touch(name)
+				}
+				continue
+			}
+			// CNAME只保留一条，替换已有的CNAME
+			if isCNAME {
+				if i := slices.IndexFunc(s.records, func(r Record) bool { return s.owner(r) == name && r.Type == "CNAME" }); i >= 0 {
+					if s.records[i].Source != "" {
+						return false, fmt.Errorf("%w: 记录来自$INCLUDE文件%s，只读", ErrInvalidRecord, s.records[i].Source)
+					}
+					rec.ID = s.records[i].ID
+					s.records[i] = rec
+					touch(name)
+					continue
+				}
+			}
+			s.records = append(s.records, rec)
+			touch(name)
+
+		case dns.ClassANY, dns.ClassNONE:
+			var target *Record
+			if hdr.Class == dns.ClassNONE {
+				rec := s.recordFor(rr)
+				target = &rec
+			}
+			var deleted []int
+			for i, r := range s.records {
+				if s.owner(r) != name {
+					continue
+				}
+				rrtype := dns.StringToType[strings.ToUpper(r.Type)]
+				switch {
+				case target != nil && !sameRRSet(r, *target):
+					continue
+				case target == nil && hdr.Rrtype != dns.TypeANY && rrtype != hdr.Rrtype:
+					continue
+				case hdr.Rrtype == dns.TypeANY && !allows(name, rrtype):
+					// 删除名称下所有记录时只删除密钥有权修改的类型
+					continue
+				case name == s.apex && rrtype == dns.TypeNS && target == nil:
+					// 区域顶点的NS记录不能整体删除
+					continue
+				}
+				if r.Source != "" {
+					return false, fmt.Errorf("%w: 记录来自$INCLUDE文件%s，只读", ErrInvalidRecord, r.Source)
+				}
+				deleted = append(deleted, i)
+			}
+			// 区域顶点至少保留一条NS记录
+			if name == s.apex && target != nil && hdr.Rrtype == dns.TypeNS && len(deleted) > 0 &&
+				len(s.rrset(name, dns.TypeNS)) <= len(deleted) {
+				continue
+			}
+			for i := len(deleted) - 1; i >= 0; i-- {
+				s.records = slices.Delete(s.records, deleted[i], deleted[i]+1)
+			}
+			if len(deleted) > 0 {
+				touch(name)
+			}
+		}
+	}
+	return changed, nil
+}
+
+// ApplyDynamicUpdate 执行一次动态更新
+// 依次进行区域检查、前提条件检查、授权检查和更新段预检查，有变更时通过updateAuthZone写入，
+// 因此同样经过HistoryManager备份、序列号递增、校验和BIND重新加载
+// 返回的错误用于记录日志，响应码以结果中的Rcode为准
+func (bm *BindManager) ApplyDynamicUpdate(u DynamicUpdate, policy *UpdatePolicy) (*DynamicUpdateResult, error) {
+	result := &DynamicUpdateResult{Rcode: dns.RcodeSuccess}
+	domain := strings.TrimSuffix(dns.CanonicalName(u.Zone), ".")
+
+	zoneFileMu.Lock()
+	defer zoneFileMu.Unlock()
+	defer bm.freezeDynamicZones()()
+
+	zone, _, err := bm.loadZoneWithETag(domain)
+	if err != nil {
+		result.Rcode = dns.RcodeNotAuth
+		return result, fmt.Errorf("区域 %s 不是本服务器管理的权威域: %v", u.Zone, err)
+	}
+	if zone.Type == ZoneTypeSlave {
+		// 辅区域的更新应发往主服务器
+		result.Rcode = dns.RcodeNotAuth
+		return result, fmt.Errorf("区域 %s 为辅区域，不接受动态更新", u.Zone)
+	}
+
+	state := &updateState{
+		apex:    dns.Fqdn(domain),
+		soa:     zone.SOA,
+		records: slices.Clone(zone.Records),
+		names:   make(map[string]bool),
+	}
+
+	if rcode := state.checkPrereqs(u.Prereqs); rcode != dns.RcodeSuccess {
+		result.Rcode = rcode
+		return result, fmt.Errorf("前提条件不满足: %s", dns.RcodeToString[rcode])
+	}
+
+	allows := func(name string, rrtype uint16) bool {
+		return policy.Allows(u.Key, state.apex, name, rrtype)
+	}
+	for _, rr := range u.Updates {
+		hdr := rr.Header()
+		if !allows(hdr.Name, hdr.Rrtype) {
+			result.Rcode = dns.RcodeRefused
+			return result, fmt.Errorf("密钥 %s 无权修改 %s %s", u.Key, hdr.Name, dns.Type(hdr.Rrtype).String())
+		}
+	}
+
+	if rcode := state.prescan(u.Updates); rcode != dns.RcodeSuccess {
+		result.Rcode = rcode
+		return result, fmt.Errorf("更新段格式不正确: %s", dns.RcodeToString[rcode])
+	}
+
+	changed, err := state.apply(u.Updates, allows)
+	if err != nil {
+		result.Rcode = dns.RcodeRefused
+		return result, err
+	}
+	if !changed {
+		return result, nil
+	}
+
+	zone.Records = state.records
+	if err := bm.updateAuthZone(*zone, true); err != nil {
+		result.Rcode = dns.RcodeServerFailure
+		return result, err
+	}
+
+	result.Changed = true
+	for name := range state.names {
+		result.Names = append(result.Names, name)
+	}
+	slices.Sort(result.Names)
+	return result, nil
+}
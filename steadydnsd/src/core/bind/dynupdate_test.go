@@ -0,0 +1,239 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/dynupdate_test.go
+// 动态更新的授权策略、前提条件和记录变更测试
+
+package bind
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// mustRR 解析测试用的资源记录
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("dns.NewRR(%q) error = %v", s, err)
+	}
+	return rr
+}
+
+// emptyRR 构造类为ANY或NONE、无RDATA的资源记录
+func emptyRR(name string, rrtype, class uint16) dns.RR {
+	return &dns.RR_Header{Name: name, Rrtype: rrtype, Class: class}
+}
+
+// newTestUpdateState 创建example.com的测试区域数据
+func newTestUpdateState() *updateState {
+	return &updateState{
+		apex: "example.com.",
+		soa:  SOARecord{PrimaryNS: "ns1.example.com.", AdminEmail: "admin.example.com.", Serial: "2026031501", Refresh: "3600", Retry: "1800", Expire: "604800", MinimumTTL: "300"},
+		records: []Record{
+			{Name: "@", Type: "NS", Value: "ns1.example.com."},
+			{Name: "ns1", Type: "A", Value: "192.0.2.53"},
+			{Name: "host1", Type: "A", Value: "192.0.2.10", TTL: 300},
+			{Name: "host1", Type: "A", Value: "192.0.2.11", TTL: 300},
+			{Name: "www", Type: "CNAME", Value: "host1.example.com."},
+			{Name: "inc", Type: "A", Value: "192.0.2.99", Source: "include/inc.zone"},
+		},
+		names: make(map[string]bool),
+	}
+}
+
+// TestParseUpdatePolicy 测试授权策略的解析和匹配
+func TestParseUpdatePolicy(t *testing.T) {
+	policy, err := ParseUpdatePolicy("deny dhcp name secure.example.com A; grant DHCP subdomain example.com A AAAA; " +
+		"grant acme wildcard *.example.com TXT; grant admin zonesub example.com")
+	if err != nil {
+		t.Fatalf("ParseUpdatePolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		key, name string
+		rrtype    uint16
+		want      bool
+	}{
+		{"dhcp.", "host.example.com.", dns.TypeA, true},
+		{"dhcp", "Example.COM", dns.TypeAAAA, true},
+		{"dhcp", "secure.example.com", dns.TypeA, false},
+		{"dhcp", "host.example.com", dns.TypeTXT, false},
+		{"dhcp", "host.example.org", dns.TypeA, false},
+		{"acme", "_acme-challenge.www.example.com", dns.TypeTXT, true},
+		{"acme", "example.com", dns.TypeTXT, false},
+		{"admin", "anything.example.com", dns.TypeMX, true},
+		{"admin", "example.com", dns.TypeNS, false},
+		{"admin", "example.com", dns.TypeANY, true},
+		{"admin", "host.example.org", dns.TypeA, false},
+		{"dhcp", "host.example.com", dns.TypeANY, false},
+		{"unknown", "host.example.com", dns.TypeA, false},
+	}
+	for _, tt := range tests {
+		if got := policy.Allows(tt.key, "example.com.", tt.name, tt.rrtype); got != tt.want {
+			t.Errorf("Allows(%s, %s, %s) = %v, want %v", tt.key, tt.name, dns.TypeToString[tt.rrtype], got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"allow key zonesub example.com",
+		"grant key zonesub",
+		"grant key",
+		"grant key subdomain",
+		"grant key wildcard example.com A",
+		"grant key self example.com",
+		"grant key zonesub example.com BOGUS",
+	} {
+		if _, err := ParseUpdatePolicy(bad); err == nil {
+			t.Errorf("ParseUpdatePolicy(%q) 应返回错误", bad)
+		}
+	}
+}
+
+// TestDynamicUpdatePrereqs 测试RFC 2136 3.2节的前提条件
+func TestDynamicUpdatePrereqs(t *testing.T) {
+	tests := []struct {
+		name    string
+		prereqs []dns.RR
+		want    int
+	}{
+		{"名称存在", []dns.RR{emptyRR("host1.example.com.", dns.TypeANY, dns.ClassANY)}, dns.RcodeSuccess},
+		{"名称不存在", []dns.RR{emptyRR("new.example.com.", dns.TypeANY, dns.ClassANY)}, dns.RcodeNameError},
+		{"RRset存在", []dns.RR{emptyRR("host1.example.com.", dns.TypeA, dns.ClassANY)}, dns.RcodeSuccess},
+		{"SOA存在", []dns.RR{emptyRR("example.com.", dns.TypeSOA, dns.ClassANY)}, dns.RcodeSuccess},
+		{"RRset不存在", []dns.RR{emptyRR("host1.example.com.", dns.TypeAAAA, dns.ClassANY)}, dns.RcodeNXRrset},
+		{"名称未使用", []dns.RR{emptyRR("new.example.com.", dns.TypeANY, dns.ClassNONE)}, dns.RcodeSuccess},
+		{"名称已使用", []dns.RR{emptyRR("example.com.", dns.TypeANY, dns.ClassNONE)}, dns.RcodeYXDomain},
+		{"RRset已存在", []dns.RR{emptyRR("www.example.com.", dns.TypeCNAME, dns.ClassNONE)}, dns.RcodeYXRrset},
+		{"值相同", []dns.RR{
+			mustRR(t, "host1.example.com. 0 IN A 192.0.2.11"),
+			mustRR(t, "host1.example.com. 0 IN A 192.0.2.10"),
+		}, dns.RcodeSuccess},
+		{"值不完整", []dns.RR{mustRR(t, "host1.example.com. 0 IN A 192.0.2.10")}, dns.RcodeNXRrset},
+		{"区域外", []dns.RR{emptyRR("host.example.org.", dns.TypeANY, dns.ClassANY)}, dns.RcodeNotZone},
+		{"TTL非零", []dns.RR{mustRR(t, "host1.example.com. 60 IN A 192.0.2.10")}, dns.RcodeFormatError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestUpdateState().checkPrereqs(tt.prereqs); got != tt.want {
+				t.Errorf("checkPrereqs() = %s, want %s", dns.RcodeToString[got], dns.RcodeToString[tt.want])
+			}
+		})
+	}
+}
+
+// TestDynamicUpdatePrescan 测试RFC 2136 3.4.1节的更新段检查
+func TestDynamicUpdatePrescan(t *testing.T) {
+	tests := []struct {
+		name string
+		rr   dns.RR
+		want int
+	}{
+		{"添加", mustRR(t, "new.example.com. 300 IN A 192.0.2.1"), dns.RcodeSuccess},
+		{"删除RRset", emptyRR("host1.example.com.", dns.TypeA, dns.ClassANY), dns.RcodeSuccess},
+		{"删除记录", &dns.A{Hdr: dns.RR_Header{Name: "host1.example.com.", Rrtype: dns.TypeA, Class: dns.ClassNONE}}, dns.RcodeSuccess},
+		{"区域外", mustRR(t, "new.example.org. 300 IN A 192.0.2.1"), dns.RcodeNotZone},
+		{"添加ANY", emptyRR("new.example.com.", dns.TypeANY, dns.ClassINET), dns.RcodeFormatError},
+		{"删除TTL非零", &dns.RR_Header{Name: "host1.example.com.", Rrtype: dns.TypeA, Class: dns.ClassANY, Ttl: 60}, dns.RcodeFormatError},
+		{"元类型", emptyRR("host1.example.com.", dns.TypeAXFR, dns.ClassANY), dns.RcodeFormatError},
+		{"其他类", &dns.A{Hdr: dns.RR_Header{Name: "host1.example.com.", Rrtype: dns.TypeA, Class: dns.ClassCHAOS}}, dns.RcodeFormatError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestUpdateState().prescan([]dns.RR{tt.rr}); got != tt.want {
+				t.Errorf("prescan() = %s, want %s", dns.RcodeToString[got], dns.RcodeToString[tt.want])
+			}
+		})
+	}
+}
+
+// TestDynamicUpdateApply 测试RFC 2136 3.4.2节的记录变更
+func TestDynamicUpdateApply(t *testing.T) {
+	allowAll := func(string, uint16) bool { return true }
+	deleteRR := func(s string) dns.RR {
+		rr := mustRR(t, s)
+		rr.Header().Class = dns.ClassNONE
+		rr.Header().Ttl = 0
+		return rr
+	}
+
+	tests := []struct {
+		name    string
+		updates []dns.RR
+		changed bool
+		check   func(s *updateState) bool
+	}{
+		{"添加记录", []dns.RR{mustRR(t, "new.example.com. 300 IN A 192.0.2.1")}, true,
+			func(s *updateState) bool {
+				return len(s.rrset("new.example.com.", dns.TypeA)) == 1 && s.names["new.example.com."]
+			}},
+		{"重复添加只更新TTL", []dns.RR{mustRR(t, "host1.example.com. 600 IN A 192.0.2.10")}, true,
+			func(s *updateState) bool {
+				set := s.rrset("host1.example.com.", dns.TypeA)
+				return len(set) == 2 && set[0].TTL == 600
+			}},
+		{"重复添加无变化", []dns.RR{mustRR(t, "host1.example.com. 300 IN A 192.0.2.10")}, false, nil},
+		{"CNAME冲突被忽略", []dns.RR{mustRR(t, "www.example.com. 300 IN A 192.0.2.1")}, false, nil},
+		{"替换CNAME", []dns.RR{mustRR(t, "www.example.com. 300 IN CNAME ns1.example.com.")}, true,
+			func(s *updateState) bool {
+				set := s.rrset("www.example.com.", dns.TypeCNAME)
+				return len(set) == 1 && set[0].Value == "ns1.example.com."
+			}},
+		{"忽略SOA", []dns.RR{mustRR(t, "example.com. 300 IN SOA ns1.example.com. admin.example.com. 9 1 1 1 1")}, false, nil},
+		{"删除RRset", []dns.RR{emptyRR("host1.example.com.", dns.TypeA, dns.ClassANY)}, true,
+			func(s *updateState) bool { return !s.nameInUse("host1.example.com.") }},
+		{"删除单条记录", []dns.RR{deleteRR("host1.example.com. 0 IN A 192.0.2.11")}, true,
+			func(s *updateState) bool { return len(s.rrset("host1.example.com.", dns.TypeA)) == 1 }},
+		{"删除不存在的记录", []dns.RR{deleteRR("host1.example.com. 0 IN A 192.0.2.12")}, false, nil},
+		{"保留最后一条顶点NS", []dns.RR{deleteRR("example.com. 0 IN NS ns1.example.com.")}, false, nil},
+		{"删除名称下所有记录", []dns.RR{emptyRR("example.com.", dns.TypeANY, dns.ClassANY)}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestUpdateState()
+			changed, err := s.apply(tt.updates, allowAll)
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			if changed != tt.changed {
+				t.Errorf("apply() changed = %v, want %v", changed, tt.changed)
+			}
+			if tt.check != nil && !tt.check(s) {
+				t.Errorf("apply() 结果不正确: %+v", s.records)
+			}
+		})
+	}
+
+	// $INCLUDE文件中的记录只读
+	s := newTestUpdateState()
+	if _, err := s.apply([]dns.RR{emptyRR("inc.example.com.", dns.TypeA, dns.ClassANY)}, allowAll); err == nil {
+		t.Errorf("删除$INCLUDE记录应返回错误")
+	}
+
+	// 删除名称下所有记录时只删除有权限的类型
+	s = newTestUpdateState()
+	s.records = append(s.records, Record{Name: "host1", Type: "TXT", Value: `"keep"`})
+	onlyA := func(_ string, rrtype uint16) bool { return rrtype == dns.TypeA }
+	if _, err := s.apply([]dns.RR{emptyRR("host1.example.com.", dns.TypeANY, dns.ClassANY)}, onlyA); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+	if len(s.rrset("host1.example.com.", dns.TypeA)) != 0 || len(s.rrset("host1.example.com.", dns.TypeTXT)) != 1 {
+		t.Errorf("删除名称下所有记录的结果不正确: %+v", s.records)
+	}
+}
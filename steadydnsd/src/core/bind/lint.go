@@ -0,0 +1,280 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/lint.go
+// 区域数据检查框架：检查规则注册、执行和检查报告
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"SteadyDNS/core/common"
+
+	"github.com/miekg/dns"
+)
+
+// 检查结果严重程度
+const (
+	LintSeverityError   = "error"   // 错误，阻止保存区域
+	LintSeverityWarning = "warning" // 警告，保存时写入日志
+	LintSeverityInfo    = "info"    // 提示
+)
+
+// ErrZoneLint 区域数据存在错误级别的检查结果
+var ErrZoneLint = errors.New("区域数据检查未通过")
+
+// lintSeverityOrder 报告中结果的排序，错误在前
+var lintSeverityOrder = map[string]int{
+	LintSeverityError:   0,
+	LintSeverityWarning: 1,
+	LintSeverityInfo:    2,
+}
+
+// LintFinding 一条检查结果
+type LintFinding struct {
+	RuleID   string `json:"rule_id"`
+	Severity string `json:"severity"`
+	Name     string `json:"name,omitempty"` // 相关记录的名称
+	Type     string `json:"type,omitempty"` // 相关记录的类型
+	RecordID string `json:"record_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+// LintRule 区域检查规则，通过RegisterLintRule注册后在保存区域和按需检查时执行
+type LintRule struct {
+	ID          string                           `json:"id"`
+	Severity    string                           `json:"severity"` // 默认严重程度，结果未指定级别时使用
+	Description string                           `json:"description"`
+	Check       func(lz *LintZone) []LintFinding `json:"-"`
+}
+
+// LintRecord 参与检查的记录
+type LintRecord struct {
+	Record
+	Owner      string // 小写的绝对所有者名称
+	TTLSeconds int    // 生效的TTL，记录未设置时为区域默认TTL
+	RR         dns.RR // 解析后的资源记录，记录无效时为nil
+	ParseError string // 记录无效的原因
+}
+
+// finding 生成与该记录相关的检查结果
+func (r LintRecord) finding(severity, format string, args ...interface{}) LintFinding {
+	return LintFinding{
+		Severity: severity,
+		Name:     r.Name,
+		Type:     strings.ToUpper(r.Type),
+		RecordID: r.ID,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// LintZone 检查规则的输入，按名称建立了记录索引
+type LintZone struct {
+	Zone    AuthZone
+	Apex    string // 小写的绝对区域顶点
+	Records []LintRecord
+	byOwner map[string][]int
+}
+
+// newLintZone 解析区域记录并建立索引
+func newLintZone(zone AuthZone) *LintZone {
+	lz := &LintZone{
+		Zone:    zone,
+		Apex:    strings.ToLower(dns.Fqdn(zone.Domain)),
+		byOwner: make(map[string][]int),
+	}
+	for _, rec := range zone.Records {
+		lr := LintRecord{
+			Record:     rec,
+			Owner:      strings.ToLower(absoluteName(rec.Name, lz.Apex)),
+			TTLSeconds: rec.TTL,
+		}
+		if lr.TTLSeconds <= 0 {
+			lr.TTLSeconds = zone.DefaultTTL
+		}
+		line, err := renderRecord(rec, lz.Apex, lz.Apex)
+		if err == nil {
+			lr.RR, err = parseRecordText(line, lz.Apex)
+		}
+		if err != nil {
+			lr.RR = nil
+			lr.ParseError = err.Error()
+		}
+		lz.byOwner[lr.Owner] = append(lz.byOwner[lr.Owner], len(lz.Records))
+		lz.Records = append(lz.Records, lr)
+	}
+	return lz
+}
+
+// Lookup 返回指定名称下指定类型的有效记录，rrtype为dns.TypeNone时返回全部类型
+func (lz *LintZone) Lookup(name string, rrtype uint16) []LintRecord {
+	var out []LintRecord
+	for _, i := range lz.byOwner[strings.ToLower(dns.Fqdn(name))] {
+		r := lz.Records[i]
+		if r.RR != nil && (rrtype == dns.TypeNone || r.RR.Header().Rrtype == rrtype) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// InZone 判断名称是否位于区域内
+func (lz *LintZone) InZone(name string) bool {
+	return dns.IsSubDomain(lz.Apex, strings.ToLower(dns.Fqdn(name)))
+}
+
+// Delegation 返回覆盖该名称的子域委派点（区域内顶点以下的NS所有者），不在委派下时返回空
+func (lz *LintZone) Delegation(name string) string {
+	name = strings.ToLower(dns.Fqdn(name))
+	cut := ""
+	for _, r := range lz.Records {
+		if r.RR == nil || r.RR.Header().Rrtype != dns.TypeNS || r.Owner == lz.Apex || !lz.InZone(r.Owner) {
+			continue
+		}
+		if dns.IsSubDomain(r.Owner, name) && len(r.Owner) > len(cut) {
+			cut = r.Owner
+		}
+	}
+	return cut
+}
+
+// LintReport 区域检查报告
+type LintReport struct {
+	Domain   string        `json:"domain"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Findings []LintFinding `json:"findings"`
+}
+
+// Err 将错误级别的检查结果汇总为错误，没有错误时返回nil
+func (r *LintReport) Err() error {
+	var msgs []string
+	for _, f := range r.Findings {
+		if f.Severity == LintSeverityError {
+			msgs = append(msgs, f.String())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrZoneLint, strings.Join(msgs, "; "))
+}
+
+// String 返回便于日志和错误信息展示的检查结果
+func (f LintFinding) String() string {
+	if f.Name == "" {
+		return fmt.Sprintf("[%s] %s", f.RuleID, f.Message)
+	}
+	return fmt.Sprintf("[%s] %s %s: %s", f.RuleID, f.Name, f.Type, f.Message)
+}
+
+var (
+	lintMu    sync.RWMutex
+	lintRules = defaultLintRules()
+)
+
+// RegisterLintRule 注册区域检查规则，ID已存在时替换原规则
+func RegisterLintRule(rule LintRule) {
+	lintMu.Lock()
+	defer lintMu.Unlock()
+	for i := range lintRules {
+		if lintRules[i].ID == rule.ID {
+			lintRules[i] = rule
+			return
+		}
+	}
+	lintRules = append(lintRules, rule)
+}
+
+// LintRules 返回已注册的区域检查规则
+func LintRules() []LintRule {
+	lintMu.RLock()
+	defer lintMu.RUnlock()
+	return append([]LintRule(nil), lintRules...)
+}
+
+// disabledLintRules 读取配置中禁用的检查规则ID
+func disabledLintRules() map[string]bool {
+	disabled := make(map[string]bool)
+	for _, id := range strings.Split(common.GetConfig("BIND", "ZONE_LINT_DISABLED_RULES"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			disabled[id] = true
+		}
+	}
+	return disabled
+}
+
+// LintZoneData 对区域数据执行全部未禁用的检查规则
+func LintZoneData(zone AuthZone) *LintReport {
+	return lintZoneWith(zone, LintRules(), disabledLintRules())
+}
+
+// lintZoneWith 使用指定规则检查区域数据，结果按严重程度排序
+func lintZoneWith(zone AuthZone, rules []LintRule, disabled map[string]bool) *LintReport {
+	lz := newLintZone(zone)
+	report := &LintReport{Domain: zone.Domain, Findings: make([]LintFinding, 0)}
+	for _, rule := range rules {
+		if disabled[rule.ID] || rule.Check == nil {
+			continue
+		}
+		for _, f := range rule.Check(lz) {
+			f.RuleID = rule.ID
+			if f.Severity == "" {
+				f.Severity = rule.Severity
+			}
+			report.Findings = append(report.Findings, f)
+		}
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return lintSeverityOrder[report.Findings[i].Severity] < lintSeverityOrder[report.Findings[j].Severity]
+	})
+	for _, f := range report.Findings {
+		switch f.Severity {
+		case LintSeverityError:
+			report.Errors++
+		case LintSeverityWarning:
+			report.Warnings++
+		}
+	}
+	return report
+}
+
+// lintBeforeSave 保存区域前执行检查，错误阻止保存，警告写入日志
+func (bm *BindManager) lintBeforeSave(zone AuthZone) error {
+	report := LintZoneData(zone)
+	for _, f := range report.Findings {
+		if f.Severity == LintSeverityWarning {
+			bm.logger.Warn("区域 %s 检查警告: %s", zone.Domain, f)
+		}
+	}
+	return report.Err()
+}
+
+// LintAuthZone 按需检查已有权威域的数据
+func (bm *BindManager) LintAuthZone(domain string) (*LintReport, error) {
+	zone, _, err := bm.loadZoneWithETag(domain)
+	if err != nil {
+		return nil, err
+	}
+	return LintZoneData(*zone), nil
+}
@@ -0,0 +1,177 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/lint_test.go
+// 区域检查框架和内置检查规则测试
+
+package bind
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// lintTestZone 构造用于检查的区域
+func lintTestZone(records ...Record) AuthZone {
+	return AuthZone{Domain: "example.com", DefaultTTL: 3600, Records: records}
+}
+
+// lintFindings 使用全部内置规则检查区域，返回指定规则的结果
+func lintFindings(zone AuthZone, ruleID string) []LintFinding {
+	var out []LintFinding
+	for _, f := range lintZoneWith(zone, defaultLintRules(), nil).Findings {
+		if f.RuleID == ruleID {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// TestLintRules 测试各内置规则的检查结果和严重程度
+func TestLintRules(t *testing.T) {
+	base := []Record{
+		{Name: "@", Type: "NS", Value: "ns1.example.com."},
+		{Name: "ns1", Type: "A", Value: "192.0.2.1"},
+	}
+	cases := []struct {
+		name     string
+		rule     string
+		records  []Record
+		severity []string // 期望的结果严重程度，为空表示没有结果
+	}{
+		{"区域外数据", LintRuleOutOfZone, []Record{{Name: "www.example.org.", Type: "A", Value: "192.0.2.2"}}, []string{LintSeverityError}},
+		{"委派遮蔽", LintRuleOutOfZone, []Record{
+			{Name: "sub", Type: "NS", Value: "ns.sub.example.com."},
+			{Name: "ns.sub", Type: "A", Value: "192.0.2.3"},
+			{Name: "www.sub", Type: "TXT", Value: `"hidden"`},
+		}, []string{LintSeverityWarning}},
+		{"CNAME冲突", LintRuleCNAMEConflict, []Record{
+			{Name: "www", Type: "CNAME", Value: "web.example.net."},
+			{Name: "www", Type: "TXT", Value: `"x"`},
+		}, []string{LintSeverityError}},
+		{"顶点CNAME", LintRuleCNAMEConflict, []Record{{Name: "@", Type: "CNAME", Value: "web.example.net."}}, []string{LintSeverityError}},
+		{"MX和NS指向CNAME", LintRuleTargetCNAME, []Record{
+			{Name: "alias", Type: "CNAME", Value: "ns1.example.com."},
+			{Name: "@", Type: "MX", Priority: 10, Value: "alias.example.com."},
+			{Name: "sub", Type: "NS", Value: "alias.example.com."},
+			{Name: "_sip._tcp", Type: "SRV", Value: "10 5 5060 alias.example.com."},
+		}, []string{LintSeverityWarning, LintSeverityError, LintSeverityWarning}},
+		{"缺少胶水", LintRuleMissingGlue, []Record{
+			{Name: "sub", Type: "NS", Value: "ns.sub.example.com."},
+			{Name: "sub", Type: "NS", Value: "ns.example.net."},
+		}, []string{LintSeverityError}},
+		{"重复记录", LintRuleDuplicate, []Record{
+			{Name: "www", Type: "A", Value: "192.0.2.5"},
+			{Name: "www.example.com.", Type: "A", Value: "192.0.2.5", TTL: 3600},
+		}, []string{LintSeverityWarning}},
+		{"TTL不一致", LintRuleTTLMismatch, []Record{
+			{Name: "www", Type: "A", Value: "192.0.2.5", TTL: 300},
+			{Name: "www", Type: "A", Value: "192.0.2.6"},
+			{Name: "api", Type: "A", Value: "192.0.2.7", TTL: 3600},
+			{Name: "api", Type: "A", Value: "192.0.2.8"},
+		}, []string{LintSeverityWarning}},
+		{"有效SPF", LintRuleSPFSyntax, []Record{{Name: "@", Type: "TXT", Value: `"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 a mx/24 include:_spf.example.net ~all"`}}, nil},
+		{"无效SPF", LintRuleSPFSyntax, []Record{{Name: "@", Type: "TXT", Value: `"v=spf1 ip4:2001:db8::1 foo ?all mx"`}}, []string{LintSeverityWarning, LintSeverityWarning, LintSeverityWarning}},
+		{"多条SPF", LintRuleSPFSyntax, []Record{
+			{Name: "@", Type: "TXT", Value: `"v=spf1 -all"`},
+			{Name: "@", Type: "TXT", Value: `"v=spf1 mx -all"`},
+		}, []string{LintSeverityWarning}},
+		{"有效DMARC", LintRuleDMARCSyntax, []Record{{Name: "_dmarc", Type: "TXT", Value: `"v=DMARC1; p=quarantine; pct=50; rua=mailto:dmarc@example.com; fo=0:d"`}}, nil},
+		{"无效DMARC", LintRuleDMARCSyntax, []Record{{Name: "_dmarc", Type: "TXT", Value: `"v=DMARC1; pct=150; adkim=x"`}}, []string{LintSeverityWarning, LintSeverityWarning, LintSeverityWarning}},
+		{"DMARC位置错误", LintRuleDMARCSyntax, []Record{
+			{Name: "@", Type: "TXT", Value: `"v=DMARC1; p=none"`},
+			{Name: "_dmarc", Type: "TXT", Value: `"hello"`},
+		}, []string{LintSeverityWarning, LintSeverityWarning}},
+		{"CAA", LintRuleCAASyntax, []Record{
+			{Name: "@", Type: "CAA", Value: `0 issue "ca.example.net; account=123"`},
+			{Name: "@", Type: "CAA", Value: `0 issue "ca.example.net; bad param"`},
+			{Name: "@", Type: "CAA", Value: `0 future "x"`},
+			{Name: "@", Type: "CAA", Value: `0 iodef "ftp://example.com"`},
+		}, []string{LintSeverityError, LintSeverityWarning, LintSeverityWarning}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			zone := lintTestZone(append(append([]Record{}, base...), tc.records...)...)
+			findings := lintFindings(zone, tc.rule)
+			var got []string
+			for _, f := range findings {
+				got = append(got, f.Severity)
+			}
+			if strings.Join(got, ",") != strings.Join(sortedSeverities(tc.severity), ",") {
+				t.Errorf("%s 结果 = %v，期望 %v（%+v）", tc.rule, got, tc.severity, findings)
+			}
+		})
+	}
+}
+
+// sortedSeverities 按报告顺序排列期望的严重程度
+func sortedSeverities(severities []string) []string {
+	var out []string
+	for _, level := range []string{LintSeverityError, LintSeverityWarning, LintSeverityInfo} {
+		for _, s := range severities {
+			if s == level {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// TestLintReport 测试规则禁用、错误汇总和自定义规则注册
+func TestLintReport(t *testing.T) {
+	zone := lintTestZone(
+		Record{Name: "@", Type: "NS", Value: "ns1.example.com."},
+		Record{Name: "ns1", Type: "A", Value: "192.0.2.1"},
+		Record{Name: "www", Type: "CNAME", Value: "web.example.net."},
+		Record{Name: "www", Type: "A", Value: "192.0.2.2"},
+		Record{Name: "bad", Type: "A", Value: "not-an-ip"},
+	)
+
+	report := lintZoneWith(zone, defaultLintRules(), nil)
+	if report.Errors != 2 || report.Findings[0].Severity != LintSeverityError {
+		t.Fatalf("期望2个错误且排在最前: %+v", report)
+	}
+	err := report.Err()
+	if !errors.Is(err, ErrZoneLint) || !strings.Contains(err.Error(), "["+LintRuleCNAMEConflict+"]") {
+		t.Errorf("Err() = %v", err)
+	}
+
+	report = lintZoneWith(zone, defaultLintRules(), map[string]bool{LintRuleCNAMEConflict: true, LintRuleRecordSyntax: true})
+	if report.Err() != nil {
+		t.Errorf("禁用规则后不应有错误: %+v", report.Findings)
+	}
+
+	custom := LintRule{ID: "test-no-www", Severity: LintSeverityInfo, Check: func(lz *LintZone) []LintFinding {
+		var out []LintFinding
+		for _, r := range lz.Lookup("www.example.com", 0) {
+			out = append(out, r.finding("", "使用了www"))
+		}
+		return out
+	}}
+	report = lintZoneWith(zone, append(defaultLintRules(), custom), nil)
+	var infos int
+	for _, f := range report.Findings {
+		if f.RuleID == custom.ID && f.Severity == LintSeverityInfo {
+			infos++
+		}
+	}
+	if infos != 2 {
+		t.Errorf("自定义规则结果数 = %d，期望 2", infos)
+	}
+}
@@ -52,17 +52,38 @@ type Record struct {
 	Priority int    `json:"priority,omitempty"` // 可选，用于MX记录
 	TTL      int    `json:"ttl,omitempty"`      // 可选，记录的生存时间
 	Comment  string `json:"comment,omitempty"`  // 可选，记录后的注释信息
+	Source   string `json:"source,omitempty"`   // 记录所在的$INCLUDE文件，非空时只读
+
+	// 结构化字段，与Type对应的字段非空时以其为准生成Value，解析时自动回填
+	SRV   *SRVData   `json:"srv,omitempty"`
+	CAA   *CAAData   `json:"caa,omitempty"`
+	NAPTR *NAPTRData `json:"naptr,omitempty"`
+	SSHFP *SSHFPData `json:"sshfp,omitempty"`
+	TLSA  *TLSAData  `json:"tlsa,omitempty"`
+	SVCB  *SVCBData  `json:"svcb,omitempty"` // HTTPS和SVCB共用
+	DS    *DSData    `json:"ds,omitempty"`
+
+	AutoPTR    *bool       `json:"auto_ptr,omitempty"`    // 仅用于请求：A/AAAA记录是否同步反向区域PTR，为空时使用区域默认设置
+	PTRChanges []PTRChange `json:"ptr_changes,omitempty"` // 仅用于响应：本次操作对反向区域PTR记录的变更
 }
 
 // AuthZone 权威域信息
 type AuthZone struct {
-	Domain     string    `json:"domain"`
-	Type       string    `json:"type"`
-	File       string    `json:"file"`
-	AllowQuery string    `json:"allow_query"`
-	Comment    string    `json:"comment,omitempty"` // 权威域注释信息，对应 named.conf 中 zone 配置块的前置注释
-	SOA        SOARecord `json:"soa"`
-	Records    []Record  `json:"records"` // 通用记录切片，包含除SOA外的所有记录
+	Domain        string        `json:"domain"`
+	Type          string        `json:"type"` // 区域类型：master（默认）或slave，也接受primary/secondary
+	File          string        `json:"file"`
+	AllowQuery    string        `json:"allow_query"`
+	AllowTransfer string        `json:"allow_transfer,omitempty"` // 允许区域传送的地址匹配列表，可包含 key <名称>，为空时更新保持不变
+	AllowUpdate   string        `json:"allow_update,omitempty"`   // 允许动态更新的地址匹配列表，可包含 key <名称>，为空时更新保持不变
+	UpdatePolicy  string        `json:"update_policy,omitempty"`  // update-policy规则（分号分隔），为空时更新保持不变，"none"表示移除
+	Primaries     string        `json:"primaries,omitempty"`      // 辅区域的主服务器列表（分号分隔，如 192.0.2.1; 2001:db8::1 port 5353）
+	PrimaryKey    string        `json:"primary_key,omitempty"`    // 辅区域从主服务器传送时使用的TSIG密钥名称
+	DNSSEC        *DNSSECConfig `json:"dnssec,omitempty"`         // DNSSEC签名配置，为空时更新保持不变
+	AutoPTR       *bool         `json:"auto_ptr,omitempty"`       // A/AAAA记录变更时默认同步反向区域PTR记录，为空时更新保持不变
+	Comment       string        `json:"comment,omitempty"`        // 权威域注释信息，对应 named.conf 中 zone 配置块的前置注释
+	DefaultTTL    int           `json:"default_ttl,omitempty"`    // zone文件的$TTL，为0时新建区域使用86400，更新时保持不变
+	SOA           SOARecord     `json:"soa"`
+	Records       []Record      `json:"records"` // 通用记录切片，包含除SOA外的所有记录
 }
 
 // SOARecord SOA记录
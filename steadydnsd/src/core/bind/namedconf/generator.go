@@ -85,17 +85,20 @@ func (g *Generator) generateElement(sb *strings.Builder, element *ConfigElement,
 			g.generateElement(sb, &child, indent+g.indentSize)
 		}
 
-		// 闭合块
+		// 闭合块，named.conf要求块以分号结束
 		g.writeIndent(sb, indent)
-		sb.WriteString("}\n")
+		sb.WriteString("};\n")
 
-	case "simple":
-		// 简单配置项
+	case "simple", "keyword":
+		// 简单配置项，keyword类型的值为关键字或多个参数（如 inline-signing yes;），不加引号
 		g.writeIndent(sb, indent)
-		if element.Value != "" {
-			sb.WriteString(fmt.Sprintf("%s \"%s\";", element.Name, element.Value))
-		} else {
+		switch {
+		case element.Value == "":
 			sb.WriteString(fmt.Sprintf("%s;", element.Name))
+		case element.Type == "keyword":
+			sb.WriteString(fmt.Sprintf("%s %s;", element.Name, element.Value))
+		default:
+			sb.WriteString(fmt.Sprintf("%s \"%s\";", element.Name, element.Value))
 		}
 
 		// 行尾注释
@@ -0,0 +1,404 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/record_types.go
+// 结构化记录类型（SRV、CAA、NAPTR、SSHFP、TLSA、HTTPS/SVCB、DS）的校验与表示格式生成
+
+package bind
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SRVData SRV记录字段（RFC 2782）
+type SRVData struct {
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+	Port     uint16 `json:"port"`
+	Target   string `json:"target"`
+}
+
+// CAAData CAA记录字段（RFC 8659）
+type CAAData struct {
+	Flags uint8  `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// NAPTRData NAPTR记录字段（RFC 3403）
+type NAPTRData struct {
+	Order       uint16 `json:"order"`
+	Preference  uint16 `json:"preference"`
+	Flags       string `json:"flags"`
+	Service     string `json:"service"`
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+}
+
+// SSHFPData SSHFP记录字段（RFC 4255）
+type SSHFPData struct {
+	Algorithm       uint8  `json:"algorithm"`
+	FingerprintType uint8  `json:"fingerprint_type"`
+	Fingerprint     string `json:"fingerprint"`
+}
+
+// TLSAData TLSA记录字段（RFC 6698）
+type TLSAData struct {
+	Usage           uint8  `json:"usage"`
+	Selector        uint8  `json:"selector"`
+	MatchingType    uint8  `json:"matching_type"`
+	CertificateData string `json:"certificate_data"`
+}
+
+// SVCBParam SVCB/HTTPS服务参数，值为表示格式（如alpn的"h2,h3"）
+type SVCBParam struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// SVCBData SVCB和HTTPS记录字段（RFC 9460）
+type SVCBData struct {
+	Priority uint16      `json:"priority"`
+	Target   string      `json:"target"`
+	Params   []SVCBParam `json:"params,omitempty"`
+}
+
+// DSData DS记录字段（RFC 4034）
+type DSData struct {
+	KeyTag     uint16 `json:"key_tag"`
+	Algorithm  uint8  `json:"algorithm"`
+	DigestType uint8  `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+// typedRecordTypes 支持结构化字段的记录类型
+var typedRecordTypes = map[string]bool{
+	"SRV":   true,
+	"CAA":   true,
+	"NAPTR": true,
+	"SSHFP": true,
+	"TLSA":  true,
+	"SVCB":  true,
+	"HTTPS": true,
+	"DS":    true,
+}
+
+// NormalizeRecords 校验区域中的所有记录，并为结构化类型生成表示格式的Value
+// 提供了结构化字段时以其为准，否则解析Value并回填结构化字段
+func NormalizeRecords(zone *AuthZone) error {
+	apex := dns.Fqdn(zone.Domain)
+	for i := range zone.Records {
+		if err := normalizeRecord(&zone.Records[i], apex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeRecord 校验单条记录，结构化类型的Value统一为规范的表示格式
+func normalizeRecord(rec *Record, apex string) error {
+	rrtype := strings.ToUpper(rec.Type)
+	if n := rec.typedFields(); n > 1 || (n == 1 && !rec.hasTypedData(rrtype)) {
+		return fmt.Errorf("记录 %s %s 无效: 结构化字段与记录类型不匹配", rec.Name, rec.Type)
+	}
+	if !typedRecordTypes[rrtype] {
+		return nil
+	}
+
+	if rec.hasTypedData(rrtype) {
+		rec.Value = rec.presentation(rrtype)
+	}
+	if strings.TrimSpace(rec.Value) == "" {
+		return fmt.Errorf("记录 %s %s 无效: 缺少记录值", rec.Name, rec.Type)
+	}
+
+	rr, err := parseRR(fmt.Sprintf("%s 3600 IN %s %s", absoluteName(rec.Name, apex), rrtype, rec.Value), apex)
+	if err != nil {
+		return fmt.Errorf("记录 %s %s 无效: %v", rec.Name, rec.Type, err)
+	}
+	rec.Type = rrtype
+	rec.Value = strings.TrimPrefix(rr.String(), rr.Header().String())
+	rec.setTypedData(rr)
+
+	if err := validateTypedRecord(rr, apex); err != nil {
+		return fmt.Errorf("记录 %s %s 无效: %v", rec.Name, rec.Type, err)
+	}
+	return nil
+}
+
+// typedFields 返回已设置的结构化字段数量
+func (r *Record) typedFields() int {
+	n := 0
+	for _, set := range []bool{r.SRV != nil, r.CAA != nil, r.NAPTR != nil, r.SSHFP != nil, r.TLSA != nil, r.SVCB != nil, r.DS != nil} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+// hasTypedData 判断是否设置了与记录类型对应的结构化字段
+func (r *Record) hasTypedData(rrtype string) bool {
+	switch rrtype {
+	case "SRV":
+		return r.SRV != nil
+	case "CAA":
+		return r.CAA != nil
+	case "NAPTR":
+		return r.NAPTR != nil
+	case "SSHFP":
+		return r.SSHFP != nil
+	case "TLSA":
+		return r.TLSA != nil
+	case "SVCB", "HTTPS":
+		return r.SVCB != nil
+	case "DS":
+		return r.DS != nil
+	}
+	return false
+}
+
+// presentation 根据结构化字段生成区域文件表示格式的rdata
+func (r *Record) presentation(rrtype string) string {
+	switch rrtype {
+	case "SRV":
+		return fmt.Sprintf("%d %d %d %s", r.SRV.Priority, r.SRV.Weight, r.SRV.Port, ensureDotSuffix(r.SRV.Target))
+	case "CAA":
+		return fmt.Sprintf("%d %s %s", r.CAA.Flags, r.CAA.Tag, quoteZoneString(r.CAA.Value))
+	case "NAPTR":
+		return fmt.Sprintf("%d %d %s %s %s %s", r.NAPTR.Order, r.NAPTR.Preference,
+			quoteZoneString(r.NAPTR.Flags), quoteZoneString(r.NAPTR.Service), quoteZoneString(r.NAPTR.Regexp),
+			ensureDotSuffix(r.NAPTR.Replacement))
+	case "SSHFP":
+		return fmt.Sprintf("%d %d %s", r.SSHFP.Algorithm, r.SSHFP.FingerprintType, r.SSHFP.Fingerprint)
+	case "TLSA":
+		return fmt.Sprintf("%d %d %d %s", r.TLSA.Usage, r.TLSA.Selector, r.TLSA.MatchingType, r.TLSA.CertificateData)
+	case "SVCB", "HTTPS":
+		parts := []string{strconv.Itoa(int(r.SVCB.Priority)), ensureDotSuffix(r.SVCB.Target)}
+		for _, p := range r.SVCB.Params {
+			if p.Value == "" {
+				parts = append(parts, p.Key)
+			} else {
+				parts = append(parts, p.Key+"="+quoteZoneString(p.Value))
+			}
+		}
+		return strings.Join(parts, " ")
+	case "DS":
+		return fmt.Sprintf("%d %d %d %s", r.DS.KeyTag, r.DS.Algorithm, r.DS.DigestType, r.DS.Digest)
+	}
+	return r.Value
+}
+
+// setTypedData 根据解析出的资源记录回填结构化字段
+func (r *Record) setTypedData(rr dns.RR) {
+	switch v := rr.(type) {
+	case *dns.SRV:
+		r.SRV = &SRVData{Priority: v.Priority, Weight: v.Weight, Port: v.Port, Target: v.Target}
+	case *dns.CAA:
+		r.CAA = &CAAData{Flags: v.Flag, Tag: v.Tag, Value: v.Value}
+	case *dns.NAPTR:
+		r.NAPTR = &NAPTRData{Order: v.Order, Preference: v.Preference, Flags: v.Flags, Service: v.Service, Regexp: v.Regexp, Replacement: v.Replacement}
+	case *dns.SSHFP:
+		r.SSHFP = &SSHFPData{Algorithm: v.Algorithm, FingerprintType: v.Type, Fingerprint: v.FingerPrint}
+	case *dns.TLSA:
+		r.TLSA = &TLSAData{Usage: v.Usage, Selector: v.Selector, MatchingType: v.MatchingType, CertificateData: v.Certificate}
+	case *dns.SVCB:
+		r.SVCB = svcbData(v)
+	case *dns.HTTPS:
+		r.SVCB = svcbData(&v.SVCB)
+	case *dns.DS:
+		r.DS = &DSData{KeyTag: v.KeyTag, Algorithm: v.Algorithm, DigestType: v.DigestType, Digest: v.Digest}
+	}
+}
+
+// svcbData 转换SVCB服务参数
+func svcbData(v *dns.SVCB) *SVCBData {
+	data := &SVCBData{Priority: v.Priority, Target: v.Target}
+	for _, kv := range v.Value {
+		data.Params = append(data.Params, SVCBParam{Key: kv.Key().String(), Value: kv.String()})
+	}
+	return data
+}
+
+// quoteZoneString 生成带引号的字符串，转义引号和反斜杠
+func quoteZoneString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		if r == '"' || r == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// caaTags 已注册的CAA属性标签
+var caaTags = map[string]bool{
+	"issue":        true,
+	"issuewild":    true,
+	"iodef":        true,
+	"issuemail":    true,
+	"issuevmc":     true,
+	"contactemail": true,
+	"contactphone": true,
+}
+
+// validateTypedRecord 按记录类型做语法解析之外的语义校验
+func validateTypedRecord(rr dns.RR, apex string) error {
+	switch v := rr.(type) {
+	case *dns.SRV:
+		labels := dns.SplitDomainName(v.Hdr.Name)
+		if len(labels) < 2 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+			return fmt.Errorf("SRV记录名称必须为 _服务._协议 格式")
+		}
+		if v.Target == "." && v.Port != 0 {
+			return fmt.Errorf("目标为\".\"表示服务不可用，端口应为0")
+		}
+	case *dns.CAA:
+		if v.Flag != 0 && v.Flag != 128 {
+			return fmt.Errorf("CAA标志只能为0或128")
+		}
+		tag := strings.ToLower(v.Tag)
+		if tag == "" || strings.Trim(tag, "abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+			return fmt.Errorf("CAA标签只能包含字母和数字")
+		}
+		if !caaTags[tag] && v.Flag == 128 {
+			return fmt.Errorf("未知的关键CAA标签: %s", v.Tag)
+		}
+		switch tag {
+		case "issue", "issuewild", "issuemail", "issuevmc":
+			// 值为“CA域名[; 参数]”，CA域名为空表示禁止签发
+			issuer := strings.TrimSpace(strings.SplitN(v.Value, ";", 2)[0])
+			if issuer != "" {
+				if !isHostname(issuer) {
+					return fmt.Errorf("无效的CA域名: %s", issuer)
+				}
+			}
+		case "iodef":
+			u, err := url.Parse(v.Value)
+			if err != nil || (u.Scheme != "mailto" && u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("iodef必须为mailto:、http://或https://地址")
+			}
+		}
+	case *dns.NAPTR:
+		flags := strings.ToUpper(v.Flags)
+		if strings.Trim(flags, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") != "" {
+			return fmt.Errorf("NAPTR标志只能包含字母和数字")
+		}
+		hasRegexp, hasReplacement := v.Regexp != "", v.Replacement != "."
+		switch {
+		case hasRegexp && hasReplacement:
+			return fmt.Errorf("NAPTR的regexp和replacement只能设置一个")
+		case !hasRegexp && !hasReplacement:
+			return fmt.Errorf("NAPTR必须设置regexp或replacement")
+		case strings.ContainsAny(flags, "SA") && !hasReplacement:
+			return fmt.Errorf("标志S和A要求设置replacement")
+		case strings.Contains(flags, "U") && !hasRegexp:
+			return fmt.Errorf("标志U要求设置regexp")
+		}
+	case *dns.SSHFP:
+		switch v.Algorithm {
+		case 1, 2, 3, 4, 6:
+		default:
+			return fmt.Errorf("不支持的SSHFP算法: %d", v.Algorithm)
+		}
+		if err := checkHexDigest(v.FingerPrint, map[uint8]int{1: 20, 2: 32}, v.Type); err != nil {
+			return fmt.Errorf("SSHFP指纹%v", err)
+		}
+	case *dns.TLSA:
+		if v.Usage > 3 || v.Selector > 1 || v.MatchingType > 2 {
+			return fmt.Errorf("TLSA的usage应为0-3，selector应为0-1，matching type应为0-2")
+		}
+		if v.MatchingType == 0 {
+			if _, err := hex.DecodeString(v.Certificate); err != nil || v.Certificate == "" {
+				return fmt.Errorf("TLSA证书数据必须为十六进制")
+			}
+		} else if err := checkHexDigest(v.Certificate, map[uint8]int{1: 32, 2: 64}, v.MatchingType); err != nil {
+			return fmt.Errorf("TLSA证书数据%v", err)
+		}
+	case *dns.SVCB:
+		return validateSVCB(v)
+	case *dns.HTTPS:
+		return validateSVCB(&v.SVCB)
+	case *dns.DS:
+		if strings.EqualFold(v.Hdr.Name, apex) {
+			return fmt.Errorf("DS记录应位于子域委派点，不能放在区域顶点")
+		}
+		if _, ok := dns.AlgorithmToString[v.Algorithm]; !ok {
+			return fmt.Errorf("未知的DNSSEC算法: %d", v.Algorithm)
+		}
+		if err := checkHexDigest(v.Digest, map[uint8]int{dns.SHA1: 20, dns.SHA256: 32, dns.SHA384: 48}, v.DigestType); err != nil {
+			return fmt.Errorf("DS摘要%v", err)
+		}
+	}
+	return nil
+}
+
+// validateSVCB 校验SVCB/HTTPS记录：别名模式（优先级0）不能带服务参数，参数键不能重复
+func validateSVCB(v *dns.SVCB) error {
+	if v.Priority == 0 && len(v.Value) > 0 {
+		return fmt.Errorf("优先级为0的别名模式不能设置服务参数")
+	}
+	seen := make(map[dns.SVCBKey]bool)
+	for _, kv := range v.Value {
+		if seen[kv.Key()] {
+			return fmt.Errorf("服务参数重复: %s", kv.Key())
+		}
+		seen[kv.Key()] = true
+	}
+	return nil
+}
+
+// isHostname 判断是否为由字母、数字和连字符组成的主机名（不带结尾的点）
+func isHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		if strings.Trim(strings.ToLower(label), "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHexDigest 按摘要类型校验十六进制摘要的长度
+func checkHexDigest(digest string, lengths map[uint8]int, digestType uint8) error {
+	size, ok := lengths[digestType]
+	if !ok {
+		return fmt.Errorf("的摘要类型不支持: %d", digestType)
+	}
+	b, err := hex.DecodeString(digest)
+	if err != nil {
+		return fmt.Errorf("必须为十六进制")
+	}
+	if len(b) != size {
+		return fmt.Errorf("长度应为%d字节，实际为%d字节", size, len(b))
+	}
+	return nil
+}
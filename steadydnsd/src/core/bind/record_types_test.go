@@ -0,0 +1,140 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/record_types_test.go
+// 结构化记录类型的校验和表示格式生成测试
+
+package bind
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNormalizeTypedRecords 测试由结构化字段生成规范的表示格式
+func TestNormalizeTypedRecords(t *testing.T) {
+	sha256Hex := strings.Repeat("ab", 32)
+	tests := []struct {
+		name   string
+		record Record
+		want   string
+	}{
+		{"SRV", Record{Name: "_sip._tcp", Type: "srv", SRV: &SRVData{Priority: 10, Weight: 60, Port: 5060, Target: "sip.example.com"}},
+			"10 60 5060 sip.example.com."},
+		{"CAA", Record{Name: "@", Type: "CAA", CAA: &CAAData{Flags: 0, Tag: "issue", Value: "letsencrypt.org; validationmethods=dns-01"}},
+			`0 issue "letsencrypt.org; validationmethods=dns-01"`},
+		{"CAA iodef", Record{Name: "@", Type: "CAA", CAA: &CAAData{Flags: 128, Tag: "iodef", Value: "mailto:security@example.com"}},
+			`128 iodef "mailto:security@example.com"`},
+		{"NAPTR", Record{Name: "@", Type: "NAPTR", NAPTR: &NAPTRData{Order: 100, Preference: 10, Flags: "S", Service: "SIP+D2U", Replacement: "_sip._udp.example.com"}},
+			`100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`},
+		{"NAPTR regexp", Record{Name: "4.3.2.1", Type: "NAPTR", NAPTR: &NAPTRData{Order: 100, Preference: 10, Flags: "u", Service: "E2U+sip", Regexp: `!^.*$!sip:info@example.com!`, Replacement: "."}},
+			`100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`},
+		{"SSHFP", Record{Name: "host", Type: "SSHFP", SSHFP: &SSHFPData{Algorithm: 4, FingerprintType: 2, Fingerprint: sha256Hex}},
+			"4 2 " + strings.ToUpper(sha256Hex)},
+		{"TLSA", Record{Name: "_443._tcp.www", Type: "TLSA", TLSA: &TLSAData{Usage: 3, Selector: 1, MatchingType: 1, CertificateData: sha256Hex}},
+			"3 1 1 " + sha256Hex},
+		{"HTTPS", Record{Name: "@", Type: "HTTPS", SVCB: &SVCBData{Priority: 1, Target: ".", Params: []SVCBParam{{Key: "alpn", Value: "h2,h3"}, {Key: "ipv4hint", Value: "192.0.2.1"}}}},
+			`1 . alpn="h2,h3" ipv4hint="192.0.2.1"`},
+		{"SVCB别名", Record{Name: "_dns", Type: "SVCB", SVCB: &SVCBData{Priority: 0, Target: "svc.example.net"}},
+			"0 svc.example.net."},
+		{"DS", Record{Name: "sub", Type: "DS", DS: &DSData{KeyTag: 12345, Algorithm: 13, DigestType: 2, Digest: sha256Hex}},
+			"12345 13 2 " + strings.ToUpper(sha256Hex)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.record
+			if err := normalizeRecord(&rec, "example.com."); err != nil {
+				t.Fatalf("normalizeRecord() error = %v", err)
+			}
+			if rec.Value != tt.want {
+				t.Errorf("Value = %q, want %q", rec.Value, tt.want)
+			}
+			if _, err := renderRecord(rec, "example.com.", "example.com."); err != nil {
+				t.Errorf("renderRecord() error = %v", err)
+			}
+
+			// 只提供Value时回填相同的结构化字段
+			parsed := Record{Name: rec.Name, Type: rec.Type, Value: rec.Value}
+			if err := normalizeRecord(&parsed, "example.com."); err != nil {
+				t.Fatalf("normalizeRecord(Value) error = %v", err)
+			}
+			if parsed.typedFields() != 1 || parsed.presentation(parsed.Type) != rec.Value {
+				t.Errorf("回填的结构化字段不一致: %+v", parsed)
+			}
+		})
+	}
+}
+
+// TestNormalizeTypedRecordErrors 测试无效的结构化记录被拒绝
+func TestNormalizeTypedRecordErrors(t *testing.T) {
+	sha1Hex := strings.Repeat("cd", 20)
+	tests := []struct {
+		name   string
+		record Record
+	}{
+		{"SRV名称", Record{Name: "sip", Type: "SRV", SRV: &SRVData{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"}}},
+		{"SRV不可用带端口", Record{Name: "_sip._tcp", Type: "SRV", SRV: &SRVData{Port: 5060, Target: "."}}},
+		{"CAA标志", Record{Name: "@", Type: "CAA", CAA: &CAAData{Flags: 1, Tag: "issue", Value: "ca.example"}}},
+		{"CAA标签", Record{Name: "@", Type: "CAA", CAA: &CAAData{Tag: "is-sue", Value: "ca.example"}}},
+		{"CAA关键未知标签", Record{Name: "@", Type: "CAA", CAA: &CAAData{Flags: 128, Tag: "unknown", Value: "x"}}},
+		{"CAA域名", Record{Name: "@", Type: "CAA", CAA: &CAAData{Tag: "issue", Value: "bad domain"}}},
+		{"CAA iodef", Record{Name: "@", Type: "CAA", CAA: &CAAData{Tag: "iodef", Value: "ftp://example.com"}}},
+		{"NAPTR同时设置", Record{Name: "@", Type: "NAPTR", NAPTR: &NAPTRData{Flags: "U", Regexp: "!a!b!", Replacement: "x.example.com"}}},
+		{"NAPTR标志U", Record{Name: "@", Type: "NAPTR", NAPTR: &NAPTRData{Flags: "U", Replacement: "x.example.com"}}},
+		{"SSHFP算法", Record{Name: "host", Type: "SSHFP", SSHFP: &SSHFPData{Algorithm: 9, FingerprintType: 1, Fingerprint: sha1Hex}}},
+		{"SSHFP长度", Record{Name: "host", Type: "SSHFP", SSHFP: &SSHFPData{Algorithm: 1, FingerprintType: 2, Fingerprint: sha1Hex}}},
+		{"TLSA范围", Record{Name: "_443._tcp", Type: "TLSA", TLSA: &TLSAData{Usage: 4, CertificateData: sha1Hex}}},
+		{"TLSA长度", Record{Name: "_443._tcp", Type: "TLSA", TLSA: &TLSAData{Usage: 3, Selector: 1, MatchingType: 1, CertificateData: sha1Hex}}},
+		{"HTTPS别名带参数", Record{Name: "@", Type: "HTTPS", SVCB: &SVCBData{Target: "cdn.example.net", Params: []SVCBParam{{Key: "alpn", Value: "h2"}}}}},
+		{"HTTPS未知参数", Record{Name: "@", Type: "HTTPS", SVCB: &SVCBData{Priority: 1, Target: ".", Params: []SVCBParam{{Key: "bogus", Value: "1"}}}}},
+		{"DS顶点", Record{Name: "@", Type: "DS", DS: &DSData{KeyTag: 1, Algorithm: 13, DigestType: 1, Digest: sha1Hex}}},
+		{"DS摘要类型", Record{Name: "sub", Type: "DS", DS: &DSData{KeyTag: 1, Algorithm: 13, DigestType: 3, Digest: sha1Hex}}},
+		{"DS摘要长度", Record{Name: "sub", Type: "DS", DS: &DSData{KeyTag: 1, Algorithm: 13, DigestType: 2, Digest: sha1Hex}}},
+		{"字段与类型不匹配", Record{Name: "@", Type: "A", Value: "192.0.2.1", SRV: &SRVData{}}},
+		{"Value无效", Record{Name: "_sip._tcp", Type: "SRV", Value: "10 5 sip.example.com."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.record
+			if err := normalizeRecord(&rec, "example.com."); err == nil {
+				t.Errorf("normalizeRecord() 应返回错误, Value = %q", rec.Value)
+			}
+		})
+	}
+}
+
+// TestParseTypedRecords 测试解析zone文件时回填结构化字段
+func TestParseTypedRecords(t *testing.T) {
+	zone, err := newTestBindManager().parseZoneFile("testdata/example.com.zone", "example.com")
+	if err != nil {
+		t.Fatalf("parseZoneFile() error = %v", err)
+	}
+	var srv, caa bool
+	for _, r := range zone.Records {
+		switch r.Type {
+		case "SRV":
+			srv = r.SRV != nil && r.SRV.Port == 5060 && r.SRV.Target == "sip.example.com."
+		case "CAA":
+			caa = r.CAA != nil && r.CAA.Tag == "issue" && r.CAA.Value == "letsencrypt.org"
+		}
+	}
+	if !srv || !caa {
+		t.Errorf("结构化字段未回填: srv=%v caa=%v", srv, caa)
+	}
+}
@@ -0,0 +1,358 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/records.go
+// 权威域记录级增删改查，使用ETag/SOA序列号做乐观并发控制
+
+package bind
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	// ErrZoneNotFound 权威域不存在
+	ErrZoneNotFound = errors.New("权威域不存在")
+	// ErrRecordNotFound 记录不存在
+	ErrRecordNotFound = errors.New("记录不存在")
+	// ErrZoneModified 客户端提供的ETag或序列号与当前zone文件不一致
+	ErrZoneModified = errors.New("权威域已被修改，请刷新后重试")
+	// ErrInvalidRecord 记录内容无效
+	ErrInvalidRecord = errors.New("记录无效")
+)
+
+// RecordFilter 记录列表过滤条件，空字段不过滤
+type RecordFilter struct {
+	Name  string   // 名称，含*时按通配符匹配，否则为不区分大小写的子串
+	Types []string // 记录类型，满足其一即可
+	Value string   // 记录值子串，不区分大小写
+}
+
+// ZoneRecords 记录列表及并发控制信息
+type ZoneRecords struct {
+	Domain  string   `json:"domain"`
+	Serial  string   `json:"serial"`
+	ETag    string   `json:"etag"`
+	Total   int      `json:"total"`
+	Records []Record `json:"records"`
+}
+
+// match 判断记录是否满足过滤条件
+func (f RecordFilter) match(r Record) bool {
+	if f.Name != "" {
+		name := strings.ToLower(r.Name)
+		pattern := strings.ToLower(f.Name)
+		if strings.Contains(pattern, "*") {
+			if ok, _ := path.Match(pattern, name); !ok {
+				return false
+			}
+		} else if !strings.Contains(name, pattern) {
+			return false
+		}
+	}
+	if len(f.Types) > 0 && !slices.ContainsFunc(f.Types, func(t string) bool { return strings.EqualFold(t, r.Type) }) {
+		return false
+	}
+	if f.Value != "" && !strings.Contains(strings.ToLower(r.Value), strings.ToLower(f.Value)) {
+		return false
+	}
+	return true
+}
+
+// zoneETag 由SOA序列号和zone文件内容生成ETag，未更新序列号的外部修改同样会改变ETag
+func zoneETag(serial string, content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf(`"%s-%x"`, serial, sum[:6])
+}
+
+// checkPrecondition 校验If-Match，可为ETag或SOA序列号，多个值以逗号分隔，为空或*时不校验
+func checkPrecondition(ifMatch, etag, serial string) error {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || strings.Trim(tag, `"`) == serial {
+			return nil
+		}
+	}
+	return ErrZoneModified
+}
+
+// loadZoneWithETag 读取权威域及当前ETag
+func (bm *BindManager) loadZoneWithETag(domain string) (*AuthZone, string, error) {
+	if isSystemZone(domain) {
+		return nil, "", fmt.Errorf("系统区域不允许操作: %s", domain)
+	}
+
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		return nil, "", fmt.Errorf("获取权威域失败: %v", err)
+	}
+	for i := range zones {
+		if zones[i].Domain != domain {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(bm.config.ZoneFilePath, filepath.Base(zones[i].File)))
+		if err != nil && zones[i].Type == ZoneTypeSlave && os.IsNotExist(err) {
+			// 辅区域首次传送完成前没有zone文件
+			err = nil
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("读取zone文件失败: %v", err)
+		}
+		return &zones[i], zoneETag(zones[i].SOA.Serial, content), nil
+	}
+	return nil, "", ErrZoneNotFound
+}
+
+// canonicalRecord 校验记录并转换为与解析结果一致的规范形式（名称相对区域顶点，域名值为绝对形式）
+func canonicalRecord(rec Record, apex string) (Record, error) {
+	if err := normalizeRecord(&rec, apex); err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	line, err := renderRecord(rec, apex, apex)
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	rr, err := parseRecordText(line, apex)
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrInvalidRecord, err)
+	}
+	if _, ok := rr.(*dns.SOA); ok {
+		return Record{}, fmt.Errorf("%w: SOA记录请通过权威域接口修改", ErrInvalidRecord)
+	}
+
+	canonical := recordFromRR(rr, apex)
+	canonical.ID = rec.ID
+	canonical.TTL = rec.TTL
+	// 与解析器读取注释的结果保持一致
+	canonical.Comment = strings.TrimSpace(strings.ReplaceAll(rec.Comment, "\n", " "))
+	return canonical, nil
+}
+
+// findRecord 按ID查找记录下标
+func findRecord(records []Record, id string) (int, error) {
+	for i, r := range records {
+		if r.ID != id {
+			continue
+		}
+		if r.Source != "" {
+			return -1, fmt.Errorf("%w: 记录来自$INCLUDE文件%s，只读", ErrInvalidRecord, r.Source)
+		}
+		return i, nil
+	}
+	return -1, ErrRecordNotFound
+}
+
+// sameRRSet 判断两条记录是否为同一资源记录（忽略TTL和注释）
+// 所有者名和RDATA中的域名不区分大小写，其余RDATA（如TXT内容）按原样比较
+func sameRRSet(a, b Record) bool {
+	if !strings.EqualFold(a.Name, b.Name) || !strings.EqualFold(a.Type, b.Type) || a.Priority != b.Priority {
+		return false
+	}
+	if a.Value == b.Value {
+		return true
+	}
+	if !strings.EqualFold(a.Value, b.Value) {
+		return false
+	}
+	// 仅大小写不同时按RDATA字段逐一比较
+	ra, err := recordRR(a)
+	if err != nil {
+		return false
+	}
+	rb, err := recordRR(b)
+	if err != nil {
+		return false
+	}
+	return dns.IsDuplicate(ra, rb)
+}
+
+// recordRR 将通用记录转换为dns.RR，用于按RDATA字段比较
+func recordRR(rec Record) (dns.RR, error) {
+	line, err := renderRecord(rec, ".", ".")
+	if err != nil {
+		return nil, err
+	}
+	return parseRecordText(line, ".")
+}
+
+// ListRecords 列出权威域中满足过滤条件的记录
+func (bm *BindManager) ListRecords(domain string, filter RecordFilter) (*ZoneRecords, error) {
+	zone, etag, err := bm.loadZoneWithETag(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ZoneRecords{
+		Domain:  domain,
+		Serial:  zone.SOA.Serial,
+		ETag:    etag,
+		Records: make([]Record, 0),
+	}
+	for _, r := range zone.Records {
+		if filter.match(r) {
+			result.Records = append(result.Records, r)
+		}
+	}
+	result.Total = len(result.Records)
+	return result, nil
+}
+
+// AddRecord 向权威域添加一条记录，返回写入后的记录和新的ETag
+// rec.AutoPTR为空时按区域默认设置决定是否为A/AAAA记录创建PTR
+func (bm *BindManager) AddRecord(domain string, rec Record, ifMatch string) (*Record, string, error) {
+	return bm.modifyRecords(domain, ifMatch, rec.AutoPTR, func(records []Record, apex string) ([]Record, *Record, error) {
+		rec.ID, rec.Source = "", ""
+		canonical, err := canonicalRecord(rec, apex)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, r := range records {
+			if sameRRSet(r, canonical) {
+				return nil, nil, fmt.Errorf("%w: 记录已存在", ErrInvalidRecord)
+			}
+		}
+		return append(records, canonical), &canonical, nil
+	})
+}
+
+// UpdateRecord 按ID修改一条记录，返回写入后的记录（ID随内容变化）和新的ETag
+func (bm *BindManager) UpdateRecord(domain, id string, rec Record, ifMatch string) (*Record, string, error) {
+	return bm.modifyRecords(domain, ifMatch, rec.AutoPTR, func(records []Record, apex string) ([]Record, *Record, error) {
+		i, err := findRecord(records, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		rec.ID, rec.Source = id, ""
+		canonical, err := canonicalRecord(rec, apex)
+		if err != nil {
+			return nil, nil, err
+		}
+		for j, r := range records {
+			if j != i && sameRRSet(r, canonical) {
+				return nil, nil, fmt.Errorf("%w: 记录已存在", ErrInvalidRecord)
+			}
+		}
+		records[i] = canonical
+		return records, &canonical, nil
+	})
+}
+
+// DeleteRecord 按ID删除一条记录，返回被删除的记录（含PTR变更）和新的ETag
+// 指向该记录且地址一致的PTR随之删除，autoPTR显式为false时保留
+func (bm *BindManager) DeleteRecord(domain, id string, ifMatch string, autoPTR *bool) (*Record, string, error) {
+	return bm.modifyRecords(domain, ifMatch, autoPTR, func(records []Record, apex string) ([]Record, *Record, error) {
+		i, err := findRecord(records, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		// 区域顶点至少保留一条NS记录
+		if records[i].Type == "NS" && records[i].Name == "@" {
+			apexNS := 0
+			for _, r := range records {
+				if r.Type == "NS" && r.Name == "@" {
+					apexNS++
+				}
+			}
+			if apexNS <= 1 {
+				return nil, nil, fmt.Errorf("%w: 不能删除区域顶点的最后一条NS记录", ErrInvalidRecord)
+			}
+		}
+		deleted := records[i]
+		return slices.Delete(records, i, i+1), &deleted, nil
+	})
+}
+
+// modifyRecords 在zoneFileMu保护下完成 读取-校验ETag-修改-写入
+// 写入复用updateAuthZone，因此同样经过HistoryManager备份、序列号递增、named-checkzone校验和失败回滚
+// apply返回的记录不在修改后的记录中时视为删除，直接返回该记录
+func (bm *BindManager) modifyRecords(domain, ifMatch string, autoPTR *bool, apply func(records []Record, apex string) ([]Record, *Record, error)) (*Record, string, error) {
+	zoneFileMu.Lock()
+	defer zoneFileMu.Unlock()
+	defer bm.freezeDynamicZones()()
+
+	zone, etag, err := bm.loadZoneWithETag(domain)
+	if err != nil {
+		return nil, "", err
+	}
+	if zone.Type == ZoneTypeSlave {
+		return nil, "", ErrReadOnlyZone
+	}
+	if err := checkPrecondition(ifMatch, etag, zone.SOA.Serial); err != nil {
+		return nil, "", err
+	}
+
+	before := zone.Records
+	records, target, err := apply(slices.Clone(zone.Records), dns.Fqdn(domain))
+	if err != nil {
+		return nil, "", err
+	}
+	zone.Records = records
+
+	enabled := zone.AutoPTR != nil && *zone.AutoPTR
+	if autoPTR != nil {
+		enabled = *autoPTR
+	}
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		return nil, "", fmt.Errorf("获取权威域失败: %v", err)
+	}
+	// 请求未显式关闭自动PTR时，清理与被移除记录完全一致的PTR
+	cleanup := autoPTR == nil || *autoPTR
+	changes, err := bm.writeZoneWithPTR(*zone, before, zones, enabled, cleanup)
+	if err != nil {
+		return nil, "", err
+	}
+
+	updated, newETag, err := bm.loadZoneWithETag(domain)
+	if err != nil {
+		return nil, "", err
+	}
+	if target == nil {
+		return nil, newETag, nil
+	}
+	if !slices.ContainsFunc(records, func(r Record) bool { return sameRecord(r, *target) }) {
+		target.PTRChanges = changes
+		return target, newETag, nil
+	}
+	// 写入后的记录ID由内容决定，按ID查找（跳过$INCLUDE文件中内容相同的记录）
+	key := recordIDKey(*target)
+	for n := 0; ; n++ {
+		id := recordIDAt(key, n)
+		i := slices.IndexFunc(updated.Records, func(r Record) bool { return r.ID == id })
+		if i < 0 {
+			break
+		}
+		if r := updated.Records[i]; r.Source == "" {
+			r.PTRChanges = changes
+			return &r, newETag, nil
+		}
+	}
+	return nil, newETag, fmt.Errorf("写入后未找到记录")
+}
@@ -0,0 +1,174 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/records_test.go
+// 记录级增删改查的过滤、并发校验和规范化测试
+
+package bind
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestRecordFilter 测试记录过滤条件
+func TestRecordFilter(t *testing.T) {
+	rec := Record{Name: "www.api", Type: "CNAME", Value: "LB.example.com."}
+	tests := []struct {
+		filter RecordFilter
+		want   bool
+	}{
+		{RecordFilter{}, true},
+		{RecordFilter{Name: "API"}, true},
+		{RecordFilter{Name: "*.api"}, true},
+		{RecordFilter{Name: "*.www"}, false},
+		{RecordFilter{Types: []string{"a", "cname"}}, true},
+		{RecordFilter{Types: []string{"A"}}, false},
+		{RecordFilter{Value: "lb.example"}, true},
+		{RecordFilter{Name: "www", Value: "other"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.match(rec); got != tt.want {
+			t.Errorf("%+v.match() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+// TestCheckPrecondition 测试If-Match的ETag和序列号校验
+func TestCheckPrecondition(t *testing.T) {
+	etag := zoneETag("2026031501", []byte("zone"))
+	if etag == zoneETag("2026031501", []byte("zone changed")) {
+		t.Fatalf("内容变化时ETag应变化")
+	}
+	tests := []struct {
+		ifMatch string
+		ok      bool
+	}{
+		{"", true},
+		{"*", true},
+		{etag, true},
+		{"W/" + etag, true},
+		{`"other", ` + etag, true},
+		{"2026031501", true},
+		{`"2026031501"`, true},
+		{"2026031500", false},
+		{zoneETag("2026031501", []byte("old")), false},
+	}
+	for _, tt := range tests {
+		err := checkPrecondition(tt.ifMatch, etag, "2026031501")
+		if (err == nil) != tt.ok {
+			t.Errorf("checkPrecondition(%q) error = %v, ok = %v", tt.ifMatch, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrZoneModified) {
+			t.Errorf("checkPrecondition(%q) error = %v, want ErrZoneModified", tt.ifMatch, err)
+		}
+	}
+}
+
+// TestCanonicalRecord 测试提交的记录被转换为与解析结果一致的形式
+func TestCanonicalRecord(t *testing.T) {
+	got, err := canonicalRecord(Record{Name: "www.example.com.", Type: "cname", Value: "lb.example.com", TTL: 300, Comment: "a\nb"}, "example.com.")
+	if err != nil {
+		t.Fatalf("canonicalRecord() error = %v", err)
+	}
+	if got.Name != "www" || got.Type != "CNAME" || got.Value != "lb.example.com." || got.TTL != 300 || got.Comment != "a b" {
+		t.Errorf("canonicalRecord() = %+v", got)
+	}
+
+	// 写入后重新解析得到相同的注释，并能按内容生成的ID找到
+	got, err = canonicalRecord(Record{Name: "api", Type: "A", Value: "192.0.2.50", Comment: "  新增 \n"}, "example.com.")
+	if err != nil {
+		t.Fatalf("canonicalRecord() error = %v", err)
+	}
+	line, err := renderRecord(got, "example.com.", "example.com.")
+	if err != nil {
+		t.Fatalf("renderRecord() error = %v", err)
+	}
+	zf, err := parseZoneContent(line+"\n", "example.com", "")
+	if err != nil || len(zf.records) != 1 {
+		t.Fatalf("parseZoneContent() = %+v, %v", zf, err)
+	}
+	if parsed := zf.records[0]; parsed.Comment != got.Comment || parsed.ID != recordIDAt(recordIDKey(got), 0) {
+		t.Errorf("写入后解析的记录 = %+v, canonical = %+v", parsed, got)
+	}
+
+	got, err = canonicalRecord(Record{Name: "@", Type: "MX", Value: "mail.example.com", Priority: 10}, "example.com.")
+	if err != nil {
+		t.Fatalf("canonicalRecord() error = %v", err)
+	}
+	if got.Value != "mail.example.com." || got.Priority != 10 {
+		t.Errorf("canonicalRecord() = %+v", got)
+	}
+
+	for _, rec := range []Record{
+		{Name: "www", Type: "A", Value: "192.0.2.300"},
+		{Name: "@", Type: "SOA", Value: "ns. admin. 1 2 3 4 5"},
+		{Name: "sip", Type: "SRV", Value: "10 5 5060 sip.example.com."},
+	} {
+		if _, err := canonicalRecord(rec, "example.com."); !errors.Is(err, ErrInvalidRecord) {
+			t.Errorf("canonicalRecord(%+v) error = %v, want ErrInvalidRecord", rec, err)
+		}
+	}
+}
+
+// TestFindRecord 测试按ID查找记录，$INCLUDE文件中的记录只读
+func TestFindRecord(t *testing.T) {
+	zone, err := newTestBindManager().parseZoneFile("testdata/example.net.zone", "example.net")
+	if err != nil {
+		t.Fatalf("parseZoneFile() error = %v", err)
+	}
+	var local, included string
+	for _, r := range zone.Records {
+		if r.Source == "" && local == "" {
+			local = r.ID
+		}
+		if r.Source != "" && included == "" {
+			included = r.ID
+		}
+	}
+
+	if i, err := findRecord(zone.Records, local); err != nil || zone.Records[i].ID != local {
+		t.Errorf("findRecord(local) = %d, %v", i, err)
+	}
+	if _, err := findRecord(zone.Records, included); !errors.Is(err, ErrInvalidRecord) {
+		t.Errorf("findRecord(included) error = %v, want ErrInvalidRecord", err)
+	}
+	if _, err := findRecord(zone.Records, "missing"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("findRecord(missing) error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+// TestSameRRSet 测试所有者名和RDATA中的域名不区分大小写，其余RDATA区分大小写
+func TestSameRRSet(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Record
+		want bool
+	}{
+		{"所有者名大小写", Record{Name: "WWW", Type: "A", Value: "192.0.2.1"}, Record{Name: "www", Type: "a", Value: "192.0.2.1"}, true},
+		{"CNAME目标大小写", Record{Name: "www", Type: "CNAME", Value: "LB.example.com."}, Record{Name: "www", Type: "CNAME", Value: "lb.example.com."}, true},
+		{"SRV目标大小写", Record{Name: "_sip._udp", Type: "SRV", Value: "0 5 5060 SIP.example.com."}, Record{Name: "_sip._udp", Type: "SRV", Value: "0 5 5060 sip.example.com."}, true},
+		{"TXT内容大小写", Record{Name: "@", Type: "TXT", Value: `"Token=ABC"`}, Record{Name: "@", Type: "TXT", Value: `"token=abc"`}, false},
+		{"CAA值大小写", Record{Name: "@", Type: "CAA", Value: `0 issue "CA.example"`}, Record{Name: "@", Type: "CAA", Value: `0 issue "ca.example"`}, false},
+		{"优先级不同", Record{Name: "@", Type: "MX", Value: "mail.example.com.", Priority: 10}, Record{Name: "@", Type: "MX", Value: "mail.example.com.", Priority: 20}, false},
+	}
+	for _, tt := range tests {
+		if got := sameRRSet(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: sameRRSet() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/reverseptr.go
+// 自动维护反向解析：A/AAAA记录变更时同步覆盖该地址的反向区域中的PTR记录，并检查孤立PTR
+
+package bind
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"SteadyDNS/core/database"
+
+	"github.com/miekg/dns"
+)
+
+// PTR变更类型
+const (
+	PTRActionCreated = "created"
+	PTRActionUpdated = "updated"
+	PTRActionDeleted = "deleted"
+	PTRActionSkipped = "skipped"
+)
+
+// 孤立PTR的原因
+const (
+	OrphanReasonNoAddress       = "no_address"       // 目标名称没有A/AAAA记录
+	OrphanReasonAddressMismatch = "address_mismatch" // 目标名称的A/AAAA记录不包含该地址
+)
+
+// PTRChange 自动维护的PTR记录变更
+type PTRChange struct {
+	Action  string `json:"action"`
+	Zone    string `json:"zone,omitempty"` // 反向区域
+	Name    string `json:"name,omitempty"` // 反向区域中的记录名称
+	Address string `json:"address"`
+	Target  string `json:"target"`           // PTR指向的正向域名
+	Reason  string `json:"reason,omitempty"` // 跳过的原因
+}
+
+// OrphanPTR 孤立的PTR记录：指向受管正向区域中的名称，但该名称没有对应地址的A/AAAA记录
+type OrphanPTR struct {
+	Zone     string `json:"zone"`
+	RecordID string `json:"record_id"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	Target   string `json:"target"`
+	Reason   string `json:"reason"`
+}
+
+// OrphanPTRReport 孤立PTR检查报告
+type OrphanPTRReport struct {
+	Checked    int         `json:"checked"`    // 已核对的PTR记录数
+	Unverified int         `json:"unverified"` // 目标不在受管正向区域或名称无法解析为地址的PTR记录数
+	Orphans    []OrphanPTR `json:"orphans"`
+}
+
+// addressRecord 正向区域中的一条A/AAAA记录
+type addressRecord struct {
+	owner string // 小写的绝对域名
+	ip    net.IP
+	ttl   int
+	auto  *bool // 记录级的自动PTR设置
+}
+
+// key 返回记录的比较键，名称大小写和地址书写形式不影响比较
+func (a addressRecord) key() string {
+	return a.owner + " " + a.ip.String()
+}
+
+// isReverseZone 判断区域是否为IPv4或IPv6反向区域
+func isReverseZone(domain string) bool {
+	name := strings.ToLower(dns.Fqdn(domain))
+	return dns.IsSubDomain("in-addr.arpa.", name) || dns.IsSubDomain("ip6.arpa.", name)
+}
+
+// addressRecords 提取记录中的A/AAAA记录，通配符名称不参与反向解析
+func addressRecords(records []Record, apex string) []addressRecord {
+	var result []addressRecord
+	for _, r := range records {
+		if (r.Type != "A" && r.Type != "AAAA") || strings.Contains(r.Name, "*") {
+			continue
+		}
+		ip := net.ParseIP(strings.TrimSpace(r.Value))
+		if ip == nil {
+			continue
+		}
+		result = append(result, addressRecord{
+			owner: strings.ToLower(absoluteName(r.Name, apex)),
+			ip:    ip,
+			ttl:   r.TTL,
+			auto:  r.AutoPTR,
+		})
+	}
+	return result
+}
+
+// findReverseZone 查找覆盖反向域名的受管主反向区域，存在多个时取最长匹配
+func findReverseZone(zones []*AuthZone, reverseName string) *AuthZone {
+	var best *AuthZone
+	for _, zone := range zones {
+		apex := strings.ToLower(dns.Fqdn(zone.Domain))
+		if !dns.IsSubDomain(apex, reverseName) {
+			continue
+		}
+		if best == nil || len(apex) > len(dns.Fqdn(best.Domain)) {
+			best = zone
+		}
+	}
+	return best
+}
+
+// planPTRChanges 比较正向区域修改前后的A/AAAA记录，计算反向区域需要的PTR变更
+// 返回修改后的反向区域和变更明细；enabled为区域或请求的默认设置，记录的AutoPTR优先
+// cleanup为true时，名称和地址与被移除记录完全一致的PTR总是删除，不论该PTR是否由区域默认设置创建
+// （记录级开启自动PTR创建的PTR不保存开关状态，只能据此清理）
+func planPTRChanges(zones []AuthZone, zone AuthZone, before []Record, enabled, cleanup bool) ([]AuthZone, []PTRChange) {
+	apex := dns.Fqdn(zone.Domain)
+	var reverseZones []*AuthZone
+	for i := range zones {
+		if zones[i].Type != ZoneTypeSlave && isReverseZone(zones[i].Domain) && !strings.EqualFold(zones[i].Domain, zone.Domain) {
+			reverse := zones[i]
+			reverse.Records = slices.Clone(reverse.Records)
+			reverseZones = append(reverseZones, &reverse)
+		}
+	}
+
+	oldAddrs := addressRecords(before, apex)
+	newAddrs := addressRecords(zone.Records, apex)
+	contains := func(list []addressRecord, a addressRecord) bool {
+		return slices.ContainsFunc(list, func(b addressRecord) bool { return b.key() == a.key() })
+	}
+
+	changed := make(map[string]bool)
+	var changes []PTRChange
+
+	// 先删除已移除地址的PTR，记录修改时旧PTR腾出后再创建新PTR
+	for _, a := range oldAddrs {
+		if !(enabled || cleanup) || contains(newAddrs, a) {
+			continue
+		}
+		reverseName, _ := dns.ReverseAddr(a.ip.String())
+		target := findReverseZone(reverseZones, reverseName)
+		if target == nil {
+			continue
+		}
+		name := relativeName(reverseName, dns.Fqdn(target.Domain))
+		i := slices.IndexFunc(target.Records, func(r Record) bool {
+			return r.Type == "PTR" && r.Source == "" && strings.EqualFold(r.Name, name) && strings.EqualFold(dns.Fqdn(r.Value), a.owner)
+		})
+		if i < 0 {
+			continue
+		}
+		target.Records = slices.Delete(target.Records, i, i+1)
+		changed[target.Domain] = true
+		changes = append(changes, PTRChange{Action: PTRActionDeleted, Zone: target.Domain, Name: name, Address: a.ip.String(), Target: a.owner})
+	}
+
+	for _, a := range newAddrs {
+		on := enabled
+		if a.auto != nil {
+			on = *a.auto
+		}
+		if !on || contains(oldAddrs, a) {
+			continue
+		}
+		change := PTRChange{Action: PTRActionCreated, Address: a.ip.String(), Target: a.owner}
+		reverseName, _ := dns.ReverseAddr(a.ip.String())
+		target := findReverseZone(reverseZones, reverseName)
+		if target == nil {
+			change.Action = PTRActionSkipped
+			change.Reason = "没有覆盖该地址的受管反向区域"
+			changes = append(changes, change)
+			continue
+		}
+		change.Zone = target.Domain
+		change.Name = relativeName(reverseName, dns.Fqdn(target.Domain))
+
+		existing := slices.IndexFunc(target.Records, func(r Record) bool {
+			return r.Type == "PTR" && strings.EqualFold(r.Name, change.Name)
+		})
+		if existing >= 0 {
+			if !strings.EqualFold(dns.Fqdn(target.Records[existing].Value), a.owner) {
+				change.Action = PTRActionSkipped
+				change.Reason = fmt.Sprintf("已存在指向 %s 的PTR记录", target.Records[existing].Value)
+				changes = append(changes, change)
+			}
+			continue
+		}
+
+		target.Records = append(target.Records, Record{Name: change.Name, Type: "PTR", Value: a.owner, TTL: a.ttl})
+		changed[target.Domain] = true
+
+		// 同一名称先删除后创建的合并为一次更新
+		if j := slices.IndexFunc(changes, func(c PTRChange) bool {
+			return c.Action == PTRActionDeleted && c.Zone == change.Zone && strings.EqualFold(c.Name, change.Name)
+		}); j >= 0 {
+			changes[j].Action = PTRActionUpdated
+			changes[j].Target = a.owner
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	var modified []AuthZone
+	for _, reverse := range reverseZones {
+		if changed[reverse.Domain] {
+			modified = append(modified, *reverse)
+		}
+	}
+	return modified, changes
+}
+
+// writeZoneWithPTR 写入正向区域，需要修改反向区域时在同一个HistoryManager备份中一并写入
+// enabled和cleanup的含义见planPTRChanges，调用方需持有zoneFileMu
+func (bm *BindManager) writeZoneWithPTR(zone AuthZone, before []Record, zones []AuthZone, enabled, cleanup bool) ([]PTRChange, error) {
+	reverseZones, changes := planPTRChanges(zones, zone, before, enabled, cleanup)
+	if len(reverseZones) == 0 {
+		return changes, bm.updateAuthZone(zone, true)
+	}
+	if err := bm.updateZonesWithBackup(zone.Domain, append([]AuthZone{zone}, reverseZones...)); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// updateZonesWithBackup 创建一次全量备份后依次写入多个区域，调用方需持有zoneFileMu
+// 任一区域写入失败时恢复named.conf和已写入区域的zone文件并删除备份记录，保证多个区域的修改同时生效或同时回滚
+func (bm *BindManager) updateZonesWithBackup(domain string, zones []AuthZone) error {
+	originals := make([][]byte, len(zones))
+	for i, zone := range zones {
+		content, err := os.ReadFile(filepath.Join(bm.config.ZoneFilePath, filepath.Base(zone.File)))
+		if err != nil {
+			return fmt.Errorf("读取zone文件失败: %v", err)
+		}
+		originals[i] = content
+	}
+
+	// 区域的访问控制或DNSSEC配置变更会改写named.conf，一并保存用于回滚
+	namedConfPath := filepath.Join(bm.config.NamedConfPath, "named.conf")
+	originalNamedConf, err := os.ReadFile(namedConfPath)
+	if err != nil {
+		return fmt.Errorf("读取named.conf文件失败: %v", err)
+	}
+	allZoneFiles, _ := bm.getAllZoneFiles()
+	files := append([]string{namedConfPath}, allZoneFiles...)
+	zonesJSON, _ := json.Marshal(zones)
+	backupID, err := bm.HistoryMgr.CreateBackup(OperationUpdate, domain, zonesJSON, files)
+	if err != nil {
+		bm.logger.Warn("创建备份失败: %v", err)
+		backupID = 0
+	}
+
+	for i, zone := range zones {
+		if err := bm.updateAuthZone(zone, false); err != nil {
+			// 回滚：恢复named.conf和此前已写入的区域
+			if err := os.WriteFile(namedConfPath, originalNamedConf, 0644); err != nil {
+				bm.logger.Error("恢复named.conf失败: %v", err)
+			}
+			for j := 0; j < i; j++ {
+				os.WriteFile(filepath.Join(bm.config.ZoneFilePath, filepath.Base(zones[j].File)), originals[j], 0644)
+			}
+			if i > 0 {
+				if err := bm.ReloadBind(); err != nil {
+					bm.logger.Error("刷新BIND服务器失败: %v", err)
+				}
+			}
+			if backupID > 0 {
+				bm.HistoryMgr.DeleteBackupRecord(backupID)
+			}
+			return fmt.Errorf("更新区域 %s 失败: %w", zone.Domain, err)
+		}
+	}
+	return nil
+}
+
+// loadZoneAutoPTR 读取各区域的自动PTR默认设置，数据库未初始化时返回空集合
+func (bm *BindManager) loadZoneAutoPTR() map[string]bool {
+	settings := make(map[string]bool)
+	if database.DB == nil {
+		return settings
+	}
+	list, err := database.GetZoneSettings()
+	if err != nil {
+		bm.logger.Warn("%v", err)
+		return settings
+	}
+	for _, setting := range list {
+		settings[setting.Domain] = setting.AutoPTR
+	}
+	return settings
+}
+
+// saveZoneAutoPTR 保存区域的自动PTR默认设置，失败时仅记录警告
+func (bm *BindManager) saveZoneAutoPTR(domain string, enabled bool) {
+	if database.DB == nil {
+		return
+	}
+	if err := database.SaveZoneSetting(&database.ZoneSetting{Domain: domain, AutoPTR: enabled}); err != nil {
+		bm.logger.Warn("%v", err)
+	}
+}
+
+// deleteZoneAutoPTR 删除区域的管理设置，失败时仅记录警告
+func (bm *BindManager) deleteZoneAutoPTR(domain string) {
+	if database.DB == nil {
+		return
+	}
+	if err := database.DeleteZoneSetting(domain); err != nil {
+		bm.logger.Warn("%v", err)
+	}
+}
+
+// addressFromReverse 将反向域名转换为IP地址，不是完整的单地址反向名称时返回nil
+func addressFromReverse(name string) net.IP {
+	name = strings.ToLower(dns.Fqdn(name))
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa."):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa."), ".")
+		if len(labels) != 4 {
+			return nil
+		}
+		slices.Reverse(labels)
+		return net.ParseIP(strings.Join(labels, ".")).To4()
+	case strings.HasSuffix(name, ".ip6.arpa."):
+		nibbles := strings.Split(strings.TrimSuffix(name, ".ip6.arpa."), ".")
+		if len(nibbles) != 32 {
+			return nil
+		}
+		ip := make(net.IP, net.IPv6len)
+		for i, nibble := range nibbles {
+			v, err := strconv.ParseUint(nibble, 16, 4)
+			if err != nil || len(nibble) != 1 {
+				return nil
+			}
+			// 最低位的半字节在最前
+			pos := 31 - i
+			ip[pos/2] |= byte(v) << (4 * uint(1-pos%2))
+		}
+		return ip
+	}
+	return nil
+}
+
+// findOrphanPTRs 检查反向区域中的PTR记录是否有对应的正向A/AAAA记录
+// 仅核对目标位于受管正向区域中的PTR，其余计为未核对
+func findOrphanPTRs(zones []AuthZone) *OrphanPTRReport {
+	report := &OrphanPTRReport{Orphans: make([]OrphanPTR, 0)}
+
+	var forwardApexes []string
+	addresses := make(map[string][]net.IP)
+	for _, zone := range zones {
+		if isReverseZone(zone.Domain) {
+			continue
+		}
+		apex := strings.ToLower(dns.Fqdn(zone.Domain))
+		forwardApexes = append(forwardApexes, apex)
+		for _, a := range addressRecords(zone.Records, apex) {
+			addresses[a.owner] = append(addresses[a.owner], a.ip)
+		}
+	}
+
+	for _, zone := range zones {
+		if !isReverseZone(zone.Domain) {
+			continue
+		}
+		apex := dns.Fqdn(zone.Domain)
+		for _, r := range zone.Records {
+			if r.Type != "PTR" {
+				continue
+			}
+			ip := addressFromReverse(absoluteName(r.Name, apex))
+			target := strings.ToLower(dns.Fqdn(r.Value))
+			managed := slices.ContainsFunc(forwardApexes, func(a string) bool { return dns.IsSubDomain(a, target) })
+			if ip == nil || !managed {
+				report.Unverified++
+				continue
+			}
+			report.Checked++
+
+			ips := addresses[target]
+			if slices.ContainsFunc(ips, ip.Equal) {
+				continue
+			}
+			orphan := OrphanPTR{
+				Zone:     zone.Domain,
+				RecordID: r.ID,
+				Name:     r.Name,
+				Address:  ip.String(),
+				Target:   target,
+				Reason:   OrphanReasonNoAddress,
+			}
+			if len(ips) > 0 {
+				orphan.Reason = OrphanReasonAddressMismatch
+			}
+			report.Orphans = append(report.Orphans, orphan)
+		}
+	}
+	return report
+}
+
+// GetOrphanPTRReport 获取所有受管反向区域中的孤立PTR记录
+func (bm *BindManager) GetOrphanPTRReport() (*OrphanPTRReport, error) {
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		return nil, err
+	}
+	return findOrphanPTRs(zones), nil
+}
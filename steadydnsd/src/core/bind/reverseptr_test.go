@@ -0,0 +1,179 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/reverseptr_test.go
+// 自动PTR变更计算、反向名称解析和孤立PTR检查测试
+
+package bind
+
+import (
+	"net"
+	"testing"
+)
+
+// reversePTRTestZones 返回一个正向区域和覆盖其地址的IPv4、IPv6反向区域
+func reversePTRTestZones() []AuthZone {
+	return []AuthZone{
+		{Domain: "example.com", Type: ZoneTypeMaster, Records: []Record{
+			{ID: "1", Name: "www", Type: "A", Value: "192.0.3.10"},
+			{ID: "2", Name: "mail", Type: "A", Value: "192.0.2.20"},
+			{ID: "3", Name: "v6", Type: "AAAA", Value: "2001:db8::1"},
+		}},
+		{Domain: "0.192.in-addr.arpa", Type: ZoneTypeMaster, Records: []Record{
+			{ID: "10", Name: "10.3", Type: "PTR", Value: "www.example.com."},
+		}},
+		{Domain: "2.0.192.in-addr.arpa", Type: ZoneTypeMaster, Records: []Record{
+			{ID: "20", Name: "20", Type: "PTR", Value: "mail.example.com."},
+			{ID: "30", Name: "30", Type: "PTR", Value: "old.example.com."},
+			{ID: "40", Name: "40", Type: "PTR", Value: "host.example.org."},
+		}},
+		{Domain: "8.b.d.0.1.0.0.2.ip6.arpa", Type: ZoneTypeMaster, Records: []Record{}},
+	}
+}
+
+// TestPlanPTRChanges 测试A/AAAA记录增删改对应的PTR变更
+func TestPlanPTRChanges(t *testing.T) {
+	zones := reversePTRTestZones()
+	forward := zones[0]
+	before := forward.Records
+	off := false
+
+	// 修改mail的地址、删除www、新增v6地址，并为一条记录单独关闭自动PTR
+	forward.Records = []Record{
+		{Name: "mail", Type: "A", Value: "192.0.2.21", TTL: 300},
+		{Name: "v6", Type: "AAAA", Value: "2001:db8::1"},
+		{Name: "v6b", Type: "AAAA", Value: "2001:db8::2"},
+		{Name: "manual", Type: "A", Value: "192.0.2.99", AutoPTR: &off},
+		{Name: "taken", Type: "A", Value: "192.0.2.30"},
+		{Name: "outside", Type: "A", Value: "198.51.100.1"},
+	}
+	modified, changes := planPTRChanges(zones, forward, before, true, true)
+
+	want := map[string]string{
+		"192.0.3.10":   PTRActionDeleted,
+		"192.0.2.21":   PTRActionCreated,
+		"192.0.2.20":   PTRActionDeleted,
+		"2001:db8::2":  PTRActionCreated,
+		"192.0.2.30":   PTRActionSkipped,
+		"198.51.100.1": PTRActionSkipped,
+	}
+	got := make(map[string]string)
+	for _, c := range changes {
+		got[c.Address] = c.Action
+	}
+	for addr, action := range want {
+		if got[addr] != action {
+			t.Errorf("%s 的PTR变更 = %q，期望 %q（全部变更: %+v）", addr, got[addr], action, changes)
+		}
+	}
+	if _, ok := got["192.0.2.99"]; ok {
+		t.Errorf("关闭自动PTR的记录不应产生变更")
+	}
+	if len(modified) != 3 {
+		t.Fatalf("期望修改3个反向区域，得到 %d", len(modified))
+	}
+
+	for _, zone := range modified {
+		switch zone.Domain {
+		case "0.192.in-addr.arpa":
+			if len(zone.Records) != 0 {
+				t.Errorf("www的PTR未被删除: %+v", zone.Records)
+			}
+		case "2.0.192.in-addr.arpa":
+			var has21, has20 bool
+			for _, r := range zone.Records {
+				has21 = has21 || (r.Name == "21" && r.Value == "mail.example.com." && r.TTL == 300)
+				has20 = has20 || r.Name == "20"
+			}
+			if !has21 || has20 {
+				t.Errorf("mail的PTR未正确迁移: %+v", zone.Records)
+			}
+		case "8.b.d.0.1.0.0.2.ip6.arpa":
+			if len(zone.Records) != 1 || zone.Records[0].Name != "2.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0" {
+				t.Errorf("IPv6 PTR不符合预期: %+v", zone.Records)
+			}
+		}
+	}
+
+	// 同一名称先删后建合并为更新
+	forward = zones[0]
+	forward.Records = []Record{{Name: "web", Type: "A", Value: "192.0.2.20"}}
+	_, changes = planPTRChanges(zones, forward, []Record{{Name: "mail", Type: "A", Value: "192.0.2.20"}}, true, true)
+	if len(changes) != 1 || changes[0].Action != PTRActionUpdated || changes[0].Target != "web.example.com." {
+		t.Errorf("修改名称应合并为一次PTR更新: %+v", changes)
+	}
+
+	// 未启用时不产生变更
+	if modified, changes := planPTRChanges(zones, zones[0], nil, false, false); len(modified) != 0 || len(changes) != 0 {
+		t.Errorf("未启用自动PTR时不应产生变更: %+v", changes)
+	}
+
+	// 区域未启用自动PTR时，删除记录仍清理名称和地址完全一致的PTR（如记录级开启时创建的PTR）
+	forward = zones[0]
+	forward.Records = []Record{{Name: "www", Type: "A", Value: "192.0.3.10"}}
+	before = []Record{{Name: "www", Type: "A", Value: "192.0.3.10"}, {Name: "mail", Type: "A", Value: "192.0.2.20"}, {Name: "old", Type: "A", Value: "192.0.2.31"}}
+	modified, changes = planPTRChanges(zones, forward, before, false, true)
+	if len(changes) != 1 || changes[0].Action != PTRActionDeleted || changes[0].Address != "192.0.2.20" || len(modified) != 1 {
+		t.Errorf("应删除与被移除记录一致的PTR: %+v", changes)
+	}
+	// 请求显式关闭时保留PTR
+	if _, changes := planPTRChanges(zones, forward, before, false, false); len(changes) != 0 {
+		t.Errorf("显式关闭自动PTR时不应删除PTR: %+v", changes)
+	}
+}
+
+// TestAddressFromReverse 测试反向名称转换为地址
+func TestAddressFromReverse(t *testing.T) {
+	cases := map[string]string{
+		"10.2.0.192.in-addr.arpa.": "192.0.2.10",
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa": "2001:db8::1",
+		"0/26.2.0.192.in-addr.arpa.": "",
+		"2.0.192.in-addr.arpa.":      "",
+		"www.example.com.":           "",
+	}
+	for name, want := range cases {
+		ip := addressFromReverse(name)
+		if want == "" {
+			if ip != nil {
+				t.Errorf("addressFromReverse(%q) = %v，期望 nil", name, ip)
+			}
+			continue
+		}
+		if !ip.Equal(net.ParseIP(want)) {
+			t.Errorf("addressFromReverse(%q) = %v，期望 %s", name, ip, want)
+		}
+	}
+}
+
+// TestFindOrphanPTRs 测试孤立PTR检查
+func TestFindOrphanPTRs(t *testing.T) {
+	zones := reversePTRTestZones()
+	// mail改用新地址，原PTR指向的名称仍存在但地址不一致
+	zones[0].Records[1].Value = "192.0.2.25"
+
+	report := findOrphanPTRs(zones)
+	if report.Checked != 3 || report.Unverified != 1 {
+		t.Errorf("Checked = %d, Unverified = %d，期望 3, 1", report.Checked, report.Unverified)
+	}
+	reasons := make(map[string]string)
+	for _, o := range report.Orphans {
+		reasons[o.Target] = o.Reason
+	}
+	if len(report.Orphans) != 2 || reasons["mail.example.com."] != OrphanReasonAddressMismatch || reasons["old.example.com."] != OrphanReasonNoAddress {
+		t.Errorf("孤立PTR不符合预期: %+v", report.Orphans)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/rndc.go
+// rndc命令调用
+
+package bind
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// rndcCommand 返回rndc可执行文件路径，优先使用BIND_EXEC_RELOAD中配置的rndc
+func (bm *BindManager) rndcCommand() string {
+	if fields := strings.Fields(bm.config.BindExecReload); len(fields) > 0 && strings.HasSuffix(fields[0], "rndc") {
+		return fields[0]
+	}
+	return "rndc"
+}
+
+// runRndc 使用配置的密钥和端口执行rndc子命令，返回命令输出
+func (bm *BindManager) runRndc(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var cmdArgs []string
+	if bm.config.RNDPort != "" {
+		cmdArgs = append(cmdArgs, "-p", bm.config.RNDPort)
+	}
+	if bm.config.RNDCKey != "" {
+		cmdArgs = append(cmdArgs, "-k", bm.config.RNDCKey)
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	output, err := exec.CommandContext(ctx, bm.rndcCommand(), cmdArgs...).CombinedOutput()
+	outputStr := strings.TrimSpace(string(output))
+	bm.logger.Debug("rndc %s 命令输出: %s", strings.Join(args, " "), outputStr)
+	if err != nil {
+		return outputStr, fmt.Errorf("rndc %s 执行失败: %v, 输出: %s", strings.Join(args, " "), err, outputStr)
+	}
+	return outputStr, nil
+}
+
+// isDynamicZone 判断区域是否允许BIND接收动态更新（此时zone文件由BIND维护日志）
+func isDynamicZone(zone AuthZone) bool {
+	allowUpdate := trimClauseValue(zone.AllowUpdate)
+	updatePolicy := trimClauseValue(zone.UpdatePolicy)
+	return (allowUpdate != "" && allowUpdate != "none") || (updatePolicy != "" && updatePolicy != "none")
+}
+
+// freezeZone 冻结动态区域，将日志合并进zone文件并暂停动态更新，返回解冻函数
+// rndc执行失败（如BIND未运行）时仅记录警告，此时zone文件本身即为最新内容
+func (bm *BindManager) freezeZone(zone AuthZone) func() {
+	if !isDynamicZone(zone) {
+		return func() {}
+	}
+	if _, err := bm.runRndc("freeze", zone.Domain); err != nil {
+		bm.logger.Warn("冻结动态区域失败: %v", err)
+		return func() {}
+	}
+	return func() {
+		if _, err := bm.runRndc("thaw", zone.Domain); err != nil {
+			bm.logger.Warn("解冻动态区域失败: %v", err)
+		}
+	}
+}
+
+// freezeDynamicZones 冻结named.conf中的所有动态主区域，返回解冻函数，调用方需持有zoneFileMu
+// 必须在读取zone文件之前调用：BIND先将日志合并进zone文件并暂停动态更新，
+// 之后读取的内容即为最新，写回时不会丢失日志中的更新（包括自动PTR同步涉及的反向区域）
+func (bm *BindManager) freezeDynamicZones() func() {
+	content, err := os.ReadFile(filepath.Join(bm.config.NamedConfPath, "named.conf"))
+	if err != nil {
+		// 读取失败由后续读取named.conf的操作报告
+		return func() {}
+	}
+
+	var thaws []func()
+	for _, zone := range dynamicZones(string(content)) {
+		thaws = append(thaws, bm.freezeZone(zone))
+	}
+	return func() {
+		for _, thaw := range thaws {
+			thaw()
+		}
+	}
+}
+
+// dynamicZones 从named.conf内容中找出允许BIND接收动态更新的主区域，仅解析区域名和更新授权配置
+func dynamicZones(content string) []AuthZone {
+	zoneRegex := regexp.MustCompile(`zone\s+"([^"]+)"\s+IN\s*\{`)
+	typeRegex := regexp.MustCompile(`(?:^|[\s;{])type\s+(\w+)\s*;`)
+
+	var zones []AuthZone
+	for _, match := range zoneRegex.FindAllStringSubmatchIndex(content, -1) {
+		block := extractZoneBlock(content, match[0])
+		typeMatch := typeRegex.FindStringSubmatch(block)
+		if typeMatch == nil {
+			continue
+		}
+		if zoneType, err := NormalizeZoneType(typeMatch[1]); err != nil || zoneType != ZoneTypeMaster {
+			continue
+		}
+		zone := AuthZone{
+			Domain:       content[match[2]:match[3]],
+			AllowUpdate:  extractZoneClause(block, "allow-update"),
+			UpdatePolicy: extractZoneClause(block, "update-policy"),
+		}
+		if isDynamicZone(zone) {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
@@ -0,0 +1,62 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/rndc_test.go
+// rndc调用相关的区域识别测试
+
+package bind
+
+import "testing"
+
+// TestDynamicZones 测试从named.conf中找出需要冻结的动态主区域
+func TestDynamicZones(t *testing.T) {
+	content := `
+zone "static.example" IN {
+	type master;
+	file "static.example.zone";
+};
+
+zone "ddns.example" IN {
+	type master;
+	file "ddns.example.zone";
+	allow-update { key ddns-key; };
+};
+
+zone "policy.example" IN {
+	type primary;
+	file "policy.example.zone";
+	update-policy { grant ddns-key zonesub ANY; };
+};
+
+zone "none.example" IN {
+	type master;
+	file "none.example.zone";
+	allow-update { none; };
+};
+
+zone "secondary.example" IN {
+	type slave;
+	file "secondary.example.zone";
+	masters { 192.0.2.1; };
+	allow-update { key ddns-key; };
+};
+`
+	zones := dynamicZones(content)
+	if len(zones) != 2 || zones[0].Domain != "ddns.example" || zones[1].Domain != "policy.example" {
+		t.Errorf("dynamicZones() = %+v", zones)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/secondary.go
+// 辅区域管理：主服务器配置、传送状态查询和重新传送
+
+package bind
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// 区域类型，沿用named.conf中的master/slave写法
+const (
+	ZoneTypeMaster = "master"
+	ZoneTypeSlave  = "slave"
+)
+
+var (
+	// ErrReadOnlyZone 辅区域的记录来自主服务器，不能在本地修改
+	ErrReadOnlyZone = errors.New("辅区域的记录为只读")
+	// ErrNotSecondaryZone 操作仅适用于辅区域
+	ErrNotSecondaryZone = errors.New("该操作仅适用于辅区域")
+)
+
+// primaryKeyRegex 匹配主服务器条目中的 key 子句
+var primaryKeyRegex = regexp.MustCompile(`\s+key\s+"?([^"\s;]+)"?`)
+
+// ZoneTransferStatus 区域传送状态，来自 rndc zonestatus
+type ZoneTransferStatus struct {
+	Domain      string            `json:"domain"`
+	Type        string            `json:"type"`
+	Serial      string            `json:"serial,omitempty"`
+	LastLoaded  string            `json:"last_loaded,omitempty"`
+	NextRefresh string            `json:"next_refresh,omitempty"`
+	Expires     string            `json:"expires,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Details     map[string]string `json:"details,omitempty"` // zonestatus输出的全部字段
+}
+
+// NormalizeZoneType 规范化区域类型，空值视为主区域
+func NormalizeZoneType(zoneType string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(zoneType)) {
+	case "", "master", "primary":
+		return ZoneTypeMaster, nil
+	case "slave", "secondary":
+		return ZoneTypeSlave, nil
+	}
+	return "", fmt.Errorf("不支持的区域类型: %s", zoneType)
+}
+
+// splitPrimaries 拆分分号分隔的主服务器列表，并规范化空白
+func splitPrimaries(primaries string) []string {
+	var entries []string
+	for _, part := range strings.Split(primaries, ";") {
+		if part = strings.Join(strings.Fields(part), " "); part != "" {
+			entries = append(entries, part)
+		}
+	}
+	return entries
+}
+
+// renderPrimaries 生成masters子句的内容，配置密钥时每个主服务器都使用该密钥传送
+func renderPrimaries(primaries, key string) string {
+	var sb strings.Builder
+	for _, entry := range splitPrimaries(primaries) {
+		sb.WriteString(entry)
+		if key != "" {
+			sb.WriteString(fmt.Sprintf(" key \"%s\"", key))
+		}
+		sb.WriteString("; ")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// parsePrimaries 从zone配置块中提取主服务器列表和传送密钥，兼容masters和primaries写法
+func parsePrimaries(block string) (primaries, key string) {
+	list := extractZoneClause(block, "masters")
+	if list == "" {
+		list = extractZoneClause(block, "primaries")
+	}
+	var entries []string
+	for _, entry := range splitPrimaries(list) {
+		if m := primaryKeyRegex.FindStringSubmatch(" " + entry); m != nil {
+			key = m[1]
+			entry = strings.TrimSpace(primaryKeyRegex.ReplaceAllString(" "+entry, ""))
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, "; "), key
+}
+
+// ValidateSecondaryZone 校验辅区域的主服务器、传送密钥和访问控制配置
+func ValidateSecondaryZone(zone AuthZone) error {
+	entries := splitPrimaries(zone.Primaries)
+	if len(entries) == 0 {
+		return fmt.Errorf("辅区域必须配置主服务器")
+	}
+	for _, entry := range entries {
+		fields := strings.Fields(entry)
+		if net.ParseIP(fields[0]) == nil {
+			return fmt.Errorf("主服务器地址无效: %s", entry)
+		}
+		if len(fields) == 1 {
+			continue
+		}
+		if len(fields) != 3 || fields[1] != "port" {
+			return fmt.Errorf("主服务器配置无效: %s", entry)
+		}
+		if port, err := strconv.Atoi(fields[2]); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("主服务器端口无效: %s", entry)
+		}
+	}
+
+	if key := zone.PrimaryKey; key != "" && key != "none" {
+		if _, ok := dns.IsDomainName(key); !ok || strings.ContainsAny(key, "\" ;{}") {
+			return fmt.Errorf("传送密钥名称无效: %s", key)
+		}
+	}
+	if isDynamicZone(zone) {
+		return fmt.Errorf("辅区域不支持allow-update和update-policy配置")
+	}
+	if zone.DNSSEC != nil && zone.DNSSEC.Enabled {
+		return fmt.Errorf("辅区域不支持配置DNSSEC")
+	}
+	return ValidateZoneAccessClauses(zone)
+}
+
+// createSecondaryZone 创建辅区域：只写入named.conf，zone文件由BIND从主服务器传送
+func (bm *BindManager) createSecondaryZone(zone AuthZone) error {
+	if err := ValidateSecondaryZone(zone); err != nil {
+		return err
+	}
+	if zone.PrimaryKey == "none" {
+		zone.PrimaryKey = ""
+	}
+
+	zoneFileName := fmt.Sprintf("%s.zone", zone.Domain)
+	namedConfPath := filepath.Join(bm.config.NamedConfPath, "named.conf")
+	originalNamedConf, err := os.ReadFile(namedConfPath)
+	if err != nil {
+		bm.logger.Error("读取named.conf文件失败: %v", err)
+		return fmt.Errorf("读取named.conf文件失败: %v", err)
+	}
+
+	// 在操作前创建全量备份（named.conf + 所有zone文件）
+	allZoneFiles, _ := bm.getAllZoneFiles()
+	files := append([]string{namedConfPath}, allZoneFiles...)
+	zoneJSON, _ := json.Marshal(zone)
+	backupID, err := bm.HistoryMgr.CreateBackup(OperationCreate, zone.Domain, zoneJSON, files)
+	if err != nil {
+		bm.logger.Warn("创建备份失败: %v", err)
+		backupID = 0
+	}
+
+	if err := bm.addZoneToNamedConf(namedConfPath, zone, zoneFileName); err != nil {
+		if backupID > 0 {
+			bm.HistoryMgr.DeleteBackupRecord(backupID)
+		}
+		bm.logger.Error("更新named.conf文件失败: %v", err)
+		return fmt.Errorf("更新named.conf文件失败: %v", err)
+	}
+
+	if err := bm.ValidateConfig(); err != nil {
+		os.WriteFile(namedConfPath, originalNamedConf, 0644)
+		if backupID > 0 {
+			bm.HistoryMgr.DeleteBackupRecord(backupID)
+		}
+		bm.logger.Error("验证named.conf配置失败: %v", err)
+		return fmt.Errorf("验证named.conf配置失败: %v", err)
+	}
+
+	// 刷新后BIND加载新区域并立即从主服务器传送
+	if err := bm.ReloadBind(); err != nil {
+		bm.logger.Error("刷新BIND服务器失败: %v", err)
+	}
+	return nil
+}
+
+// updateSecondaryZone 更新辅区域的named.conf配置，记录来自主服务器，请求中的记录被忽略
+// 未提供的主服务器、传送密钥、allow-query和allow-transfer保持不变，传送密钥为"none"表示移除
+func (bm *BindManager) updateSecondaryZone(zone AuthZone, existingZone *AuthZone) error {
+	if zone.Primaries == "" {
+		zone.Primaries = existingZone.Primaries
+	}
+	if zone.PrimaryKey == "" {
+		zone.PrimaryKey = existingZone.PrimaryKey
+	}
+	if zone.AllowQuery == "" {
+		zone.AllowQuery = existingZone.AllowQuery
+	}
+	if zone.AllowTransfer == "" {
+		zone.AllowTransfer = existingZone.AllowTransfer
+	}
+	if err := ValidateSecondaryZone(zone); err != nil {
+		return err
+	}
+	if zone.PrimaryKey == "none" {
+		zone.PrimaryKey = ""
+	}
+
+	if splitEqual(zone.Primaries, existingZone.Primaries) && zone.PrimaryKey == existingZone.PrimaryKey &&
+		zone.AllowQuery == existingZone.AllowQuery && zone.AllowTransfer == existingZone.AllowTransfer &&
+		zone.Comment == existingZone.Comment {
+		return nil
+	}
+
+	zoneFileName := filepath.Base(existingZone.File)
+	namedConfPath := filepath.Join(bm.config.NamedConfPath, "named.conf")
+
+	// 在操作前创建全量备份（named.conf + 所有zone文件）
+	allZoneFiles, _ := bm.getAllZoneFiles()
+	files := append([]string{namedConfPath}, allZoneFiles...)
+	zoneJSON, _ := json.Marshal(zone)
+	backupID, err := bm.HistoryMgr.CreateBackup(OperationUpdate, zone.Domain, zoneJSON, files)
+	if err != nil {
+		bm.logger.Warn("创建备份失败: %v", err)
+		backupID = 0
+	}
+
+	if err := bm.updateZoneInNamedConf(namedConfPath, zone, zoneFileName); err != nil {
+		if backupID > 0 {
+			bm.HistoryMgr.DeleteBackupRecord(backupID)
+		}
+		bm.logger.Error("更新named.conf文件失败: %v", err)
+		return fmt.Errorf("更新named.conf文件失败: %v", err)
+	}
+
+	if err := bm.ValidateConfig(); err != nil {
+		// 回滚：恢复原始named.conf
+		bm.updateZoneInNamedConf(namedConfPath, *existingZone, zoneFileName)
+		if backupID > 0 {
+			bm.HistoryMgr.DeleteBackupRecord(backupID)
+		}
+		bm.logger.Error("验证named.conf配置失败: %v", err)
+		return fmt.Errorf("验证named.conf配置失败: %v", err)
+	}
+
+	if err := bm.ReloadBind(); err != nil {
+		bm.logger.Error("刷新BIND服务器失败: %v", err)
+	}
+	return nil
+}
+
+// splitEqual 比较两个分号分隔的列表是否一致（忽略空白差异）
+func splitEqual(a, b string) bool {
+	return strings.Join(splitPrimaries(a), ";") == strings.Join(splitPrimaries(b), ";")
+}
+
+// parseZoneStatus 解析 rndc zonestatus 输出的“字段: 值”行
+func parseZoneStatus(output string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if key = strings.TrimSpace(key); key != "" {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
+
+// GetZoneTransferStatus 通过 rndc zonestatus 获取区域的加载和传送状态
+// rndc执行失败时错误信息写入Error字段，不作为接口错误返回
+func (bm *BindManager) GetZoneTransferStatus(domain string) (*ZoneTransferStatus, error) {
+	zone, err := bm.GetAuthZone(domain)
+	if err != nil {
+		return nil, ErrZoneNotFound
+	}
+
+	status := &ZoneTransferStatus{Domain: zone.Domain, Type: zone.Type}
+	output, err := bm.runRndc("zonestatus", zone.Domain)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Details = parseZoneStatus(output)
+		status.Serial = status.Details["serial"]
+		status.LastLoaded = status.Details["last loaded"]
+		status.NextRefresh = status.Details["next refresh"]
+		status.Expires = status.Details["expires"]
+	}
+
+	// 辅区域尚无zone文件说明首次传送未完成
+	if zone.Type == ZoneTypeSlave && status.Error == "" {
+		if _, err := os.Stat(filepath.Join(bm.config.ZoneFilePath, filepath.Base(zone.File))); os.IsNotExist(err) {
+			status.Error = "尚未完成从主服务器的区域传送"
+		}
+	}
+	return status, nil
+}
+
+// RetransferZone 立即从主服务器重新传送辅区域
+func (bm *BindManager) RetransferZone(domain string) error {
+	zone, err := bm.GetAuthZone(domain)
+	if err != nil {
+		return ErrZoneNotFound
+	}
+	if zone.Type != ZoneTypeSlave {
+		return ErrNotSecondaryZone
+	}
+	if _, err := bm.runRndc("retransfer", zone.Domain); err != nil {
+		return err
+	}
+	return nil
+}
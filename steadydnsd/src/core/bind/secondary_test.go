@@ -0,0 +1,138 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/secondary_test.go
+// 辅区域的配置校验、named.conf读写和zonestatus解析测试
+
+package bind
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"SteadyDNS/core/common"
+)
+
+// TestNormalizeZoneType 测试区域类型规范化
+func TestNormalizeZoneType(t *testing.T) {
+	cases := map[string]string{"": ZoneTypeMaster, "Primary": ZoneTypeMaster, "secondary": ZoneTypeSlave, "slave": ZoneTypeSlave}
+	for in, want := range cases {
+		if got, err := NormalizeZoneType(in); err != nil || got != want {
+			t.Errorf("NormalizeZoneType(%q) = %q, %v，期望 %q", in, got, err, want)
+		}
+	}
+	if _, err := NormalizeZoneType("forward"); err == nil {
+		t.Error("不支持的区域类型应返回错误")
+	}
+}
+
+// TestValidateSecondaryZone 测试辅区域配置校验
+func TestValidateSecondaryZone(t *testing.T) {
+	cases := []struct {
+		zone AuthZone
+		ok   bool
+	}{
+		{AuthZone{Primaries: "192.0.2.1; 2001:db8::1 port 5353"}, true},
+		{AuthZone{Primaries: "192.0.2.1", PrimaryKey: "xfr-key"}, true},
+		{AuthZone{}, false},
+		{AuthZone{Primaries: "ns1.example.com"}, false},
+		{AuthZone{Primaries: "192.0.2.1 port 70000"}, false},
+		{AuthZone{Primaries: "192.0.2.1 key xfr"}, false},
+		{AuthZone{Primaries: "192.0.2.1", PrimaryKey: `bad"key`}, false},
+		{AuthZone{Primaries: "192.0.2.1", AllowUpdate: "any"}, false},
+	}
+	for _, tc := range cases {
+		if err := ValidateSecondaryZone(tc.zone); (err == nil) != tc.ok {
+			t.Errorf("ValidateSecondaryZone(%+v) = %v，期望成功: %v", tc.zone, err, tc.ok)
+		}
+	}
+}
+
+// TestSecondaryZoneNamedConf 测试辅区域写入named.conf后可被读回，且记录只读
+func TestSecondaryZoneNamedConf(t *testing.T) {
+	dir := t.TempDir()
+	namedConf := filepath.Join(dir, "named.conf")
+	if err := os.WriteFile(namedConf, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bm := &BindManager{
+		config: BindConfig{NamedConfPath: dir, ZoneFilePath: dir},
+		logger: common.NewLoggerWithLevel(common.ERROR),
+	}
+
+	zone := AuthZone{
+		Domain:        "example.net",
+		Type:          ZoneTypeSlave,
+		AllowQuery:    "any",
+		AllowTransfer: "none",
+		Primaries:     "192.0.2.1; 2001:db8::1 port 5353",
+		PrimaryKey:    "xfr-key",
+		Comment:       "由主服务器传送",
+	}
+	if err := bm.addZoneToNamedConf(namedConf, zone, "example.net.zone"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(namedConf)
+	for _, want := range []string{
+		"type slave;",
+		"masterfile-format text;",
+		`masters { 192.0.2.1 key "xfr-key"; 2001:db8::1 port 5353 key "xfr-key"; };`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("named.conf缺少 %q:\n%s", want, content)
+		}
+	}
+
+	// 首次传送前没有zone文件，仍应列出
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones) != 1 {
+		t.Fatalf("期望1个区域，得到 %d", len(zones))
+	}
+	got := zones[0]
+	if got.Type != ZoneTypeSlave || got.Primaries != zone.Primaries || got.PrimaryKey != zone.PrimaryKey ||
+		got.AllowQuery != "any;" || got.AllowTransfer != "none" || got.Comment != zone.Comment {
+		t.Errorf("读回的辅区域不符合预期: %+v", got)
+	}
+
+	if _, _, err := bm.AddRecord("example.net", Record{Name: "www", Type: "A", Value: "192.0.2.10"}, ""); !errors.Is(err, ErrReadOnlyZone) {
+		t.Errorf("辅区域添加记录应返回ErrReadOnlyZone，得到 %v", err)
+	}
+}
+
+// TestParseZoneStatus 测试 rndc zonestatus 输出解析
+func TestParseZoneStatus(t *testing.T) {
+	output := `name: example.net
+type: secondary
+files: example.net.zone
+serial: 2024010101
+nodes: 12
+last loaded: Mon, 01 Jan 2024 00:00:00 GMT
+next refresh: Mon, 01 Jan 2024 01:00:00 GMT
+expires: Mon, 08 Jan 2024 00:00:00 GMT
+secure: no`
+	fields := parseZoneStatus(output)
+	if fields["serial"] != "2024010101" || fields["last loaded"] != "Mon, 01 Jan 2024 00:00:00 GMT" ||
+		fields["next refresh"] != "Mon, 01 Jan 2024 01:00:00 GMT" || fields["type"] != "secondary" {
+		t.Errorf("解析结果不符合预期: %v", fields)
+	}
+}
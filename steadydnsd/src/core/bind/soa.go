@@ -22,9 +22,7 @@ package bind
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
@@ -53,47 +51,13 @@ func (bm *BindManager) getCurrentSerial(domain string) (string, error) {
 	zoneFileName := fmt.Sprintf("%s.zone", domain)
 	zoneFilePath := filepath.Join(bm.config.ZoneFilePath, zoneFileName)
 
-	// 读取zone文件内容
-	content, err := os.ReadFile(zoneFilePath)
+	zone, err := bm.parseZoneFile(zoneFilePath, domain)
 	if err != nil {
-		return "", fmt.Errorf("读取zone文件失败: %v", err)
+		return "", err
 	}
-
-	contentStr := string(content)
-
-	// 查找SOA记录行
-	lines := strings.Split(contentStr, "\n")
-	var soaLine string
-	for _, line := range lines {
-		if strings.Contains(line, "SOA") {
-			soaLine = line
-			break
-		}
-	}
-
-	if soaLine == "" {
+	if zone.SOA.Serial == "" {
 		return "", fmt.Errorf("未找到SOA记录")
 	}
 
-	// 提取序列号
-	// SOA记录格式：@ IN SOA ns1.example.com. admin.example.com. (2026012201 3600 1800 604800 86400)
-	fields := strings.Fields(soaLine)
-	if len(fields) < 6 {
-		return "", fmt.Errorf("SOA记录格式不正确")
-	}
-
-	// 序列号通常是SOA记录括号后的第一个值
-	var serial string
-	for i, field := range fields {
-		if field == "(" && i+1 < len(fields) {
-			serial = fields[i+1]
-			break
-		}
-	}
-
-	if serial == "" {
-		return "", fmt.Errorf("未找到SOA序列号")
-	}
-
-	return serial, nil
+	return zone.SOA.Serial, nil
 }
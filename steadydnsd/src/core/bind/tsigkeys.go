@@ -0,0 +1,367 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/tsigkeys.go
+// TSIG密钥管理：密钥存储在数据库中，渲染为named.conf引用的key配置文件
+
+package bind
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/bind/namedconf"
+	"SteadyDNS/core/database"
+
+	"github.com/miekg/dns"
+)
+
+// TSIGKeysFileName 由SteadyDNS维护的key配置文件名，位于named.conf同目录并通过include引入
+const TSIGKeysFileName = "steadydns-keys.conf"
+
+// TSIGAlgorithms 支持的TSIG算法及生成密钥时使用的长度（字节，与摘要长度一致）
+var TSIGAlgorithms = map[string]int{
+	"hmac-sha1":   20,
+	"hmac-sha224": 28,
+	"hmac-sha256": 32,
+	"hmac-sha384": 48,
+	"hmac-sha512": 64,
+}
+
+// DefaultTSIGAlgorithm 未指定算法时使用的TSIG算法
+const DefaultTSIGAlgorithm = "hmac-sha256"
+
+// ErrTSIGKeyInUse 密钥仍被区域引用，不能删除
+var ErrTSIGKeyInUse = errors.New("TSIG密钥仍被区域引用")
+
+// keyRefRegex 匹配地址匹配列表中的 key <名称> 引用
+var keyRefRegex = regexp.MustCompile(`(?i)(?:^|[\s;{!])key\s+"?([^\s";]+)"?`)
+
+// GenerateTSIGSecret 按算法的摘要长度生成随机密钥，返回base64编码
+func GenerateTSIGSecret(algorithm string) (string, error) {
+	size, ok := TSIGAlgorithms[algorithm]
+	if !ok {
+		return "", fmt.Errorf("不支持的TSIG算法: %s", algorithm)
+	}
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机密钥失败: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// normalizeTSIGKeyName 规范化密钥名称：小写、去掉末尾的点
+func normalizeTSIGKeyName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// validateTSIGKey 校验密钥名称、算法和密钥内容
+func validateTSIGKey(name, algorithm, secret string) error {
+	if name == "" {
+		return fmt.Errorf("TSIG密钥名称不能为空")
+	}
+	if _, ok := dns.IsDomainName(name); !ok || strings.ContainsAny(name, "\" ;{}") {
+		return fmt.Errorf("TSIG密钥名称无效: %s", name)
+	}
+	if _, ok := TSIGAlgorithms[algorithm]; !ok {
+		return fmt.Errorf("不支持的TSIG算法: %s", algorithm)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(decoded) == 0 {
+		return fmt.Errorf("TSIG密钥内容必须为非空的base64编码")
+	}
+	return nil
+}
+
+// RenderTSIGKeys 使用namedconf生成器将密钥渲染为named.conf的key配置
+func RenderTSIGKeys(keys []database.TSIGKey) (string, error) {
+	root := &namedconf.ConfigElement{
+		Type:     "root",
+		Comments: []string{"由SteadyDNS管理，请勿手动修改"},
+	}
+	for _, key := range keys {
+		root.ChildElements = append(root.ChildElements, namedconf.ConfigElement{
+			Name:  "key",
+			Type:  "block",
+			Value: key.Name,
+			ChildElements: []namedconf.ConfigElement{
+				{Name: "algorithm", Type: "simple", Value: key.Algorithm},
+				{Name: "secret", Type: "simple", Value: key.Secret},
+			},
+		})
+	}
+	return namedconf.NewGenerator().Generate(root)
+}
+
+// zoneKeyRefs 提取区域allow-transfer、allow-update、update-policy和辅区域传送密钥中引用的密钥名称
+func zoneKeyRefs(zone AuthZone) []string {
+	var refs []string
+	for _, list := range []string{zone.AllowTransfer, zone.AllowUpdate} {
+		for _, m := range keyRefRegex.FindAllStringSubmatch(list, -1) {
+			refs = append(refs, normalizeTSIGKeyName(m[1]))
+		}
+	}
+	if zone.PrimaryKey != "" {
+		refs = append(refs, normalizeTSIGKeyName(zone.PrimaryKey))
+	}
+	// update-policy规则的第二个字段为密钥名称
+	for _, rule := range strings.Split(zone.UpdatePolicy, ";") {
+		fields := strings.Fields(rule)
+		if len(fields) >= 2 && (fields[0] == "grant" || fields[0] == "deny") {
+			refs = append(refs, normalizeTSIGKeyName(strings.Trim(fields[1], `"`)))
+		}
+	}
+	return refs
+}
+
+// tsigKeysFilePath 返回受管key配置文件路径
+func (bm *BindManager) tsigKeysFilePath() string {
+	return filepath.Join(bm.config.NamedConfPath, TSIGKeysFileName)
+}
+
+// ensureManagedInclude 确保named.conf包含受管配置文件（key、dnssec-policy）的include语句
+// 受管配置需在被zone引用之前定义，因此include插入到文件开头的注释之后
+func (bm *BindManager) ensureManagedInclude(namedConfPath, includePath string) error {
+	content, err := os.ReadFile(namedConfPath)
+	if err != nil {
+		return fmt.Errorf("读取named.conf文件失败: %v", err)
+	}
+	includeRegex := regexp.MustCompile(fmt.Sprintf(`include\s+"(%s|%s)"\s*;`,
+		regexp.QuoteMeta(includePath), regexp.QuoteMeta(filepath.Base(includePath))))
+	if includeRegex.Match(content) {
+		return nil
+	}
+
+	lines := strings.Split(string(content), "\n")
+	insertAt := 0
+	for insertAt < len(lines) {
+		line := strings.TrimSpace(lines[insertAt])
+		if line != "" && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "#") {
+			break
+		}
+		insertAt++
+	}
+	includeLine := fmt.Sprintf("include \"%s\";", includePath)
+	newLines := append([]string{}, lines[:insertAt]...)
+	newLines = append(newLines, includeLine, "")
+	newLines = append(newLines, lines[insertAt:]...)
+
+	if err := os.WriteFile(namedConfPath, []byte(strings.Join(newLines, "\n")), 0644); err != nil {
+		return fmt.Errorf("写入named.conf文件失败: %v", err)
+	}
+	return nil
+}
+
+// syncTSIGKeys 将数据库中的密钥写入受管key文件并校验BIND配置，校验失败时恢复原文件
+func (bm *BindManager) syncTSIGKeys() error {
+	keys, err := database.GetTSIGKeys()
+	if err != nil {
+		return fmt.Errorf("读取TSIG密钥失败: %v", err)
+	}
+	content, err := RenderTSIGKeys(keys)
+	if err != nil {
+		return fmt.Errorf("生成key配置失败: %v", err)
+	}
+
+	keysPath := bm.tsigKeysFilePath()
+	namedConfPath := filepath.Join(bm.config.NamedConfPath, "named.conf")
+	originalKeys, keysErr := os.ReadFile(keysPath)
+	originalNamedConf, err := os.ReadFile(namedConfPath)
+	if err != nil {
+		return fmt.Errorf("读取named.conf文件失败: %v", err)
+	}
+
+	restore := func() {
+		if keysErr == nil {
+			os.WriteFile(keysPath, originalKeys, 0640)
+		} else {
+			os.Remove(keysPath)
+		}
+		os.WriteFile(namedConfPath, originalNamedConf, 0644)
+	}
+
+	// 密钥文件包含明文密钥，仅允许BIND用户读取
+	if err := os.WriteFile(keysPath, []byte(content), 0640); err != nil {
+		return fmt.Errorf("写入key配置文件失败: %v", err)
+	}
+	bm.chownToBind(keysPath)
+
+	if err := bm.ensureManagedInclude(namedConfPath, keysPath); err != nil {
+		restore()
+		return err
+	}
+
+	if err := bm.ValidateConfig(); err != nil {
+		restore()
+		bm.logger.Error("验证named.conf配置失败: %v", err)
+		return fmt.Errorf("验证named.conf配置失败: %v", err)
+	}
+
+	if err := bm.ReloadBind(); err != nil {
+		bm.logger.Error("刷新BIND服务器失败: %v", err)
+		// 不回滚，因为配置本身是有效的，只是刷新失败
+	}
+	return nil
+}
+
+// chownToBind 将文件所有者修改为BIND用户和组，失败时仅记录警告
+func (bm *BindManager) chownToBind(path string) {
+	if bm.config.BindUser == "" || bm.config.BindGroup == "" {
+		return
+	}
+	bindUser, err := user.Lookup(bm.config.BindUser)
+	if err != nil {
+		bm.logger.Warn("查找BIND用户失败: %v，跳过修改文件所有者", err)
+		return
+	}
+	bindGroup, err := user.LookupGroup(bm.config.BindGroup)
+	if err != nil {
+		bm.logger.Warn("查找BIND组失败: %v，跳过修改文件组", err)
+		return
+	}
+	uid, uidErr := strconv.Atoi(bindUser.Uid)
+	gid, gidErr := strconv.Atoi(bindGroup.Gid)
+	if uidErr != nil || gidErr != nil {
+		bm.logger.Warn("解析UID/GID失败，跳过修改文件所有者")
+		return
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		bm.logger.Warn("修改文件所有者和组失败: %v", err)
+	}
+}
+
+// ListTSIGKeys 获取所有TSIG密钥（不含密钥内容）
+func (bm *BindManager) ListTSIGKeys() ([]database.TSIGKey, error) {
+	keys, err := database.GetTSIGKeys()
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		keys[i].Secret = ""
+	}
+	return keys, nil
+}
+
+// GetTSIGKey 获取单个TSIG密钥（含密钥内容，用于配置对端）
+func (bm *BindManager) GetTSIGKey(name string) (*database.TSIGKey, error) {
+	return database.GetTSIGKeyByName(normalizeTSIGKeyName(name))
+}
+
+// CreateTSIGKey 创建TSIG密钥，secret为空时自动生成
+func (bm *BindManager) CreateTSIGKey(name, algorithm, secret string) (*database.TSIGKey, error) {
+	zoneFileMu.Lock()
+	defer zoneFileMu.Unlock()
+
+	name = normalizeTSIGKeyName(name)
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	if algorithm == "" {
+		algorithm = DefaultTSIGAlgorithm
+	}
+	if secret == "" {
+		generated, err := GenerateTSIGSecret(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+	if err := validateTSIGKey(name, algorithm, secret); err != nil {
+		return nil, err
+	}
+
+	key := &database.TSIGKey{Name: name, Algorithm: algorithm, Secret: secret}
+	if err := database.CreateTSIGKey(key); err != nil {
+		return nil, err
+	}
+	if err := bm.syncTSIGKeys(); err != nil {
+		database.DeleteTSIGKey(name)
+		return nil, err
+	}
+	return key, nil
+}
+
+// RotateTSIGKey 轮换TSIG密钥内容，算法保持不变，secret为空时自动生成
+func (bm *BindManager) RotateTSIGKey(name, secret string) (*database.TSIGKey, error) {
+	zoneFileMu.Lock()
+	defer zoneFileMu.Unlock()
+
+	key, err := database.GetTSIGKeyByName(normalizeTSIGKeyName(name))
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		if secret, err = GenerateTSIGSecret(key.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateTSIGKey(key.Name, key.Algorithm, secret); err != nil {
+		return nil, err
+	}
+
+	previous := *key
+	now := time.Now()
+	key.Secret = secret
+	key.RotatedAt = &now
+	if err := database.UpdateTSIGKey(key); err != nil {
+		return nil, err
+	}
+	if err := bm.syncTSIGKeys(); err != nil {
+		database.UpdateTSIGKey(&previous)
+		return nil, err
+	}
+	return key, nil
+}
+
+// DeleteTSIGKey 删除TSIG密钥，仍被区域引用时拒绝删除
+func (bm *BindManager) DeleteTSIGKey(name string) error {
+	zoneFileMu.Lock()
+	defer zoneFileMu.Unlock()
+
+	key, err := database.GetTSIGKeyByName(normalizeTSIGKeyName(name))
+	if err != nil {
+		return err
+	}
+
+	zones, err := bm.GetAuthZones()
+	if err != nil {
+		return fmt.Errorf("获取权威域失败: %v", err)
+	}
+	for _, zone := range zones {
+		for _, ref := range zoneKeyRefs(zone) {
+			if ref == key.Name {
+				return fmt.Errorf("%w: %s", ErrTSIGKeyInUse, zone.Domain)
+			}
+		}
+	}
+
+	if err := database.DeleteTSIGKey(key.Name); err != nil {
+		return err
+	}
+	if err := bm.syncTSIGKeys(); err != nil {
+		database.CreateTSIGKey(key)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/tsigkeys_test.go
+// TSIG密钥生成、校验、key配置渲染和区域密钥引用测试
+
+package bind
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"SteadyDNS/core/bind/namedconf"
+	"SteadyDNS/core/database"
+)
+
+// TestGenerateTSIGSecret 测试按算法长度生成密钥
+func TestGenerateTSIGSecret(t *testing.T) {
+	for alg, size := range TSIGAlgorithms {
+		secret, err := GenerateTSIGSecret(alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil || len(decoded) != size {
+			t.Errorf("%s: 密钥长度 %d，期望 %d", alg, len(decoded), size)
+		}
+	}
+	if _, err := GenerateTSIGSecret("hmac-md5"); err == nil {
+		t.Error("不支持的算法应返回错误")
+	}
+}
+
+// TestValidateTSIGKey 测试密钥名称、算法和内容校验
+func TestValidateTSIGKey(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	cases := []struct {
+		name, alg, secret string
+		ok                bool
+	}{
+		{"xfr-key", "hmac-sha256", secret, true},
+		{"ddns.example.com", "hmac-sha512", secret, true},
+		{"", "hmac-sha256", secret, false},
+		{"bad key", "hmac-sha256", secret, false},
+		{`bad"key`, "hmac-sha256", secret, false},
+		{"xfr-key", "hmac-md5", secret, false},
+		{"xfr-key", "hmac-sha256", "not base64!", false},
+		{"xfr-key", "hmac-sha256", "", false},
+	}
+	for _, tc := range cases {
+		err := validateTSIGKey(tc.name, tc.alg, tc.secret)
+		if (err == nil) != tc.ok {
+			t.Errorf("validateTSIGKey(%q, %q, %q) = %v，期望成功: %v", tc.name, tc.alg, tc.secret, err, tc.ok)
+		}
+	}
+}
+
+// TestRenderTSIGKeys 测试key配置渲染结果可被namedconf解析器读回
+func TestRenderTSIGKeys(t *testing.T) {
+	keys := []database.TSIGKey{
+		{Name: "xfr-key", Algorithm: "hmac-sha256", Secret: "c2VjcmV0LXhmcg=="},
+		{Name: "ddns.example.com", Algorithm: "hmac-sha512", Secret: "c2VjcmV0LWRkbnM="},
+	}
+	content, err := RenderTSIGKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(content, `key "xfr-key" {`) || !strings.Contains(content, `secret "c2VjcmV0LXhmcg==";`) {
+		t.Fatalf("渲染结果不符合预期:\n%s", content)
+	}
+
+	path := filepath.Join(t.TempDir(), TSIGKeysFileName)
+	if err := os.WriteFile(path, []byte(content), 0640); err != nil {
+		t.Fatal(err)
+	}
+	root, err := namedconf.NewParser(path).Parse()
+	if err != nil {
+		t.Fatalf("解析渲染结果失败: %v", err)
+	}
+	var blocks int
+	for _, el := range root.ChildElements {
+		if el.Type != "block" {
+			continue
+		}
+		blocks++
+		if len(el.ChildElements) != 2 || el.ChildElements[0].Name != "algorithm" || el.ChildElements[1].Name != "secret" {
+			t.Errorf("key块内容不符合预期: %+v", el)
+		}
+	}
+	if blocks != len(keys) {
+		t.Errorf("解析得到 %d 个key块，期望 %d", blocks, len(keys))
+	}
+}
+
+// TestZoneKeyRefs 测试从区域配置中提取密钥引用
+func TestZoneKeyRefs(t *testing.T) {
+	zone := AuthZone{
+		AllowTransfer: "key xfr-key; 192.0.2.1",
+		AllowUpdate:   "!key Old-Key.",
+		UpdatePolicy:  "grant ddns.example.com subdomain example.com A AAAA; deny xfr-key name www.example.com",
+	}
+	want := []string{"xfr-key", "old-key", "ddns.example.com", "xfr-key"}
+	if got := zoneKeyRefs(zone); !reflect.DeepEqual(got, want) {
+		t.Errorf("zoneKeyRefs = %v，期望 %v", got, want)
+	}
+	if refs := zoneKeyRefs(AuthZone{AllowTransfer: "any", UpdatePolicy: "local"}); len(refs) != 0 {
+		t.Errorf("未引用密钥时应返回空，得到 %v", refs)
+	}
+}
+
+// TestZoneAccessClauses 测试zone子句的校验、写入named.conf和读回
+func TestZoneAccessClauses(t *testing.T) {
+	if err := ValidateZoneAccessClauses(AuthZone{AllowUpdate: "key a", UpdatePolicy: "grant a zonesub ANY"}); err == nil {
+		t.Error("allow-update与update-policy同时配置应返回错误")
+	}
+	if err := ValidateZoneAccessClauses(AuthZone{AllowUpdate: "none", UpdatePolicy: "grant a zonesub ANY"}); err != nil {
+		t.Errorf("allow-update为none时应允许update-policy: %v", err)
+	}
+	if err := ValidateZoneAccessClauses(AuthZone{AllowTransfer: "any; }; zone \"x\" {"}); err == nil {
+		t.Error("包含大括号的配置应返回错误")
+	}
+	if err := ValidateZoneAccessClauses(AuthZone{UpdatePolicy: "allow a zonesub ANY"}); err == nil {
+		t.Error("无效的update-policy规则应返回错误")
+	}
+
+	path := filepath.Join(t.TempDir(), "named.conf")
+	if err := os.WriteFile(path, []byte("options {\n    directory \"/var/named\";\n};\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bm := &BindManager{}
+	zone := AuthZone{
+		Domain:        "example.com",
+		AllowQuery:    "any",
+		AllowTransfer: "key xfr-key; 192.0.2.0/24;",
+		UpdatePolicy:  "grant ddns-key zonesub A AAAA; grant ddns-key name host.example.com TXT",
+	}
+	if err := bm.addZoneToNamedConf(path, zone, "example.com.zone"); err != nil {
+		t.Fatal(err)
+	}
+	other := AuthZone{Domain: "example.org", AllowQuery: "any", UpdatePolicy: "local"}
+	if err := bm.addZoneToNamedConf(path, other, "example.org.zone"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(path)
+
+	start := strings.Index(string(content), `zone "example.com"`)
+	block := extractZoneBlock(string(content), start)
+	if got := extractZoneClause(block, "allow-transfer"); got != "key xfr-key; 192.0.2.0/24" {
+		t.Errorf("allow-transfer = %q", got)
+	}
+	if got := extractZoneClause(block, "allow-update"); got != "" {
+		t.Errorf("allow-update = %q，期望为空", got)
+	}
+	if got := extractZoneClause(block, "update-policy"); got != "grant ddns-key zonesub A AAAA; grant ddns-key name host.example.com TXT" {
+		t.Errorf("update-policy = %q", got)
+	}
+	if strings.Contains(block, "example.org") {
+		t.Errorf("zone块提取越界:\n%s", block)
+	}
+
+	start = strings.Index(string(content), `zone "example.org"`)
+	if got := extractZoneClause(extractZoneBlock(string(content), start), "update-policy"); got != "local" {
+		t.Errorf("update-policy = %q，期望 local", got)
+	}
+}
+
+// TestEnsureTSIGKeysInclude 测试include语句插入到文件开头注释之后且不重复添加
+func TestEnsureTSIGKeysInclude(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "named.conf")
+	if err := os.WriteFile(path, []byte("// named.conf\n\noptions {\n};\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bm := &BindManager{config: BindConfig{NamedConfPath: dir}}
+	for i := 0; i < 2; i++ {
+		if err := bm.ensureManagedInclude(path, bm.tsigKeysFilePath()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	content, _ := os.ReadFile(path)
+	include := `include "` + filepath.Join(dir, TSIGKeysFileName) + `";`
+	if strings.Count(string(content), include) != 1 {
+		t.Fatalf("include语句数量不为1:\n%s", content)
+	}
+	if !strings.HasPrefix(string(content), "// named.conf\n\n"+include+"\n") {
+		t.Errorf("include语句位置不符合预期:\n%s", content)
+	}
+}
@@ -20,47 +20,543 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 package bind
 
-import "fmt"
-
-// CheckCNAMEConflicts 检查CNAME冲突
-// 1. 同一名称不能同时存在CNAME和其他类型的记录
-// 2. NS记录不能指向CNAME
-// 3. MX记录不能指向CNAME
-// 4. 警告用户不要在裸域使用CNAME记录
-func CheckCNAMEConflicts(zone AuthZone) error {
-	// 创建一个映射，用于记录已经存在的非CNAME记录名称
-	nonCNAMERecords := make(map[string]bool)
-	cnameRecords := make([]Record, 0)
-	
-	// 遍历所有记录，收集信息
-	for _, record := range zone.Records {
-		if record.Type == "CNAME" {
-			// 收集CNAME记录，稍后处理
-			cnameRecords = append(cnameRecords, record)
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// 内置检查规则ID，可在配置 [BIND] ZONE_LINT_DISABLED_RULES 中禁用
+const (
+	LintRuleRecordSyntax  = "record-syntax"    // 记录无法解析
+	LintRuleOutOfZone     = "out-of-zone"      // 记录不在区域内或被子域委派遮蔽
+	LintRuleCNAMEConflict = "cname-conflict"   // CNAME与其他记录共存或位于区域顶点
+	LintRuleTargetCNAME   = "target-cname"     // MX/NS/SRV目标指向CNAME
+	LintRuleMissingGlue   = "missing-glue"     // 区域内NS目标缺少地址记录
+	LintRuleDuplicate     = "duplicate-record" // 重复记录
+	LintRuleTTLMismatch   = "ttl-mismatch"     // 同一RRset的TTL不一致
+	LintRuleSPFSyntax     = "spf-syntax"       // SPF语法
+	LintRuleDMARCSyntax   = "dmarc-syntax"     // DMARC语法
+	LintRuleCAASyntax     = "caa-syntax"       // CAA语法
+)
+
+// spfMaxLookups SPF检查允许的最多DNS查询次数（RFC 7208 4.6.4）
+const spfMaxLookups = 10
+
+// defaultLintRules 内置的区域检查规则
+func defaultLintRules() []LintRule {
+	return []LintRule{
+		{ID: LintRuleRecordSyntax, Severity: LintSeverityError, Description: "记录必须能被解析为有效的资源记录", Check: lintRecordSyntax},
+		{ID: LintRuleOutOfZone, Severity: LintSeverityError, Description: "记录必须位于区域内，且不能被子域委派遮蔽", Check: lintOutOfZone},
+		{ID: LintRuleCNAMEConflict, Severity: LintSeverityError, Description: "CNAME不能与其他记录共存，也不能位于区域顶点", Check: lintCNAMEConflict},
+		{ID: LintRuleTargetCNAME, Severity: LintSeverityWarning, Description: "MX、NS、SRV记录的目标不能是CNAME", Check: lintTargetCNAME},
+		{ID: LintRuleMissingGlue, Severity: LintSeverityError, Description: "位于区域内的NS目标必须有A或AAAA记录", Check: lintMissingGlue},
+		{ID: LintRuleDuplicate, Severity: LintSeverityWarning, Description: "同一名称下不应存在内容相同的记录", Check: lintDuplicate},
+		{ID: LintRuleTTLMismatch, Severity: LintSeverityWarning, Description: "同一RRset中的记录应使用相同的TTL", Check: lintTTLMismatch},
+		{ID: LintRuleSPFSyntax, Severity: LintSeverityWarning, Description: "v=spf1 TXT记录的SPF语法", Check: lintSPF},
+		{ID: LintRuleDMARCSyntax, Severity: LintSeverityWarning, Description: "_dmarc TXT记录的DMARC语法", Check: lintDMARC},
+		{ID: LintRuleCAASyntax, Severity: LintSeverityWarning, Description: "CAA记录的标签和属性值语法", Check: lintCAA},
+	}
+}
+
+// lintRecordSyntax 报告无法解析的记录，其余规则会跳过这些记录
+func lintRecordSyntax(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	for _, r := range lz.Records {
+		if r.RR == nil {
+			findings = append(findings, r.finding("", "%s", r.ParseError))
+		}
+	}
+	return findings
+}
+
+// lintOutOfZone 检查区域外数据和被子域委派遮蔽的数据
+func lintOutOfZone(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	for _, r := range lz.Records {
+		if r.RR == nil {
+			continue
+		}
+		if !lz.InZone(r.Owner) {
+			findings = append(findings, r.finding("", "记录不在区域 %s 内，BIND加载时会忽略", lz.Apex))
+			continue
+		}
+		cut := lz.Delegation(r.Owner)
+		if cut == "" {
+			continue
+		}
+		rrtype := r.RR.Header().Rrtype
+		switch {
+		case r.Owner == cut && (rrtype == dns.TypeNS || rrtype == dns.TypeDS):
+		case r.Owner != cut && (rrtype == dns.TypeA || rrtype == dns.TypeAAAA):
+			// 委派下的地址记录作为胶水记录
+		default:
+			findings = append(findings, r.finding(LintSeverityWarning, "记录位于子域委派 %s 之下，不会被权威应答", cut))
+		}
+	}
+	return findings
+}
+
+// lintCNAMEConflict 检查CNAME与其他记录共存、多个CNAME和区域顶点CNAME
+func lintCNAMEConflict(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	seen := make(map[string]bool)
+	for _, r := range lz.Records {
+		if r.RR == nil || r.RR.Header().Rrtype != dns.TypeCNAME || seen[r.Owner] {
+			continue
+		}
+		seen[r.Owner] = true
+
+		all := lz.Lookup(r.Owner, dns.TypeNone)
+		cnames := lz.Lookup(r.Owner, dns.TypeCNAME)
+		switch {
+		case r.Owner == lz.Apex:
+			findings = append(findings, r.finding("", "区域顶点不能使用CNAME记录，会与SOA和NS记录冲突"))
+		case len(all) > len(cnames):
+			findings = append(findings, r.finding("", "记录名称冲突: %s 同时存在其他记录和 CNAME 记录", r.Name))
+		case len(cnames) > 1:
+			findings = append(findings, r.finding("", "同一名称只能有一条CNAME记录，实际为 %d 条", len(cnames)))
+		}
+	}
+	return findings
+}
+
+// recordTarget 返回MX、NS、SRV记录指向的主机名
+func recordTarget(rr dns.RR) string {
+	switch v := rr.(type) {
+	case *dns.MX:
+		return v.Mx
+	case *dns.NS:
+		return v.Ns
+	case *dns.SRV:
+		return v.Target
+	}
+	return ""
+}
+
+// lintTargetCNAME 检查MX、NS、SRV记录的目标是否为区域内的CNAME（RFC 2181 10.3、RFC 2782）
+func lintTargetCNAME(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	for _, r := range lz.Records {
+		if r.RR == nil {
+			continue
+		}
+		target := recordTarget(r.RR)
+		if target == "" || target == "." || len(lz.Lookup(target, dns.TypeCNAME)) == 0 {
+			continue
+		}
+		severity := LintSeverityWarning
+		if r.RR.Header().Rrtype == dns.TypeNS {
+			// BIND拒绝加载NS指向CNAME的区域
+			severity = LintSeverityError
+		}
+		findings = append(findings, r.finding(severity, "目标 %s 是CNAME记录，应指向主机的规范名称", target))
+	}
+	return findings
+}
+
+// lintMissingGlue 检查区域内的NS目标（包括子域委派需要的胶水）是否有地址记录
+func lintMissingGlue(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	for _, r := range lz.Records {
+		if r.RR == nil || r.RR.Header().Rrtype != dns.TypeNS || !lz.InZone(r.Owner) {
+			continue
+		}
+		target := recordTarget(r.RR)
+		if !lz.InZone(target) || len(lz.Lookup(target, dns.TypeCNAME)) > 0 {
+			continue
+		}
+		if len(lz.Lookup(target, dns.TypeA)) == 0 && len(lz.Lookup(target, dns.TypeAAAA)) == 0 {
+			findings = append(findings, r.finding("", "NS目标 %s 位于区域内，但没有A或AAAA记录", target))
+		}
+	}
+	return findings
+}
+
+// lintDuplicate 检查同一名称下内容相同的记录
+func lintDuplicate(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	for i, r := range lz.Records {
+		if r.RR == nil {
+			continue
+		}
+		for _, j := range lz.byOwner[r.Owner] {
+			if j >= i {
+				break
+			}
+			if prev := lz.Records[j]; prev.RR != nil && dns.IsDuplicate(prev.RR, r.RR) {
+				findings = append(findings, r.finding("", "与同名的另一条记录内容相同，BIND只会保留一条"))
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// lintTTLMismatch 检查同一RRset中TTL不一致的记录，BIND会统一使用第一条记录的TTL
+func lintTTLMismatch(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	seen := make(map[string]bool)
+	for _, r := range lz.Records {
+		if r.RR == nil {
+			continue
+		}
+		rrtype := r.RR.Header().Rrtype
+		key := r.Owner + "/" + dns.TypeToString[rrtype]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var ttls []string
+		values := make(map[int]bool)
+		for _, other := range lz.Lookup(r.Owner, rrtype) {
+			if !values[other.TTLSeconds] {
+				values[other.TTLSeconds] = true
+				ttls = append(ttls, ttlLabel(other.TTLSeconds))
+			}
+		}
+		if len(ttls) > 1 {
+			findings = append(findings, r.finding("", "RRset中的TTL不一致（%s），BIND将统一使用 %s", strings.Join(ttls, ", "), ttls[0]))
+		}
+	}
+	return findings
+}
+
+// ttlLabel 返回TTL的展示形式，0表示使用区域默认值
+func ttlLabel(ttl int) string {
+	if ttl <= 0 {
+		return "默认TTL"
+	}
+	return strconv.Itoa(ttl)
+}
+
+// txtText 返回TXT记录拼接后的文本，非TXT记录返回false
+func txtText(r LintRecord) (string, bool) {
+	if r.RR == nil {
+		return "", false
+	}
+	txt, ok := r.RR.(*dns.TXT)
+	if !ok {
+		return "", false
+	}
+	return strings.Join(txt.Txt, ""), true
+}
+
+// isSPF 判断文本是否为SPF记录
+func isSPF(text string) bool {
+	lower := strings.ToLower(text)
+	return lower == "v=spf1" || strings.HasPrefix(lower, "v=spf1 ")
+}
+
+// lintSPF 检查SPF记录语法、同一名称下的多条SPF和DNS查询次数
+func lintSPF(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	counts := make(map[string]int)
+	for _, r := range lz.Records {
+		text, ok := txtText(r)
+		if !ok || !isSPF(text) {
+			continue
+		}
+		counts[r.Owner]++
+		if counts[r.Owner] == 2 {
+			findings = append(findings, r.finding("", "同一名称存在多条SPF记录，接收方会返回permerror"))
+		}
+		for _, problem := range checkSPF(text) {
+			findings = append(findings, r.finding("", "%s", problem))
+		}
+	}
+	return findings
+}
+
+// spfMechanisms SPF机制及其是否产生DNS查询
+var spfMechanisms = map[string]bool{
+	"all":     false,
+	"include": true,
+	"a":       true,
+	"mx":      true,
+	"ptr":     true,
+	"ip4":     false,
+	"ip6":     false,
+	"exists":  true,
+}
+
+// checkSPF 按RFC 7208检查SPF记录，返回发现的问题
+func checkSPF(text string) []string {
+	var problems []string
+	terms := strings.Fields(text)[1:]
+	lookups := 0
+	modifiers := make(map[string]bool)
+	for i, term := range terms {
+		lower := strings.ToLower(term)
+
+		// 修饰符 name=value
+		if eq := strings.IndexByte(lower, '='); eq > 0 && !strings.ContainsAny(lower[:eq], ":/") {
+			name, value := lower[:eq], term[eq+1:]
+			if name != "redirect" && name != "exp" {
+				continue
+			}
+			if modifiers[name] {
+				problems = append(problems, fmt.Sprintf("修饰符 %s 只能出现一次", name))
+			}
+			modifiers[name] = true
+			if !validSPFDomain(value) {
+				problems = append(problems, fmt.Sprintf("修饰符 %s 的域名无效: %s", name, value))
+			}
+			if name == "redirect" {
+				lookups++
+			}
+			continue
+		}
+
+		mech := strings.TrimLeft(lower, "+-~?")
+		if len(lower)-len(mech) > 1 {
+			problems = append(problems, fmt.Sprintf("无效的限定符: %s", term))
+			continue
+		}
+		name, arg, hasArg := mech, "", false
+		if k := strings.IndexAny(mech, ":/"); k >= 0 {
+			name, arg, hasArg = mech[:k], mech[k:], true
+		}
+		lookup, known := spfMechanisms[name]
+		if !known {
+			problems = append(problems, fmt.Sprintf("未知的SPF机制: %s", term))
+			continue
+		}
+		if lookup {
+			lookups++
+		}
+
+		switch name {
+		case "all":
+			if hasArg {
+				problems = append(problems, "all机制不能带参数")
+			}
+			if i != len(terms)-1 {
+				problems = append(problems, "all之后的项不会生效")
+			}
+		case "include", "exists":
+			if !strings.HasPrefix(arg, ":") || !validSPFDomain(arg[1:]) {
+				problems = append(problems, fmt.Sprintf("%s机制需要有效的域名: %s", name, term))
+			}
+		case "ip4", "ip6":
+			if !strings.HasPrefix(arg, ":") || !validSPFNetwork(arg[1:], name == "ip4") {
+				problems = append(problems, fmt.Sprintf("%s机制的地址无效: %s", name, term))
+			}
+		case "a", "mx", "ptr":
+			if name == "ptr" {
+				problems = append(problems, "不建议使用ptr机制（RFC 7208 5.5）")
+			}
+			if hasArg && !validSPFHostArg(arg, name != "ptr") {
+				problems = append(problems, fmt.Sprintf("%s机制的参数无效: %s", name, term))
+			}
+		}
+	}
+	if lookups > spfMaxLookups {
+		problems = append(problems, fmt.Sprintf("需要 %d 次DNS查询，超过了 %d 次的限制", lookups, spfMaxLookups))
+	}
+	return problems
+}
+
+// validSPFDomain 检查SPF中的域名，包含宏时不做检查
+func validSPFDomain(s string) bool {
+	if s == "" {
+		return false
+	}
+	if strings.Contains(s, "%") {
+		return true
+	}
+	_, ok := dns.IsDomainName(s)
+	return ok
+}
+
+// validSPFNetwork 检查ip4/ip6机制的地址或CIDR
+func validSPFNetwork(s string, v4 bool) bool {
+	ip := net.ParseIP(s)
+	if strings.Contains(s, "/") {
+		var err error
+		if ip, _, err = net.ParseCIDR(s); err != nil {
+			return false
+		}
+	}
+	return ip != nil && (ip.To4() != nil) == v4
+}
+
+// validSPFHostArg 检查a、mx、ptr机制的 :域名 和 /前缀长度（//前缀长度用于IPv6）参数
+func validSPFHostArg(arg string, cidr bool) bool {
+	if strings.HasPrefix(arg, ":") {
+		domain := arg[1:]
+		if k := strings.IndexByte(domain, '/'); k >= 0 {
+			domain, arg = domain[:k], domain[k:]
 		} else {
-			// 记录非CNAME记录名称
-			nonCNAMERecords[record.Name] = true
-		}
-	}
-	
-	// 检查CNAME记录是否与其他记录冲突
-	for _, cname := range cnameRecords {
-		if nonCNAMERecords[cname.Name] {
-			return fmt.Errorf("记录名称冲突: %s 同时存在其他记录和 CNAME 记录", cname.Name)
-		}
-		
-		// 警告：不要在裸域使用CNAME记录
-		if cname.Name == "@" || cname.Name == zone.Domain {
-			return fmt.Errorf("警告：不建议在裸域使用CNAME记录，这可能导致其他记录失效")
-		}
-	}
-	
-	// 检查NS记录是否指向CNAME
-	// 这里简化处理，实际应该检查指向的域名是否为CNAME
-	// 但由于我们没有完整的DNS解析功能，这里只做简单的警告
-	
-	// 检查MX记录是否指向CNAME
-	// 同样简化处理
-	
-	return nil
+			arg = ""
+		}
+		if !validSPFDomain(domain) {
+			return false
+		}
+	}
+	if arg == "" {
+		return true
+	}
+	if !cidr {
+		return false
+	}
+	v4, v6, _ := strings.Cut(arg, "//")
+	v4 = strings.TrimPrefix(v4, "/")
+	if v4 != "" {
+		if n, err := strconv.Atoi(v4); err != nil || n < 0 || n > 32 {
+			return false
+		}
+	}
+	if v6 != "" {
+		if n, err := strconv.Atoi(v6); err != nil || n < 0 || n > 128 {
+			return false
+		}
+	}
+	return true
+}
+
+// isDMARC 判断文本是否以DMARC版本标签开头
+func isDMARC(text string) bool {
+	version, _, _ := strings.Cut(text, ";")
+	return strings.EqualFold(strings.ReplaceAll(version, " ", ""), "v=DMARC1")
+}
+
+// lintDMARC 检查 _dmarc 名称下的DMARC记录，以及放错位置的DMARC记录
+func lintDMARC(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	counts := make(map[string]int)
+	for _, r := range lz.Records {
+		text, ok := txtText(r)
+		if !ok {
+			continue
+		}
+		atDMARC := strings.HasPrefix(r.Owner, "_dmarc.")
+		switch {
+		case !atDMARC && isDMARC(text):
+			findings = append(findings, r.finding("", "DMARC记录应位于 _dmarc.<域名> 下"))
+		case atDMARC && !isDMARC(text):
+			findings = append(findings, r.finding("", "_dmarc 下的TXT记录必须以 v=DMARC1 开头"))
+		case atDMARC:
+			counts[r.Owner]++
+			if counts[r.Owner] == 2 {
+				findings = append(findings, r.finding("", "同一名称存在多条DMARC记录，接收方会忽略DMARC策略"))
+			}
+			for _, problem := range checkDMARC(text) {
+				findings = append(findings, r.finding("", "%s", problem))
+			}
+		}
+	}
+	return findings
+}
+
+// dmarcPolicies DMARC策略取值
+var dmarcPolicies = map[string]bool{"none": true, "quarantine": true, "reject": true}
+
+// checkDMARC 按RFC 7489检查DMARC标签，调用方已确认以 v=DMARC1 开头
+func checkDMARC(text string) []string {
+	var problems []string
+	tags := make(map[string]string)
+	for i, part := range strings.Split(text, ";") {
+		part = strings.TrimSpace(part)
+		if i == 0 || part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		if !ok || name == "" {
+			problems = append(problems, fmt.Sprintf("无效的标签: %s", part))
+			continue
+		}
+		if _, dup := tags[name]; dup {
+			problems = append(problems, fmt.Sprintf("标签 %s 重复", name))
+		}
+		tags[name] = value
+
+		switch name {
+		case "p", "sp", "np":
+			if !dmarcPolicies[strings.ToLower(value)] {
+				problems = append(problems, fmt.Sprintf("%s 只能为 none、quarantine 或 reject", name))
+			}
+		case "adkim", "aspf":
+			if v := strings.ToLower(value); v != "r" && v != "s" {
+				problems = append(problems, fmt.Sprintf("%s 只能为 r 或 s", name))
+			}
+		case "pct":
+			if n, err := strconv.Atoi(value); err != nil || n < 0 || n > 100 {
+				problems = append(problems, "pct 应为0-100的整数")
+			}
+		case "ri":
+			if n, err := strconv.ParseUint(value, 10, 32); err != nil || n == 0 {
+				problems = append(problems, "ri 应为正整数秒数")
+			}
+		case "fo":
+			for _, opt := range strings.Split(value, ":") {
+				switch strings.TrimSpace(opt) {
+				case "0", "1", "d", "s":
+				default:
+					problems = append(problems, fmt.Sprintf("fo 包含无效选项: %s", opt))
+				}
+			}
+		case "rua", "ruf":
+			for _, uri := range strings.Split(value, ",") {
+				uri = strings.TrimSpace(uri)
+				if k := strings.LastIndexByte(uri, '!'); k > 0 {
+					uri = uri[:k]
+				}
+				if u, err := url.Parse(uri); err != nil || u.Scheme == "" || (u.Scheme == "mailto" && u.Opaque == "") {
+					problems = append(problems, fmt.Sprintf("%s 包含无效的报告地址: %s", name, uri))
+				}
+			}
+		case "rf", "psd", "t":
+		default:
+			problems = append(problems, fmt.Sprintf("未知的DMARC标签: %s", name))
+		}
+	}
+	if _, ok := tags["p"]; !ok {
+		problems = append(problems, "缺少必需的 p 标签")
+	}
+	return problems
+}
+
+// lintCAA 检查CAA记录的标签和属性值，语义错误为错误级别，未知标签和参数语法问题为警告
+func lintCAA(lz *LintZone) []LintFinding {
+	var findings []LintFinding
+	for _, r := range lz.Records {
+		if r.RR == nil {
+			continue
+		}
+		caa, ok := r.RR.(*dns.CAA)
+		if !ok {
+			continue
+		}
+		if err := validateTypedRecord(caa, lz.Apex); err != nil {
+			findings = append(findings, r.finding(LintSeverityError, "%v", err))
+			continue
+		}
+		tag := strings.ToLower(caa.Tag)
+		if !caaTags[tag] {
+			findings = append(findings, r.finding("", "未知的CAA标签 %s，CA会忽略该记录", caa.Tag))
+			continue
+		}
+		if tag != "issue" && tag != "issuewild" && tag != "issuemail" && tag != "issuevmc" {
+			continue
+		}
+		params := strings.Split(caa.Value, ";")[1:]
+		for _, param := range params {
+			param = strings.TrimSpace(param)
+			if param == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(param, "=")
+			if !ok || key == "" || value == "" || strings.Trim(key, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") != "" || strings.ContainsAny(value, " \t") {
+				findings = append(findings, r.finding("", "CAA参数应为 key=value 格式: %s", param))
+			}
+		}
+	}
+	return findings
 }
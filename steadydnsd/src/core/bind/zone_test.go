@@ -0,0 +1,274 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/zone_test.go
+// 区域文件解析与回写的golden测试，testdata下每个*.zone对应一个*.golden
+
+package bind
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// updateGolden 使用 go test ./core/bind -update 重新生成golden文件
+var updateGolden = flag.Bool("update", false, "更新testdata中的golden文件")
+
+// newTestBindManager 创建以testdata为区域目录的管理器
+func newTestBindManager() *BindManager {
+	return &BindManager{config: BindConfig{ZoneFilePath: "testdata/"}}
+}
+
+// dumpZone 将解析结果输出为便于比对的文本
+func dumpZone(zone *AuthZone) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "$TTL %d\n", zone.DefaultTTL)
+	fmt.Fprintf(&b, "SOA %s %s %s %s %s %s %s\n", zone.SOA.PrimaryNS, zone.SOA.AdminEmail,
+		zone.SOA.Serial, zone.SOA.Refresh, zone.SOA.Retry, zone.SOA.Expire, zone.SOA.MinimumTTL)
+	for _, r := range zone.Records {
+		fmt.Fprintf(&b, "%s name=%q type=%s ttl=%d priority=%d value=%q", r.ID, r.Name, r.Type, r.TTL, r.Priority, r.Value)
+		if r.Comment != "" {
+			fmt.Fprintf(&b, " comment=%q", r.Comment)
+		}
+		if r.Source != "" {
+			fmt.Fprintf(&b, " source=%q", r.Source)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// checkGolden 比对golden文件，-update时重写
+func checkGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("写入golden文件失败: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取golden文件失败: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s 不一致\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// TestZoneFileGolden 解析语料库中的区域文件，并验证不做修改时逐字节回写
+func TestZoneFileGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.zone")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("未找到testdata区域文件: %v", err)
+	}
+	bm := newTestBindManager()
+
+	for _, path := range files {
+		domain := strings.TrimSuffix(filepath.Base(path), ".zone")
+		t.Run(domain, func(t *testing.T) {
+			zone, err := bm.parseZoneFile(path, domain)
+			if err != nil {
+				t.Fatalf("parseZoneFile() error = %v", err)
+			}
+			checkGolden(t, strings.TrimSuffix(path, ".zone")+".golden", dumpZone(zone))
+
+			original, _ := os.ReadFile(path)
+			got, err := bm.updateZoneContent(original, *zone, path)
+			if err != nil {
+				t.Fatalf("updateZoneContent() error = %v", err)
+			}
+			if got != string(original) {
+				t.Errorf("未修改时回写结果与原文件不一致\n--- got ---\n%s\n--- want ---\n%s", got, original)
+			}
+
+			// 再次解析得到相同的记录ID
+			again, err := bm.parseZoneFile(path, domain)
+			if err != nil {
+				t.Fatalf("parseZoneFile() error = %v", err)
+			}
+			if dumpZone(again) != dumpZone(zone) {
+				t.Errorf("重复解析结果不一致")
+			}
+		})
+	}
+}
+
+// TestZoneFileEdit 测试修改、删除和新增记录时只改动相关条目
+func TestZoneFileEdit(t *testing.T) {
+	bm := newTestBindManager()
+	path := "testdata/example.com.zone"
+	zone, err := bm.parseZoneFile(path, "example.com")
+	if err != nil {
+		t.Fatalf("parseZoneFile() error = %v", err)
+	}
+
+	zone.DefaultTTL = 7200
+	zone.SOA.Serial = "2026031503"
+	var records []Record
+	for _, r := range zone.Records {
+		switch {
+		case r.Type == "MX" && r.Priority == 10:
+			// 删除后，下一条省略所有者的MX需要显式写出所有者
+			continue
+		case r.Type == "A" && r.Name == "mail":
+			r.Value = "192.0.2.26"
+		case r.Type == "CNAME" && r.Name == "www":
+			r.ID = ""
+		}
+		records = append(records, r)
+	}
+	records = append(records,
+		Record{Name: "api", Type: "A", Value: "192.0.2.50", TTL: 300, Comment: "新增"},
+		Record{Name: "www", Type: "HINFO", Value: `"x86" "Linux"`},
+	)
+	zone.Records = records
+
+	original, _ := os.ReadFile(path)
+	got, err := bm.updateZoneContent(original, *zone, path)
+	if err != nil {
+		t.Fatalf("updateZoneContent() error = %v", err)
+	}
+	checkGolden(t, "testdata/example.com.edit.golden", got)
+
+	// 回写结果可以再次解析
+	zf, err := parseZoneContent(got, "example.com", "testdata")
+	if err != nil {
+		t.Fatalf("解析回写结果失败: %v", err)
+	}
+	if len(zf.records) != len(records) {
+		t.Errorf("回写后记录数 = %d, want %d", len(zf.records), len(records))
+	}
+}
+
+// TestGenerateZoneContent 测试新建区域文件的生成
+func TestGenerateZoneContent(t *testing.T) {
+	bm := newTestBindManager()
+	zone := AuthZone{
+		Domain: "new.example",
+		SOA: SOARecord{
+			PrimaryNS:  "ns1.new.example",
+			AdminEmail: "admin.new.example",
+			Serial:     "2026050101",
+			Refresh:    "3600",
+			Retry:      "1800",
+			Expire:     "604800",
+			MinimumTTL: "300",
+		},
+		Records: []Record{
+			{Name: "www", Type: "A", Value: "192.0.2.80"},
+			{Name: "@", Type: "TXT", Value: `"v=spf1 -all; 不发信"`},
+			{Name: "@", Type: "NS", Value: "ns1.new.example"},
+			{Name: "ns1", Type: "A", Value: "192.0.2.53", TTL: 600},
+			{Name: "@", Type: "MX", Value: "mail.new.example", Priority: 10, Comment: "主邮件"},
+			{Name: "_sip._udp", Type: "SRV", Value: "0 5 5060 sip.new.example."},
+			{Name: "@", Type: "CAA", Value: `0 issue "letsencrypt.org"`},
+		},
+	}
+
+	got, err := bm.generateZoneContent(zone)
+	if err != nil {
+		t.Fatalf("generateZoneContent() error = %v", err)
+	}
+	checkGolden(t, "testdata/new.example.golden", got)
+
+	zf, err := parseZoneContent(got, zone.Domain, "testdata")
+	if err != nil {
+		t.Fatalf("解析生成结果失败: %v", err)
+	}
+	if zf.defaultTTL != defaultZoneTTL || len(zf.records) != len(zone.Records) {
+		t.Errorf("生成结果解析不正确: $TTL=%d 记录数=%d", zf.defaultTTL, len(zf.records))
+	}
+}
+
+// TestZoneFileErrors 测试无效区域文件的错误报告
+func TestZoneFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"引号未闭合", "$TTL 3600\n@ IN TXT \"abc\n"},
+		{"括号未闭合", "@ IN SOA ns. admin. ( 1 2 3 4 5\n"},
+		{"括号不匹配", "@ IN A 192.0.2.1 )\n"},
+		{"未知指令", "$FOO bar\n"},
+		{"无效TTL", "$TTL 1x\n"},
+		{"未知类型", "@ 3600 IN BOGUS 1\n"},
+		{"无效地址", "@ 3600 IN A 192.0.2.300\n"},
+		{"多条SOA", "@ IN SOA ns. admin. 1 2 3 4 5\n@ IN SOA ns. admin. 1 2 3 4 5\n"},
+		{"包含文件不存在", "$INCLUDE missing.inc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseZoneContent(tt.content, "example.com", "testdata"); err == nil {
+				t.Errorf("parseZoneContent() 应返回错误")
+			}
+		})
+	}
+}
+
+// TestRenderRecordValidation 测试生成记录时拒绝无效的值
+func TestRenderRecordValidation(t *testing.T) {
+	tests := []struct {
+		record Record
+		valid  bool
+	}{
+		{Record{Name: "www", Type: "A", Value: "192.0.2.1"}, true},
+		{Record{Name: "www", Type: "A", Value: "not-an-ip"}, false},
+		{Record{Name: "@", Type: "MX", Value: "mail", Priority: 10}, true},
+		{Record{Name: "@", Type: "TXT", Value: `"a;b" "c"`}, true},
+		{Record{Name: "@", Type: "TXT", Value: `"unterminated`}, false},
+		{Record{Name: "@", Type: "CAA", Value: `0 issue`}, false},
+		{Record{Name: "x", Type: "A", Value: "192.0.2.1\nevil IN A 192.0.2.2"}, false},
+		{Record{Name: "x", Type: "A", Value: "192.0.2.1", TTL: -1}, false},
+	}
+	for _, tt := range tests {
+		_, err := renderRecord(tt.record, "example.com.", "example.com.")
+		if (err == nil) != tt.valid {
+			t.Errorf("renderRecord(%+v) error = %v, valid = %v", tt.record, err, tt.valid)
+		}
+	}
+}
+
+// TestParseTTLValue 测试TTL单位解析
+func TestParseTTLValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint32
+		ok   bool
+	}{
+		{"3600", 3600, true},
+		{"1h", 3600, true},
+		{"1h30m", 5400, true},
+		{"2W", 1209600, true},
+		{"1d2h3m4s", 93784, true},
+		{"", 0, false},
+		{"h", 0, false},
+		{"1x", 0, false},
+		{"1hh", 0, false},
+		{"4294967296", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseTTLValue(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseTTLValue(%q) = %d, %v, want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,794 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/zonefile.go
+// 区域文件词法分析、解析与保真回写（RFC 1035 主文件格式）
+
+package bind
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxIncludeDepth $INCLUDE最大嵌套层数
+const maxIncludeDepth = 8
+
+// zoneEntryKind 区域文件条目类型
+type zoneEntryKind int
+
+const (
+	zoneEntryBlank     zoneEntryKind = iota // 空行或纯注释行
+	zoneEntryDirective                      // $ORIGIN/$TTL/$INCLUDE/$GENERATE指令
+	zoneEntryRecord                         // 资源记录
+)
+
+// zoneToken 条目中的一个词法单元，引号保留在text中
+type zoneToken struct {
+	text       string
+	start, end int // 在条目原文中的偏移
+}
+
+// zoneEntry 区域文件中的一个逻辑条目，括号内跨多行的记录视为一个条目
+type zoneEntry struct {
+	raw     string // 原文，不含结尾换行
+	line    int    // 起始行号
+	tokens  []zoneToken
+	comment string // 条目内所有注释，多段以空格连接
+	inherit bool   // 行首为空白，沿用上一条记录的所有者
+	kind    zoneEntryKind
+
+	origin     string  // 条目位置生效的$ORIGIN
+	owner      string  // 记录的绝对所有者名；$INCLUDE为包含文件结束时的所有者
+	rr         dns.RR  // 解析出的资源记录
+	rdataIndex int     // rdata在tokens中的起始下标
+	record     *Record // 解析出的通用记录，SOA为nil
+}
+
+// zoneFile 解析后的区域文件，保留全部原始条目用于保真回写
+type zoneFile struct {
+	apex            string
+	entries         []*zoneEntry
+	trailingNewline bool
+	endOrigin       string     // 文件末尾生效的$ORIGIN
+	defaultTTL      uint32     // 第一条$TTL的值
+	ttlEntry        *zoneEntry // 第一条$TTL指令
+	soaEntry        *zoneEntry
+	soa             *dns.SOA
+	records         []Record // 按文件顺序排列的记录，含$INCLUDE文件中的记录
+}
+
+// lexZoneEntries 将区域文件内容切分为逻辑条目
+// 处理引号字符串、反斜杠转义、括号续行和分号注释，引号内的分号和括号不做特殊处理
+func lexZoneEntries(content string) ([]*zoneEntry, bool, error) {
+	var (
+		entries   []*zoneEntry
+		tokens    []zoneToken
+		comments  []string
+		start     int
+		startLine = 1
+		line      = 1
+		tokStart  = -1
+		depth     int
+		inQuote   bool
+		escape    bool
+	)
+
+	endToken := func(i int) {
+		if tokStart >= 0 {
+			tokens = append(tokens, zoneToken{text: content[tokStart:i], start: tokStart - start, end: i - start})
+			tokStart = -1
+		}
+	}
+	endEntry := func(i int) {
+		raw := content[start:i]
+		entries = append(entries, &zoneEntry{
+			raw:     raw,
+			line:    startLine,
+			tokens:  tokens,
+			comment: strings.Join(comments, " "),
+			inherit: raw != "" && (raw[0] == ' ' || raw[0] == '\t'),
+		})
+		tokens, comments = nil, nil
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if escape {
+			escape = false
+			if c == '\n' {
+				line++
+			}
+			continue
+		}
+		if inQuote {
+			switch c {
+			case '\\':
+				escape = true
+			case '"':
+				inQuote = false
+			case '\n':
+				line++
+			}
+			continue
+		}
+
+		switch c {
+		case '\\':
+			if tokStart < 0 {
+				tokStart = i
+			}
+			escape = true
+		case '"':
+			if tokStart < 0 {
+				tokStart = i
+			}
+			inQuote = true
+		case ';':
+			endToken(i)
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i
+			}
+			if text := strings.TrimSpace(content[i+1 : end]); text != "" {
+				comments = append(comments, text)
+			}
+			i = end - 1
+		case '(':
+			endToken(i)
+			depth++
+		case ')':
+			endToken(i)
+			if depth == 0 {
+				return nil, false, fmt.Errorf("第%d行: 括号不匹配", line)
+			}
+			depth--
+		case ' ', '\t', '\r':
+			endToken(i)
+		case '\n':
+			endToken(i)
+			line++
+			if depth == 0 {
+				endEntry(i)
+				start, startLine = i+1, line
+			}
+		default:
+			if tokStart < 0 {
+				tokStart = i
+			}
+		}
+	}
+
+	if inQuote {
+		return nil, false, fmt.Errorf("第%d行: 引号未闭合", startLine)
+	}
+	if depth > 0 {
+		return nil, false, fmt.Errorf("第%d行: 括号未闭合", startLine)
+	}
+
+	trailingNewline := len(content) > 0 && start == len(content)
+	if start < len(content) {
+		endToken(len(content))
+		endEntry(len(content))
+	}
+	return entries, trailingNewline, nil
+}
+
+// zoneParser 区域文件解析状态，$INCLUDE文件共享同一状态
+type zoneParser struct {
+	apex       string
+	dir        string // 相对$INCLUDE路径的基准目录
+	defaultTTL uint32
+	hasTTL     bool
+	lastTTL    uint32
+	lastOwner  string
+	ids        map[string]int // 相同记录的出现次数，用于生成稳定ID
+}
+
+// parseZoneContent 解析区域文件内容
+// 记录通过miekg/dns逐条解析，$GENERATE指令原样保留但不展开
+func parseZoneContent(content, domain, dir string) (*zoneFile, error) {
+	p := &zoneParser{
+		apex: dns.Fqdn(domain),
+		dir:  dir,
+		ids:  make(map[string]int),
+	}
+	return p.parse(content, p.apex, "", 0)
+}
+
+// parse 解析一个文件的内容，source为$INCLUDE文件名，主文件为空
+func (p *zoneParser) parse(content, origin, source string, depth int) (*zoneFile, error) {
+	entries, trailingNewline, err := lexZoneEntries(content)
+	if err != nil {
+		return nil, err
+	}
+
+	zf := &zoneFile{
+		apex:            p.apex,
+		entries:         entries,
+		trailingNewline: trailingNewline,
+	}
+	for _, e := range entries {
+		e.origin = origin
+		if len(e.tokens) == 0 {
+			continue
+		}
+		if !e.inherit && strings.HasPrefix(e.tokens[0].text, "$") {
+			e.kind = zoneEntryDirective
+			if err := p.directive(zf, e, &origin, depth); err != nil {
+				return nil, fmt.Errorf("第%d行: %v", e.line, err)
+			}
+			continue
+		}
+		e.kind = zoneEntryRecord
+		if err := p.record(zf, e, source); err != nil {
+			return nil, fmt.Errorf("第%d行: %v", e.line, err)
+		}
+	}
+	zf.endOrigin = origin
+	return zf, nil
+}
+
+// directive 处理$ORIGIN、$TTL、$INCLUDE和$GENERATE指令
+func (p *zoneParser) directive(zf *zoneFile, e *zoneEntry, origin *string, depth int) error {
+	name := strings.ToUpper(e.tokens[0].text)
+	args := e.tokens[1:]
+	if name != "$GENERATE" && len(args) == 0 {
+		return fmt.Errorf("%s缺少参数", name)
+	}
+
+	switch name {
+	case "$ORIGIN":
+		*origin = absoluteName(args[0].text, *origin)
+	case "$TTL":
+		ttl, ok := parseTTLValue(args[0].text)
+		if !ok {
+			return fmt.Errorf("无效的$TTL值: %s", args[0].text)
+		}
+		p.defaultTTL, p.hasTTL = ttl, true
+		if zf.ttlEntry == nil {
+			zf.ttlEntry, zf.defaultTTL = e, ttl
+		}
+	case "$INCLUDE":
+		if depth >= maxIncludeDepth {
+			return fmt.Errorf("$INCLUDE嵌套超过%d层", maxIncludeDepth)
+		}
+		file := strings.Trim(args[0].text, `"`)
+		includeOrigin := *origin
+		if len(args) > 1 {
+			includeOrigin = absoluteName(args[1].text, *origin)
+		}
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.dir, file)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取$INCLUDE文件失败: %v", err)
+		}
+		included, err := p.parse(string(content), includeOrigin, file, depth+1)
+		if err != nil {
+			return fmt.Errorf("解析$INCLUDE文件%s失败: %v", file, err)
+		}
+		zf.records = append(zf.records, included.records...)
+		e.owner = p.lastOwner
+	case "$GENERATE":
+		// 原样保留，生成的记录由BIND展开
+	default:
+		return fmt.Errorf("不支持的指令: %s", e.tokens[0].text)
+	}
+	return nil
+}
+
+// record 解析一条资源记录：[所有者] [TTL] [类] 类型 rdata
+func (p *zoneParser) record(zf *zoneFile, e *zoneEntry, source string) error {
+	tokens := e.tokens
+	owner := p.lastOwner
+	i := 0
+	if !e.inherit {
+		owner = absoluteName(tokens[0].text, e.origin)
+		i = 1
+	}
+	if owner == "" {
+		owner = e.origin
+	}
+
+	// TTL和类的顺序可以互换，均可省略
+	class := "IN"
+	var ttl uint32
+	explicitTTL, explicitClass := false, false
+	for ; i < len(tokens); i++ {
+		text := tokens[i].text
+		if !explicitClass && isZoneClass(text) {
+			class, explicitClass = strings.ToUpper(text), true
+			continue
+		}
+		if v, ok := parseTTLValue(text); ok && !explicitTTL {
+			ttl, explicitTTL = v, true
+			continue
+		}
+		break
+	}
+	if i >= len(tokens) {
+		return fmt.Errorf("记录缺少类型")
+	}
+	rrtype := strings.ToUpper(tokens[i].text)
+	if _, ok := dns.StringToType[rrtype]; !ok && !strings.HasPrefix(rrtype, "TYPE") {
+		return fmt.Errorf("未知记录类型: %s", tokens[i].text)
+	}
+	e.rdataIndex = i + 1
+
+	// 省略TTL时使用$TTL，没有$TTL时沿用上一条显式TTL
+	if explicitTTL {
+		p.lastTTL = ttl
+	} else if p.hasTTL {
+		ttl = p.defaultTTL
+	} else {
+		ttl = p.lastTTL
+	}
+
+	rdata := make([]string, 0, len(tokens)-e.rdataIndex)
+	for _, t := range tokens[e.rdataIndex:] {
+		rdata = append(rdata, t.text)
+	}
+	rr, err := parseRR(fmt.Sprintf("%s %d %s %s %s", owner, ttl, class, rrtype, strings.Join(rdata, " ")), e.origin)
+	if err != nil {
+		return err
+	}
+	e.owner, e.rr = rr.Header().Name, rr
+	p.lastOwner = e.owner
+
+	if soa, ok := rr.(*dns.SOA); ok {
+		// $INCLUDE文件中的SOA不作为区域的SOA
+		if source != "" {
+			return nil
+		}
+		if zf.soa != nil {
+			return fmt.Errorf("区域文件包含多条SOA记录")
+		}
+		zf.soa, zf.soaEntry = soa, e
+		return nil
+	}
+
+	rec := recordFromRR(rr, p.apex)
+	if explicitTTL {
+		rec.TTL = int(ttl)
+	}
+	rec.Comment = e.comment
+	rec.Source = source
+	rec.ID = p.recordID(rec)
+	e.record = &rec
+	zf.records = append(zf.records, rec)
+	return nil
+}
+
+// recordID 根据记录内容生成稳定的ID，同一文件重复解析得到相同结果
+func (p *zoneParser) recordID(rec Record) string {
+	key := recordIDKey(rec)
+	n := p.ids[key]
+	p.ids[key] = n + 1
+	return recordIDAt(key, n)
+}
+
+// recordIDKey 生成记录ID的内容部分
+func recordIDKey(rec Record) string {
+	return fmt.Sprintf("%s|%s|%d|%s", strings.ToLower(rec.Name), rec.Type, rec.Priority, rec.Value)
+}
+
+// recordIDAt 内容相同的第n条记录（从0开始）的ID
+func recordIDAt(key string, n int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", key, n)))
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// parseRR 使用miekg/dns解析一条所有者和TTL已确定的记录
+func parseRR(text, origin string) (dns.RR, error) {
+	zp := dns.NewZoneParser(strings.NewReader(text), origin, "")
+	rr, ok := zp.Next()
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("无法解析记录")
+	}
+	return rr, nil
+}
+
+// parseRecordText 解析单个条目文本（可为带括号的多行记录），用于校验生成结果
+func parseRecordText(text, origin string) (dns.RR, error) {
+	entries, _, err := lexZoneEntries(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 || len(entries[0].tokens) == 0 || entries[0].inherit {
+		return nil, fmt.Errorf("记录格式不正确")
+	}
+	p := &zoneParser{apex: origin, hasTTL: true, ids: make(map[string]int)}
+	e := entries[0]
+	e.origin = origin
+	if err := p.record(&zoneFile{}, e, ""); err != nil {
+		return nil, err
+	}
+	return e.rr, nil
+}
+
+// recordFromRR 将资源记录转换为通用记录，名称相对于区域顶点
+func recordFromRR(rr dns.RR, apex string) Record {
+	hdr := rr.Header()
+	rec := Record{
+		Name:  relativeName(hdr.Name, apex),
+		Type:  dns.Type(hdr.Rrtype).String(),
+		Value: strings.TrimPrefix(rr.String(), hdr.String()),
+	}
+	if mx, ok := rr.(*dns.MX); ok {
+		rec.Priority = int(mx.Preference)
+		rec.Value = mx.Mx
+	}
+	rec.setTypedData(rr)
+	return rec
+}
+
+// isZoneClass 判断是否为记录类字段
+func isZoneClass(s string) bool {
+	upper := strings.ToUpper(s)
+	if _, ok := dns.StringToClass[upper]; ok {
+		return true
+	}
+	return strings.HasPrefix(upper, "CLASS") && len(upper) > len("CLASS")
+}
+
+// parseTTLValue 解析TTL，支持纯秒数和1h30m这样的BIND单位写法
+func parseTTLValue(s string) (uint32, bool) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, false
+	}
+	var total, num uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			num = num*10 + uint64(c-'0')
+		} else {
+			var unit uint64
+			switch c {
+			case 's', 'S':
+				unit = 1
+			case 'm', 'M':
+				unit = 60
+			case 'h', 'H':
+				unit = 3600
+			case 'd', 'D':
+				unit = 86400
+			case 'w', 'W':
+				unit = 604800
+			default:
+				return 0, false
+			}
+			if i > 0 && (s[i-1] < '0' || s[i-1] > '9') {
+				return 0, false
+			}
+			total += num * unit
+			num = 0
+		}
+		if num > math.MaxUint32 || total+num > math.MaxUint32 {
+			return 0, false
+		}
+	}
+	return uint32(total + num), true
+}
+
+// absoluteName 将相对名称按origin补全为绝对域名
+func absoluteName(name, origin string) string {
+	switch {
+	case name == "" || name == "@":
+		return origin
+	case dns.IsFqdn(name):
+		return name
+	case origin == ".":
+		return name + "."
+	default:
+		return name + "." + origin
+	}
+}
+
+// relativeName 将绝对域名转换为相对origin的名称，不在origin之下时保持绝对形式
+func relativeName(name, origin string) string {
+	if strings.EqualFold(name, origin) {
+		return "@"
+	}
+	suffix := "." + origin
+	if origin != "." && len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name[:len(name)-len(suffix)]
+	}
+	return name
+}
+
+// zoneNameTypes 值为域名的记录类型，生成时补全结尾的点
+var zoneNameTypes = map[string]bool{
+	"NS":    true,
+	"CNAME": true,
+	"PTR":   true,
+	"MX":    true,
+	"DNAME": true,
+}
+
+// sameRecord 比较两条记录的内容是否一致（忽略ID）
+func sameRecord(a, b Record) bool {
+	return strings.EqualFold(a.Name, b.Name) &&
+		strings.EqualFold(a.Type, b.Type) &&
+		a.Value == b.Value &&
+		a.Priority == b.Priority &&
+		a.TTL == b.TTL &&
+		a.Comment == b.Comment
+}
+
+// 默认TTL值映射表，新写入的记录未指定TTL时使用
+var defaultTTLMap = map[string]int{
+	"NS":  86400,
+	"MX":  86400,
+	"PTR": 86400,
+}
+
+// getDefaultTTL 根据记录类型获取默认TTL值
+func getDefaultTTL(recordType string) int {
+	if ttl, ok := defaultTTLMap[strings.ToUpper(recordType)]; ok {
+		return ttl
+	}
+	return 3600 // 默认值
+}
+
+// withDefaultTTL 为未指定TTL的记录填入类型默认TTL
+// 区域文件中原本省略TTL（继承$TTL）的记录修改时不经过此处，保持继承
+func withDefaultTTL(rec Record) Record {
+	if rec.TTL == 0 {
+		rec.TTL = getDefaultTTL(rec.Type)
+	}
+	return rec
+}
+
+// renderRecord 将通用记录渲染为区域文件中的一行，并用解析器校验结果
+// 名称按origin输出，TTL为0时省略以继承$TTL
+func renderRecord(rec Record, origin, apex string) (string, error) {
+	rrtype := strings.ToUpper(rec.Type)
+	if rec.TTL < 0 {
+		return "", fmt.Errorf("记录 %s %s 的TTL无效: %d", rec.Name, rec.Type, rec.TTL)
+	}
+
+	value := rec.Value
+	if zoneNameTypes[rrtype] {
+		value = ensureDotSuffix(value)
+	}
+	head := "IN " + rrtype
+	if rrtype == "MX" {
+		head += " " + strconv.Itoa(rec.Priority)
+	}
+
+	line := relativeName(absoluteName(rec.Name, apex), origin) + "\t"
+	if rec.TTL > 0 {
+		line += strconv.Itoa(rec.TTL) + "\t"
+	}
+	line += head + "\t" + value
+	if rec.Comment != "" {
+		line += " ; " + strings.ReplaceAll(rec.Comment, "\n", " ")
+	}
+
+	rr, err := parseRecordText(line, origin)
+	if err != nil {
+		return "", fmt.Errorf("记录 %s %s 无效: %v", rec.Name, rec.Type, err)
+	}
+	if dns.Type(rr.Header().Rrtype).String() != rrtype {
+		return "", fmt.Errorf("记录 %s %s 无效: 类型不匹配", rec.Name, rec.Type)
+	}
+	return line, nil
+}
+
+// renderSOA 按多行格式渲染SOA记录
+func renderSOA(soa SOARecord, owner string) string {
+	return fmt.Sprintf("%s\tIN SOA %s %s (\n\t\t%s ; Serial\n\t\t%s ; Refresh\n\t\t%s ; Retry\n\t\t%s ; Expire\n\t\t%s ; Minimum TTL\n)",
+		owner, ensureDotSuffix(soa.PrimaryNS), ensureDotSuffix(soa.AdminEmail),
+		soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.MinimumTTL)
+}
+
+// soaFromRR 将SOA资源记录转换为SOARecord
+func soaFromRR(soa *dns.SOA) SOARecord {
+	return SOARecord{
+		PrimaryNS:  soa.Ns,
+		AdminEmail: soa.Mbox,
+		Serial:     strconv.FormatUint(uint64(soa.Serial), 10),
+		Refresh:    strconv.FormatUint(uint64(soa.Refresh), 10),
+		Retry:      strconv.FormatUint(uint64(soa.Retry), 10),
+		Expire:     strconv.FormatUint(uint64(soa.Expire), 10),
+		MinimumTTL: strconv.FormatUint(uint64(soa.Minttl), 10),
+	}
+}
+
+// patchSOA 只替换SOA条目中发生变化的字段，保留原有的排版和注释
+func patchSOA(e *zoneEntry, soa SOARecord) (string, error) {
+	fields := e.tokens[e.rdataIndex:]
+	if len(fields) != 7 {
+		return "", fmt.Errorf("SOA记录字段数量不正确")
+	}
+	current := soaFromRR(e.rr.(*dns.SOA))
+	wanted := []string{ensureDotSuffix(soa.PrimaryNS), ensureDotSuffix(soa.AdminEmail), soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.MinimumTTL}
+	existing := []string{current.PrimaryNS, current.AdminEmail, current.Serial, current.Refresh, current.Retry, current.Expire, current.MinimumTTL}
+
+	raw := e.raw
+	for i := len(fields) - 1; i >= 0; i-- {
+		if strings.EqualFold(wanted[i], existing[i]) {
+			continue
+		}
+		if i == 2 {
+			if _, err := strconv.ParseUint(wanted[i], 10, 32); err != nil {
+				return "", fmt.Errorf("无效的SOA序列号: %s", wanted[i])
+			}
+		} else if i > 2 {
+			if _, ok := parseTTLValue(wanted[i]); !ok {
+				return "", fmt.Errorf("无效的SOA时间值: %s", wanted[i])
+			}
+		}
+		raw = raw[:fields[i].start] + wanted[i] + raw[fields[i].end:]
+	}
+	return raw, nil
+}
+
+// renderZoneUpdate 基于原区域文件回写修改后的权威域
+// 未修改的条目（注释、空行、指令、多行记录）原样保留，修改的记录就地重写，删除的记录移除，
+// 新增记录插入到同类型最后一条记录之后，没有同类型记录时追加到文件末尾
+func renderZoneUpdate(zf *zoneFile, zone AuthZone) (string, error) {
+	if zf.soaEntry == nil {
+		return "", fmt.Errorf("区域文件缺少SOA记录")
+	}
+
+	// 优先按ID匹配原有记录
+	byID := make(map[string]*zoneEntry)
+	var recordEntries []*zoneEntry
+	for _, e := range zf.entries {
+		if e.record != nil {
+			byID[e.record.ID] = e
+			recordEntries = append(recordEntries, e)
+		}
+	}
+	matched := make(map[*zoneEntry]Record)
+	var pending []Record
+	for _, rec := range zone.Records {
+		// $INCLUDE文件中的记录只读
+		if rec.Source != "" {
+			continue
+		}
+		if e, ok := byID[rec.ID]; ok {
+			if _, used := matched[e]; !used {
+				matched[e] = rec
+				continue
+			}
+		}
+		pending = append(pending, rec)
+	}
+
+	// ID未匹配的记录再按内容匹配，兼容未携带ID的客户端
+	var added []Record
+	for _, rec := range pending {
+		found := false
+		for _, e := range recordEntries {
+			if _, used := matched[e]; !used && sameRecord(*e.record, rec) {
+				matched[e] = rec
+				found = true
+				break
+			}
+		}
+		if !found {
+			added = append(added, rec)
+		}
+	}
+
+	// 确定新增记录的插入位置
+	anchors := make(map[*zoneEntry][]Record)
+	var tail []Record
+	for _, rec := range added {
+		var anchor *zoneEntry
+		for _, e := range recordEntries {
+			if m, ok := matched[e]; ok && strings.EqualFold(m.Type, rec.Type) {
+				anchor = e
+			}
+		}
+		if anchor == nil {
+			tail = append(tail, rec)
+		} else {
+			anchors[anchor] = append(anchors[anchor], rec)
+		}
+	}
+
+	var out []string
+	if zf.ttlEntry == nil && zone.DefaultTTL > 0 {
+		out = append(out, fmt.Sprintf("$TTL %d", zone.DefaultTTL))
+	}
+
+	// lastOwner跟踪已输出的最后一个所有者，省略所有者的条目与之不一致时改为显式输出
+	lastOwner := ""
+	emit := func(rec Record, origin string) error {
+		line, err := renderRecord(rec, origin, zf.apex)
+		if err != nil {
+			return err
+		}
+		out = append(out, line)
+		lastOwner = absoluteName(rec.Name, zf.apex)
+		return nil
+	}
+
+	for _, e := range zf.entries {
+		switch {
+		case e == zf.ttlEntry && zone.DefaultTTL > 0 && uint32(zone.DefaultTTL) != zf.defaultTTL:
+			line := fmt.Sprintf("%s %d", e.tokens[0].text, zone.DefaultTTL)
+			if e.comment != "" {
+				line += " ; " + e.comment
+			}
+			out = append(out, line)
+		case e == zf.soaEntry:
+			raw, err := patchSOA(e, zone.SOA)
+			if err != nil {
+				return "", err
+			}
+			out = append(out, raw)
+			lastOwner = e.owner
+		case e.record != nil:
+			rec, ok := matched[e]
+			if !ok {
+				continue
+			}
+			if sameRecord(*e.record, rec) && (!e.inherit || strings.EqualFold(lastOwner, e.owner)) {
+				out = append(out, e.raw)
+				lastOwner = e.owner
+			} else {
+				// 原记录继承$TTL且修改后仍未指定TTL时继续继承，否则未指定TTL使用类型默认值
+				if e.record.TTL != 0 {
+					rec = withDefaultTTL(rec)
+				}
+				if err := emit(rec, e.origin); err != nil {
+					return "", err
+				}
+			}
+			for _, rec := range anchors[e] {
+				if err := emit(withDefaultTTL(rec), e.origin); err != nil {
+					return "", err
+				}
+			}
+		default:
+			out = append(out, e.raw)
+			if e.owner != "" {
+				lastOwner = e.owner
+			}
+		}
+	}
+	for _, rec := range tail {
+		if err := emit(withDefaultTTL(rec), zf.endOrigin); err != nil {
+			return "", err
+		}
+	}
+
+	content := strings.Join(out, "\n")
+	if zf.trailingNewline || len(tail) > 0 {
+		content += "\n"
+	}
+	return content, nil
+}
@@ -0,0 +1,403 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/zoneimport.go
+// 区域导入（上传区域文件或AXFR）和导出
+
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/database"
+
+	"github.com/miekg/dns"
+)
+
+var (
+	// ErrImportConflict 导入预览存在阻止导入的冲突
+	ErrImportConflict = errors.New("区域导入存在冲突")
+	// ErrInvalidImport 导入请求或区域数据无效
+	ErrInvalidImport = errors.New("导入数据无效")
+)
+
+// 导入冲突类型
+const (
+	ImportConflictZoneExists    = "zone_exists"    // 区域已存在
+	ImportConflictInvalidRecord = "invalid_record" // 记录无效
+	ImportConflictLint          = "lint"           // 区域检查结果，错误级别阻止导入
+	ImportConflictOutOfZone     = "out_of_zone"    // 记录不属于该区域，已忽略
+	ImportConflictSkippedType   = "skipped_type"   // 由BIND维护的DNSSEC记录，已忽略
+	ImportConflictGenerate      = "generate"       // $GENERATE指令不会展开导入
+)
+
+// importSkippedTypes 导入时忽略的记录类型，签名相关记录由BIND重新生成
+var importSkippedTypes = map[uint16]bool{
+	dns.TypeRRSIG:      true,
+	dns.TypeNSEC:       true,
+	dns.TypeNSEC3:      true,
+	dns.TypeNSEC3PARAM: true,
+	dns.TypeDNSKEY:     true,
+	dns.TypeCDS:        true,
+	dns.TypeCDNSKEY:    true,
+	dns.TypeZONEMD:     true,
+}
+
+// ZoneImportRequest 区域导入请求，Content和Server二选一
+type ZoneImportRequest struct {
+	Domain     string `json:"domain" form:"domain"`
+	Content    string `json:"content,omitempty" form:"content"`         // 上传的区域文件内容
+	Server     string `json:"server,omitempty" form:"server"`           // AXFR源服务器，host或host:port
+	KeyName    string `json:"key_name,omitempty" form:"key_name"`       // AXFR使用的TSIG密钥，取自密钥存储
+	AllowQuery string `json:"allow_query,omitempty" form:"allow_query"` // 新区域的allow-query，为空时使用全局配置
+	Comment    string `json:"comment,omitempty" form:"comment"`
+}
+
+// ZoneImportConflict 导入冲突或警告，Blocking为true时不能导入
+type ZoneImportConflict struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Message  string `json:"message"`
+	Blocking bool   `json:"blocking"`
+}
+
+// ZoneImportDiff 导入记录与现有区域记录的差异
+type ZoneImportDiff struct {
+	Added     []Record `json:"added"`
+	Removed   []Record `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// ZoneImportPreview 区域导入预览
+type ZoneImportPreview struct {
+	Zone      AuthZone             `json:"zone"`
+	Source    string               `json:"source"` // file或axfr
+	Exists    bool                 `json:"exists"`
+	Diff      *ZoneImportDiff      `json:"diff,omitempty"` // 区域已存在时与现有记录的差异
+	Conflicts []ZoneImportConflict `json:"conflicts"`
+	CanImport bool                 `json:"can_import"`
+}
+
+// PreviewZoneImport 解析导入来源，生成区域预览、差异和冲突报告，不修改任何文件
+func (bm *BindManager) PreviewZoneImport(req ZoneImportRequest) (*ZoneImportPreview, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(req.Domain)), ".")
+	if _, ok := dns.IsDomainName(domain); !ok || domain == "" {
+		return nil, fmt.Errorf("%w: 域名无效: %s", ErrInvalidImport, req.Domain)
+	}
+	if isSystemZone(domain) {
+		return nil, fmt.Errorf("不能导入系统区域: %s", domain)
+	}
+
+	preview := &ZoneImportPreview{Conflicts: make([]ZoneImportConflict, 0)}
+	var rrs []dns.RR
+	var err error
+	switch {
+	case req.Content != "" && req.Server != "":
+		return nil, fmt.Errorf("%w: 区域文件内容和AXFR服务器只能提供一个", ErrInvalidImport)
+	case req.Content != "":
+		preview.Source = "file"
+		rrs, err = parseImportContent(req.Content, domain, preview)
+	case req.Server != "":
+		preview.Source = "axfr"
+		rrs, err = transferZone(domain, req.Server, req.KeyName)
+	default:
+		return nil, fmt.Errorf("%w: 需要提供区域文件内容或AXFR服务器", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := zoneFromRRs(domain, rrs, preview)
+	if err != nil {
+		return nil, err
+	}
+	zone.AllowQuery = req.AllowQuery
+	zone.Comment = req.Comment
+	preview.Zone = *zone
+
+	// 与现有区域比较
+	if existing, err := bm.GetAuthZone(domain); err == nil {
+		preview.Exists = true
+		preview.Diff = diffZoneRecords(existing.Records, zone.Records)
+		preview.addConflict(ImportConflictZoneExists, "", "", "区域已存在，请先删除现有区域或使用记录接口逐条修改", true)
+	}
+
+	// 区域检查的错误阻止导入，警告仅提示
+	for _, f := range LintZoneData(preview.Zone).Findings {
+		preview.addConflict(ImportConflictLint, f.Name, f.Type, fmt.Sprintf("[%s] %s", f.RuleID, f.Message), f.Severity == LintSeverityError)
+	}
+
+	preview.CanImport = true
+	for _, c := range preview.Conflicts {
+		if c.Blocking {
+			preview.CanImport = false
+			break
+		}
+	}
+	return preview, nil
+}
+
+// ImportZone 导入区域：预览无阻止性冲突时创建区域，并与普通创建一样记录历史备份
+func (bm *BindManager) ImportZone(req ZoneImportRequest) (*ZoneImportPreview, error) {
+	preview, err := bm.PreviewZoneImport(req)
+	if err != nil {
+		return nil, err
+	}
+	if !preview.CanImport {
+		return preview, ErrImportConflict
+	}
+	if err := bm.createAuthZone(preview.Zone, true); err != nil {
+		return preview, err
+	}
+	return preview, nil
+}
+
+// addConflict 添加一条冲突或警告
+func (p *ZoneImportPreview) addConflict(kind, name, rrtype, message string, blocking bool) {
+	p.Conflicts = append(p.Conflicts, ZoneImportConflict{
+		Kind: kind, Name: name, Type: rrtype, Message: message, Blocking: blocking,
+	})
+}
+
+// parseImportContent 逐条解析上传的区域文件，拒绝$INCLUDE以免读取服务器上的文件
+func parseImportContent(content, domain string, preview *ZoneImportPreview) ([]dns.RR, error) {
+	entries, _, err := lexZoneEntries(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解析区域文件失败: %v", ErrInvalidImport, err)
+	}
+	for _, e := range entries {
+		if len(e.tokens) == 0 || e.inherit {
+			continue
+		}
+		switch strings.ToUpper(e.tokens[0].text) {
+		case "$INCLUDE":
+			return nil, fmt.Errorf("%w: 第%d行: 导入的区域文件不支持$INCLUDE", ErrInvalidImport, e.line)
+		case "$GENERATE":
+			preview.addConflict(ImportConflictGenerate, "", "", fmt.Sprintf("第%d行的$GENERATE指令不会被导入", e.line), false)
+		}
+	}
+
+	zf, err := parseZoneContent(content, domain, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: 解析区域文件失败: %v", ErrInvalidImport, err)
+	}
+	if zf.soa == nil {
+		return nil, fmt.Errorf("%w: 区域文件缺少SOA记录", ErrInvalidImport)
+	}
+	rrs := []dns.RR{zf.soa}
+	for _, e := range zf.entries {
+		if e.rr != nil && e.rr != dns.RR(zf.soa) {
+			rrs = append(rrs, e.rr)
+		}
+	}
+	return rrs, nil
+}
+
+// transferZone 通过AXFR从源服务器获取区域数据，keyName不为空时使用密钥存储中的TSIG密钥签名
+func transferZone(domain, server, keyName string) ([]dns.RR, error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(dns.Fqdn(domain))
+	t := &dns.Transfer{DialTimeout: 10 * time.Second, ReadTimeout: 30 * time.Second}
+	if keyName != "" {
+		key, err := database.GetTSIGKeyByName(normalizeTSIGKeyName(keyName))
+		if err != nil {
+			return nil, err
+		}
+		name := dns.Fqdn(key.Name)
+		t.TsigSecret = map[string]string{name: key.Secret}
+		msg.SetTsig(name, dns.Fqdn(key.Algorithm), 300, time.Now().Unix())
+	}
+
+	envelopes, err := t.In(msg, addr)
+	if err != nil {
+		return nil, fmt.Errorf("AXFR请求失败: %v", err)
+	}
+	var rrs []dns.RR
+	for env := range envelopes {
+		if env.Error != nil {
+			return nil, fmt.Errorf("AXFR传送失败: %v", env.Error)
+		}
+		rrs = append(rrs, env.RR...)
+	}
+	// AXFR以SOA开始并以相同SOA结束，去掉结尾的SOA
+	if len(rrs) < 2 || rrs[0].Header().Rrtype != dns.TypeSOA || rrs[len(rrs)-1].Header().Rrtype != dns.TypeSOA {
+		return nil, fmt.Errorf("AXFR响应不完整")
+	}
+	return rrs[:len(rrs)-1], nil
+}
+
+// zoneFromRRs 将资源记录转换为AuthZone，第一条记录须为区域顶点的SOA
+// 默认TTL取SOA记录的TTL，与之不同的记录保留显式TTL
+func zoneFromRRs(domain string, rrs []dns.RR, preview *ZoneImportPreview) (*AuthZone, error) {
+	apex := dns.Fqdn(domain)
+	if len(rrs) == 0 {
+		return nil, fmt.Errorf("%w: 区域数据缺少SOA记录", ErrInvalidImport)
+	}
+	soa, ok := rrs[0].(*dns.SOA)
+	if !ok || !strings.EqualFold(soa.Hdr.Name, apex) {
+		return nil, fmt.Errorf("%w: 区域数据缺少区域顶点的SOA记录", ErrInvalidImport)
+	}
+
+	zone := &AuthZone{
+		Domain:     domain,
+		Type:       ZoneTypeMaster,
+		DefaultTTL: int(soa.Hdr.Ttl),
+		SOA:        soaFromRR(soa),
+		Records:    make([]Record, 0, len(rrs)-1),
+	}
+	ids := &zoneParser{ids: make(map[string]int)}
+	for _, rr := range rrs[1:] {
+		hdr := rr.Header()
+		rrtype := dns.Type(hdr.Rrtype).String()
+		switch {
+		case !dns.IsSubDomain(apex, dns.CanonicalName(hdr.Name)):
+			preview.addConflict(ImportConflictOutOfZone, hdr.Name, rrtype, "记录不属于该区域，已忽略", false)
+			continue
+		case importSkippedTypes[hdr.Rrtype]:
+			preview.addConflict(ImportConflictSkippedType, hdr.Name, rrtype, "DNSSEC相关记录由BIND维护，已忽略", false)
+			continue
+		case hdr.Rrtype == dns.TypeSOA:
+			preview.addConflict(ImportConflictInvalidRecord, hdr.Name, rrtype, "区域包含多条SOA记录", true)
+			continue
+		}
+
+		rec := recordFromRR(rr, apex)
+		if int(hdr.Ttl) != zone.DefaultTTL {
+			rec.TTL = int(hdr.Ttl)
+		}
+		if err := normalizeRecord(&rec, apex); err != nil {
+			preview.addConflict(ImportConflictInvalidRecord, hdr.Name, rrtype, err.Error(), true)
+			continue
+		}
+		rec.ID = ids.recordID(rec)
+		zone.Records = append(zone.Records, rec)
+	}
+	return zone, nil
+}
+
+// recordKey 记录比较键，忽略ID、TTL和注释
+func recordKey(r Record) string {
+	return fmt.Sprintf("%s|%s|%d|%s", strings.ToLower(r.Name), strings.ToUpper(r.Type), r.Priority, r.Value)
+}
+
+// diffZoneRecords 比较现有记录和导入记录
+func diffZoneRecords(existing, imported []Record) *ZoneImportDiff {
+	diff := &ZoneImportDiff{Added: make([]Record, 0), Removed: make([]Record, 0)}
+	current := make(map[string]int)
+	for _, r := range existing {
+		current[recordKey(r)]++
+	}
+	for _, r := range imported {
+		key := recordKey(r)
+		if current[key] > 0 {
+			current[key]--
+			diff.Unchanged++
+			continue
+		}
+		diff.Added = append(diff.Added, r)
+	}
+	for _, r := range existing {
+		if key := recordKey(r); current[key] > 0 {
+			current[key]--
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+	return diff
+}
+
+// ExportZone 以规范表示格式导出区域：所有者名称为绝对形式，按RFC 4034规范顺序排列
+// $INCLUDE文件中的记录一并导出，$GENERATE指令不展开
+func (bm *BindManager) ExportZone(domain string) (string, error) {
+	zone, err := bm.GetAuthZone(domain)
+	if err != nil {
+		return "", ErrZoneNotFound
+	}
+	return renderCanonicalZone(zone)
+}
+
+// renderCanonicalZone 生成区域的规范表示格式文本
+func renderCanonicalZone(zone *AuthZone) (string, error) {
+	apex := dns.Fqdn(zone.Domain)
+	ttl := zone.DefaultTTL
+	if ttl <= 0 {
+		ttl = defaultZoneTTL
+	}
+
+	soa, err := parseRecordText(renderSOA(zone.SOA, "@"), apex)
+	if err != nil {
+		return "", fmt.Errorf("SOA记录无效: %v", err)
+	}
+	soa.Header().Ttl = uint32(ttl)
+
+	rrs := make([]dns.RR, 0, len(zone.Records))
+	for _, rec := range zone.Records {
+		line, err := renderRecord(rec, apex, apex)
+		if err != nil {
+			return "", err
+		}
+		rr, err := parseRecordText(line, apex)
+		if err != nil {
+			return "", fmt.Errorf("记录 %s %s 无效: %v", rec.Name, rec.Type, err)
+		}
+		if rec.TTL == 0 {
+			rr.Header().Ttl = uint32(ttl)
+		}
+		rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+		rrs = append(rrs, rr)
+	}
+	sort.SliceStable(rrs, func(i, j int) bool {
+		a, b := rrs[i].Header(), rrs[j].Header()
+		if c := compareCanonicalNames(a.Name, b.Name); c != 0 {
+			return c < 0
+		}
+		if a.Rrtype != b.Rrtype {
+			return a.Rrtype < b.Rrtype
+		}
+		return rrs[i].String() < rrs[j].String()
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("$ORIGIN %s\n$TTL %d\n", apex, ttl))
+	sb.WriteString(soa.String() + "\n")
+	for _, rr := range rrs {
+		sb.WriteString(rr.String() + "\n")
+	}
+	return sb.String(), nil
+}
+
+// compareCanonicalNames 按RFC 4034第6.1节的规范顺序比较两个域名（从右向左逐个标签比较）
+func compareCanonicalNames(a, b string) int {
+	la := dns.SplitDomainName(dns.CanonicalName(a))
+	lb := dns.SplitDomainName(dns.CanonicalName(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
@@ -0,0 +1,233 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/bind/zoneimport_test.go
+// 区域导入预览、冲突报告、AXFR获取和规范格式导出测试
+
+package bind
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"SteadyDNS/core/common"
+
+	"github.com/miekg/dns"
+)
+
+const importZoneContent = `$TTL 3600
+@	IN SOA ns1.example.org. hostmaster.example.org. ( 2024050101 7200 900 1209600 300 )
+	IN NS ns1
+ns1	IN A 192.0.2.53
+www	300 IN A 192.0.2.80
+mail	IN MX 10 mx.example.net.
+@	IN RRSIG A 13 2 3600 20240601000000 20240501000000 12345 example.org. dGVzdA==
+other.example.com.	IN A 192.0.2.1
+$GENERATE 1-3 host$ A 192.0.2.$
+`
+
+// newImportTestBindManager 创建使用临时named.conf的管理器
+func newImportTestBindManager(t *testing.T) *BindManager {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "named.conf"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &BindManager{
+		config: BindConfig{NamedConfPath: dir, ZoneFilePath: dir},
+		logger: common.NewLoggerWithLevel(common.ERROR),
+	}
+}
+
+// TestPreviewZoneImportFile 测试上传区域文件的预览和冲突报告
+func TestPreviewZoneImportFile(t *testing.T) {
+	bm := newImportTestBindManager(t)
+	preview, err := bm.PreviewZoneImport(ZoneImportRequest{Domain: "Example.org.", Content: importZoneContent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !preview.CanImport || preview.Exists || preview.Source != "file" {
+		t.Fatalf("预览状态不符合预期: %+v", preview)
+	}
+
+	zone := preview.Zone
+	if zone.Domain != "example.org" || zone.DefaultTTL != 3600 || zone.SOA.Serial != "2024050101" {
+		t.Errorf("区域信息不符合预期: %+v", zone)
+	}
+	got := make(map[string]Record)
+	for _, r := range zone.Records {
+		got[r.Name+" "+r.Type] = r
+	}
+	if len(got) != 4 {
+		t.Errorf("期望4条记录，得到 %v", zone.Records)
+	}
+	if got["www A"].TTL != 300 || got["ns1 A"].TTL != 0 {
+		t.Errorf("TTL处理不符合预期: %+v", zone.Records)
+	}
+	if mx := got["mail MX"]; mx.Priority != 10 || mx.Value != "mx.example.net." {
+		t.Errorf("MX记录不符合预期: %+v", mx)
+	}
+
+	kinds := make(map[string]bool)
+	for _, c := range preview.Conflicts {
+		if c.Blocking {
+			t.Errorf("不应存在阻止性冲突: %+v", c)
+		}
+		kinds[c.Kind] = true
+	}
+	for _, kind := range []string{ImportConflictSkippedType, ImportConflictOutOfZone, ImportConflictGenerate} {
+		if !kinds[kind] {
+			t.Errorf("缺少 %s 警告: %+v", kind, preview.Conflicts)
+		}
+	}
+}
+
+// TestPreviewZoneImportRejects 测试无效导入和阻止性冲突
+func TestPreviewZoneImportRejects(t *testing.T) {
+	bm := newImportTestBindManager(t)
+
+	for name, req := range map[string]ZoneImportRequest{
+		"include": {Domain: "example.org", Content: "$INCLUDE /etc/passwd\n"},
+		"无SOA":    {Domain: "example.org", Content: "$TTL 300\nwww IN A 192.0.2.1\n"},
+		"无来源":     {Domain: "example.org"},
+		"两种来源":    {Domain: "example.org", Content: importZoneContent, Server: "192.0.2.1"},
+	} {
+		if _, err := bm.PreviewZoneImport(req); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%s: 期望ErrInvalidImport，得到 %v", name, err)
+		}
+	}
+
+	content := importZoneContent + "www IN CNAME ns1\n"
+	preview, err := bm.PreviewZoneImport(ZoneImportRequest{Domain: "example.org", Content: content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.CanImport {
+		t.Error("CNAME冲突时不应允许导入")
+	}
+	if _, err := bm.ImportZone(ZoneImportRequest{Domain: "example.org", Content: content}); !errors.Is(err, ErrImportConflict) {
+		t.Errorf("期望ErrImportConflict，得到 %v", err)
+	}
+}
+
+// TestDiffZoneRecords 测试现有记录与导入记录的差异
+func TestDiffZoneRecords(t *testing.T) {
+	existing := []Record{
+		{Name: "www", Type: "A", Value: "192.0.2.1"},
+		{Name: "www", Type: "A", Value: "192.0.2.2"},
+		{Name: "@", Type: "MX", Priority: 10, Value: "mail.example.org."},
+	}
+	imported := []Record{
+		{Name: "WWW", Type: "A", Value: "192.0.2.1", TTL: 60},
+		{Name: "@", Type: "MX", Priority: 20, Value: "mail.example.org."},
+	}
+	diff := diffZoneRecords(existing, imported)
+	if diff.Unchanged != 1 || len(diff.Added) != 1 || len(diff.Removed) != 2 {
+		t.Errorf("差异不符合预期: %+v", diff)
+	}
+}
+
+// TestTransferZone 测试通过AXFR获取区域数据
+func TestTransferZone(t *testing.T) {
+	zp := dns.NewZoneParser(strings.NewReader(importZoneContent), "example.org.", "")
+	var rrs []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	rrs = append(rrs, rrs[0])
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{Listener: ln, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		ch := make(chan *dns.Envelope, 1)
+		tr := new(dns.Transfer)
+		go tr.Out(w, r, ch)
+		ch <- &dns.Envelope{RR: rrs}
+		close(ch)
+		w.Hijack()
+	})}
+	go server.ActivateAndServe()
+	defer server.Shutdown()
+
+	got, err := transferZone("example.org", ln.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(rrs)-1 {
+		t.Fatalf("期望 %d 条记录，得到 %d", len(rrs)-1, len(got))
+	}
+
+	preview := &ZoneImportPreview{}
+	zone, err := zoneFromRRs("example.org", got, preview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 源服务器已展开$GENERATE，RRSIG和区域外记录被忽略
+	if len(zone.Records) != 7 || zone.SOA.Serial != "2024050101" {
+		t.Errorf("AXFR转换结果不符合预期: %+v", zone)
+	}
+}
+
+// TestRenderCanonicalZone 测试规范格式导出的顺序，以及导出结果可重新导入
+func TestRenderCanonicalZone(t *testing.T) {
+	bm := newImportTestBindManager(t)
+	preview, err := bm.PreviewZoneImport(ZoneImportRequest{Domain: "example.org", Content: importZoneContent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported, err := renderCanonicalZone(&preview.Zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(exported), "\n")
+	var owners []string
+	for _, line := range lines[2:] {
+		owners = append(owners, strings.Fields(line)[0]+" "+strings.Fields(line)[3])
+	}
+	want := []string{"example.org. SOA", "example.org. NS", "mail.example.org. MX", "ns1.example.org. A", "www.example.org. A"}
+	if strings.Join(owners, ",") != strings.Join(want, ",") {
+		t.Errorf("导出顺序不符合预期:\n%s", exported)
+	}
+	if !strings.HasPrefix(exported, "$ORIGIN example.org.\n$TTL 3600\n") || !strings.Contains(exported, "www.example.org.\t300\tIN\tA\t192.0.2.80") {
+		t.Errorf("导出内容不符合预期:\n%s", exported)
+	}
+
+	reimported, err := bm.PreviewZoneImport(ZoneImportRequest{Domain: "example.org", Content: exported})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := diffZoneRecords(preview.Zone.Records, reimported.Zone.Records)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 {
+		t.Errorf("重新导入后记录发生变化: %+v", diff)
+	}
+}
+
+// TestCompareCanonicalNames 测试RFC 4034规范顺序
+func TestCompareCanonicalNames(t *testing.T) {
+	ordered := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.", "z.example.", "*.z.example."}
+	for i := 0; i < len(ordered)-1; i++ {
+		if compareCanonicalNames(ordered[i], ordered[i+1]) >= 0 {
+			t.Errorf("%s 应排在 %s 之前", ordered[i], ordered[i+1])
+		}
+	}
+}
@@ -33,6 +33,19 @@ const DefaultConfigTemplate = `# SteadyDNS Configuration File
 # Database file path (relative to working directory)
 # Default: steadydns.db
 DB_PATH=steadydns.db
+# Statistics retention per tier (days)
+# Raw QPS, resource and network samples; the 1h/6h/24h ranges read raw samples
+# Default: 2, Recommended: 1-7
+STATS_RAW_RETENTION_DAYS=2
+# 1-minute rollups (min/avg/max), used for the 7d range
+# Default: 30, Recommended: 7-90
+STATS_MINUTE_RETENTION_DAYS=30
+# 1-hour rollups, used for the 30d range
+# Default: 365, Recommended: 30-730
+STATS_HOUR_RETENTION_DAYS=365
+# 1-day rollups, used for the 1y range
+# Default: 1825, Recommended: 365-3650
+STATS_DAY_RETENTION_DAYS=1825
 
 [APIServer]
 # API Server port
@@ -167,6 +180,12 @@ BIND_CHECKCONF_PATH=/usr/local/bind9/bin/named-checkconf
 # named-checkzone executable path
 # Default: /usr/local/bind9/bin/named-checkzone
 BIND_CHECKZONE_PATH=/usr/local/bind9/bin/named-checkzone
+# Warn when DNSSEC signatures of a signed zone expire within this many hours
+# Default: 72, Recommended: 24-168
+DNSSEC_EXPIRY_WARNING_HOURS=72
+# Comma-separated zone lint rule IDs to skip on zone save and on-demand checks
+# (e.g. ttl-mismatch,spf-syntax). Default: empty (all rules enabled)
+ZONE_LINT_DISABLED_RULES=
 
 [DNS]
 # Client processing worker pool size
@@ -209,6 +228,56 @@ QUERY_LOG_MAX_SIZE=10
 # Default: 10, Recommended: 5-20
 # Maximum number of log files to retain. The oldest file will be deleted when this limit is exceeded.
 QUERY_LOG_MAX_FILES=10
+# Query log format
+# Default: text, Recommended: json (when shipping logs to a log pipeline)
+# Possible values: text (key=value lines), json (one JSON object per line)
+QUERY_LOG_FORMAT=text
+# Query log fields (JSON format only, comma separated)
+# Default: empty (all fields)
+# Available: timestamp, query_id, client_ip, client_port, protocol, qname, qtype, rcode, answer_count, answers, cache, group, upstream, security, durations_ms, total_ms, flags, error
+QUERY_LOG_FIELDS=
+# Client IP anonymization for JSON log lines, the query log store, the live query stream and anomaly alerts
+# Applies in both formats; text log lines and anomaly quarantine always use the real client IP
+# Default: none, Recommended: truncate (privacy-sensitive deployments)
+# Possible values: none, truncate (keep network prefix), hash (keyed HMAC-SHA256)
+QUERY_LOG_ANONYMIZE=none
+# IPv4 prefix length kept when anonymizing with truncate
+# Default: 24, Recommended: 16-24
+QUERY_LOG_IPV4_PREFIX=24
+# IPv6 prefix length kept when anonymizing with truncate
+# Default: 48, Recommended: 32-56
+QUERY_LOG_IPV6_PREFIX=48
+# Secret key used when anonymizing with hash
+# Default: empty (random key per process, hashes cannot be correlated across restarts)
+QUERY_LOG_HASH_SALT=
+# Enable dnstap output
+# Default: false, Recommended: true (when a dnstap collector is available)
+# Emits client and forwarder query/response messages in Frame Streams format
+DNSTAP_ENABLED=false
+# dnstap output target
+# Default: unix:/var/run/steadydns/dnstap.sock
+# Possible values: unix:/path/to/socket, tcp:host:port, file:/path/to/file.dnstap (file output is appended, each start or reconnect begins a new START/STOP session)
+DNSTAP_OUTPUT=unix:/var/run/steadydns/dnstap.sock
+# dnstap server identity
+# Default: empty (hostname)
+DNSTAP_IDENTITY=
+# dnstap message categories (comma separated)
+# Default: client,forwarder
+# Possible values: client (CLIENT_QUERY/CLIENT_RESPONSE), forwarder (FORWARDER_QUERY/FORWARDER_RESPONSE)
+DNSTAP_MESSAGES=client,forwarder
+# dnstap frame buffer size
+# Default: 10000, Recommended: 10000-100000
+# Frames are dropped (and counted) when the buffer is full or the collector is unavailable
+DNSTAP_BUFFER_SIZE=10000
+# Indexed query log store path (used by the log analysis plugin)
+# Default: log/querylog.db
+QUERY_LOG_STORE_PATH=log/querylog.db
+# Days of query logs kept in the indexed store
+# Default: 7, Recommended: 3-30
+QUERY_LOG_RETENTION_DAYS=7
+# Maximum size of the indexed query log store (MB), oldest entries are removed first
+# Default: 1024
+QUERY_LOG_STORE_MAX_SIZE=1024
 # Log level
 # Default: DEBUG, Recommended: INFO (production)/DEBUG (development)
 # Log detail level. Possible values: DEBUG, INFO, WARN, ERROR
@@ -234,7 +303,130 @@ DNS_MESSAGE_SIZE_LIMIT=4096
 # DNS query validation enabled
 # Default: true, Recommended: true
 # Enable DNS message validation to prevent poisoning attacks
+# Upstream responses are also validated before being cached or returned
 DNS_VALIDATION_ENABLED=true
+# DNS 0x20 QNAME case randomisation for forwarded queries
+# Default: false, Recommended: true (if all upstream servers preserve QNAME case)
+# Randomises the letter case of outgoing query names and rejects responses that do not echo the exact case
+DNS_0X20_ENABLED=false
+
+[Events]
+# Event stream enabled (server down/up, group unavailable, BIND stopped, rate-limit bans, cache pressure, DNS anomalies, expiring DNSSEC signatures)
+# Default: true, Recommended: true
+EVENTS_ENABLED=true
+# Per-event-type switches
+# Default: true
+SERVER_DOWN_ENABLED=true
+SERVER_UP_ENABLED=true
+GROUP_UNAVAILABLE_ENABLED=true
+GROUP_RECOVERED_ENABLED=true
+BIND_STOPPED_ENABLED=true
+BIND_RECOVERED_ENABLED=true
+RATE_LIMIT_BAN_ENABLED=true
+CACHE_PRESSURE_ENABLED=true
+DNS_ANOMALY_ENABLED=true
+DNSSEC_EXPIRING_ENABLED=true
+# Minimum interval between repeated rate-limit ban / cache pressure / DNS anomaly events for the same subject (seconds)
+# Default: 300, Recommended: 60-3600
+EVENT_COOLDOWN_SECONDS=300
+# Event retention (days)
+# Default: 30, Recommended: 7-90
+EVENT_RETENTION_DAYS=30
+# Webhook request timeout (seconds)
+# Default: 5, Recommended: 3-10
+WEBHOOK_TIMEOUT=5
+# Webhook maximum retries after the first failed attempt
+# Default: 3, Recommended: 0-5
+WEBHOOK_MAX_RETRIES=3
+# Webhook initial retry backoff (seconds), doubled on each retry
+# Default: 2, Recommended: 1-10
+WEBHOOK_RETRY_BACKOFF=2
+# BIND status check interval (seconds), 0 to disable
+# Restart the service for changes to take effect
+# Default: 30, Recommended: 10-120
+BIND_MONITOR_INTERVAL=30
+# DNSSEC signature expiry check interval for signed zones (seconds), 0 to disable
+# Restart the service for changes to take effect
+# Default: 3600, Recommended: 600-21600
+DNSSEC_MONITOR_INTERVAL=3600
+
+[Metrics]
+# Prometheus metrics endpoint (/metrics, text exposition format)
+# Default: false
+# Restart the service for changes to take effect
+METRICS_ENABLED=false
+# Separate listen address for /metrics, e.g. 127.0.0.1:9153
+# Default: empty (serve /metrics on the API server address)
+METRICS_LISTEN=
+# Bearer token required to scrape /metrics (Authorization: Bearer <token>)
+# Default: empty
+METRICS_AUTH_TOKEN=
+# HTTP basic auth credentials required to scrape /metrics
+# Default: empty; when both a token and basic auth are set, either is accepted
+# Without a token or basic auth, /metrics is served without authentication
+METRICS_BASIC_AUTH_USER=
+METRICS_BASIC_AUTH_PASSWORD=
+
+[AnomalyDetection]
+# DNS tunnelling / DGA detection on the query stream
+# Requires the Log Analysis plugin (LOG_ANALYSIS_ENABLED=true)
+# Restart the service for changes to take effect
+# Default: true
+ANOMALY_DETECTION_ENABLED=true
+# Counting window for per-client and per-domain signals (seconds)
+# Default: 60, Recommended: 30-300
+ANOMALY_WINDOW_SECONDS=60
+# Shannon entropy (bits per character) above which a query's subdomain part is suspicious
+# Default: 4.0, Recommended: 3.5-4.5
+ANOMALY_ENTROPY_THRESHOLD=4.0
+# Minimum subdomain length (characters) before entropy is evaluated
+# Default: 20
+ANOMALY_ENTROPY_MIN_LENGTH=20
+# Query name length (characters) above which a query is suspicious
+# Default: 100, Recommended: 80-150
+ANOMALY_QNAME_LENGTH_THRESHOLD=100
+# High-entropy or over-long queries per client per window that raise an alert
+# Default: 20
+ANOMALY_SUSPICIOUS_QUERY_THRESHOLD=20
+# TXT/NULL queries per client per window that raise an alert
+# Default: 100
+ANOMALY_TXT_NULL_THRESHOLD=100
+# Unique subdomains per registered domain per window that raise an alert
+# Default: 200
+ANOMALY_UNIQUE_SUBDOMAIN_THRESHOLD=200
+# NXDOMAIN ratio per client that raises an alert, evaluated once the client reaches the minimum query count
+# Default: 0.5 and 50
+ANOMALY_NXDOMAIN_RATIO=0.5
+ANOMALY_NXDOMAIN_MIN_QUERIES=50
+# Registered domains never scored (comma-separated, e.g. CDN or anti-virus lookup domains)
+# Default: empty
+ANOMALY_ALLOWLIST=
+# Quarantine offending clients through the DNS rate limiter (all queries refused)
+# Default: false
+ANOMALY_QUARANTINE_ENABLED=false
+# Quarantine duration (minutes)
+# Default: 30, Recommended: 5-1440
+ANOMALY_QUARANTINE_MINUTES=30
+
+[DynamicUpdate]
+# Accept RFC 2136 dynamic updates (e.g. from DHCP servers or ACME clients) on the DNS listener
+# Updates must be TSIG-signed and are written to the authoritative zone files managed by the BIND plugin,
+# with a history backup, serial increment and zone reload. Unsigned updates are refused.
+# When disabled, UPDATE messages are answered with NOTIMP
+# Restart the service for changes to take effect
+# Default: false
+DYNAMIC_UPDATE_ENABLED=false
+# TSIG key file in BIND format (output of tsig-keygen), may contain several key statements
+# Keys managed in SteadyDNS (TSIG key store) are loaded as well; leave empty to use only those
+# Default: /etc/named/ddns.key
+DYNAMIC_UPDATE_KEY_FILE=/etc/named/ddns.key
+# Update policy, rules separated by ';' and evaluated in order (first match wins, no match refuses):
+#   grant|deny <key> name|subdomain|wildcard <name> [types...]
+#   grant|deny <key> zonesub <zone> [types...]  (any name in the given zone)
+# Without types a rule covers all types except SOA, NS and DNSSEC records
+# Example: grant dhcp-updater subdomain example.com A AAAA; grant dhcp-updater subdomain 2.0.192.in-addr.arpa PTR; grant acme wildcard *.example.com TXT
+# Default: empty (all updates refused)
+DYNAMIC_UPDATE_POLICY=
 
 [Plugins]
 # BIND Plugin - Authoritative Domain Management, BIND Server Management, Forwarding Queries, Backup
@@ -243,7 +435,7 @@ BIND_ENABLED=true
 # DNS Rules Plugin - DNS query rules management (Reserved - feature not implemented yet)
 # Restart the service for changes to take effect
 DNS_RULES_ENABLED=false
-# Log Analysis Plugin - DNS query log analysis (Reserved - feature not implemented yet)
+# Log Analysis Plugin - Indexed query log store, query log search and CSV export API
 # Restart the service for changes to take effect
 LOG_ANALYSIS_ENABLED=false
 `
@@ -383,10 +575,18 @@ func setDefaultConfig() {
 	ensureSection("Cache")
 	ensureSection("Logging")
 	ensureSection("Security")
+	ensureSection("Events")
+	ensureSection("Metrics")
+	ensureSection("AnomalyDetection")
+	ensureSection("DynamicUpdate")
 	ensureSection("Plugins")
 
 	// 设置默认值
 	setDefault("Database", "DB_PATH", "steadydns.db")
+	setDefault("Database", "STATS_RAW_RETENTION_DAYS", "2")
+	setDefault("Database", "STATS_MINUTE_RETENTION_DAYS", "30")
+	setDefault("Database", "STATS_HOUR_RETENTION_DAYS", "365")
+	setDefault("Database", "STATS_DAY_RETENTION_DAYS", "1825")
 	setDefault("APIServer", "API_SERVER_PORT", "8080")
 	setDefault("APIServer", "API_SERVER_IP_ADDR", "0.0.0.0")
 	setDefault("APIServer", "API_SERVER_IPV6_ADDR", "::")
@@ -431,6 +631,8 @@ func setDefaultConfig() {
 	setDefault("BIND", "BIND_EXEC_STOP", "/usr/local/bind9/sbin/rndc -k /etc/named/rndc.key -s 127.0.0.1 -p 9530 stop")
 	setDefault("BIND", "BIND_CHECKCONF_PATH", "/usr/local/bind9/bin/named-checkconf")
 	setDefault("BIND", "BIND_CHECKZONE_PATH", "/usr/local/bind9/bin/named-checkzone")
+	setDefault("BIND", "DNSSEC_EXPIRY_WARNING_HOURS", "72")
+	setDefault("BIND", "ZONE_LINT_DISABLED_RULES", "")
 	setDefault("DNS", "DNS_CLIENT_WORKERS", "10000")
 	setDefault("DNS", "DNS_QUEUE_MULTIPLIER", "2")
 	setDefault("DNS", "DNS_PRIORITY_TIMEOUT_MS", "50")
@@ -440,12 +642,69 @@ func setDefaultConfig() {
 	setDefault("Logging", "QUERY_LOG_PATH", "log/")
 	setDefault("Logging", "QUERY_LOG_MAX_SIZE", "10")
 	setDefault("Logging", "QUERY_LOG_MAX_FILES", "10")
+	setDefault("Logging", "QUERY_LOG_FORMAT", "text")
+	setDefault("Logging", "QUERY_LOG_FIELDS", "")
+	setDefault("Logging", "QUERY_LOG_ANONYMIZE", "none")
+	setDefault("Logging", "QUERY_LOG_IPV4_PREFIX", "24")
+	setDefault("Logging", "QUERY_LOG_IPV6_PREFIX", "48")
+	setDefault("Logging", "QUERY_LOG_HASH_SALT", "")
+	setDefault("Logging", "DNSTAP_ENABLED", "false")
+	setDefault("Logging", "DNSTAP_OUTPUT", "unix:/var/run/steadydns/dnstap.sock")
+	setDefault("Logging", "DNSTAP_IDENTITY", "")
+	setDefault("Logging", "DNSTAP_MESSAGES", "client,forwarder")
+	setDefault("Logging", "DNSTAP_BUFFER_SIZE", "10000")
+	setDefault("Logging", "QUERY_LOG_STORE_PATH", "log/querylog.db")
+	setDefault("Logging", "QUERY_LOG_RETENTION_DAYS", "7")
+	setDefault("Logging", "QUERY_LOG_STORE_MAX_SIZE", "1024")
 	setDefault("Logging", "DNS_LOG_LEVEL", "DEBUG")
 	setDefault("Security", "DNS_RATE_LIMIT_PER_IP", "300")
 	setDefault("Security", "DNS_RATE_LIMIT_GLOBAL", "10000")
 	setDefault("Security", "DNS_BAN_DURATION", "5")
 	setDefault("Security", "DNS_MESSAGE_SIZE_LIMIT", "4096")
 	setDefault("Security", "DNS_VALIDATION_ENABLED", "true")
+	setDefault("Security", "DNS_0X20_ENABLED", "false")
+	// 事件与告警配置
+	setDefault("Events", "EVENTS_ENABLED", "true")
+	setDefault("Events", "SERVER_DOWN_ENABLED", "true")
+	setDefault("Events", "SERVER_UP_ENABLED", "true")
+	setDefault("Events", "GROUP_UNAVAILABLE_ENABLED", "true")
+	setDefault("Events", "GROUP_RECOVERED_ENABLED", "true")
+	setDefault("Events", "BIND_STOPPED_ENABLED", "true")
+	setDefault("Events", "BIND_RECOVERED_ENABLED", "true")
+	setDefault("Events", "RATE_LIMIT_BAN_ENABLED", "true")
+	setDefault("Events", "CACHE_PRESSURE_ENABLED", "true")
+	setDefault("Events", "DNS_ANOMALY_ENABLED", "true")
+	setDefault("Events", "DNSSEC_EXPIRING_ENABLED", "true")
+	setDefault("Events", "EVENT_COOLDOWN_SECONDS", "300")
+	setDefault("Events", "EVENT_RETENTION_DAYS", "30")
+	setDefault("Events", "WEBHOOK_TIMEOUT", "5")
+	setDefault("Events", "WEBHOOK_MAX_RETRIES", "3")
+	setDefault("Events", "WEBHOOK_RETRY_BACKOFF", "2")
+	setDefault("Events", "BIND_MONITOR_INTERVAL", "30")
+	setDefault("Events", "DNSSEC_MONITOR_INTERVAL", "3600")
+	// Prometheus指标配置
+	setDefault("Metrics", "METRICS_ENABLED", "false")
+	setDefault("Metrics", "METRICS_LISTEN", "")
+	setDefault("Metrics", "METRICS_AUTH_TOKEN", "")
+	setDefault("Metrics", "METRICS_BASIC_AUTH_USER", "")
+	setDefault("Metrics", "METRICS_BASIC_AUTH_PASSWORD", "")
+	setDefault("AnomalyDetection", "ANOMALY_DETECTION_ENABLED", "true")
+	setDefault("AnomalyDetection", "ANOMALY_WINDOW_SECONDS", "60")
+	setDefault("AnomalyDetection", "ANOMALY_ENTROPY_THRESHOLD", "4.0")
+	setDefault("AnomalyDetection", "ANOMALY_ENTROPY_MIN_LENGTH", "20")
+	setDefault("AnomalyDetection", "ANOMALY_QNAME_LENGTH_THRESHOLD", "100")
+	setDefault("AnomalyDetection", "ANOMALY_SUSPICIOUS_QUERY_THRESHOLD", "20")
+	setDefault("AnomalyDetection", "ANOMALY_TXT_NULL_THRESHOLD", "100")
+	setDefault("AnomalyDetection", "ANOMALY_UNIQUE_SUBDOMAIN_THRESHOLD", "200")
+	setDefault("AnomalyDetection", "ANOMALY_NXDOMAIN_RATIO", "0.5")
+	setDefault("AnomalyDetection", "ANOMALY_NXDOMAIN_MIN_QUERIES", "50")
+	setDefault("AnomalyDetection", "ANOMALY_ALLOWLIST", "")
+	setDefault("AnomalyDetection", "ANOMALY_QUARANTINE_ENABLED", "false")
+	setDefault("AnomalyDetection", "ANOMALY_QUARANTINE_MINUTES", "30")
+	// 动态更新配置
+	setDefault("DynamicUpdate", "DYNAMIC_UPDATE_ENABLED", "false")
+	setDefault("DynamicUpdate", "DYNAMIC_UPDATE_KEY_FILE", "/etc/named/ddns.key")
+	setDefault("DynamicUpdate", "DYNAMIC_UPDATE_POLICY", "")
 	// 插件配置
 	setDefault("Plugins", "BIND_ENABLED", "true")
 	// 预留插件配置（功能暂未实现）
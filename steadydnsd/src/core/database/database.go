@@ -172,25 +172,47 @@ func CheckConnection() error {
 	return err
 }
 
-// InitializeDatabase 初始化数据库，创建必要的表
-// 创建用户表、转发组表和DNS服务器表
-// 创建默认管理员用户和默认转发组
-func InitializeDatabase() error {
-	// 创建表 - 按依赖关系排序
-	tables := []interface{}{
-		&User{},            // 用户表
-		&ForwardGroup{},    // 转发组表
-		&DNSServer{},       // DNS服务器表
-		&QPSHistory{},      // QPS历史记录表
-		&ResourceHistory{}, // 资源使用历史记录表
-		&NetworkHistory{},  // 网络流量历史记录表
+// schemaTables 返回需要迁移的全部数据表模型，按依赖关系排序
+func schemaTables() []interface{} {
+	return []interface{}{
+		&User{},                    // 用户表
+		&ForwardGroup{},            // 转发组表
+		&DNSServer{},               // DNS服务器表
+		&QPSHistory{},              // QPS历史记录表
+		&ResourceHistory{},         // 资源使用历史记录表
+		&NetworkHistory{},          // 网络流量历史记录表
+		&ServerHealthHistory{},     // 上游服务器健康历史记录表
+		&QueryDimensionHistory{},   // 查询维度分钟聚合历史表
+		&TopNHistory{},             // 热门域名/客户端窗口快照表
+		&UniqueCountHistory{},      // 去重计数窗口快照表
+		&LatencyHistogramHistory{}, // 延迟直方图分钟历史表
+		&StatsRollup{},             // 统计汇总表（1分钟/1小时/1天）
+		&SystemEvent{},             // 系统事件表
+		&WebhookTarget{},           // Webhook告警目标表
+		&TSIGKey{},                 // TSIG密钥表
+		&ZoneSetting{},             // 区域管理设置表
 	}
+}
 
-	for _, table := range tables {
+// MigrateSchema 迁移数据表结构
+// 已存在的数据库不会执行InitializeDatabase，启动时调用此函数确保新增的表和字段存在
+func MigrateSchema() error {
+	for _, table := range schemaTables() {
 		if err := DB.AutoMigrate(table); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// InitializeDatabase 初始化数据库，创建必要的表
+// 创建用户表、转发组表和DNS服务器表
+// 创建默认管理员用户和默认转发组
+func InitializeDatabase() error {
+	// 创建表 - 按依赖关系排序
+	if err := MigrateSchema(); err != nil {
+		return err
+	}
 
 	// 创建默认管理员用户
 	defaultUser := &User{
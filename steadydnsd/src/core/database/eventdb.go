@@ -0,0 +1,209 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/eventdb.go
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SystemEvent 系统事件表
+// 记录上游状态变化、BIND停止、速率限制封禁、缓存压力等事件
+type SystemEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp time.Time `json:"timestamp" gorm:"index;not null"`
+	Type      string    `json:"type" gorm:"size:64;index;not null"` // 事件类型，如server_down
+	Severity  string    `json:"severity" gorm:"size:16;not null"`   // 严重级别：info/warning/critical
+	Source    string    `json:"source" gorm:"size:64"`              // 事件来源模块
+	Subject   string    `json:"subject" gorm:"size:255;index"`      // 事件对象，如服务器地址、转发组、客户端IP
+	Message   string    `json:"message" gorm:"type:text"`           // 事件描述
+	Details   string    `json:"details" gorm:"type:text"`           // 事件详情（JSON）
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (SystemEvent) TableName() string {
+	return "system_events"
+}
+
+// WebhookTarget Webhook告警目标表
+type WebhookTarget struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name       string    `json:"name" gorm:"size:64;uniqueIndex;not null"`
+	URL        string    `json:"url" gorm:"size:1024;not null"`
+	Format     string    `json:"format" gorm:"size:32;not null;default:generic"` // 消息格式：generic/slack/discord/dingtalk/wecom/feishu
+	EventTypes string    `json:"eventTypes" gorm:"type:text"`                    // 订阅的事件类型，逗号分隔，为空表示全部
+	Secret     string    `json:"secret,omitempty" gorm:"size:255"`               // generic格式的HMAC签名密钥
+	Enabled    bool      `json:"enabled" gorm:"not null"`                        // 不设数据库默认值，否则创建时false会被当作零值忽略
+	CreatedAt  time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (WebhookTarget) TableName() string {
+	return "webhook_targets"
+}
+
+// EventQuery 事件查询条件
+type EventQuery struct {
+	Type      string
+	Severity  string
+	Subject   string
+	StartTime time.Time
+	EndTime   time.Time
+	Page      int
+	PageSize  int
+}
+
+// SaveSystemEvent 保存系统事件
+func SaveSystemEvent(event *SystemEvent) error {
+	if err := DB.Create(event).Error; err != nil {
+		return fmt.Errorf("保存系统事件失败: %v", err)
+	}
+	return nil
+}
+
+// GetSystemEvents 按条件分页查询系统事件，按时间倒序排列
+func GetSystemEvents(query EventQuery) ([]SystemEvent, int64, error) {
+	var events []SystemEvent
+	var total int64
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = 20
+	}
+
+	tx := DB.Model(&SystemEvent{})
+	if query.Type != "" {
+		tx = tx.Where("type = ?", query.Type)
+	}
+	if query.Severity != "" {
+		tx = tx.Where("severity = ?", query.Severity)
+	}
+	if query.Subject != "" {
+		tx = tx.Where("subject = ?", query.Subject)
+	}
+	if !query.StartTime.IsZero() {
+		tx = tx.Where("timestamp >= ?", query.StartTime)
+	}
+	if !query.EndTime.IsZero() {
+		tx = tx.Where("timestamp <= ?", query.EndTime)
+	}
+
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计系统事件失败: %v", err)
+	}
+
+	offset := (query.Page - 1) * query.PageSize
+	if err := tx.Order("timestamp DESC, id DESC").Offset(offset).Limit(query.PageSize).Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询系统事件失败: %v", err)
+	}
+
+	return events, total, nil
+}
+
+// CleanOldSystemEvents 清理过期的系统事件
+func CleanOldSystemEvents(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := DB.Where("timestamp < ?", cutoff).Delete(&SystemEvent{})
+	if result.Error != nil {
+		return fmt.Errorf("清理系统事件失败: %v", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		GetLogManager().logger.Info("清理了 %d 条过期的系统事件", result.RowsAffected)
+	}
+
+	return nil
+}
+
+// GetWebhookTargets 获取所有Webhook目标
+func GetWebhookTargets() ([]WebhookTarget, error) {
+	var targets []WebhookTarget
+	if err := DB.Order("id ASC").Find(&targets).Error; err != nil {
+		return nil, fmt.Errorf("查询Webhook目标失败: %v", err)
+	}
+	return targets, nil
+}
+
+// GetEnabledWebhookTargets 获取所有启用的Webhook目标
+func GetEnabledWebhookTargets() ([]WebhookTarget, error) {
+	var targets []WebhookTarget
+	if err := DB.Where("enabled = ?", true).Order("id ASC").Find(&targets).Error; err != nil {
+		return nil, fmt.Errorf("查询Webhook目标失败: %v", err)
+	}
+	return targets, nil
+}
+
+// GetWebhookTargetByID 根据ID获取Webhook目标
+func GetWebhookTargetByID(id uint) (*WebhookTarget, error) {
+	var target WebhookTarget
+	if err := DB.First(&target, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("Webhook目标不存在")
+		}
+		return nil, fmt.Errorf("查询Webhook目标失败: %v", err)
+	}
+	return &target, nil
+}
+
+// CreateWebhookTarget 创建Webhook目标
+func CreateWebhookTarget(target *WebhookTarget) error {
+	var count int64
+	DB.Model(&WebhookTarget{}).Where("name = ?", target.Name).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("Webhook目标名称已存在")
+	}
+
+	if err := DB.Create(target).Error; err != nil {
+		return fmt.Errorf("创建Webhook目标失败: %v", err)
+	}
+	return nil
+}
+
+// UpdateWebhookTarget 更新Webhook目标
+func UpdateWebhookTarget(target *WebhookTarget) error {
+	var count int64
+	DB.Model(&WebhookTarget{}).Where("name = ? AND id <> ?", target.Name, target.ID).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("Webhook目标名称已存在")
+	}
+
+	if err := DB.Save(target).Error; err != nil {
+		return fmt.Errorf("更新Webhook目标失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteWebhookTarget 删除Webhook目标
+func DeleteWebhookTarget(id uint) error {
+	result := DB.Delete(&WebhookTarget{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除Webhook目标失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("Webhook目标不存在")
+	}
+	return nil
+}
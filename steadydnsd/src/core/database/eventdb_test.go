@@ -0,0 +1,168 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/eventdb_test.go
+// 系统事件与Webhook目标数据库操作测试
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupEventTestDB 创建测试用的内存数据库
+func setupEventTestDB(t *testing.T) func() {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+
+	DB = db
+
+	if err := DB.AutoMigrate(&SystemEvent{}, &WebhookTarget{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	return func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}
+}
+
+// TestSystemEvents 测试系统事件保存、查询和清理
+func TestSystemEvents(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	events := []SystemEvent{
+		{Timestamp: now.Add(-40 * 24 * time.Hour), Type: "server_down", Severity: "warning", Subject: "8.8.8.8:53"},
+		{Timestamp: now.Add(-2 * time.Minute), Type: "server_down", Severity: "warning", Subject: "8.8.8.8:53"},
+		{Timestamp: now.Add(-1 * time.Minute), Type: "server_up", Severity: "info", Subject: "8.8.8.8:53"},
+		{Timestamp: now, Type: "bind_stopped", Severity: "critical", Subject: "named"},
+	}
+
+	t.Run("保存系统事件", func(t *testing.T) {
+		for i := range events {
+			if err := SaveSystemEvent(&events[i]); err != nil {
+				t.Fatalf("SaveSystemEvent() error = %v", err)
+			}
+			if events[i].ID == 0 {
+				t.Errorf("保存后ID未设置")
+			}
+		}
+	})
+
+	t.Run("按条件查询", func(t *testing.T) {
+		result, total, err := GetSystemEvents(EventQuery{Type: "server_down"})
+		if err != nil {
+			t.Fatalf("GetSystemEvents() error = %v", err)
+		}
+		if total != 2 || len(result) != 2 {
+			t.Errorf("total = %d, len = %d, want 2", total, len(result))
+		}
+
+		result, total, err = GetSystemEvents(EventQuery{StartTime: now.Add(-time.Hour), Page: 1, PageSize: 2})
+		if err != nil {
+			t.Fatalf("GetSystemEvents() error = %v", err)
+		}
+		if total != 3 || len(result) != 2 {
+			t.Errorf("total = %d, len = %d, want 3/2", total, len(result))
+		}
+		if result[0].Type != "bind_stopped" {
+			t.Errorf("结果未按时间倒序排列: %s", result[0].Type)
+		}
+	})
+
+	t.Run("清理过期事件", func(t *testing.T) {
+		if err := CleanOldSystemEvents(30); err != nil {
+			t.Fatalf("CleanOldSystemEvents() error = %v", err)
+		}
+		_, total, _ := GetSystemEvents(EventQuery{})
+		if total != 3 {
+			t.Errorf("total = %d, want 3", total)
+		}
+	})
+}
+
+// TestWebhookTargetCRUD 测试Webhook目标CRUD操作
+func TestWebhookTargetCRUD(t *testing.T) {
+	cleanup := setupEventTestDB(t)
+	defer cleanup()
+
+	target := &WebhookTarget{Name: "ops", URL: "https://example.com/hook", Format: "generic", Enabled: true}
+
+	t.Run("创建Webhook目标", func(t *testing.T) {
+		if err := CreateWebhookTarget(target); err != nil {
+			t.Fatalf("CreateWebhookTarget() error = %v", err)
+		}
+		dup := &WebhookTarget{Name: "ops", URL: "https://example.com/other", Format: "generic"}
+		if err := CreateWebhookTarget(dup); err == nil {
+			t.Errorf("重复名称应返回错误")
+		}
+	})
+
+	t.Run("更新与启用过滤", func(t *testing.T) {
+		disabled := &WebhookTarget{Name: "chat", URL: "https://example.com/chat", Format: "slack", Enabled: true}
+		if err := CreateWebhookTarget(disabled); err != nil {
+			t.Fatalf("CreateWebhookTarget() error = %v", err)
+		}
+		disabled.Enabled = false
+		if err := UpdateWebhookTarget(disabled); err != nil {
+			t.Fatalf("UpdateWebhookTarget() error = %v", err)
+		}
+
+		enabled, err := GetEnabledWebhookTargets()
+		if err != nil {
+			t.Fatalf("GetEnabledWebhookTargets() error = %v", err)
+		}
+		if len(enabled) != 1 || enabled[0].Name != "ops" {
+			t.Errorf("启用的目标不正确: %+v", enabled)
+		}
+	})
+
+	t.Run("创建禁用的Webhook目标", func(t *testing.T) {
+		off := &WebhookTarget{Name: "off", URL: "https://example.com/off", Format: "generic", Enabled: false}
+		if err := CreateWebhookTarget(off); err != nil {
+			t.Fatalf("CreateWebhookTarget() error = %v", err)
+		}
+		got, err := GetWebhookTargetByID(off.ID)
+		if err != nil {
+			t.Fatalf("GetWebhookTargetByID() error = %v", err)
+		}
+		if got.Enabled {
+			t.Errorf("创建时Enabled为false的目标被保存为启用")
+		}
+	})
+
+	t.Run("删除Webhook目标", func(t *testing.T) {
+		if err := DeleteWebhookTarget(target.ID); err != nil {
+			t.Fatalf("DeleteWebhookTarget() error = %v", err)
+		}
+		if _, err := GetWebhookTargetByID(target.ID); err == nil {
+			t.Errorf("删除后仍能查询到目标")
+		}
+		if err := DeleteWebhookTarget(target.ID); err == nil {
+			t.Errorf("删除不存在的目标应返回错误")
+		}
+	})
+}
@@ -0,0 +1,318 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/forwardgroupconvert.go
+// 将dnsmasq和unbound的转发配置转换为转发组文档
+
+package database
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// forwardDocumentBuilder 按域名聚合服务器，保持域名首次出现的顺序
+type forwardDocumentBuilder struct {
+	order    []string
+	groups   map[string]*ForwardGroupEntry
+	seen     map[string]bool // 域名|地址:端口，用于去重
+	warnings []string
+}
+
+// newForwardDocumentBuilder 创建文档构建器
+func newForwardDocumentBuilder() *forwardDocumentBuilder {
+	return &forwardDocumentBuilder{
+		groups: make(map[string]*ForwardGroupEntry),
+		seen:   make(map[string]bool),
+	}
+}
+
+// group 获取或创建域名对应的转发组
+func (b *forwardDocumentBuilder) group(domain string) *ForwardGroupEntry {
+	domain = NormalizeForwardDomain(domain)
+	entry, ok := b.groups[domain]
+	if !ok {
+		entry = &ForwardGroupEntry{Domain: domain, Servers: []ForwardServerEntry{}}
+		b.groups[domain] = entry
+		b.order = append(b.order, domain)
+	}
+	return entry
+}
+
+// addServer 向转发组添加服务器，重复的地址:端口被忽略
+func (b *forwardDocumentBuilder) addServer(domain string, server ForwardServerEntry) {
+	entry := b.group(domain)
+	key := entry.Domain + "|" + net.JoinHostPort(server.Address, strconv.Itoa(server.Port))
+	if b.seen[key] {
+		return
+	}
+	b.seen[key] = true
+	entry.Servers = append(entry.Servers, server)
+}
+
+// warnf 记录转换警告
+func (b *forwardDocumentBuilder) warnf(lineNo int, format string, args ...interface{}) {
+	b.warnings = append(b.warnings, fmt.Sprintf("第%d行: %s", lineNo, fmt.Sprintf(format, args...)))
+}
+
+// document 生成转发组文档
+func (b *forwardDocumentBuilder) document() *ForwardGroupDocument {
+	doc := &ForwardGroupDocument{
+		Version: ForwardGroupDocumentVersion,
+		Groups:  make([]ForwardGroupEntry, 0, len(b.order)),
+	}
+	for _, domain := range b.order {
+		doc.Groups = append(doc.Groups, *b.groups[domain])
+	}
+	return doc
+}
+
+// ParseDnsmasqConfig 解析dnsmasq配置中的server=行
+//
+// 支持的格式：
+//   - server=/example.com/10.0.0.1
+//   - server=/a.com/b.com/10.0.0.1#5353
+//   - server=10.0.0.1（无域名，导入到默认组）
+//   - server=/example.com/10.0.0.1@eth0 或 @192.168.1.2#源端口（源地址）
+//
+// 返回:
+//   - *ForwardGroupDocument: 转换后的文档
+//   - []string: 被忽略的配置项说明
+//   - error: 没有可导入的转发配置时返回错误
+func ParseDnsmasqConfig(data string) (*ForwardGroupDocument, []string, error) {
+	b := newForwardDocumentBuilder()
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "--")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if key != "server" {
+			if key == "rev-server" || key == "local" {
+				b.warnf(lineNo, "不支持的配置项 %s，已忽略", key)
+			}
+			continue
+		}
+
+		domains := []string{""}
+		target := value
+		if strings.HasPrefix(value, "/") {
+			idx := strings.LastIndex(value, "/")
+			domains = strings.Split(strings.Trim(value[:idx], "/"), "/")
+			target = value[idx+1:]
+		}
+
+		if target == "" || target == "#" {
+			b.warnf(lineNo, "仅本地解析的域名 %s 没有上游服务器，已忽略", strings.Join(domains, ","))
+			continue
+		}
+
+		server, err := parseDnsmasqTarget(target)
+		if err != nil {
+			b.warnf(lineNo, "%v，已忽略", err)
+			continue
+		}
+
+		for _, domain := range domains {
+			if strings.Contains(domain, "*") {
+				b.warnf(lineNo, "不支持通配符域名 %s，已忽略", domain)
+				continue
+			}
+			b.addServer(domain, server)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("读取dnsmasq配置失败: %v", err)
+	}
+	if len(b.order) == 0 {
+		return nil, b.warnings, fmt.Errorf("dnsmasq配置中没有可导入的server=配置")
+	}
+
+	return b.document(), b.warnings, nil
+}
+
+// parseDnsmasqTarget 解析dnsmasq上游地址：ip[#port][@source[#port]]
+func parseDnsmasqTarget(target string) (ForwardServerEntry, error) {
+	server := ForwardServerEntry{Port: 53, Priority: 1}
+
+	addrPart, source, _ := strings.Cut(target, "@")
+	if source != "" {
+		// 源地址可能带源端口（@ip#port），源端口由系统随机分配，忽略
+		source, _, _ = strings.Cut(source, "#")
+		server.SourceAddress = source
+	}
+
+	host, portStr, hasPort := strings.Cut(addrPart, "#")
+	if net.ParseIP(host) == nil {
+		return server, fmt.Errorf("无效的上游地址 %s", addrPart)
+	}
+	server.Address = host
+
+	if hasPort {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return server, fmt.Errorf("无效的端口 %s", portStr)
+		}
+		server.Port = port
+	}
+
+	return server, nil
+}
+
+// ParseUnboundConfig 解析unbound配置中的forward-zone块
+//
+// 支持name和forward-addr（ip[@port][#tls-name]），forward-host需要域名解析，
+// 以及DoT上游（forward-tls-upstream）均不受支持并记录警告。
+//
+// 返回:
+//   - *ForwardGroupDocument: 转换后的文档
+//   - []string: 被忽略的配置项说明
+//   - error: 没有可导入的转发配置时返回错误
+func ParseUnboundConfig(data string) (*ForwardGroupDocument, []string, error) {
+	b := newForwardDocumentBuilder()
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNo := 0
+
+	inZone := false
+	zoneName := ""
+	zoneLine := 0
+	var zoneServers []ForwardServerEntry
+
+	flush := func() {
+		if !inZone {
+			return
+		}
+		if zoneName == "" {
+			b.warnf(zoneLine, "forward-zone缺少name，已忽略")
+		} else if len(zoneServers) == 0 {
+			b.warnf(zoneLine, "forward-zone %s 没有forward-addr，已忽略", zoneName)
+		} else {
+			for _, server := range zoneServers {
+				b.addServer(zoneName, server)
+			}
+		}
+		inZone = false
+		zoneName = ""
+		zoneServers = nil
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		line := stripUnboundComment(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		// 新的顶层子句（如server:、forward-zone:）结束当前块
+		if value == "" && !strings.Contains(key, " ") {
+			flush()
+			if key == "forward-zone" {
+				inZone = true
+				zoneLine = lineNo
+			}
+			continue
+		}
+
+		if !inZone {
+			continue
+		}
+
+		switch key {
+		case "name":
+			zoneName = value
+		case "forward-addr":
+			server, err := parseUnboundAddr(value)
+			if err != nil {
+				b.warnf(lineNo, "%v，已忽略", err)
+				continue
+			}
+			zoneServers = append(zoneServers, server)
+		case "forward-host":
+			b.warnf(lineNo, "不支持forward-host %s，请改用forward-addr", value)
+		case "forward-tls-upstream":
+			if value == "yes" {
+				b.warnf(lineNo, "不支持DNS over TLS上游，已按普通DNS导入")
+			}
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("读取unbound配置失败: %v", err)
+	}
+	if len(b.order) == 0 {
+		return nil, b.warnings, fmt.Errorf("unbound配置中没有可导入的forward-zone")
+	}
+
+	return b.document(), b.warnings, nil
+}
+
+// parseUnboundAddr 解析unbound上游地址：ip[@port][#tls-name]
+func parseUnboundAddr(value string) (ForwardServerEntry, error) {
+	server := ForwardServerEntry{Port: 53, Priority: 1}
+
+	addr, _, _ := strings.Cut(value, "#")
+	host, portStr, hasPort := strings.Cut(addr, "@")
+	if net.ParseIP(host) == nil {
+		return server, fmt.Errorf("无效的上游地址 %s", value)
+	}
+	server.Address = host
+
+	if hasPort {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return server, fmt.Errorf("无效的端口 %s", portStr)
+		}
+		server.Port = port
+	}
+
+	return server, nil
+}
+
+// stripUnboundComment 去除unbound配置行的注释和首尾空白
+// forward-addr中的#用于TLS认证名，只有行首或空白后的#才视为注释
+func stripUnboundComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(line)
+}
@@ -29,26 +29,28 @@ import (
 
 // ForwardGroup 转发组模型
 type ForwardGroup struct {
-	ID          uint        `json:"id" gorm:"primaryKey"`
-	Domain      string      `json:"domain" gorm:"size:255;not null;unique"` // 转发组域名，长度0-255
-	Description string      `json:"description" gorm:"size:65535"`          // 描述，长度0-65535
-	Enable      bool        `json:"enable" gorm:"default:true"`             // 是否启用，默认启用
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
-	Servers     []DNSServer `json:"servers" gorm:"foreignKey:GroupID"` // 关联的DNS服务器
+	ID            uint        `json:"id" gorm:"primaryKey"`
+	Domain        string      `json:"domain" gorm:"size:255;not null;unique"` // 转发组域名，长度0-255
+	Description   string      `json:"description" gorm:"size:65535"`          // 描述，长度0-65535
+	Enable        bool        `json:"enable" gorm:"default:true"`             // 是否启用，默认启用
+	SourceAddress string      `json:"source_address" gorm:"size:64"`          // 出站查询源地址（IP或网卡名），为空时由系统选择
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	Servers       []DNSServer `json:"servers" gorm:"foreignKey:GroupID"` // 关联的DNS服务器
 }
 
 // DNSServer DNS服务器模型
 type DNSServer struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	GroupID     uint      `json:"group_id" gorm:"index"`         // 关联的转发组ID
-	Address     string    `json:"address" gorm:"not null"`       // DNS服务器地址，支持IPv4/IPv6
-	Port        int       `json:"port" gorm:"default:53"`        // 端口，默认53
-	Description string    `json:"description" gorm:"size:65535"` // 描述，长度0-65535
-	QueueIndex  int       `json:"queue_index"`                   // 队列序号
-	Priority    int       `json:"priority" gorm:"default:1"`     // 优先级 (1-3)
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	GroupID       uint      `json:"group_id" gorm:"index"`         // 关联的转发组ID
+	Address       string    `json:"address" gorm:"not null"`       // DNS服务器地址，支持IPv4/IPv6
+	Port          int       `json:"port" gorm:"default:53"`        // 端口，默认53
+	Description   string    `json:"description" gorm:"size:65535"` // 描述，长度0-65535
+	QueueIndex    int       `json:"queue_index"`                   // 队列序号
+	Priority      int       `json:"priority" gorm:"default:1"`     // 优先级 (1-3)
+	SourceAddress string    `json:"source_address" gorm:"size:64"` // 出站查询源地址（IP或网卡名），优先于转发组配置
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // GetForwardGroups 获取所有转发组
@@ -134,6 +136,7 @@ func UpdateForwardGroup(group *ForwardGroup) error {
 	// 更新转发组基本信息
 	updateData := make(map[string]interface{})
 	updateData["enable"] = group.Enable
+	updateData["source_address"] = group.SourceAddress
 
 	// 只有非默认组允许更新域名和描述
 	if group.ID != 1 {
@@ -254,6 +257,10 @@ func ValidateForwardGroupDB(group *ForwardGroup) error {
 		}
 	}
 
+	if err := ValidateSourceAddress(group.SourceAddress); err != nil {
+		return err
+	}
+
 	// 检查是否有重复的服务器地址:端口组合
 	serverMap := make(map[string]bool)
 	for _, server := range group.Servers {
@@ -294,6 +301,39 @@ func ValidateDNSServerDB(server *DNSServer) error {
 		return fmt.Errorf("优先级必须在1-3之间")
 	}
 
+	if err := ValidateSourceAddress(server.SourceAddress); err != nil {
+		return err
+	}
+
+	// 源地址为IP时必须与服务器地址属于同一地址族
+	if sourceIP := net.ParseIP(server.SourceAddress); sourceIP != nil {
+		if (sourceIP.To4() == nil) != (ip.To4() == nil) {
+			return fmt.Errorf("源地址 %s 与服务器地址 %s 的地址族不一致", server.SourceAddress, server.Address)
+		}
+	}
+
+	return nil
+}
+
+// ValidateSourceAddress 验证出站查询源地址
+// 允许为空、IPv4/IPv6地址或网卡名（1-15个字符，仅包含字母、数字和 _ . : @ -）
+func ValidateSourceAddress(source string) error {
+	if source == "" || net.ParseIP(source) != nil {
+		return nil
+	}
+
+	if len(source) > 15 {
+		return fmt.Errorf("无效的源地址: %s", source)
+	}
+	for _, c := range source {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '_' || c == '.' || c == ':' || c == '@' || c == '-':
+		default:
+			return fmt.Errorf("无效的源地址: %s", source)
+		}
+	}
+
 	return nil
 }
 
@@ -370,6 +410,11 @@ func UpdateDNSServer(server *DNSServer) error {
 		return fmt.Errorf("更新服务器失败: %v", err)
 	}
 
+	// 源地址允许清空，结构体更新会忽略零值，需要单独更新
+	if err := DB.Model(&existingServer).Update("source_address", server.SourceAddress).Error; err != nil {
+		return fmt.Errorf("更新服务器源地址失败: %v", err)
+	}
+
 	return nil
 }
 
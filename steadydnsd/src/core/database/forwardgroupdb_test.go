@@ -67,6 +67,10 @@ func TestValidateDNSServerDB(t *testing.T) {
 		{"优先级-过大", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 4}, true, "优先级必须在1-3之间"},
 		{"有效优先级边界-1", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 1}, false, ""},
 		{"有效优先级边界-3", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 3}, false, ""},
+		{"有效源地址-IP", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 1, SourceAddress: "10.0.0.2"}, false, ""},
+		{"有效源地址-网卡", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 1, SourceAddress: "eth0.100"}, false, ""},
+		{"源地址族不一致", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 1, SourceAddress: "2001:db8::1"}, true, "地址族不一致"},
+		{"无效源地址", &DNSServer{Address: "192.168.1.1", Port: 53, Priority: 1, SourceAddress: "bad name!"}, true, "无效的源地址"},
 	}
 
 	for _, tt := range tests {
@@ -96,6 +100,7 @@ func TestValidateForwardGroupDB(t *testing.T) {
 		{"超长域名", &ForwardGroup{Domain: strings.Repeat("a", 256), Description: "Test", Servers: []DNSServer{}}, true, "组域名长度必须在1-255之间"},
 		{"ID=1跳过验证", &ForwardGroup{ID: 1, Domain: "", Description: "", Servers: []DNSServer{}}, false, ""},
 		{"无效服务器", &ForwardGroup{Domain: "example.com", Servers: []DNSServer{{Address: "", Port: 53, Priority: 1}}}, true, "服务器配置错误"},
+		{"无效组源地址", &ForwardGroup{Domain: "example.com", SourceAddress: "very-long-interface-name"}, true, "无效的源地址"},
 		{"重复服务器", &ForwardGroup{Domain: "example.com", Servers: []DNSServer{{Address: "192.168.1.1", Port: 53, Priority: 1}, {Address: "192.168.1.1", Port: 53, Priority: 2}}}, true, "存在重复的服务器地址和端口"},
 	}
 
@@ -0,0 +1,468 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/forwardgroupio.go
+// 转发组导入导出：版本化文档、导入差异计划和事务性应用
+
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// ForwardGroupDocumentVersion 当前转发组文档版本
+const ForwardGroupDocumentVersion = 1
+
+// DefaultForwardGroupDomain 默认转发组（ID=1）的域名
+const DefaultForwardGroupDomain = "Default"
+
+// 导入模式
+const (
+	ImportModeMerge   = "merge"   // 合并：新增或更新文档中的转发组，保留其他转发组
+	ImportModeReplace = "replace" // 替换：额外删除文档中不存在的转发组（默认组除外）
+)
+
+// 导入计划中的操作类型
+const (
+	ImportActionCreate    = "create"
+	ImportActionUpdate    = "update"
+	ImportActionDelete    = "delete"
+	ImportActionUnchanged = "unchanged"
+)
+
+// ForwardGroupDocument 转发组导入导出文档
+type ForwardGroupDocument struct {
+	Version    int                 `json:"version" yaml:"version"`
+	ExportedAt time.Time           `json:"exported_at,omitempty" yaml:"exported_at,omitempty"`
+	Groups     []ForwardGroupEntry `json:"groups" yaml:"groups"`
+}
+
+// ForwardGroupEntry 文档中的转发组
+type ForwardGroupEntry struct {
+	Domain        string               `json:"domain" yaml:"domain"`
+	Description   string               `json:"description,omitempty" yaml:"description,omitempty"`
+	Enable        *bool                `json:"enable,omitempty" yaml:"enable,omitempty"` // 未设置时默认启用
+	SourceAddress string               `json:"source_address,omitempty" yaml:"source_address,omitempty"`
+	Servers       []ForwardServerEntry `json:"servers" yaml:"servers"`
+}
+
+// ForwardServerEntry 文档中的DNS服务器
+type ForwardServerEntry struct {
+	Address       string `json:"address" yaml:"address"`
+	Port          int    `json:"port,omitempty" yaml:"port,omitempty"`         // 未设置时为53
+	Priority      int    `json:"priority,omitempty" yaml:"priority,omitempty"` // 未设置时为1
+	Description   string `json:"description,omitempty" yaml:"description,omitempty"`
+	SourceAddress string `json:"source_address,omitempty" yaml:"source_address,omitempty"`
+}
+
+// ForwardGroupImportChange 单个转发组的导入变更
+type ForwardGroupImportChange struct {
+	Domain  string   `json:"domain"`
+	Action  string   `json:"action"`
+	Details []string `json:"details,omitempty"` // 字段和服务器级别的差异说明
+}
+
+// ForwardGroupImportPlan 导入计划（差异）
+type ForwardGroupImportPlan struct {
+	Mode      string                     `json:"mode"`
+	Changes   []ForwardGroupImportChange `json:"changes"`
+	Created   int                        `json:"created"`
+	Updated   int                        `json:"updated"`
+	Deleted   int                        `json:"deleted"`
+	Unchanged int                        `json:"unchanged"`
+}
+
+// ExportForwardGroups 导出所有转发组及其服务器
+func ExportForwardGroups() (*ForwardGroupDocument, error) {
+	groups, err := GetForwardGroups()
+	if err != nil {
+		return nil, fmt.Errorf("获取转发组失败: %v", err)
+	}
+
+	doc := &ForwardGroupDocument{
+		Version:    ForwardGroupDocumentVersion,
+		ExportedAt: time.Now(),
+		Groups:     make([]ForwardGroupEntry, 0, len(groups)),
+	}
+
+	for _, group := range groups {
+		enable := group.Enable
+		entry := ForwardGroupEntry{
+			Domain:        group.Domain,
+			Description:   group.Description,
+			Enable:        &enable,
+			SourceAddress: group.SourceAddress,
+			Servers:       make([]ForwardServerEntry, 0, len(group.Servers)),
+		}
+		for _, server := range sortedServers(group.Servers) {
+			entry.Servers = append(entry.Servers, ForwardServerEntry{
+				Address:       server.Address,
+				Port:          server.Port,
+				Priority:      server.Priority,
+				Description:   server.Description,
+				SourceAddress: server.SourceAddress,
+			})
+		}
+		doc.Groups = append(doc.Groups, entry)
+	}
+
+	return doc, nil
+}
+
+// MarshalForwardGroupDocument 按格式（yaml或json）序列化转发组文档
+func MarshalForwardGroupDocument(doc *ForwardGroupDocument, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(doc, "", "  ")
+	case "yaml", "yml":
+		return yaml.Marshal(doc)
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// UnmarshalForwardGroupDocument 按格式（yaml或json）解析转发组文档并检查版本
+func UnmarshalForwardGroupDocument(data []byte, format string) (*ForwardGroupDocument, error) {
+	var doc ForwardGroupDocument
+
+	switch format {
+	case "json":
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析JSON文档失败: %v", err)
+		}
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("解析YAML文档失败: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的文档格式: %s", format)
+	}
+
+	if doc.Version < 1 || doc.Version > ForwardGroupDocumentVersion {
+		return nil, fmt.Errorf("不支持的文档版本: %d，当前支持的版本: %d", doc.Version, ForwardGroupDocumentVersion)
+	}
+
+	return &doc, nil
+}
+
+// PlanForwardGroupImport 生成导入计划，不修改数据库（dry-run）
+func PlanForwardGroupImport(doc *ForwardGroupDocument, mode string) (*ForwardGroupImportPlan, error) {
+	groups, err := normalizeImportDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	return planForwardGroupImport(DB, groups, mode)
+}
+
+// ApplyForwardGroupImport 在单个事务中应用导入，任一转发组失败时全部回滚
+func ApplyForwardGroupImport(doc *ForwardGroupDocument, mode string) (*ForwardGroupImportPlan, error) {
+	groups, err := normalizeImportDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *ForwardGroupImportPlan
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		plan, err = planForwardGroupImport(tx, groups, mode)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range plan.Changes {
+			var err error
+			switch change.Action {
+			case ImportActionCreate:
+				// enable字段带默认值，创建时零值会被默认值覆盖，需要单独更新
+				group := groups[change.Domain]
+				enable := group.Enable
+				if err = tx.Create(&group).Error; err == nil && !enable {
+					err = tx.Model(&group).Update("enable", false).Error
+				}
+			case ImportActionUpdate:
+				err = applyImportedGroup(tx, groups[change.Domain])
+			case ImportActionDelete:
+				err = deleteImportedGroup(tx, change.Domain)
+			}
+			if err != nil {
+				return fmt.Errorf("导入转发组 %s 失败: %v", change.Domain, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// normalizeImportDocument 将文档转换为转发组模型并校验
+//
+// 返回:
+//   - map[string]ForwardGroup: 域名 -> 转发组
+//   - error: 文档不合法时返回错误
+func normalizeImportDocument(doc *ForwardGroupDocument) (map[string]ForwardGroup, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("导入文档为空")
+	}
+
+	groups := make(map[string]ForwardGroup, len(doc.Groups))
+	for _, entry := range doc.Groups {
+		domain := NormalizeForwardDomain(entry.Domain)
+		if _, exists := groups[domain]; exists {
+			return nil, fmt.Errorf("文档中存在重复的转发组: %s", domain)
+		}
+
+		group := ForwardGroup{
+			Domain:        domain,
+			Description:   entry.Description,
+			Enable:        entry.Enable == nil || *entry.Enable,
+			SourceAddress: entry.SourceAddress,
+			Servers:       make([]DNSServer, 0, len(entry.Servers)),
+		}
+
+		queueIndex := make(map[int]int)
+		for _, s := range entry.Servers {
+			server := DNSServer{
+				Address:       s.Address,
+				Port:          s.Port,
+				Priority:      s.Priority,
+				Description:   s.Description,
+				SourceAddress: s.SourceAddress,
+			}
+			if server.Port == 0 {
+				server.Port = 53
+			}
+			if server.Priority == 0 {
+				server.Priority = 1
+			}
+			queueIndex[server.Priority]++
+			server.QueueIndex = queueIndex[server.Priority]
+			group.Servers = append(group.Servers, server)
+		}
+
+		// 默认组跳过域名和描述校验
+		validateTarget := group
+		if domain == DefaultForwardGroupDomain {
+			validateTarget.ID = 1
+		}
+		if err := ValidateForwardGroupDB(&validateTarget); err != nil {
+			return nil, fmt.Errorf("转发组 %s 配置错误: %v", domain, err)
+		}
+
+		groups[domain] = group
+	}
+
+	return groups, nil
+}
+
+// NormalizeForwardDomain 规范化转发组域名：去除首尾空白和点号并转为小写，根域映射为默认组
+func NormalizeForwardDomain(domain string) string {
+	domain = strings.TrimSpace(domain)
+	if domain == "" || domain == "." || strings.EqualFold(domain, DefaultForwardGroupDomain) {
+		return DefaultForwardGroupDomain
+	}
+	return strings.ToLower(strings.Trim(domain, "."))
+}
+
+// planForwardGroupImport 对比数据库与导入内容生成导入计划
+func planForwardGroupImport(db *gorm.DB, groups map[string]ForwardGroup, mode string) (*ForwardGroupImportPlan, error) {
+	if mode == "" {
+		mode = ImportModeMerge
+	}
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		return nil, fmt.Errorf("无效的导入模式: %s，可选值：%s, %s", mode, ImportModeMerge, ImportModeReplace)
+	}
+
+	var existing []ForwardGroup
+	if err := db.Preload("Servers").Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("获取现有转发组失败: %v", err)
+	}
+	existingByDomain := make(map[string]ForwardGroup, len(existing))
+	for _, group := range existing {
+		existingByDomain[group.Domain] = group
+	}
+
+	plan := &ForwardGroupImportPlan{Mode: mode, Changes: []ForwardGroupImportChange{}}
+
+	domains := make([]string, 0, len(groups))
+	for domain := range groups {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		group := groups[domain]
+		current, exists := existingByDomain[domain]
+		if !exists {
+			if domain == DefaultForwardGroupDomain {
+				return nil, fmt.Errorf("默认转发组不存在，请先启动服务完成初始化")
+			}
+			details := []string{}
+			for _, server := range group.Servers {
+				details = append(details, "+ 服务器 "+describeServer(server))
+			}
+			plan.Changes = append(plan.Changes, ForwardGroupImportChange{Domain: domain, Action: ImportActionCreate, Details: details})
+			plan.Created++
+			continue
+		}
+
+		details := diffForwardGroup(current, group)
+		if len(details) == 0 {
+			plan.Changes = append(plan.Changes, ForwardGroupImportChange{Domain: domain, Action: ImportActionUnchanged})
+			plan.Unchanged++
+			continue
+		}
+		plan.Changes = append(plan.Changes, ForwardGroupImportChange{Domain: domain, Action: ImportActionUpdate, Details: details})
+		plan.Updated++
+	}
+
+	if mode == ImportModeReplace {
+		for _, current := range existing {
+			if _, ok := groups[current.Domain]; ok || current.ID == 1 {
+				continue
+			}
+			plan.Changes = append(plan.Changes, ForwardGroupImportChange{Domain: current.Domain, Action: ImportActionDelete})
+			plan.Deleted++
+		}
+	}
+
+	return plan, nil
+}
+
+// diffForwardGroup 生成转发组字段和服务器列表的差异说明
+func diffForwardGroup(current, target ForwardGroup) []string {
+	details := []string{}
+
+	if current.ID != 1 && current.Description != target.Description {
+		details = append(details, fmt.Sprintf("描述: %q -> %q", current.Description, target.Description))
+	}
+	if current.Enable != target.Enable {
+		details = append(details, fmt.Sprintf("启用: %v -> %v", current.Enable, target.Enable))
+	}
+	if current.SourceAddress != target.SourceAddress {
+		details = append(details, fmt.Sprintf("源地址: %q -> %q", current.SourceAddress, target.SourceAddress))
+	}
+
+	currentServers := make(map[string]DNSServer, len(current.Servers))
+	for _, server := range current.Servers {
+		currentServers[serverKey(server)] = server
+	}
+	targetKeys := make(map[string]bool, len(target.Servers))
+
+	for _, server := range target.Servers {
+		key := serverKey(server)
+		targetKeys[key] = true
+		old, ok := currentServers[key]
+		if !ok {
+			details = append(details, "+ 服务器 "+describeServer(server))
+			continue
+		}
+		if old.Priority != server.Priority || old.Description != server.Description || old.SourceAddress != server.SourceAddress {
+			details = append(details, fmt.Sprintf("~ 服务器 %s -> %s", describeServer(old), describeServer(server)))
+		}
+	}
+
+	for _, server := range sortedServers(current.Servers) {
+		if !targetKeys[serverKey(server)] {
+			details = append(details, "- 服务器 "+describeServer(server))
+		}
+	}
+
+	return details
+}
+
+// applyImportedGroup 更新已存在的转发组并替换其服务器列表
+func applyImportedGroup(tx *gorm.DB, group ForwardGroup) error {
+	var existing ForwardGroup
+	if err := tx.Where("domain = ?", group.Domain).First(&existing).Error; err != nil {
+		return err
+	}
+
+	updateData := map[string]interface{}{
+		"enable":         group.Enable,
+		"source_address": group.SourceAddress,
+	}
+	// 默认组的描述不可修改
+	if existing.ID != 1 {
+		updateData["description"] = group.Description
+	}
+	if err := tx.Model(&existing).Updates(updateData).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("group_id = ?", existing.ID).Delete(&DNSServer{}).Error; err != nil {
+		return err
+	}
+	for i := range group.Servers {
+		group.Servers[i].GroupID = existing.ID
+		if err := tx.Create(&group.Servers[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteImportedGroup 删除转发组及其服务器
+func deleteImportedGroup(tx *gorm.DB, domain string) error {
+	var existing ForwardGroup
+	if err := tx.Where("domain = ?", domain).First(&existing).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("group_id = ?", existing.ID).Delete(&DNSServer{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&existing).Error
+}
+
+// serverKey 服务器唯一标识（地址:端口）
+func serverKey(server DNSServer) string {
+	return net.JoinHostPort(server.Address, strconv.Itoa(server.Port))
+}
+
+// describeServer 服务器的可读描述
+func describeServer(server DNSServer) string {
+	desc := fmt.Sprintf("%s (优先级 %d", serverKey(server), server.Priority)
+	if server.SourceAddress != "" {
+		desc += ", 源地址 " + server.SourceAddress
+	}
+	return desc + ")"
+}
+
+// sortedServers 按优先级和队列序号排序服务器
+func sortedServers(servers []DNSServer) []DNSServer {
+	sorted := make([]DNSServer, len(servers))
+	copy(sorted, servers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority < sorted[j].Priority
+		}
+		return sorted[i].QueueIndex < sorted[j].QueueIndex
+	})
+	return sorted
+}
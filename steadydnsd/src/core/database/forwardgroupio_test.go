@@ -0,0 +1,191 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/forwardgroupio_test.go
+// 转发组导入导出测试
+
+package database
+
+import (
+	"testing"
+)
+
+// TestParseDnsmasqConfig 测试dnsmasq配置转换
+func TestParseDnsmasqConfig(t *testing.T) {
+	config := `
+# upstream
+server=8.8.8.8
+server=/corp.example.com/10.0.0.1
+server=/corp.example.com/10.0.0.2#5353
+server=/a.example/b.example/192.168.1.1@eth0
+server=/local.lan/
+server=/bad.example/not-an-ip
+no-resolv
+`
+	doc, warnings, err := ParseDnsmasqConfig(config)
+	if err != nil {
+		t.Fatalf("ParseDnsmasqConfig() error = %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %v, want 2", warnings)
+	}
+
+	groups := make(map[string]ForwardGroupEntry)
+	for _, g := range doc.Groups {
+		groups[g.Domain] = g
+	}
+
+	if len(groups[DefaultForwardGroupDomain].Servers) != 1 {
+		t.Errorf("默认组服务器 = %+v", groups[DefaultForwardGroupDomain].Servers)
+	}
+	corp := groups["corp.example.com"].Servers
+	if len(corp) != 2 || corp[1].Port != 5353 {
+		t.Errorf("corp.example.com 服务器 = %+v", corp)
+	}
+	for _, domain := range []string{"a.example", "b.example"} {
+		servers := groups[domain].Servers
+		if len(servers) != 1 || servers[0].SourceAddress != "eth0" {
+			t.Errorf("%s 服务器 = %+v", domain, servers)
+		}
+	}
+}
+
+// TestParseUnboundConfig 测试unbound配置转换
+func TestParseUnboundConfig(t *testing.T) {
+	config := `
+server:
+    interface: 0.0.0.0
+
+forward-zone:
+    name: "corp.example.com."
+    forward-addr: 10.0.0.1
+    forward-addr: 2001:db8::53@5353  # secondary
+forward-zone:
+    name: "."
+    forward-addr: 1.1.1.1@853#cloudflare-dns.com
+    forward-tls-upstream: yes
+forward-zone:
+    name: "empty.example"
+    forward-host: ns.example.net
+`
+	doc, warnings, err := ParseUnboundConfig(config)
+	if err != nil {
+		t.Fatalf("ParseUnboundConfig() error = %v", err)
+	}
+	if len(doc.Groups) != 2 {
+		t.Fatalf("groups = %+v, want 2", doc.Groups)
+	}
+	if doc.Groups[0].Domain != "corp.example.com" || len(doc.Groups[0].Servers) != 2 || doc.Groups[0].Servers[1].Port != 5353 {
+		t.Errorf("corp.example.com = %+v", doc.Groups[0])
+	}
+	if doc.Groups[1].Domain != DefaultForwardGroupDomain || doc.Groups[1].Servers[0].Port != 853 {
+		t.Errorf("根域应映射为默认组: %+v", doc.Groups[1])
+	}
+	// forward-tls-upstream、forward-host、缺少forward-addr各一条警告
+	if len(warnings) != 3 {
+		t.Errorf("warnings = %v, want 3", warnings)
+	}
+}
+
+// TestForwardGroupImport 测试导入计划和事务性应用
+func TestForwardGroupImport(t *testing.T) {
+	cleanup := setupForwardGroupTestDB(t)
+	defer cleanup()
+
+	if err := CreateForwardGroup(&ForwardGroup{ID: 1, Domain: DefaultForwardGroupDomain, Enable: true}); err != nil {
+		t.Fatalf("创建默认组失败: %v", err)
+	}
+	if err := CreateForwardGroup(&ForwardGroup{Domain: "old.example", Enable: true, Servers: []DNSServer{{Address: "10.1.1.1", Port: 53, Priority: 1}}}); err != nil {
+		t.Fatalf("创建转发组失败: %v", err)
+	}
+	if err := CreateForwardGroup(&ForwardGroup{Domain: "keep.example", Enable: true, Servers: []DNSServer{{Address: "10.2.2.2", Port: 53, Priority: 1}}}); err != nil {
+		t.Fatalf("创建转发组失败: %v", err)
+	}
+
+	disabled := false
+	doc := &ForwardGroupDocument{
+		Version: ForwardGroupDocumentVersion,
+		Groups: []ForwardGroupEntry{
+			{Domain: "Default", Servers: []ForwardServerEntry{{Address: "8.8.8.8"}}},
+			{Domain: "keep.example.", Servers: []ForwardServerEntry{{Address: "10.2.2.2"}}},
+			{Domain: "new.example", Enable: &disabled, Servers: []ForwardServerEntry{{Address: "10.3.3.3", Priority: 2}}},
+		},
+	}
+
+	t.Run("dry-run不修改数据库", func(t *testing.T) {
+		plan, err := PlanForwardGroupImport(doc, ImportModeReplace)
+		if err != nil {
+			t.Fatalf("PlanForwardGroupImport() error = %v", err)
+		}
+		if plan.Created != 1 || plan.Updated != 1 || plan.Deleted != 1 || plan.Unchanged != 1 {
+			t.Errorf("plan = %+v", plan)
+		}
+		if count, _ := CountForwardGroups(); count != 3 {
+			t.Errorf("dry-run后转发组数量 = %d, want 3", count)
+		}
+	})
+
+	t.Run("无效文档整体拒绝", func(t *testing.T) {
+		bad := &ForwardGroupDocument{Version: 1, Groups: []ForwardGroupEntry{
+			{Domain: "x.example", Servers: []ForwardServerEntry{{Address: "bad"}}},
+		}}
+		if _, err := ApplyForwardGroupImport(bad, ImportModeMerge); err == nil {
+			t.Errorf("无效服务器地址应返回错误")
+		}
+	})
+
+	t.Run("应用导入", func(t *testing.T) {
+		if _, err := ApplyForwardGroupImport(doc, ImportModeReplace); err != nil {
+			t.Fatalf("ApplyForwardGroupImport() error = %v", err)
+		}
+		if _, err := GetForwardGroupByDomain("old.example"); err == nil {
+			t.Errorf("replace模式应删除文档中不存在的转发组")
+		}
+		group, err := GetForwardGroupByDomain("new.example")
+		if err != nil {
+			t.Fatalf("新转发组未创建: %v", err)
+		}
+		if group.Enable || len(group.Servers) != 1 || group.Servers[0].Priority != 2 {
+			t.Errorf("new.example = %+v", group)
+		}
+		def, _ := GetForwardGroupByID(1)
+		if len(def.Servers) != 1 || def.Servers[0].Address != "8.8.8.8" {
+			t.Errorf("默认组服务器 = %+v", def.Servers)
+		}
+	})
+
+	t.Run("导出后再次导入无变化", func(t *testing.T) {
+		exported, err := ExportForwardGroups()
+		if err != nil {
+			t.Fatalf("ExportForwardGroups() error = %v", err)
+		}
+		data, err := MarshalForwardGroupDocument(exported, "yaml")
+		if err != nil {
+			t.Fatalf("MarshalForwardGroupDocument() error = %v", err)
+		}
+		parsed, err := UnmarshalForwardGroupDocument(data, "yaml")
+		if err != nil {
+			t.Fatalf("UnmarshalForwardGroupDocument() error = %v", err)
+		}
+		plan, err := PlanForwardGroupImport(parsed, ImportModeReplace)
+		if err != nil {
+			t.Fatalf("PlanForwardGroupImport() error = %v", err)
+		}
+		if plan.Unchanged != 3 || plan.Created+plan.Updated+plan.Deleted != 0 {
+			t.Errorf("plan = %+v", plan)
+		}
+	})
+}
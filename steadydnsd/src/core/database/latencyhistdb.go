@@ -0,0 +1,94 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/latencyhistdb.go
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// LatencyHistogramHistory 延迟直方图分钟历史表
+// 每分钟为每个分类标签写入一行，Buckets为稀疏编码的直方图桶
+type LatencyHistogramHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp time.Time `json:"timestamp" gorm:"index;not null"`        // 分钟起始时间
+	Category  string    `json:"category" gorm:"size:16;index;not null"` // 分类（cache, upstream）
+	Label     string    `json:"label" gorm:"size:255;not null"`         // 缓存结果或上游服务器地址
+	Count     int64     `json:"count" gorm:"not null"`                  // 记录数
+	Sum       int64     `json:"sum" gorm:"not null"`                    // 延迟总和（微秒）
+	Max       int64     `json:"max" gorm:"not null"`                    // 最大延迟（微秒）
+	Buckets   []byte    `json:"-"`                                      // 直方图桶编码
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (LatencyHistogramHistory) TableName() string {
+	return "latency_histogram_history"
+}
+
+// SaveLatencyHistogramBatch 批量保存延迟直方图历史记录
+func SaveLatencyHistogramBatch(records []LatencyHistogramHistory) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := DB.CreateInBatches(records, 100).Error; err != nil {
+		return fmt.Errorf("批量保存延迟直方图历史记录失败: %v", err)
+	}
+
+	return nil
+}
+
+// GetLatencyHistogramHistoryByTimeRange 根据时间范围获取指定分类的延迟直方图
+// 参数：
+//   - category: 分类（cache, upstream）
+//   - startTime: 开始时间
+//   - endTime: 结束时间
+//
+// 返回：
+//   - []LatencyHistogramHistory: 按时间升序排列的历史记录
+//   - error: 查询失败时返回错误
+func GetLatencyHistogramHistoryByTimeRange(category string, startTime, endTime time.Time) ([]LatencyHistogramHistory, error) {
+	var records []LatencyHistogramHistory
+
+	err := DB.Where("category = ? AND timestamp >= ? AND timestamp <= ?", category, startTime, endTime).
+		Order("timestamp ASC").
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询延迟直方图历史记录失败: %v", err)
+	}
+
+	return records, nil
+}
+
+// CleanOldLatencyHistogramHistory 清理过期的延迟直方图历史记录
+func CleanOldLatencyHistogramHistory(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := DB.Where("timestamp < ?", cutoff).Delete(&LatencyHistogramHistory{})
+	if result.Error != nil {
+		return fmt.Errorf("清理延迟直方图历史记录失败: %v", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		GetLogManager().logger.Info("清理了 %d 条过期的延迟直方图历史记录", result.RowsAffected)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/latencyhistdb_test.go
+// 延迟直方图历史数据库操作测试
+
+package database
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestLatencyHistogramHistory 测试延迟直方图记录的保存、查询和清理
+func TestLatencyHistogramHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	DB = db
+	defer func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}()
+	if err := DB.AutoMigrate(&LatencyHistogramHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	minute := time.Now().Truncate(time.Minute)
+	buckets := []byte{0x05, 0x02, 0x81, 0x01, 0x07}
+	records := []LatencyHistogramHistory{
+		{Timestamp: minute.Add(-10 * 24 * time.Hour), Category: "cache", Label: "hit", Count: 9, Sum: 900, Max: 200, Buckets: buckets},
+		{Timestamp: minute.Add(-2 * time.Minute), Category: "cache", Label: "miss", Count: 9, Sum: 90000, Max: 40000, Buckets: buckets},
+		{Timestamp: minute.Add(-1 * time.Minute), Category: "cache", Label: "hit", Count: 9, Sum: 900, Max: 200, Buckets: buckets},
+		{Timestamp: minute.Add(-1 * time.Minute), Category: "upstream", Label: "8.8.8.8:53", Count: 9, Sum: 90000, Max: 30000, Buckets: buckets},
+	}
+	if err := SaveLatencyHistogramBatch(records); err != nil {
+		t.Fatalf("SaveLatencyHistogramBatch() error = %v", err)
+	}
+
+	result, err := GetLatencyHistogramHistoryByTimeRange("cache", minute.Add(-time.Hour), minute)
+	if err != nil {
+		t.Fatalf("GetLatencyHistogramHistoryByTimeRange() error = %v", err)
+	}
+	if len(result) != 2 || result[0].Label != "miss" || result[1].Label != "hit" {
+		t.Fatalf("查询结果 = %+v", result)
+	}
+	if !bytes.Equal(result[0].Buckets, buckets) {
+		t.Errorf("直方图桶编码读取后不一致: %x", result[0].Buckets)
+	}
+
+	if err := CleanOldLatencyHistogramHistory(7); err != nil {
+		t.Fatalf("CleanOldLatencyHistogramHistory() error = %v", err)
+	}
+	var count int64
+	DB.Model(&LatencyHistogramHistory{}).Count(&count)
+	if count != 3 {
+		t.Errorf("清理后记录数 = %d, want 3", count)
+	}
+}
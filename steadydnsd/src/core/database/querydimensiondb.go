@@ -0,0 +1,114 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/querydimensiondb.go
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// QueryDimensionHistory 查询维度分钟聚合历史表
+// 每分钟为每个维度取值写入一行，Count为该分钟内的查询次数
+type QueryDimensionHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp time.Time `json:"timestamp" gorm:"index;not null"`         // 分钟起始时间
+	Dimension string    `json:"dimension" gorm:"size:16;index;not null"` // 维度（qtype, rcode, protocol, cache）
+	Value     string    `json:"value" gorm:"size:32;not null"`           // 维度取值
+	Count     int64     `json:"count" gorm:"not null"`                   // 分钟内查询次数
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (QueryDimensionHistory) TableName() string {
+	return "query_dimension_history"
+}
+
+// SaveQueryDimensionHistoryBatch 批量保存查询维度历史记录
+func SaveQueryDimensionHistoryBatch(records []QueryDimensionHistory) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := DB.CreateInBatches(records, 100).Error; err != nil {
+		return fmt.Errorf("批量保存查询维度历史记录失败: %v", err)
+	}
+
+	return nil
+}
+
+// GetQueryDimensionHistoryByTimeRange 根据时间范围获取指定维度的历史记录
+// 参数：
+//   - dimension: 维度名称
+//   - startTime: 开始时间
+//   - endTime: 结束时间
+//
+// 返回：
+//   - []QueryDimensionHistory: 按时间升序排列的历史记录
+//   - error: 查询失败时返回错误
+func GetQueryDimensionHistoryByTimeRange(dimension string, startTime, endTime time.Time) ([]QueryDimensionHistory, error) {
+	var records []QueryDimensionHistory
+
+	err := DB.Where("dimension = ? AND timestamp >= ? AND timestamp <= ?", dimension, startTime, endTime).
+		Order("timestamp ASC").
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询查询维度历史记录失败: %v", err)
+	}
+
+	return records, nil
+}
+
+// QueryDimensionSummary 维度取值在时间范围内的查询次数汇总
+type QueryDimensionSummary struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// GetQueryDimensionSummary 获取时间范围内指定维度各取值的查询次数，按次数降序排列
+func GetQueryDimensionSummary(dimension string, startTime, endTime time.Time) ([]QueryDimensionSummary, error) {
+	var results []QueryDimensionSummary
+
+	err := DB.Model(&QueryDimensionHistory{}).
+		Where("dimension = ? AND timestamp >= ? AND timestamp <= ?", dimension, startTime, endTime).
+		Select("value, SUM(count) as count").
+		Group("value").
+		Order("count DESC, value ASC").
+		Scan(&results).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取查询维度汇总失败: %v", err)
+	}
+
+	return results, nil
+}
+
+// CleanOldQueryDimensionHistory 清理过期的查询维度历史记录
+func CleanOldQueryDimensionHistory(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := DB.Where("timestamp < ?", cutoff).Delete(&QueryDimensionHistory{})
+	if result.Error != nil {
+		return fmt.Errorf("清理查询维度历史记录失败: %v", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		GetLogManager().logger.Info("清理了 %d 条过期的查询维度历史记录", result.RowsAffected)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/querydimensiondb_test.go
+// 查询维度历史数据库操作测试
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestQueryDimensionHistory 测试查询维度历史记录操作
+func TestQueryDimensionHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	DB = db
+	defer func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}()
+	if err := DB.AutoMigrate(&QueryDimensionHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	minute := time.Now().Truncate(time.Minute)
+	records := []QueryDimensionHistory{
+		{Timestamp: minute.Add(-10 * 24 * time.Hour), Dimension: "rcode", Value: "NOERROR", Count: 500},
+		{Timestamp: minute.Add(-2 * time.Minute), Dimension: "rcode", Value: "NOERROR", Count: 80},
+		{Timestamp: minute.Add(-2 * time.Minute), Dimension: "rcode", Value: "NXDOMAIN", Count: 20},
+		{Timestamp: minute.Add(-1 * time.Minute), Dimension: "rcode", Value: "NOERROR", Count: 90},
+		{Timestamp: minute.Add(-1 * time.Minute), Dimension: "protocol", Value: "udp", Count: 90},
+	}
+
+	if err := SaveQueryDimensionHistoryBatch(records); err != nil {
+		t.Fatalf("SaveQueryDimensionHistoryBatch() error = %v", err)
+	}
+	if err := SaveQueryDimensionHistoryBatch(nil); err != nil {
+		t.Errorf("SaveQueryDimensionHistoryBatch(nil) error = %v", err)
+	}
+
+	t.Run("按维度和时间范围查询", func(t *testing.T) {
+		result, err := GetQueryDimensionHistoryByTimeRange("rcode", minute.Add(-time.Hour), minute)
+		if err != nil {
+			t.Fatalf("GetQueryDimensionHistoryByTimeRange() error = %v", err)
+		}
+		if len(result) != 3 {
+			t.Fatalf("len(result) = %d, want 3", len(result))
+		}
+		if !result[0].Timestamp.Before(result[2].Timestamp) {
+			t.Errorf("记录应按时间升序排列")
+		}
+		for _, r := range result {
+			if r.Dimension != "rcode" {
+				t.Errorf("返回了其他维度的记录: %+v", r)
+			}
+		}
+	})
+
+	t.Run("清理过期记录", func(t *testing.T) {
+		if err := CleanOldQueryDimensionHistory(7); err != nil {
+			t.Fatalf("CleanOldQueryDimensionHistory() error = %v", err)
+		}
+		var count int64
+		DB.Model(&QueryDimensionHistory{}).Count(&count)
+		if count != 4 {
+			t.Errorf("清理后记录数 = %d, want 4", count)
+		}
+	})
+}
@@ -0,0 +1,410 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/querylogdb.go
+// 查询日志存储：独立的SQLite数据库，按天分区，支持条件检索和按时间、大小清理
+
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// QueryLogDB 查询日志数据库连接，未启用日志分析时为nil
+// 查询日志写入量大，使用独立的数据库文件，避免影响主库的配置读写
+var QueryLogDB *gorm.DB
+
+// queryLogDBMu 保护QueryLogDB的打开和关闭
+var queryLogDBMu sync.Mutex
+
+// QueryLogEntry 查询日志记录表
+type QueryLogEntry struct {
+	ID           uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp    time.Time `json:"timestamp" gorm:"index;not null"`
+	Day          int       `json:"-" gorm:"index;not null"` // 分区键（YYYYMMDD），按天清理
+	ClientIP     string    `json:"clientIp" gorm:"size:64;index"`
+	ClientPort   int       `json:"clientPort"`
+	Protocol     string    `json:"protocol" gorm:"size:8"`
+	QName        string    `json:"qname" gorm:"column:qname;size:255;index"`
+	QType        string    `json:"qtype" gorm:"column:qtype;size:16"`
+	RCode        string    `json:"rcode" gorm:"column:rcode;size:16"`
+	CacheStatus  string    `json:"cacheStatus" gorm:"size:16"`
+	ForwardGroup string    `json:"forwardGroup" gorm:"size:255"`
+	Upstream     string    `json:"upstream" gorm:"size:64;index"`
+	Security     string    `json:"security" gorm:"size:32"`
+	AnswerCount  int       `json:"answerCount"`
+	Answers      string    `json:"answers" gorm:"type:text"` // 应答记录，每行一条
+	DurationMs   float64   `json:"durationMs"`
+	Error        string    `json:"error" gorm:"type:text"`
+}
+
+// TableName 指定表名
+func (QueryLogEntry) TableName() string {
+	return "query_log_entries"
+}
+
+// QueryLogFilter 查询日志检索条件
+type QueryLogFilter struct {
+	StartTime   time.Time
+	EndTime     time.Time
+	ClientIP    string
+	QName       string // 子串匹配，不区分大小写
+	QType       string
+	RCode       string
+	CacheStatus string
+	Upstream    string
+	Page        int
+	PageSize    int
+}
+
+// QueryLogStoreStats 查询日志存储状态
+type QueryLogStoreStats struct {
+	Entries   int64     `json:"entries"`
+	SizeBytes int64     `json:"sizeBytes"`
+	Oldest    time.Time `json:"oldest"`
+	Newest    time.Time `json:"newest"`
+}
+
+// InitQueryLogDB 打开查询日志数据库并创建表结构
+func InitQueryLogDB(dbPath string) error {
+	queryLogDBMu.Lock()
+	defer queryLogDBMu.Unlock()
+
+	if QueryLogDB != nil {
+		return nil
+	}
+
+	if dir := filepath.Dir(dbPath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建查询日志数据库目录失败: %v", err)
+		}
+	}
+
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+		Logger: logManager.gormLogger,
+	})
+	if err != nil {
+		return fmt.Errorf("打开查询日志数据库失败: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("获取查询日志数据库连接失败: %v", err)
+	}
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetMaxOpenConns(1)
+
+	// auto_vacuum需在建表前设置，清理后通过incremental_vacuum归还空间
+	for _, pragma := range []string{
+		"PRAGMA auto_vacuum = INCREMENTAL",
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+	} {
+		if err := db.Exec(pragma).Error; err != nil {
+			sqlDB.Close()
+			return fmt.Errorf("设置查询日志数据库参数失败: %v", err)
+		}
+	}
+
+	if err := db.AutoMigrate(&QueryLogEntry{}); err != nil {
+		sqlDB.Close()
+		return fmt.Errorf("迁移查询日志表失败: %v", err)
+	}
+
+	QueryLogDB = db
+	return nil
+}
+
+// CloseQueryLogDB 关闭查询日志数据库
+func CloseQueryLogDB() error {
+	queryLogDBMu.Lock()
+	defer queryLogDBMu.Unlock()
+
+	if QueryLogDB == nil {
+		return nil
+	}
+	sqlDB, err := QueryLogDB.DB()
+	QueryLogDB = nil
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// queryLogDB 获取查询日志数据库连接
+func queryLogDB() (*gorm.DB, error) {
+	queryLogDBMu.Lock()
+	defer queryLogDBMu.Unlock()
+
+	if QueryLogDB == nil {
+		return nil, fmt.Errorf("查询日志存储未启用")
+	}
+	return QueryLogDB, nil
+}
+
+// QueryLogDay 计算时间对应的分区键（按本地时区）
+func QueryLogDay(t time.Time) int {
+	t = t.Local()
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// SaveQueryLogBatch 批量保存查询日志
+func SaveQueryLogBatch(entries []QueryLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	db, err := queryLogDB()
+	if err != nil {
+		return err
+	}
+
+	for i := range entries {
+		if entries[i].Day == 0 {
+			entries[i].Day = QueryLogDay(entries[i].Timestamp)
+		}
+	}
+
+	if err := db.CreateInBatches(entries, 200).Error; err != nil {
+		return fmt.Errorf("批量保存查询日志失败: %v", err)
+	}
+	return nil
+}
+
+// applyQueryLogFilter 构建查询条件
+func applyQueryLogFilter(db *gorm.DB, filter QueryLogFilter) *gorm.DB {
+	// 时间以本地时区文本存储，比较前统一转换为本地时区
+	tx := db.Model(&QueryLogEntry{})
+	if !filter.StartTime.IsZero() {
+		tx = tx.Where("day >= ? AND timestamp >= ?", QueryLogDay(filter.StartTime), filter.StartTime.Local())
+	}
+	if !filter.EndTime.IsZero() {
+		tx = tx.Where("day <= ? AND timestamp <= ?", QueryLogDay(filter.EndTime), filter.EndTime.Local())
+	}
+	if filter.ClientIP != "" {
+		tx = tx.Where("client_ip = ?", filter.ClientIP)
+	}
+	if filter.QName != "" {
+		tx = tx.Where("qname LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(filter.QName))+"%")
+	}
+	if filter.QType != "" {
+		tx = tx.Where("qtype = ?", strings.ToUpper(filter.QType))
+	}
+	if filter.RCode != "" {
+		tx = tx.Where("rcode = ?", strings.ToUpper(filter.RCode))
+	}
+	if filter.CacheStatus != "" {
+		tx = tx.Where("cache_status = ?", strings.ToLower(filter.CacheStatus))
+	}
+	if filter.Upstream != "" {
+		tx = tx.Where("upstream = ?", filter.Upstream)
+	}
+	return tx
+}
+
+// escapeLike 转义LIKE模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SearchQueryLogs 按条件分页检索查询日志，按时间倒序排列
+func SearchQueryLogs(filter QueryLogFilter) ([]QueryLogEntry, int64, error) {
+	db, err := queryLogDB()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 50
+	}
+
+	var total int64
+	if err := applyQueryLogFilter(db, filter).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计查询日志失败: %v", err)
+	}
+
+	entries := []QueryLogEntry{}
+	offset := (filter.Page - 1) * filter.PageSize
+	if err := applyQueryLogFilter(db, filter).Order("timestamp DESC, id DESC").
+		Offset(offset).Limit(filter.PageSize).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询查询日志失败: %v", err)
+	}
+
+	return entries, total, nil
+}
+
+// ExportQueryLogs 按条件导出查询日志，按时间倒序分批回调，最多导出limit条
+func ExportQueryLogs(filter QueryLogFilter, limit int, fn func(entries []QueryLogEntry) error) error {
+	db, err := queryLogDB()
+	if err != nil {
+		return err
+	}
+
+	const batchSize = 1000
+	exported := 0
+	// 使用(timestamp, id)游标分页，避免大偏移量
+	var lastTime time.Time
+	var lastID uint
+	for exported < limit {
+		size := batchSize
+		if limit-exported < size {
+			size = limit - exported
+		}
+
+		tx := applyQueryLogFilter(db, filter)
+		if lastID != 0 {
+			tx = tx.Where("timestamp < ? OR (timestamp = ? AND id < ?)", lastTime, lastTime, lastID)
+		}
+		var batch []QueryLogEntry
+		if err := tx.Order("timestamp DESC, id DESC").Limit(size).Find(&batch).Error; err != nil {
+			return fmt.Errorf("导出查询日志失败: %v", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+
+		exported += len(batch)
+		last := batch[len(batch)-1]
+		lastTime, lastID = last.Timestamp, last.ID
+		if len(batch) < size {
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetQueryLogStoreStats 获取查询日志存储状态
+func GetQueryLogStoreStats() (*QueryLogStoreStats, error) {
+	db, err := queryLogDB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &QueryLogStoreStats{}
+	if err := db.Model(&QueryLogEntry{}).Count(&stats.Entries).Error; err != nil {
+		return nil, fmt.Errorf("统计查询日志失败: %v", err)
+	}
+	if stats.Entries > 0 {
+		var oldest, newest QueryLogEntry
+		db.Order("timestamp ASC").Select("timestamp").First(&oldest)
+		db.Order("timestamp DESC").Select("timestamp").First(&newest)
+		stats.Oldest = oldest.Timestamp
+		stats.Newest = newest.Timestamp
+	}
+	stats.SizeBytes, err = queryLogDBSize(db)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// queryLogDBSize 计算数据库已使用的大小（不含空闲页）
+func queryLogDBSize(db *gorm.DB) (int64, error) {
+	var pageCount, freePages, pageSize int64
+	if err := db.Raw("PRAGMA page_count").Scan(&pageCount).Error; err != nil {
+		return 0, fmt.Errorf("获取查询日志数据库大小失败: %v", err)
+	}
+	if err := db.Raw("PRAGMA freelist_count").Scan(&freePages).Error; err != nil {
+		return 0, fmt.Errorf("获取查询日志数据库大小失败: %v", err)
+	}
+	if err := db.Raw("PRAGMA page_size").Scan(&pageSize).Error; err != nil {
+		return 0, fmt.Errorf("获取查询日志数据库大小失败: %v", err)
+	}
+	return (pageCount - freePages) * pageSize, nil
+}
+
+// CleanQueryLogs 按保留天数和大小上限清理查询日志
+//
+// 参数:
+//   - retentionDays: 保留天数，<=0表示不按时间清理
+//   - maxSizeBytes: 数据库大小上限，<=0表示不按大小清理；超出时从最早的分区开始删除
+//
+// 返回:
+//   - int64: 删除的记录数
+//   - error: 清理失败时返回错误
+func CleanQueryLogs(retentionDays int, maxSizeBytes int64) (int64, error) {
+	db, err := queryLogDB()
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	if retentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -retentionDays)
+		result := db.Where("day < ? OR timestamp < ?", QueryLogDay(cutoff), cutoff).Delete(&QueryLogEntry{})
+		if result.Error != nil {
+			return 0, fmt.Errorf("清理过期查询日志失败: %v", result.Error)
+		}
+		deleted += result.RowsAffected
+	}
+
+	if maxSizeBytes > 0 {
+		// 删除后的空间以页为单位回收，限制轮数避免碎片导致的过度删除
+		for round := 0; round < 10; round++ {
+			size, err := queryLogDBSize(db)
+			if err != nil {
+				return deleted, err
+			}
+			if size <= maxSizeBytes {
+				break
+			}
+
+			var count int64
+			if err := db.Model(&QueryLogEntry{}).Count(&count).Error; err != nil {
+				return deleted, fmt.Errorf("统计查询日志失败: %v", err)
+			}
+			if count == 0 {
+				break
+			}
+
+			// 按超出比例删除最早的记录，至少删除10%，避免反复小批量删除
+			ratio := float64(size-maxSizeBytes)/float64(size) + 0.1
+			n := int64(float64(count) * ratio)
+			if n < 1 {
+				n = 1
+			}
+			result := db.Where("id IN (?)", db.Model(&QueryLogEntry{}).Select("id").Order("timestamp ASC, id ASC").Limit(int(n))).
+				Delete(&QueryLogEntry{})
+			if result.Error != nil {
+				return deleted, fmt.Errorf("按大小清理查询日志失败: %v", result.Error)
+			}
+			deleted += result.RowsAffected
+			if result.RowsAffected == 0 {
+				break
+			}
+		}
+	}
+
+	if deleted > 0 {
+		db.Exec("PRAGMA incremental_vacuum")
+		GetLogManager().logger.Info("清理了 %d 条查询日志", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,183 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/querylogdb_test.go
+// 查询日志存储数据库操作测试
+
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupQueryLogTestDB 在临时目录中创建查询日志数据库
+func setupQueryLogTestDB(t *testing.T) {
+	t.Helper()
+	if err := InitQueryLogDB(filepath.Join(t.TempDir(), "querylog.db")); err != nil {
+		t.Fatalf("初始化查询日志数据库失败: %v", err)
+	}
+	t.Cleanup(func() { CloseQueryLogDB() })
+}
+
+// TestSearchQueryLogs 测试查询日志保存和条件检索
+func TestSearchQueryLogs(t *testing.T) {
+	setupQueryLogTestDB(t)
+
+	now := time.Now()
+	entries := []QueryLogEntry{
+		{Timestamp: now.Add(-3 * time.Minute), ClientIP: "192.168.1.10", QName: "www.example.com.", QType: "A", RCode: "NOERROR", CacheStatus: "miss", Upstream: "8.8.8.8:53"},
+		{Timestamp: now.Add(-2 * time.Minute), ClientIP: "192.168.1.11", QName: "mail.example.com.", QType: "MX", RCode: "NOERROR", CacheStatus: "hit"},
+		{Timestamp: now.Add(-1 * time.Minute), ClientIP: "192.168.1.10", QName: "missing.test.", QType: "A", RCode: "NXDOMAIN", CacheStatus: "miss", Upstream: "1.1.1.1:53"},
+		{Timestamp: now.Add(-2 * time.Hour), ClientIP: "192.168.1.12", QName: "old_name.example.com.", QType: "AAAA", RCode: "SERVFAIL"},
+	}
+	if err := SaveQueryLogBatch(entries); err != nil {
+		t.Fatalf("SaveQueryLogBatch() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter QueryLogFilter
+		want   int64
+	}{
+		{"全部", QueryLogFilter{}, 4},
+		{"域名子串", QueryLogFilter{QName: "EXAMPLE.com"}, 3},
+		{"LIKE通配符转义", QueryLogFilter{QName: "old_"}, 1},
+		{"下划线不作通配", QueryLogFilter{QName: "l_e"}, 0},
+		{"响应码", QueryLogFilter{RCode: "nxdomain"}, 1},
+		{"客户端", QueryLogFilter{ClientIP: "192.168.1.10"}, 2},
+		{"查询类型", QueryLogFilter{QType: "a"}, 2},
+		{"缓存状态", QueryLogFilter{CacheStatus: "hit"}, 1},
+		{"上游", QueryLogFilter{Upstream: "8.8.8.8:53"}, 1},
+		{"时间范围", QueryLogFilter{StartTime: now.Add(-150 * time.Second), EndTime: now}, 2},
+		{"组合条件", QueryLogFilter{ClientIP: "192.168.1.10", RCode: "NOERROR"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, total, err := SearchQueryLogs(tt.filter)
+			if err != nil {
+				t.Fatalf("SearchQueryLogs() error = %v", err)
+			}
+			if total != tt.want {
+				t.Errorf("total = %d, want %d", total, tt.want)
+			}
+		})
+	}
+
+	// 分页按时间倒序
+	page, total, err := SearchQueryLogs(QueryLogFilter{Page: 2, PageSize: 2})
+	if err != nil {
+		t.Fatalf("SearchQueryLogs() error = %v", err)
+	}
+	if total != 4 || len(page) != 2 {
+		t.Fatalf("total = %d, len = %d, want 4/2", total, len(page))
+	}
+	if page[0].QName != "www.example.com." || page[1].QName != "old_name.example.com." {
+		t.Errorf("第2页 = %s, %s", page[0].QName, page[1].QName)
+	}
+}
+
+// TestExportQueryLogs 测试分批导出和数量限制
+func TestExportQueryLogs(t *testing.T) {
+	setupQueryLogTestDB(t)
+
+	// 相同时间戳的记录需依靠id游标区分
+	base := time.Now().Truncate(time.Second)
+	entries := make([]QueryLogEntry, 0, 2500)
+	for i := 0; i < 2500; i++ {
+		entries = append(entries, QueryLogEntry{
+			Timestamp: base.Add(-time.Duration(i/10) * time.Second),
+			ClientIP:  "10.0.0.1",
+			QName:     "example.com.",
+			QType:     "A",
+			RCode:     "NOERROR",
+		})
+	}
+	if err := SaveQueryLogBatch(entries); err != nil {
+		t.Fatalf("SaveQueryLogBatch() error = %v", err)
+	}
+
+	seen := make(map[uint]bool)
+	batches := 0
+	err := ExportQueryLogs(QueryLogFilter{}, 100000, func(batch []QueryLogEntry) error {
+		batches++
+		for _, e := range batch {
+			if seen[e.ID] {
+				t.Fatalf("记录 %d 重复导出", e.ID)
+			}
+			seen[e.ID] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportQueryLogs() error = %v", err)
+	}
+	if len(seen) != 2500 || batches != 3 {
+		t.Errorf("导出 %d 条/%d 批, want 2500/3", len(seen), batches)
+	}
+
+	count := 0
+	err = ExportQueryLogs(QueryLogFilter{}, 1500, func(batch []QueryLogEntry) error {
+		count += len(batch)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportQueryLogs() error = %v", err)
+	}
+	if count != 1500 {
+		t.Errorf("limit导出 %d 条, want 1500", count)
+	}
+}
+
+// TestCleanQueryLogs 测试按保留天数清理
+func TestCleanQueryLogs(t *testing.T) {
+	setupQueryLogTestDB(t)
+
+	now := time.Now()
+	entries := []QueryLogEntry{
+		{Timestamp: now.AddDate(0, 0, -10), QName: "old.example.com."},
+		{Timestamp: now.AddDate(0, 0, -8), QName: "old.example.com."},
+		{Timestamp: now.Add(-time.Hour), QName: "new.example.com."},
+	}
+	if err := SaveQueryLogBatch(entries); err != nil {
+		t.Fatalf("SaveQueryLogBatch() error = %v", err)
+	}
+
+	deleted, err := CleanQueryLogs(7, 0)
+	if err != nil {
+		t.Fatalf("CleanQueryLogs() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted = %d, want 2", deleted)
+	}
+
+	stats, err := GetQueryLogStoreStats()
+	if err != nil {
+		t.Fatalf("GetQueryLogStoreStats() error = %v", err)
+	}
+	if stats.Entries != 1 || stats.SizeBytes <= 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// 大小上限低于当前大小时删除最早的记录
+	if _, err := CleanQueryLogs(0, 1); err != nil {
+		t.Fatalf("CleanQueryLogs() error = %v", err)
+	}
+	if stats, _ := GetQueryLogStoreStats(); stats.Entries != 0 {
+		t.Errorf("按大小清理后剩余 %d 条", stats.Entries)
+	}
+}
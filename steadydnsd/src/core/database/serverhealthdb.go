@@ -0,0 +1,159 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/serverhealthdb.go
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ServerHealthHistory 上游服务器健康历史快照表
+// 每个持久化周期为每台上游服务器写入一行，查询计数为该周期内的增量
+type ServerHealthHistory struct {
+	ID                uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp         time.Time `json:"timestamp" gorm:"index;not null"`
+	ServerAddress     string    `json:"serverAddress" gorm:"size:255;index;not null"` // 服务器地址（host:port）
+	Queries           int64     `json:"queries" gorm:"not null"`                      // 周期内查询次数
+	SuccessfulQueries int64     `json:"successfulQueries" gorm:"not null"`            // 周期内成功次数
+	FailedQueries     int64     `json:"failedQueries" gorm:"not null"`                // 周期内失败次数
+	AvgLatency        float64   `json:"avgLatency"`                                   // 周期内平均延迟（毫秒）
+	P50Latency        float64   `json:"p50Latency"`                                   // 周期内P50延迟（毫秒）
+	P90Latency        float64   `json:"p90Latency"`                                   // 周期内P90延迟（毫秒）
+	P99Latency        float64   `json:"p99Latency"`                                   // 周期内P99延迟（毫秒）
+	EWMAScore         float64   `json:"ewmaScore"`                                    // 快照时的EWMA评分
+	Status            string    `json:"status" gorm:"size:32"`                        // 快照时的健康状态
+	CircuitBroken     bool      `json:"circuitBroken"`                                // 快照时是否处于熔断状态
+	CircuitTrips      int64     `json:"circuitTrips"`                                 // 周期内触发熔断次数
+	CircuitRecoveries int64     `json:"circuitRecoveries"`                            // 周期内熔断恢复次数
+	CreatedAt         time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (ServerHealthHistory) TableName() string {
+	return "server_health_history"
+}
+
+// SaveServerHealthHistoryBatch 批量保存上游服务器健康历史记录
+func SaveServerHealthHistoryBatch(records []ServerHealthHistory) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := DB.CreateInBatches(records, 100).Error; err != nil {
+		return fmt.Errorf("批量保存服务器健康历史记录失败: %v", err)
+	}
+
+	return nil
+}
+
+// GetServerHealthHistoryByTimeRange 根据时间范围获取指定服务器的健康历史记录
+// 参数：
+//   - address: 服务器地址（host:port），为空时返回所有服务器
+//   - startTime: 开始时间
+//   - endTime: 结束时间
+//
+// 返回：
+//   - []ServerHealthHistory: 按时间升序排列的历史记录
+//   - error: 查询失败时返回错误
+func GetServerHealthHistoryByTimeRange(address string, startTime, endTime time.Time) ([]ServerHealthHistory, error) {
+	var records []ServerHealthHistory
+
+	query := DB.Where("timestamp >= ? AND timestamp <= ?", startTime, endTime)
+	if address != "" {
+		query = query.Where("server_address = ?", address)
+	}
+
+	if err := query.Order("timestamp ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询服务器健康历史记录失败: %v", err)
+	}
+
+	return records, nil
+}
+
+// ServerAvailabilitySummary 单台服务器在时间范围内的可用性汇总
+type ServerAvailabilitySummary struct {
+	ServerAddress     string  `json:"serverAddress"`
+	Queries           int64   `json:"queries"`
+	SuccessfulQueries int64   `json:"successfulQueries"`
+	FailedQueries     int64   `json:"failedQueries"`
+	AvgLatency        float64 `json:"avgLatency"`
+	P50Latency        float64 `json:"p50Latency"`
+	P90Latency        float64 `json:"p90Latency"`
+	P99Latency        float64 `json:"p99Latency"`
+	MaxP99Latency     float64 `json:"maxP99Latency"`
+	CircuitTrips      int64   `json:"circuitTrips"`
+	CircuitRecoveries int64   `json:"circuitRecoveries"`
+	BrokenSnapshots   int64   `json:"brokenSnapshots"`
+	Snapshots         int64   `json:"snapshots"`
+}
+
+// GetServerAvailabilitySummary 获取时间范围内每台服务器的可用性汇总
+// 延迟百分位按成功查询数加权平均，MaxP99Latency为周期内最差的P99
+func GetServerAvailabilitySummary(startTime, endTime time.Time) ([]ServerAvailabilitySummary, error) {
+	var results []ServerAvailabilitySummary
+
+	err := DB.Model(&ServerHealthHistory{}).
+		Where("timestamp >= ? AND timestamp <= ?", startTime, endTime).
+		Select("server_address, " +
+			"SUM(queries) as queries, SUM(successful_queries) as successful_queries, SUM(failed_queries) as failed_queries, " +
+			"COALESCE(SUM(avg_latency * successful_queries) / NULLIF(SUM(successful_queries), 0), 0) as avg_latency, " +
+			"COALESCE(SUM(p50_latency * successful_queries) / NULLIF(SUM(successful_queries), 0), 0) as p50_latency, " +
+			"COALESCE(SUM(p90_latency * successful_queries) / NULLIF(SUM(successful_queries), 0), 0) as p90_latency, " +
+			"COALESCE(SUM(p99_latency * successful_queries) / NULLIF(SUM(successful_queries), 0), 0) as p99_latency, " +
+			"MAX(p99_latency) as max_p99_latency, " +
+			"SUM(circuit_trips) as circuit_trips, SUM(circuit_recoveries) as circuit_recoveries, " +
+			"SUM(CASE WHEN circuit_broken THEN 1 ELSE 0 END) as broken_snapshots, " +
+			"COUNT(*) as snapshots").
+		Group("server_address").
+		Order("server_address ASC").
+		Scan(&results).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("获取服务器可用性汇总失败: %v", err)
+	}
+
+	return results, nil
+}
+
+// CleanOldServerHealthHistory 清理过期的服务器健康历史记录
+func CleanOldServerHealthHistory(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := DB.Where("timestamp < ?", cutoff).Delete(&ServerHealthHistory{})
+	if result.Error != nil {
+		return fmt.Errorf("清理服务器健康历史记录失败: %v", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		GetLogManager().logger.Info("清理了 %d 条过期的服务器健康历史记录", result.RowsAffected)
+	}
+
+	return nil
+}
+
+// EnsureServerHealthHistoryTableExists 确保服务器健康历史表存在
+func EnsureServerHealthHistoryTableExists() error {
+	if !DB.Migrator().HasTable(&ServerHealthHistory{}) {
+		if err := DB.AutoMigrate(&ServerHealthHistory{}); err != nil {
+			return fmt.Errorf("创建服务器健康历史表失败: %v", err)
+		}
+		GetLogManager().logger.Info("服务器健康历史表创建成功")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/serverhealthdb_test.go
+// 服务器健康历史数据库操作测试
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupServerHealthTestDB 创建测试用的内存数据库
+func setupServerHealthTestDB(t *testing.T) func() {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+
+	DB = db
+
+	if err := DB.AutoMigrate(&ServerHealthHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	return func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}
+}
+
+// TestServerHealthHistory 测试服务器健康历史记录操作
+func TestServerHealthHistory(t *testing.T) {
+	cleanup := setupServerHealthTestDB(t)
+	defer cleanup()
+
+	now := time.Now()
+	records := []ServerHealthHistory{
+		{Timestamp: now.Add(-10 * 24 * time.Hour), ServerAddress: "8.8.8.8:53", Queries: 50, SuccessfulQueries: 50},
+		{Timestamp: now.Add(-2 * time.Minute), ServerAddress: "8.8.8.8:53", Queries: 100, SuccessfulQueries: 90, FailedQueries: 10, AvgLatency: 10, P99Latency: 40, CircuitTrips: 1},
+		{Timestamp: now.Add(-1 * time.Minute), ServerAddress: "8.8.8.8:53", Queries: 100, SuccessfulQueries: 30, FailedQueries: 70, AvgLatency: 30, P99Latency: 80, CircuitBroken: true},
+		{Timestamp: now.Add(-1 * time.Minute), ServerAddress: "1.1.1.1:53", Queries: 20, SuccessfulQueries: 20, AvgLatency: 5, P99Latency: 9},
+	}
+
+	t.Run("批量保存健康历史记录", func(t *testing.T) {
+		if err := SaveServerHealthHistoryBatch(records); err != nil {
+			t.Fatalf("SaveServerHealthHistoryBatch() error = %v", err)
+		}
+		if err := SaveServerHealthHistoryBatch(nil); err != nil {
+			t.Errorf("SaveServerHealthHistoryBatch(nil) error = %v", err)
+		}
+	})
+
+	t.Run("按服务器和时间范围查询", func(t *testing.T) {
+		result, err := GetServerHealthHistoryByTimeRange("8.8.8.8:53", now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetServerHealthHistoryByTimeRange() error = %v", err)
+		}
+		if len(result) != 2 {
+			t.Fatalf("len(result) = %d, want 2", len(result))
+		}
+		if !result[0].Timestamp.Before(result[1].Timestamp) {
+			t.Errorf("记录未按时间升序排列")
+		}
+
+		all, err := GetServerHealthHistoryByTimeRange("", now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetServerHealthHistoryByTimeRange() error = %v", err)
+		}
+		if len(all) != 3 {
+			t.Errorf("len(all) = %d, want 3", len(all))
+		}
+	})
+
+	t.Run("可用性汇总", func(t *testing.T) {
+		summaries, err := GetServerAvailabilitySummary(now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetServerAvailabilitySummary() error = %v", err)
+		}
+		if len(summaries) != 2 {
+			t.Fatalf("len(summaries) = %d, want 2", len(summaries))
+		}
+
+		google := summaries[1]
+		if google.ServerAddress != "8.8.8.8:53" {
+			t.Fatalf("ServerAddress = %s, want 8.8.8.8:53", google.ServerAddress)
+		}
+		if google.Queries != 200 || google.SuccessfulQueries != 120 || google.FailedQueries != 80 {
+			t.Errorf("查询计数错误: %+v", google)
+		}
+		// (10*90 + 30*30) / 120 = 15
+		if google.AvgLatency != 15 {
+			t.Errorf("AvgLatency = %v, want 15", google.AvgLatency)
+		}
+		if google.MaxP99Latency != 80 {
+			t.Errorf("MaxP99Latency = %v, want 80", google.MaxP99Latency)
+		}
+		if google.CircuitTrips != 1 || google.BrokenSnapshots != 1 || google.Snapshots != 2 {
+			t.Errorf("熔断统计错误: %+v", google)
+		}
+	})
+
+	t.Run("清理过期记录", func(t *testing.T) {
+		if err := CleanOldServerHealthHistory(7); err != nil {
+			t.Fatalf("CleanOldServerHealthHistory() error = %v", err)
+		}
+
+		var count int64
+		DB.Model(&ServerHealthHistory{}).Count(&count)
+		if count != 3 {
+			t.Errorf("count = %d, want 3", count)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/statsrollupdb.go
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 统计汇总层级
+const (
+	RollupTierMinute = "1m" // 1分钟汇总，由原始数据生成
+	RollupTierHour   = "1h" // 1小时汇总，由1分钟汇总生成
+	RollupTierDay    = "1d" // 1天汇总（本地时间），由1小时汇总生成
+)
+
+// 汇总指标
+const (
+	RollupMetricQPS         = "qps"
+	RollupMetricCPU         = "cpu"
+	RollupMetricMemory      = "memory"
+	RollupMetricDisk        = "disk"
+	RollupMetricInboundBps  = "inbound_bps"
+	RollupMetricOutboundBps = "outbound_bps"
+)
+
+// StatsRollup 统计汇总表
+// 每个层级的每个时间桶为每个指标写入一行，保留最小值、平均值和最大值
+type StatsRollup struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Tier      string    `json:"tier" gorm:"size:8;index:idx_stats_rollup_tier_metric;not null"`    // 汇总层级（1m, 1h, 1d）
+	Metric    string    `json:"metric" gorm:"size:32;index:idx_stats_rollup_tier_metric;not null"` // 指标名称
+	Timestamp time.Time `json:"timestamp" gorm:"index;not null"`                                   // 时间桶起始时间
+	Min       float64   `json:"min"`
+	Avg       float64   `json:"avg"`
+	Max       float64   `json:"max"`
+	Samples   int64     `json:"samples" gorm:"not null"` // 参与汇总的原始样本数
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (StatsRollup) TableName() string {
+	return "stats_rollup"
+}
+
+// SaveStatsRollups 保存一个层级在[from, to)内的汇总记录
+// 先删除该范围内已有的记录再写入，重复执行结果相同
+func SaveStatsRollups(tier string, from, to time.Time, records []StatsRollup) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tier = ? AND timestamp >= ? AND timestamp < ?", tier, from, to).Delete(&StatsRollup{}).Error; err != nil {
+			return fmt.Errorf("删除已有统计汇总记录失败: %v", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(records, 100).Error; err != nil {
+			return fmt.Errorf("保存统计汇总记录失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// GetStatsRollups 根据时间范围获取指定层级和指标的汇总记录
+// 参数：
+//   - tier: 汇总层级
+//   - metric: 指标名称，为空时返回所有指标
+//   - startTime: 开始时间
+//   - endTime: 结束时间（不包含）
+//
+// 返回：
+//   - []StatsRollup: 按时间升序排列的汇总记录
+//   - error: 查询失败时返回错误
+func GetStatsRollups(tier, metric string, startTime, endTime time.Time) ([]StatsRollup, error) {
+	var records []StatsRollup
+
+	query := DB.Where("tier = ? AND timestamp >= ? AND timestamp < ?", tier, startTime, endTime)
+	if metric != "" {
+		query = query.Where("metric = ?", metric)
+	}
+
+	if err := query.Order("timestamp ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询统计汇总记录失败: %v", err)
+	}
+
+	return records, nil
+}
+
+// GetLatestStatsRollupTime 获取指定层级最新时间桶的起始时间
+// 该层级没有记录时ok为false
+func GetLatestStatsRollupTime(tier string) (latest time.Time, ok bool, err error) {
+	return statsRollupBoundary(tier, "timestamp DESC")
+}
+
+// GetEarliestStatsRollupTime 获取指定层级最早时间桶的起始时间
+// 该层级没有记录时ok为false
+func GetEarliestStatsRollupTime(tier string) (earliest time.Time, ok bool, err error) {
+	return statsRollupBoundary(tier, "timestamp ASC")
+}
+
+// statsRollupBoundary 按指定排序获取层级的第一条记录时间
+func statsRollupBoundary(tier, order string) (time.Time, bool, error) {
+	var record StatsRollup
+	result := DB.Where("tier = ?", tier).Order(order).Limit(1).Find(&record)
+	if result.Error != nil {
+		return time.Time{}, false, fmt.Errorf("查询统计汇总记录时间失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return time.Time{}, false, nil
+	}
+	return record.Timestamp, true, nil
+}
+
+// GetEarliestRawStatsTime 获取QPS、资源使用和网络流量原始记录中最早的时间
+// 没有任何原始记录时ok为false
+func GetEarliestRawStatsTime() (earliest time.Time, ok bool, err error) {
+	for _, model := range []interface{}{&QPSHistory{}, &ResourceHistory{}, &NetworkHistory{}} {
+		var timestamps []time.Time
+		if err := DB.Model(model).Order("timestamp ASC").Limit(1).Pluck("timestamp", &timestamps).Error; err != nil {
+			return time.Time{}, false, fmt.Errorf("查询最早原始统计记录失败: %v", err)
+		}
+		if len(timestamps) > 0 && (!ok || timestamps[0].Before(earliest)) {
+			earliest = timestamps[0]
+			ok = true
+		}
+	}
+	return earliest, ok, nil
+}
+
+// CleanOldStatsRollups 清理指定层级过期的汇总记录
+func CleanOldStatsRollups(tier string, retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := DB.Where("tier = ? AND timestamp < ?", tier, cutoff).Delete(&StatsRollup{})
+	if result.Error != nil {
+		return fmt.Errorf("清理统计汇总记录失败: %v", result.Error)
+	}
+
+	if result.RowsAffected > 0 {
+		GetLogManager().logger.Info("清理了 %d 条过期的%s统计汇总记录", result.RowsAffected, tier)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/statsrollupdb_test.go
+// 统计汇总数据库操作测试
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestStatsRollups 测试汇总记录的幂等保存、查询、边界时间和按层级清理
+func TestStatsRollups(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	DB = db
+	defer func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}()
+	if err := DB.AutoMigrate(&StatsRollup{}, &QPSHistory{}, &ResourceHistory{}, &NetworkHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	hour := time.Now().Truncate(time.Hour)
+	records := []StatsRollup{
+		{Tier: RollupTierMinute, Metric: RollupMetricQPS, Timestamp: hour, Min: 1, Avg: 2, Max: 3, Samples: 6},
+		{Tier: RollupTierMinute, Metric: RollupMetricCPU, Timestamp: hour, Min: 10, Avg: 20, Max: 30, Samples: 6},
+		{Tier: RollupTierMinute, Metric: RollupMetricQPS, Timestamp: hour.Add(time.Minute), Min: 4, Avg: 5, Max: 6, Samples: 6},
+	}
+	// 重复保存同一范围不应产生重复记录
+	for i := 0; i < 2; i++ {
+		if err := SaveStatsRollups(RollupTierMinute, hour, hour.Add(2*time.Minute), records); err != nil {
+			t.Fatalf("SaveStatsRollups() error = %v", err)
+		}
+	}
+	old := StatsRollup{Tier: RollupTierHour, Metric: RollupMetricQPS, Timestamp: hour.AddDate(0, 0, -40), Min: 1, Avg: 1, Max: 1, Samples: 60}
+	if err := SaveStatsRollups(RollupTierHour, old.Timestamp, old.Timestamp.Add(time.Hour), []StatsRollup{old}); err != nil {
+		t.Fatalf("SaveStatsRollups() error = %v", err)
+	}
+
+	qps, err := GetStatsRollups(RollupTierMinute, RollupMetricQPS, hour, hour.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetStatsRollups() error = %v", err)
+	}
+	if len(qps) != 2 || qps[0].Avg != 2 || qps[1].Max != 6 {
+		t.Fatalf("查询结果 = %+v", qps)
+	}
+	all, _ := GetStatsRollups(RollupTierMinute, "", hour, hour.Add(time.Minute))
+	if len(all) != 2 {
+		t.Errorf("不指定指标且结束时间不包含时记录数 = %d, want 2", len(all))
+	}
+
+	latest, ok, err := GetLatestStatsRollupTime(RollupTierMinute)
+	if err != nil || !ok || !latest.Equal(hour.Add(time.Minute)) {
+		t.Errorf("GetLatestStatsRollupTime() = %v, %v, %v", latest, ok, err)
+	}
+	earliest, ok, err := GetEarliestStatsRollupTime(RollupTierMinute)
+	if err != nil || !ok || !earliest.Equal(hour) {
+		t.Errorf("GetEarliestStatsRollupTime() = %v, %v, %v", earliest, ok, err)
+	}
+	if _, ok, _ := GetLatestStatsRollupTime(RollupTierDay); ok {
+		t.Errorf("没有记录的层级应返回ok=false")
+	}
+
+	if _, ok, _ := GetEarliestRawStatsTime(); ok {
+		t.Errorf("没有原始记录时应返回ok=false")
+	}
+	DB.Create(&QPSHistory{Timestamp: hour.Add(-time.Minute), QPS: 1})
+	DB.Create(&NetworkHistory{Timestamp: hour.Add(-2 * time.Minute)})
+	raw, ok, err := GetEarliestRawStatsTime()
+	if err != nil || !ok || !raw.Equal(hour.Add(-2*time.Minute)) {
+		t.Errorf("GetEarliestRawStatsTime() = %v, %v, %v", raw, ok, err)
+	}
+
+	// 各层级按自己的保留天数清理
+	if err := CleanOldStatsRollups(RollupTierMinute, 30); err != nil {
+		t.Fatalf("CleanOldStatsRollups() error = %v", err)
+	}
+	var count int64
+	DB.Model(&StatsRollup{}).Where("tier = ?", RollupTierHour).Count(&count)
+	if count != 1 {
+		t.Errorf("清理分钟层级不应影响小时层级")
+	}
+	if err := CleanOldStatsRollups(RollupTierHour, 30); err != nil {
+		t.Fatalf("CleanOldStatsRollups() error = %v", err)
+	}
+	DB.Model(&StatsRollup{}).Count(&count)
+	if count != 3 {
+		t.Errorf("清理后记录数 = %d, want 3", count)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/tophistorydb.go
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TopNHistory 热门域名/客户端窗口快照表
+// 每个统计窗口为每类写入前N名，Count为窗口内的估算查询次数
+type TopNHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp time.Time `json:"timestamp" gorm:"index;not null"`    // 窗口起始时间
+	Kind      string    `json:"kind" gorm:"size:16;index;not null"` // 类型（domain, client）
+	Rank      int       `json:"rank" gorm:"not null"`               // 排名
+	Name      string    `json:"name" gorm:"size:255;not null"`      // 域名或客户端IP
+	Count     int64     `json:"count" gorm:"not null"`              // 估算查询次数
+	Error     int64     `json:"error"`                              // 计数最大高估值
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (TopNHistory) TableName() string {
+	return "top_n_history"
+}
+
+// UniqueCountHistory 窗口内去重域名数和客户端数估算表
+type UniqueCountHistory struct {
+	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Timestamp     time.Time `json:"timestamp" gorm:"index;not null"` // 窗口起始时间
+	Queries       int64     `json:"queries" gorm:"not null"`         // 窗口内查询次数
+	UniqueDomains int64     `json:"uniqueDomains" gorm:"not null"`   // 去重域名数估算
+	UniqueClients int64     `json:"uniqueClients" gorm:"not null"`   // 去重客户端数估算
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (UniqueCountHistory) TableName() string {
+	return "unique_count_history"
+}
+
+// SaveTopNSnapshot 在一个事务中保存一个窗口的热门项和去重计数
+func SaveTopNSnapshot(records []TopNHistory, unique *UniqueCountHistory) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if len(records) > 0 {
+			if err := tx.CreateInBatches(records, 100).Error; err != nil {
+				return fmt.Errorf("保存热门项快照失败: %v", err)
+			}
+		}
+		if unique != nil {
+			if err := tx.Create(unique).Error; err != nil {
+				return fmt.Errorf("保存去重计数快照失败: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetTopNHistoryByTimeRange 根据时间范围获取指定类型的热门项快照
+// 参数：
+//   - kind: 类型（domain, client）
+//   - startTime: 开始时间
+//   - endTime: 结束时间
+//   - maxRank: 每个窗口返回的最大排名，<=0时不限制
+//
+// 返回：
+//   - []TopNHistory: 按时间升序、排名升序排列的快照记录
+//   - error: 查询失败时返回错误
+func GetTopNHistoryByTimeRange(kind string, startTime, endTime time.Time, maxRank int) ([]TopNHistory, error) {
+	var records []TopNHistory
+
+	query := DB.Where("kind = ? AND timestamp >= ? AND timestamp <= ?", kind, startTime, endTime)
+	if maxRank > 0 {
+		query = query.Where("rank <= ?", maxRank)
+	}
+
+	if err := query.Order("timestamp ASC, rank ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询热门项快照失败: %v", err)
+	}
+
+	return records, nil
+}
+
+// GetUniqueCountHistoryByTimeRange 根据时间范围获取去重计数快照
+func GetUniqueCountHistoryByTimeRange(startTime, endTime time.Time) ([]UniqueCountHistory, error) {
+	var records []UniqueCountHistory
+
+	err := DB.Where("timestamp >= ? AND timestamp <= ?", startTime, endTime).
+		Order("timestamp ASC").
+		Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询去重计数快照失败: %v", err)
+	}
+
+	return records, nil
+}
+
+// CleanOldTopNHistory 清理过期的热门项和去重计数快照
+func CleanOldTopNHistory(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	result := DB.Where("timestamp < ?", cutoff).Delete(&TopNHistory{})
+	if result.Error != nil {
+		return fmt.Errorf("清理热门项快照失败: %v", result.Error)
+	}
+	deleted := result.RowsAffected
+
+	result = DB.Where("timestamp < ?", cutoff).Delete(&UniqueCountHistory{})
+	if result.Error != nil {
+		return fmt.Errorf("清理去重计数快照失败: %v", result.Error)
+	}
+	deleted += result.RowsAffected
+
+	if deleted > 0 {
+		GetLogManager().logger.Info("清理了 %d 条过期的热门项快照记录", deleted)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/database/tophistorydb_test.go
+// 热门项快照数据库操作测试
+
+package database
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestTopNHistory 测试热门项快照的保存、查询和清理
+func TestTopNHistory(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	DB = db
+	defer func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}()
+	if err := DB.AutoMigrate(&TopNHistory{}, &UniqueCountHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	now := time.Now()
+	windows := []time.Time{now.Add(-10 * 24 * time.Hour), now.Add(-10 * time.Minute), now.Add(-5 * time.Minute)}
+	for _, ts := range windows {
+		records := []TopNHistory{
+			{Timestamp: ts, Kind: "domain", Rank: 1, Name: "a.example.", Count: 30},
+			{Timestamp: ts, Kind: "domain", Rank: 2, Name: "b.example.", Count: 20, Error: 2},
+			{Timestamp: ts, Kind: "domain", Rank: 3, Name: "c.example.", Count: 10},
+			{Timestamp: ts, Kind: "client", Rank: 1, Name: "10.0.0.1", Count: 60},
+		}
+		if err := SaveTopNSnapshot(records, &UniqueCountHistory{Timestamp: ts, Queries: 60, UniqueDomains: 3, UniqueClients: 1}); err != nil {
+			t.Fatalf("SaveTopNSnapshot() error = %v", err)
+		}
+	}
+
+	t.Run("按类型和排名查询", func(t *testing.T) {
+		records, err := GetTopNHistoryByTimeRange("domain", now.Add(-time.Hour), now, 2)
+		if err != nil {
+			t.Fatalf("GetTopNHistoryByTimeRange() error = %v", err)
+		}
+		if len(records) != 4 {
+			t.Fatalf("len(records) = %d, want 4", len(records))
+		}
+		if records[0].Rank != 1 || records[1].Rank != 2 || !records[1].Timestamp.Before(records[2].Timestamp) {
+			t.Errorf("记录应按时间和排名升序排列: %+v", records)
+		}
+
+		uniques, err := GetUniqueCountHistoryByTimeRange(now.Add(-time.Hour), now)
+		if err != nil {
+			t.Fatalf("GetUniqueCountHistoryByTimeRange() error = %v", err)
+		}
+		if len(uniques) != 2 || !uniques[0].Timestamp.Equal(records[0].Timestamp) {
+			t.Errorf("去重计数快照与热门项时间不一致: %+v", uniques)
+		}
+	})
+
+	t.Run("清理过期快照", func(t *testing.T) {
+		if err := CleanOldTopNHistory(7); err != nil {
+			t.Fatalf("CleanOldTopNHistory() error = %v", err)
+		}
+		var topCount, uniqueCount int64
+		DB.Model(&TopNHistory{}).Count(&topCount)
+		DB.Model(&UniqueCountHistory{}).Count(&uniqueCount)
+		if topCount != 8 || uniqueCount != 2 {
+			t.Errorf("清理后记录数 top=%d unique=%d", topCount, uniqueCount)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/tsigkeydb.go
+// TSIG密钥存储
+
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TSIGKey TSIG密钥表
+// 用于区域传送、动态更新和rndc，由BIND插件渲染到named.conf的key配置中
+type TSIGKey struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name      string     `json:"name" gorm:"size:255;uniqueIndex;not null"` // 密钥名，不含结尾的点
+	Algorithm string     `json:"algorithm" gorm:"size:32;not null"`         // 算法，如hmac-sha256
+	Secret    string     `json:"secret,omitempty" gorm:"size:255;not null"` // base64编码的密钥
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`                       // 最近一次轮换时间
+	CreatedAt time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (TSIGKey) TableName() string {
+	return "tsig_keys"
+}
+
+// GetTSIGKeys 获取所有TSIG密钥，按名称排序
+func GetTSIGKeys() ([]TSIGKey, error) {
+	var keys []TSIGKey
+	if err := DB.Order("name ASC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("查询TSIG密钥失败: %v", err)
+	}
+	return keys, nil
+}
+
+// GetTSIGKeyByName 根据名称获取TSIG密钥
+func GetTSIGKeyByName(name string) (*TSIGKey, error) {
+	var key TSIGKey
+	if err := DB.Where("name = ?", name).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("TSIG密钥不存在")
+		}
+		return nil, fmt.Errorf("查询TSIG密钥失败: %v", err)
+	}
+	return &key, nil
+}
+
+// CreateTSIGKey 创建TSIG密钥
+func CreateTSIGKey(key *TSIGKey) error {
+	var count int64
+	DB.Model(&TSIGKey{}).Where("name = ?", key.Name).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("TSIG密钥名称已存在")
+	}
+
+	if err := DB.Create(key).Error; err != nil {
+		return fmt.Errorf("创建TSIG密钥失败: %v", err)
+	}
+	return nil
+}
+
+// UpdateTSIGKey 更新TSIG密钥的算法和密钥内容
+func UpdateTSIGKey(key *TSIGKey) error {
+	if err := DB.Save(key).Error; err != nil {
+		return fmt.Errorf("更新TSIG密钥失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteTSIGKey 删除TSIG密钥
+func DeleteTSIGKey(name string) error {
+	result := DB.Where("name = ?", name).Delete(&TSIGKey{})
+	if result.Error != nil {
+		return fmt.Errorf("删除TSIG密钥失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("TSIG密钥不存在")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/tsigkeydb_test.go
+// TSIG密钥数据库操作测试
+
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTSIGKeyTestDB 创建测试用的内存数据库
+func setupTSIGKeyTestDB(t *testing.T) func() {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+
+	DB = db
+
+	if err := DB.AutoMigrate(&TSIGKey{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	return func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}
+}
+
+// TestTSIGKeys 测试TSIG密钥的增删改查
+func TestTSIGKeys(t *testing.T) {
+	cleanup := setupTSIGKeyTestDB(t)
+	defer cleanup()
+
+	for _, name := range []string{"xfr-key", "ddns-key"} {
+		if err := CreateTSIGKey(&TSIGKey{Name: name, Algorithm: "hmac-sha256", Secret: "c2VjcmV0"}); err != nil {
+			t.Fatalf("CreateTSIGKey(%s) error = %v", name, err)
+		}
+	}
+	if err := CreateTSIGKey(&TSIGKey{Name: "ddns-key", Algorithm: "hmac-sha256", Secret: "c2VjcmV0"}); err == nil {
+		t.Errorf("重复的密钥名称应返回错误")
+	}
+
+	keys, err := GetTSIGKeys()
+	if err != nil || len(keys) != 2 || keys[0].Name != "ddns-key" {
+		t.Fatalf("GetTSIGKeys() = %+v, %v", keys, err)
+	}
+
+	key, err := GetTSIGKeyByName("xfr-key")
+	if err != nil {
+		t.Fatalf("GetTSIGKeyByName() error = %v", err)
+	}
+	key.Secret = "bmV3c2VjcmV0"
+	if err := UpdateTSIGKey(key); err != nil {
+		t.Fatalf("UpdateTSIGKey() error = %v", err)
+	}
+	if got, _ := GetTSIGKeyByName("xfr-key"); got == nil || got.Secret != "bmV3c2VjcmV0" {
+		t.Errorf("更新后的密钥 = %+v", got)
+	}
+
+	if err := DeleteTSIGKey("xfr-key"); err != nil {
+		t.Fatalf("DeleteTSIGKey() error = %v", err)
+	}
+	if err := DeleteTSIGKey("xfr-key"); err == nil {
+		t.Errorf("删除不存在的密钥应返回错误")
+	}
+	if _, err := GetTSIGKeyByName("xfr-key"); err == nil {
+		t.Errorf("删除后仍能查询到密钥")
+	}
+}
@@ -0,0 +1,71 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/zonesettingdb.go
+// 权威域管理设置存储
+
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// ZoneSetting 权威域管理设置表
+// 保存不属于BIND配置、仅由SteadyDNS使用的区域级选项
+type ZoneSetting struct {
+	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Domain    string    `json:"domain" gorm:"size:255;uniqueIndex;not null"` // 区域名称
+	AutoPTR   bool      `json:"autoPtr" gorm:"default:false"`                // A/AAAA记录变更时是否默认同步反向区域的PTR记录
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (ZoneSetting) TableName() string {
+	return "zone_settings"
+}
+
+// GetZoneSettings 获取所有区域的管理设置
+func GetZoneSettings() ([]ZoneSetting, error) {
+	var settings []ZoneSetting
+	if err := DB.Order("domain ASC").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("查询区域设置失败: %v", err)
+	}
+	return settings, nil
+}
+
+// SaveZoneSetting 保存区域的管理设置，区域已有设置时覆盖
+func SaveZoneSetting(setting *ZoneSetting) error {
+	var existing ZoneSetting
+	if err := DB.Where("domain = ?", setting.Domain).Limit(1).Find(&existing).Error; err != nil {
+		return fmt.Errorf("查询区域设置失败: %v", err)
+	}
+	setting.ID = existing.ID
+	setting.CreatedAt = existing.CreatedAt
+	if err := DB.Save(setting).Error; err != nil {
+		return fmt.Errorf("保存区域设置失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteZoneSetting 删除区域的管理设置，设置不存在时不返回错误
+func DeleteZoneSetting(domain string) error {
+	if err := DB.Where("domain = ?", domain).Delete(&ZoneSetting{}).Error; err != nil {
+		return fmt.Errorf("删除区域设置失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/database/zonesettingdb_test.go
+// 区域管理设置数据库操作测试
+
+package database
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupZoneSettingTestDB 创建测试用的内存数据库
+func setupZoneSettingTestDB(t *testing.T) func() {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+
+	DB = db
+
+	if err := DB.AutoMigrate(&ZoneSetting{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	return func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+		DB = nil
+	}
+}
+
+// TestZoneSettings 测试区域设置的保存、覆盖和删除
+func TestZoneSettings(t *testing.T) {
+	cleanup := setupZoneSettingTestDB(t)
+	defer cleanup()
+
+	if err := SaveZoneSetting(&ZoneSetting{Domain: "example.com", AutoPTR: true}); err != nil {
+		t.Fatalf("SaveZoneSetting() error = %v", err)
+	}
+	if err := SaveZoneSetting(&ZoneSetting{Domain: "example.net", AutoPTR: true}); err != nil {
+		t.Fatalf("SaveZoneSetting() error = %v", err)
+	}
+	// 覆盖已有设置，布尔零值也应写入
+	if err := SaveZoneSetting(&ZoneSetting{Domain: "example.com", AutoPTR: false}); err != nil {
+		t.Fatalf("SaveZoneSetting() error = %v", err)
+	}
+
+	settings, err := GetZoneSettings()
+	if err != nil || len(settings) != 2 {
+		t.Fatalf("GetZoneSettings() = %+v, %v", settings, err)
+	}
+	if settings[0].Domain != "example.com" || settings[0].AutoPTR || !settings[1].AutoPTR {
+		t.Errorf("区域设置不符合预期: %+v", settings)
+	}
+
+	if err := DeleteZoneSetting("example.com"); err != nil {
+		t.Fatalf("DeleteZoneSetting() error = %v", err)
+	}
+	if err := DeleteZoneSetting("missing.example"); err != nil {
+		t.Errorf("删除不存在的设置不应返回错误: %v", err)
+	}
+	if settings, _ := GetZoneSettings(); len(settings) != 1 || settings[0].Domain != "example.net" {
+		t.Errorf("删除后的区域设置 = %+v", settings)
+	}
+}
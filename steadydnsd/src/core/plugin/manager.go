@@ -179,8 +179,8 @@ func (pm *PluginManager) IsPluginEnabled(name string) bool {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	// 检查是否是预留插件
-	if name == PluginNameDNSRules || name == PluginNameLogAnalysis {
+	// 检查是否是未注册的预留插件
+	if pm.isReservedLocked(name) {
 		return pm.statuses[name]
 	}
 
@@ -204,8 +204,8 @@ func (pm *PluginManager) SetPluginEnabled(name string, enabled bool) error {
 	pm.mu.Lock()
 	defer pm.mu.Unlock()
 
-	// 检查是否是预留插件
-	if name == PluginNameDNSRules || name == PluginNameLogAnalysis {
+	// 检查是否是未注册的预留插件
+	if pm.isReservedLocked(name) {
 		pm.statuses[name] = enabled
 		pm.logger.Info("预留插件 %s 状态已设置为: %v", name, enabled)
 		return nil
@@ -220,6 +220,16 @@ func (pm *PluginManager) SetPluginEnabled(name string, enabled bool) error {
 	return nil
 }
 
+// isReservedLocked 检查是否是尚未注册实现的预留插件
+// 调用方需持有锁
+func (pm *PluginManager) isReservedLocked(name string) bool {
+	if name != PluginNameDNSRules && name != PluginNameLogAnalysis {
+		return false
+	}
+	_, registered := pm.plugins[name]
+	return !registered
+}
+
 // InitializeEnabledPlugins 初始化所有已启用的插件
 // 遍历所有插件，对启用状态的插件调用Initialize方法
 // 返回值：
@@ -19,7 +19,9 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -173,6 +175,147 @@ func (p *BindPlugin) Routes() []plugin.RouteDefinition {
 			Middlewares:  nil,
 		},
 
+		{
+			Method:       "POST",
+			Path:         "/api/bind-zones/import/preview",
+			Handler:      p.handlePreviewZoneImport,
+			Description:  "预览区域导入（上传区域文件或AXFR），返回差异和冲突报告",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "POST",
+			Path:         "/api/bind-zones/import",
+			Handler:      p.handleImportZone,
+			Description:  "导入区域（上传区域文件或AXFR）",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/bind-zones/:domain/export",
+			Handler:      p.handleExportZone,
+			Description:  "以规范表示格式导出区域文件",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/bind-zones/:domain/transfer-status",
+			Handler:      p.handleGetZoneTransferStatus,
+			Description:  "获取区域加载和传送状态（rndc zonestatus）",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "POST",
+			Path:         "/api/bind-zones/:domain/retransfer",
+			Handler:      p.handleRetransferZone,
+			Description:  "立即从主服务器重新传送辅区域",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/bind-zones/:domain/dnssec",
+			Handler:      p.handleGetDNSSECStatus,
+			Description:  "获取区域DNSSEC密钥状态、DS记录和签名有效期",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/bind-zones/:domain/lint",
+			Handler:      p.handleLintZone,
+			Description:  "检查区域数据，返回带规则ID和严重程度的检查结果",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+
+		// ==================== 记录管理路由 ====================
+		{
+			Method:       "GET",
+			Path:         "/api/bind-zones/:domain/records",
+			Handler:      p.handleListZoneRecords,
+			Description:  "获取权威域记录列表（支持过滤）",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "POST",
+			Path:         "/api/bind-zones/:domain/records",
+			Handler:      p.handleAddZoneRecord,
+			Description:  "添加单条记录",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "PUT",
+			Path:         "/api/bind-zones/:domain/records/:id",
+			Handler:      p.handleUpdateZoneRecord,
+			Description:  "修改单条记录",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "DELETE",
+			Path:         "/api/bind-zones/:domain/records/:id",
+			Handler:      p.handleDeleteZoneRecord,
+			Description:  "删除单条记录",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/bind-ptr-orphans",
+			Handler:      p.handleGetOrphanPTRs,
+			Description:  "获取反向区域中没有对应A/AAAA记录的孤立PTR记录",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+
+		// ==================== TSIG密钥管理路由 ====================
+		{
+			Method:       "GET",
+			Path:         "/api/bind-tsig-keys",
+			Handler:      p.handleListTSIGKeys,
+			Description:  "获取TSIG密钥列表（不含密钥内容）",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/bind-tsig-keys/:name",
+			Handler:      p.handleGetTSIGKey,
+			Description:  "获取单个TSIG密钥（含密钥内容）",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "POST",
+			Path:         "/api/bind-tsig-keys",
+			Handler:      p.handleCreateTSIGKey,
+			Description:  "创建TSIG密钥（未提供密钥内容时自动生成）",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "POST",
+			Path:         "/api/bind-tsig-keys/:name/rotate",
+			Handler:      p.handleRotateTSIGKey,
+			Description:  "轮换TSIG密钥",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+		{
+			Method:       "DELETE",
+			Path:         "/api/bind-tsig-keys/:name",
+			Handler:      p.handleDeleteTSIGKey,
+			Description:  "删除TSIG密钥",
+			AuthRequired: true,
+			Middlewares:  nil,
+		},
+
 		// ==================== BIND服务器管理路由 ====================
 		{
 			Method:       "GET",
@@ -418,11 +561,39 @@ func (p *BindPlugin) handleCreateBindZone(c *gin.Context) {
 		return
 	}
 
+	// 校验记录，结构化字段无效时直接拒绝
+	if err := bind.NormalizeRecords(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 校验区域类型、区域传送、动态更新和DNSSEC配置，辅区域需配置有效的主服务器
+	zoneType, err := bind.NormalizeZoneType(zone.Type)
+	if err == nil {
+		err = bind.ValidateZoneAccessClauses(zone)
+	}
+	if err == nil {
+		err = bind.ValidateDNSSECConfig(zone.Domain, zone.DNSSEC)
+	}
+	if err == nil && zoneType == bind.ZoneTypeSlave {
+		err = bind.ValidateSecondaryZone(zone)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	// 创建权威域
 	if err := p.bindManager.CreateAuthZone(zone); err != nil {
 		errMsg := "创建权威域失败: " + err.Error()
-		// 检查是否为CNAME冲突错误，如果是则返回400状态码
-		if strings.Contains(err.Error(), "CNAME") || strings.Contains(err.Error(), "记录名称冲突") {
+		// 区域数据检查未通过时返回400状态码
+		if errors.Is(err, bind.ErrZoneLint) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
 				"error":   errMsg,
@@ -485,11 +656,33 @@ func (p *BindPlugin) handleUpdateBindZone(c *gin.Context) {
 	// 确保域名一致
 	zone.Domain = domain
 
+	// 校验记录，结构化字段无效时直接拒绝
+	if err := bind.NormalizeRecords(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	// 校验区域传送、动态更新和DNSSEC配置
+	err := bind.ValidateZoneAccessClauses(zone)
+	if err == nil {
+		err = bind.ValidateDNSSECConfig(zone.Domain, zone.DNSSEC)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	// 更新权威域
 	if err := p.bindManager.UpdateAuthZone(zone); err != nil {
 		errMsg := "更新权威域失败: " + err.Error()
-		// 检查是否为CNAME冲突错误，如果是则返回400状态码
-		if strings.Contains(err.Error(), "CNAME") || strings.Contains(err.Error(), "记录名称冲突") {
+		// 区域数据检查未通过时返回400状态码
+		if errors.Is(err, bind.ErrZoneLint) {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
 				"error":   errMsg,
@@ -568,6 +761,729 @@ func (p *BindPlugin) handleDeleteBindZone(c *gin.Context) {
 	})
 }
 
+// ==================== 记录管理处理函数 ====================
+
+// recordErrorStatus 将记录操作的错误映射为HTTP状态码
+func recordErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, bind.ErrZoneNotFound), errors.Is(err, bind.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, bind.ErrZoneModified):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, bind.ErrReadOnlyZone):
+		return http.StatusForbidden
+	case errors.Is(err, bind.ErrInvalidRecord), errors.Is(err, bind.ErrZoneLint):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// onZoneRecordsChanged 记录变更后刷新权威域转发列表并清除缓存
+func (p *BindPlugin) onZoneRecordsChanged(domain string) {
+	if sdns.GlobalDNSForwarder != nil {
+		if err := sdns.GlobalDNSForwarder.GetAuthorityForwarder().ReloadAuthorityZones(); err != nil {
+			p.logger.Warn("刷新权威域转发列表失败: %v", err)
+		}
+	}
+	p.clearCacheAsync(domain)
+}
+
+// onPTRChanged 自动维护的PTR记录变更后清除反向区域相关缓存
+func (p *BindPlugin) onPTRChanged(changes []bind.PTRChange) {
+	for _, change := range changes {
+		if change.Action != bind.PTRActionSkipped {
+			p.clearCacheAsync(change.Zone)
+		}
+	}
+}
+
+// handleListZoneRecords 处理获取记录列表的请求
+// 查询参数: name（子串或*通配符）、type（逗号分隔）、value（子串）
+// 响应头ETag可用于后续修改请求的If-Match
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleListZoneRecords(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("获取记录列表请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	filter := bind.RecordFilter{
+		Name:  c.Query("name"),
+		Value: c.Query("value"),
+	}
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	result, err := p.bindManager.ListRecords(domain, filter)
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "获取记录列表失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", result.ETag)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// handleAddZoneRecord 处理添加单条记录的请求
+// 请求头If-Match可携带ETag或SOA序列号，不一致时返回412
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleAddZoneRecord(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("添加记录请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	var record bind.Record
+	if err := c.ShouldBindJSON(&record); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "解析请求体失败: " + err.Error(),
+		})
+		return
+	}
+
+	created, etag, err := p.bindManager.AddRecord(domain, record, c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "添加记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	p.onZoneRecordsChanged(domain)
+	p.onPTRChanged(created.PTRChanges)
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"record": created,
+			"etag":   etag,
+		},
+	})
+}
+
+// handleUpdateZoneRecord 处理修改单条记录的请求
+// 修改后记录ID会随内容变化，响应中返回新的记录和ETag
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleUpdateZoneRecord(c *gin.Context) {
+	domain := c.Param("domain")
+	id := c.Param("id")
+	p.logger.Debug("修改记录请求，域名: %s，记录: %s", domain, id)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	var record bind.Record
+	if err := c.ShouldBindJSON(&record); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "解析请求体失败: " + err.Error(),
+		})
+		return
+	}
+
+	updated, etag, err := p.bindManager.UpdateRecord(domain, id, record, c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "修改记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	p.onZoneRecordsChanged(domain)
+	p.onPTRChanged(updated.PTRChanges)
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"record": updated,
+			"etag":   etag,
+		},
+	})
+}
+
+// handleDeleteZoneRecord 处理删除单条记录的请求
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleDeleteZoneRecord(c *gin.Context) {
+	domain := c.Param("domain")
+	id := c.Param("id")
+	p.logger.Debug("删除记录请求，域名: %s，记录: %s", domain, id)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	// 查询参数auto_ptr=false时保留指向该记录的PTR
+	var autoPTR *bool
+	if v := c.Query("auto_ptr"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "auto_ptr参数无效: " + v,
+			})
+			return
+		}
+		autoPTR = &enabled
+	}
+
+	deleted, etag, err := p.bindManager.DeleteRecord(domain, id, c.GetHeader("If-Match"), autoPTR)
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "删除记录失败: " + err.Error(),
+		})
+		return
+	}
+
+	p.onZoneRecordsChanged(domain)
+	p.onPTRChanged(deleted.PTRChanges)
+
+	c.Header("ETag", etag)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"message":     "记录删除成功",
+			"etag":        etag,
+			"ptr_changes": deleted.PTRChanges,
+		},
+	})
+}
+
+// handleGetOrphanPTRs 处理获取孤立PTR记录报告的请求
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleGetOrphanPTRs(c *gin.Context) {
+	p.logger.Debug("获取孤立PTR记录报告请求")
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	report, err := p.bindManager.GetOrphanPTRReport()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取孤立PTR记录报告失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// tsigKeyErrorStatus 将TSIG密钥操作错误映射为HTTP状态码
+func tsigKeyErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "不存在"):
+		return http.StatusNotFound
+	case errors.Is(err, bind.ErrTSIGKeyInUse), strings.Contains(msg, "已存在"):
+		return http.StatusConflict
+	case strings.Contains(msg, "无效"), strings.Contains(msg, "不支持"),
+		strings.Contains(msg, "不能为空"), strings.Contains(msg, "base64"):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// onTSIGKeysChanged 密钥变更后重新加载动态更新使用的密钥
+func (p *BindPlugin) onTSIGKeysChanged() {
+	if err := sdns.ReloadTSIGKeys(); err != nil {
+		p.logger.Warn("重新加载动态更新密钥失败: %v", err)
+	}
+}
+
+// handleListTSIGKeys 处理获取TSIG密钥列表的请求
+func (p *BindPlugin) handleListTSIGKeys(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	keys, err := p.bindManager.ListTSIGKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "获取TSIG密钥失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+// handleGetTSIGKey 处理获取单个TSIG密钥的请求，返回密钥内容用于配置对端
+func (p *BindPlugin) handleGetTSIGKey(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	key, err := p.bindManager.GetTSIGKey(c.Param("name"))
+	if err != nil {
+		c.JSON(tsigKeyErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "获取TSIG密钥失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// handleCreateTSIGKey 处理创建TSIG密钥的请求
+// 请求体: name、algorithm（默认hmac-sha256）、secret（为空时自动生成）
+func (p *BindPlugin) handleCreateTSIGKey(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	var req struct {
+		Name      string `json:"name"`
+		Algorithm string `json:"algorithm"`
+		Secret    string `json:"secret"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "解析请求体失败: " + err.Error(),
+		})
+		return
+	}
+
+	key, err := p.bindManager.CreateTSIGKey(req.Name, req.Algorithm, req.Secret)
+	if err != nil {
+		c.JSON(tsigKeyErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "创建TSIG密钥失败: " + err.Error(),
+		})
+		return
+	}
+	p.onTSIGKeysChanged()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// handleRotateTSIGKey 处理轮换TSIG密钥的请求
+// 请求体可选: secret（为空时自动生成），算法保持不变
+func (p *BindPlugin) handleRotateTSIGKey(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	var req struct {
+		Secret string `json:"secret"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "解析请求体失败: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	key, err := p.bindManager.RotateTSIGKey(c.Param("name"), req.Secret)
+	if err != nil {
+		c.JSON(tsigKeyErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "轮换TSIG密钥失败: " + err.Error(),
+		})
+		return
+	}
+	p.onTSIGKeysChanged()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// handleDeleteTSIGKey 处理删除TSIG密钥的请求，仍被区域引用的密钥不能删除
+func (p *BindPlugin) handleDeleteTSIGKey(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	name := c.Param("name")
+	if err := p.bindManager.DeleteTSIGKey(name); err != nil {
+		c.JSON(tsigKeyErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "删除TSIG密钥失败: " + err.Error(),
+		})
+		return
+	}
+	p.onTSIGKeysChanged()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": map[string]string{
+			"message": "TSIG密钥删除成功",
+			"name":    name,
+		},
+	})
+}
+
+// maxZoneImportSize 上传区域文件的大小上限
+const maxZoneImportSize = 16 << 20
+
+// bindZoneImportRequest 解析区域导入请求，支持JSON请求体或带file字段的multipart表单
+func bindZoneImportRequest(c *gin.Context) (bind.ZoneImportRequest, error) {
+	var req bind.ZoneImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		return req, fmt.Errorf("解析请求体失败: %v", err)
+	}
+	if fh, err := c.FormFile("file"); err == nil {
+		if fh.Size > maxZoneImportSize {
+			return req, fmt.Errorf("区域文件超过大小限制: %d字节", maxZoneImportSize)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return req, fmt.Errorf("读取上传文件失败: %v", err)
+		}
+		defer f.Close()
+		content, err := io.ReadAll(io.LimitReader(f, maxZoneImportSize))
+		if err != nil {
+			return req, fmt.Errorf("读取上传文件失败: %v", err)
+		}
+		req.Content = string(content)
+	}
+	return req, nil
+}
+
+// zoneImportErrorStatus 将区域导入错误映射为HTTP状态码
+func zoneImportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, bind.ErrImportConflict):
+		return http.StatusConflict
+	case errors.Is(err, bind.ErrInvalidImport):
+		return http.StatusBadRequest
+	case strings.Contains(err.Error(), "AXFR"), strings.Contains(err.Error(), "TSIG密钥不存在"):
+		return http.StatusBadGateway
+	}
+	return recordErrorStatus(err)
+}
+
+// handlePreviewZoneImport 处理区域导入预览请求，不修改任何配置
+// 请求体: domain，content（区域文件内容，或以multipart的file字段上传）或 server+key_name（AXFR）
+func (p *BindPlugin) handlePreviewZoneImport(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	req, err := bindZoneImportRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	p.logger.Debug("区域导入预览请求，域名: %s，AXFR服务器: %s", req.Domain, req.Server)
+
+	preview, err := p.bindManager.PreviewZoneImport(req)
+	if err != nil {
+		c.JSON(zoneImportErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "区域导入预览失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    preview,
+	})
+}
+
+// handleImportZone 处理区域导入请求，存在阻止性冲突时返回409及冲突报告
+func (p *BindPlugin) handleImportZone(c *gin.Context) {
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	req, err := bindZoneImportRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	p.logger.Debug("区域导入请求，域名: %s，AXFR服务器: %s", req.Domain, req.Server)
+
+	preview, err := p.bindManager.ImportZone(req)
+	if err != nil {
+		c.JSON(zoneImportErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "区域导入失败: " + err.Error(),
+			"data":    preview,
+		})
+		return
+	}
+
+	p.onZoneRecordsChanged(preview.Zone.Domain)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    preview,
+	})
+}
+
+// handleExportZone 处理区域导出请求，以附件形式返回规范表示格式的区域文件
+func (p *BindPlugin) handleExportZone(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("导出区域请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	content, err := p.bindManager.ExportZone(domain)
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "导出区域失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", domain+".zone"))
+	c.Data(http.StatusOK, "text/dns; charset=utf-8", []byte(content))
+}
+
+// handleGetZoneTransferStatus 处理获取区域传送状态的请求
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleGetZoneTransferStatus(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("获取区域传送状态请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	status, err := p.bindManager.GetZoneTransferStatus(domain)
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "获取区域传送状态失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// handleGetDNSSECStatus 处理获取区域DNSSEC签名状态的请求
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleGetDNSSECStatus(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("获取DNSSEC签名状态请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	status, err := p.bindManager.GetDNSSECStatus(domain)
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "获取DNSSEC签名状态失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// handleLintZone 处理按需检查区域数据的请求
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleLintZone(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("检查区域数据请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	report, err := p.bindManager.LintAuthZone(domain)
+	if err != nil {
+		c.JSON(recordErrorStatus(err), gin.H{
+			"success": false,
+			"error":   "检查区域数据失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// handleRetransferZone 处理辅区域立即重新传送的请求
+// 参数:
+//   - c: Gin上下文
+func (p *BindPlugin) handleRetransferZone(c *gin.Context) {
+	domain := c.Param("domain")
+	p.logger.Debug("重新传送辅区域请求，域名: %s", domain)
+
+	// 检查插件是否已初始化
+	if !p.initialized || p.bindManager == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "BIND插件未初始化",
+		})
+		return
+	}
+
+	if err := p.bindManager.RetransferZone(domain); err != nil {
+		status := recordErrorStatus(err)
+		if errors.Is(err, bind.ErrNotSecondaryZone) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"error":   "重新传送辅区域失败: " + err.Error(),
+		})
+		return
+	}
+
+	// 传送在BIND中异步完成，清除缓存以便尽快返回新数据
+	p.clearCacheAsync(domain)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": map[string]string{
+			"message": "已请求重新传送",
+			"domain":  domain,
+		},
+	})
+}
+
 // handleReloadBindZone 处理刷新权威域的请求
 // 参数:
 //   - c: Gin上下文
@@ -0,0 +1,336 @@
+// core/plugin/plugins/log_analysis_plugin.go
+//
+// SteadyDNS Log Analysis Plugin Implementation
+// Copyright (C) 2024 SteadyDNS Project
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package plugins
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/database"
+	"SteadyDNS/core/plugin"
+	"SteadyDNS/core/sdns"
+)
+
+// maxQueryLogExportRows CSV导出的最大记录数
+const maxQueryLogExportRows = 100000
+
+// LogAnalysisPlugin 日志分析插件
+// 将查询日志写入可检索的索引存储，并提供查询和导出API
+type LogAnalysisPlugin struct {
+	// logger 日志记录器
+	logger *common.Logger
+	// store 查询日志存储
+	store *sdns.QueryLogStore
+	// detector 异常检测器，未启用异常检测时为nil
+	detector *sdns.AnomalyDetector
+}
+
+// QueryLogListResponse 查询日志列表响应结构体
+type QueryLogListResponse struct {
+	Entries  []database.QueryLogEntry `json:"entries"`
+	Total    int64                    `json:"total"`
+	Page     int                      `json:"page"`
+	PageSize int                      `json:"pageSize"`
+}
+
+// NewLogAnalysisPlugin 创建日志分析插件实例
+// 返回值: 日志分析插件实例指针
+func NewLogAnalysisPlugin() *LogAnalysisPlugin {
+	return &LogAnalysisPlugin{
+		logger: common.NewLogger(),
+	}
+}
+
+// Name 返回插件的唯一标识名称
+// 返回值: 插件名称字符串 "log-analysis"
+func (p *LogAnalysisPlugin) Name() string {
+	return plugin.PluginNameLogAnalysis
+}
+
+// Description 返回插件的功能描述
+// 返回值: 插件描述字符串
+func (p *LogAnalysisPlugin) Description() string {
+	return "日志分析插件 - 提供查询日志索引存储、检索、CSV导出和DNS隧道/DGA异常检测功能"
+}
+
+// Version 返回插件的版本号
+// 返回值: 版本号字符串 "1.0.0"
+func (p *LogAnalysisPlugin) Version() string {
+	return "1.0.0"
+}
+
+// Initialize 初始化插件
+// 打开查询日志数据库并启动写入协程，需在DNS服务器启动前调用
+// 返回值: 初始化错误信息，nil表示成功
+func (p *LogAnalysisPlugin) Initialize() error {
+	p.logger.Info("初始化日志分析插件...")
+
+	store, err := sdns.StartQueryLogStore(p.logger)
+	if err != nil {
+		return fmt.Errorf("启动查询日志存储失败: %v", err)
+	}
+	p.store = store
+
+	p.detector = sdns.StartAnomalyDetector(p.logger)
+	if p.detector != nil {
+		p.logger.Info("DNS异常检测已启用")
+	}
+
+	p.logger.Info("日志分析插件初始化完成")
+	return nil
+}
+
+// Shutdown 关闭插件
+// 写入剩余的查询日志并关闭数据库
+// 返回值: 关闭错误信息，nil表示成功
+func (p *LogAnalysisPlugin) Shutdown() error {
+	p.logger.Info("关闭日志分析插件...")
+
+	sdns.StopAnomalyDetector()
+	p.detector = nil
+	sdns.StopQueryLogStore()
+	p.store = nil
+
+	p.logger.Info("日志分析插件已关闭")
+	return nil
+}
+
+// Routes 返回插件提供的HTTP路由定义列表
+// 返回值: 路由定义切片
+func (p *LogAnalysisPlugin) Routes() []plugin.RouteDefinition {
+	return []plugin.RouteDefinition{
+		{
+			Method:       "GET",
+			Path:         "/api/query-logs",
+			Handler:      p.handleSearchQueryLogs,
+			Description:  "检索查询日志",
+			AuthRequired: true,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/query-logs/export",
+			Handler:      p.handleExportQueryLogs,
+			Description:  "导出查询日志为CSV",
+			AuthRequired: true,
+		},
+		{
+			Method:       "GET",
+			Path:         "/api/query-logs/status",
+			Handler:      p.handleGetQueryLogStatus,
+			Description:  "获取查询日志存储状态",
+			AuthRequired: true,
+		},
+		{
+			Method:       "DELETE",
+			Path:         "/api/query-logs/quarantine/:client",
+			Handler:      p.handleReleaseQuarantine,
+			Description:  "解除异常检测对客户端的隔离",
+			AuthRequired: true,
+		},
+	}
+}
+
+// parseQueryLogFilter 解析查询日志检索参数
+// 支持startTime、endTime（RFC3339）、client、qname（子串）、qtype、rcode、cache、upstream
+func parseQueryLogFilter(c *gin.Context) (database.QueryLogFilter, error) {
+	filter := database.QueryLogFilter{
+		ClientIP:    c.Query("client"),
+		QName:       c.Query("qname"),
+		QType:       c.Query("qtype"),
+		RCode:       c.Query("rcode"),
+		CacheStatus: c.Query("cache"),
+		Upstream:    c.Query("upstream"),
+	}
+
+	if s := c.Query("startTime"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return filter, fmt.Errorf("无效的startTime参数，需为RFC3339格式")
+		}
+		filter.StartTime = t
+	}
+	if s := c.Query("endTime"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return filter, fmt.Errorf("无效的endTime参数，需为RFC3339格式")
+		}
+		filter.EndTime = t
+	}
+	return filter, nil
+}
+
+// handleSearchQueryLogs 处理查询日志检索请求
+// 支持分页参数page和pageSize（最大1000）
+func (p *LogAnalysisPlugin) handleSearchQueryLogs(c *gin.Context) {
+	filter, err := parseQueryLogFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "50"))
+	if err != nil || pageSize < 1 || pageSize > 1000 {
+		pageSize = 50
+	}
+	filter.Page = page
+	filter.PageSize = pageSize
+
+	entries, total, err := database.SearchQueryLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": QueryLogListResponse{
+			Entries:  entries,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		},
+	})
+}
+
+// handleExportQueryLogs 处理查询日志CSV导出请求
+// 使用与检索相同的过滤参数，limit指定最大导出条数（默认且最多100000）
+func (p *LogAnalysisPlugin) handleExportQueryLogs(c *gin.Context) {
+	filter, err := parseQueryLogFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxQueryLogExportRows)))
+	if err != nil || limit < 1 || limit > maxQueryLogExportRows {
+		limit = maxQueryLogExportRows
+	}
+
+	// 收到第一批记录后才发送响应头，查询失败时仍可返回错误状态
+	w := csv.NewWriter(c.Writer)
+	started := false
+	start := func() {
+		filename := fmt.Sprintf("steadydns-query-logs-%s.csv", time.Now().Format("20060102-150405"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Status(http.StatusOK)
+		w.Write([]string{"timestamp", "client_ip", "client_port", "protocol", "qname", "qtype", "rcode",
+			"cache", "group", "upstream", "security", "answer_count", "answers", "duration_ms", "error"})
+		started = true
+	}
+
+	err = database.ExportQueryLogs(filter, limit, func(entries []database.QueryLogEntry) error {
+		if !started {
+			start()
+		}
+		for _, e := range entries {
+			w.Write([]string{
+				e.Timestamp.Format(time.RFC3339Nano),
+				csvSafe(e.ClientIP),
+				strconv.Itoa(e.ClientPort),
+				csvSafe(e.Protocol),
+				csvSafe(e.QName),
+				csvSafe(e.QType),
+				csvSafe(e.RCode),
+				csvSafe(e.CacheStatus),
+				csvSafe(e.ForwardGroup),
+				csvSafe(e.Upstream),
+				csvSafe(e.Security),
+				strconv.Itoa(e.AnswerCount),
+				csvSafe(e.Answers),
+				strconv.FormatFloat(e.DurationMs, 'f', 3, 64),
+				csvSafe(e.Error),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		if !started {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		// 响应头已发送，只能记录错误
+		p.logger.Error("导出查询日志失败: %v", err)
+		return
+	}
+	if !started {
+		start()
+	}
+	w.Flush()
+}
+
+// csvSafe 转义可能被电子表格解释为公式的字段
+// 查询名、应答等内容来自客户端或上游，以=、+、-、@、制表符或回车开头时加单引号前缀，防止CSV公式注入
+func csvSafe(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// handleGetQueryLogStatus 处理查询日志存储状态请求
+func (p *LogAnalysisPlugin) handleGetQueryLogStatus(c *gin.Context) {
+	if p.store == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "查询日志存储未启动"})
+		return
+	}
+
+	status, err := p.store.GetStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    status,
+	})
+}
+
+// handleReleaseQuarantine 处理解除客户端隔离请求
+func (p *LogAnalysisPlugin) handleReleaseQuarantine(c *gin.Context) {
+	if sdns.GlobalSecurityManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "DNS服务器未启动"})
+		return
+	}
+
+	client := c.Param("client")
+	if !sdns.GlobalSecurityManager.ReleaseQuarantine(client) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "客户端未被隔离"})
+		return
+	}
+
+	p.logger.Info("已解除客户端 %s 的隔离", client)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已解除隔离",
+	})
+}
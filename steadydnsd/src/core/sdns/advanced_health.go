@@ -287,6 +287,7 @@ func CheckCircuitBreaker(stats *ServerStats) bool {
 	if stats.ConsecutiveFails >= DefaultFailureThreshold {
 		stats.CircuitBroken = true
 		stats.ProbeMode = true
+		stats.CircuitTrips++
 		return true
 	}
 
@@ -310,6 +311,9 @@ func ResetCircuitBreaker(stats *ServerStats) {
 	stats.Mu.Lock()
 	defer stats.Mu.Unlock()
 
+	if stats.CircuitBroken {
+		stats.CircuitRecoveries++
+	}
 	stats.CircuitBroken = false
 	stats.ProbeMode = false
 	stats.ConsecutiveFails = 0
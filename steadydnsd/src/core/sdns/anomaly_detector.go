@@ -0,0 +1,574 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/anomaly_detector.go
+// DNS隧道和DGA流量异常检测
+
+package sdns
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+
+	"SteadyDNS/core/common"
+)
+
+// 异常类型
+const (
+	AnomalyHighEntropy   = "high_entropy"    // 客户端的高熵查询名数量超过阈值
+	AnomalyLongQName     = "long_qname"      // 客户端的超长查询名数量超过阈值
+	AnomalyTXTNullVolume = "txt_null_volume" // 客户端的TXT/NULL查询量超过阈值
+	AnomalySubdomainRate = "subdomain_rate"  // 注册域名下的去重子域名数超过阈值
+	AnomalyNXDomainRatio = "nxdomain_ratio"  // 客户端的NXDOMAIN比例超过阈值
+)
+
+// anomalyDescriptions 异常类型说明，用于告警消息
+var anomalyDescriptions = map[string]string{
+	AnomalyHighEntropy:   "高熵查询名",
+	AnomalyLongQName:     "超长查询名",
+	AnomalyTXTNullVolume: "TXT/NULL查询量过高",
+	AnomalySubdomainRate: "去重子域名速率过高",
+	AnomalyNXDomainRatio: "NXDOMAIN比例过高",
+}
+
+const (
+	// anomalyMaxTrackedKeys 单个窗口内跟踪的客户端和注册域名数量上限，超出后新出现的对象不再计数
+	anomalyMaxTrackedKeys = 50000
+	// anomalyMaxAlerts 保留的最近告警数量
+	anomalyMaxAlerts = 500
+	// anomalyMaxOffenders 保留的异常客户端和异常域名数量上限
+	anomalyMaxOffenders = 1000
+	// anomalyOffenderTTL 异常客户端和异常域名在最后一次告警后的保留时长
+	anomalyOffenderTTL = 24 * time.Hour
+)
+
+// GlobalAnomalyDetector 全局异常检测器实例，日志分析插件启用时创建
+var GlobalAnomalyDetector *AnomalyDetector
+
+// AnomalyConfig 异常检测配置
+type AnomalyConfig struct {
+	WindowSeconds            int      `json:"windowSeconds"`
+	EntropyThreshold         float64  `json:"entropyThreshold"`
+	EntropyMinLength         int      `json:"entropyMinLength"`
+	QNameLengthThreshold     int      `json:"qnameLengthThreshold"`
+	SuspiciousQueryThreshold int      `json:"suspiciousQueryThreshold"`
+	TXTNullThreshold         int      `json:"txtNullThreshold"`
+	UniqueSubdomainThreshold int      `json:"uniqueSubdomainThreshold"`
+	NXDomainRatio            float64  `json:"nxdomainRatio"`
+	NXDomainMinQueries       int      `json:"nxdomainMinQueries"`
+	Allowlist                []string `json:"allowlist"`
+	Quarantine               bool     `json:"quarantine"`
+	QuarantineMinutes        int      `json:"quarantineMinutes"`
+}
+
+// LoadAnomalyConfig 从[AnomalyDetection]配置节读取异常检测配置，无效值使用默认值
+func LoadAnomalyConfig() AnomalyConfig {
+	positiveInt := func(key string, defaultVal int) int {
+		if v := common.GetConfigInt("AnomalyDetection", key, defaultVal); v > 0 {
+			return v
+		}
+		return defaultVal
+	}
+
+	cfg := AnomalyConfig{
+		WindowSeconds:            positiveInt("ANOMALY_WINDOW_SECONDS", 60),
+		EntropyThreshold:         common.GetConfigFloat("AnomalyDetection", "ANOMALY_ENTROPY_THRESHOLD", 4.0),
+		EntropyMinLength:         positiveInt("ANOMALY_ENTROPY_MIN_LENGTH", 20),
+		QNameLengthThreshold:     positiveInt("ANOMALY_QNAME_LENGTH_THRESHOLD", 100),
+		SuspiciousQueryThreshold: positiveInt("ANOMALY_SUSPICIOUS_QUERY_THRESHOLD", 20),
+		TXTNullThreshold:         positiveInt("ANOMALY_TXT_NULL_THRESHOLD", 100),
+		UniqueSubdomainThreshold: positiveInt("ANOMALY_UNIQUE_SUBDOMAIN_THRESHOLD", 200),
+		NXDomainRatio:            common.GetConfigFloat("AnomalyDetection", "ANOMALY_NXDOMAIN_RATIO", 0.5),
+		NXDomainMinQueries:       positiveInt("ANOMALY_NXDOMAIN_MIN_QUERIES", 50),
+		Allowlist:                splitAnomalyAllowlist(common.GetConfig("AnomalyDetection", "ANOMALY_ALLOWLIST")),
+		Quarantine:               common.GetConfigBool("AnomalyDetection", "ANOMALY_QUARANTINE_ENABLED", false),
+		QuarantineMinutes:        positiveInt("ANOMALY_QUARANTINE_MINUTES", 30),
+	}
+	if cfg.EntropyThreshold <= 0 {
+		cfg.EntropyThreshold = 4.0
+	}
+	if cfg.NXDomainRatio <= 0 || cfg.NXDomainRatio > 1 {
+		cfg.NXDomainRatio = 0.5
+	}
+	return cfg
+}
+
+// window 计数窗口时长
+func (c AnomalyConfig) window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// quarantineDuration 隔离时长
+func (c AnomalyConfig) quarantineDuration() time.Duration {
+	return time.Duration(c.QuarantineMinutes) * time.Minute
+}
+
+// splitAnomalyAllowlist 解析逗号分隔的白名单域名
+func splitAnomalyAllowlist(value string) []string {
+	var domains []string
+	for _, d := range strings.Split(value, ",") {
+		d = strings.ToLower(strings.Trim(strings.TrimSpace(d), "."))
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// QueryScore 单个查询名的评分结果
+type QueryScore struct {
+	RegisteredDomain string  `json:"registeredDomain"` // 注册域名（公共后缀+1）
+	Subdomain        string  `json:"subdomain"`        // 注册域名之前的部分
+	Entropy          float64 `json:"entropy"`          // 子域名部分的香农熵（比特/字符）
+	Length           int     `json:"length"`           // 查询名长度
+	HighEntropy      bool    `json:"highEntropy"`
+	LongQName        bool    `json:"longQName"`
+}
+
+// AnomalyAlert 异常告警
+type AnomalyAlert struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Client      string    `json:"client,omitempty"`
+	Domain      string    `json:"domain,omitempty"`
+	Value       float64   `json:"value"`
+	Threshold   float64   `json:"threshold"`
+	Quarantined bool      `json:"quarantined"`
+
+	clientIP string // 真实客户端地址，用于隔离，Client可能为匿名化后的地址
+}
+
+// AnomalyOffender 触发过告警的客户端或注册域名
+type AnomalyOffender struct {
+	Key       string    `json:"key"`
+	Alerts    int64     `json:"alerts"`
+	Types     []string  `json:"types"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// AnomalyReport 异常检测报告
+type AnomalyReport struct {
+	Config       AnomalyConfig       `json:"config"`
+	Scored       int64               `json:"scored"`      // 累计评分的查询数
+	TotalAlerts  int64               `json:"totalAlerts"` // 累计告警数
+	RecentAlerts []AnomalyAlert      `json:"recentAlerts"`
+	Clients      []AnomalyOffender   `json:"clients"`
+	Domains      []AnomalyOffender   `json:"domains"`
+	Quarantined  []QuarantinedClient `json:"quarantined"`
+}
+
+// anomalyClientWindow 客户端在当前窗口内的计数
+type anomalyClientWindow struct {
+	total       int
+	nxdomain    int
+	txtNull     int
+	highEntropy int
+	longQName   int
+	lastDomain  string // 最近一次可疑查询的注册域名
+}
+
+// anomalyDomainWindow 注册域名在当前窗口内的去重子域名
+// 子域名只保存哈希值，数量达到阈值后不再增加
+type anomalyDomainWindow struct {
+	subdomains map[uint64]struct{}
+	lastClient string
+}
+
+// AnomalyDetector 查询流异常检测器
+// 实现QueryLogSink，在查询处理协程中逐条评分，按固定窗口累计客户端和注册域名的信号，
+// 超过阈值时告警（每个窗口内同一类型和对象只告警一次），可选通过速率限制器隔离客户端
+type AnomalyDetector struct {
+	mu     sync.Mutex
+	config AnomalyConfig
+	allow  map[string]bool
+	logger *common.Logger
+
+	windowStart time.Time
+	clients     map[string]*anomalyClientWindow
+	domains     map[string]*anomalyDomainWindow
+	fired       map[string]bool
+
+	alerts          []AnomalyAlert
+	clientOffenders map[string]*AnomalyOffender
+	domainOffenders map[string]*AnomalyOffender
+
+	scoredCount int64
+	alertCount  int64
+	stopped     int32
+}
+
+// NewAnomalyDetector 创建异常检测器
+func NewAnomalyDetector(config AnomalyConfig, logger *common.Logger) *AnomalyDetector {
+	allow := make(map[string]bool, len(config.Allowlist))
+	for _, d := range config.Allowlist {
+		allow[d] = true
+	}
+	return &AnomalyDetector{
+		config:          config,
+		allow:           allow,
+		logger:          logger,
+		clients:         make(map[string]*anomalyClientWindow),
+		domains:         make(map[string]*anomalyDomainWindow),
+		fired:           make(map[string]bool),
+		clientOffenders: make(map[string]*AnomalyOffender),
+		domainOffenders: make(map[string]*AnomalyOffender),
+	}
+}
+
+// StartAnomalyDetector 按配置创建异常检测器并设置GlobalAnomalyDetector
+// 配置未启用时返回nil
+func StartAnomalyDetector(logger *common.Logger) *AnomalyDetector {
+	if !common.GetConfigBool("AnomalyDetection", "ANOMALY_DETECTION_ENABLED", true) {
+		return nil
+	}
+	d := NewAnomalyDetector(LoadAnomalyConfig(), logger)
+	GlobalAnomalyDetector = d
+	return d
+}
+
+// StopAnomalyDetector 停止全局异常检测器
+func StopAnomalyDetector() {
+	if GlobalAnomalyDetector == nil {
+		return
+	}
+	atomic.StoreInt32(&GlobalAnomalyDetector.stopped, 1)
+	GlobalAnomalyDetector = nil
+}
+
+// labelEntropy 计算字符串的香农熵（比特/字符），忽略标签分隔点
+func labelEntropy(s string) float64 {
+	var counts [256]int
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '.' {
+			continue
+		}
+		counts[s[i]]++
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+
+	var entropy float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(n)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// ScoreQuery 计算查询名的熵和长度信号
+func (d *AnomalyDetector) ScoreQuery(qname string) QueryScore {
+	name := strings.ToLower(strings.TrimSuffix(qname, "."))
+	score := QueryScore{Length: len(name)}
+
+	registered, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		// 查询名本身就是公共后缀或格式无效
+		registered = name
+	}
+	score.RegisteredDomain = registered
+	if name != registered {
+		score.Subdomain = strings.TrimSuffix(name, "."+registered)
+	}
+
+	if len(score.Subdomain) >= d.config.EntropyMinLength {
+		score.Entropy = labelEntropy(score.Subdomain)
+		score.HighEntropy = score.Entropy >= d.config.EntropyThreshold
+	}
+	score.LongQName = score.Length >= d.config.QNameLengthThreshold
+	return score
+}
+
+// ConsumeQueryLog 实现QueryLogSink，评分并累计窗口信号，超过阈值时告警
+// 按真实客户端地址计数和隔离，告警和异常对象中显示匿名化后的地址
+func (d *AnomalyDetector) ConsumeQueryLog(buf *QueryLogBuffer, clientIP string) {
+	if atomic.LoadInt32(&d.stopped) != 0 || buf.QueryName == "" {
+		return
+	}
+
+	score := d.ScoreQuery(buf.QueryName)
+	if d.allow[score.RegisteredDomain] {
+		return
+	}
+	atomic.AddInt64(&d.scoredCount, 1)
+
+	ts := buf.StartTime
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	alerts := d.observe(ts, buf.ClientIP, clientIP, buf.QueryType, buf.ResponseCode, score)
+	for i := range alerts {
+		d.raise(&alerts[i])
+	}
+}
+
+// observe 累计一次查询的窗口信号，返回本次新触发的告警
+// clientIP为真实客户端地址，用于计数；displayIP为告警中显示的地址
+func (d *AnomalyDetector) observe(ts time.Time, clientIP, displayIP, qtype string, rcode int, score QueryScore) []AnomalyAlert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if ts.Sub(d.windowStart) >= d.config.window() {
+		d.resetWindowLocked(ts)
+	}
+
+	var alerts []AnomalyAlert
+	fire := func(anomalyType, client, domain string, value, threshold float64) {
+		key := anomalyType + "|" + client + "|" + domain
+		if d.fired[key] {
+			return
+		}
+		d.fired[key] = true
+		alert := AnomalyAlert{
+			Time:      ts,
+			Type:      anomalyType,
+			Domain:    domain,
+			Value:     value,
+			Threshold: threshold,
+		}
+		if client != "" {
+			alert.Client = displayIP
+			alert.clientIP = client
+		}
+		alerts = append(alerts, alert)
+	}
+
+	if c := d.clients[clientIP]; c != nil || len(d.clients) < anomalyMaxTrackedKeys {
+		if c == nil {
+			c = &anomalyClientWindow{}
+			d.clients[clientIP] = c
+		}
+		c.total++
+		if rcode == dns.RcodeNameError {
+			c.nxdomain++
+		}
+		suspicious := false
+		if qtype == "TXT" || qtype == "NULL" {
+			c.txtNull++
+			suspicious = true
+		}
+		if score.HighEntropy {
+			c.highEntropy++
+			suspicious = true
+		}
+		if score.LongQName {
+			c.longQName++
+			suspicious = true
+		}
+		if suspicious {
+			c.lastDomain = score.RegisteredDomain
+		}
+
+		cfg := d.config
+		if c.highEntropy >= cfg.SuspiciousQueryThreshold {
+			fire(AnomalyHighEntropy, clientIP, c.lastDomain, float64(c.highEntropy), float64(cfg.SuspiciousQueryThreshold))
+		}
+		if c.longQName >= cfg.SuspiciousQueryThreshold {
+			fire(AnomalyLongQName, clientIP, c.lastDomain, float64(c.longQName), float64(cfg.SuspiciousQueryThreshold))
+		}
+		if c.txtNull >= cfg.TXTNullThreshold {
+			fire(AnomalyTXTNullVolume, clientIP, c.lastDomain, float64(c.txtNull), float64(cfg.TXTNullThreshold))
+		}
+		if c.total >= cfg.NXDomainMinQueries {
+			if ratio := float64(c.nxdomain) / float64(c.total); ratio >= cfg.NXDomainRatio {
+				fire(AnomalyNXDomainRatio, clientIP, "", math.Round(ratio*100)/100, cfg.NXDomainRatio)
+			}
+		}
+	}
+
+	// 反向解析查询天然具有大量不同子域名，不计入子域名速率
+	if score.Subdomain != "" && !strings.HasSuffix(score.RegisteredDomain, "arpa") {
+		w := d.domains[score.RegisteredDomain]
+		if w == nil && len(d.domains) < anomalyMaxTrackedKeys {
+			w = &anomalyDomainWindow{subdomains: make(map[uint64]struct{})}
+			d.domains[score.RegisteredDomain] = w
+		}
+		if w != nil && len(w.subdomains) < d.config.UniqueSubdomainThreshold {
+			w.subdomains[maphash.String(sketchSeed, score.Subdomain)] = struct{}{}
+			w.lastClient = clientIP
+			if n := len(w.subdomains); n >= d.config.UniqueSubdomainThreshold {
+				fire(AnomalySubdomainRate, "", score.RegisteredDomain, float64(n), float64(d.config.UniqueSubdomainThreshold))
+			}
+		}
+	}
+
+	return alerts
+}
+
+// resetWindowLocked 开始新的计数窗口，并清理过期的异常对象，调用方需持有锁
+func (d *AnomalyDetector) resetWindowLocked(ts time.Time) {
+	d.windowStart = ts.Truncate(d.config.window())
+	d.clients = make(map[string]*anomalyClientWindow)
+	d.domains = make(map[string]*anomalyDomainWindow)
+	d.fired = make(map[string]bool)
+
+	cutoff := ts.Add(-anomalyOffenderTTL)
+	for _, offenders := range []map[string]*AnomalyOffender{d.clientOffenders, d.domainOffenders} {
+		for key, o := range offenders {
+			if o.LastSeen.Before(cutoff) {
+				delete(offenders, key)
+			}
+		}
+	}
+}
+
+// raise 处理告警：按配置隔离客户端、记录异常对象并发布事件
+func (d *AnomalyDetector) raise(alert *AnomalyAlert) {
+	// 子域名速率告警针对域名，不隔离最后一个查询的客户端
+	if d.config.Quarantine && alert.clientIP != "" && GlobalSecurityManager != nil {
+		GlobalSecurityManager.Quarantine(alert.clientIP, d.config.quarantineDuration())
+		alert.Quarantined = true
+	}
+
+	d.mu.Lock()
+	d.alerts = append(d.alerts, *alert)
+	if len(d.alerts) > anomalyMaxAlerts {
+		d.alerts = d.alerts[len(d.alerts)-anomalyMaxAlerts:]
+	}
+	if alert.Client != "" {
+		recordAnomalyOffender(d.clientOffenders, alert.Client, alert)
+	}
+	if alert.Domain != "" {
+		recordAnomalyOffender(d.domainOffenders, alert.Domain, alert)
+	}
+	d.mu.Unlock()
+	atomic.AddInt64(&d.alertCount, 1)
+
+	subject := alert.Client
+	if subject == "" {
+		subject = alert.Domain
+	}
+	message := fmt.Sprintf("检测到DNS异常（%s）：客户端 %s，域名 %s，当前值 %v，阈值 %v",
+		anomalyDescriptions[alert.Type], valueOrDash(alert.Client), valueOrDash(alert.Domain), alert.Value, alert.Threshold)
+	if alert.Quarantined {
+		message += fmt.Sprintf("，已隔离 %d 分钟", d.config.QuarantineMinutes)
+	}
+	d.logger.Warn("%s", message)
+
+	GetEventManager().EmitWithCooldown(&Event{
+		Type:     EventDNSAnomaly,
+		Severity: SeverityWarning,
+		Source:   "anomaly",
+		Subject:  subject,
+		Message:  message,
+		Details: map[string]interface{}{
+			"anomaly":     alert.Type,
+			"client":      alert.Client,
+			"domain":      alert.Domain,
+			"value":       alert.Value,
+			"threshold":   alert.Threshold,
+			"quarantined": alert.Quarantined,
+		},
+	})
+}
+
+// valueOrDash 空字符串显示为"-"
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// recordAnomalyOffender 更新异常对象，数量达到上限时淘汰最久未告警的对象
+func recordAnomalyOffender(offenders map[string]*AnomalyOffender, key string, alert *AnomalyAlert) {
+	o, ok := offenders[key]
+	if !ok {
+		if len(offenders) >= anomalyMaxOffenders {
+			var oldest string
+			for k, v := range offenders {
+				if oldest == "" || v.LastSeen.Before(offenders[oldest].LastSeen) {
+					oldest = k
+				}
+			}
+			delete(offenders, oldest)
+		}
+		o = &AnomalyOffender{Key: key, FirstSeen: alert.Time}
+		offenders[key] = o
+	}
+	o.Alerts++
+	o.LastSeen = alert.Time
+	for _, t := range o.Types {
+		if t == alert.Type {
+			return
+		}
+	}
+	o.Types = append(o.Types, alert.Type)
+}
+
+// sortedAnomalyOffenders 按最后告警时间倒序返回异常对象副本
+func sortedAnomalyOffenders(offenders map[string]*AnomalyOffender, limit int) []AnomalyOffender {
+	result := make([]AnomalyOffender, 0, len(offenders))
+	for _, o := range offenders {
+		c := *o
+		c.Types = append([]string(nil), o.Types...)
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// GetReport 获取异常检测报告
+// limit 限制最近告警和异常对象的返回数量，最近告警按时间倒序
+func (d *AnomalyDetector) GetReport(limit int) AnomalyReport {
+	d.mu.Lock()
+	n := len(d.alerts)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	recent := make([]AnomalyAlert, 0, n)
+	for i := len(d.alerts) - 1; i >= len(d.alerts)-n; i-- {
+		recent = append(recent, d.alerts[i])
+	}
+	clients := sortedAnomalyOffenders(d.clientOffenders, limit)
+	domains := sortedAnomalyOffenders(d.domainOffenders, limit)
+	d.mu.Unlock()
+
+	quarantined := []QuarantinedClient{}
+	if GlobalSecurityManager != nil {
+		quarantined = GlobalSecurityManager.GetQuarantinedClients()
+	}
+
+	return AnomalyReport{
+		Config:       d.config,
+		Scored:       atomic.LoadInt64(&d.scoredCount),
+		TotalAlerts:  atomic.LoadInt64(&d.alertCount),
+		RecentAlerts: recent,
+		Clients:      clients,
+		Domains:      domains,
+		Quarantined:  quarantined,
+	}
+}
@@ -0,0 +1,248 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/anomaly_detector_test.go
+// DNS隧道和DGA异常检测测试
+
+package sdns
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"SteadyDNS/core/common"
+)
+
+// testAnomalyConfig 测试用的异常检测配置
+func testAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		WindowSeconds:            60,
+		EntropyThreshold:         4.0,
+		EntropyMinLength:         20,
+		QNameLengthThreshold:     100,
+		SuspiciousQueryThreshold: 5,
+		TXTNullThreshold:         10,
+		UniqueSubdomainThreshold: 20,
+		NXDomainRatio:            0.5,
+		NXDomainMinQueries:       10,
+		QuarantineMinutes:        30,
+	}
+}
+
+// consumeAnomalyQuery 向检测器提交一条查询
+func consumeAnomalyQuery(d *AnomalyDetector, ts time.Time, client, qname, qtype string, rcode int) {
+	d.ConsumeQueryLog(&QueryLogBuffer{
+		StartTime:    ts,
+		ClientIP:     client,
+		QueryName:    qname,
+		QueryType:    qtype,
+		ResponseCode: rcode,
+	}, client)
+}
+
+// tunnelLabel 生成类似base32编码的隧道数据标签
+func tunnelLabel(i int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+	b := make([]byte, 48)
+	x := uint32(i)*2654435761 + 12345
+	for j := range b {
+		x = x*1664525 + 1013904223
+		b[j] = alphabet[x>>27]
+	}
+	return string(b)
+}
+
+// TestScoreQuery 测试查询名的注册域名拆分、熵和长度评分
+func TestScoreQuery(t *testing.T) {
+	d := NewAnomalyDetector(testAnomalyConfig(), common.NewLogger())
+
+	score := d.ScoreQuery("WWW.Example.co.uk.")
+	if score.RegisteredDomain != "example.co.uk" || score.Subdomain != "www" || score.HighEntropy || score.LongQName {
+		t.Errorf("ScoreQuery(www.example.co.uk) = %+v", score)
+	}
+
+	score = d.ScoreQuery(tunnelLabel(1) + ".t.evil.com.")
+	if score.RegisteredDomain != "evil.com" || !score.HighEntropy {
+		t.Errorf("隧道查询名应判定为高熵: %+v", score)
+	}
+
+	// 较长但重复字符多的标签熵较低
+	score = d.ScoreQuery("aaaaaaaaaabbbbbbbbbbcccccccccc.example.com.")
+	if score.HighEntropy {
+		t.Errorf("低熵标签误判为高熵: %+v", score)
+	}
+
+	if e := labelEntropy("abcd"); e != 2 {
+		t.Errorf("labelEntropy(abcd) = %v, want 2", e)
+	}
+}
+
+// TestAnomalyDetectorSignals 测试各类信号的告警和窗口内去重
+func TestAnomalyDetectorSignals(t *testing.T) {
+	d := NewAnomalyDetector(testAnomalyConfig(), common.NewLogger())
+	now := time.Now().Truncate(time.Minute)
+
+	// 高熵、TXT查询：同时触发高熵、TXT/NULL和子域名速率告警
+	for i := 0; i < 25; i++ {
+		consumeAnomalyQuery(d, now, "10.0.0.1", tunnelLabel(i)+".t.evil.com.", "TXT", dns.RcodeSuccess)
+	}
+	// DGA：大量NXDOMAIN
+	for i := 0; i < 12; i++ {
+		consumeAnomalyQuery(d, now, "10.0.0.2", fmt.Sprintf("q%dx.example%d.net.", i, i), "A", dns.RcodeNameError)
+	}
+	// 正常客户端
+	for i := 0; i < 30; i++ {
+		consumeAnomalyQuery(d, now, "10.0.0.3", "www.example.com.", "A", dns.RcodeSuccess)
+	}
+
+	report := d.GetReport(0)
+	got := make(map[string]AnomalyAlert)
+	for _, a := range report.RecentAlerts {
+		if _, dup := got[a.Type]; dup {
+			t.Errorf("同一窗口内重复告警: %+v", a)
+		}
+		got[a.Type] = a
+	}
+
+	for _, tt := range []struct {
+		anomaly, client, domain string
+	}{
+		{AnomalyHighEntropy, "10.0.0.1", "evil.com"},
+		{AnomalyTXTNullVolume, "10.0.0.1", "evil.com"},
+		{AnomalySubdomainRate, "", "evil.com"},
+		{AnomalyNXDomainRatio, "10.0.0.2", ""},
+	} {
+		a, ok := got[tt.anomaly]
+		if !ok {
+			t.Errorf("缺少%s告警", tt.anomaly)
+			continue
+		}
+		if a.Client != tt.client || a.Domain != tt.domain {
+			t.Errorf("%s告警 = %+v", tt.anomaly, a)
+		}
+	}
+	if _, ok := got[AnomalyLongQName]; ok {
+		t.Errorf("不应触发超长查询名告警")
+	}
+
+	if len(report.Clients) != 2 || len(report.Domains) != 1 || report.Domains[0].Key != "evil.com" {
+		t.Errorf("异常对象 clients=%+v domains=%+v", report.Clients, report.Domains)
+	}
+	for _, c := range report.Clients {
+		if c.Key == "10.0.0.3" {
+			t.Errorf("正常客户端不应列为异常")
+		}
+	}
+
+	// 新窗口重新计数，可再次告警
+	for i := 0; i < 12; i++ {
+		consumeAnomalyQuery(d, now.Add(time.Minute), "10.0.0.2", fmt.Sprintf("r%d.example%d.org.", i, i), "A", dns.RcodeNameError)
+	}
+	if report = d.GetReport(0); report.TotalAlerts != int64(len(got))+1 {
+		t.Errorf("新窗口告警数 = %d, want %d", report.TotalAlerts, len(got)+1)
+	}
+}
+
+// TestAnomalyDetectorAllowlistAndQuarantine 测试白名单和自动隔离
+func TestAnomalyDetectorAllowlistAndQuarantine(t *testing.T) {
+	cfg := testAnomalyConfig()
+	cfg.Allowlist = []string{"cdn.example"}
+	cfg.Quarantine = true
+	d := NewAnomalyDetector(cfg, common.NewLogger())
+
+	saved := GlobalSecurityManager
+	GlobalSecurityManager = &SecurityManager{rateLimiter: NewDNSRateLimiter(common.NewLogger())}
+	defer func() { GlobalSecurityManager = saved }()
+
+	now := time.Now()
+	for i := 0; i < 25; i++ {
+		consumeAnomalyQuery(d, now, "10.0.0.9", tunnelLabel(i)+".cdn.example.", "TXT", dns.RcodeSuccess)
+	}
+	if report := d.GetReport(0); report.TotalAlerts != 0 || report.Scored != 0 {
+		t.Fatalf("白名单域名不应评分: %+v", report)
+	}
+
+	for i := 0; i < 5; i++ {
+		consumeAnomalyQuery(d, now, "10.0.0.9", tunnelLabel(i)+".evil.com.", "A", dns.RcodeSuccess)
+	}
+	if allowed, _ := GlobalSecurityManager.CheckRateLimit("10.0.0.9"); allowed {
+		t.Errorf("被隔离的客户端应被拒绝")
+	}
+	report := d.GetReport(0)
+	if len(report.Quarantined) != 1 || report.Quarantined[0].ClientIP != "10.0.0.9" || !report.RecentAlerts[0].Quarantined {
+		t.Errorf("隔离状态 = %+v", report)
+	}
+
+	if !GlobalSecurityManager.ReleaseQuarantine("10.0.0.9") {
+		t.Errorf("ReleaseQuarantine() = false")
+	}
+	if allowed, _ := GlobalSecurityManager.CheckRateLimit("10.0.0.9"); !allowed {
+		t.Errorf("解除隔离后应允许查询")
+	}
+}
+
+// TestAnomalyDetectorAnonymizedClient 测试查询日志匿名化时按真实地址计数和隔离，告警中显示匿名化地址
+func TestAnomalyDetectorAnonymizedClient(t *testing.T) {
+	for _, mode := range []string{"truncate", "hash"} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := testAnomalyConfig()
+			cfg.Quarantine = true
+			d := NewAnomalyDetector(cfg, common.NewLogger())
+
+			saved := GlobalSecurityManager
+			limiter := NewDNSRateLimiter(common.NewLogger())
+			GlobalSecurityManager = &SecurityManager{rateLimiter: limiter}
+			defer func() { GlobalSecurityManager = saved }()
+
+			anonymizer := NewIPAnonymizer(mode, 24, 48, "secret")
+			l := &DNSLogger{
+				options:   &queryLogOptions{format: QueryLogFormatText, anonymizer: anonymizer},
+				batchChan: make(chan *LogEntry, 100),
+				sinks:     []QueryLogSink{d},
+			}
+			l.bufferPool.New = func() interface{} { return &QueryLogBuffer{} }
+			query := func(client string, i int) {
+				buf := l.StartQuery("1", client, tunnelLabel(i)+".evil.com.", "A")
+				l.EndQuery(buf, dns.RcodeSuccess, nil)
+			}
+
+			// 同一/24内的两个客户端分别计数，均未达到阈值
+			for i := 0; i < cfg.SuspiciousQueryThreshold-1; i++ {
+				query("192.0.2.77", i)
+				query("192.0.2.78", i)
+			}
+			if report := d.GetReport(0); report.TotalAlerts != 0 {
+				t.Fatalf("不同客户端不应合并计数: %+v", report.RecentAlerts)
+			}
+
+			query("192.0.2.77", cfg.SuspiciousQueryThreshold)
+			if !limiter.IsQuarantined("192.0.2.77") {
+				t.Errorf("达到阈值的真实客户端地址应被隔离")
+			}
+			if limiter.IsQuarantined("192.0.2.78") || limiter.IsQuarantined(anonymizer.Anonymize("192.0.2.77")) {
+				t.Errorf("只应隔离触发告警的真实客户端地址")
+			}
+			report := d.GetReport(0)
+			if len(report.RecentAlerts) != 1 || report.RecentAlerts[0].Client != anonymizer.Anonymize("192.0.2.77") || !report.RecentAlerts[0].Quarantined {
+				t.Errorf("告警应显示匿名化地址: %+v", report.RecentAlerts)
+			}
+		})
+	}
+}
@@ -467,12 +467,6 @@ func (s *CustomDNSServer) handleTCPConnection(conn net.Conn) {
 		tcpConn.SetNoDelay(true) // 禁用Nagle算法，提高实时性
 	}
 
-	// 创建TCP响应 writer
-	writer := &TCPResponseWriter{
-		conn:     conn,
-		clientIP: clientIP,
-	}
-
 	// 连接状态跟踪
 	startTime := time.Now()
 	messageCount := 0
@@ -542,6 +536,13 @@ func (s *CustomDNSServer) handleTCPConnection(conn net.Conn) {
 		// 增加消息计数
 		messageCount++
 
+		// 创建TCP响应 writer，每条消息单独创建以保存各自的TSIG校验结果
+		writer := &TCPResponseWriter{
+			conn:     conn,
+			clientIP: clientIP,
+			tsig:     verifyRequestTSIG(buf, &msg),
+		}
+
 		// 提交到协程池处理
 		s.pool.SubmitWithClientIP(s.handler, writer, &msg, clientIP)
 		// 更新网络流量统计（延迟数据已在 DNSHandler.ServeDNS 中记录）
@@ -607,6 +608,16 @@ func (s *CustomDNSServer) GetStatsManager() *StatsManager {
 	return s.statsManager
 }
 
+// GetWorkerPool 获取协程池
+func (s *CustomDNSServer) GetWorkerPool() *WorkerPool {
+	return s.pool
+}
+
+// GetPendingPackets 获取已接收但尚未处理完成的数据包数
+func (s *CustomDNSServer) GetPendingPackets() int {
+	return s.getPacketCount()
+}
+
 // SetStatsManager 设置统计管理器（用于TCP和UDP服务器共享同一个StatsManager）
 func (s *CustomDNSServer) SetStatsManager(sm *StatsManager) {
 	s.statsManager = sm
@@ -711,6 +722,7 @@ func (s *CustomDNSServer) processUDPPacket(pc net.PacketConn, udpConn *net.UDPCo
 		udpConn:  udpConn,
 		addr:     task.addr,
 		clientIP: clientIP,
+		tsig:     verifyRequestTSIG(task.buf[:task.n], &msg),
 	}
 
 	// 提交到协程池处理
@@ -783,11 +795,12 @@ type UDPResponseWriter struct {
 	udpConn  *net.UDPConn
 	addr     net.Addr
 	clientIP string
+	tsig     *tsigContext // 动态更新请求的TSIG校验结果
 }
 
 // WriteMsg 写入DNS响应
 func (w *UDPResponseWriter) WriteMsg(m *dns.Msg) error {
-	buf, err := m.Pack()
+	buf, err := w.tsig.packResponse(m)
 	if err != nil {
 		return err
 	}
@@ -834,7 +847,7 @@ func (w *UDPResponseWriter) Close() error {
 
 // TsigStatus 返回TSIG状态
 func (w *UDPResponseWriter) TsigStatus() error {
-	return nil
+	return w.tsig.tsigStatus()
 }
 
 // TsigTimersOnly 返回TSIG计时器状态
@@ -849,11 +862,12 @@ func (w *UDPResponseWriter) Hijack() {
 type TCPResponseWriter struct {
 	conn     net.Conn
 	clientIP string
+	tsig     *tsigContext // 动态更新请求的TSIG校验结果
 }
 
 // WriteMsg 写入DNS响应
 func (w *TCPResponseWriter) WriteMsg(m *dns.Msg) error {
-	buf, err := m.Pack()
+	buf, err := w.tsig.packResponse(m)
 	if err != nil {
 		return err
 	}
@@ -890,7 +904,7 @@ func (w *TCPResponseWriter) Close() error {
 
 // TsigStatus 返回TSIG状态
 func (w *TCPResponseWriter) TsigStatus() error {
-	return nil
+	return w.tsig.tsigStatus()
 }
 
 // TsigTimersOnly 返回TSIG计时器状态
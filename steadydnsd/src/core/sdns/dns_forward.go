@@ -31,17 +31,31 @@ import (
 // DNSForwardTask DNS转发任务
 type DNSForwardTask struct {
 	address    string
+	source     string // 出站源地址，为空时由系统选择
 	query      *dns.Msg
-	resultChan chan *dns.Msg
+	resultChan chan *forwardResponse
 	errorChan  chan error
 	forwarder  *DNSForwarder
 	cancelChan chan struct{} // 取消信号通道
 }
 
+// forwardResponse 转发任务结果，记录应答的上游服务器
+type forwardResponse struct {
+	msg    *dns.Msg
+	server string
+}
+
+// ForwardInfo 转发路径信息，用于查询日志
+type ForwardInfo struct {
+	Group     string // 匹配的转发组
+	Upstream  string // 应答的上游服务器地址
+	Authority bool   // 是否转发至权威域BIND服务器
+}
+
 // Process 处理DNS转发任务
 func (t *DNSForwardTask) Process() {
 	// 执行DNS查询
-	result, err := t.forwarder.forwardToServer(t.address, t.query, t.cancelChan)
+	result, err := t.forwarder.forwardToServer(t.address, t.source, t.query, t.cancelChan)
 
 	// 检查是否收到取消信号
 	select {
@@ -51,7 +65,7 @@ func (t *DNSForwardTask) Process() {
 			t.forwarder.logger.Debug("转发查询 - 任务被取消，但查询已成功完成，服务器: %s", t.address)
 			// 尝试发送结果，即使取消通道已关闭
 			select {
-			case t.resultChan <- result:
+			case t.resultChan <- &forwardResponse{msg: result, server: t.address}:
 				// 结果发送成功
 			default:
 				// 结果发送失败，可能是因为结果通道已满或已关闭
@@ -65,7 +79,7 @@ func (t *DNSForwardTask) Process() {
 		// 没有取消信号，处理查询结果
 		if err == nil && result != nil {
 			t.forwarder.logger.Debug("转发查询 - 服务器 %s 成功", t.address)
-			t.resultChan <- result
+			t.resultChan <- &forwardResponse{msg: result, server: t.address}
 		} else {
 			t.forwarder.logger.Debug("转发查询 - 服务器 %s 失败: %v", t.address, err)
 			t.errorChan <- err
@@ -76,7 +90,14 @@ func (t *DNSForwardTask) Process() {
 // ForwardQuery 转发DNS查询
 // 如果BIND插件禁用，跳过权威域转发逻辑
 func (f *DNSForwarder) ForwardQuery(query *dns.Msg) (*dns.Msg, error) {
+	result, _, err := f.ForwardQueryWithInfo(query)
+	return result, err
+}
+
+// ForwardQueryWithInfo 转发DNS查询，并返回匹配的转发组和应答的上游服务器
+func (f *DNSForwarder) ForwardQueryWithInfo(query *dns.Msg) (*dns.Msg, *ForwardInfo, error) {
 	startTime := time.Now()
+	info := &ForwardInfo{}
 
 	var queryDomain, queryType string
 	if len(query.Question) > 0 {
@@ -91,14 +112,17 @@ func (f *DNSForwarder) ForwardQuery(query *dns.Msg) (*dns.Msg, error) {
 		if isAuthority {
 			// 匹配权威域，转发至BIND服务器
 			bindAddr := f.authorityForwarder.GetBindAddress()
+			info.Group = authorityZone
+			info.Upstream = bindAddr
+			info.Authority = true
 			f.logger.Debug("转发查询 - 匹配权威域: %s, 转发至BIND服务器: %s", authorityZone, bindAddr)
-			result, err := f.forwardToServer(bindAddr, query, nil)
+			result, err := f.forwardToServer(bindAddr, "", query, nil)
 			if err == nil && result != nil {
-				return result, nil
+				return result, info, nil
 			}
 			// 权威域查询失败，直接返回错误，不再尝试其他服务器
 			f.logger.Error("权威域查询失败: %v", err)
-			return nil, fmt.Errorf("权威域查询失败: %v", err)
+			return nil, info, fmt.Errorf("权威域查询失败: %v", err)
 		}
 	}
 
@@ -106,33 +130,35 @@ func (f *DNSForwarder) ForwardQuery(query *dns.Msg) (*dns.Msg, error) {
 	matchedGroup := f.matchDomain(queryDomain)
 	if matchedGroup == nil {
 		f.logger.Error("转发查询 - 没有可用的转发组")
-		return nil, fmt.Errorf("没有可用的转发组")
+		return nil, info, fmt.Errorf("没有可用的转发组")
 	}
 
 	// 无锁执行转发操作
 	f.logger.Debug("转发查询 - 开始使用组: %s, 域名: %s, 类型: %s", matchedGroup.Name, queryDomain, queryType)
-	result, err := f.tryForwardWithPriority(matchedGroup, query)
+	info.Group = matchedGroup.Name
+	result, upstream, err := f.tryForwardWithPriority(matchedGroup, query)
 	f.logger.Debug("转发查询 - 完成, 耗时: %v, 错误: %v", time.Since(startTime), err)
 
 	if err == nil && result != nil {
-		return result, nil
+		info.Upstream = upstream
+		return result, info, nil
 	}
 
-	return nil, fmt.Errorf("所有转发服务器都不可用")
+	return nil, info, fmt.Errorf("所有转发服务器都不可用")
 }
 
-// tryForwardWithPriority 尝试按优先级转发查询
-func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Msg) (*dns.Msg, error) {
+// tryForwardWithPriority 尝试按优先级转发查询，返回响应及应答的上游服务器地址
+func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Msg) (*dns.Msg, string, error) {
 	// 整体查询超时时间（5秒）
 	overallTimeout := 5 * time.Second
 	// 优先级队列启动间隔（从配置读取）
 	priorityInterval := f.priorityTimeout
 
 	// 创建通道来接收查询结果（容量设置为服务器总数的估计值）
-	resultChan := make(chan *dns.Msg, 10)
+	resultChan := make(chan *forwardResponse, 10)
 	errorChan := make(chan error, 10)
 	// 创建备用结果队列，用于存放被启动队列处理逻辑消费的非NOERROR结果
-	spareResultChan := make(chan *dns.Msg, 10)
+	spareResultChan := make(chan *forwardResponse, 10)
 	// 创建整体取消通道
 	cancelChan := make(chan struct{})
 
@@ -157,6 +183,12 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 			return healthyStatsList[i].EWMAScore > healthyStatsList[j].EWMAScore
 		})
 
+		// 服务器在本转发组中配置的出站源地址
+		sources := make(map[string]string)
+		for _, server := range group.PriorityQueues[priority] {
+			sources[server.GetAddress()] = server.SourceAddress
+		}
+
 		// 为每台服务器创建任务，根据评分分层延迟启动
 		// 同一优先级内的服务器按评分延迟启动
 		// 不同优先级之间由priorityInterval控制
@@ -175,6 +207,7 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 			// 创建转发任务
 			task := &DNSForwardTask{
 				address:    addr,
+				source:     sources[addr],
 				query:      query,
 				resultChan: resultChan,
 				errorChan:  errorChan,
@@ -201,11 +234,11 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 			select {
 			case result := <-resultChan:
 				// 检查是否是NOERROR响应
-				if result.Rcode == dns.RcodeSuccess {
+				if result.msg.Rcode == dns.RcodeSuccess {
 					// NOERROR响应，直接返回
 					f.logger.Debug("转发查询 - 已收到NOERROR结果，停止启动更多优先级队列")
 					close(cancelChan)
-					return result, nil
+					return result.msg, result.server, nil
 				} else {
 					// 非NOERROR响应，存入备用结果队列，然后继续等待
 					f.logger.Debug("转发查询 - 收到非NOERROR结果，存入备用队列并继续启动下一优先级队列")
@@ -223,13 +256,13 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 	if totalHealthyServers == 0 {
 		close(cancelChan)
 		close(spareResultChan)
-		return nil, fmt.Errorf("没有健康的转发服务器")
+		return nil, "", fmt.Errorf("没有健康的转发服务器")
 	}
 
 	// 等待整体超时或结果
 	processedCount := 0
 	var lastError error
-	var firstNonNoErrorResult *dns.Msg
+	var firstNonNoErrorResult *forwardResponse
 
 	// 设置超时定时器
 	timeoutTimer := time.NewTimer(overallTimeout)
@@ -239,10 +272,10 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 		select {
 		case result := <-resultChan:
 			// 处理正式结果队列中的结果
-			if result.Rcode == dns.RcodeSuccess {
+			if result.msg.Rcode == dns.RcodeSuccess {
 				f.logger.Debug("转发查询 - 成功收到NOERROR结果")
 				close(cancelChan)
-				return result, nil
+				return result.msg, result.server, nil
 			} else {
 				// 非NOERROR响应，记录但继续等待
 				f.logger.Debug("转发查询 - 收到非NOERROR结果，继续等待其他服务器")
@@ -271,14 +304,14 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 			case result := <-resultChan:
 				f.logger.Debug("转发查询 - 超时后收到结果")
 				close(cancelChan)
-				return result, nil
+				return result.msg, result.server, nil
 			case result := <-spareResultChan:
 				f.logger.Debug("转发查询 - 超时后收到备用队列结果")
 				close(cancelChan)
-				return result, nil
+				return result.msg, result.server, nil
 			default:
 				close(cancelChan)
-				return nil, fmt.Errorf("整体查询超时")
+				return nil, "", fmt.Errorf("整体查询超时")
 			}
 		}
 	}
@@ -291,20 +324,21 @@ func (f *DNSForwarder) tryForwardWithPriority(group *ForwardGroup, query *dns.Ms
 	// 如果有非NOERROR响应，返回第一个收到的
 	if firstNonNoErrorResult != nil {
 		f.logger.Debug("转发查询 - 所有服务器都返回非NOERROR响应，返回第一个收到的响应")
-		return firstNonNoErrorResult, nil
+		return firstNonNoErrorResult.msg, firstNonNoErrorResult.server, nil
 	}
 
 	// 所有服务器都返回错误
 	if lastError != nil {
-		return nil, fmt.Errorf("所有转发服务器都返回错误: %v", lastError)
+		return nil, "", fmt.Errorf("所有转发服务器都返回错误: %v", lastError)
 	}
 
-	return nil, fmt.Errorf("所有转发服务器都不可用")
+	return nil, "", fmt.Errorf("所有转发服务器都不可用")
 }
 
 // forwardToServer 向单个DNS服务器转发查询
 // 使用ExchangeWithCookie替代直接Exchange，支持Cookie、TCP管道化和动态协议升级
-func (f *DNSForwarder) forwardToServer(addr string, query *dns.Msg, cancelChan chan struct{}) (*dns.Msg, error) {
+// 统计信息按服务器地址维护，连接按服务器地址和源地址组成的连接标识维护
+func (f *DNSForwarder) forwardToServer(addr, source string, query *dns.Msg, cancelChan chan struct{}) (*dns.Msg, error) {
 	startTime := time.Now()
 
 	// 首先检查是否已被取消
@@ -320,8 +354,26 @@ func (f *DNSForwarder) forwardToServer(addr string, query *dns.Msg, cancelChan c
 	// 获取或创建服务器统计信息
 	stats := f.getOrCreateServerStats(addr)
 
+	// 出站查询使用随机查询ID，按配置对查询名进行0x20大小写随机化
+	upstreamQuery := query.Copy()
+	upstreamQuery.Id = dns.Id()
+	originalName := ""
+	if f.qnameCase0x20 {
+		originalName = RandomizeQNAMECase(upstreamQuery)
+	}
+
 	// 使用ExchangeWithCookie进行查询，支持Cookie、TCP管道化和动态协议升级
-	result, err := f.ExchangeWithCookie(addr, query)
+	queryTime := time.Now()
+	endpoint := UpstreamEndpoint(addr, source)
+	result, protocol, err := f.exchange(endpoint, upstreamQuery)
+	if f.dnstap.ForwarderEnabled() {
+		f.dnstap.TapForwarder(addr, protocol, upstreamQuery, result, queryTime, time.Now())
+	}
+
+	// 校验响应，防止缓存投毒
+	if err == nil {
+		err = f.verifyUpstreamResponse(addr, protocol, upstreamQuery, result, stats)
+	}
 
 	// 再次检查是否被取消（查询完成后）
 	if cancelChan != nil {
@@ -355,6 +407,7 @@ func (f *DNSForwarder) forwardToServer(addr string, query *dns.Msg, cancelChan c
 		// 检查是否触发熔断
 		if CheckCircuitBreaker(stats) {
 			f.logger.Warn("服务器 %s 触发熔断，连续失败次数达到阈值", addr)
+			f.notifyServerDown(stats)
 		}
 
 		return nil, err
@@ -375,27 +428,50 @@ func (f *DNSForwarder) forwardToServer(addr string, query *dns.Msg, cancelChan c
 	stats.LastSuccessfulQueryTime = now
 	stats.WindowQueries++
 	stats.Status = "healthy"
+	stats.recordLatencySampleLocked(float64(duration.Microseconds()) / 1000)
 	stats.Mu.Unlock()
 
+	// 记录上游延迟直方图
+	if statsManager := GetStatsManager(); statsManager != nil {
+		statsManager.RecordUpstreamLatency(addr, duration)
+	}
+
 	// 更新EWMA评分和滑动窗口
 	// rcode=0表示NOERROR，使用默认半衰期10秒
 	UpdateTimeDecayEWMA(stats, result.Rcode, latency, now, 0)
 	UpdateSlidingWindow(stats, true)
 	RecordQueryResult(stats, true)
 
+	// 恢复客户端查询ID和查询名大小写
+	result.Id = query.Id
+	if originalName != "" {
+		RestoreQNAMECase(result, upstreamQuery.Question[0].Name, originalName)
+	}
+
 	return result, nil
 }
 
 // ExchangeWithCookie 统一的DNS查询接口，支持Cookie、TCP管道化和动态协议升级
 //
 // 参数:
-//   - serverAddr: 服务器地址
+//   - serverAddr: 服务器地址，或由UpstreamEndpoint生成的带源地址的连接标识
 //   - query: DNS查询消息
 //
 // 返回:
 //   - *dns.Msg: DNS响应消息
 //   - error: 错误信息
 func (f *DNSForwarder) ExchangeWithCookie(serverAddr string, query *dns.Msg) (*dns.Msg, error) {
+	result, _, err := f.exchange(serverAddr, query)
+	return result, err
+}
+
+// exchange 执行ExchangeWithCookie的查询流程，并返回实际使用的传输协议
+//
+// 返回:
+//   - *dns.Msg: DNS响应消息
+//   - string: 实际发送查询的传输协议（"tcp"或"udp"，Cookie查询同样经由UDP）
+//   - error: 错误信息
+func (f *DNSForwarder) exchange(serverAddr string, query *dns.Msg) (*dns.Msg, string, error) {
 	// 复制查询消息，避免修改原始查询
 	msg := query.Copy()
 
@@ -416,22 +492,24 @@ func (f *DNSForwarder) ExchangeWithCookie(serverAddr string, query *dns.Msg) (*d
 		result, err := f.exchangeWithTCP(serverAddr, msg)
 		if err != nil {
 			f.logger.Debug("TCP查询失败，尝试降级: %v", err)
-			return f.handleProtocolDowngrade(serverAddr, msg, "tcp", err)
+			result, err = f.handleProtocolDowngrade(serverAddr, msg, "tcp", err)
+			return result, "udp", err
 		}
-		return result, nil
+		return result, "tcp", nil
 
 	case "cookie":
 		// 使用UDP+Cookie
 		result, err := f.exchangeWithCookie(serverAddr, msg)
 		if err != nil {
 			f.logger.Debug("Cookie查询失败，尝试降级到UDP Plain: %v", err)
-			return f.handleProtocolDowngrade(serverAddr, msg, "cookie", err)
+			result, err = f.handleProtocolDowngrade(serverAddr, msg, "cookie", err)
 		}
-		return result, nil
+		return result, "udp", err
 
 	default:
 		// 使用UDP Plain
-		return f.exchangeWithUDP(serverAddr, msg)
+		result, err := f.exchangeWithUDP(serverAddr, msg)
+		return result, "udp", err
 	}
 }
 
@@ -504,15 +582,16 @@ func (f *DNSForwarder) shouldUseTCP(serverAddr string) bool {
 //
 // 返回:
 //   - *dns.Msg: DNS响应消息
+//   - string: 实际使用的传输协议
 //   - error: 错误信息
-func (f *DNSForwarder) handleLargeQuery(serverAddr string, msg *dns.Msg) (*dns.Msg, error) {
+func (f *DNSForwarder) handleLargeQuery(serverAddr string, msg *dns.Msg) (*dns.Msg, string, error) {
 	f.logger.Debug("处理大数据包查询，服务器: %s", serverAddr)
 
 	// 第一层：尝试TCP
 	if f.shouldUseTCP(serverAddr) {
 		result, err := f.exchangeWithTCP(serverAddr, msg)
 		if err == nil {
-			return result, nil
+			return result, "tcp", nil
 		}
 		f.logger.Debug("TCP查询失败，尝试降级到UDP+Cookie: %v", err)
 	}
@@ -524,7 +603,7 @@ func (f *DNSForwarder) handleLargeQuery(serverAddr string, msg *dns.Msg) (*dns.M
 		if caps.HasCapability(CapabilityEDNS0) {
 			result, err := f.exchangeWithCookie(serverAddr, msg)
 			if err == nil {
-				return result, nil
+				return result, "udp", nil
 			}
 			f.logger.Debug("UDP+Cookie查询失败，尝试降级到UDP Plain: %v", err)
 		}
@@ -532,7 +611,8 @@ func (f *DNSForwarder) handleLargeQuery(serverAddr string, msg *dns.Msg) (*dns.M
 
 	// 第三层：UDP Plain（可能失败，因为数据包太大）
 	f.logger.Warn("大数据包查询降级到UDP Plain，可能因数据包过大而失败")
-	return f.exchangeWithUDP(serverAddr, msg)
+	result, err := f.exchangeWithUDP(serverAddr, msg)
+	return result, "udp", err
 }
 
 // tryReconnectTCP 尝试重建TCP连接
@@ -841,13 +921,19 @@ func (f *DNSForwarder) exchangeWithUDP(serverAddr string, msg *dns.Msg) (*dns.Ms
 	udpMsg := msg.Copy()
 	RemoveCookie(udpMsg)
 
-	// 创建UDP客户端
+	// 创建UDP客户端，已绑定源地址时使用指定的本地地址
+	dialer, err := f.SourceResolver.NewDialer("udp", serverAddr, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("解析源地址失败: %w", err)
+	}
 	c := new(dns.Client)
 	c.Net = "udp"
 	c.Timeout = 5 * time.Second
+	c.Dialer = dialer
 
 	// 执行查询
-	result, rtt, err := c.Exchange(udpMsg, serverAddr)
+	addr, _ := SplitUpstreamEndpoint(serverAddr)
+	result, rtt, err := c.Exchange(udpMsg, addr)
 	if err != nil {
 		return nil, fmt.Errorf("UDP查询失败: %w", err)
 	}
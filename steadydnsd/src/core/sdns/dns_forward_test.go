@@ -34,7 +34,7 @@ func TestDNSForwardTaskProcess(t *testing.T) {
 	query := new(dns.Msg)
 	query.SetQuestion("example.com.", dns.TypeA)
 
-	resultChan := make(chan *dns.Msg, 1)
+	resultChan := make(chan *forwardResponse, 1)
 	errorChan := make(chan error, 1)
 	cancelChan := make(chan struct{})
 
@@ -41,6 +41,9 @@ var GlobalUDPServer *CustomDNSServer
 // GlobalTCPServer 全局TCP DNS服务器实例，用于在webapi中获取统计信息
 var GlobalTCPServer *CustomDNSServer
 
+// GlobalSecurityManager 全局安全管理器实例，用于在webapi中获取速率限制统计
+var GlobalSecurityManager *SecurityManager
+
 // ReloadForwardGroups 重新加载转发组配置
 func ReloadForwardGroups() error {
 	if GlobalDNSForwarder != nil {
@@ -58,6 +61,8 @@ type DNSHandler struct {
 	clientIP        string           // 客户端IP地址
 	securityManager *SecurityManager // 安全管理器
 	statsManager    *StatsManager    // 统计管理器
+	dnstap          *DnstapWriter    // dnstap输出，未启用时为nil
+	updater         *DynamicUpdater  // 动态更新处理器，未启用时为nil
 }
 
 // NewDNSHandler 创建新的DNS处理器
@@ -98,12 +103,29 @@ func NewDNSHandler(forwardAddr string, logger *common.Logger) *DNSHandler {
 	// 创建安全管理器
 	securityManager := NewSecurityManager(logger)
 
+	// 创建dnstap输出（替换上次启动的输出），转发器共享同一输出
+	dnstap := ReplaceDnstapWriterFromConfig(logger)
+	forwarder.dnstap = dnstap
+
+	// 查询日志推送到实时查询流和查询指标；日志分析插件启用时同时写入索引存储并进行异常检测
+	dnsLogger := NewDNSLogger(logDir, maxLogSize, maxLogFiles)
+	dnsLogger.AddSink(GlobalQueryStream)
+	dnsLogger.AddSink(GlobalQueryMetrics)
+	if GlobalQueryLogStore != nil {
+		dnsLogger.AddSink(GlobalQueryLogStore)
+	}
+	if GlobalAnomalyDetector != nil {
+		dnsLogger.AddSink(GlobalAnomalyDetector)
+	}
+
 	return &DNSHandler{
 		forwarder:       forwarder,
 		cacheUpdater:    NewCacheUpdater(),
 		logger:          logger,
-		dnsLogger:       NewDNSLogger(logDir, maxLogSize, maxLogFiles),
+		dnsLogger:       dnsLogger,
 		securityManager: securityManager,
+		dnstap:          dnstap,
+		updater:         NewDynamicUpdaterFromConfig(logger),
 	}
 }
 
@@ -120,6 +142,8 @@ func generateQueryID() string {
 // ServeDNS 实现DNS服务器接口
 func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	startTime := time.Now()
+	// 记录dnstap CLIENT_QUERY，响应写出时记录CLIENT_RESPONSE
+	w = h.dnstap.WrapResponseWriter(w, r, startTime)
 	clientIP := h.clientIP
 	if clientIP == "" {
 		clientIP = getClientIP(w)
@@ -140,16 +164,25 @@ func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		h.serveDNSInternal(w, r, clientIP)
 		return
 	}
+	logBuf.ClientPort, logBuf.Protocol = remoteAddrInfo(w)
+	logBuf.Request = r
 
 	// 确保查询结束时输出日志并记录延迟统计
 	var responseCode int
 	var processErr error
 	defer func() {
 		totalTime := time.Since(startTime)
+		// EndQuery会回收logBuf，需提前取出协议和缓存状态
+		dims := QueryDimensions{
+			QType:    queryType,
+			RCode:    rcodeName(responseCode),
+			Protocol: logBuf.Protocol,
+			Cache:    CacheOutcome(logBuf.CacheStatus),
+		}
 		h.dnsLogger.EndQuery(logBuf, responseCode, processErr)
-		// 记录延迟统计到StatsManager
+		// 记录延迟和查询维度统计到StatsManager
 		if statsManager := GetStatsManager(); statsManager != nil {
-			statsManager.RecordQuery(queryDomain, clientIP, totalTime)
+			statsManager.RecordQuery(queryDomain, clientIP, dims, totalTime)
 		}
 	}()
 
@@ -158,8 +191,10 @@ func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	if !valid {
 		h.logger.Warn("DNS消息验证失败: %s, 客户端: %s", msg, clientIP)
 		h.dnsLogger.RecordStage(logBuf, "SECURITY", "validation_failed:"+msg)
+		logBuf.Security = "validation_failed"
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeFormatError)
+		logBuf.Response = m
 		w.WriteMsg(m)
 		responseCode = dns.RcodeFormatError
 		return
@@ -170,14 +205,26 @@ func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	if !allowed {
 		h.logger.Warn("DNS查询速率限制: %s, 客户端: %s", msg, clientIP)
 		h.dnsLogger.RecordStage(logBuf, "SECURITY", "rate_limited:"+msg)
+		logBuf.Security = "rate_limited"
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeRefused)
+		logBuf.Response = m
 		w.WriteMsg(m)
 		responseCode = dns.RcodeRefused
 		return
 	}
 
 	h.dnsLogger.RecordStage(logBuf, "SECURITY", "passed")
+	logBuf.Security = "passed"
+
+	// 动态更新不经过缓存和转发，在本地写入权威域
+	if r.Opcode == dns.OpcodeUpdate {
+		resp := h.serveUpdate(w, r, clientIP)
+		h.dnsLogger.RecordStage(logBuf, "UPDATE", dns.RcodeToString[resp.Rcode])
+		logBuf.Response = resp
+		responseCode = resp.Rcode
+		return
+	}
 
 	// 首先检查缓存
 	cacheStart := time.Now()
@@ -193,10 +240,13 @@ func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		if cachedResult.Rcode == dns.RcodeSuccess && len(cachedResult.Answer) > 0 {
 			// 成功响应
 			h.dnsLogger.RecordStage(logBuf, "CACHE", fmt.Sprintf("hit,records=%d,time=%.2fms", len(cachedResult.Answer), float64(cacheDuration)/float64(time.Millisecond)))
+			logBuf.CacheStatus = "hit"
 		} else {
 			// 错误响应或空响应
 			h.dnsLogger.RecordStage(logBuf, "CACHE", fmt.Sprintf("hit_error,rcode=%d,time=%.2fms", cachedResult.Rcode, float64(cacheDuration)/float64(time.Millisecond)))
+			logBuf.CacheStatus = "hit_error"
 		}
+		logBuf.Response = cachedResult
 		w.WriteMsg(cachedResult)
 		responseCode = cachedResult.Rcode
 		return
@@ -204,17 +254,23 @@ func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 
 	// 缓存未命中
 	h.dnsLogger.RecordStage(logBuf, "CACHE", fmt.Sprintf("miss,error=%v,time=%.2fms", err, float64(cacheDuration)/float64(time.Millisecond)))
+	logBuf.CacheStatus = "miss"
 
 	// 进行转发查询
 	forwardStart := time.Now()
-	forwardedResult, err := h.forwarder.ForwardQuery(r)
+	forwardedResult, forwardInfo, err := h.forwarder.ForwardQueryWithInfo(r)
 	forwardDuration := time.Since(forwardStart)
+	if forwardInfo != nil {
+		logBuf.ForwardGroup = forwardInfo.Group
+		logBuf.Upstream = forwardInfo.Upstream
+	}
 
 	if err != nil {
 		h.logger.Error("转发查询失败: %v", err)
 		h.dnsLogger.RecordStage(logBuf, "FORWARD", fmt.Sprintf("failed,error=%v,time=%.2fms", err, float64(forwardDuration)/float64(time.Millisecond)))
 		m := new(dns.Msg)
 		m.SetRcode(r, dns.RcodeServerFailure)
+		logBuf.Response = m
 		w.WriteMsg(m)
 		responseCode = dns.RcodeServerFailure
 		processErr = err
@@ -234,10 +290,22 @@ func (h *DNSHandler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	}
 
 	// 返回转发结果
+	logBuf.Response = forwardedResult
 	w.WriteMsg(forwardedResult)
 	responseCode = forwardedResult.Rcode
 }
 
+// remoteAddrInfo 获取客户端端口和传输协议
+func remoteAddrInfo(w dns.ResponseWriter) (int, string) {
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		return addr.Port, "udp"
+	case *net.TCPAddr:
+		return addr.Port, "tcp"
+	}
+	return 0, ""
+}
+
 // serveDNSInternal 内部DNS处理逻辑（日志系统关闭时使用）
 func (h *DNSHandler) serveDNSInternal(w dns.ResponseWriter, r *dns.Msg, clientIP string) {
 	// 安全检查：DNS消息验证
@@ -259,6 +327,12 @@ func (h *DNSHandler) serveDNSInternal(w dns.ResponseWriter, r *dns.Msg, clientIP
 		return
 	}
 
+	// 动态更新不经过缓存和转发，在本地写入权威域
+	if r.Opcode == dns.OpcodeUpdate {
+		h.serveUpdate(w, r, clientIP)
+		return
+	}
+
 	// 首先检查缓存
 	cachedResult, err := h.cacheUpdater.CheckCache(r)
 	if err == nil && cachedResult != nil && cachedResult.Rcode == dns.RcodeSuccess && len(cachedResult.Answer) > 0 {
@@ -383,6 +457,8 @@ func StartDNSServer(logger *common.Logger) error {
 	GlobalDNSForwarder = handler.forwarder
 	// 设置全局缓存更新器实例
 	GlobalCacheUpdater = handler.cacheUpdater
+	// 设置全局安全管理器实例
+	GlobalSecurityManager = handler.securityManager
 
 	// 创建协程池（使用固定大小）
 	pool := NewWorkerPool(clientWorkers, queueMultiplier, 5*time.Second)
@@ -31,6 +31,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/miekg/dns"
 )
 
 // QueryLogBuffer 查询日志缓冲区（每个查询独立使用，无锁）
@@ -43,6 +45,17 @@ type QueryLogBuffer struct {
 	Stages        []StageInfo
 	ResponseCode  int
 	Error         string
+
+	// 结构化日志字段
+	ClientPort    int      // 客户端端口
+	Protocol      string   // 传输协议（udp/tcp）
+	CacheStatus   string   // 缓存状态（hit/hit_error/miss）
+	ForwardGroup  string   // 匹配的转发组
+	Upstream      string   // 实际应答的上游服务器
+	Security      string   // 安全检查结果（passed/validation_failed/rate_limited）
+	Request       *dns.Msg // 客户端请求，仅在EndQuery前有效
+	Response      *dns.Msg // 返回给客户端的响应，仅在EndQuery前有效
+	lastStageAt   time.Time
 }
 
 // StageInfo 阶段信息
@@ -56,6 +69,7 @@ type StageInfo struct {
 type LogEntry struct {
 	Timestamp string
 	Content   string
+	Raw       bool // 为true时原样写入Content（JSON行格式）
 }
 
 // DNSLogger DNS查询日志管理器
@@ -82,6 +96,20 @@ type DNSLogger struct {
 	// 统计信息
 	writtenCount   int64
 	droppedCount   int64
+
+	// 日志格式、字段选择和IP匿名化配置
+	options *queryLogOptions
+
+	// 查询日志订阅者，在EndQuery中同步调用
+	sinks []QueryLogSink
+}
+
+// QueryLogSink 查询日志订阅者
+// ConsumeQueryLog在查询处理协程中同步调用，实现必须快速返回且不能持有buf
+// buf.ClientIP为真实客户端地址，用于计数和隔离等需要识别客户端的处理；
+// clientIP为按配置匿名化后的客户端地址，用于展示和持久化
+type QueryLogSink interface {
+	ConsumeQueryLog(buf *QueryLogBuffer, clientIP string)
 }
 
 // NewDNSLogger 创建DNS日志管理器
@@ -114,6 +142,7 @@ func NewDNSLogger(logDir string, maxFileSize int64, maxFiles int) *DNSLogger {
 		batchSize:     100,             // 每批100条
 		flushInterval: 100 * time.Millisecond, // 100ms刷新
 		writerCount:   4,               // 4个writer协程
+		options:       loadQueryLogOptions(),
 	}
 
 	// 初始化对象池
@@ -144,9 +173,16 @@ func NewDNSLogger(logDir string, maxFileSize int64, maxFiles int) *DNSLogger {
 func (l *DNSLogger) GetBuffer() *QueryLogBuffer {
 	buf := l.bufferPool.Get().(*QueryLogBuffer)
 	buf.StartTime = time.Now()
+	buf.lastStageAt = buf.StartTime
 	buf.Stages = buf.Stages[:0]
 	buf.ResponseCode = 0
 	buf.Error = ""
+	buf.ClientPort = 0
+	buf.Protocol = ""
+	buf.CacheStatus = ""
+	buf.ForwardGroup = ""
+	buf.Upstream = ""
+	buf.Security = ""
 	return buf
 }
 
@@ -154,6 +190,8 @@ func (l *DNSLogger) GetBuffer() *QueryLogBuffer {
 func (l *DNSLogger) PutBuffer(buf *QueryLogBuffer) {
 	if buf != nil {
 		buf.Stages = buf.Stages[:0]
+		buf.Request = nil
+		buf.Response = nil
 		l.bufferPool.Put(buf)
 	}
 }
@@ -178,14 +216,10 @@ func (l *DNSLogger) RecordStage(buf *QueryLogBuffer, name, detail string) {
 		return
 	}
 	
+	// 当前阶段耗时为距上一阶段（或查询开始）结束的时间
 	now := time.Now()
-	duration := now.Sub(buf.StartTime)
-	
-	// 如果已有阶段，计算当前阶段耗时
-	if len(buf.Stages) > 0 {
-		lastStage := buf.Stages[len(buf.Stages)-1]
-		duration = now.Sub(buf.StartTime) - lastStage.Duration
-	}
+	duration := now.Sub(buf.lastStageAt)
+	buf.lastStageAt = now
 	
 	buf.Stages = append(buf.Stages, StageInfo{
 		Name:     name,
@@ -205,14 +239,29 @@ func (l *DNSLogger) EndQuery(buf *QueryLogBuffer, responseCode int, err error) {
 	if err != nil {
 		buf.Error = err.Error()
 	}
+
+	if len(l.sinks) > 0 {
+		clientIP := buf.ClientIP
+		if l.options != nil {
+			clientIP = l.options.anonymizer.Anonymize(clientIP)
+		}
+		for _, sink := range l.sinks {
+			sink.ConsumeQueryLog(buf, clientIP)
+		}
+	}
 	
 	// 生成汇总日志
-	logContent := l.formatLogEntry(buf)
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	
-	entry := &LogEntry{
-		Timestamp: timestamp,
-		Content:   logContent,
+	var entry *LogEntry
+	if l.options != nil && l.options.format == QueryLogFormatJSON {
+		entry = &LogEntry{
+			Content: l.formatJSONLogEntry(buf),
+			Raw:     true,
+		}
+	} else {
+		entry = &LogEntry{
+			Timestamp: time.Now().Format("2006-01-02 15:04:05.000"),
+			Content:   l.formatLogEntry(buf),
+		}
 	}
 	
 	// 提交到批量channel
@@ -316,6 +365,11 @@ func (l *DNSLogger) flushBatch(batch []*LogEntry) {
 	
 	// 批量写入
 	for _, entry := range batch {
+		if entry.Raw {
+			l.writer.WriteString(entry.Content)
+			l.writer.WriteByte('\n')
+			continue
+		}
 		fmt.Fprintf(l.writer, "[%s] %s\n", entry.Timestamp, entry.Content)
 	}
 	
@@ -380,6 +434,12 @@ func (l *DNSLogger) Log(format string, args ...interface{}) {
 		Timestamp: timestamp,
 		Content:   content,
 	}
+	if l.options != nil && l.options.format == QueryLogFormatJSON {
+		entry = &LogEntry{
+			Content: formatJSONMessage(content),
+			Raw:     true,
+		}
+	}
 
 	select {
 	case l.batchChan <- entry:
@@ -388,6 +448,13 @@ func (l *DNSLogger) Log(format string, args ...interface{}) {
 	}
 }
 
+// AddSink 添加查询日志订阅者，需在开始处理查询前调用
+func (l *DNSLogger) AddSink(sink QueryLogSink) {
+	if sink != nil {
+		l.sinks = append(l.sinks, sink)
+	}
+}
+
 // Close 关闭日志管理器
 func (l *DNSLogger) Close() {
 	l.shutdown = true
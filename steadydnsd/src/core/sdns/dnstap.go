@@ -0,0 +1,696 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/dnstap.go
+// dnstap输出：以Frame Streams格式将查询和响应写入Unix套接字、TCP或文件
+
+package sdns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"SteadyDNS/core/common"
+)
+
+// dnstap消息类型（dnstap.proto Message.Type）
+const (
+	DnstapClientQuery       = 5
+	DnstapClientResponse    = 6
+	DnstapForwarderQuery    = 7
+	DnstapForwarderResponse = 8
+)
+
+// dnstap套接字族和传输协议
+const (
+	dnstapFamilyInet  = 1
+	dnstapFamilyInet6 = 2
+	dnstapProtoUDP    = 1
+	dnstapProtoTCP    = 2
+)
+
+// Frame Streams控制帧类型
+const (
+	fstrmControlAccept = 0x01
+	fstrmControlStart  = 0x02
+	fstrmControlStop   = 0x03
+	fstrmControlReady  = 0x04
+	fstrmControlFinish = 0x05
+
+	fstrmFieldContentType = 0x01
+	fstrmMaxControlLen    = 512
+)
+
+// dnstapContentType dnstap的Frame Streams内容类型
+const dnstapContentType = "protobuf:dnstap.Dnstap"
+
+// DnstapMessage 待编码的dnstap消息
+type DnstapMessage struct {
+	Type            int
+	SocketProtocol  int
+	QueryAddress    net.IP
+	QueryPort       int
+	ResponseAddress net.IP
+	ResponsePort    int
+	QueryTime       time.Time
+	ResponseTime    time.Time
+	QueryMessage    []byte
+	ResponseMessage []byte
+}
+
+// DnstapWriter dnstap输出器
+// 帧通过带缓冲的channel交给后台协程写出，缓冲区满或输出不可用时直接丢弃并计数，不阻塞查询处理
+type DnstapWriter struct {
+	network  string // unix、tcp或file
+	address  string
+	identity []byte
+	version  []byte
+
+	logClient    bool
+	logForwarder bool
+
+	frames chan []byte
+	done   chan struct{}
+
+	// fileSynced 文件输出最后一次成功刷新后的长度，重新打开时据此截掉写入失败留下的残缺帧
+	fileSynced int64
+
+	wg     sync.WaitGroup
+	once   sync.Once
+	logger *common.Logger
+
+	// 统计信息
+	writtenCount int64
+	droppedCount int64
+}
+
+// NewDnstapWriterFromConfig 根据[Logging]配置创建dnstap输出器，未启用或配置无效时返回nil
+func NewDnstapWriterFromConfig(logger *common.Logger) *DnstapWriter {
+	if !common.GetConfigBool("Logging", "DNSTAP_ENABLED", false) {
+		return nil
+	}
+
+	identity := common.GetConfig("Logging", "DNSTAP_IDENTITY")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+
+	w, err := NewDnstapWriter(
+		common.GetConfig("Logging", "DNSTAP_OUTPUT"),
+		identity,
+		strings.Split(common.GetConfig("Logging", "DNSTAP_MESSAGES"), ","),
+		common.GetConfigInt("Logging", "DNSTAP_BUFFER_SIZE", 10000),
+		logger,
+	)
+	if err != nil {
+		if logger != nil {
+			logger.Error("dnstap输出初始化失败: %v", err)
+		}
+		return nil
+	}
+	return w
+}
+
+var (
+	dnstapMu      sync.Mutex
+	currentDnstap *DnstapWriter // 当前DNS服务器使用的dnstap输出器
+)
+
+// ReplaceDnstapWriterFromConfig 关闭当前的dnstap输出器，再按配置创建新的输出器
+// DNS服务器重启时调用，旧输出器先写出剩余帧并释放连接或文件，避免协程泄漏以及新旧输出器同时写入同一文件
+func ReplaceDnstapWriterFromConfig(logger *common.Logger) *DnstapWriter {
+	dnstapMu.Lock()
+	defer dnstapMu.Unlock()
+
+	currentDnstap.Close()
+	currentDnstap = NewDnstapWriterFromConfig(logger)
+	return currentDnstap
+}
+
+// NewDnstapWriter 创建dnstap输出器
+//
+// 参数:
+//   - output: 输出目标，格式为unix:/path、tcp:host:port或file:/path
+//   - identity: 服务器标识
+//   - messages: 记录的消息类别，可选client、forwarder，为空时全部记录
+//   - bufferSize: 待写出帧的缓冲数量
+//   - logger: 日志管理器，可为nil
+func NewDnstapWriter(output, identity string, messages []string, bufferSize int, logger *common.Logger) (*DnstapWriter, error) {
+	network, address, ok := strings.Cut(strings.TrimSpace(output), ":")
+	if !ok || address == "" {
+		return nil, fmt.Errorf("无效的dnstap输出目标: %s，格式应为unix:/path、tcp:host:port或file:/path", output)
+	}
+	network = strings.ToLower(network)
+	switch network {
+	case "unix", "file":
+	case "tcp":
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, fmt.Errorf("无效的dnstap TCP地址 %s: %v", address, err)
+		}
+	default:
+		return nil, fmt.Errorf("不支持的dnstap输出类型: %s，可选值：unix, tcp, file", network)
+	}
+
+	if bufferSize <= 0 {
+		bufferSize = 10000
+	}
+
+	w := &DnstapWriter{
+		network:  network,
+		address:  address,
+		identity: []byte(identity),
+		version:  []byte("SteadyDNS"),
+		frames:   make(chan []byte, bufferSize),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+
+	for _, m := range messages {
+		switch strings.ToLower(strings.TrimSpace(m)) {
+		case "client":
+			w.logClient = true
+		case "forwarder":
+			w.logForwarder = true
+		case "":
+		default:
+			return nil, fmt.Errorf("不支持的dnstap消息类别: %s，可选值：client, forwarder", m)
+		}
+	}
+	if !w.logClient && !w.logForwarder {
+		w.logClient = true
+		w.logForwarder = true
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w, nil
+}
+
+// ClientEnabled 是否记录客户端查询和响应
+func (w *DnstapWriter) ClientEnabled() bool {
+	return w != nil && w.logClient
+}
+
+// ForwarderEnabled 是否记录转发查询和响应
+func (w *DnstapWriter) ForwarderEnabled() bool {
+	return w != nil && w.logForwarder
+}
+
+// Write 编码并提交dnstap消息，缓冲区满时丢弃
+func (w *DnstapWriter) Write(m *DnstapMessage) {
+	if w == nil {
+		return
+	}
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+
+	frame := encodeDnstap(w.identity, w.version, m)
+	select {
+	case w.frames <- frame:
+	default:
+		atomic.AddInt64(&w.droppedCount, 1)
+	}
+}
+
+// WrapResponseWriter 包装客户端ResponseWriter：记录CLIENT_QUERY，并在写出响应时记录CLIENT_RESPONSE
+func (w *DnstapWriter) WrapResponseWriter(rw dns.ResponseWriter, query *dns.Msg, queryTime time.Time) dns.ResponseWriter {
+	if !w.ClientEnabled() {
+		return rw
+	}
+
+	tw := &dnstapResponseWriter{ResponseWriter: rw, tap: w, queryTime: queryTime}
+	tw.clientIP, tw.clientPort, tw.protocol = dnstapAddr(rw.RemoteAddr())
+	tw.localIP, tw.localPort, _ = dnstapAddr(rw.LocalAddr())
+
+	packed, err := query.Pack()
+	if err == nil {
+		tw.queryMessage = packed
+		w.Write(&DnstapMessage{
+			Type:            DnstapClientQuery,
+			SocketProtocol:  tw.protocol,
+			QueryAddress:    tw.clientIP,
+			QueryPort:       tw.clientPort,
+			ResponseAddress: tw.localIP,
+			ResponsePort:    tw.localPort,
+			QueryTime:       queryTime,
+			QueryMessage:    packed,
+		})
+	}
+	return tw
+}
+
+// TapForwarder 记录一次转发的FORWARDER_QUERY和FORWARDER_RESPONSE（resp为nil时只记录查询）
+func (w *DnstapWriter) TapForwarder(serverAddr, protocol string, query, resp *dns.Msg, queryTime, responseTime time.Time) {
+	if !w.ForwarderEnabled() {
+		return
+	}
+
+	m := &DnstapMessage{
+		Type:           DnstapForwarderQuery,
+		SocketProtocol: dnstapProtoUDP,
+		QueryTime:      queryTime,
+	}
+	if protocol == "tcp" {
+		m.SocketProtocol = dnstapProtoTCP
+	}
+	if host, portStr, err := net.SplitHostPort(serverAddr); err == nil {
+		m.ResponseAddress = net.ParseIP(host)
+		m.ResponsePort, _ = strconv.Atoi(portStr)
+	}
+
+	packed, err := query.Pack()
+	if err != nil {
+		return
+	}
+	m.QueryMessage = packed
+	w.Write(m)
+
+	if resp == nil {
+		return
+	}
+	if packedResp, err := resp.Pack(); err == nil {
+		respMsg := *m
+		respMsg.Type = DnstapForwarderResponse
+		respMsg.ResponseTime = responseTime
+		respMsg.ResponseMessage = packedResp
+		w.Write(&respMsg)
+	}
+}
+
+// GetStats 获取统计信息
+func (w *DnstapWriter) GetStats() (written, dropped int64) {
+	if w == nil {
+		return 0, 0
+	}
+	return atomic.LoadInt64(&w.writtenCount), atomic.LoadInt64(&w.droppedCount)
+}
+
+// Close 关闭输出器，写出缓冲区中剩余的帧并发送STOP控制帧
+func (w *DnstapWriter) Close() {
+	if w == nil {
+		return
+	}
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+}
+
+// run 后台写出协程，输出断开时按退避间隔重连
+func (w *DnstapWriter) run() {
+	defer w.wg.Done()
+
+	backoff := time.Second
+	for {
+		conn, err := w.open()
+		if err != nil {
+			if w.logger != nil {
+				w.logger.Warn("dnstap输出连接失败: %v，%v后重试", err, backoff)
+			}
+			select {
+			case <-w.done:
+				w.dropPending()
+				return
+			case <-time.After(backoff):
+			}
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+
+		if stopped := w.writeLoop(conn); stopped {
+			return
+		}
+	}
+}
+
+// open 打开输出并完成Frame Streams握手
+func (w *DnstapWriter) open() (io.ReadWriteCloser, error) {
+	if w.network == "file" {
+		return w.openFile()
+	}
+
+	conn, err := net.DialTimeout(w.network, w.address, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// 双向握手：READY -> ACCEPT -> START
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := writeControlFrame(conn, fstrmControlReady, true); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ctype, err := readControlFrame(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ctype != fstrmControlAccept {
+		conn.Close()
+		return nil, fmt.Errorf("dnstap接收端返回非ACCEPT控制帧: %d", ctype)
+	}
+	if err := writeControlFrame(conn, fstrmControlStart, true); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// openFile 以追加方式打开文件输出并写出START控制帧，不覆盖已有的捕获数据
+// 上次会话未以STOP结束（写入失败或进程异常退出）时先截掉残缺帧并补写STOP，保证文件为连续的START/STOP会话序列
+func (w *DnstapWriter) openFile() (io.ReadWriteCloser, error) {
+	f, err := os.OpenFile(w.address, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	size := info.Size()
+	if w.fileSynced > 0 && size > w.fileSynced {
+		if err := f.Truncate(w.fileSynced); err != nil {
+			f.Close()
+			return nil, err
+		}
+		size = w.fileSynced
+	}
+	if size > 0 && !endsWithStopFrame(f, size) {
+		if err := writeControlFrame(f, fstrmControlStop, false); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if err := writeControlFrame(f, fstrmControlStart, true); err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.markSynced(f)
+	return f, nil
+}
+
+// markSynced 记录文件输出刷新成功后的长度
+func (w *DnstapWriter) markSynced(conn io.ReadWriteCloser) {
+	if f, ok := conn.(*os.File); ok {
+		if offset, err := f.Seek(0, io.SeekCurrent); err == nil {
+			w.fileSynced = offset
+		}
+	}
+}
+
+// endsWithStopFrame 判断文件是否以STOP控制帧结尾
+func endsWithStopFrame(f *os.File, size int64) bool {
+	var tail [12]byte
+	if size < int64(len(tail)) {
+		return false
+	}
+	if _, err := f.ReadAt(tail[:], size-int64(len(tail))); err != nil {
+		return false
+	}
+	return binary.BigEndian.Uint32(tail[0:4]) == 0 &&
+		binary.BigEndian.Uint32(tail[4:8]) == 4 &&
+		binary.BigEndian.Uint32(tail[8:12]) == fstrmControlStop
+}
+
+// writeLoop 持续写出数据帧，关闭时返回true，写出失败时返回false以便重连
+func (w *DnstapWriter) writeLoop(conn io.ReadWriteCloser) bool {
+	bw := bufio.NewWriterSize(conn, 64*1024)
+	flushTicker := time.NewTicker(time.Second)
+	defer flushTicker.Stop()
+
+	writeFrame := func(frame []byte) error {
+		if err := binary.Write(bw, binary.BigEndian, uint32(len(frame))); err != nil {
+			return err
+		}
+		_, err := bw.Write(frame)
+		return err
+	}
+
+	for {
+		select {
+		case frame := <-w.frames:
+			if err := writeFrame(frame); err != nil {
+				w.fail(conn, err)
+				return false
+			}
+			atomic.AddInt64(&w.writtenCount, 1)
+			// channel为空时立即刷新，降低延迟
+			if len(w.frames) == 0 {
+				if err := bw.Flush(); err != nil {
+					w.fail(conn, err)
+					return false
+				}
+				w.markSynced(conn)
+			}
+		case <-flushTicker.C:
+			if err := bw.Flush(); err != nil {
+				w.fail(conn, err)
+				return false
+			}
+			w.markSynced(conn)
+		case <-w.done:
+			// 写出剩余帧后发送STOP
+		drain:
+			for {
+				select {
+				case frame := <-w.frames:
+					if writeFrame(frame) == nil {
+						atomic.AddInt64(&w.writtenCount, 1)
+					}
+				default:
+					break drain
+				}
+			}
+			writeControlFrame(bw, fstrmControlStop, false)
+			bw.Flush()
+			if c, ok := conn.(net.Conn); ok {
+				c.SetReadDeadline(time.Now().Add(time.Second))
+				readControlFrame(c)
+			}
+			conn.Close()
+			return true
+		}
+	}
+}
+
+// fail 记录写出失败并关闭输出
+func (w *DnstapWriter) fail(conn io.Closer, err error) {
+	atomic.AddInt64(&w.droppedCount, 1)
+	if w.logger != nil {
+		w.logger.Warn("dnstap输出写入失败: %v，将重新连接", err)
+	}
+	conn.Close()
+}
+
+// dropPending 丢弃缓冲区中未写出的帧
+func (w *DnstapWriter) dropPending() {
+	for {
+		select {
+		case <-w.frames:
+			atomic.AddInt64(&w.droppedCount, 1)
+		default:
+			return
+		}
+	}
+}
+
+// dnstapResponseWriter 记录CLIENT_RESPONSE的ResponseWriter
+type dnstapResponseWriter struct {
+	dns.ResponseWriter
+	tap          *DnstapWriter
+	queryTime    time.Time
+	queryMessage []byte
+	clientIP     net.IP
+	clientPort   int
+	localIP      net.IP
+	localPort    int
+	protocol     int
+}
+
+// WriteMsg 写出响应并记录CLIENT_RESPONSE
+func (tw *dnstapResponseWriter) WriteMsg(m *dns.Msg) error {
+	if packed, err := m.Pack(); err == nil {
+		tw.tap.Write(&DnstapMessage{
+			Type:            DnstapClientResponse,
+			SocketProtocol:  tw.protocol,
+			QueryAddress:    tw.clientIP,
+			QueryPort:       tw.clientPort,
+			ResponseAddress: tw.localIP,
+			ResponsePort:    tw.localPort,
+			QueryTime:       tw.queryTime,
+			ResponseTime:    time.Now(),
+			QueryMessage:    tw.queryMessage,
+			ResponseMessage: packed,
+		})
+	}
+	return tw.ResponseWriter.WriteMsg(m)
+}
+
+// dnstapAddr 解析地址的IP、端口和dnstap传输协议
+func dnstapAddr(addr net.Addr) (net.IP, int, int) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port, dnstapProtoUDP
+	case *net.TCPAddr:
+		return a.IP, a.Port, dnstapProtoTCP
+	}
+	return nil, 0, dnstapProtoUDP
+}
+
+// encodeDnstap 按dnstap.proto将消息编码为Dnstap protobuf
+func encodeDnstap(identity, version []byte, m *DnstapMessage) []byte {
+	var msg []byte
+	msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(m.Type))
+
+	family, queryAddr, responseAddr := dnstapFamily(m.QueryAddress, m.ResponseAddress)
+	if family != 0 {
+		msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(family))
+	}
+	msg = protowire.AppendTag(msg, 3, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, uint64(m.SocketProtocol))
+
+	if queryAddr != nil {
+		msg = protowire.AppendTag(msg, 4, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, queryAddr)
+	}
+	if responseAddr != nil {
+		msg = protowire.AppendTag(msg, 5, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, responseAddr)
+	}
+	if m.QueryPort > 0 {
+		msg = protowire.AppendTag(msg, 6, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(m.QueryPort))
+	}
+	if m.ResponsePort > 0 {
+		msg = protowire.AppendTag(msg, 7, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(m.ResponsePort))
+	}
+	if !m.QueryTime.IsZero() {
+		msg = protowire.AppendTag(msg, 8, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(m.QueryTime.Unix()))
+		msg = protowire.AppendTag(msg, 9, protowire.Fixed32Type)
+		msg = protowire.AppendFixed32(msg, uint32(m.QueryTime.Nanosecond()))
+	}
+	if m.QueryMessage != nil {
+		msg = protowire.AppendTag(msg, 10, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, m.QueryMessage)
+	}
+	if !m.ResponseTime.IsZero() {
+		msg = protowire.AppendTag(msg, 12, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(m.ResponseTime.Unix()))
+		msg = protowire.AppendTag(msg, 13, protowire.Fixed32Type)
+		msg = protowire.AppendFixed32(msg, uint32(m.ResponseTime.Nanosecond()))
+	}
+	if m.ResponseMessage != nil {
+		msg = protowire.AppendTag(msg, 14, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, m.ResponseMessage)
+	}
+
+	var out []byte
+	if len(identity) > 0 {
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, identity)
+	}
+	if len(version) > 0 {
+		out = protowire.AppendTag(out, 2, protowire.BytesType)
+		out = protowire.AppendBytes(out, version)
+	}
+	out = protowire.AppendTag(out, 14, protowire.BytesType)
+	out = protowire.AppendBytes(out, msg)
+	// Dnstap.Type = MESSAGE
+	out = protowire.AppendTag(out, 15, protowire.VarintType)
+	out = protowire.AppendVarint(out, 1)
+	return out
+}
+
+// dnstapFamily 确定套接字族，IPv4地址使用4字节形式
+func dnstapFamily(queryAddr, responseAddr net.IP) (int, []byte, []byte) {
+	family := 0
+	normalize := func(ip net.IP) []byte {
+		if ip == nil {
+			return nil
+		}
+		if v4 := ip.To4(); v4 != nil {
+			family = dnstapFamilyInet
+			return v4
+		}
+		family = dnstapFamilyInet6
+		return ip.To16()
+	}
+	q := normalize(queryAddr)
+	r := normalize(responseAddr)
+	return family, q, r
+}
+
+// writeControlFrame 写出Frame Streams控制帧
+func writeControlFrame(w io.Writer, ctype uint32, withContentType bool) error {
+	payload := binary.BigEndian.AppendUint32(nil, ctype)
+	if withContentType {
+		payload = binary.BigEndian.AppendUint32(payload, fstrmFieldContentType)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(dnstapContentType)))
+		payload = append(payload, dnstapContentType...)
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, 0) // 转义序列：长度0表示控制帧
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+	_, err := w.Write(frame)
+	return err
+}
+
+// readControlFrame 读取Frame Streams控制帧，返回控制帧类型
+func readControlFrame(r io.Reader) (uint32, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint32(header[:4]) != 0 {
+		return 0, fmt.Errorf("期望控制帧，收到数据帧")
+	}
+	length := binary.BigEndian.Uint32(header[4:])
+	if length < 4 || length > fstrmMaxControlLen {
+		return 0, fmt.Errorf("无效的控制帧长度: %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(payload[:4]), nil
+}
@@ -0,0 +1,342 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/dnstap_test.go
+// dnstap编码和Frame Streams输出测试
+
+package sdns
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeFields 解码一层protobuf字段，返回字段号到原始值的映射（varint/fixed32为数值，bytes为切片）
+func decodeFields(t *testing.T, b []byte) map[protowire.Number]interface{} {
+	t.Helper()
+	fields := make(map[protowire.Number]interface{})
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("解析tag失败")
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			fields[num] = v
+			b = b[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			fields[num] = uint64(v)
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			fields[num] = v
+			b = b[n:]
+		default:
+			t.Fatalf("意外的字段类型 %v", typ)
+		}
+	}
+	return fields
+}
+
+// readFrame 读取一个Frame Streams帧，控制帧返回控制类型
+func readFrame(r io.Reader) (data []byte, control uint32, err error) {
+	var length uint32
+	if err = binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, 0, err
+	}
+	if length == 0 {
+		if err = binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, 0, err
+		}
+		payload := make([]byte, length)
+		if _, err = io.ReadFull(r, payload); err != nil {
+			return nil, 0, err
+		}
+		return nil, binary.BigEndian.Uint32(payload), nil
+	}
+	data = make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	return data, 0, err
+}
+
+// TestEncodeDnstap 测试dnstap protobuf编码
+func TestEncodeDnstap(t *testing.T) {
+	queryTime := time.Unix(1700000000, 123456789)
+	frame := encodeDnstap([]byte("ns1"), []byte("SteadyDNS"), &DnstapMessage{
+		Type:            DnstapClientQuery,
+		SocketProtocol:  dnstapProtoUDP,
+		QueryAddress:    net.ParseIP("192.168.1.10"),
+		QueryPort:       53000,
+		ResponseAddress: net.ParseIP("192.168.1.1"),
+		ResponsePort:    53,
+		QueryTime:       queryTime,
+		QueryMessage:    []byte{0x12, 0x34},
+	})
+
+	top := decodeFields(t, frame)
+	if !bytes.Equal(top[1].([]byte), []byte("ns1")) || top[15].(uint64) != 1 {
+		t.Fatalf("Dnstap = %v", top)
+	}
+
+	msg := decodeFields(t, top[14].([]byte))
+	checks := map[protowire.Number]uint64{
+		1: DnstapClientQuery,
+		2: dnstapFamilyInet,
+		3: dnstapProtoUDP,
+		6: 53000,
+		7: 53,
+		8: 1700000000,
+		9: 123456789,
+	}
+	for num, want := range checks {
+		if got, _ := msg[num].(uint64); got != want {
+			t.Errorf("字段 %d = %v, want %d", num, msg[num], want)
+		}
+	}
+	if !bytes.Equal(msg[4].([]byte), []byte{192, 168, 1, 10}) {
+		t.Errorf("query_address = %v，IPv4应编码为4字节", msg[4])
+	}
+	if _, ok := msg[12]; ok {
+		t.Errorf("查询消息不应包含response_time")
+	}
+}
+
+// TestDnstapWriterUnixSocket 测试Unix套接字双向握手和数据帧输出
+func TestDnstapWriterUnixSocket(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "dnstap.sock")
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Skipf("无法创建Unix套接字: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan [][]byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var frames [][]byte
+		for {
+			data, control, err := readFrame(conn)
+			if err != nil {
+				break
+			}
+			switch control {
+			case fstrmControlReady:
+				writeControlFrame(conn, fstrmControlAccept, true)
+			case fstrmControlStop:
+				writeControlFrame(conn, fstrmControlFinish, false)
+				received <- frames
+				return
+			case 0:
+				frames = append(frames, data)
+			}
+		}
+		received <- frames
+	}()
+
+	w, err := NewDnstapWriter("unix:"+sockPath, "test", []string{"forwarder"}, 100, nil)
+	if err != nil {
+		t.Fatalf("NewDnstapWriter() error = %v", err)
+	}
+	if w.ClientEnabled() || !w.ForwarderEnabled() {
+		t.Errorf("消息类别配置错误")
+	}
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+	resp := new(dns.Msg)
+	resp.SetReply(query)
+	w.TapForwarder("8.8.8.8:53", "udp", query, resp, time.Now(), time.Now())
+
+	// 等待帧写出后再关闭
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if written, _ := w.GetStats(); written == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Close()
+
+	select {
+	case frames := <-received:
+		if len(frames) != 2 {
+			t.Fatalf("收到 %d 帧, want 2", len(frames))
+		}
+		msg := decodeFields(t, decodeFields(t, frames[1])[14].([]byte))
+		if msg[1].(uint64) != DnstapForwarderResponse || msg[7].(uint64) != 53 {
+			t.Errorf("FORWARDER_RESPONSE = %v", msg)
+		}
+		if _, ok := msg[14]; !ok {
+			t.Errorf("缺少response_message")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("接收端超时")
+	}
+}
+
+// writeDnstapFile 创建文件输出器，写出一条消息后关闭
+func writeDnstapFile(t *testing.T, path string) {
+	t.Helper()
+	w, err := NewDnstapWriter("file:"+path, "test", nil, 100, nil)
+	if err != nil {
+		t.Fatalf("NewDnstapWriter() error = %v", err)
+	}
+	w.Write(&DnstapMessage{Type: DnstapClientQuery, SocketProtocol: dnstapProtoUDP, QueryTime: time.Now()})
+	// 等待文件打开并写出
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if written, _ := w.GetStats(); written == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	w.Close()
+}
+
+// readDnstapFile 读取文件中的控制帧类型序列和数据帧数量
+func readDnstapFile(t *testing.T, path string) ([]uint32, int) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取dnstap文件失败: %v", err)
+	}
+	r := bytes.NewReader(data)
+	var controls []uint32
+	dataFrames := 0
+	for {
+		frame, control, err := readFrame(r)
+		if err != nil {
+			break
+		}
+		if control != 0 {
+			controls = append(controls, control)
+		} else if frame != nil {
+			dataFrames++
+		}
+	}
+	return controls, dataFrames
+}
+
+// TestDnstapWriterFile 测试文件输出的单向帧流
+func TestDnstapWriterFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.dnstap")
+	writeDnstapFile(t, path)
+
+	controls, dataFrames := readDnstapFile(t, path)
+	if len(controls) != 2 || controls[0] != fstrmControlStart || controls[1] != fstrmControlStop || dataFrames != 1 {
+		t.Errorf("controls = %v, dataFrames = %d", controls, dataFrames)
+	}
+}
+
+// TestDnstapWriterFileAppend 测试重新打开文件输出时追加新会话，不覆盖已有数据
+func TestDnstapWriterFileAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.dnstap")
+	writeDnstapFile(t, path)
+	writeDnstapFile(t, path)
+
+	controls, dataFrames := readDnstapFile(t, path)
+	want := []uint32{fstrmControlStart, fstrmControlStop, fstrmControlStart, fstrmControlStop}
+	if !slices.Equal(controls, want) || dataFrames != 2 {
+		t.Errorf("controls = %v, dataFrames = %d, want %v/2", controls, dataFrames, want)
+	}
+
+	// 上次会话未以STOP结束时补写STOP
+	var buf bytes.Buffer
+	writeControlFrame(&buf, fstrmControlStart, true)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("写入dnstap文件失败: %v", err)
+	}
+	writeDnstapFile(t, path)
+
+	controls, dataFrames = readDnstapFile(t, path)
+	if !slices.Equal(controls, want) || dataFrames != 1 {
+		t.Errorf("controls = %v, dataFrames = %d, want %v/1", controls, dataFrames, want)
+	}
+}
+
+// TestDnstapWriterDrops 测试输出不可用时非阻塞丢弃
+func TestDnstapWriterDrops(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "missing.sock")
+	w, err := NewDnstapWriter("unix:"+sockPath, "test", nil, 1, nil)
+	if err != nil {
+		t.Fatalf("NewDnstapWriter() error = %v", err)
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		w.Write(&DnstapMessage{Type: DnstapClientQuery})
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("写入不应阻塞")
+	}
+	w.Close()
+
+	if written, dropped := w.GetStats(); written != 0 || dropped != 3 {
+		t.Errorf("written = %d, dropped = %d, want 0/3", written, dropped)
+	}
+}
+
+// TestNewDnstapWriterInvalid 测试无效输出配置
+func TestNewDnstapWriterInvalid(t *testing.T) {
+	for _, output := range []string{"", "udp:1.2.3.4:6000", "tcp:noport", "unix:"} {
+		if _, err := NewDnstapWriter(output, "", nil, 10, nil); err == nil {
+			t.Errorf("NewDnstapWriter(%q) 应返回错误", output)
+		}
+	}
+	if _, err := NewDnstapWriter("file:/tmp/x", "", []string{"auth"}, 10, nil); err == nil {
+		t.Errorf("不支持的消息类别应返回错误")
+	}
+}
+
+// TestReplaceDnstapWriter 测试重启时关闭上次创建的输出器
+func TestReplaceDnstapWriter(t *testing.T) {
+	old, err := NewDnstapWriter("file:"+filepath.Join(t.TempDir(), "old.dnstap"), "test", nil, 100, nil)
+	if err != nil {
+		t.Fatalf("NewDnstapWriter() error = %v", err)
+	}
+	dnstapMu.Lock()
+	currentDnstap = old
+	dnstapMu.Unlock()
+
+	// 未启用dnstap时替换为nil
+	if w := ReplaceDnstapWriterFromConfig(nil); w != nil {
+		t.Errorf("未启用dnstap时应返回nil")
+	}
+	select {
+	case <-old.done:
+	default:
+		t.Errorf("替换后上次的输出器未关闭")
+	}
+}
@@ -0,0 +1,355 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/dynamic_update.go
+// RFC 2136 动态更新：TSIG校验、按密钥授权，并通过BindManager写入权威域
+
+package sdns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"SteadyDNS/core/bind"
+	"SteadyDNS/core/bind/namedconf"
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/database"
+)
+
+// TSIGKey TSIG密钥
+type TSIGKey struct {
+	Name      string // 密钥名（绝对形式，小写）
+	Algorithm string // 算法（绝对形式，如hmac-sha256.）
+	Secret    string // base64编码的密钥
+}
+
+// TSIGKeyring TSIG密钥集合
+type TSIGKeyring struct {
+	mu   sync.RWMutex
+	keys map[string]TSIGKey
+}
+
+// GlobalTSIGKeyring 动态更新使用的密钥集合，未启用动态更新时为nil
+var GlobalTSIGKeyring *TSIGKeyring
+
+// tsigAlgorithms 支持的TSIG算法
+var tsigAlgorithms = map[string]string{
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha224": dns.HmacSHA224,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha384": dns.HmacSHA384,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+// NewTSIGKeyring 创建密钥集合
+func NewTSIGKeyring(keys ...TSIGKey) *TSIGKeyring {
+	kr := &TSIGKeyring{keys: make(map[string]TSIGKey)}
+	kr.Replace(keys)
+	return kr
+}
+
+// Replace 替换全部密钥
+func (kr *TSIGKeyring) Replace(keys []TSIGKey) {
+	m := make(map[string]TSIGKey, len(keys))
+	for _, k := range keys {
+		k.Name = dns.CanonicalName(k.Name)
+		m[k.Name] = k
+	}
+	kr.mu.Lock()
+	kr.keys = m
+	kr.mu.Unlock()
+}
+
+// Lookup 按名称查找密钥
+func (kr *TSIGKeyring) Lookup(name string) (TSIGKey, bool) {
+	if kr == nil {
+		return TSIGKey{}, false
+	}
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	k, ok := kr.keys[dns.CanonicalName(name)]
+	return k, ok
+}
+
+// LoadTSIGKeyFile 读取BIND格式的密钥文件（tsig-keygen的输出），可包含多个key语句
+func LoadTSIGKeyFile(path string) ([]TSIGKey, error) {
+	root, err := namedconf.NewParser(path).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("解析密钥文件失败: %v", err)
+	}
+
+	var keys []TSIGKey
+	for _, el := range root.ChildElements {
+		if el.Type != "block" || !strings.HasPrefix(el.Name, "key") {
+			continue
+		}
+		name, _ := el.Value.(string)
+		if name == "" {
+			name = strings.TrimSpace(strings.TrimPrefix(el.Name, "key"))
+		}
+		name = strings.Trim(name, `"`)
+
+		key := TSIGKey{Name: dns.CanonicalName(name)}
+		for _, child := range el.ChildElements {
+			value, _ := child.Value.(string)
+			switch child.Name {
+			case "algorithm":
+				alg, ok := tsigAlgorithms[strings.ToLower(strings.TrimSuffix(strings.Trim(value, `"`), "."))]
+				if !ok {
+					return nil, fmt.Errorf("密钥 %s 的算法不受支持: %s", name, value)
+				}
+				key.Algorithm = alg
+			case "secret":
+				key.Secret = strings.Trim(value, `"`)
+			}
+		}
+		if name == "" || key.Algorithm == "" || key.Secret == "" {
+			return nil, fmt.Errorf("密钥 %q 缺少名称、算法或密钥内容", name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// loadTSIGKeys 加载动态更新使用的密钥：配置的密钥文件加数据库中的密钥，同名时数据库优先
+func loadTSIGKeys() ([]TSIGKey, error) {
+	var keys []TSIGKey
+	if path := common.GetConfig("DynamicUpdate", "DYNAMIC_UPDATE_KEY_FILE"); path != "" {
+		fileKeys, err := LoadTSIGKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	stored, err := database.GetTSIGKeys()
+	if err != nil {
+		return nil, fmt.Errorf("读取数据库中的TSIG密钥失败: %v", err)
+	}
+	for _, k := range stored {
+		alg, ok := tsigAlgorithms[k.Algorithm]
+		if !ok {
+			return nil, fmt.Errorf("密钥 %s 的算法不受支持: %s", k.Name, k.Algorithm)
+		}
+		keys = append(keys, TSIGKey{Name: k.Name, Algorithm: alg, Secret: k.Secret})
+	}
+	return keys, nil
+}
+
+// ReloadTSIGKeys 密钥创建、轮换或删除后重新加载动态更新密钥，未启用动态更新时不做处理
+func ReloadTSIGKeys() error {
+	if GlobalTSIGKeyring == nil {
+		return nil
+	}
+	keys, err := loadTSIGKeys()
+	if err != nil {
+		return err
+	}
+	GlobalTSIGKeyring.Replace(keys)
+	return nil
+}
+
+// tsigContext 请求的TSIG校验结果，响应写出时据此签名
+type tsigContext struct {
+	secret     string // 校验通过（或仅时间超差）时为密钥内容，否则为空，响应TSIG不签名且MAC为空
+	requestMAC string
+	status     error
+}
+
+// verifyRequestTSIG 使用原始报文校验动态更新请求的TSIG，非动态更新或未签名的请求返回nil
+func verifyRequestTSIG(buf []byte, msg *dns.Msg) *tsigContext {
+	t := msg.IsTsig()
+	if t == nil || msg.Opcode != dns.OpcodeUpdate || GlobalTSIGKeyring == nil {
+		return nil
+	}
+
+	ctx := &tsigContext{requestMAC: t.MAC}
+	key, ok := GlobalTSIGKeyring.Lookup(t.Hdr.Name)
+	if !ok {
+		ctx.status = dns.ErrSecret
+		return ctx
+	}
+	if !strings.EqualFold(dns.Fqdn(t.Algorithm), key.Algorithm) {
+		ctx.status = dns.ErrKeyAlg
+		return ctx
+	}
+
+	ctx.status = dns.TsigVerify(buf, key.Secret, "", false)
+	if ctx.status == nil || errors.Is(ctx.status, dns.ErrTime) {
+		ctx.secret = key.Secret
+	}
+	return ctx
+}
+
+// packResponse 打包响应，响应带TSIG记录时按请求的校验结果签名
+func (c *tsigContext) packResponse(m *dns.Msg) ([]byte, error) {
+	if c == nil || m.IsTsig() == nil {
+		return m.Pack()
+	}
+	if c.secret == "" {
+		// 密钥未知、算法不支持或MAC错误时不签名，TSIG记录携带空MAC（RFC 8945 5.3.2）
+		t := m.IsTsig()
+		t.MAC = ""
+		t.MACSize = 0
+		return m.Pack()
+	}
+	buf, _, err := dns.TsigGenerate(m, c.secret, c.requestMAC, false)
+	return buf, err
+}
+
+// tsigStatus 返回校验结果，未签名的请求为nil
+func (c *tsigContext) tsigStatus() error {
+	if c == nil {
+		return nil
+	}
+	return c.status
+}
+
+// tsigErrorCode 将TSIG校验错误转换为TSIG扩展错误码（RFC 8945）
+func tsigErrorCode(err error) uint16 {
+	switch {
+	case err == nil:
+		return dns.RcodeSuccess
+	case errors.Is(err, dns.ErrTime):
+		return dns.RcodeBadTime
+	case errors.Is(err, dns.ErrSecret), errors.Is(err, dns.ErrKeyAlg):
+		return dns.RcodeBadKey
+	}
+	return dns.RcodeBadSig
+}
+
+// DynamicUpdater 动态更新处理器
+type DynamicUpdater struct {
+	bindManager *bind.BindManager
+	policy      *bind.UpdatePolicy
+	logger      *common.Logger
+}
+
+// NewDynamicUpdaterFromConfig 根据[DynamicUpdate]配置创建动态更新处理器，未启用或配置无效时返回nil
+func NewDynamicUpdaterFromConfig(logger *common.Logger) *DynamicUpdater {
+	if !common.GetConfigBool("DynamicUpdate", "DYNAMIC_UPDATE_ENABLED", false) {
+		return nil
+	}
+
+	keys, err := loadTSIGKeys()
+	if err != nil {
+		logger.Error("动态更新初始化失败: %v", err)
+		return nil
+	}
+	policy, err := bind.ParseUpdatePolicy(common.GetConfig("DynamicUpdate", "DYNAMIC_UPDATE_POLICY"))
+	if err != nil {
+		logger.Error("动态更新初始化失败: %v", err)
+		return nil
+	}
+	if len(policy.Grants) == 0 {
+		logger.Warn("动态更新已启用，但未配置授权规则，所有更新请求都将被拒绝")
+	}
+
+	GlobalTSIGKeyring = NewTSIGKeyring(keys...)
+	logger.Info("动态更新已启用，密钥数: %d，授权规则数: %d", len(keys), len(policy.Grants))
+	return &DynamicUpdater{
+		bindManager: bind.NewBindManager(),
+		policy:      policy,
+		logger:      logger,
+	}
+}
+
+// ServeUpdate 处理动态更新请求并写出响应，返回响应消息
+func (u *DynamicUpdater) ServeUpdate(w dns.ResponseWriter, r *dns.Msg, clientIP string) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetRcode(r, u.handle(w, r, clientIP))
+
+	// 签名请求的响应同样带TSIG，由ResponseWriter按校验结果签名
+	if t := r.IsTsig(); t != nil {
+		rt := &dns.TSIG{
+			Hdr:        dns.RR_Header{Name: t.Hdr.Name, Rrtype: dns.TypeTSIG, Class: dns.ClassANY},
+			Algorithm:  t.Algorithm,
+			Fudge:      300,
+			TimeSigned: uint64(time.Now().Unix()),
+			OrigId:     r.Id,
+			Error:      tsigErrorCode(w.TsigStatus()),
+		}
+		if rt.Error == dns.RcodeBadTime {
+			// BADTIME响应携带服务器时间，便于客户端校准
+			rt.OtherLen = 6
+			rt.OtherData = fmt.Sprintf("%012x", rt.TimeSigned)
+			rt.TimeSigned = t.TimeSigned
+		}
+		m.Extra = append(m.Extra, rt)
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		u.logger.Error("写入动态更新响应失败: %v", err)
+	}
+	return m
+}
+
+// handle 校验并执行动态更新，返回响应码
+func (u *DynamicUpdater) handle(w dns.ResponseWriter, r *dns.Msg, clientIP string) int {
+	// 先校验TSIG，再检查消息内容（RFC 8945 5.2）
+	t := r.IsTsig()
+	if t == nil {
+		u.logger.Warn("拒绝未签名的动态更新, 客户端 %s", clientIP)
+		return dns.RcodeRefused
+	}
+	if err := w.TsigStatus(); err != nil {
+		u.logger.Warn("动态更新TSIG校验失败: 密钥 %s, 客户端 %s: %v", t.Hdr.Name, clientIP, err)
+		return dns.RcodeNotAuth
+	}
+
+	if len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA || r.Question[0].Qclass != dns.ClassINET {
+		return dns.RcodeFormatError
+	}
+	zone := r.Question[0].Name
+
+	result, err := u.bindManager.ApplyDynamicUpdate(bind.DynamicUpdate{
+		Zone:    zone,
+		Prereqs: r.Answer,
+		Updates: r.Ns,
+		Key:     t.Hdr.Name,
+	}, u.policy)
+	if err != nil {
+		u.logger.Warn("动态更新未执行: 区域 %s, 密钥 %s, 客户端 %s, 响应 %s: %v",
+			zone, t.Hdr.Name, clientIP, dns.RcodeToString[result.Rcode], err)
+		return result.Rcode
+	}
+
+	if result.Changed {
+		u.logger.Info("动态更新已写入: 区域 %s, 密钥 %s, 客户端 %s, 名称 %s",
+			zone, t.Hdr.Name, clientIP, strings.Join(result.Names, ","))
+		for _, name := range result.Names {
+			ClearCacheByDomain(name)
+		}
+	}
+	return result.Rcode
+}
+
+// serveUpdate 处理动态更新请求，未启用动态更新时返回NOTIMP
+func (h *DNSHandler) serveUpdate(w dns.ResponseWriter, r *dns.Msg, clientIP string) *dns.Msg {
+	if h.updater == nil {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNotImplemented)
+		w.WriteMsg(m)
+		return m
+	}
+	return h.updater.ServeUpdate(w, r, clientIP)
+}
@@ -0,0 +1,232 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/dynamic_update_test.go
+// 动态更新的TSIG密钥加载、请求校验和响应签名测试
+
+package sdns
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"SteadyDNS/core/common"
+)
+
+const testTSIGSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+
+// recordWriter 记录响应报文的ResponseWriter，签名方式与UDP/TCP writer相同
+type recordWriter struct {
+	tsig *tsigContext
+	buf  []byte
+}
+
+func (w *recordWriter) WriteMsg(m *dns.Msg) (err error) {
+	w.buf, err = w.tsig.packResponse(m)
+	return err
+}
+func (w *recordWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+func (w *recordWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5353}
+}
+func (w *recordWriter) Write(b []byte) (int, error) { w.buf = b; return len(b), nil }
+func (w *recordWriter) Close() error                { return nil }
+func (w *recordWriter) TsigStatus() error           { return w.tsig.tsigStatus() }
+func (w *recordWriter) TsigTimersOnly(bool)         {}
+func (w *recordWriter) Hijack()                     {}
+
+// signedUpdate 构造并签名动态更新请求，返回请求、报文和请求MAC
+func signedUpdate(t *testing.T, keyName, secret string, timeSigned int64) (*dns.Msg, []byte, string) {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+	rr, _ := dns.NewRR("host.example.com. 300 IN A 192.0.2.1")
+	m.Insert([]dns.RR{rr})
+	m.SetTsig(keyName, dns.HmacSHA256, 300, timeSigned)
+	buf, mac, err := dns.TsigGenerate(m, secret, "", false)
+	if err != nil {
+		t.Fatalf("TsigGenerate() error = %v", err)
+	}
+	req := new(dns.Msg)
+	if err := req.Unpack(buf); err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	return req, buf, mac
+}
+
+// TestLoadTSIGKeyFile 测试读取BIND格式的密钥文件
+func TestLoadTSIGKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddns.key")
+	content := "key \"dhcp-updater\" {\n\talgorithm hmac-sha256;\n\tsecret \"" + testTSIGSecret + "\";\n};\n" +
+		"key \"acme.\" {\n\talgorithm HMAC-SHA512;\n\tsecret \"YWNtZQ==\";\n};\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadTSIGKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadTSIGKeyFile() error = %v", err)
+	}
+	kr := NewTSIGKeyring(keys...)
+	if k, ok := kr.Lookup("DHCP-Updater"); !ok || k.Algorithm != dns.HmacSHA256 || k.Secret != testTSIGSecret {
+		t.Errorf("Lookup(dhcp-updater) = %+v, %v", k, ok)
+	}
+	if k, ok := kr.Lookup("acme."); !ok || k.Algorithm != dns.HmacSHA512 {
+		t.Errorf("Lookup(acme.) = %+v, %v", k, ok)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.key")
+	os.WriteFile(bad, []byte("key \"x\" {\n\talgorithm hmac-md5;\n\tsecret \"YQ==\";\n};\n"), 0600)
+	if _, err := LoadTSIGKeyFile(bad); err == nil {
+		t.Errorf("不支持的算法应返回错误")
+	}
+}
+
+// TestDynamicUpdateTSIG 测试TSIG校验结果和响应签名
+func TestDynamicUpdateTSIG(t *testing.T) {
+	GlobalTSIGKeyring = NewTSIGKeyring(TSIGKey{Name: "dhcp-updater.", Algorithm: dns.HmacSHA256, Secret: testTSIGSecret})
+	defer func() { GlobalTSIGKeyring = nil }()
+	updater := &DynamicUpdater{logger: common.NewLogger()}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		key       string
+		secret    string
+		signed    int64
+		wantTSIG  uint16
+		wantRcode int
+	}{
+		// 区域段有两条记录，TSIG校验通过后以FORMERR拒绝，不会写入zone文件
+		{"校验通过", "dhcp-updater.", testTSIGSecret, now, dns.RcodeSuccess, dns.RcodeFormatError},
+		{"未知密钥", "other.", testTSIGSecret, now, dns.RcodeBadKey, dns.RcodeNotAuth},
+		{"签名错误", "dhcp-updater.", "b3RoZXJzZWNyZXQ=", now, dns.RcodeBadSig, dns.RcodeNotAuth},
+		{"时间超差", "dhcp-updater.", testTSIGSecret, now - 3600, dns.RcodeBadTime, dns.RcodeNotAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, buf, mac := signedUpdate(t, tt.key, tt.secret, tt.signed)
+			w := &recordWriter{tsig: verifyRequestTSIG(buf, req)}
+			if got := tsigErrorCode(w.TsigStatus()); got != tt.wantTSIG {
+				t.Fatalf("TSIG校验结果 = %s, want %s", dns.RcodeToString[int(got)], dns.RcodeToString[int(tt.wantTSIG)])
+			}
+
+			req.Question = append(req.Question, req.Question[0])
+			resp := updater.ServeUpdate(w, req, "127.0.0.1")
+			if resp.Rcode != tt.wantRcode {
+				t.Errorf("Rcode = %s, want %s", dns.RcodeToString[resp.Rcode], dns.RcodeToString[tt.wantRcode])
+			}
+
+			// 密钥和签名有效时响应可以用请求MAC校验；TSIG错误时只有BADTIME响应带MAC
+			if tt.wantTSIG == dns.RcodeSuccess {
+				if err := dns.TsigVerify(w.buf, testTSIGSecret, mac, false); err != nil {
+					t.Errorf("响应签名校验失败: %v", err)
+				}
+				return
+			}
+			var out dns.Msg
+			if err := out.Unpack(w.buf); err != nil {
+				t.Fatalf("Unpack() error = %v", err)
+			}
+			rt := out.IsTsig()
+			if rt == nil || rt.Error != tt.wantTSIG || (rt.MAC != "") != (tt.wantTSIG == dns.RcodeBadTime) {
+				t.Errorf("响应TSIG = %v", rt)
+			}
+		})
+	}
+}
+
+// TestDynamicUpdateUnsigned 测试未签名和未启用时的处理
+func TestDynamicUpdateUnsigned(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetUpdate("example.com.")
+
+	updater := &DynamicUpdater{logger: common.NewLogger()}
+	w := &recordWriter{}
+	if resp := updater.ServeUpdate(w, m, "127.0.0.1"); resp.Rcode != dns.RcodeRefused {
+		t.Errorf("未签名的更新 Rcode = %s, want REFUSED", dns.RcodeToString[resp.Rcode])
+	}
+
+	h := &DNSHandler{}
+	if resp := h.serveUpdate(w, m, "127.0.0.1"); resp.Rcode != dns.RcodeNotImplemented {
+		t.Errorf("未启用时 Rcode = %s, want NOTIMP", dns.RcodeToString[resp.Rcode])
+	}
+}
+
+// TestDynamicUpdateTSIGErrorResponse 测试无法签名时仍写出带空MAC的TSIG错误响应
+func TestDynamicUpdateTSIGErrorResponse(t *testing.T) {
+	GlobalTSIGKeyring = NewTSIGKeyring(TSIGKey{Name: "dhcp-updater.", Algorithm: dns.HmacSHA256, Secret: testTSIGSecret})
+	defer func() { GlobalTSIGKeyring = nil }()
+	updater := &DynamicUpdater{logger: common.NewLogger()}
+
+	// unsignedUpdate 构造带指定算法和MAC的请求，不依赖本地是否支持该算法
+	unsignedUpdate := func(keyName, algorithm string) (*dns.Msg, []byte) {
+		m := new(dns.Msg)
+		m.SetUpdate("example.com.")
+		m.SetTsig(keyName, algorithm, 300, time.Now().Unix())
+		rt := m.IsTsig()
+		rt.MAC = "00112233445566778899aabbccddeeff"
+		rt.MACSize = 16
+		buf, err := m.Pack()
+		if err != nil {
+			t.Fatalf("Pack() error = %v", err)
+		}
+		req := new(dns.Msg)
+		if err := req.Unpack(buf); err != nil {
+			t.Fatalf("Unpack() error = %v", err)
+		}
+		return req, buf
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		algorithm string
+	}{
+		{"未知密钥", "other.", dns.HmacSHA256},
+		{"不支持的算法", "dhcp-updater.", "hmac-md5.sig-alg.reg.int."},
+		{"未知密钥且算法不支持", "other.", "hmac-md5.sig-alg.reg.int."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, buf := unsignedUpdate(tt.key, tt.algorithm)
+			w := &recordWriter{tsig: verifyRequestTSIG(buf, req)}
+			resp := updater.ServeUpdate(w, req, "127.0.0.1")
+			if resp.Rcode != dns.RcodeNotAuth {
+				t.Errorf("Rcode = %s, want NOTAUTH", dns.RcodeToString[resp.Rcode])
+			}
+			if w.buf == nil {
+				t.Fatalf("未写出响应")
+			}
+			var out dns.Msg
+			if err := out.Unpack(w.buf); err != nil {
+				t.Fatalf("Unpack() error = %v", err)
+			}
+			rt := out.IsTsig()
+			if out.Rcode != dns.RcodeNotAuth || rt == nil || rt.Error != dns.RcodeBadKey || rt.MAC != "" || rt.MACSize != 0 {
+				t.Errorf("响应 Rcode = %s, TSIG = %v", dns.RcodeToString[out.Rcode], rt)
+			}
+		})
+	}
+}
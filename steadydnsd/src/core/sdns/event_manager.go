@@ -0,0 +1,546 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/event_manager.go
+
+package sdns
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/database"
+)
+
+// 事件类型
+const (
+	EventServerDown       = "server_down"       // 上游服务器触发熔断
+	EventServerUp         = "server_up"         // 上游服务器熔断恢复
+	EventGroupUnavailable = "group_unavailable" // 转发组内所有服务器均不可用
+	EventGroupRecovered   = "group_recovered"   // 转发组恢复可用
+	EventBindStopped      = "bind_stopped"      // BIND服务停止
+	EventBindRecovered    = "bind_recovered"    // BIND服务恢复运行
+	EventRateLimitBan     = "rate_limit_ban"    // 客户端因超出速率限制被封禁
+	EventCachePressure    = "cache_pressure"    // 缓存使用率超过清理阈值
+	EventDNSAnomaly       = "dns_anomaly"       // 检测到疑似DNS隧道或DGA流量
+	EventDNSSECExpiring   = "dnssec_expiring"   // 区域DNSSEC签名即将过期
+)
+
+// 事件严重级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Webhook消息格式
+const (
+	WebhookFormatGeneric  = "generic"
+	WebhookFormatSlack    = "slack"
+	WebhookFormatDiscord  = "discord"
+	WebhookFormatDingTalk = "dingtalk"
+	WebhookFormatWeCom    = "wecom"
+	WebhookFormatFeishu   = "feishu"
+)
+
+// AllEventTypes 所有支持的事件类型
+var AllEventTypes = []string{
+	EventServerDown,
+	EventServerUp,
+	EventGroupUnavailable,
+	EventGroupRecovered,
+	EventBindStopped,
+	EventBindRecovered,
+	EventRateLimitBan,
+	EventCachePressure,
+	EventDNSAnomaly,
+	EventDNSSECExpiring,
+}
+
+// WebhookFormats 所有支持的Webhook消息格式
+var WebhookFormats = []string{
+	WebhookFormatGeneric,
+	WebhookFormatSlack,
+	WebhookFormatDiscord,
+	WebhookFormatDingTalk,
+	WebhookFormatWeCom,
+	WebhookFormatFeishu,
+}
+
+// eventQueueSize 事件队列容量，队列满时丢弃新事件，避免阻塞DNS处理路径
+const eventQueueSize = 1024
+
+// Event 系统事件
+type Event struct {
+	ID        uint                   `json:"id,omitempty"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"`
+	Source    string                 `json:"source"`
+	Subject   string                 `json:"subject"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// EventManager 事件管理器
+// 负责事件的过滤、持久化和Webhook投递
+type EventManager struct {
+	mu            sync.RWMutex
+	enabled       bool
+	typeEnabled   map[string]bool
+	cooldown      time.Duration
+	retentionDays int
+	maxRetries    int
+	retryBackoff  time.Duration
+	client        *http.Client
+
+	lastEmitted map[string]time.Time // 冷却期内的事件（类型+对象）上次触发时间
+	states      map[string]bool      // 状态类事件的当前状态，用于检测状态变化
+
+	queue     chan *Event
+	stopChan  chan struct{}
+	startOnce sync.Once
+
+	emittedCount   int64
+	droppedCount   int64
+	deliveredCount int64
+	failedCount    int64
+
+	logger *common.Logger
+}
+
+var (
+	eventManager     *EventManager
+	eventManagerOnce sync.Once
+)
+
+// GetEventManager 获取全局事件管理器实例
+func GetEventManager() *EventManager {
+	eventManagerOnce.Do(func() {
+		eventManager = NewEventManager(common.NewLogger())
+	})
+	return eventManager
+}
+
+// NewEventManager 创建事件管理器
+func NewEventManager(logger *common.Logger) *EventManager {
+	em := &EventManager{
+		typeEnabled: make(map[string]bool),
+		lastEmitted: make(map[string]time.Time),
+		states:      make(map[string]bool),
+		queue:       make(chan *Event, eventQueueSize),
+		stopChan:    make(chan struct{}),
+		logger:      logger,
+	}
+	em.ReloadConfig()
+	return em
+}
+
+// ReloadConfig 从[Events]配置节重新加载事件配置
+func (em *EventManager) ReloadConfig() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.enabled = common.GetConfigBool("Events", "EVENTS_ENABLED", true)
+	for _, eventType := range AllEventTypes {
+		em.typeEnabled[eventType] = common.GetConfigBool("Events", eventTypeConfigKey(eventType), true)
+	}
+
+	cooldown := common.GetConfigInt("Events", "EVENT_COOLDOWN_SECONDS", 300)
+	if cooldown < 0 {
+		cooldown = 0
+	}
+	em.cooldown = time.Duration(cooldown) * time.Second
+
+	em.retentionDays = common.GetConfigInt("Events", "EVENT_RETENTION_DAYS", 30)
+	if em.retentionDays <= 0 {
+		em.retentionDays = 30
+	}
+
+	em.maxRetries = common.GetConfigInt("Events", "WEBHOOK_MAX_RETRIES", 3)
+	if em.maxRetries < 0 {
+		em.maxRetries = 0
+	}
+
+	backoff := common.GetConfigInt("Events", "WEBHOOK_RETRY_BACKOFF", 2)
+	if backoff <= 0 {
+		backoff = 2
+	}
+	em.retryBackoff = time.Duration(backoff) * time.Second
+
+	timeout := common.GetConfigInt("Events", "WEBHOOK_TIMEOUT", 5)
+	if timeout <= 0 {
+		timeout = 5
+	}
+	em.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+}
+
+// eventTypeConfigKey 获取事件类型对应的启用开关配置键，如server_down对应SERVER_DOWN_ENABLED
+func eventTypeConfigKey(eventType string) string {
+	return strings.ToUpper(eventType) + "_ENABLED"
+}
+
+// IsTypeEnabled 检查事件类型是否启用
+func (em *EventManager) IsTypeEnabled(eventType string) bool {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+	return em.enabled && em.typeEnabled[eventType]
+}
+
+// Start 启动事件处理和过期事件清理协程，重复调用无副作用
+func (em *EventManager) Start() {
+	em.startOnce.Do(func() {
+		go em.processEvents()
+		go em.cleanupLoop()
+		em.logger.Info("事件管理器已启动")
+	})
+}
+
+// Stop 停止事件管理器
+func (em *EventManager) Stop() {
+	select {
+	case <-em.stopChan:
+	default:
+		close(em.stopChan)
+	}
+}
+
+// Emit 发布事件
+// 事件类型未启用时直接忽略；队列已满时丢弃事件并计数，不会阻塞调用方
+func (em *EventManager) Emit(event *Event) {
+	if event == nil || !em.IsTypeEnabled(event.Type) {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Severity == "" {
+		event.Severity = SeverityInfo
+	}
+
+	select {
+	case em.queue <- event:
+		atomic.AddInt64(&em.emittedCount, 1)
+	default:
+		atomic.AddInt64(&em.droppedCount, 1)
+	}
+}
+
+// EmitWithCooldown 发布事件，同一类型和对象的事件在冷却期内只发布一次
+// 用于速率限制封禁、缓存压力等可能高频重复触发的事件
+func (em *EventManager) EmitWithCooldown(event *Event) {
+	if event == nil || !em.IsTypeEnabled(event.Type) {
+		return
+	}
+
+	key := event.Type + "|" + event.Subject
+	now := time.Now()
+
+	em.mu.Lock()
+	if last, ok := em.lastEmitted[key]; ok && now.Sub(last) < em.cooldown {
+		em.mu.Unlock()
+		return
+	}
+	em.lastEmitted[key] = now
+	em.mu.Unlock()
+
+	em.Emit(event)
+}
+
+// Transition 更新状态类事件的状态
+// 参数：
+//   - key: 状态键，如"group:example.com"
+//   - active: 新状态
+//
+// 返回：
+//   - bool: 状态是否发生变化（初始状态视为false）
+func (em *EventManager) Transition(key string, active bool) bool {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	if em.states[key] == active {
+		return false
+	}
+	em.states[key] = active
+	return true
+}
+
+// GetStats 获取事件管理器统计信息
+func (em *EventManager) GetStats() map[string]interface{} {
+	em.mu.RLock()
+	typeEnabled := make(map[string]bool, len(em.typeEnabled))
+	for k, v := range em.typeEnabled {
+		typeEnabled[k] = v
+	}
+	enabled := em.enabled
+	em.mu.RUnlock()
+
+	return map[string]interface{}{
+		"enabled":        enabled,
+		"typeEnabled":    typeEnabled,
+		"queueLength":    len(em.queue),
+		"queueCapacity":  cap(em.queue),
+		"emittedCount":   atomic.LoadInt64(&em.emittedCount),
+		"droppedCount":   atomic.LoadInt64(&em.droppedCount),
+		"deliveredCount": atomic.LoadInt64(&em.deliveredCount),
+		"failedCount":    atomic.LoadInt64(&em.failedCount),
+	}
+}
+
+// processEvents 处理事件队列：持久化事件并投递到Webhook目标
+func (em *EventManager) processEvents() {
+	for {
+		select {
+		case event := <-em.queue:
+			em.handleEvent(event)
+		case <-em.stopChan:
+			return
+		}
+	}
+}
+
+// handleEvent 处理单个事件
+func (em *EventManager) handleEvent(event *Event) {
+	em.logger.Info("系统事件 [%s] %s: %s", event.Severity, event.Type, event.Message)
+
+	if database.DB == nil {
+		return
+	}
+
+	record := &database.SystemEvent{
+		Timestamp: event.Timestamp,
+		Type:      event.Type,
+		Severity:  event.Severity,
+		Source:    event.Source,
+		Subject:   event.Subject,
+		Message:   event.Message,
+	}
+	if len(event.Details) > 0 {
+		if data, err := json.Marshal(event.Details); err == nil {
+			record.Details = string(data)
+		}
+	}
+	if err := database.SaveSystemEvent(record); err != nil {
+		em.logger.Error("持久化系统事件失败: %v", err)
+	} else {
+		event.ID = record.ID
+	}
+
+	targets, err := database.GetEnabledWebhookTargets()
+	if err != nil {
+		em.logger.Error("获取Webhook目标失败: %v", err)
+		return
+	}
+
+	for _, target := range targets {
+		if !webhookSubscribed(target, event.Type) {
+			continue
+		}
+		go em.deliverWithRetry(target, event)
+	}
+}
+
+// webhookSubscribed 检查Webhook目标是否订阅了指定事件类型
+func webhookSubscribed(target database.WebhookTarget, eventType string) bool {
+	if strings.TrimSpace(target.EventTypes) == "" {
+		return true
+	}
+	for _, t := range strings.Split(target.EventTypes, ",") {
+		if strings.TrimSpace(t) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliverWithRetry 投递事件到Webhook目标，失败时按指数退避重试
+func (em *EventManager) deliverWithRetry(target database.WebhookTarget, event *Event) {
+	em.mu.RLock()
+	maxRetries := em.maxRetries
+	backoff := em.retryBackoff
+	em.mu.RUnlock()
+
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff * time.Duration(1<<(attempt-1))):
+			case <-em.stopChan:
+				return
+			}
+		}
+
+		if err = em.Deliver(target, event); err == nil {
+			atomic.AddInt64(&em.deliveredCount, 1)
+			return
+		}
+		em.logger.Warn("Webhook投递失败 - 目标: %s, 事件: %s, 第 %d 次尝试: %v", target.Name, event.Type, attempt+1, err)
+	}
+
+	atomic.AddInt64(&em.failedCount, 1)
+	em.logger.Error("Webhook投递最终失败 - 目标: %s, 事件: %s: %v", target.Name, event.Type, err)
+}
+
+// Deliver 投递事件到Webhook目标（单次尝试，不重试）
+func (em *EventManager) Deliver(target database.WebhookTarget, event *Event) error {
+	body, err := BuildWebhookPayload(target.Format, event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建Webhook请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SteadyDNS-Webhook")
+	req.Header.Set("X-SteadyDNS-Event", event.Type)
+	if target.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(target.Secret))
+		mac.Write(body)
+		req.Header.Set("X-SteadyDNS-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	em.mu.RLock()
+	client := em.client
+	em.mu.RUnlock()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送Webhook请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook返回非成功状态码: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// formatEventText 生成聊天工具使用的纯文本消息
+func formatEventText(event *Event) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[SteadyDNS][%s] %s\n", strings.ToUpper(event.Severity), event.Message)
+	fmt.Fprintf(&sb, "事件类型: %s\n", event.Type)
+	if event.Subject != "" {
+		fmt.Fprintf(&sb, "对象: %s\n", event.Subject)
+	}
+	fmt.Fprintf(&sb, "时间: %s", event.Timestamp.Format("2006-01-02 15:04:05"))
+	return sb.String()
+}
+
+// BuildWebhookPayload 根据消息格式生成Webhook请求体
+func BuildWebhookPayload(format string, event *Event) ([]byte, error) {
+	var payload interface{}
+
+	switch format {
+	case "", WebhookFormatGeneric:
+		payload = event
+	case WebhookFormatSlack:
+		payload = map[string]interface{}{"text": formatEventText(event)}
+	case WebhookFormatDiscord:
+		payload = map[string]interface{}{"content": formatEventText(event)}
+	case WebhookFormatDingTalk, WebhookFormatWeCom:
+		payload = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": formatEventText(event)},
+		}
+	case WebhookFormatFeishu:
+		payload = map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": formatEventText(event)},
+		}
+	default:
+		return nil, fmt.Errorf("不支持的Webhook消息格式: %s", format)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化Webhook消息失败: %v", err)
+	}
+	return data, nil
+}
+
+// IsValidWebhookFormat 检查Webhook消息格式是否有效
+func IsValidWebhookFormat(format string) bool {
+	for _, f := range WebhookFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidEventType 检查事件类型是否有效
+func IsValidEventType(eventType string) bool {
+	for _, t := range AllEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanupLoop 定期清理过期事件
+func (em *EventManager) cleanupLoop() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			em.pruneCooldowns()
+			if database.DB == nil {
+				continue
+			}
+			em.mu.RLock()
+			retentionDays := em.retentionDays
+			em.mu.RUnlock()
+			if err := database.CleanOldSystemEvents(retentionDays); err != nil {
+				em.logger.Error("清理过期系统事件失败: %v", err)
+			}
+		case <-em.stopChan:
+			return
+		}
+	}
+}
+
+// pruneCooldowns 清理已超过冷却期的事件记录，避免内存持续增长
+func (em *EventManager) pruneCooldowns() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	now := time.Now()
+	for key, last := range em.lastEmitted {
+		if now.Sub(last) >= em.cooldown {
+			delete(em.lastEmitted, key)
+		}
+	}
+}
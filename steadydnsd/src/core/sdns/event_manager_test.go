@@ -0,0 +1,143 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/event_manager_test.go
+// 事件管理器测试
+
+package sdns
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/database"
+)
+
+// TestBuildWebhookPayload 测试各消息格式的请求体
+func TestBuildWebhookPayload(t *testing.T) {
+	event := &Event{
+		Type:      EventServerDown,
+		Severity:  SeverityWarning,
+		Subject:   "8.8.8.8:53",
+		Message:   "上游服务器 8.8.8.8:53 触发熔断",
+		Timestamp: time.Now(),
+	}
+
+	tests := []struct {
+		format string
+		key    string
+	}{
+		{WebhookFormatGeneric, "type"},
+		{WebhookFormatSlack, "text"},
+		{WebhookFormatDiscord, "content"},
+		{WebhookFormatDingTalk, "msgtype"},
+		{WebhookFormatWeCom, "msgtype"},
+		{WebhookFormatFeishu, "msg_type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, err := BuildWebhookPayload(tt.format, event)
+			if err != nil {
+				t.Fatalf("BuildWebhookPayload() error = %v", err)
+			}
+			var payload map[string]interface{}
+			if err := json.Unmarshal(data, &payload); err != nil {
+				t.Fatalf("请求体不是有效JSON: %v", err)
+			}
+			if _, ok := payload[tt.key]; !ok {
+				t.Errorf("请求体缺少字段 %s: %s", tt.key, data)
+			}
+		})
+	}
+
+	if _, err := BuildWebhookPayload("unknown", event); err == nil {
+		t.Errorf("未知格式应返回错误")
+	}
+}
+
+// TestEventManagerFiltering 测试事件开关、冷却和状态变化检测
+func TestEventManagerFiltering(t *testing.T) {
+	em := NewEventManager(common.NewLogger())
+
+	t.Run("冷却期内重复事件被忽略", func(t *testing.T) {
+		em.EmitWithCooldown(&Event{Type: EventRateLimitBan, Subject: "10.0.0.1"})
+		em.EmitWithCooldown(&Event{Type: EventRateLimitBan, Subject: "10.0.0.1"})
+		em.EmitWithCooldown(&Event{Type: EventRateLimitBan, Subject: "10.0.0.2"})
+		if len(em.queue) != 2 {
+			t.Errorf("队列长度 = %d, want 2", len(em.queue))
+		}
+	})
+
+	t.Run("禁用的事件类型被忽略", func(t *testing.T) {
+		em.mu.Lock()
+		em.typeEnabled[EventCachePressure] = false
+		em.mu.Unlock()
+
+		before := len(em.queue)
+		em.Emit(&Event{Type: EventCachePressure})
+		if len(em.queue) != before {
+			t.Errorf("禁用的事件类型不应入队")
+		}
+	})
+
+	t.Run("状态变化检测", func(t *testing.T) {
+		if em.Transition("group:example.com", false) {
+			t.Errorf("初始状态为false，不应视为变化")
+		}
+		if !em.Transition("group:example.com", true) {
+			t.Errorf("false->true 应视为变化")
+		}
+		if em.Transition("group:example.com", true) {
+			t.Errorf("重复状态不应视为变化")
+		}
+	})
+}
+
+// TestEventManagerDeliverWithRetry 测试Webhook投递失败后重试
+func TestEventManagerDeliverWithRetry(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-SteadyDNS-Signature") == "" {
+			t.Errorf("设置密钥时应包含签名头")
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	em := NewEventManager(common.NewLogger())
+	em.retryBackoff = 10 * time.Millisecond
+
+	target := database.WebhookTarget{Name: "test", URL: server.URL, Format: WebhookFormatGeneric, Secret: "s3cret"}
+	em.deliverWithRetry(target, &Event{Type: EventServerDown, Timestamp: time.Now()})
+
+	if got := atomic.LoadInt32(&attempts); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+	if em.deliveredCount != 1 || em.failedCount != 0 {
+		t.Errorf("delivered = %d, failed = %d", em.deliveredCount, em.failedCount)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/event_monitor.go
+
+package sdns
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"SteadyDNS/core/bind"
+	"SteadyDNS/core/common"
+)
+
+// notifyServerDown 上游服务器触发熔断时发布事件，并检查所属转发组是否整体不可用
+func (f *DNSForwarder) notifyServerDown(stats *ServerStats) {
+	stats.Mu.RLock()
+	addr := stats.Address
+	consecutiveFails := stats.ConsecutiveFails
+	stats.Mu.RUnlock()
+
+	GetEventManager().Emit(&Event{
+		Type:     EventServerDown,
+		Severity: SeverityWarning,
+		Source:   "forwarder",
+		Subject:  addr,
+		Message:  fmt.Sprintf("上游服务器 %s 触发熔断", addr),
+		Details: map[string]interface{}{
+			"group":            f.getServerGroupDomain(addr),
+			"consecutiveFails": consecutiveFails,
+		},
+	})
+
+	f.checkGroupAvailability()
+}
+
+// notifyServerUp 上游服务器熔断恢复时发布事件，并检查所属转发组是否恢复可用
+func (f *DNSForwarder) notifyServerUp(addr string) {
+	GetEventManager().Emit(&Event{
+		Type:     EventServerUp,
+		Severity: SeverityInfo,
+		Source:   "forwarder",
+		Subject:  addr,
+		Message:  fmt.Sprintf("上游服务器 %s 熔断恢复", addr),
+		Details: map[string]interface{}{
+			"group": f.getServerGroupDomain(addr),
+		},
+	})
+
+	f.checkGroupAvailability()
+}
+
+// checkGroupAvailability 检查各转发组是否所有服务器均处于熔断状态
+// 仅在状态发生变化时发布group_unavailable/group_recovered事件
+func (f *DNSForwarder) checkGroupAvailability() {
+	f.mu.RLock()
+	groupServers := make(map[string][]string, len(f.groups))
+	for domain, group := range f.groups {
+		for _, priorityServers := range group.PriorityQueues {
+			for _, server := range priorityServers {
+				groupServers[domain] = append(groupServers[domain], net.JoinHostPort(server.Address, strconv.Itoa(server.Port)))
+			}
+		}
+	}
+	f.mu.RUnlock()
+
+	em := GetEventManager()
+	for domain, addrs := range groupServers {
+		if len(addrs) == 0 {
+			continue
+		}
+
+		unavailable := true
+		for _, addr := range addrs {
+			stats := f.GetServerStats(addr)
+			if stats == nil {
+				unavailable = false
+				break
+			}
+			stats.Mu.RLock()
+			broken := stats.CircuitBroken
+			stats.Mu.RUnlock()
+			if !broken {
+				unavailable = false
+				break
+			}
+		}
+
+		if !em.Transition("group:"+domain, unavailable) {
+			continue
+		}
+
+		if unavailable {
+			em.Emit(&Event{
+				Type:     EventGroupUnavailable,
+				Severity: SeverityCritical,
+				Source:   "forwarder",
+				Subject:  domain,
+				Message:  fmt.Sprintf("转发组 %s 的所有上游服务器均不可用", domain),
+				Details: map[string]interface{}{
+					"servers": addrs,
+				},
+			})
+		} else {
+			em.Emit(&Event{
+				Type:     EventGroupRecovered,
+				Severity: SeverityInfo,
+				Source:   "forwarder",
+				Subject:  domain,
+				Message:  fmt.Sprintf("转发组 %s 恢复可用", domain),
+			})
+		}
+	}
+}
+
+// StartBindMonitor 启动BIND服务状态监控
+// 按[Events] BIND_MONITOR_INTERVAL配置的间隔检查BIND状态，状态变化时发布事件
+func (em *EventManager) StartBindMonitor() {
+	interval := common.GetConfigInt("Events", "BIND_MONITOR_INTERVAL", 30)
+	if interval <= 0 {
+		em.logger.Info("BIND状态监控已禁用")
+		return
+	}
+
+	bindManager := bind.NewBindManager()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				em.checkBindStatus(bindManager)
+			case <-em.stopChan:
+				return
+			}
+		}
+	}()
+
+	em.logger.Info("BIND状态监控已启动，间隔: %ds", interval)
+}
+
+// checkBindStatus 检查BIND服务状态并在状态变化时发布事件
+func (em *EventManager) checkBindStatus(bindManager *bind.BindManager) {
+	status, err := bindManager.GetBindStatus()
+	if err != nil || (status != "running" && status != "stopped") {
+		return
+	}
+
+	stopped := status == "stopped"
+	if !em.Transition("bind", stopped) {
+		return
+	}
+
+	if stopped {
+		em.Emit(&Event{
+			Type:     EventBindStopped,
+			Severity: SeverityCritical,
+			Source:   "bind",
+			Subject:  "named",
+			Message:  "BIND服务已停止，权威域解析不可用",
+		})
+	} else {
+		em.Emit(&Event{
+			Type:     EventBindRecovered,
+			Severity: SeverityInfo,
+			Source:   "bind",
+			Subject:  "named",
+			Message:  "BIND服务已恢复运行",
+		})
+	}
+}
+
+// StartDNSSECMonitor 启动DNSSEC签名有效期监控
+// 按[Events] DNSSEC_MONITOR_INTERVAL配置的间隔检查已签名区域，签名即将过期时发布事件
+func (em *EventManager) StartDNSSECMonitor() {
+	interval := common.GetConfigInt("Events", "DNSSEC_MONITOR_INTERVAL", 3600)
+	if interval <= 0 {
+		em.logger.Info("DNSSEC签名监控已禁用")
+		return
+	}
+
+	bindManager := bind.NewBindManager()
+
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+
+		em.checkDNSSECExpiry(bindManager)
+		for {
+			select {
+			case <-ticker.C:
+				em.checkDNSSECExpiry(bindManager)
+			case <-em.stopChan:
+				return
+			}
+		}
+	}()
+
+	em.logger.Info("DNSSEC签名监控已启动，间隔: %ds", interval)
+}
+
+// checkDNSSECExpiry 检查已签名区域的签名有效期，进入即将过期状态时发布事件
+func (em *EventManager) checkDNSSECExpiry(bindManager *bind.BindManager) {
+	statuses, err := bindManager.GetDNSSECStatuses()
+	if err != nil {
+		em.logger.Debug("获取DNSSEC签名状态失败: %v", err)
+		return
+	}
+
+	for _, status := range statuses {
+		if !em.Transition("dnssec:"+status.Domain, status.ExpiringSoon) || !status.ExpiringSoon {
+			continue
+		}
+		em.Emit(&Event{
+			Type:     EventDNSSECExpiring,
+			Severity: SeverityWarning,
+			Source:   "bind",
+			Subject:  status.Domain,
+			Message:  fmt.Sprintf("区域 %s 的DNSSEC签名即将过期", status.Domain),
+			Details: map[string]interface{}{
+				"policy":     status.Policy,
+				"expiration": status.SignatureExpiration,
+				"warnings":   status.Warnings,
+			},
+		})
+	}
+}
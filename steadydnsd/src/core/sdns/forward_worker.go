@@ -72,6 +72,11 @@ func (p *ForwardWorkerPool) SubmitTask(task *DNSForwardTask) {
 	}
 }
 
+// QueueLength 获取当前排队的转发任务数
+func (p *ForwardWorkerPool) QueueLength() int {
+	return len(p.taskChan)
+}
+
 // Close 关闭协程池
 func (p *ForwardWorkerPool) Close() {
 	p.shutdown = true
@@ -41,11 +41,12 @@ type ForwardGroup struct {
 
 // DNSServer 表示单个DNS服务器
 type DNSServer struct {
-	Address     string `json:"address"`     // DNS服务器地址，支持IPv4/IPv6
-	Port        int    `json:"port"`        // 端口，默认53
-	Description string `json:"description"` // 描述，长度0-65535
-	QueueIndex  int    `json:"queue_index"` // 队列序号
-	Priority    int    `json:"priority"`    // 优先级 (1-3)
+	Address       string `json:"address"`        // DNS服务器地址，支持IPv4/IPv6
+	Port          int    `json:"port"`           // 端口，默认53
+	Description   string `json:"description"`    // 描述，长度0-65535
+	QueueIndex    int    `json:"queue_index"`    // 队列序号
+	Priority      int    `json:"priority"`       // 优先级 (1-3)
+	SourceAddress string `json:"source_address"` // 出站查询源地址（IP或网卡名），已合并转发组配置
 }
 
 // GetAddress 获取服务器完整地址（IP:Port）
@@ -77,6 +78,14 @@ type DNSForwarder struct {
 	AdaptiveCookieManager  *AdaptiveCookieManager  // 自适应Cookie管理器
 	TCPConnectionPool      *TCPConnectionPool      // TCP连接池
 	ServerCapabilityProber *ServerCapabilityProber // 服务器能力探测器
+	SourceResolver         *SourceAddressResolver  // 出站查询源地址解析器
+
+	// 出站查询加固
+	responseValidator *DNSMessageValidator // 上游响应校验器
+	qnameCase0x20     bool                 // 是否启用0x20查询名大小写随机化
+
+	// dnstap输出，为nil时不记录转发查询
+	dnstap *DnstapWriter
 
 	// Goroutine生命周期管理
 	ctx    context.Context    // 上下文，用于控制所有后台协程的生命周期
@@ -198,6 +207,9 @@ func (f *DNSForwarder) LoadForwardGroups() error {
 		return fmt.Errorf("获取所有转发组失败: %v", err)
 	}
 
+	// 绑定了源地址的上游连接标识
+	endpoints := make(map[string]bool)
+
 	// 检查是否有ID=1的默认转发组
 	var defaultGroup *database.ForwardGroup
 	var defaultDNSServers []database.DNSServer
@@ -228,12 +240,21 @@ func (f *DNSForwarder) LoadForwardGroups() error {
 			if _, exists := dnsGroup.PriorityQueues[server.Priority]; !exists {
 				dnsGroup.PriorityQueues[server.Priority] = []*DNSServer{}
 			}
+			// 服务器级源地址优先于转发组级源地址
+			sourceAddress := server.SourceAddress
+			if sourceAddress == "" {
+				sourceAddress = group.SourceAddress
+			}
 			dnsServer := &DNSServer{
-				Address:     server.Address,
-				Port:        server.Port,
-				Description: server.Description,
-				QueueIndex:  server.QueueIndex,
-				Priority:    server.Priority,
+				Address:       server.Address,
+				Port:          server.Port,
+				Description:   server.Description,
+				QueueIndex:    server.QueueIndex,
+				Priority:      server.Priority,
+				SourceAddress: sourceAddress,
+			}
+			if sourceAddress != "" {
+				endpoints[UpstreamEndpoint(dnsServer.GetAddress(), sourceAddress)] = true
 			}
 			dnsGroup.PriorityQueues[server.Priority] = append(dnsGroup.PriorityQueues[server.Priority], dnsServer)
 		}
@@ -279,12 +300,53 @@ func (f *DNSForwarder) LoadForwardGroups() error {
 	// 清除域名匹配缓存，因为转发组配置已更新
 	f.clearMatchCache()
 
+	// 更新源地址绑定，关闭不再使用的连接标识的TCP连接
+	f.applySourceEndpoints(endpoints)
+
 	// 清理服务器统计信息，只保留当前活跃的服务器
 	f.CleanupServerStats(defaultDNSServers)
 
 	return nil
 }
 
+// applySourceEndpoints 更新绑定了源地址的上游连接标识，关闭不再使用的标识的TCP连接
+func (f *DNSForwarder) applySourceEndpoints(endpoints map[string]bool) {
+	if f.SourceResolver == nil {
+		return
+	}
+
+	for endpoint := range endpoints {
+		serverAddr, source := SplitUpstreamEndpoint(endpoint)
+		f.logger.Debug("上游服务器 %s 使用出站源地址: %s", serverAddr, source)
+	}
+	for _, endpoint := range f.SourceResolver.SetEndpoints(endpoints) {
+		serverAddr, source := SplitUpstreamEndpoint(endpoint)
+		f.logger.Info("上游服务器 %s 不再使用出站源地址 %s，关闭相关连接", serverAddr, source)
+		if f.TCPConnectionPool != nil {
+			f.TCPConnectionPool.CloseServerConnections(endpoint)
+		}
+	}
+}
+
+// serverSourceAddress 获取服务器在指定转发组中的出站源地址，调用方不能持有f.mu
+func (f *DNSForwarder) serverSourceAddress(groupDomain, addr string) string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	group, ok := f.groups[groupDomain]
+	if !ok {
+		return ""
+	}
+	for _, priorityServers := range group.PriorityQueues {
+		for _, server := range priorityServers {
+			if server.GetAddress() == addr {
+				return server.SourceAddress
+			}
+		}
+	}
+	return ""
+}
+
 // AddForwardGroup 添加转发组
 func (f *DNSForwarder) AddForwardGroup(group *ForwardGroup) error {
 	f.mu.Lock()
@@ -321,6 +383,10 @@ func (f *DNSForwarder) LoadConfig() {
 
 	f.priorityTimeout = time.Duration(priorityTimeoutMs) * time.Millisecond
 	f.logger.Debug("加载配置 - 最终优先级超时时间: %v", f.priorityTimeout)
+
+	// 从配置读取0x20查询名大小写随机化开关
+	f.qnameCase0x20 = common.GetConfigBool("Security", "DNS_0X20_ENABLED", false)
+	f.logger.Debug("加载配置 - 0x20查询名大小写随机化: %v", f.qnameCase0x20)
 }
 
 // NewDNSForwarder 创建新的DNS转发器
@@ -330,8 +396,12 @@ func NewDNSForwarder(forwardAddr string) *DNSForwarder {
 	// 创建上下文，用于控制所有后台协程的生命周期
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// 先创建TCP连接池
+	// 先创建源地址解析器和TCP连接池
+	sourceResolver := NewSourceAddressResolver()
 	tcpPool := NewTCPConnectionPool(nil)
+	tcpPool.SetSourceResolver(sourceResolver)
+	prober := NewServerCapabilityProber(0, logger, tcpPool)
+	prober.SetSourceResolver(sourceResolver)
 
 	// 计算域名匹配缓存大小，与DNS响应缓存大小相同
 	// 从配置读取缓存大小（MB）
@@ -366,7 +436,9 @@ func NewDNSForwarder(forwardAddr string) *DNSForwarder {
 		// 初始化Cookie和TCP相关组件
 		AdaptiveCookieManager:  NewAdaptiveCookieManager(),
 		TCPConnectionPool:      tcpPool,
-		ServerCapabilityProber: NewServerCapabilityProber(0, logger, tcpPool),
+		ServerCapabilityProber: prober,
+		SourceResolver:         sourceResolver,
+		responseValidator:      NewDNSMessageValidator(logger),
 
 		// 初始化上下文和取消函数
 		ctx:    ctx,
@@ -82,8 +82,8 @@ func (f *DNSForwarder) CheckServerHealth(addr string, groupDomain string) bool {
 	query.SetQuestion(checkDomain, dns.TypeSOA)
 	query.RecursionDesired = true
 
-	// 执行查询，使用ExchangeWithCookie支持Cookie和动态协议选择
-	result, err := f.ExchangeWithCookie(addr, query)
+	// 执行查询，使用ExchangeWithCookie支持Cookie和动态协议选择，经由该转发组配置的源地址发出
+	result, err := f.ExchangeWithCookie(UpstreamEndpoint(addr, f.serverSourceAddress(groupDomain, addr)), query)
 
 	// 获取或创建统计信息
 	stats := f.getOrCreateServerStats(addr)
@@ -160,6 +160,7 @@ func (f *DNSForwarder) probeCircuitBrokenServers() {
 			if success {
 				f.logger.Info("主动探测 - 服务器 %s 恢复成功，重置熔断状态", addr)
 				ResetCircuitBreaker(s)
+				f.notifyServerUp(addr)
 			}
 		}(stats)
 	}
@@ -0,0 +1,200 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/heavy_hitters.go
+// 固定内存的热门项统计（Space-Saving）和基数估算（HyperLogLog）
+
+package sdns
+
+import (
+	"container/heap"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// hyperLogLogPrecision HyperLogLog寄存器索引位数，2^14个寄存器占用16KB，标准误差约0.81%
+const hyperLogLogPrecision = 14
+
+// sketchSeed 进程内统一的哈希种子
+var sketchSeed = maphash.MakeSeed()
+
+// HeavyHitter 热门项及其计数
+// Count可能高估，真实计数位于[Count-Error, Count]区间
+type HeavyHitter struct {
+	Key   string
+	Count int64
+	Error int64
+}
+
+// spaceSavingEntry Space-Saving计数器
+type spaceSavingEntry struct {
+	key   string
+	count int64
+	err   int64
+	index int // 在最小堆中的位置
+}
+
+// spaceSavingHeap 按计数排序的最小堆
+type spaceSavingHeap []*spaceSavingEntry
+
+func (h spaceSavingHeap) Len() int           { return len(h) }
+func (h spaceSavingHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h spaceSavingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *spaceSavingHeap) Push(x interface{}) {
+	e := x.(*spaceSavingEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *spaceSavingHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+// SpaceSaving 使用固定数量计数器统计热门项
+// 计数器已满时新项替换计数最小的项并继承其计数，保证真实计数超过N/capacity的项一定被保留
+// 非并发安全，由调用方加锁
+type SpaceSaving struct {
+	capacity int
+	entries  map[string]*spaceSavingEntry
+	heap     spaceSavingHeap
+}
+
+// NewSpaceSaving 创建指定计数器数量的热门项统计
+func NewSpaceSaving(capacity int) *SpaceSaving {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &SpaceSaving{
+		capacity: capacity,
+		entries:  make(map[string]*spaceSavingEntry, capacity),
+		heap:     make(spaceSavingHeap, 0, capacity),
+	}
+}
+
+// Add 记录一次出现
+func (s *SpaceSaving) Add(key string) {
+	if e, ok := s.entries[key]; ok {
+		e.count++
+		heap.Fix(&s.heap, e.index)
+		return
+	}
+
+	if len(s.heap) < s.capacity {
+		e := &spaceSavingEntry{key: key, count: 1}
+		s.entries[key] = e
+		heap.Push(&s.heap, e)
+		return
+	}
+
+	// 替换计数最小的项
+	min := s.heap[0]
+	delete(s.entries, min.key)
+	min.key = key
+	min.err = min.count
+	min.count++
+	s.entries[key] = min
+	heap.Fix(&s.heap, 0)
+}
+
+// Top 获取计数最高的n项，按计数降序排列，n<=0时返回全部
+func (s *SpaceSaving) Top(n int) []HeavyHitter {
+	result := make([]HeavyHitter, 0, len(s.heap))
+	for _, e := range s.heap {
+		result = append(result, HeavyHitter{Key: e.key, Count: e.count, Error: e.err})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Len 当前跟踪的项数
+func (s *SpaceSaving) Len() int {
+	return len(s.heap)
+}
+
+// Reset 清空所有计数器
+func (s *SpaceSaving) Reset() {
+	s.entries = make(map[string]*spaceSavingEntry, s.capacity)
+	s.heap = s.heap[:0]
+}
+
+// HyperLogLog 基数估算
+// 非并发安全，由调用方加锁
+type HyperLogLog struct {
+	registers [1 << hyperLogLogPrecision]uint8
+}
+
+// NewHyperLogLog 创建基数估算器
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{}
+}
+
+// Add 记录一个元素
+func (h *HyperLogLog) Add(key string) {
+	x := maphash.String(sketchSeed, key)
+	idx := x >> (64 - hyperLogLogPrecision)
+	// 剩余位的前导零个数+1，末尾补1防止全零
+	rho := uint8(bits.LeadingZeros64(x<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1)) + 1)
+	if rho > h.registers[idx] {
+		h.registers[idx] = rho
+	}
+}
+
+// Estimate 估算不同元素的数量
+func (h *HyperLogLog) Estimate() int64 {
+	const m = float64(1 << hyperLogLogPrecision)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	// 小基数时使用线性计数修正
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(estimate + 0.5)
+}
+
+// Reset 清空所有寄存器
+func (h *HyperLogLog) Reset() {
+	h.registers = [1 << hyperLogLogPrecision]uint8{}
+}
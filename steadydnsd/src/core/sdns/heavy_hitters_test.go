@@ -0,0 +1,129 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/heavy_hitters_test.go
+// 热门项统计、基数估算和窗口快照测试
+
+package sdns
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"SteadyDNS/core/common"
+)
+
+// TestSpaceSaving 测试随机子域名洪泛下热门项仍被保留且内存固定
+func TestSpaceSaving(t *testing.T) {
+	s := NewSpaceSaving(100)
+	for i := 0; i < 20000; i++ {
+		s.Add(fmt.Sprintf("%d.flood.example.", i))
+		if i%10 == 0 {
+			s.Add("hot.example.")
+		}
+		if i%20 == 0 {
+			s.Add("warm.example.")
+		}
+	}
+
+	if s.Len() != 100 {
+		t.Errorf("Len() = %d, want 100", s.Len())
+	}
+	top := s.Top(2)
+	if len(top) != 2 || top[0].Key != "hot.example." || top[1].Key != "warm.example." {
+		t.Fatalf("Top(2) = %+v", top)
+	}
+	// 真实计数位于[Count-Error, Count]区间
+	if top[0].Count < 2000 || top[0].Count-top[0].Error > 2000 {
+		t.Errorf("hot计数 = %d, error = %d，不包含真实值2000", top[0].Count, top[0].Error)
+	}
+
+	s.Reset()
+	if s.Len() != 0 || len(s.Top(10)) != 0 {
+		t.Errorf("Reset()后仍有计数")
+	}
+}
+
+// TestHyperLogLog 测试基数估算误差
+func TestHyperLogLog(t *testing.T) {
+	for _, n := range []int{0, 100, 10000, 200000} {
+		h := NewHyperLogLog()
+		for i := 0; i < n; i++ {
+			key := fmt.Sprintf("client-%d", i)
+			h.Add(key)
+			h.Add(key)
+		}
+		got := h.Estimate()
+		if n == 0 {
+			if got != 0 {
+				t.Errorf("空集合估算 = %d", got)
+			}
+			continue
+		}
+		if errRate := math.Abs(float64(got)-float64(n)) / float64(n); errRate > 0.03 {
+			t.Errorf("n = %d，估算 = %d，误差 %.2f%%", n, got, errRate*100)
+		}
+	}
+}
+
+// TestTopNSnapshot 测试窗口结束时生成快照并开始新窗口
+func TestTopNSnapshot(t *testing.T) {
+	sm := NewStatsManager(common.NewLogger())
+	start := sm.topNWindow.start
+
+	for i := 0; i < 30; i++ {
+		sm.RecordQuery(fmt.Sprintf("d%d.example.", i%25), "10.0.0.1", QueryDimensions{}, time.Millisecond)
+	}
+	sm.RecordQuery("d0.example.", "10.0.0.2", QueryDimensions{}, time.Millisecond)
+
+	if records, unique := sm.takeTopNSnapshot(start.Add(time.Minute)); records != nil || unique != nil {
+		t.Fatalf("窗口未结束时不应生成快照")
+	}
+
+	records, unique := sm.takeTopNSnapshot(start.Add(topNWindowDuration))
+	if unique == nil {
+		t.Fatalf("窗口结束时应生成快照")
+	}
+	if unique.Queries != 31 || unique.UniqueDomains != 25 || unique.UniqueClients != 2 {
+		t.Errorf("去重计数快照 = %+v", unique)
+	}
+	domains, clients := 0, 0
+	for _, r := range records {
+		switch r.Kind {
+		case TopNKindDomain:
+			domains++
+			if r.Rank == 1 && (r.Name != "d0.example." || r.Count != 3) {
+				t.Errorf("热门域名第1名 = %+v", r)
+			}
+		case TopNKindClient:
+			clients++
+		}
+	}
+	if domains != TopNSnapshotSize || clients != 2 {
+		t.Errorf("快照记录数 domain=%d client=%d", domains, clients)
+	}
+
+	// 新窗口从头计数，累计的热门项不受影响
+	if sm.topNWindow.queries != 0 {
+		t.Errorf("新窗口查询数 = %d", sm.topNWindow.queries)
+	}
+	if top := sm.GetTopDomains(1); len(top) != 1 || top[0].Queries != 3 {
+		t.Errorf("GetTopDomains(1) = %+v", top)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/latency_histogram.go
+// HDR风格的对数-线性延迟直方图，固定内存且可合并
+
+package sdns
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// latencySubBucketBits 每个2的幂区间内的线性子桶位数，32个子桶时相对误差不超过1/64
+	latencySubBucketBits  = 5
+	latencySubBucketCount = 1 << latencySubBucketBits
+	// latencyMaxMicros 可记录的最大延迟（约71分钟），超出的值按最大值记录
+	latencyMaxMicros = 1<<32 - 1
+	// latencyBucketCount 桶数量：[0, 64)微秒逐一计数，之后每个2的幂区间32个桶
+	latencyBucketCount = 2*latencySubBucketCount + (32-latencySubBucketBits-1)*latencySubBucketCount
+)
+
+// LatencyHistogram 延迟直方图，以微秒为单位记录
+// 非并发安全，由调用方加锁
+type LatencyHistogram struct {
+	counts [latencyBucketCount]uint64
+	total  uint64
+	sum    uint64 // 延迟总和（微秒）
+	max    uint64 // 最大延迟（微秒）
+}
+
+// NewLatencyHistogram 创建延迟直方图
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{}
+}
+
+// latencyBucketIndex 计算微秒值所在的桶
+func latencyBucketIndex(us uint64) int {
+	if us > latencyMaxMicros {
+		us = latencyMaxMicros
+	}
+	if us < 2*latencySubBucketCount {
+		return int(us)
+	}
+	exp := bits.Len64(us) - (latencySubBucketBits + 1)
+	return 2*latencySubBucketCount + (exp-1)*latencySubBucketCount + int(us>>exp) - latencySubBucketCount
+}
+
+// latencyBucketBounds 获取桶的下界和宽度（微秒）
+func latencyBucketBounds(index int) (lower, width uint64) {
+	if index < 2*latencySubBucketCount {
+		return uint64(index), 1
+	}
+	offset := index - 2*latencySubBucketCount
+	exp := offset/latencySubBucketCount + 1
+	sub := offset%latencySubBucketCount + latencySubBucketCount
+	return uint64(sub) << exp, 1 << exp
+}
+
+// Record 记录一次延迟
+func (h *LatencyHistogram) Record(d time.Duration) {
+	us := uint64(0)
+	if d > 0 {
+		us = uint64(d / time.Microsecond)
+	}
+	if us > latencyMaxMicros {
+		us = latencyMaxMicros
+	}
+	h.counts[latencyBucketIndex(us)]++
+	h.total++
+	h.sum += us
+	if us > h.max {
+		h.max = us
+	}
+}
+
+// Merge 合并另一个直方图
+func (h *LatencyHistogram) Merge(other *LatencyHistogram) {
+	if other == nil || other.total == 0 {
+		return
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	h.total += other.total
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// Count 记录的延迟数量
+func (h *LatencyHistogram) Count() int64 {
+	return int64(h.total)
+}
+
+// Quantile 获取分位延迟（毫秒），q取值0-1
+// 返回所在桶的中点，且不超过记录的最大值
+func (h *LatencyHistogram) Quantile(q float64) float64 {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.total)))
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen < rank {
+			continue
+		}
+		lower, width := latencyBucketBounds(i)
+		value := lower + width/2
+		if value > h.max {
+			value = h.max
+		}
+		return float64(value) / 1000
+	}
+	return float64(h.max) / 1000
+}
+
+// Mean 平均延迟（毫秒）
+func (h *LatencyHistogram) Mean() float64 {
+	if h.total == 0 {
+		return 0
+	}
+	return float64(h.sum) / float64(h.total) / 1000
+}
+
+// Max 最大延迟（毫秒）
+func (h *LatencyHistogram) Max() float64 {
+	return float64(h.max) / 1000
+}
+
+// CountBelow 统计延迟小于d的记录数，按桶下界判断
+func (h *LatencyHistogram) CountBelow(d time.Duration) int64 {
+	limit := uint64(d / time.Microsecond)
+	var n uint64
+	for i, c := range h.counts {
+		if lower, _ := latencyBucketBounds(i); lower >= limit {
+			break
+		}
+		n += c
+	}
+	return int64(n)
+}
+
+// Reset 清空直方图
+func (h *LatencyHistogram) Reset() {
+	*h = LatencyHistogram{}
+}
+
+// EncodeBuckets 将非零桶编码为(桶序号增量, 计数)的varint序列
+func (h *LatencyHistogram) EncodeBuckets() []byte {
+	var buf []byte
+	last := 0
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		buf = binary.AppendUvarint(buf, uint64(i-last))
+		buf = binary.AppendUvarint(buf, c)
+		last = i
+	}
+	return buf
+}
+
+// DecodeLatencyHistogram 从桶编码和汇总值还原直方图
+// 参数：
+//   - buckets: EncodeBuckets的输出
+//   - sum: 延迟总和（微秒）
+//   - max: 最大延迟（微秒）
+func DecodeLatencyHistogram(buckets []byte, sum, max uint64) (*LatencyHistogram, error) {
+	h := &LatencyHistogram{sum: sum, max: max}
+	index := 0
+	for len(buckets) > 0 {
+		delta, n := binary.Uvarint(buckets)
+		if n <= 0 {
+			return nil, fmt.Errorf("延迟直方图桶序号解码失败")
+		}
+		buckets = buckets[n:]
+		count, n := binary.Uvarint(buckets)
+		if n <= 0 {
+			return nil, fmt.Errorf("延迟直方图桶计数解码失败")
+		}
+		buckets = buckets[n:]
+
+		index += int(delta)
+		if index >= latencyBucketCount {
+			return nil, fmt.Errorf("延迟直方图桶序号越界: %d", index)
+		}
+		h.counts[index] += count
+		h.total += count
+	}
+	return h, nil
+}
@@ -0,0 +1,135 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/latency_histogram_test.go
+// 延迟直方图精度、合并和编码测试
+
+package sdns
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"SteadyDNS/core/common"
+)
+
+// TestLatencyBucketIndex 测试桶序号与桶边界互逆且覆盖最大值
+func TestLatencyBucketIndex(t *testing.T) {
+	if got := latencyBucketIndex(math.MaxUint64); got != latencyBucketCount-1 {
+		t.Fatalf("最大值桶序号 = %d, want %d", got, latencyBucketCount-1)
+	}
+	for _, us := range []uint64{0, 1, 63, 64, 65, 127, 128, 1000, 12345, 1 << 20, latencyMaxMicros} {
+		i := latencyBucketIndex(us)
+		lower, width := latencyBucketBounds(i)
+		if us < lower || us >= lower+width {
+			t.Errorf("值 %d 落在桶 %d [%d, %d) 之外", us, i, lower, lower+width)
+		}
+	}
+}
+
+// TestLatencyHistogramQuantile 测试百分位误差
+func TestLatencyHistogramQuantile(t *testing.T) {
+	h := NewLatencyHistogram()
+	// 1ms到1000ms均匀分布
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * 100 * time.Microsecond)
+	}
+
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0.50, 500},
+		{0.90, 900},
+		{0.99, 990},
+		{0.999, 999},
+	}
+	for _, tt := range tests {
+		got := h.Quantile(tt.q)
+		if math.Abs(got-tt.want)/tt.want > 0.02 {
+			t.Errorf("Quantile(%v) = %.2f, want ≈%.0f", tt.q, got, tt.want)
+		}
+	}
+	if h.Max() != 1000 {
+		t.Errorf("Max() = %v", h.Max())
+	}
+	if math.Abs(h.Mean()-500.05) > 0.01 {
+		t.Errorf("Mean() = %v", h.Mean())
+	}
+	if below := h.CountBelow(100 * time.Millisecond); below < 980 || below > 1020 {
+		t.Errorf("CountBelow(100ms) = %d", below)
+	}
+}
+
+// TestLatencyHistogramMergeEncode 测试合并和编码还原
+func TestLatencyHistogramMergeEncode(t *testing.T) {
+	a, b := NewLatencyHistogram(), NewLatencyHistogram()
+	for i := 0; i < 990; i++ {
+		a.Record(2 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		b.Record(300 * time.Millisecond)
+	}
+	a.Merge(b)
+	if a.Count() != 1000 {
+		t.Fatalf("合并后Count() = %d", a.Count())
+	}
+	if p := a.Quantile(0.999); math.Abs(p-300)/300 > 0.02 {
+		t.Errorf("合并后p999 = %.2f", p)
+	}
+
+	decoded, err := DecodeLatencyHistogram(a.EncodeBuckets(), a.sum, a.max)
+	if err != nil {
+		t.Fatalf("DecodeLatencyHistogram() error = %v", err)
+	}
+	if decoded.counts != a.counts || decoded.total != a.total || decoded.Mean() != a.Mean() {
+		t.Errorf("编码还原后的直方图与原直方图不一致")
+	}
+	if _, err := DecodeLatencyHistogram([]byte{0xff}, 0, 0); err == nil {
+		t.Errorf("截断的编码应返回错误")
+	}
+}
+
+// TestLatencyHistogramsByMinute 测试按缓存结果和上游记录分钟直方图
+func TestLatencyHistogramsByMinute(t *testing.T) {
+	sm := NewStatsManager(common.NewLogger())
+	sm.RecordQuery("a.example.", "10.0.0.1", QueryDimensions{Cache: CacheOutcomeHit}, time.Millisecond)
+	sm.RecordQuery("b.example.", "10.0.0.1", QueryDimensions{Cache: CacheOutcomeMiss}, 40*time.Millisecond)
+	sm.RecordUpstreamLatency("8.8.8.8:53", 35*time.Millisecond)
+
+	now := time.Now()
+	if n := len(sm.pendingLatencyHistograms(LatencyCategoryCache, now.Add(-time.Hour))); n != 2 {
+		t.Errorf("缓存分类直方图数 = %d, want 2", n)
+	}
+	upstream := sm.pendingLatencyHistograms(LatencyCategoryUpstream, now.Add(-time.Hour))
+	if len(upstream) != 1 || upstream[0].Label != "8.8.8.8:53" || upstream[0].Count != 1 {
+		t.Errorf("上游分类直方图 = %+v", upstream)
+	}
+
+	dist := sm.GetLatencyDistribution()
+	if dist["<10ms"] != 50 || dist["20-50ms"] != 50 {
+		t.Errorf("GetLatencyDistribution() = %v", dist)
+	}
+
+	if records := sm.takeCompletedLatencyHistograms(now.Add(time.Minute)); len(records) != 3 {
+		t.Errorf("下一分钟取出的直方图数 = %d, want 3", len(records))
+	}
+	if len(sm.latencyHists) != 0 {
+		t.Errorf("取出后内存中仍有 %d 个直方图", len(sm.latencyHists))
+	}
+}
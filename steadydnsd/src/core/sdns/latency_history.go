@@ -0,0 +1,317 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/latency_history.go
+
+package sdns
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"SteadyDNS/core/database"
+)
+
+// 延迟直方图分类
+const (
+	LatencyCategoryCache    = "cache"    // 按缓存结果区分的端到端查询延迟
+	LatencyCategoryUpstream = "upstream" // 按上游服务器区分的转发延迟
+)
+
+// recentLatencyMinutes 延迟分布统计使用的最近分钟数
+const recentLatencyMinutes = 5
+
+// latencyHistogramKey 分钟延迟直方图的键
+type latencyHistogramKey struct {
+	minute   int64 // 分钟起始时间（Unix秒）
+	category string
+	label    string
+}
+
+// recentLatencySlot 最近延迟环形缓冲区中的一分钟
+type recentLatencySlot struct {
+	minute int64
+	hist   LatencyHistogram
+}
+
+// LatencyPercentiles 延迟百分位汇总（毫秒）
+type LatencyPercentiles struct {
+	Count int64   `json:"count"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	P999  float64 `json:"p999"`
+	Max   float64 `json:"max"`
+}
+
+// LatencySeries 单个标签的延迟趋势
+// 每个切片长度与LatencyTrend.TimeLabels一致
+type LatencySeries struct {
+	Label   string             `json:"label"`
+	Summary LatencyPercentiles `json:"summary"`
+	Counts  []int64            `json:"counts"`
+	P50     []float64          `json:"p50"`
+	P90     []float64          `json:"p90"`
+	P99     []float64          `json:"p99"`
+	P999    []float64          `json:"p999"`
+}
+
+// LatencyTrend 按时间段合并直方图得到的延迟趋势
+type LatencyTrend struct {
+	Category   string          `json:"category"`
+	TimeRange  string          `json:"timeRange"`
+	TimeLabels []string        `json:"timeLabels"`
+	Overall    LatencySeries   `json:"overall"`
+	Series     []LatencySeries `json:"series"`
+}
+
+// latencyPercentiles 从直方图计算百分位汇总
+func latencyPercentiles(h *LatencyHistogram) LatencyPercentiles {
+	return LatencyPercentiles{
+		Count: h.Count(),
+		Avg:   round2(h.Mean()),
+		P50:   round2(h.Quantile(0.50)),
+		P90:   round2(h.Quantile(0.90)),
+		P99:   round2(h.Quantile(0.99)),
+		P999:  round2(h.Quantile(0.999)),
+		Max:   round2(h.Max()),
+	}
+}
+
+// recordLatencyLocked 记录一次延迟到当前分钟的直方图，调用方需持有sm.mutex写锁
+func (sm *StatsManager) recordLatencyLocked(category, label string, d time.Duration, now time.Time) {
+	key := latencyHistogramKey{minute: now.Truncate(time.Minute).Unix(), category: category, label: label}
+	h, ok := sm.latencyHists[key]
+	if !ok {
+		h = NewLatencyHistogram()
+		sm.latencyHists[key] = h
+	}
+	h.Record(d)
+}
+
+// recordRecentLatencyLocked 记录一次端到端延迟到最近延迟环形缓冲区，调用方需持有sm.mutex写锁
+func (sm *StatsManager) recordRecentLatencyLocked(d time.Duration, now time.Time) {
+	minute := now.Truncate(time.Minute).Unix()
+	slot := &sm.recentLatency[(minute/60)%recentLatencyMinutes]
+	if slot.minute != minute {
+		slot.minute = minute
+		slot.hist.Reset()
+	}
+	slot.hist.Record(d)
+}
+
+// RecordUpstreamLatency 记录一次上游服务器成功应答的延迟
+func (sm *StatsManager) RecordUpstreamLatency(address string, d time.Duration) {
+	sm.mutex.Lock()
+	sm.recordLatencyLocked(LatencyCategoryUpstream, address, d, time.Now())
+	sm.mutex.Unlock()
+}
+
+// recentLatencyHistogram 合并最近几分钟的端到端延迟直方图
+func (sm *StatsManager) recentLatencyHistogram(now time.Time) *LatencyHistogram {
+	oldest := now.Truncate(time.Minute).Add(-(recentLatencyMinutes - 1) * time.Minute).Unix()
+
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	merged := NewLatencyHistogram()
+	for i := range sm.recentLatency {
+		if sm.recentLatency[i].minute >= oldest {
+			merged.Merge(&sm.recentLatency[i].hist)
+		}
+	}
+	return merged
+}
+
+// latencyHistogramRecord 将直方图转换为数据库记录
+func latencyHistogramRecord(key latencyHistogramKey, h *LatencyHistogram) database.LatencyHistogramHistory {
+	return database.LatencyHistogramHistory{
+		Timestamp: time.Unix(key.minute, 0),
+		Category:  key.category,
+		Label:     key.label,
+		Count:     h.Count(),
+		Sum:       int64(h.sum),
+		Max:       int64(h.max),
+		Buckets:   h.EncodeBuckets(),
+	}
+}
+
+// takeCompletedLatencyHistograms 取出已结束分钟的延迟直方图并从内存中移除
+func (sm *StatsManager) takeCompletedLatencyHistograms(now time.Time) []database.LatencyHistogramHistory {
+	current := now.Truncate(time.Minute).Unix()
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	var records []database.LatencyHistogramHistory
+	for key, h := range sm.latencyHists {
+		if key.minute >= current {
+			continue
+		}
+		records = append(records, latencyHistogramRecord(key, h))
+		delete(sm.latencyHists, key)
+	}
+	return records
+}
+
+// pendingLatencyHistograms 获取尚未持久化的指定分类直方图
+func (sm *StatsManager) pendingLatencyHistograms(category string, startTime time.Time) []database.LatencyHistogramHistory {
+	start := startTime.Unix()
+
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	var records []database.LatencyHistogramHistory
+	for key, h := range sm.latencyHists {
+		if key.category != category || key.minute < start {
+			continue
+		}
+		records = append(records, latencyHistogramRecord(key, h))
+	}
+	return records
+}
+
+// persistLatencyHistograms 持久化已结束分钟的延迟直方图
+// 写入失败时丢弃该批数据，避免内存中的直方图无限增长
+func (sm *StatsManager) persistLatencyHistograms() {
+	records := sm.takeCompletedLatencyHistograms(time.Now())
+	if len(records) == 0 {
+		return
+	}
+
+	if err := database.SaveLatencyHistogramBatch(records); err != nil {
+		sm.logger.Error("持久化延迟直方图历史数据失败: %v", err)
+		return
+	}
+	sm.logger.Debug("持久化了 %d 条延迟直方图历史记录", len(records))
+}
+
+// GetLatencyTrend 获取时间范围内的延迟百分位趋势
+// 每个时间段内的分钟直方图先合并再计算百分位，避免对百分位取平均带来的误差
+// 参数：
+//   - category: 分类（cache, upstream）
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//   - points: 数据点数量，<=0时默认12
+//
+// 返回：
+//   - *LatencyTrend: 整体及各标签的延迟趋势，标签按记录数降序排列
+//   - error: 分类无效或查询失败时返回错误
+func (sm *StatsManager) GetLatencyTrend(category, timeRange string, points int) (*LatencyTrend, error) {
+	if category != LatencyCategoryCache && category != LatencyCategoryUpstream {
+		return nil, fmt.Errorf("无效的延迟分类: %s", category)
+	}
+
+	now := time.Now()
+	totalDuration := timeRangeDuration(timeRange)
+	startTime := now.Add(-totalDuration)
+
+	records, err := database.GetLatencyHistogramHistoryByTimeRange(category, startTime, now)
+	if err != nil {
+		return nil, err
+	}
+	// 合并当前分钟等尚未持久化的直方图
+	records = append(records, sm.pendingLatencyHistograms(category, startTime)...)
+
+	if points <= 0 {
+		points = 12
+	}
+	interval := totalDuration / time.Duration(points)
+
+	// 按标签和时间段合并，空标签表示整体
+	slots := make(map[string][]*LatencyHistogram)
+	totals := make(map[string]*LatencyHistogram)
+	addTo := func(label string, slot int, h *LatencyHistogram) {
+		if _, ok := slots[label]; !ok {
+			slots[label] = make([]*LatencyHistogram, points)
+			totals[label] = NewLatencyHistogram()
+		}
+		if slots[label][slot] == nil {
+			slots[label][slot] = NewLatencyHistogram()
+		}
+		slots[label][slot].Merge(h)
+		totals[label].Merge(h)
+	}
+	for _, r := range records {
+		h, err := DecodeLatencyHistogram(r.Buckets, uint64(r.Sum), uint64(r.Max))
+		if err != nil {
+			sm.logger.Warn("跳过无法解析的延迟直方图记录 %d: %v", r.ID, err)
+			continue
+		}
+		slot := int(r.Timestamp.Sub(startTime) / interval)
+		if slot < 0 {
+			slot = 0
+		} else if slot >= points {
+			slot = points - 1
+		}
+		addTo("", slot, h)
+		addTo(r.Label, slot, h)
+	}
+
+	trend := &LatencyTrend{
+		Category:   category,
+		TimeRange:  timeRange,
+		TimeLabels: make([]string, 0, points),
+		Series:     make([]LatencySeries, 0, len(slots)),
+	}
+	timeFormat := timeRangeLabelFormat(timeRange)
+	for i := 0; i < points; i++ {
+		trend.TimeLabels = append(trend.TimeLabels, startTime.Add(time.Duration(i)*interval).Format(timeFormat))
+	}
+
+	buildSeries := func(label string) LatencySeries {
+		series := LatencySeries{
+			Label:  label,
+			Counts: make([]int64, points),
+			P50:    make([]float64, points),
+			P90:    make([]float64, points),
+			P99:    make([]float64, points),
+			P999:   make([]float64, points),
+		}
+		if total, ok := totals[label]; ok {
+			series.Summary = latencyPercentiles(total)
+		}
+		for i, h := range slots[label] {
+			if h == nil {
+				continue
+			}
+			p := latencyPercentiles(h)
+			series.Counts[i] = p.Count
+			series.P50[i] = p.P50
+			series.P90[i] = p.P90
+			series.P99[i] = p.P99
+			series.P999[i] = p.P999
+		}
+		return series
+	}
+
+	trend.Overall = buildSeries("")
+	for label := range slots {
+		if label != "" {
+			trend.Series = append(trend.Series, buildSeries(label))
+		}
+	}
+	sort.Slice(trend.Series, func(i, j int) bool {
+		if trend.Series[i].Summary.Count != trend.Series[j].Summary.Count {
+			return trend.Series[i].Summary.Count > trend.Series[j].Summary.Count
+		}
+		return trend.Series[i].Label < trend.Series[j].Label
+	})
+
+	return trend, nil
+}
@@ -22,6 +22,7 @@ package sdns
 import (
 	"SteadyDNS/core/common"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
@@ -404,6 +405,18 @@ func (c *MemoryCache) Set(msg *dns.Msg) error {
 		} else if entryUsagePercent > 0.8 {
 			cleanupPercentage = 0.3 // 如果使用超过80%，清理30%
 		}
+		GetEventManager().EmitWithCooldown(&Event{
+			Type:     EventCachePressure,
+			Severity: SeverityWarning,
+			Source:   "cache",
+			Subject:  "dns_cache",
+			Message:  fmt.Sprintf("DNS缓存条目使用率达到 %.1f%%，超过清理阈值 %.0f%%", entryUsagePercent*100, c.cleanupThreshold*100),
+			Details: map[string]interface{}{
+				"entries":           entryCount,
+				"maxEntries":        c.maxBlocks,
+				"cleanupPercentage": cleanupPercentage,
+			},
+		})
 		c.cleanupByPercentage(cleanupPercentage)
 	}
 
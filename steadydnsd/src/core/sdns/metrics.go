@@ -0,0 +1,484 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/metrics.go
+// Prometheus指标：查询计数和延迟直方图由查询日志订阅累计，其余指标在抓取时从各子系统读取
+
+package sdns
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queryLatencyBuckets 查询延迟直方图的桶上界（秒）
+var queryLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// GlobalQueryMetrics 全局查询指标
+var GlobalQueryMetrics = NewQueryMetrics()
+
+// queryMetricKey 查询计数标签
+type queryMetricKey struct {
+	qtype    string
+	rcode    string
+	protocol string
+}
+
+// latencyMetricKey 延迟直方图标签
+type latencyMetricKey struct {
+	protocol string
+	cache    string
+}
+
+// latencyHistogram 延迟直方图，各桶独立计数，输出时累加
+type latencyHistogram struct {
+	buckets   []int64
+	count     int64
+	sumMicros int64
+}
+
+// observe 记录一次延迟
+func (h *latencyHistogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, upper := range queryLatencyBuckets {
+		if seconds <= upper {
+			atomic.AddInt64(&h.buckets[i], 1)
+			break
+		}
+	}
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sumMicros, d.Microseconds())
+}
+
+// QueryMetrics 查询指标
+// 作为QueryLogSink在EndQuery中累计，标签组合首次出现时加写锁创建，之后只读锁加原子计数
+type QueryMetrics struct {
+	mu      sync.RWMutex
+	queries map[queryMetricKey]*int64
+	latency map[latencyMetricKey]*latencyHistogram
+}
+
+// NewQueryMetrics 创建查询指标
+func NewQueryMetrics() *QueryMetrics {
+	return &QueryMetrics{
+		queries: make(map[queryMetricKey]*int64),
+		latency: make(map[latencyMetricKey]*latencyHistogram),
+	}
+}
+
+// metricLabel 规范化标签值，空值使用默认值
+func metricLabel(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// ConsumeQueryLog 实现QueryLogSink，累计查询计数和延迟
+func (m *QueryMetrics) ConsumeQueryLog(buf *QueryLogBuffer, clientIP string) {
+	protocol := metricLabel(buf.Protocol, "unknown")
+	qkey := queryMetricKey{
+		qtype:    metricLabel(strings.ToUpper(buf.QueryType), "UNKNOWN"),
+		rcode:    rcodeName(buf.ResponseCode),
+		protocol: protocol,
+	}
+	lkey := latencyMetricKey{
+		protocol: protocol,
+		cache:    metricLabel(buf.CacheStatus, "none"),
+	}
+
+	m.mu.RLock()
+	counter := m.queries[qkey]
+	hist := m.latency[lkey]
+	m.mu.RUnlock()
+
+	if counter == nil || hist == nil {
+		m.mu.Lock()
+		if counter = m.queries[qkey]; counter == nil {
+			counter = new(int64)
+			m.queries[qkey] = counter
+		}
+		if hist = m.latency[lkey]; hist == nil {
+			hist = &latencyHistogram{buckets: make([]int64, len(queryLatencyBuckets))}
+			m.latency[lkey] = hist
+		}
+		m.mu.Unlock()
+	}
+
+	atomic.AddInt64(counter, 1)
+	hist.observe(time.Since(buf.StartTime))
+}
+
+// metricsWriter Prometheus文本格式输出
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+// header 输出指标的HELP和TYPE行
+func (mw *metricsWriter) header(name, typ, help string) {
+	mw.w.WriteString("# HELP " + name + " " + help + "\n")
+	mw.w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+// sample 输出一个样本，labels为成对的标签名和标签值
+func (mw *metricsWriter) sample(name string, value float64, labels ...string) {
+	mw.w.WriteString(name)
+	if len(labels) > 0 {
+		mw.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				mw.w.WriteByte(',')
+			}
+			mw.w.WriteString(labels[i])
+			mw.w.WriteString(`="`)
+			mw.w.WriteString(escapeLabelValue(labels[i+1]))
+			mw.w.WriteByte('"')
+		}
+		mw.w.WriteByte('}')
+	}
+	mw.w.WriteByte(' ')
+	mw.w.WriteString(formatMetricValue(value))
+	mw.w.WriteByte('\n')
+}
+
+// single 输出只有一个样本的指标
+func (mw *metricsWriter) single(name, typ, help string, value float64) {
+	mw.header(name, typ, help)
+	mw.sample(name, value)
+}
+
+// escapeLabelValue 转义标签值中的反斜杠、双引号和换行
+func escapeLabelValue(v string) string {
+	if !strings.ContainsAny(v, "\\\"\n") {
+		return v
+	}
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatMetricValue 格式化样本值
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// boolMetric 将布尔值转换为0/1
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// numberMetric 将统计map中的数值转换为float64
+func numberMetric(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// WritePrometheusMetrics 以Prometheus文本格式输出所有子系统的指标
+func WritePrometheusMetrics(out io.Writer) error {
+	mw := &metricsWriter{w: bufio.NewWriter(out)}
+
+	writeQueryMetrics(mw, GlobalQueryMetrics)
+	writeServerMetrics(mw)
+	writeCacheMetrics(mw)
+	writeUpstreamMetrics(mw)
+	writeSecurityMetrics(mw)
+	writeLoggingMetrics(mw)
+
+	return mw.w.Flush()
+}
+
+// writeQueryMetrics 输出查询计数和延迟直方图
+func writeQueryMetrics(mw *metricsWriter, m *QueryMetrics) {
+	m.mu.RLock()
+	qkeys := make([]queryMetricKey, 0, len(m.queries))
+	for k := range m.queries {
+		qkeys = append(qkeys, k)
+	}
+	lkeys := make([]latencyMetricKey, 0, len(m.latency))
+	for k := range m.latency {
+		lkeys = append(lkeys, k)
+	}
+	m.mu.RUnlock()
+
+	// 排序保证输出稳定
+	sort.Slice(qkeys, func(i, j int) bool {
+		a, b := qkeys[i], qkeys[j]
+		if a.qtype != b.qtype {
+			return a.qtype < b.qtype
+		}
+		if a.rcode != b.rcode {
+			return a.rcode < b.rcode
+		}
+		return a.protocol < b.protocol
+	})
+	sort.Slice(lkeys, func(i, j int) bool {
+		if lkeys[i].protocol != lkeys[j].protocol {
+			return lkeys[i].protocol < lkeys[j].protocol
+		}
+		return lkeys[i].cache < lkeys[j].cache
+	})
+
+	mw.header("steadydns_queries_total", "counter", "DNS queries answered, by query type, response code and transport.")
+	for _, k := range qkeys {
+		m.mu.RLock()
+		counter := m.queries[k]
+		m.mu.RUnlock()
+		mw.sample("steadydns_queries_total", float64(atomic.LoadInt64(counter)),
+			"qtype", k.qtype, "rcode", k.rcode, "protocol", k.protocol)
+	}
+
+	mw.header("steadydns_query_duration_seconds", "histogram", "End-to-end DNS query latency, by transport and cache outcome.")
+	for _, k := range lkeys {
+		m.mu.RLock()
+		hist := m.latency[k]
+		m.mu.RUnlock()
+
+		var cumulative int64
+		for i, upper := range queryLatencyBuckets {
+			cumulative += atomic.LoadInt64(&hist.buckets[i])
+			mw.sample("steadydns_query_duration_seconds_bucket", float64(cumulative),
+				"protocol", k.protocol, "cache", k.cache, "le", formatMetricValue(upper))
+		}
+		count := atomic.LoadInt64(&hist.count)
+		mw.sample("steadydns_query_duration_seconds_bucket", float64(count),
+			"protocol", k.protocol, "cache", k.cache, "le", "+Inf")
+		mw.sample("steadydns_query_duration_seconds_sum", float64(atomic.LoadInt64(&hist.sumMicros))/1e6,
+			"protocol", k.protocol, "cache", k.cache)
+		mw.sample("steadydns_query_duration_seconds_count", float64(count),
+			"protocol", k.protocol, "cache", k.cache)
+	}
+}
+
+// writeServerMetrics 输出DNS服务器流量和协程池指标
+func writeServerMetrics(mw *metricsWriter) {
+	servers := []*CustomDNSServer{GlobalUDPServer, GlobalTCPServer}
+
+	mw.header("steadydns_network_requests_total", "counter", "DNS messages received, by transport.")
+	for _, s := range servers {
+		if s != nil {
+			stats := s.GetStats()
+			mw.sample("steadydns_network_requests_total", float64(stats.TotalRequests), "protocol", s.net)
+		}
+	}
+	mw.header("steadydns_network_bytes_total", "counter", "DNS traffic in bytes, by transport and direction.")
+	for _, s := range servers {
+		if s != nil {
+			stats := s.GetStats()
+			mw.sample("steadydns_network_bytes_total", float64(stats.TotalBytesIn), "protocol", s.net, "direction", "in")
+			mw.sample("steadydns_network_bytes_total", float64(stats.TotalBytesOut), "protocol", s.net, "direction", "out")
+		}
+	}
+
+	if GlobalUDPServer != nil {
+		mw.single("steadydns_udp_pending_packets", "gauge", "UDP packets received but not yet answered.",
+			float64(GlobalUDPServer.GetPendingPackets()))
+
+		// UDP和TCP服务器共享同一个协程池
+		if pool := GlobalUDPServer.GetWorkerPool(); pool != nil {
+			stats := pool.GetStats()
+			mw.single("steadydns_worker_pool_queue_length", "gauge", "Tasks waiting in the client worker pool queue.", float64(stats.QueueLength))
+			mw.single("steadydns_worker_pool_active_workers", "gauge", "Client worker pool workers currently busy.", float64(stats.ActiveWorkers))
+			mw.single("steadydns_worker_pool_tasks_total", "counter", "Tasks submitted to the client worker pool.", float64(stats.TotalTasks))
+			mw.single("steadydns_worker_pool_failed_tasks_total", "counter", "Client worker pool tasks that failed or were rejected.", float64(stats.FailedTasks))
+		}
+	}
+	if GlobalDNSForwarder != nil && GlobalDNSForwarder.forwardPool != nil {
+		mw.single("steadydns_forward_pool_queue_length", "gauge", "Forwarding tasks waiting in the forwarder worker pool queue.",
+			float64(GlobalDNSForwarder.forwardPool.QueueLength()))
+	}
+}
+
+// writeCacheMetrics 输出缓存指标
+func writeCacheMetrics(mw *metricsWriter) {
+	if GlobalCacheUpdater == nil || GlobalCacheUpdater.cache == nil {
+		return
+	}
+	stats := GlobalCacheUpdater.cache.Stats()
+
+	mw.single("steadydns_cache_hits_total", "counter", "Cache lookups that found an entry.", numberMetric(stats["hitCount"]))
+	mw.single("steadydns_cache_misses_total", "counter", "Cache lookups that found no entry.", numberMetric(stats["missCount"]))
+	mw.single("steadydns_cache_evictions_total", "counter", "Cache entries evicted to free space.", numberMetric(stats["evictionCount"]))
+	mw.single("steadydns_cache_entries", "gauge", "Entries currently in the cache.", numberMetric(stats["count"]))
+	mw.single("steadydns_cache_size_bytes", "gauge", "Cache memory in use.", numberMetric(stats["currentSize"]))
+	mw.single("steadydns_cache_max_size_bytes", "gauge", "Configured cache memory limit.", numberMetric(stats["maxSize"]))
+}
+
+// upstreamSample 单个上游服务器的指标快照
+type upstreamSample struct {
+	addr        string
+	queries     int64
+	successes   int64
+	failures    int64
+	score       float64
+	latencyMs   float64
+	circuitOpen bool
+	probeMode   bool
+	trips       int64
+	recoveries  int64
+	spoofs      int64
+	connections int
+}
+
+// writeUpstreamMetrics 输出上游服务器和Cookie指标
+func writeUpstreamMetrics(mw *metricsWriter) {
+	f := GlobalDNSForwarder
+	if f == nil {
+		return
+	}
+
+	allStats := f.GetAllServerStats()
+	samples := make([]upstreamSample, 0, len(allStats))
+	var connCounts map[string]int
+	if f.TCPConnectionPool != nil {
+		connCounts = make(map[string]int)
+		if servers, ok := f.TCPConnectionPool.GetStats()["servers"].(map[string]map[string]interface{}); ok {
+			for addr, s := range servers {
+				connCounts[addr] = int(numberMetric(s["connection_count"]))
+			}
+		}
+	}
+	for addr, stats := range allStats {
+		stats.Mu.RLock()
+		samples = append(samples, upstreamSample{
+			addr:        addr,
+			queries:     stats.Queries,
+			successes:   stats.SuccessfulQueries,
+			failures:    stats.FailedQueries,
+			score:       stats.EWMAScore,
+			latencyMs:   stats.EWMALatency,
+			circuitOpen: stats.CircuitBroken,
+			probeMode:   stats.ProbeMode,
+			trips:       stats.CircuitTrips,
+			recoveries:  stats.CircuitRecoveries,
+			spoofs:      stats.SpoofDetections,
+			connections: connCounts[addr],
+		})
+		stats.Mu.RUnlock()
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].addr < samples[j].addr })
+
+	mw.header("steadydns_upstream_queries_total", "counter", "Queries forwarded to each upstream server.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_queries_total", float64(s.queries), "server", s.addr)
+	}
+	mw.header("steadydns_upstream_responses_total", "counter", "Upstream query outcomes, by server and result.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_responses_total", float64(s.successes), "server", s.addr, "result", "success")
+		mw.sample("steadydns_upstream_responses_total", float64(s.failures), "server", s.addr, "result", "failure")
+	}
+	mw.header("steadydns_upstream_score", "gauge", "Time-decayed EWMA health score of each upstream server (0-1).")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_score", s.score, "server", s.addr)
+	}
+	mw.header("steadydns_upstream_latency_seconds", "gauge", "Time-decayed EWMA response latency of each upstream server.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_latency_seconds", s.latencyMs/1000, "server", s.addr)
+	}
+	mw.header("steadydns_upstream_circuit_open", "gauge", "Whether the circuit breaker of each upstream server is open (1) or closed (0).")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_circuit_open", boolMetric(s.circuitOpen), "server", s.addr)
+	}
+	mw.header("steadydns_upstream_probe_mode", "gauge", "Whether each upstream server is being actively probed for recovery.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_probe_mode", boolMetric(s.probeMode), "server", s.addr)
+	}
+	mw.header("steadydns_upstream_circuit_trips_total", "counter", "Times the circuit breaker of each upstream server opened.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_circuit_trips_total", float64(s.trips), "server", s.addr)
+	}
+	mw.header("steadydns_upstream_circuit_recoveries_total", "counter", "Times the circuit breaker of each upstream server closed again.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_circuit_recoveries_total", float64(s.recoveries), "server", s.addr)
+	}
+	mw.header("steadydns_upstream_spoofed_responses_total", "counter", "Upstream responses rejected by response validation.")
+	for _, s := range samples {
+		mw.sample("steadydns_upstream_spoofed_responses_total", float64(s.spoofs), "server", s.addr)
+	}
+	if connCounts != nil {
+		mw.header("steadydns_upstream_tcp_connections", "gauge", "Pooled TCP connections to each upstream server.")
+		for _, s := range samples {
+			mw.sample("steadydns_upstream_tcp_connections", float64(s.connections), "server", s.addr)
+		}
+	}
+
+	if f.AdaptiveCookieManager != nil {
+		entries, failures := f.AdaptiveCookieManager.GetStats()
+		mw.single("steadydns_cookie_entries", "gauge", "Upstream DNS cookies currently cached.", float64(entries))
+		mw.single("steadydns_cookie_failures", "gauge", "Upstream servers with recent DNS cookie failures.", float64(failures))
+	}
+}
+
+// writeSecurityMetrics 输出速率限制指标
+func writeSecurityMetrics(mw *metricsWriter) {
+	if GlobalSecurityManager == nil || GlobalSecurityManager.rateLimiter == nil {
+		return
+	}
+	rl := GlobalSecurityManager.rateLimiter
+	stats := rl.GetStats()
+
+	mw.header("steadydns_ratelimit_rejections_total", "counter", "Queries refused by rate limiting, by reason.")
+	mw.sample("steadydns_ratelimit_rejections_total", float64(atomic.LoadInt64(&rl.globalLimitedCount)), "reason", "global")
+	mw.sample("steadydns_ratelimit_rejections_total", float64(atomic.LoadInt64(&rl.ipLimitedCount)), "reason", "ip")
+	mw.sample("steadydns_ratelimit_rejections_total", float64(atomic.LoadInt64(&rl.bannedCount)), "reason", "banned")
+	mw.sample("steadydns_ratelimit_rejections_total", float64(atomic.LoadInt64(&rl.quarantinedCount)), "reason", "quarantined")
+	mw.single("steadydns_ratelimit_banned_clients", "gauge", "Client addresses currently banned by rate limiting.", numberMetric(stats["banned_ips"]))
+	mw.single("steadydns_ratelimit_quarantined_clients", "gauge", "Client addresses currently quarantined by anomaly detection.", numberMetric(stats["quarantined_ips"]))
+	mw.single("steadydns_ratelimit_tracked_clients", "gauge", "Client addresses currently tracked by the rate limiter.", numberMetric(stats["ip_limit_count"]))
+}
+
+// writeLoggingMetrics 输出dnstap、实时查询流和查询日志存储指标
+func writeLoggingMetrics(mw *metricsWriter) {
+	if GlobalDNSForwarder != nil && GlobalDNSForwarder.dnstap != nil {
+		written, dropped := GlobalDNSForwarder.dnstap.GetStats()
+		mw.header("steadydns_dnstap_frames_total", "counter", "dnstap frames, by outcome.")
+		mw.sample("steadydns_dnstap_frames_total", float64(written), "result", "written")
+		mw.sample("steadydns_dnstap_frames_total", float64(dropped), "result", "dropped")
+	}
+
+	mw.single("steadydns_query_stream_subscribers", "gauge", "Active live query stream subscribers.", float64(GlobalQueryStream.SubscriberCount()))
+
+	if store := GlobalQueryLogStore; store != nil {
+		mw.header("steadydns_query_log_store_entries_total", "counter", "Query log entries submitted to the indexed store, by outcome.")
+		mw.sample("steadydns_query_log_store_entries_total", float64(atomic.LoadInt64(&store.writtenCount)), "result", "written")
+		mw.sample("steadydns_query_log_store_entries_total", float64(atomic.LoadInt64(&store.droppedCount)), "result", "dropped")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/metrics_test.go
+// Prometheus指标累计和文本格式输出测试
+
+package sdns
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TestQueryMetricsExposition 测试查询计数和延迟直方图输出
+func TestQueryMetricsExposition(t *testing.T) {
+	m := NewQueryMetrics()
+	record := func(qtype, protocol, cache string, rcode int, latency time.Duration) {
+		m.ConsumeQueryLog(&QueryLogBuffer{
+			StartTime:    time.Now().Add(-latency),
+			QueryType:    qtype,
+			ResponseCode: rcode,
+			Protocol:     protocol,
+			CacheStatus:  cache,
+		}, "")
+	}
+	record("A", "udp", "hit", dns.RcodeSuccess, 0)
+	record("A", "udp", "hit", dns.RcodeSuccess, 0)
+	record("AAAA", "tcp", "miss", dns.RcodeNameError, 30*time.Millisecond)
+	record("", "", "", dns.RcodeRefused, 0)
+
+	var buf bytes.Buffer
+	mw := &metricsWriter{w: bufio.NewWriter(&buf)}
+	writeQueryMetrics(mw, m)
+	mw.w.Flush()
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE steadydns_queries_total counter",
+		`steadydns_queries_total{qtype="A",rcode="NOERROR",protocol="udp"} 2`,
+		`steadydns_queries_total{qtype="AAAA",rcode="NXDOMAIN",protocol="tcp"} 1`,
+		`steadydns_queries_total{qtype="UNKNOWN",rcode="REFUSED",protocol="unknown"} 1`,
+		"# TYPE steadydns_query_duration_seconds histogram",
+		`steadydns_query_duration_seconds_bucket{protocol="udp",cache="hit",le="0.0005"} 2`,
+		`steadydns_query_duration_seconds_bucket{protocol="tcp",cache="miss",le="0.025"} 0`,
+		`steadydns_query_duration_seconds_bucket{protocol="tcp",cache="miss",le="0.05"} 1`,
+		`steadydns_query_duration_seconds_bucket{protocol="tcp",cache="miss",le="+Inf"} 1`,
+		`steadydns_query_duration_seconds_count{protocol="unknown",cache="none"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %q\n%s", want, out)
+		}
+	}
+}
+
+// TestWritePrometheusMetrics 测试未启动DNS服务器时的完整输出格式
+func TestWritePrometheusMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePrometheusMetrics(&buf); err != nil {
+		t.Fatalf("WritePrometheusMetrics() error = %v", err)
+	}
+
+	// 每个样本行必须是 名称[{标签}] 值 的形式，且之前声明过TYPE
+	declared := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			declared[strings.Fields(line)[2]] = true
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line
+		if i := strings.IndexAny(line, "{ "); i >= 0 {
+			name = line[:i]
+		}
+		base := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(name, "_bucket"), "_sum"), "_count")
+		if !declared[name] && !declared[base] {
+			t.Errorf("样本 %q 缺少TYPE声明", line)
+		}
+		if len(strings.Fields(line[strings.LastIndex(line, " "):])) != 1 {
+			t.Errorf("样本格式错误: %q", line)
+		}
+	}
+	if !declared["steadydns_query_stream_subscribers"] {
+		t.Errorf("缺少实时查询流指标")
+	}
+}
+
+// TestEscapeLabelValue 测试标签值转义
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabelValue() = %q", got)
+	}
+	if got := formatMetricValue(0.25); got != "0.25" {
+		t.Errorf("formatMetricValue(0.25) = %q", got)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/query_breakdown.go
+
+package sdns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/database"
+)
+
+// 查询统计维度
+const (
+	QueryDimensionQType    = "qtype"    // 查询类型
+	QueryDimensionRCode    = "rcode"    // 响应码
+	QueryDimensionProtocol = "protocol" // 传输协议
+	QueryDimensionCache    = "cache"    // 缓存结果
+)
+
+// 缓存结果取值
+const (
+	CacheOutcomeHit      = "hit"      // 缓存命中（成功响应）
+	CacheOutcomeNegative = "negative" // 缓存命中（错误或空响应）
+	CacheOutcomeMiss     = "miss"     // 缓存未命中
+	CacheOutcomeNone     = "none"     // 未查询缓存（如被安全检查拦截）
+)
+
+// validQueryDimensions 支持的统计维度
+var validQueryDimensions = map[string]bool{
+	QueryDimensionQType:    true,
+	QueryDimensionRCode:    true,
+	QueryDimensionProtocol: true,
+	QueryDimensionCache:    true,
+}
+
+// QueryDimensions 单次查询的统计维度取值
+type QueryDimensions struct {
+	QType    string // 查询类型（A, AAAA, ...）
+	RCode    string // 响应码名称（NOERROR, NXDOMAIN, ...）
+	Protocol string // 传输协议（udp, tcp）
+	Cache    string // 缓存结果（hit, negative, miss, none）
+}
+
+// queryDimensionKey 分钟聚合计数的键
+type queryDimensionKey struct {
+	minute    int64 // 分钟起始时间（Unix秒）
+	dimension string
+	value     string
+}
+
+// QueryBreakdownItem 维度取值的汇总
+type QueryBreakdownItem struct {
+	Value      string  `json:"value"`
+	Count      int64   `json:"count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// QueryBreakdown 查询维度分布及趋势
+// Series中每个切片长度与TimeLabels一致
+type QueryBreakdown struct {
+	Dimension  string               `json:"dimension"`
+	TimeRange  string               `json:"timeRange"`
+	Total      int64                `json:"total"`
+	Items      []QueryBreakdownItem `json:"items"`
+	TimeLabels []string             `json:"timeLabels"`
+	Series     map[string][]int64   `json:"series"`
+}
+
+// CacheOutcome 将查询日志中的缓存状态转换为缓存结果维度取值
+func CacheOutcome(cacheStatus string) string {
+	switch cacheStatus {
+	case "hit":
+		return CacheOutcomeHit
+	case "hit_error":
+		return CacheOutcomeNegative
+	case "miss":
+		return CacheOutcomeMiss
+	default:
+		return CacheOutcomeNone
+	}
+}
+
+// recordQueryDimensions 按分钟累计查询维度计数，调用方需持有sm.mutex写锁
+func (sm *StatsManager) recordQueryDimensions(dims QueryDimensions, now time.Time) {
+	minute := now.Truncate(time.Minute).Unix()
+	values := [...][2]string{
+		{QueryDimensionQType, metricLabel(strings.ToUpper(dims.QType), "UNKNOWN")},
+		{QueryDimensionRCode, metricLabel(dims.RCode, "UNKNOWN")},
+		{QueryDimensionProtocol, metricLabel(dims.Protocol, "unknown")},
+		{QueryDimensionCache, metricLabel(dims.Cache, CacheOutcomeNone)},
+	}
+	for _, v := range values {
+		sm.dimensionCounts[queryDimensionKey{minute: minute, dimension: v[0], value: v[1]}]++
+	}
+}
+
+// takeCompletedQueryDimensions 取出已结束分钟的维度计数并从内存中移除
+func (sm *StatsManager) takeCompletedQueryDimensions(now time.Time) []database.QueryDimensionHistory {
+	current := now.Truncate(time.Minute).Unix()
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	var records []database.QueryDimensionHistory
+	for key, count := range sm.dimensionCounts {
+		if key.minute >= current {
+			continue
+		}
+		records = append(records, database.QueryDimensionHistory{
+			Timestamp: time.Unix(key.minute, 0),
+			Dimension: key.dimension,
+			Value:     key.value,
+			Count:     count,
+		})
+		delete(sm.dimensionCounts, key)
+	}
+	return records
+}
+
+// pendingQueryDimensions 获取尚未持久化的指定维度计数
+func (sm *StatsManager) pendingQueryDimensions(dimension string, startTime time.Time) []database.QueryDimensionHistory {
+	start := startTime.Unix()
+
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	var records []database.QueryDimensionHistory
+	for key, count := range sm.dimensionCounts {
+		if key.dimension != dimension || key.minute < start {
+			continue
+		}
+		records = append(records, database.QueryDimensionHistory{
+			Timestamp: time.Unix(key.minute, 0),
+			Dimension: key.dimension,
+			Value:     key.value,
+			Count:     count,
+		})
+	}
+	return records
+}
+
+// persistQueryDimensions 持久化已结束分钟的查询维度计数
+// 写入失败时丢弃该批数据，避免内存中的计数无限增长
+func (sm *StatsManager) persistQueryDimensions() {
+	records := sm.takeCompletedQueryDimensions(time.Now())
+	if len(records) == 0 {
+		return
+	}
+
+	if err := database.SaveQueryDimensionHistoryBatch(records); err != nil {
+		sm.logger.Error("持久化查询维度历史数据失败: %v", err)
+		return
+	}
+	sm.logger.Debug("持久化了 %d 条查询维度历史记录", len(records))
+}
+
+// GetQueryBreakdown 获取时间范围内指定维度的查询分布和趋势
+// 参数：
+//   - dimension: 统计维度（qtype, rcode, protocol, cache）
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//   - points: 趋势数据点数量，<=0时默认12
+//
+// 返回：
+//   - *QueryBreakdown: 按查询次数降序排列的分布及按时间段聚合的趋势
+//   - error: 维度无效或查询失败时返回错误
+func (sm *StatsManager) GetQueryBreakdown(dimension, timeRange string, points int) (*QueryBreakdown, error) {
+	if !validQueryDimensions[dimension] {
+		return nil, fmt.Errorf("无效的统计维度: %s", dimension)
+	}
+
+	now := time.Now()
+	totalDuration := timeRangeDuration(timeRange)
+	startTime := now.Add(-totalDuration)
+
+	records, err := database.GetQueryDimensionHistoryByTimeRange(dimension, startTime, now)
+	if err != nil {
+		return nil, err
+	}
+	// 合并当前分钟等尚未持久化的计数
+	records = append(records, sm.pendingQueryDimensions(dimension, startTime)...)
+
+	if points <= 0 {
+		points = 12
+	}
+
+	breakdown := &QueryBreakdown{
+		Dimension:  dimension,
+		TimeRange:  timeRange,
+		Items:      make([]QueryBreakdownItem, 0),
+		TimeLabels: make([]string, 0, points),
+		Series:     make(map[string][]int64),
+	}
+
+	totals := make(map[string]int64)
+	for _, r := range records {
+		totals[r.Value] += r.Count
+		breakdown.Total += r.Count
+		if _, ok := breakdown.Series[r.Value]; !ok {
+			breakdown.Series[r.Value] = make([]int64, points)
+		}
+	}
+
+	for value, count := range totals {
+		item := QueryBreakdownItem{Value: value, Count: count}
+		if breakdown.Total > 0 {
+			item.Percentage = round2(float64(count) / float64(breakdown.Total) * 100)
+		}
+		breakdown.Items = append(breakdown.Items, item)
+	}
+	sort.Slice(breakdown.Items, func(i, j int) bool {
+		if breakdown.Items[i].Count != breakdown.Items[j].Count {
+			return breakdown.Items[i].Count > breakdown.Items[j].Count
+		}
+		return breakdown.Items[i].Value < breakdown.Items[j].Value
+	})
+
+	// 记录时间为分钟起始时间，按所在时间段累加
+	timeFormat := timeRangeLabelFormat(timeRange)
+	interval := totalDuration / time.Duration(points)
+	for i := 0; i < points; i++ {
+		breakdown.TimeLabels = append(breakdown.TimeLabels, startTime.Add(time.Duration(i)*interval).Format(timeFormat))
+	}
+	for _, r := range records {
+		slot := int(r.Timestamp.Sub(startTime) / interval)
+		if slot < 0 {
+			slot = 0
+		} else if slot >= points {
+			slot = points - 1
+		}
+		breakdown.Series[r.Value][slot] += r.Count
+	}
+
+	return breakdown, nil
+}
@@ -0,0 +1,80 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/query_breakdown_test.go
+// 查询维度分钟聚合测试
+
+package sdns
+
+import (
+	"testing"
+	"time"
+
+	"SteadyDNS/core/common"
+)
+
+// TestQueryDimensionAggregation 测试按分钟累计和取出已结束分钟的计数
+func TestQueryDimensionAggregation(t *testing.T) {
+	sm := NewStatsManager(common.NewLogger())
+	now := time.Now().Truncate(time.Minute).Add(30 * time.Second)
+
+	sm.recordQueryDimensions(QueryDimensions{QType: "a", RCode: "NOERROR", Protocol: "udp", Cache: CacheOutcomeHit}, now.Add(-time.Minute))
+	sm.recordQueryDimensions(QueryDimensions{QType: "AAAA", RCode: "NXDOMAIN", Protocol: "tcp", Cache: CacheOutcomeMiss}, now.Add(-time.Minute))
+	sm.recordQueryDimensions(QueryDimensions{QType: "A", RCode: "NOERROR", Protocol: "udp", Cache: CacheOutcomeHit}, now)
+	sm.recordQueryDimensions(QueryDimensions{}, now)
+
+	pending := sm.pendingQueryDimensions(QueryDimensionQType, now.Add(-time.Hour))
+	counts := make(map[string]int64)
+	for _, r := range pending {
+		counts[r.Value] += r.Count
+	}
+	if counts["A"] != 2 || counts["AAAA"] != 1 || counts["UNKNOWN"] != 1 {
+		t.Errorf("查询类型计数 = %v", counts)
+	}
+
+	// 只取出上一分钟的计数，当前分钟保留在内存中
+	records := sm.takeCompletedQueryDimensions(now)
+	if len(records) != 8 {
+		t.Fatalf("len(records) = %d, want 8", len(records))
+	}
+	for _, r := range records {
+		if !r.Timestamp.Equal(now.Truncate(time.Minute).Add(-time.Minute)) {
+			t.Errorf("记录时间 = %v，应为上一分钟起始时间", r.Timestamp)
+		}
+	}
+	if n := len(sm.dimensionCounts); n != 8 {
+		t.Errorf("内存中剩余计数 = %d, want 8", n)
+	}
+	if pending := sm.pendingQueryDimensions(QueryDimensionCache, now.Add(-time.Hour)); len(pending) != 2 {
+		t.Errorf("当前分钟缓存结果计数 = %+v", pending)
+	}
+}
+
+// TestCacheOutcome 测试缓存状态到缓存结果的转换
+func TestCacheOutcome(t *testing.T) {
+	tests := map[string]string{
+		"hit":       CacheOutcomeHit,
+		"hit_error": CacheOutcomeNegative,
+		"miss":      CacheOutcomeMiss,
+		"":          CacheOutcomeNone,
+	}
+	for status, want := range tests {
+		if got := CacheOutcome(status); got != want {
+			t.Errorf("CacheOutcome(%q) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/query_hardening.go
+// 出站查询加固：DNS 0x20大小写随机化和响应校验
+//
+// 源端口随机化说明：UDP查询每次新建套接字且不指定本地端口（绑定源地址时端口同样为0），
+// 源端口由操作系统在临时端口范围内随机分配。客户端查询ID不会透传给上游，
+// 转发前使用dns.Id()（crypto/rand）重新生成，收到响应后再恢复为客户端ID。
+
+package sdns
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ErrSpoofedResponse 响应未通过防伪造校验
+var ErrSpoofedResponse = errors.New("疑似伪造的DNS响应")
+
+// RandomizeQNAMECase 对查询名中的字母随机改变大小写（DNS 0x20编码）
+// 直接修改msg的第一个查询，调用方需传入副本
+//
+// 返回:
+//   - string: 原始查询名，消息没有查询部分时返回空字符串
+func RandomizeQNAMECase(msg *dns.Msg) string {
+	if len(msg.Question) == 0 {
+		return ""
+	}
+
+	original := msg.Question[0].Name
+	name := []byte(original)
+
+	random := make([]byte, len(name))
+	if _, err := rand.Read(random); err != nil {
+		return original
+	}
+
+	for i, c := range name {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			if random[i]&1 == 1 {
+				name[i] = c | 0x20 // 小写
+			} else {
+				name[i] = c &^ 0x20 // 大写
+			}
+		}
+	}
+
+	msg.Question[0].Name = string(name)
+	return original
+}
+
+// RestoreQNAMECase 将响应中的查询名及与之相同的记录所有者名恢复为原始大小写
+//
+// 参数:
+//   - resp: DNS响应消息
+//   - randomized: 发送给上游的随机大小写查询名
+//   - original: 客户端的原始查询名
+func RestoreQNAMECase(resp *dns.Msg, randomized, original string) {
+	if resp == nil || randomized == original {
+		return
+	}
+
+	for i := range resp.Question {
+		if resp.Question[i].Name == randomized {
+			resp.Question[i].Name = original
+		}
+	}
+
+	for _, section := range [][]dns.RR{resp.Answer, resp.Ns, resp.Extra} {
+		for _, rr := range section {
+			if rr.Header().Name == randomized {
+				rr.Header().Name = original
+			}
+		}
+	}
+}
+
+// verifyUpstreamResponse 校验上游响应，未通过时记录伪造检测次数
+//
+// 参数:
+//   - addr: 上游服务器地址
+//   - protocol: 收到响应的传输协议（"tcp"或"udp"）
+//   - query: 实际发送给上游的查询（可能已进行0x20编码）
+//   - resp: 上游响应
+//   - stats: 上游服务器统计信息
+//
+// 返回:
+//   - error: 校验失败时返回包装了ErrSpoofedResponse的错误
+func (f *DNSForwarder) verifyUpstreamResponse(addr, protocol string, query, resp *dns.Msg, stats *ServerStats) error {
+	reason := ""
+	caseMismatch := false
+
+	if f.responseValidator != nil {
+		validate := f.responseValidator.ValidateResponse
+		if protocol == "tcp" {
+			validate = f.responseValidator.ValidateTCPResponse
+		}
+		if ok, msg := validate(query, resp); !ok {
+			reason = msg
+		}
+	}
+
+	// 0x20编码时要求响应原样返回查询名的大小写
+	if reason == "" && f.qnameCase0x20 && len(query.Question) > 0 && len(resp.Question) > 0 &&
+		resp.Question[0].Name != query.Question[0].Name {
+		reason = fmt.Sprintf("查询名大小写不匹配: 发送 %s, 收到 %s", query.Question[0].Name, resp.Question[0].Name)
+		caseMismatch = true
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	stats.Mu.Lock()
+	stats.SpoofDetections++
+	if caseMismatch {
+		stats.CaseMismatches++
+	}
+	stats.LastSpoofTime = time.Now()
+	stats.Mu.Unlock()
+
+	f.logger.Warn("转发查询 - 服务器 %s 的响应未通过校验: %s", addr, reason)
+	return fmt.Errorf("%w: %s", ErrSpoofedResponse, reason)
+}
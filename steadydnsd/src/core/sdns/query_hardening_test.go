@@ -0,0 +1,134 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/query_hardening_test.go
+// 出站查询加固测试
+
+package sdns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+
+	"SteadyDNS/core/common"
+)
+
+// TestRandomizeQNAMECase 测试0x20编码和大小写恢复
+func TestRandomizeQNAMECase(t *testing.T) {
+	query := new(dns.Msg)
+	query.SetQuestion("www.example-test.com.", dns.TypeA)
+
+	original := RandomizeQNAMECase(query)
+	randomized := query.Question[0].Name
+	if original != "www.example-test.com." {
+		t.Fatalf("original = %s", original)
+	}
+	if !strings.EqualFold(randomized, original) {
+		t.Fatalf("随机化后的查询名 %s 与原始查询名不等价", randomized)
+	}
+
+	resp := new(dns.Msg)
+	resp.SetReply(query)
+	rr, _ := dns.NewRR(randomized + " 300 IN A 192.0.2.1")
+	resp.Answer = append(resp.Answer, rr)
+
+	RestoreQNAMECase(resp, randomized, original)
+	if resp.Question[0].Name != original || resp.Answer[0].Header().Name != original {
+		t.Errorf("未恢复原始大小写: %s, %s", resp.Question[0].Name, resp.Answer[0].Header().Name)
+	}
+}
+
+// TestVerifyUpstreamResponse 测试上游响应校验和伪造检测计数
+func TestVerifyUpstreamResponse(t *testing.T) {
+	logger := common.NewLogger()
+	f := &DNSForwarder{
+		logger:            logger,
+		responseValidator: NewDNSMessageValidator(logger),
+		qnameCase0x20:     true,
+	}
+	stats := &ServerStats{Address: "192.0.2.53:53"}
+
+	query := new(dns.Msg)
+	query.SetQuestion("ExAmPlE.CoM.", dns.TypeA)
+
+	t.Run("大小写一致", func(t *testing.T) {
+		resp := new(dns.Msg)
+		resp.SetReply(query)
+		if err := f.verifyUpstreamResponse(stats.Address, "udp", query, resp, stats); err != nil {
+			t.Errorf("verifyUpstreamResponse() error = %v", err)
+		}
+	})
+
+	t.Run("大小写不匹配", func(t *testing.T) {
+		resp := new(dns.Msg)
+		resp.SetReply(query)
+		resp.Question[0].Name = "example.com."
+		err := f.verifyUpstreamResponse(stats.Address, "udp", query, resp, stats)
+		if !errors.Is(err, ErrSpoofedResponse) {
+			t.Errorf("大小写不匹配应视为伪造响应, error = %v", err)
+		}
+	})
+
+	t.Run("查询类型不匹配", func(t *testing.T) {
+		resp := new(dns.Msg)
+		resp.SetReply(query)
+		resp.Question[0].Qtype = dns.TypeAAAA
+		if err := f.verifyUpstreamResponse(stats.Address, "udp", query, resp, stats); err == nil {
+			t.Errorf("查询类型不匹配应返回错误")
+		}
+	})
+
+	if stats.SpoofDetections != 2 || stats.CaseMismatches != 1 {
+		t.Errorf("SpoofDetections = %d, CaseMismatches = %d, want 2/1", stats.SpoofDetections, stats.CaseMismatches)
+	}
+}
+
+// TestVerifyUpstreamResponseSize 测试响应大小按传输协议限制，记录数量不设上限
+func TestVerifyUpstreamResponseSize(t *testing.T) {
+	logger := common.NewLogger()
+	f := &DNSForwarder{logger: logger, responseValidator: NewDNSMessageValidator(logger)}
+	stats := &ServerStats{Address: "192.0.2.53:53"}
+
+	query := new(dns.Msg)
+	query.SetQuestion("example.com.", dns.TypeA)
+
+	// 构造包含n条A记录的响应
+	reply := func(n int) *dns.Msg {
+		resp := new(dns.Msg)
+		resp.SetReply(query)
+		for i := 0; i < n; i++ {
+			rr, _ := dns.NewRR(fmt.Sprintf("example.com. 300 IN A 10.0.%d.%d", i/256, i%256))
+			resp.Answer = append(resp.Answer, rr)
+		}
+		return resp
+	}
+
+	if err := f.verifyUpstreamResponse(stats.Address, "udp", query, reply(150), stats); err != nil {
+		t.Errorf("记录数量超过100但大小未超限的响应应通过校验: %v", err)
+	}
+	large := reply(400)
+	if err := f.verifyUpstreamResponse(stats.Address, "udp", query, large, stats); err == nil {
+		t.Errorf("超过DNS_MESSAGE_SIZE_LIMIT的UDP响应应被拒绝")
+	}
+	if err := f.verifyUpstreamResponse(stats.Address, "tcp", query, large, stats); err != nil {
+		t.Errorf("TCP响应应按DNS消息最大长度限制: %v", err)
+	}
+}
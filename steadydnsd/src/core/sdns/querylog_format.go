@@ -0,0 +1,294 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/querylog_format.go
+// 查询日志JSON行格式、字段选择和客户端IP匿名化
+
+package sdns
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"SteadyDNS/core/common"
+)
+
+// 查询日志格式
+const (
+	QueryLogFormatText = "text" // 空格分隔的key=value文本（默认）
+	QueryLogFormatJSON = "json" // JSON行格式
+)
+
+// 客户端IP匿名化方式
+const (
+	AnonymizeNone     = "none"     // 不处理
+	AnonymizeTruncate = "truncate" // 按前缀长度截断
+	AnonymizeHash     = "hash"     // HMAC-SHA256哈希
+)
+
+// QueryLogFields JSON查询日志支持的全部字段
+var QueryLogFields = []string{
+	"timestamp", "query_id", "client_ip", "client_port", "protocol",
+	"qname", "qtype", "rcode", "answer_count", "answers",
+	"cache", "group", "upstream", "security",
+	"durations_ms", "total_ms", "flags", "error",
+}
+
+// QueryLogAnswer JSON查询日志中的应答记录
+type QueryLogAnswer struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	TTL  uint32 `json:"ttl"`
+	Data string `json:"data"`
+}
+
+// queryLogOptions 查询日志输出配置
+type queryLogOptions struct {
+	format     string
+	fields     map[string]bool // 为nil时输出全部字段
+	anonymizer *IPAnonymizer
+}
+
+// loadQueryLogOptions 从[Logging]配置加载查询日志输出配置
+func loadQueryLogOptions() *queryLogOptions {
+	opts := &queryLogOptions{
+		format: strings.ToLower(common.GetConfig("Logging", "QUERY_LOG_FORMAT")),
+	}
+	if opts.format != QueryLogFormatJSON {
+		opts.format = QueryLogFormatText
+	}
+
+	if fields := common.GetConfig("Logging", "QUERY_LOG_FIELDS"); strings.TrimSpace(fields) != "" {
+		opts.fields = make(map[string]bool)
+		for _, field := range strings.Split(fields, ",") {
+			if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+				opts.fields[field] = true
+			}
+		}
+	}
+
+	opts.anonymizer = NewIPAnonymizer(
+		common.GetConfig("Logging", "QUERY_LOG_ANONYMIZE"),
+		common.GetConfigInt("Logging", "QUERY_LOG_IPV4_PREFIX", 24),
+		common.GetConfigInt("Logging", "QUERY_LOG_IPV6_PREFIX", 48),
+		common.GetConfig("Logging", "QUERY_LOG_HASH_SALT"),
+	)
+
+	return opts
+}
+
+// wants 判断字段是否需要输出
+func (o *queryLogOptions) wants(field string) bool {
+	return o.fields == nil || o.fields[field]
+}
+
+// IPAnonymizer 客户端IP匿名化器
+type IPAnonymizer struct {
+	mode     string
+	v4Mask   net.IPMask
+	v6Mask   net.IPMask
+	hashSalt []byte
+}
+
+// NewIPAnonymizer 创建IP匿名化器
+//
+// 参数:
+//   - mode: none、truncate或hash，无效值按none处理
+//   - v4Prefix: truncate模式下IPv4保留的前缀长度（0-32）
+//   - v6Prefix: truncate模式下IPv6保留的前缀长度（0-128）
+//   - salt: hash模式的密钥，为空时每次启动随机生成，哈希值在重启后不可关联
+func NewIPAnonymizer(mode string, v4Prefix, v6Prefix int, salt string) *IPAnonymizer {
+	a := &IPAnonymizer{mode: strings.ToLower(strings.TrimSpace(mode))}
+
+	switch a.mode {
+	case AnonymizeTruncate:
+		if v4Prefix < 0 || v4Prefix > 32 {
+			v4Prefix = 24
+		}
+		if v6Prefix < 0 || v6Prefix > 128 {
+			v6Prefix = 48
+		}
+		a.v4Mask = net.CIDRMask(v4Prefix, 32)
+		a.v6Mask = net.CIDRMask(v6Prefix, 128)
+	case AnonymizeHash:
+		if salt != "" {
+			a.hashSalt = []byte(salt)
+		} else {
+			a.hashSalt = make([]byte, 32)
+			rand.Read(a.hashSalt)
+		}
+	default:
+		a.mode = AnonymizeNone
+	}
+
+	return a
+}
+
+// Anonymize 按配置处理客户端IP，无法解析的地址原样返回（hash模式下同样哈希）
+func (a *IPAnonymizer) Anonymize(ip string) string {
+	if a == nil {
+		return ip
+	}
+
+	switch a.mode {
+	case AnonymizeTruncate:
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return ip
+		}
+		if v4 := parsed.To4(); v4 != nil {
+			return v4.Mask(a.v4Mask).String()
+		}
+		return parsed.Mask(a.v6Mask).String()
+	case AnonymizeHash:
+		mac := hmac.New(sha256.New, a.hashSalt)
+		mac.Write([]byte(ip))
+		return hex.EncodeToString(mac.Sum(nil))[:16]
+	default:
+		return ip
+	}
+}
+
+// formatJSONLogEntry 将查询日志格式化为一行JSON
+func (l *DNSLogger) formatJSONLogEntry(buf *QueryLogBuffer) string {
+	opts := l.options
+	entry := make(map[string]interface{}, len(QueryLogFields))
+	set := func(field string, value interface{}) {
+		if opts.wants(field) {
+			entry[field] = value
+		}
+	}
+
+	set("timestamp", buf.StartTime.Format(time.RFC3339Nano))
+	set("query_id", buf.QueryID)
+	set("client_ip", opts.anonymizer.Anonymize(buf.ClientIP))
+	set("client_port", buf.ClientPort)
+	set("protocol", buf.Protocol)
+	set("qname", buf.QueryName)
+	set("qtype", buf.QueryType)
+	set("rcode", rcodeName(buf.ResponseCode))
+	set("cache", buf.CacheStatus)
+	set("group", buf.ForwardGroup)
+	set("upstream", buf.Upstream)
+	set("security", buf.Security)
+	set("total_ms", roundMs(time.Since(buf.StartTime)))
+
+	if opts.wants("durations_ms") {
+		durations := make(map[string]float64, len(buf.Stages))
+		for _, stage := range buf.Stages {
+			durations[strings.ToLower(stage.Name)] = roundMs(stage.Duration)
+		}
+		entry["durations_ms"] = durations
+	}
+
+	if opts.wants("answer_count") || opts.wants("answers") {
+		answers := queryLogAnswers(buf.Response)
+		set("answer_count", len(answers))
+		set("answers", answers)
+	}
+
+	set("flags", queryLogFlags(buf.Request, buf.Response))
+
+	if buf.Error != "" {
+		set("error", buf.Error)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return formatJSONMessage("查询日志序列化失败: " + err.Error())
+	}
+	return string(data)
+}
+
+// queryLogAnswers 提取响应中的应答记录
+func queryLogAnswers(resp *dns.Msg) []QueryLogAnswer {
+	answers := []QueryLogAnswer{}
+	if resp == nil {
+		return answers
+	}
+	for _, rr := range resp.Answer {
+		hdr := rr.Header()
+		answers = append(answers, QueryLogAnswer{
+			Name: hdr.Name,
+			Type: dns.TypeToString[hdr.Rrtype],
+			TTL:  hdr.Ttl,
+			Data: strings.TrimPrefix(rr.String(), hdr.String()),
+		})
+	}
+	return answers
+}
+
+// formatJSONMessage 将普通日志消息格式化为一行JSON
+func formatJSONMessage(message string) string {
+	data, _ := json.Marshal(map[string]string{
+		"timestamp": time.Now().Format(time.RFC3339Nano),
+		"message":   message,
+	})
+	return string(data)
+}
+
+// queryLogFlags 提取响应头标志位和请求的DO位
+func queryLogFlags(req, resp *dns.Msg) []string {
+	flags := []string{}
+	if resp != nil {
+		for _, f := range []struct {
+			set  bool
+			name string
+		}{
+			{resp.Response, "qr"},
+			{resp.Authoritative, "aa"},
+			{resp.Truncated, "tc"},
+			{resp.RecursionDesired, "rd"},
+			{resp.RecursionAvailable, "ra"},
+			{resp.AuthenticatedData, "ad"},
+			{resp.CheckingDisabled, "cd"},
+		} {
+			if f.set {
+				flags = append(flags, f.name)
+			}
+		}
+	}
+	if req != nil {
+		if opt := req.IsEdns0(); opt != nil && opt.Do() {
+			flags = append(flags, "do")
+		}
+	}
+	return flags
+}
+
+// rcodeName 响应码名称，未知响应码返回数字字符串
+func rcodeName(rcode int) string {
+	if name, ok := dns.RcodeToString[rcode]; ok {
+		return name
+	}
+	return strconv.Itoa(rcode)
+}
+
+// roundMs 转换为毫秒并保留3位小数
+func roundMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
@@ -0,0 +1,159 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/querylog_format_test.go
+// 查询日志JSON格式和IP匿名化测试
+
+package sdns
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TestIPAnonymizer 测试客户端IP匿名化
+func TestIPAnonymizer(t *testing.T) {
+	tests := []struct {
+		name string
+		a    *IPAnonymizer
+		ip   string
+		want string
+	}{
+		{"none原样返回", NewIPAnonymizer("none", 24, 48, ""), "192.168.1.100", "192.168.1.100"},
+		{"无效模式按none处理", NewIPAnonymizer("bogus", 24, 48, ""), "10.0.0.1", "10.0.0.1"},
+		{"IPv4截断/24", NewIPAnonymizer("truncate", 24, 48, ""), "192.168.1.100", "192.168.1.0"},
+		{"IPv4截断/16", NewIPAnonymizer("truncate", 16, 48, ""), "192.168.1.100", "192.168.0.0"},
+		{"IPv6截断/48", NewIPAnonymizer("truncate", 24, 48, ""), "2001:db8:1234:5678::1", "2001:db8:1234::"},
+		{"无效前缀使用默认值", NewIPAnonymizer("truncate", 99, -1, ""), "10.1.2.3", "10.1.2.0"},
+		{"无法解析的地址原样返回", NewIPAnonymizer("truncate", 24, 48, ""), "unknown", "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Anonymize(tt.ip); got != tt.want {
+				t.Errorf("Anonymize(%q) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("hash模式", func(t *testing.T) {
+		a := NewIPAnonymizer("hash", 24, 48, "secret")
+		b := NewIPAnonymizer("hash", 24, 48, "other")
+		h1 := a.Anonymize("192.168.1.100")
+		if len(h1) != 16 || h1 == "192.168.1.100" {
+			t.Errorf("哈希结果 = %q", h1)
+		}
+		if a.Anonymize("192.168.1.100") != h1 {
+			t.Errorf("相同密钥的哈希结果应稳定")
+		}
+		if b.Anonymize("192.168.1.100") == h1 {
+			t.Errorf("不同密钥的哈希结果应不同")
+		}
+	})
+}
+
+// TestFormatJSONLogEntry 测试JSON查询日志格式和字段选择
+func TestFormatJSONLogEntry(t *testing.T) {
+	req := new(dns.Msg)
+	req.SetQuestion("example.com.", dns.TypeA)
+	req.SetEdns0(1232, true)
+
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.RecursionAvailable = true
+	rr, _ := dns.NewRR("example.com. 300 IN A 93.184.216.34")
+	resp.Answer = append(resp.Answer, rr)
+
+	buf := &QueryLogBuffer{
+		QueryID:      "1",
+		ClientIP:     "192.168.1.100",
+		ClientPort:   53000,
+		Protocol:     "udp",
+		QueryName:    "example.com.",
+		QueryType:    "A",
+		StartTime:    time.Now(),
+		Stages:       []StageInfo{{Name: "CACHE", Duration: 1500 * time.Microsecond}},
+		ResponseCode: dns.RcodeSuccess,
+		CacheStatus:  "miss",
+		ForwardGroup: "Default",
+		Upstream:     "8.8.8.8:53",
+		Request:      req,
+		Response:     resp,
+	}
+
+	t.Run("全部字段", func(t *testing.T) {
+		l := &DNSLogger{options: &queryLogOptions{
+			format:     QueryLogFormatJSON,
+			anonymizer: NewIPAnonymizer("truncate", 24, 48, ""),
+		}}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(l.formatJSONLogEntry(buf)), &entry); err != nil {
+			t.Fatalf("JSON解析失败: %v", err)
+		}
+
+		if entry["client_ip"] != "192.168.1.0" {
+			t.Errorf("client_ip = %v", entry["client_ip"])
+		}
+		if entry["rcode"] != "NOERROR" || entry["upstream"] != "8.8.8.8:53" || entry["cache"] != "miss" {
+			t.Errorf("entry = %v", entry)
+		}
+		if entry["answer_count"] != float64(1) {
+			t.Errorf("answer_count = %v", entry["answer_count"])
+		}
+		answers := entry["answers"].([]interface{})
+		answer := answers[0].(map[string]interface{})
+		if answer["type"] != "A" || answer["data"] != "93.184.216.34" || answer["ttl"] != float64(300) {
+			t.Errorf("answers = %v", answers)
+		}
+		durations := entry["durations_ms"].(map[string]interface{})
+		if durations["cache"] != 1.5 {
+			t.Errorf("durations_ms = %v", durations)
+		}
+		flags := entry["flags"].([]interface{})
+		want := map[string]bool{"qr": true, "rd": true, "ra": true, "do": true}
+		if len(flags) != len(want) {
+			t.Errorf("flags = %v", flags)
+		}
+		for _, f := range flags {
+			if !want[f.(string)] {
+				t.Errorf("意外的标志位 %v", f)
+			}
+		}
+		if _, ok := entry["error"]; ok {
+			t.Errorf("无错误时不应输出error字段")
+		}
+	})
+
+	t.Run("字段选择", func(t *testing.T) {
+		l := &DNSLogger{options: &queryLogOptions{
+			format: QueryLogFormatJSON,
+			fields: map[string]bool{"qname": true, "rcode": true},
+		}}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(l.formatJSONLogEntry(buf)), &entry); err != nil {
+			t.Fatalf("JSON解析失败: %v", err)
+		}
+		if len(entry) != 2 || entry["qname"] != "example.com." || entry["rcode"] != "NOERROR" {
+			t.Errorf("entry = %v", entry)
+		}
+	})
+}
@@ -0,0 +1,231 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/querylog_store.go
+// 查询日志索引存储：订阅DNSLogger，批量写入查询日志数据库并按配置清理
+
+package sdns
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/database"
+)
+
+// GlobalQueryLogStore 全局查询日志存储实例，日志分析插件启用时创建
+var GlobalQueryLogStore *QueryLogStore
+
+// QueryLogStore 查询日志存储
+// 与DNSLogger相同，查询处理协程只做非阻塞提交，写入由后台协程批量完成，队列满时丢弃并计数
+type QueryLogStore struct {
+	entries chan database.QueryLogEntry
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	logger  *common.Logger
+
+	batchSize     int
+	flushInterval time.Duration
+	retentionDays int
+	maxSizeBytes  int64
+
+	// 统计信息
+	writtenCount int64
+	droppedCount int64
+}
+
+// QueryLogStoreStatus 查询日志存储运行状态
+type QueryLogStoreStatus struct {
+	Written       int64                        `json:"written"`
+	Dropped       int64                        `json:"dropped"`
+	RetentionDays int                          `json:"retentionDays"`
+	MaxSizeBytes  int64                        `json:"maxSizeBytes"`
+	Store         *database.QueryLogStoreStats `json:"store"`
+}
+
+// StartQueryLogStore 打开查询日志数据库并启动存储，设置GlobalQueryLogStore
+func StartQueryLogStore(logger *common.Logger) (*QueryLogStore, error) {
+	dbPath := common.GetConfig("Logging", "QUERY_LOG_STORE_PATH")
+	if dbPath == "" {
+		dbPath = "log/querylog.db"
+	}
+	if err := database.InitQueryLogDB(dbPath); err != nil {
+		return nil, err
+	}
+
+	s := &QueryLogStore{
+		entries:       make(chan database.QueryLogEntry, 10000),
+		done:          make(chan struct{}),
+		logger:        logger,
+		batchSize:     500,
+		flushInterval: time.Second,
+		retentionDays: common.GetConfigInt("Logging", "QUERY_LOG_RETENTION_DAYS", 7),
+		maxSizeBytes:  int64(common.GetConfigInt("Logging", "QUERY_LOG_STORE_MAX_SIZE", 1024)) * 1024 * 1024,
+	}
+
+	s.wg.Add(2)
+	go s.batchWriter()
+	go s.retentionLoop()
+
+	GlobalQueryLogStore = s
+	return s, nil
+}
+
+// StopQueryLogStore 停止全局查询日志存储并关闭数据库
+func StopQueryLogStore() {
+	if GlobalQueryLogStore == nil {
+		return
+	}
+	GlobalQueryLogStore.Close()
+	GlobalQueryLogStore = nil
+	database.CloseQueryLogDB()
+}
+
+// ConsumeQueryLog 实现QueryLogSink，转换为存储记录并非阻塞提交
+func (s *QueryLogStore) ConsumeQueryLog(buf *QueryLogBuffer, clientIP string) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	entry := database.QueryLogEntry{
+		Timestamp:    buf.StartTime,
+		ClientIP:     clientIP,
+		ClientPort:   buf.ClientPort,
+		Protocol:     buf.Protocol,
+		QName:        strings.ToLower(buf.QueryName),
+		QType:        buf.QueryType,
+		RCode:        rcodeName(buf.ResponseCode),
+		CacheStatus:  buf.CacheStatus,
+		ForwardGroup: buf.ForwardGroup,
+		Upstream:     buf.Upstream,
+		Security:     buf.Security,
+		DurationMs:   roundMs(time.Since(buf.StartTime)),
+		Error:        buf.Error,
+	}
+	if buf.Response != nil {
+		entry.AnswerCount = len(buf.Response.Answer)
+		answers := make([]string, 0, len(buf.Response.Answer))
+		for _, rr := range buf.Response.Answer {
+			answers = append(answers, rr.String())
+		}
+		entry.Answers = strings.Join(answers, "\n")
+	}
+
+	select {
+	case s.entries <- entry:
+	default:
+		atomic.AddInt64(&s.droppedCount, 1)
+	}
+}
+
+// batchWriter 批量写入协程
+func (s *QueryLogStore) batchWriter() {
+	defer s.wg.Done()
+
+	batch := make([]database.QueryLogEntry, 0, s.batchSize)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := database.SaveQueryLogBatch(batch); err != nil {
+			atomic.AddInt64(&s.droppedCount, int64(len(batch)))
+			if s.logger != nil {
+				s.logger.Error("写入查询日志存储失败: %v", err)
+			}
+		} else {
+			atomic.AddInt64(&s.writtenCount, int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			// 写入队列中剩余的记录
+		drain:
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+				default:
+					break drain
+				}
+			}
+			flush()
+			return
+		}
+	}
+}
+
+// retentionLoop 启动时及每小时按保留天数和大小上限清理
+func (s *QueryLogStore) retentionLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if _, err := database.CleanQueryLogs(s.retentionDays, s.maxSizeBytes); err != nil && s.logger != nil {
+			s.logger.Error("清理查询日志存储失败: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close 停止存储，写入剩余记录
+func (s *QueryLogStore) Close() {
+	s.once.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+	})
+}
+
+// GetStatus 获取存储运行状态
+func (s *QueryLogStore) GetStatus() (*QueryLogStoreStatus, error) {
+	stats, err := database.GetQueryLogStoreStats()
+	if err != nil {
+		return nil, err
+	}
+	return &QueryLogStoreStatus{
+		Written:       atomic.LoadInt64(&s.writtenCount),
+		Dropped:       atomic.LoadInt64(&s.droppedCount),
+		RetentionDays: s.retentionDays,
+		MaxSizeBytes:  s.maxSizeBytes,
+		Store:         stats,
+	}, nil
+}
@@ -0,0 +1,311 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/querylog_stream.go
+// 实时查询流：订阅DNSLogger，按订阅者的过滤条件和采样率分发查询事件
+
+package sdns
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// MaxQueryStreamSubscribers 最大同时订阅数
+	MaxQueryStreamSubscribers = 16
+	// queryStreamBufferSize 每个订阅者的事件缓冲区大小，满时丢弃
+	queryStreamBufferSize = 256
+)
+
+// GlobalQueryStream 全局实时查询流
+var GlobalQueryStream = NewQueryStream()
+
+// QueryStreamEvent 实时查询事件
+type QueryStreamEvent struct {
+	Timestamp    time.Time        `json:"timestamp"`
+	ClientIP     string           `json:"clientIp"`
+	ClientPort   int              `json:"clientPort"`
+	Protocol     string           `json:"protocol"`
+	QName        string           `json:"qname"`
+	QType        string           `json:"qtype"`
+	RCode        string           `json:"rcode"`
+	CacheStatus  string           `json:"cacheStatus,omitempty"`
+	ForwardGroup string           `json:"forwardGroup,omitempty"`
+	Upstream     string           `json:"upstream,omitempty"`
+	Security     string           `json:"security,omitempty"`
+	Answers      []QueryLogAnswer `json:"answers"`
+	DurationMs   float64          `json:"durationMs"`
+	Error        string           `json:"error,omitempty"`
+}
+
+// QueryStreamFilter 订阅过滤条件，空值表示不过滤
+type QueryStreamFilter struct {
+	// Client 客户端IP或CIDR，与匿名化后的地址比较
+	Client string
+	// Name 域名模式，包含*时按通配符匹配完整域名，否则按子串匹配，不区分大小写
+	Name string
+	// RCodes 响应码名称列表，如NOERROR、NXDOMAIN
+	RCodes []string
+	// QTypes 查询类型列表，如A、AAAA
+	QTypes []string
+	// Sample 采样率，(0,1]，0表示不采样（全部推送）
+	Sample float64
+}
+
+// compiledStreamFilter 预处理后的过滤条件
+type compiledStreamFilter struct {
+	clientIP  string
+	clientNet *net.IPNet
+	name      string
+	wildcard  bool
+	rcodes    map[string]bool
+	qtypes    map[string]bool
+	sample    float64
+}
+
+// compile 校验并预处理过滤条件
+func (f QueryStreamFilter) compile() (*compiledStreamFilter, error) {
+	c := &compiledStreamFilter{sample: f.Sample}
+
+	if f.Sample < 0 || f.Sample > 1 {
+		return nil, fmt.Errorf("采样率必须在0到1之间")
+	}
+
+	if client := strings.TrimSpace(f.Client); client != "" {
+		if strings.Contains(client, "/") {
+			_, ipNet, err := net.ParseCIDR(client)
+			if err != nil {
+				return nil, fmt.Errorf("无效的客户端CIDR: %s", client)
+			}
+			c.clientNet = ipNet
+		} else {
+			c.clientIP = client
+		}
+	}
+
+	if name := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(f.Name), ".")); name != "" {
+		c.name = name
+		c.wildcard = strings.Contains(name, "*")
+		if c.wildcard {
+			if _, err := path.Match(name, ""); err != nil {
+				return nil, fmt.Errorf("无效的域名模式: %s", f.Name)
+			}
+		}
+	}
+
+	if len(f.RCodes) > 0 {
+		c.rcodes = make(map[string]bool, len(f.RCodes))
+		for _, r := range f.RCodes {
+			if r = strings.ToUpper(strings.TrimSpace(r)); r != "" {
+				c.rcodes[r] = true
+			}
+		}
+	}
+	if len(f.QTypes) > 0 {
+		c.qtypes = make(map[string]bool, len(f.QTypes))
+		for _, t := range f.QTypes {
+			if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+				c.qtypes[t] = true
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// match 检查事件是否满足过滤条件
+func (c *compiledStreamFilter) match(clientIP, qname, qtype, rcode string) bool {
+	if c.clientIP != "" && clientIP != c.clientIP {
+		return false
+	}
+	if c.clientNet != nil {
+		ip := net.ParseIP(clientIP)
+		if ip == nil || !c.clientNet.Contains(ip) {
+			return false
+		}
+	}
+	if len(c.rcodes) > 0 && !c.rcodes[rcode] {
+		return false
+	}
+	if len(c.qtypes) > 0 && !c.qtypes[qtype] {
+		return false
+	}
+	if c.name != "" {
+		name := strings.ToLower(strings.TrimSuffix(qname, "."))
+		if c.wildcard {
+			// path.Match的*不跨越/，域名中不含/，因此*可匹配多级标签
+			if ok, _ := path.Match(c.name, name); !ok {
+				return false
+			}
+		} else if !strings.Contains(name, c.name) {
+			return false
+		}
+	}
+	if c.sample > 0 && c.sample < 1 && rand.Float64() >= c.sample {
+		return false
+	}
+	return true
+}
+
+// QueryStreamSubscription 实时查询流订阅
+type QueryStreamSubscription struct {
+	stream  *QueryStream
+	filter  *compiledStreamFilter
+	events  chan *QueryStreamEvent
+	dropped int64
+	once    sync.Once
+}
+
+// Events 返回事件通道
+func (s *QueryStreamSubscription) Events() <-chan *QueryStreamEvent {
+	return s.events
+}
+
+// Dropped 返回因缓冲区满而丢弃的事件数
+func (s *QueryStreamSubscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close 取消订阅，之后不再接收新事件
+// 事件通道不关闭，避免与查询处理协程的并发发送冲突
+func (s *QueryStreamSubscription) Close() {
+	s.once.Do(func() {
+		s.stream.unsubscribe(s)
+	})
+}
+
+// QueryStream 实时查询流
+// 订阅者列表采用写时复制，查询处理协程无锁读取；没有订阅者时不构造事件
+type QueryStream struct {
+	mu          sync.Mutex
+	subscribers atomic.Pointer[[]*QueryStreamSubscription]
+}
+
+// NewQueryStream 创建实时查询流
+func NewQueryStream() *QueryStream {
+	return &QueryStream{}
+}
+
+// Subscribe 按过滤条件订阅查询事件
+func (qs *QueryStream) Subscribe(filter QueryStreamFilter) (*QueryStreamSubscription, error) {
+	compiled, err := filter.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	var current []*QueryStreamSubscription
+	if p := qs.subscribers.Load(); p != nil {
+		current = *p
+	}
+	if len(current) >= MaxQueryStreamSubscribers {
+		return nil, fmt.Errorf("实时查询流订阅数已达上限 %d", MaxQueryStreamSubscribers)
+	}
+
+	sub := &QueryStreamSubscription{
+		stream: qs,
+		filter: compiled,
+		events: make(chan *QueryStreamEvent, queryStreamBufferSize),
+	}
+	next := make([]*QueryStreamSubscription, 0, len(current)+1)
+	next = append(next, current...)
+	next = append(next, sub)
+	qs.subscribers.Store(&next)
+	return sub, nil
+}
+
+// unsubscribe 移除订阅者
+func (qs *QueryStream) unsubscribe(sub *QueryStreamSubscription) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	p := qs.subscribers.Load()
+	if p == nil {
+		return
+	}
+	next := make([]*QueryStreamSubscription, 0, len(*p))
+	for _, s := range *p {
+		if s != sub {
+			next = append(next, s)
+		}
+	}
+	qs.subscribers.Store(&next)
+}
+
+// SubscriberCount 返回当前订阅数
+func (qs *QueryStream) SubscriberCount() int {
+	if p := qs.subscribers.Load(); p != nil {
+		return len(*p)
+	}
+	return 0
+}
+
+// ConsumeQueryLog 实现QueryLogSink，向匹配的订阅者非阻塞推送事件
+func (qs *QueryStream) ConsumeQueryLog(buf *QueryLogBuffer, clientIP string) {
+	p := qs.subscribers.Load()
+	if p == nil || len(*p) == 0 {
+		return
+	}
+
+	qtype := strings.ToUpper(buf.QueryType)
+	rcode := rcodeName(buf.ResponseCode)
+
+	var event *QueryStreamEvent
+	for _, sub := range *p {
+		if !sub.filter.match(clientIP, buf.QueryName, qtype, rcode) {
+			continue
+		}
+		// 事件在首个匹配的订阅者处构造，订阅者之间共享只读事件
+		if event == nil {
+			event = &QueryStreamEvent{
+				Timestamp:    buf.StartTime,
+				ClientIP:     clientIP,
+				ClientPort:   buf.ClientPort,
+				Protocol:     buf.Protocol,
+				QName:        buf.QueryName,
+				QType:        qtype,
+				RCode:        rcode,
+				CacheStatus:  buf.CacheStatus,
+				ForwardGroup: buf.ForwardGroup,
+				Upstream:     buf.Upstream,
+				Security:     buf.Security,
+				Answers:      queryLogAnswers(buf.Response),
+				DurationMs:   roundMs(time.Since(buf.StartTime)),
+				Error:        buf.Error,
+			}
+		}
+		sub.send(event)
+	}
+}
+
+// send 非阻塞发送，缓冲区满时丢弃
+func (s *QueryStreamSubscription) send(event *QueryStreamEvent) {
+	select {
+	case s.events <- event:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/querylog_stream_test.go
+// 实时查询流过滤、采样和非阻塞分发测试
+
+package sdns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// newStreamTestBuffer 构造测试用的查询日志缓冲区
+func newStreamTestBuffer(qname, qtype string, rcode int) *QueryLogBuffer {
+	return &QueryLogBuffer{
+		StartTime:    time.Now(),
+		ClientIP:     "192.168.1.10",
+		QueryName:    qname,
+		QueryType:    qtype,
+		ResponseCode: rcode,
+	}
+}
+
+// TestQueryStreamFilter 测试订阅过滤条件
+func TestQueryStreamFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter QueryStreamFilter
+		client string
+		qname  string
+		qtype  string
+		rcode  int
+		want   bool
+	}{
+		{"无过滤", QueryStreamFilter{}, "10.0.0.1", "example.com.", "A", dns.RcodeSuccess, true},
+		{"客户端IP", QueryStreamFilter{Client: "10.0.0.1"}, "10.0.0.2", "example.com.", "A", dns.RcodeSuccess, false},
+		{"客户端CIDR", QueryStreamFilter{Client: "10.0.0.0/24"}, "10.0.0.2", "example.com.", "A", dns.RcodeSuccess, true},
+		{"CIDR不匹配", QueryStreamFilter{Client: "10.0.0.0/24"}, "10.0.1.2", "example.com.", "A", dns.RcodeSuccess, false},
+		{"域名子串", QueryStreamFilter{Name: "EXAMPLE"}, "10.0.0.1", "www.example.com.", "A", dns.RcodeSuccess, true},
+		{"通配符", QueryStreamFilter{Name: "*.example.com"}, "10.0.0.1", "a.b.example.com.", "A", dns.RcodeSuccess, true},
+		{"通配符不匹配父域", QueryStreamFilter{Name: "*.example.com"}, "10.0.0.1", "example.com.", "A", dns.RcodeSuccess, false},
+		{"响应码", QueryStreamFilter{RCodes: []string{"nxdomain", "servfail"}}, "10.0.0.1", "x.test.", "A", dns.RcodeNameError, true},
+		{"响应码不匹配", QueryStreamFilter{RCodes: []string{"NXDOMAIN"}}, "10.0.0.1", "x.test.", "A", dns.RcodeSuccess, false},
+		{"查询类型", QueryStreamFilter{QTypes: []string{"aaaa"}}, "10.0.0.1", "x.test.", "A", dns.RcodeSuccess, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qs := NewQueryStream()
+			sub, err := qs.Subscribe(tt.filter)
+			if err != nil {
+				t.Fatalf("Subscribe() error = %v", err)
+			}
+			defer sub.Close()
+
+			qs.ConsumeQueryLog(newStreamTestBuffer(tt.qname, tt.qtype, tt.rcode), tt.client)
+			got := len(sub.Events()) == 1
+			if got != tt.want {
+				t.Errorf("匹配 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestQueryStreamInvalidFilter 测试无效过滤条件
+func TestQueryStreamInvalidFilter(t *testing.T) {
+	qs := NewQueryStream()
+	for _, f := range []QueryStreamFilter{
+		{Client: "10.0.0.0/33"},
+		{Name: "*[example"},
+		{Sample: 1.5},
+		{Sample: -0.1},
+	} {
+		if _, err := qs.Subscribe(f); err == nil {
+			t.Errorf("Subscribe(%+v) 应返回错误", f)
+		}
+	}
+}
+
+// TestQueryStreamSampling 测试采样率
+func TestQueryStreamSampling(t *testing.T) {
+	qs := NewQueryStream()
+	sub, err := qs.Subscribe(QueryStreamFilter{Sample: 0.1})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+
+	// 少于缓冲区大小，避免丢弃影响计数
+	for i := 0; i < queryStreamBufferSize; i++ {
+		qs.ConsumeQueryLog(newStreamTestBuffer("example.com.", "A", dns.RcodeSuccess), "10.0.0.1")
+	}
+	if n := len(sub.Events()); n == 0 || n > queryStreamBufferSize/2 {
+		t.Errorf("采样后事件数 = %d，超出预期范围", n)
+	}
+}
+
+// TestQueryStreamNonBlocking 测试订阅者不消费时丢弃事件而不阻塞
+func TestQueryStreamNonBlocking(t *testing.T) {
+	qs := NewQueryStream()
+	sub, err := qs.Subscribe(QueryStreamFilter{})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	start := time.Now()
+	total := queryStreamBufferSize + 100
+	for i := 0; i < total; i++ {
+		qs.ConsumeQueryLog(newStreamTestBuffer("example.com.", "A", dns.RcodeSuccess), "10.0.0.1")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("分发不应阻塞")
+	}
+	if sub.Dropped() != 100 {
+		t.Errorf("Dropped() = %d, want 100", sub.Dropped())
+	}
+
+	sub.Close()
+	if qs.SubscriberCount() != 0 {
+		t.Errorf("取消订阅后订阅数 = %d", qs.SubscriberCount())
+	}
+	qs.ConsumeQueryLog(newStreamTestBuffer("example.com.", "A", dns.RcodeSuccess), "10.0.0.1")
+	if sub.Dropped() != 100 {
+		t.Errorf("取消订阅后不应再接收事件")
+	}
+}
+
+// TestQueryStreamSubscriberLimit 测试订阅数上限
+func TestQueryStreamSubscriberLimit(t *testing.T) {
+	qs := NewQueryStream()
+	for i := 0; i < MaxQueryStreamSubscribers; i++ {
+		if _, err := qs.Subscribe(QueryStreamFilter{}); err != nil {
+			t.Fatalf("第 %d 个订阅失败: %v", i+1, err)
+		}
+	}
+	if _, err := qs.Subscribe(QueryStreamFilter{}); err == nil {
+		t.Errorf("超出上限的订阅应返回错误")
+	}
+}
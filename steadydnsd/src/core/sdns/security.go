@@ -20,8 +20,12 @@ along with this program.  If not, see <https://www.gnu.org/licenses/>.
 package sdns
 
 import (
+	"fmt"
 	"net"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/miekg/dns"
@@ -71,6 +75,11 @@ func (v *DNSMessageValidator) ValidateQuery(msg *dns.Msg) (bool, string) {
 		return false, "DNS消息中查询数量过多"
 	}
 
+	// 动态更新的区域段必须只有一条SOA类型的记录（RFC 2136 3.1.1）
+	if msg.Opcode == dns.OpcodeUpdate && (len(msg.Question) != 1 || msg.Question[0].Qtype != dns.TypeSOA) {
+		return false, "动态更新的区域段无效"
+	}
+
 	// 检查每个查询
 	for i, q := range msg.Question {
 		// 检查查询名称
@@ -116,8 +125,19 @@ func (v *DNSMessageValidator) ValidateQuery(msg *dns.Msg) (bool, string) {
 	return true, "验证通过"
 }
 
-// ValidateResponse 验证DNS响应消息
+// ValidateResponse 验证DNS响应消息，响应大小按DNS_MESSAGE_SIZE_LIMIT限制
 func (v *DNSMessageValidator) ValidateResponse(req *dns.Msg, resp *dns.Msg) (bool, string) {
+	return v.validateResponse(req, resp, v.messageSizeLimit)
+}
+
+// ValidateTCPResponse 验证经由TCP收到的DNS响应消息
+// TCP响应不受UDP报文大小约束，响应大小按DNS消息最大长度限制
+func (v *DNSMessageValidator) ValidateTCPResponse(req *dns.Msg, resp *dns.Msg) (bool, string) {
+	return v.validateResponse(req, resp, dns.MaxMsgSize)
+}
+
+// validateResponse 验证DNS响应消息，sizeLimit为允许的最大响应字节数
+func (v *DNSMessageValidator) validateResponse(req *dns.Msg, resp *dns.Msg, sizeLimit int) (bool, string) {
 	// 如果验证被禁用，直接通过
 	if !v.validationEnabled {
 		return true, "验证已禁用"
@@ -138,8 +158,11 @@ func (v *DNSMessageValidator) ValidateResponse(req *dns.Msg, resp *dns.Msg) (boo
 		return false, "只支持查询类型的DNS响应"
 	}
 
-	// 验证查询部分
+	// 验证查询部分，错误响应（如FORMERR、REFUSED）可能不携带查询部分
 	if len(resp.Question) == 0 {
+		if resp.Rcode != dns.RcodeSuccess {
+			return true, "验证通过"
+		}
 		return false, "DNS响应中没有查询部分"
 	}
 
@@ -154,8 +177,9 @@ func (v *DNSMessageValidator) ValidateResponse(req *dns.Msg, resp *dns.Msg) (boo
 				break
 			}
 
+			// 域名比较不区分大小写，0x20大小写校验由转发路径单独处理
 			reqQ := req.Question[i]
-			if q.Name != reqQ.Name || q.Qtype != reqQ.Qtype || q.Qclass != reqQ.Qclass {
+			if !strings.EqualFold(q.Name, reqQ.Name) || q.Qtype != reqQ.Qtype || q.Qclass != reqQ.Qclass {
 				return false, "DNS响应中的查询与原始查询不匹配"
 			}
 		}
@@ -168,16 +192,10 @@ func (v *DNSMessageValidator) ValidateResponse(req *dns.Msg, resp *dns.Msg) (boo
 	}
 
 	// 限制响应大小
-	if len(msgBytes) > v.messageSizeLimit {
+	if len(msgBytes) > sizeLimit {
 		return false, "DNS响应过大"
 	}
 
-	// 检查响应记录数量
-	totalRecords := len(resp.Answer) + len(resp.Ns) + len(resp.Extra)
-	if totalRecords > 100 {
-		return false, "DNS响应中记录数量过多"
-	}
-
 	return true, "验证通过"
 }
 
@@ -221,6 +239,22 @@ type DNSRateLimiter struct {
 	maxQueriesPerMinuteGlobal int
 	banDuration               time.Duration
 	logger                    *common.Logger
+
+	// 异常检测隔离的客户端及隔离解除时间
+	quarantineMutex sync.RWMutex
+	quarantined     map[string]time.Time
+
+	// 累计拒绝次数，按原因区分
+	globalLimitedCount int64
+	ipLimitedCount     int64
+	bannedCount        int64
+	quarantinedCount   int64
+}
+
+// QuarantinedClient 被隔离的客户端
+type QuarantinedClient struct {
+	ClientIP string    `json:"clientIp"`
+	Until    time.Time `json:"until"`
 }
 
 // LimitCounter 限制计数器
@@ -306,6 +340,7 @@ func NewDNSRateLimiter(logger *common.Logger) *DNSRateLimiter {
 		maxQueriesPerMinuteIP:     ipLimit,     // 每IP每分钟查询限制
 		maxQueriesPerMinuteGlobal: globalLimit, // 全局每分钟查询限制
 		banDuration:               banDuration,
+		quarantined:               make(map[string]time.Time),
 		logger:                    logger,
 	}
 }
@@ -325,12 +360,19 @@ func (rl *DNSRateLimiter) getIPShard(ip string) int {
 
 // CheckAndLimit 检查并限制查询速率
 func (rl *DNSRateLimiter) CheckAndLimit(clientIP string) (bool, string) {
+	// 被隔离的客户端直接拒绝，不计入速率统计
+	if rl.IsQuarantined(clientIP) {
+		atomic.AddInt64(&rl.quarantinedCount, 1)
+		return false, "IP已被异常检测隔离"
+	}
+
 	// 检查全局限制
 	rl.globalMutex.Lock()
 	allowed, _ := rl.globalLimit.AddRequest()
 	rl.globalMutex.Unlock()
 
 	if !allowed {
+		atomic.AddInt64(&rl.globalLimitedCount, 1)
 		return false, "全局查询速率限制"
 	}
 
@@ -347,19 +389,88 @@ func (rl *DNSRateLimiter) CheckAndLimit(clientIP string) (bool, string) {
 	allowed, banned := counter.AddRequest()
 	if !allowed {
 		if banned {
+			atomic.AddInt64(&rl.bannedCount, 1)
+			GetEventManager().EmitWithCooldown(&Event{
+				Type:     EventRateLimitBan,
+				Severity: SeverityWarning,
+				Source:   "security",
+				Subject:  clientIP,
+				Message:  fmt.Sprintf("客户端 %s 超出查询速率限制被临时封禁", clientIP),
+				Details: map[string]interface{}{
+					"limitPerMinute": rl.maxQueriesPerMinuteIP,
+				},
+			})
 			return false, "IP已被临时封禁"
 		}
+		atomic.AddInt64(&rl.ipLimitedCount, 1)
 		return false, "IP查询速率限制"
 	}
 
 	return true, "通过速率限制检查"
 }
 
+// Quarantine 隔离客户端，隔离期内该客户端的所有查询被拒绝
+// 客户端已被隔离时延长到较晚的解除时间
+func (rl *DNSRateLimiter) Quarantine(clientIP string, duration time.Duration) {
+	until := time.Now().Add(duration)
+
+	rl.quarantineMutex.Lock()
+	defer rl.quarantineMutex.Unlock()
+	if current, ok := rl.quarantined[clientIP]; !ok || until.After(current) {
+		rl.quarantined[clientIP] = until
+	}
+}
+
+// ReleaseQuarantine 解除客户端隔离，客户端未被隔离时返回false
+func (rl *DNSRateLimiter) ReleaseQuarantine(clientIP string) bool {
+	rl.quarantineMutex.Lock()
+	defer rl.quarantineMutex.Unlock()
+
+	until, ok := rl.quarantined[clientIP]
+	delete(rl.quarantined, clientIP)
+	return ok && time.Now().Before(until)
+}
+
+// IsQuarantined 检查客户端是否处于隔离期
+func (rl *DNSRateLimiter) IsQuarantined(clientIP string) bool {
+	rl.quarantineMutex.RLock()
+	until, ok := rl.quarantined[clientIP]
+	rl.quarantineMutex.RUnlock()
+	return ok && time.Now().Before(until)
+}
+
+// GetQuarantinedClients 获取处于隔离期的客户端列表
+func (rl *DNSRateLimiter) GetQuarantinedClients() []QuarantinedClient {
+	now := time.Now()
+
+	rl.quarantineMutex.RLock()
+	defer rl.quarantineMutex.RUnlock()
+
+	clients := make([]QuarantinedClient, 0, len(rl.quarantined))
+	for ip, until := range rl.quarantined {
+		if now.Before(until) {
+			clients = append(clients, QuarantinedClient{ClientIP: ip, Until: until})
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Until.Before(clients[j].Until)
+	})
+	return clients
+}
+
 // CleanupExpired 清理过期的限制计数器
 func (rl *DNSRateLimiter) CleanupExpired() {
 	now := time.Now()
 	cutoff := now.Add(-time.Hour)
 
+	rl.quarantineMutex.Lock()
+	for ip, until := range rl.quarantined {
+		if !now.Before(until) {
+			delete(rl.quarantined, ip)
+		}
+	}
+	rl.quarantineMutex.Unlock()
+
 	// 遍历所有分片进行清理
 	for i := 0; i < rl.shardCount; i++ {
 		rl.ipMutexes[i].Lock()
@@ -407,11 +518,19 @@ func (rl *DNSRateLimiter) SetLimits(ipLimit, globalLimit int, banDuration time.D
 
 // GetStats 获取速率限制统计信息
 func (rl *DNSRateLimiter) GetStats() map[string]interface{} {
-	// 统计所有分片中的IP数量
+	// 统计所有分片中的IP数量和处于封禁状态的IP数量
 	ipCount := 0
+	bannedIPs := 0
 	for i := 0; i < rl.shardCount; i++ {
 		rl.ipMutexes[i].RLock()
 		ipCount += len(rl.ipLimits[i])
+		for _, counter := range rl.ipLimits[i] {
+			counter.mutex.Lock()
+			if counter.maxFailures > 0 && counter.failCount >= counter.maxFailures {
+				bannedIPs++
+			}
+			counter.mutex.Unlock()
+		}
 		rl.ipMutexes[i].RUnlock()
 	}
 
@@ -419,12 +538,20 @@ func (rl *DNSRateLimiter) GetStats() map[string]interface{} {
 	globalRequests := len(rl.globalLimit.requests)
 	rl.globalMutex.Unlock()
 
+	quarantinedIPs := len(rl.GetQuarantinedClients())
+
 	return map[string]interface{}{
 		"ip_limit_count":     ipCount,
 		"global_requests":    globalRequests,
 		"max_queries_ip":     rl.maxQueriesPerMinuteIP,
 		"max_queries_global": rl.maxQueriesPerMinuteGlobal,
 		"ban_duration":       rl.banDuration,
+		"banned_ips":         bannedIPs,
+		"global_limited":     atomic.LoadInt64(&rl.globalLimitedCount),
+		"ip_limited":         atomic.LoadInt64(&rl.ipLimitedCount),
+		"banned_rejections":  atomic.LoadInt64(&rl.bannedCount),
+		"quarantined_ips":    quarantinedIPs,
+		"quarantined":        atomic.LoadInt64(&rl.quarantinedCount),
 	}
 }
 
@@ -462,6 +589,21 @@ func (sm *SecurityManager) CheckRateLimit(clientIP string) (bool, string) {
 	return sm.rateLimiter.CheckAndLimit(clientIP)
 }
 
+// Quarantine 隔离客户端
+func (sm *SecurityManager) Quarantine(clientIP string, duration time.Duration) {
+	sm.rateLimiter.Quarantine(clientIP, duration)
+}
+
+// ReleaseQuarantine 解除客户端隔离
+func (sm *SecurityManager) ReleaseQuarantine(clientIP string) bool {
+	return sm.rateLimiter.ReleaseQuarantine(clientIP)
+}
+
+// GetQuarantinedClients 获取处于隔离期的客户端列表
+func (sm *SecurityManager) GetQuarantinedClients() []QuarantinedClient {
+	return sm.rateLimiter.GetQuarantinedClients()
+}
+
 // GetStats 获取安全统计信息
 func (sm *SecurityManager) GetStats() map[string]interface{} {
 	return sm.rateLimiter.GetStats()
@@ -0,0 +1,233 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/server_health_history.go
+
+package sdns
+
+import (
+	"time"
+
+	"SteadyDNS/core/database"
+)
+
+// ServerAvailability 上游服务器在时间范围内的可用性报告
+type ServerAvailability struct {
+	database.ServerAvailabilitySummary
+	Group        string  `json:"group"`        // 所属转发组
+	Availability float64 `json:"availability"` // 可用率（百分比，按成功查询数/查询数计算）
+	BrokenRatio  float64 `json:"brokenRatio"`  // 处于熔断状态的快照占比（百分比）
+}
+
+// ServerHealthTrend 上游服务器健康趋势数据
+// 每个切片长度与TimeLabels一致，Snapshots为0表示该时间段内无数据
+type ServerHealthTrend struct {
+	Server       string    `json:"server"`
+	TimeLabels   []string  `json:"timeLabels"`
+	Queries      []int64   `json:"queries"`
+	Availability []float64 `json:"availability"`
+	AvgLatency   []float64 `json:"avgLatency"`
+	P50Latency   []float64 `json:"p50Latency"`
+	P90Latency   []float64 `json:"p90Latency"`
+	P99Latency   []float64 `json:"p99Latency"`
+	CircuitTrips []int64   `json:"circuitTrips"`
+	Snapshots    []int     `json:"snapshots"`
+}
+
+// timeRangeDuration 将时间范围参数转换为时长，未知参数按1h处理
+func timeRangeDuration(timeRange string) time.Duration {
+	switch timeRange {
+	case "1h":
+		return time.Hour
+	case "6h":
+		return 6 * time.Hour
+	case "24h":
+		return 24 * time.Hour
+	case "7d":
+		return 7 * 24 * time.Hour
+	case "30d":
+		return 30 * 24 * time.Hour
+	case "1y":
+		return 365 * 24 * time.Hour
+	default:
+		return time.Hour
+	}
+}
+
+// timeRangeLabelFormat 获取时间范围对应的时间标签格式
+func timeRangeLabelFormat(timeRange string) string {
+	switch timeRange {
+	case "7d", "30d":
+		return "01-02 15:04"
+	case "1y":
+		return "2006-01-02"
+	default:
+		return "15:04"
+	}
+}
+
+// persistServerHealth 持久化上游服务器健康快照
+// 与QPS历史共享持久化周期和保留天数
+func (sm *StatsManager) persistServerHealth() {
+	if GlobalDNSForwarder == nil {
+		return
+	}
+
+	records := GlobalDNSForwarder.CollectServerHealthSnapshots(time.Now())
+	if len(records) == 0 {
+		return
+	}
+
+	if err := database.SaveServerHealthHistoryBatch(records); err != nil {
+		sm.logger.Error("持久化服务器健康历史数据失败: %v", err)
+		return
+	}
+	sm.logger.Debug("持久化了 %d 条服务器健康历史记录", len(records))
+}
+
+// GetServerAvailability 获取时间范围内所有上游服务器的可用性报告
+// 参数：
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//
+// 返回：
+//   - []ServerAvailability: 按服务器地址排序的可用性报告
+//   - error: 查询失败时返回错误
+func (sm *StatsManager) GetServerAvailability(timeRange string) ([]ServerAvailability, error) {
+	now := time.Now()
+	summaries, err := database.GetServerAvailabilitySummary(now.Add(-timeRangeDuration(timeRange)), now)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ServerAvailability, 0, len(summaries))
+	for _, summary := range summaries {
+		item := ServerAvailability{
+			ServerAvailabilitySummary: summary,
+			Group:                     "Default",
+		}
+		item.AvgLatency = round2(summary.AvgLatency)
+		item.P50Latency = round2(summary.P50Latency)
+		item.P90Latency = round2(summary.P90Latency)
+		item.P99Latency = round2(summary.P99Latency)
+
+		if summary.Queries > 0 {
+			item.Availability = round2(float64(summary.SuccessfulQueries) / float64(summary.Queries) * 100)
+		} else if summary.BrokenSnapshots == 0 {
+			item.Availability = 100
+		}
+		if summary.Snapshots > 0 {
+			item.BrokenRatio = round2(float64(summary.BrokenSnapshots) / float64(summary.Snapshots) * 100)
+		}
+		if GlobalDNSForwarder != nil {
+			item.Group = GlobalDNSForwarder.getServerGroupDomain(summary.ServerAddress)
+		}
+
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// GetServerHealthTrend 获取指定上游服务器的健康趋势
+// 参数：
+//   - address: 服务器地址（host:port）
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//   - points: 数据点数量，<=0时默认12
+//
+// 返回：
+//   - *ServerHealthTrend: 按时间段聚合的趋势数据
+//   - error: 查询失败时返回错误
+func (sm *StatsManager) GetServerHealthTrend(address, timeRange string, points int) (*ServerHealthTrend, error) {
+	now := time.Now()
+	totalDuration := timeRangeDuration(timeRange)
+
+	records, err := database.GetServerHealthHistoryByTimeRange(address, now.Add(-totalDuration), now)
+	if err != nil {
+		return nil, err
+	}
+
+	if points <= 0 {
+		points = 12
+	}
+
+	trend := &ServerHealthTrend{
+		Server:       address,
+		TimeLabels:   make([]string, 0, points),
+		Queries:      make([]int64, 0, points),
+		Availability: make([]float64, 0, points),
+		AvgLatency:   make([]float64, 0, points),
+		P50Latency:   make([]float64, 0, points),
+		P90Latency:   make([]float64, 0, points),
+		P99Latency:   make([]float64, 0, points),
+		CircuitTrips: make([]int64, 0, points),
+		Snapshots:    make([]int, 0, points),
+	}
+
+	timeFormat := timeRangeLabelFormat(timeRange)
+	interval := totalDuration / time.Duration(points)
+	for i := 0; i < points; i++ {
+		slotStart := now.Add(-totalDuration + time.Duration(i)*interval)
+		slotEnd := slotStart.Add(interval)
+
+		var queries, successful, trips int64
+		var avgSum, p50Sum, p90Sum, p99Sum float64
+		var snapshots, broken int
+		for _, r := range records {
+			if !r.Timestamp.After(slotStart) || r.Timestamp.After(slotEnd) {
+				continue
+			}
+			snapshots++
+			queries += r.Queries
+			successful += r.SuccessfulQueries
+			trips += r.CircuitTrips
+			if r.CircuitBroken {
+				broken++
+			}
+			// 延迟按成功查询数加权
+			weight := float64(r.SuccessfulQueries)
+			avgSum += r.AvgLatency * weight
+			p50Sum += r.P50Latency * weight
+			p90Sum += r.P90Latency * weight
+			p99Sum += r.P99Latency * weight
+		}
+
+		var availability, avgLatency, p50, p90, p99 float64
+		if queries > 0 {
+			availability = round2(float64(successful) / float64(queries) * 100)
+		} else if snapshots > 0 && broken == 0 {
+			availability = 100
+		}
+		if successful > 0 {
+			avgLatency = round2(avgSum / float64(successful))
+			p50 = round2(p50Sum / float64(successful))
+			p90 = round2(p90Sum / float64(successful))
+			p99 = round2(p99Sum / float64(successful))
+		}
+
+		trend.TimeLabels = append(trend.TimeLabels, slotStart.Format(timeFormat))
+		trend.Queries = append(trend.Queries, queries)
+		trend.Availability = append(trend.Availability, availability)
+		trend.AvgLatency = append(trend.AvgLatency, avgLatency)
+		trend.P50Latency = append(trend.P50Latency, p50)
+		trend.P90Latency = append(trend.P90Latency, p90)
+		trend.P99Latency = append(trend.P99Latency, p99)
+		trend.CircuitTrips = append(trend.CircuitTrips, trips)
+		trend.Snapshots = append(trend.Snapshots, snapshots)
+	}
+
+	return trend, nil
+}
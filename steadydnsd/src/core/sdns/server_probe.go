@@ -228,6 +228,8 @@ type ServerCapabilityProber struct {
 	rateLimiter *ProbeRateLimiter
 	// tcpPool TCP连接池，用于保存探测成功的连接
 	tcpPool *TCPConnectionPool
+	// sourceResolver 出站源地址解析器，为nil时由系统选择源地址
+	sourceResolver *SourceAddressResolver
 }
 
 // ProbeRateLimiter 探测速率限制器
@@ -331,6 +333,11 @@ func NewServerCapabilityProber(workers int, logger Logger, tcpPool *TCPConnectio
 	return prober
 }
 
+// SetSourceResolver 设置出站源地址解析器
+func (p *ServerCapabilityProber) SetSourceResolver(resolver *SourceAddressResolver) {
+	p.sourceResolver = resolver
+}
+
 // probeWorker 探测工作协程
 func (p *ServerCapabilityProber) probeWorker(id int) {
 	defer p.wg.Done()
@@ -393,11 +400,12 @@ func (p *ServerCapabilityProber) performProbe(serverAddr string) {
 func (p *ServerCapabilityProber) probeTCPCapability(serverAddr string) (ServerCapability, ProbeResult, time.Duration) {
 	caps := CapabilityNone
 
-	// 解析地址
-	host, port, err := net.SplitHostPort(serverAddr)
+	// 解析地址，连接标识中的源地址用于拨号
+	serverHost, source := SplitUpstreamEndpoint(serverAddr)
+	host, port, err := net.SplitHostPort(serverHost)
 	if err != nil {
 		// 如果没有端口，使用默认DNS端口
-		host = serverAddr
+		host = serverHost
 		port = "53"
 	}
 
@@ -406,8 +414,10 @@ func (p *ServerCapabilityProber) probeTCPCapability(serverAddr string) (ServerCa
 	// 尝试建立TCP连接
 	startTime := time.Now()
 
-	dialer := &net.Dialer{
-		Timeout: TCPProbeTimeout,
+	dialer, err := p.sourceResolver.NewDialer("tcp", UpstreamEndpoint(addr, source), TCPProbeTimeout)
+	if err != nil {
+		p.logger.Debug("解析源地址失败 %s: %v", addr, err)
+		return caps, ProbeResultFailed, time.Since(startTime)
 	}
 
 	conn, err := dialer.Dial("tcp", addr)
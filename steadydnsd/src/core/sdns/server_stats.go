@@ -21,6 +21,7 @@ package sdns
 
 import (
 	"net"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -57,9 +58,111 @@ type ServerStats struct {
 	WindowSize    int    // 滑动窗口大小
 
 	// 熔断状态相关字段
-	CircuitBroken    bool // 是否处于熔断状态
-	ProbeMode        bool // 是否处于主动探测模式
-	ConsecutiveFails int  // 连续失败次数
+	CircuitBroken     bool  // 是否处于熔断状态
+	ProbeMode         bool  // 是否处于主动探测模式
+	ConsecutiveFails  int   // 连续失败次数
+	CircuitTrips      int64 // 累计触发熔断次数
+	CircuitRecoveries int64 // 累计熔断恢复次数
+
+	// 响应防伪造校验相关字段
+	SpoofDetections int64     // 累计未通过校验的响应次数（含0x20大小写不匹配）
+	CaseMismatches  int64     // 累计0x20查询名大小写不匹配次数
+	LastSpoofTime   time.Time // 最近一次检测到疑似伪造响应的时间
+
+	// 健康历史快照相关字段
+	LatencySamples     []float64           // 自上次快照以来成功查询的延迟样本（毫秒）
+	latencySampleIndex int                 // 延迟样本环形缓冲区写入位置
+	lastSnapshot       serverStatsSnapshot // 上次快照时的累计计数
+}
+
+// MaxLatencySamples 每个快照周期内保留的最大延迟样本数
+const MaxLatencySamples = 4096
+
+// serverStatsSnapshot 上次快照时的累计计数，用于计算周期增量
+type serverStatsSnapshot struct {
+	queries           int64
+	successfulQueries int64
+	failedQueries     int64
+	totalResponseTime time.Duration
+	circuitTrips      int64
+	circuitRecoveries int64
+}
+
+// recordLatencySampleLocked 记录一次成功查询的延迟样本
+// 调用方必须持有stats.Mu写锁，样本数达到上限后以环形方式覆盖
+func (s *ServerStats) recordLatencySampleLocked(latencyMs float64) {
+	if len(s.LatencySamples) < MaxLatencySamples {
+		s.LatencySamples = append(s.LatencySamples, latencyMs)
+		return
+	}
+	s.LatencySamples[s.latencySampleIndex] = latencyMs
+	s.latencySampleIndex = (s.latencySampleIndex + 1) % MaxLatencySamples
+}
+
+// TakeHealthSnapshot 生成自上次快照以来的健康统计增量，并重置延迟样本
+// 参数：
+//   - now: 快照时间
+//
+// 返回：
+//   - database.ServerHealthHistory: 可直接持久化的快照记录
+func (s *ServerStats) TakeHealthSnapshot(now time.Time) database.ServerHealthHistory {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	record := database.ServerHealthHistory{
+		Timestamp:         now,
+		ServerAddress:     s.Address,
+		Queries:           s.Queries - s.lastSnapshot.queries,
+		SuccessfulQueries: s.SuccessfulQueries - s.lastSnapshot.successfulQueries,
+		FailedQueries:     s.FailedQueries - s.lastSnapshot.failedQueries,
+		EWMAScore:         round2(s.EWMAScore),
+		Status:            s.Status,
+		CircuitBroken:     s.CircuitBroken,
+		CircuitTrips:      s.CircuitTrips - s.lastSnapshot.circuitTrips,
+		CircuitRecoveries: s.CircuitRecoveries - s.lastSnapshot.circuitRecoveries,
+	}
+
+	if record.SuccessfulQueries > 0 {
+		intervalTime := s.TotalResponseTime - s.lastSnapshot.totalResponseTime
+		record.AvgLatency = round2(float64(intervalTime.Microseconds()) / 1000 / float64(record.SuccessfulQueries))
+	}
+
+	if len(s.LatencySamples) > 0 {
+		sorted := make([]float64, len(s.LatencySamples))
+		copy(sorted, s.LatencySamples)
+		sort.Float64s(sorted)
+		record.P50Latency = latencyPercentile(sorted, 50)
+		record.P90Latency = latencyPercentile(sorted, 90)
+		record.P99Latency = latencyPercentile(sorted, 99)
+	}
+
+	s.lastSnapshot = serverStatsSnapshot{
+		queries:           s.Queries,
+		successfulQueries: s.SuccessfulQueries,
+		failedQueries:     s.FailedQueries,
+		totalResponseTime: s.TotalResponseTime,
+		circuitTrips:      s.CircuitTrips,
+		circuitRecoveries: s.CircuitRecoveries,
+	}
+	s.LatencySamples = s.LatencySamples[:0]
+	s.latencySampleIndex = 0
+
+	return record
+}
+
+// latencyPercentile 使用最近秩法计算已排序样本的百分位值
+func latencyPercentile(sorted []float64, percentile float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(percentile/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
 }
 
 // UpdateServerStats 更新服务器统计信息
@@ -155,6 +258,23 @@ func (f *DNSForwarder) CleanupServerStats(activeServers []database.DNSServer) {
 	}
 }
 
+// CollectServerHealthSnapshots 为所有上游服务器生成健康快照
+// 跳过自上次快照以来没有任何查询、熔断变化且未处于熔断状态的服务器
+func (f *DNSForwarder) CollectServerHealthSnapshots(now time.Time) []database.ServerHealthHistory {
+	statsList := f.GetAllServerStats()
+
+	records := make([]database.ServerHealthHistory, 0, len(statsList))
+	for _, stats := range statsList {
+		record := stats.TakeHealthSnapshot(now)
+		if record.Queries == 0 && record.CircuitTrips == 0 && record.CircuitRecoveries == 0 && !record.CircuitBroken {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
 // getOrCreateServerStats 获取或创建服务器统计信息
 func (f *DNSForwarder) getOrCreateServerStats(addr string) *ServerStats {
 	f.statsMu.Lock()
@@ -0,0 +1,182 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/source_addr.go
+
+package sdns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointSourceSep 上游连接标识中服务器地址与源地址的分隔符
+const endpointSourceSep = "@"
+
+// UpstreamEndpoint 返回上游连接标识，由服务器地址和源地址组成
+// 同一服务器在不同转发组中使用不同源地址时，连接池、Cookie和能力探测状态按该标识分别维护
+func UpstreamEndpoint(serverAddr, source string) string {
+	if source == "" {
+		return serverAddr
+	}
+	return serverAddr + endpointSourceSep + source
+}
+
+// SplitUpstreamEndpoint 将上游连接标识拆分为服务器地址和源地址，未指定源地址时source为空
+func SplitUpstreamEndpoint(endpoint string) (serverAddr, source string) {
+	serverAddr, source, _ = strings.Cut(endpoint, endpointSourceSep)
+	return serverAddr, source
+}
+
+// SourceAddressResolver 出站查询源地址解析器
+// 记录当前配置中绑定了源地址的上游连接标识，供UDP、TCP、TCP连接池和能力探测器使用
+type SourceAddressResolver struct {
+	mu        sync.RWMutex
+	endpoints map[string]bool // 绑定了源地址的上游连接标识
+}
+
+// NewSourceAddressResolver 创建源地址解析器
+func NewSourceAddressResolver() *SourceAddressResolver {
+	return &SourceAddressResolver{
+		endpoints: make(map[string]bool),
+	}
+}
+
+// SetEndpoints 替换当前绑定了源地址的上游连接标识
+//
+// 返回:
+//   - []string: 不再使用的连接标识，调用方需要关闭这些标识的已有连接
+func (r *SourceAddressResolver) SetEndpoints(endpoints map[string]bool) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var removed []string
+	for endpoint := range r.endpoints {
+		if !endpoints[endpoint] {
+			removed = append(removed, endpoint)
+		}
+	}
+
+	r.endpoints = endpoints
+	return removed
+}
+
+// LocalIP 获取通过指定上游连接标识访问服务器时应使用的本地IP
+//
+// 返回:
+//   - net.IP: 本地IP，未绑定源地址时返回nil
+//   - error: 源地址无法解析或与服务器地址族不匹配时返回错误
+func (r *SourceAddressResolver) LocalIP(endpoint string) (net.IP, error) {
+	serverAddr, source := SplitUpstreamEndpoint(endpoint)
+	if source == "" {
+		return nil, nil
+	}
+
+	host, _, err := net.SplitHostPort(serverAddr)
+	if err != nil {
+		host = serverAddr
+	}
+	remoteIP := net.ParseIP(host)
+	if remoteIP == nil {
+		return nil, fmt.Errorf("无效的服务器地址: %s", serverAddr)
+	}
+
+	return ResolveSourceIP(source, remoteIP.To4() == nil)
+}
+
+// NewDialer 创建访问指定服务器的拨号器，连接标识带源地址时设置LocalAddr
+//
+// 参数:
+//   - network: 网络类型（udp或tcp）
+//   - endpoint: 上游连接标识，拨号地址需通过SplitUpstreamEndpoint获取
+//   - timeout: 连接超时时间
+//
+// 返回:
+//   - *net.Dialer: 拨号器
+//   - error: 源地址解析失败时返回错误
+func (r *SourceAddressResolver) NewDialer(network, endpoint string, timeout time.Duration) (*net.Dialer, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	ip, err := r.LocalIP(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if ip == nil {
+		return dialer, nil
+	}
+
+	switch network {
+	case "udp", "udp4", "udp6":
+		dialer.LocalAddr = &net.UDPAddr{IP: ip}
+	default:
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	return dialer, nil
+}
+
+// ResolveSourceIP 将源地址配置解析为本地IP
+//
+// 参数:
+//   - source: 源IP地址或网卡名
+//   - ipv6: 上游服务器是否为IPv6地址
+//
+// 返回:
+//   - net.IP: 与上游服务器地址族一致的本地IP
+//   - error: 解析失败时返回错误
+func ResolveSourceIP(source string, ipv6 bool) (net.IP, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		if (ip.To4() == nil) != ipv6 {
+			return nil, fmt.Errorf("源地址 %s 与上游服务器地址族不一致", source)
+		}
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("网卡 %s 不存在: %v", source, err)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("获取网卡 %s 地址失败: %v", source, err)
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if (ip.To4() == nil) != ipv6 {
+			continue
+		}
+		// 链路本地地址需要指定zone，不适合作为转发查询的源地址
+		if ip.IsLinkLocalUnicast() {
+			continue
+		}
+		return ip, nil
+	}
+
+	family := "IPv4"
+	if ipv6 {
+		family = "IPv6"
+	}
+	return nil, fmt.Errorf("网卡 %s 没有可用的%s地址", source, family)
+}
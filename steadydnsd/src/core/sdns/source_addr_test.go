@@ -0,0 +1,130 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/source_addr_test.go
+// 出站查询源地址解析器测试
+
+package sdns
+
+import (
+	"net"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestResolveSourceIP 测试源地址解析
+func TestResolveSourceIP(t *testing.T) {
+	t.Run("IP地址", func(t *testing.T) {
+		ip, err := ResolveSourceIP("127.0.0.1", false)
+		if err != nil || !ip.Equal(net.ParseIP("127.0.0.1")) {
+			t.Errorf("ResolveSourceIP() = %v, %v", ip, err)
+		}
+	})
+
+	t.Run("地址族不一致", func(t *testing.T) {
+		if _, err := ResolveSourceIP("127.0.0.1", true); err == nil {
+			t.Errorf("IPv4源地址访问IPv6服务器应返回错误")
+		}
+	})
+
+	t.Run("不存在的网卡", func(t *testing.T) {
+		if _, err := ResolveSourceIP("nonexistent0", false); err == nil {
+			t.Errorf("不存在的网卡应返回错误")
+		}
+	})
+
+	t.Run("回环网卡", func(t *testing.T) {
+		iface, err := net.InterfaceByName("lo")
+		if err != nil {
+			t.Skip("系统没有lo网卡")
+		}
+		ip, err := ResolveSourceIP(iface.Name, false)
+		if err != nil {
+			t.Fatalf("ResolveSourceIP() error = %v", err)
+		}
+		if !ip.IsLoopback() {
+			t.Errorf("ResolveSourceIP() = %v, want loopback", ip)
+		}
+	})
+}
+
+// TestUpstreamEndpoint 测试上游连接标识的生成和拆分
+func TestUpstreamEndpoint(t *testing.T) {
+	if got := UpstreamEndpoint("8.8.8.8:53", ""); got != "8.8.8.8:53" {
+		t.Errorf("未指定源地址时连接标识应为服务器地址: %s", got)
+	}
+	a := UpstreamEndpoint("8.8.8.8:53", "eth0")
+	b := UpstreamEndpoint("8.8.8.8:53", "eth1")
+	if a == b {
+		t.Errorf("同一服务器使用不同源地址时连接标识应不同: %s", a)
+	}
+	addr, source := SplitUpstreamEndpoint(UpstreamEndpoint("[2001:db8::1]:53", "2001:db8::2"))
+	if addr != "[2001:db8::1]:53" || source != "2001:db8::2" {
+		t.Errorf("SplitUpstreamEndpoint() = %s, %s", addr, source)
+	}
+}
+
+// TestSourceAddressResolver 测试连接标识更新和拨号器创建
+func TestSourceAddressResolver(t *testing.T) {
+	r := NewSourceAddressResolver()
+
+	first := UpstreamEndpoint("127.0.0.1:53", "127.0.0.1")
+	second := UpstreamEndpoint("8.8.8.8:53", "eth0")
+	if removed := r.SetEndpoints(map[string]bool{first: true, second: true}); len(removed) != 0 {
+		t.Errorf("removed = %v, want none", removed)
+	}
+
+	removed := r.SetEndpoints(map[string]bool{first: true, UpstreamEndpoint("1.1.1.1:53", "127.0.0.1"): true})
+	sort.Strings(removed)
+	if len(removed) != 1 || removed[0] != second {
+		t.Errorf("removed = %v, want [%s]", removed, second)
+	}
+
+	dialer, err := r.NewDialer("udp", first, time.Second)
+	if err != nil {
+		t.Fatalf("NewDialer() error = %v", err)
+	}
+	if addr, ok := dialer.LocalAddr.(*net.UDPAddr); !ok || !addr.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("LocalAddr = %v, want 127.0.0.1", dialer.LocalAddr)
+	}
+
+	dialer, err = r.NewDialer("tcp", "127.0.0.1:53", time.Second)
+	if err != nil || dialer.LocalAddr != nil {
+		t.Errorf("不带源地址的连接标识不应设置LocalAddr: %v, %v", dialer, err)
+	}
+
+	var nilResolver *SourceAddressResolver
+	if dialer, err := nilResolver.NewDialer("tcp", "9.9.9.9:53", time.Second); err != nil || dialer.LocalAddr != nil {
+		t.Errorf("nil解析器应返回默认拨号器: %v, %v", dialer, err)
+	}
+}
+
+// TestForwardTaskSourcePerGroup 测试同一服务器在不同转发组中使用各自的源地址
+func TestForwardTaskSourcePerGroup(t *testing.T) {
+	f := &DNSForwarder{groups: map[string]*ForwardGroup{
+		"a.example": {Name: "a.example", PriorityQueues: map[int][]*DNSServer{1: {{Address: "192.0.2.53", Port: 53, SourceAddress: "10.0.0.1"}}}},
+		"b.example": {Name: "b.example", PriorityQueues: map[int][]*DNSServer{1: {{Address: "192.0.2.53", Port: 53, SourceAddress: "10.0.0.2"}}}},
+		"c.example": {Name: "c.example", PriorityQueues: map[int][]*DNSServer{1: {{Address: "192.0.2.53", Port: 53}}}},
+	}}
+	want := map[string]string{"a.example": "10.0.0.1", "b.example": "10.0.0.2", "c.example": ""}
+	for group, source := range want {
+		if got := f.serverSourceAddress(group, "192.0.2.53:53"); got != source {
+			t.Errorf("转发组 %s 的源地址 = %q, want %q", group, got, source)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/stats_rollup.go
+// 统计数据分层汇总：原始数据 → 1分钟 → 1小时 → 1天
+
+package sdns
+
+import (
+	"time"
+
+	"SteadyDNS/core/database"
+)
+
+const (
+	// rollupDelay 原始数据按持久化周期批量写入，分钟汇总滞后该时长以免遗漏尚未写入的样本
+	rollupDelay = 2 * time.Minute
+	// rollupMaxSpan 单次汇总处理的最大时间跨度，避免首次运行时一次读取过多原始数据
+	rollupMaxSpan = 24 * time.Hour
+)
+
+// rollupTierSpec 汇总层级的时间桶划分
+type rollupTierSpec struct {
+	tier     string
+	truncate func(time.Time) time.Time // 时间所在桶的起始时间
+	next     func(time.Time) time.Time // 下一个桶的起始时间
+}
+
+// 汇总层级，按粒度从细到粗排列
+var rollupTiers = []rollupTierSpec{
+	{
+		tier:     database.RollupTierMinute,
+		truncate: func(t time.Time) time.Time { return t.Truncate(time.Minute) },
+		next:     func(t time.Time) time.Time { return t.Add(time.Minute) },
+	},
+	{
+		tier: database.RollupTierHour,
+		truncate: func(t time.Time) time.Time {
+			y, m, d := t.Date()
+			return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+		},
+		next: func(t time.Time) time.Time { return t.Add(time.Hour) },
+	},
+	{
+		tier: database.RollupTierDay,
+		truncate: func(t time.Time) time.Time {
+			y, m, d := t.Date()
+			return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+		},
+		next: func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+	},
+}
+
+// rollupTierForRange 获取时间范围应读取的汇总层级
+// 24小时及以内的范围读取原始数据，返回空字符串
+func rollupTierForRange(timeRange string) string {
+	switch timeRange {
+	case "7d":
+		return database.RollupTierMinute
+	case "30d":
+		return database.RollupTierHour
+	case "1y":
+		return database.RollupTierDay
+	default:
+		return ""
+	}
+}
+
+// rollupAccumulator 单个时间桶的汇总值
+type rollupAccumulator struct {
+	min, max, sum float64
+	samples       int64
+}
+
+// add 加入一个原始样本
+func (a *rollupAccumulator) add(v float64) {
+	if a.samples == 0 || v < a.min {
+		a.min = v
+	}
+	if a.samples == 0 || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.samples++
+}
+
+// merge 加入一个下级汇总记录，平均值按样本数加权
+func (a *rollupAccumulator) merge(r database.StatsRollup) {
+	if r.Samples <= 0 {
+		return
+	}
+	if a.samples == 0 || r.Min < a.min {
+		a.min = r.Min
+	}
+	if a.samples == 0 || r.Max > a.max {
+		a.max = r.Max
+	}
+	a.sum += r.Avg * float64(r.Samples)
+	a.samples += r.Samples
+}
+
+// rollupBucketKey 汇总时间桶的键
+type rollupBucketKey struct {
+	metric string
+	bucket int64 // 桶起始时间（Unix秒）
+}
+
+// rollupBuckets 按指标和时间桶累计的汇总值
+type rollupBuckets map[rollupBucketKey]*rollupAccumulator
+
+// get 获取或创建时间桶
+func (b rollupBuckets) get(metric string, bucket time.Time) *rollupAccumulator {
+	key := rollupBucketKey{metric: metric, bucket: bucket.Unix()}
+	acc, ok := b[key]
+	if !ok {
+		acc = &rollupAccumulator{}
+		b[key] = acc
+	}
+	return acc
+}
+
+// records 转换为汇总记录
+func (b rollupBuckets) records(tier string) []database.StatsRollup {
+	records := make([]database.StatsRollup, 0, len(b))
+	for key, acc := range b {
+		records = append(records, database.StatsRollup{
+			Tier:      tier,
+			Metric:    key.metric,
+			Timestamp: time.Unix(key.bucket, 0),
+			Min:       acc.min,
+			Avg:       acc.sum / float64(acc.samples),
+			Max:       acc.max,
+			Samples:   acc.samples,
+		})
+	}
+	return records
+}
+
+// rollupStats 依次生成各层级的汇总数据
+// 单次汇总最多处理rollupMaxSpan，水位线落后较多时（如升级后首次运行）循环追赶到截止时间，
+// 保证原始数据在按保留期清理前已完成汇总
+// 仅由持久化任务调用，水位线不加锁
+func (sm *StatsManager) rollupStats(now time.Time) {
+	end := now.Add(-rollupDelay)
+	for i, spec := range rollupTiers {
+		target := spec.truncate(end)
+		for {
+			reached, err := sm.rollupTier(i, target)
+			if err != nil {
+				sm.logger.Error("生成%s统计汇总失败: %v", spec.tier, err)
+				return
+			}
+			end = reached
+			if reached.IsZero() || !reached.Before(target) {
+				break
+			}
+		}
+	}
+}
+
+// rawRollupComplete 判断保留期之外的原始数据是否都已完成分钟汇总
+// 汇总失败或尚未追赶完成时不清理原始数据，避免丢失历史
+func (sm *StatsManager) rawRollupComplete(now time.Time) bool {
+	watermark, ok := sm.rollupWatermarks[database.RollupTierMinute]
+	if !ok {
+		return false
+	}
+	return !watermark.Before(now.AddDate(0, 0, -sm.rawRetentionDays))
+}
+
+// rollupTier 生成第i个层级在水位线到end之间的汇总数据
+// 返回该层级已汇总到的时间，作为下一层级的截止时间
+func (sm *StatsManager) rollupTier(i int, end time.Time) (time.Time, error) {
+	spec := rollupTiers[i]
+
+	start, ok := sm.rollupWatermarks[spec.tier]
+	if !ok {
+		latest, found, err := database.GetLatestStatsRollupTime(spec.tier)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if found {
+			start = spec.next(latest)
+		} else {
+			// 首次汇总从数据源最早的记录开始
+			var earliest time.Time
+			if i == 0 {
+				earliest, found, err = database.GetEarliestRawStatsTime()
+			} else {
+				earliest, found, err = database.GetEarliestStatsRollupTime(rollupTiers[i-1].tier)
+			}
+			if err != nil {
+				return time.Time{}, err
+			}
+			if !found {
+				return time.Time{}, nil
+			}
+			start = spec.truncate(earliest)
+		}
+	}
+
+	if !start.Before(end) {
+		sm.rollupWatermarks[spec.tier] = start
+		return start, nil
+	}
+	if end.Sub(start) > rollupMaxSpan {
+		end = spec.truncate(start.Add(rollupMaxSpan))
+		if !start.Before(end) {
+			end = spec.next(start)
+		}
+	}
+
+	buckets := make(rollupBuckets)
+	var err error
+	if i == 0 {
+		err = collectRawRollups(buckets, spec, start, end)
+	} else {
+		var rows []database.StatsRollup
+		rows, err = database.GetStatsRollups(rollupTiers[i-1].tier, "", start, end)
+		for _, r := range rows {
+			buckets.get(r.Metric, spec.truncate(r.Timestamp)).merge(r)
+		}
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	records := buckets.records(spec.tier)
+	if err := database.SaveStatsRollups(spec.tier, start, end, records); err != nil {
+		return time.Time{}, err
+	}
+	if len(records) > 0 {
+		sm.logger.Debug("生成了 %d 条%s统计汇总记录", len(records), spec.tier)
+	}
+
+	// 无数据的时间段同样推进水位线
+	sm.rollupWatermarks[spec.tier] = end
+	return end, nil
+}
+
+// collectRawRollups 将[start, end)内的QPS、资源使用和网络流量原始记录按分钟汇总
+func collectRawRollups(buckets rollupBuckets, spec rollupTierSpec, start, end time.Time) error {
+	qps, err := database.GetQPSHistoryByTimeRange(start, end)
+	if err != nil {
+		return err
+	}
+	for _, r := range qps {
+		if r.Timestamp.Before(end) {
+			buckets.get(database.RollupMetricQPS, spec.truncate(r.Timestamp)).add(r.QPS)
+		}
+	}
+
+	resources, err := database.GetResourceHistoryByTimeRange(start, end)
+	if err != nil {
+		return err
+	}
+	for _, r := range resources {
+		if !r.Timestamp.Before(end) {
+			continue
+		}
+		bucket := spec.truncate(r.Timestamp)
+		buckets.get(database.RollupMetricCPU, bucket).add(float64(r.CPU))
+		buckets.get(database.RollupMetricMemory, bucket).add(float64(r.Memory))
+		buckets.get(database.RollupMetricDisk, bucket).add(float64(r.Disk))
+	}
+
+	network, err := database.GetNetworkHistoryByTimeRange(start, end)
+	if err != nil {
+		return err
+	}
+	for _, r := range network {
+		if !r.Timestamp.Before(end) {
+			continue
+		}
+		bucket := spec.truncate(r.Timestamp)
+		buckets.get(database.RollupMetricInboundBps, bucket).add(float64(r.InboundBps))
+		buckets.get(database.RollupMetricOutboundBps, bucket).add(float64(r.OutboundBps))
+	}
+
+	return nil
+}
+
+// getRollupSeries 读取[start, end)内指定指标的汇总数据
+// 从tier层级开始读取，之后尚未汇总到该层级的时间段依次用更细的层级补齐
+// 返回按时间升序排列的记录，以及汇总数据未覆盖部分的起始时间
+func (sm *StatsManager) getRollupSeries(metric, tier string, start, end time.Time) ([]database.StatsRollup, time.Time) {
+	first := -1
+	for i, spec := range rollupTiers {
+		if spec.tier == tier {
+			first = i
+		}
+	}
+
+	var series []database.StatsRollup
+	cursor := start
+	for i := first; i >= 0; i-- {
+		spec := rollupTiers[i]
+		rows, err := database.GetStatsRollups(spec.tier, metric, cursor, end)
+		if err != nil {
+			sm.logger.Warn("读取%s统计汇总失败: %v", spec.tier, err)
+			continue
+		}
+		if len(rows) > 0 {
+			series = append(series, rows...)
+			cursor = spec.next(rows[len(rows)-1].Timestamp)
+		}
+	}
+
+	return series, cursor
+}
+
+// widenRollupExtremes 用汇总记录的最小值和最大值扩展统计范围
+// 长时间范围的数据点是各时间桶的平均值，峰值需从汇总记录中获取
+func widenRollupExtremes(rows []database.StatsRollup, min, max float64) (float64, float64) {
+	for _, r := range rows {
+		if r.Min < min {
+			min = r.Min
+		}
+		if r.Max > max {
+			max = r.Max
+		}
+	}
+	return min, max
+}
+
+// widenResourceStatItem 用汇总记录扩展资源使用统计的最小值和最大值
+func widenResourceStatItem(item *ResourceStatItem, rows []database.StatsRollup) {
+	min, max := widenRollupExtremes(rows, float64(item.Min), float64(item.Max))
+	item.Min, item.Max = int(min), int(max)
+}
+
+// widenNetworkStatItem 用汇总记录扩展网络流量统计的最小值和最大值
+func widenNetworkStatItem(item *NetworkStatItem, rows []database.StatsRollup) {
+	min, max := widenRollupExtremes(rows, float64(item.Min), float64(item.Max))
+	item.Min, item.Max = uint64(min), uint64(max)
+}
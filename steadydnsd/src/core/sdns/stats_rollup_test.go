@@ -0,0 +1,201 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/stats_rollup_test.go
+// 统计数据分层汇总测试
+
+package sdns
+
+import (
+	"testing"
+	"time"
+
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestRollupAccumulatorMerge 测试合并下级汇总时保留极值并按样本数加权平均
+func TestRollupAccumulatorMerge(t *testing.T) {
+	var acc rollupAccumulator
+	acc.merge(database.StatsRollup{Min: 1, Avg: 2, Max: 5, Samples: 3})
+	acc.merge(database.StatsRollup{Min: 0, Avg: 10, Max: 20, Samples: 1})
+	acc.merge(database.StatsRollup{Min: -5, Avg: 100, Max: 100, Samples: 0})
+
+	if acc.min != 0 || acc.max != 20 || acc.samples != 4 {
+		t.Errorf("合并结果 min=%v max=%v samples=%d", acc.min, acc.max, acc.samples)
+	}
+	if avg := acc.sum / float64(acc.samples); avg != 4 {
+		t.Errorf("加权平均值 = %v, want 4", avg)
+	}
+}
+
+// TestRollupTierTruncate 测试各层级的时间桶划分
+func TestRollupTierTruncate(t *testing.T) {
+	ts := time.Date(2026, 3, 8, 13, 47, 29, 0, time.Local)
+	want := []time.Time{
+		time.Date(2026, 3, 8, 13, 47, 0, 0, time.Local),
+		time.Date(2026, 3, 8, 13, 0, 0, 0, time.Local),
+		time.Date(2026, 3, 8, 0, 0, 0, 0, time.Local),
+	}
+	for i, spec := range rollupTiers {
+		if got := spec.truncate(ts); !got.Equal(want[i]) {
+			t.Errorf("%s truncate() = %v, want %v", spec.tier, got, want[i])
+		}
+	}
+	if got := rollupTiers[2].next(want[2]); !got.Equal(time.Date(2026, 3, 9, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("1d next() = %v", got)
+	}
+
+	if rollupTierForRange("24h") != "" || rollupTierForRange("7d") != database.RollupTierMinute ||
+		rollupTierForRange("30d") != database.RollupTierHour || rollupTierForRange("1y") != database.RollupTierDay {
+		t.Errorf("rollupTierForRange() 层级选择错误")
+	}
+}
+
+// TestRollupStats 测试原始数据逐级汇总、重复执行的幂等性和跨层级读取
+func TestRollupStats(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	database.DB = db
+	defer func() {
+		sqlDB, _ := database.DB.DB()
+		sqlDB.Close()
+		database.DB = nil
+	}()
+	if err := db.AutoMigrate(&database.StatsRollup{}, &database.QPSHistory{}, &database.ResourceHistory{}, &database.NetworkHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	// 午夜前后各一小时，每30秒一个QPS样本，前一天包含一个峰值和一个谷值
+	base := time.Date(2026, 3, 8, 0, 0, 0, 0, time.Local)
+	var raw []database.QPSHistory
+	for i := -120; i < 120; i++ {
+		ts := base.Add(time.Duration(i) * 30 * time.Second)
+		qps := 2.0
+		switch ts {
+		case base.Add(-30 * time.Minute):
+			qps = 100
+		case base.Add(-20 * time.Minute):
+			qps = 0
+		}
+		raw = append(raw, database.QPSHistory{Timestamp: ts, QPS: qps})
+	}
+	if err := database.SaveQPSHistoryBatch(raw); err != nil {
+		t.Fatalf("SaveQPSHistoryBatch() error = %v", err)
+	}
+
+	now := base.Add(time.Hour + 5*time.Minute)
+	sm := NewStatsManager(common.NewLogger())
+	sm.rollupStats(now)
+
+	countTier := func(tier string) int64 {
+		var count int64
+		db.Model(&database.StatsRollup{}).Where("tier = ?", tier).Count(&count)
+		return count
+	}
+	if n := countTier(database.RollupTierMinute); n != 120 {
+		t.Errorf("分钟汇总记录数 = %d, want 120", n)
+	}
+	if n := countTier(database.RollupTierHour); n != 2 {
+		t.Errorf("小时汇总记录数 = %d, want 2", n)
+	}
+	if n := countTier(database.RollupTierDay); n != 1 {
+		t.Errorf("天汇总记录数 = %d, want 1", n)
+	}
+
+	days, err := database.GetStatsRollups(database.RollupTierDay, database.RollupMetricQPS, base.AddDate(0, 0, -1), base)
+	if err != nil || len(days) != 1 {
+		t.Fatalf("GetStatsRollups() = %+v, %v", days, err)
+	}
+	day := days[0]
+	if day.Min != 0 || day.Max != 100 || day.Samples != 120 || day.Avg != 2.8 {
+		t.Errorf("天汇总 = %+v，极值或加权平均值错误", day)
+	}
+
+	// 重启后从数据库恢复水位线，重复执行不产生重复记录
+	NewStatsManager(common.NewLogger()).rollupStats(now)
+	sm.rollupStats(now)
+	if n := countTier(database.RollupTierMinute); n != 120 {
+		t.Errorf("重复汇总后分钟记录数 = %d, want 120", n)
+	}
+
+	// 1y范围：天汇总之后依次用小时和分钟汇总补齐
+	series, cursor := sm.getRollupSeries(database.RollupMetricQPS, database.RollupTierDay, base.AddDate(0, 0, -2), now)
+	if len(series) != 2 || series[0].Tier != database.RollupTierDay || series[1].Tier != database.RollupTierHour {
+		t.Fatalf("getRollupSeries() = %+v", series)
+	}
+	if !cursor.Equal(base.Add(time.Hour)) {
+		t.Errorf("未覆盖部分起始时间 = %v, want %v", cursor, base.Add(time.Hour))
+	}
+	if min, max := widenRollupExtremes(series, 2, 2); min != 0 || max != 100 {
+		t.Errorf("widenRollupExtremes() = %v, %v", min, max)
+	}
+}
+
+// TestRollupCatchUpBeforeClean 测试升级后首次运行时先汇总完全部原始数据再按保留期清理
+func TestRollupCatchUpBeforeClean(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	database.DB = db
+	defer func() {
+		sqlDB, _ := database.DB.DB()
+		sqlDB.Close()
+		database.DB = nil
+	}()
+	if err := db.AutoMigrate(&database.StatsRollup{}, &database.QPSHistory{}, &database.ResourceHistory{}, &database.NetworkHistory{}); err != nil {
+		t.Fatalf("迁移表失败: %v", err)
+	}
+
+	// 7天的原始数据，每10分钟一个QPS样本，尚无任何汇总记录
+	now := time.Now()
+	start := now.Add(-7 * 24 * time.Hour).Truncate(time.Minute)
+	var raw []database.QPSHistory
+	for ts := start; ts.Before(now.Add(-rollupDelay - time.Minute)); ts = ts.Add(10 * time.Minute) {
+		raw = append(raw, database.QPSHistory{Timestamp: ts, QPS: 1})
+	}
+	if err := database.SaveQPSHistoryBatch(raw); err != nil {
+		t.Fatalf("SaveQPSHistoryBatch() error = %v", err)
+	}
+
+	// 一次持久化周期：汇总后清理
+	sm := NewStatsManager(common.NewLogger())
+	sm.rollupStats(now)
+	sm.cleanOldDatabaseRecords()
+
+	var minutes int64
+	db.Model(&database.StatsRollup{}).Where("tier = ?", database.RollupTierMinute).Count(&minutes)
+	if minutes != int64(len(raw)) {
+		t.Errorf("分钟汇总记录数 = %d, want %d", minutes, len(raw))
+	}
+	earliest, found, err := database.GetEarliestStatsRollupTime(database.RollupTierMinute)
+	if err != nil || !found || !earliest.Equal(start) {
+		t.Errorf("最早的分钟汇总 = %v, %v, %v, want %v", earliest, found, err, start)
+	}
+
+	var remaining int64
+	db.Model(&database.QPSHistory{}).Where("timestamp < ?", now.AddDate(0, 0, -sm.rawRetentionDays)).Count(&remaining)
+	if remaining != 0 {
+		t.Errorf("汇总完成后仍有 %d 条超出保留期的原始记录", remaining)
+	}
+}
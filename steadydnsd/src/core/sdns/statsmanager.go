@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"math"
 	"os"
-	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,10 +38,15 @@ type StatsManager struct {
 	logger           *common.Logger
 	mutex            sync.RWMutex
 	networkStats     *NetworkStats
-	domainCounters   map[string]int64
-	clientCounters   map[string]int64
+	topDomains       *SpaceSaving                // 热门域名（固定内存）
+	topClients       *SpaceSaving                // 热门客户端（固定内存）
+	uniqueDomains    *HyperLogLog                // 去重域名数估算
+	uniqueClients    *HyperLogLog                // 去重客户端数估算
+	topNWindow       *topNWindow                 // 当前热门项快照窗口
+	dimensionCounts  map[queryDimensionKey]int64 // 按分钟累计的查询维度计数，持久化后移除
 	qpsHistory       []QPSDataPoint
-	latencyData      []int64
+	latencyHists     map[latencyHistogramKey]*LatencyHistogram // 按分钟和分类标签记录的延迟直方图，持久化后移除
+	recentLatency    [recentLatencyMinutes]recentLatencySlot   // 最近几分钟的端到端延迟，用于延迟分布
 	resourceHistory  []ResourceDataPoint
 	networkHistory   []NetworkDataPoint
 	lastCleanupTime  time.Time
@@ -65,6 +69,11 @@ type StatsManager struct {
 	stopPersist     chan struct{}
 	retentionDays   int
 
+	// 分层汇总相关
+	rawRetentionDays    int                  // QPS、资源、网络原始数据保留天数
+	rollupRetentionDays map[string]int       // 各汇总层级保留天数
+	rollupWatermarks    map[string]time.Time // 各汇总层级已处理到的时间
+
 	// 资源监控相关
 	stopResourceMonitor chan struct{}
 
@@ -116,10 +125,14 @@ func NewStatsManager(logger *common.Logger) *StatsManager {
 	return &StatsManager{
 		logger:           logger,
 		networkStats:     &NetworkStats{LastRequestTime: time.Now()},
-		domainCounters:   make(map[string]int64),
-		clientCounters:   make(map[string]int64),
+		topDomains:       NewSpaceSaving(heavyHitterCapacity),
+		topClients:       NewSpaceSaving(heavyHitterCapacity),
+		uniqueDomains:    NewHyperLogLog(),
+		uniqueClients:    NewHyperLogLog(),
+		topNWindow:       newTopNWindow(time.Now()),
+		dimensionCounts:  make(map[queryDimensionKey]int64),
 		qpsHistory:       make([]QPSDataPoint, 0),
-		latencyData:      make([]int64, 0),
+		latencyHists:     make(map[latencyHistogramKey]*LatencyHistogram),
 		resourceHistory:  make([]ResourceDataPoint, 0),
 		networkHistory:   make([]NetworkDataPoint, 0),
 		lastCleanupTime:  time.Now(),
@@ -140,6 +153,15 @@ func NewStatsManager(logger *common.Logger) *StatsManager {
 		stopPersist:     make(chan struct{}),
 		retentionDays:   7,
 
+		// 初始化分层汇总
+		rawRetentionDays: common.GetConfigInt("Database", "STATS_RAW_RETENTION_DAYS", 2),
+		rollupRetentionDays: map[string]int{
+			database.RollupTierMinute: common.GetConfigInt("Database", "STATS_MINUTE_RETENTION_DAYS", 30),
+			database.RollupTierHour:   common.GetConfigInt("Database", "STATS_HOUR_RETENTION_DAYS", 365),
+			database.RollupTierDay:    common.GetConfigInt("Database", "STATS_DAY_RETENTION_DAYS", 1825),
+		},
+		rollupWatermarks: make(map[string]time.Time),
+
 		// 初始化资源监控
 		stopResourceMonitor: make(chan struct{}),
 
@@ -217,15 +239,9 @@ func (sm *StatsManager) asyncCleanup() {
 	}
 	sm.networkHistory = filteredNetwork
 
-	// 限制延迟数据点数量
-	if len(sm.latencyData) > 10000 {
-		sm.latencyData = sm.latencyData[len(sm.latencyData)-10000:]
-	}
-
 	// 定期重置计数器（每天）
 	if now.Sub(sm.lastCleanupTime) > 24*time.Hour {
-		sm.domainCounters = make(map[string]int64)
-		sm.clientCounters = make(map[string]int64)
+		sm.resetTopN()
 	}
 
 	sm.lastCleanupTime = now
@@ -271,23 +287,20 @@ func (sm *StatsManager) calculateQPSAsync() float64 {
 }
 
 // RecordQuery 记录DNS查询
-func (sm *StatsManager) RecordQuery(domain, clientIP string, responseTime time.Duration) {
+// dims为查询类型、响应码、传输协议和缓存结果，按分钟聚合后持久化
+func (sm *StatsManager) RecordQuery(domain, clientIP string, dims QueryDimensions, responseTime time.Duration) {
+	now := time.Now()
 	sm.mutex.Lock()
 	{
-		// 记录域名查询次数
-		sm.domainCounters[domain]++
-
-		// 记录客户端查询次数
-		sm.clientCounters[clientIP]++
+		// 记录域名和客户端查询次数
+		sm.recordTopN(domain, clientIP)
 
-		// 记录响应时间
-		latencyMs := int64(responseTime.Milliseconds())
-		sm.latencyData = append(sm.latencyData, latencyMs)
+		// 记录查询维度
+		sm.recordQueryDimensions(dims, now)
 
-		// 限制延迟数据点数量
-		if len(sm.latencyData) > 10000 {
-			sm.latencyData = sm.latencyData[len(sm.latencyData)-10000:]
-		}
+		// 记录响应时间，按缓存结果区分
+		sm.recordLatencyLocked(LatencyCategoryCache, metricLabel(dims.Cache, CacheOutcomeNone), responseTime, now)
+		sm.recordRecentLatencyLocked(responseTime, now)
 	}
 	sm.mutex.Unlock()
 
@@ -373,26 +386,26 @@ func (sm *StatsManager) GetNetworkStats() *NetworkStats {
 	return &stats
 }
 
-// GetDomainCounters 获取域名计数器
+// GetDomainCounters 获取域名计数器，仅包含当前跟踪的热门域名
 func (sm *StatsManager) GetDomainCounters() map[string]int64 {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
-	counters := make(map[string]int64)
-	for domain, count := range sm.domainCounters {
-		counters[domain] = count
+	counters := make(map[string]int64, sm.topDomains.Len())
+	for _, hh := range sm.topDomains.Top(0) {
+		counters[hh.Key] = hh.Count
 	}
 	return counters
 }
 
-// GetClientCounters 获取客户端计数器
+// GetClientCounters 获取客户端计数器，仅包含当前跟踪的热门客户端
 func (sm *StatsManager) GetClientCounters() map[string]int64 {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
-	counters := make(map[string]int64)
-	for client, count := range sm.clientCounters {
-		counters[client] = count
+	counters := make(map[string]int64, sm.topClients.Len())
+	for _, hh := range sm.topClients.Top(0) {
+		counters[hh.Key] = hh.Count
 	}
 	return counters
 }
@@ -436,39 +449,30 @@ func (sm *StatsManager) GetQPSHistory(timeRange string) []QPSDataPoint {
 	return history
 }
 
-// GetLatencyDistribution 获取延迟分布数据
+// GetLatencyDistribution 获取最近几分钟的延迟分布数据（百分比）
 func (sm *StatsManager) GetLatencyDistribution() map[string]int {
-	sm.mutex.RLock()
-	defer sm.mutex.RUnlock()
+	h := sm.recentLatencyHistogram(time.Now())
 
-	distribution := map[string]int{
-		"<10ms":    0,
-		"10-20ms":  0,
-		"20-50ms":  0,
-		"50-100ms": 0,
-		">100ms":   0,
-	}
-
-	for _, latency := range sm.latencyData {
-		switch {
-		case latency < 10:
-			distribution["<10ms"]++
-		case latency < 20:
-			distribution["10-20ms"]++
-		case latency < 50:
-			distribution["20-50ms"]++
-		case latency < 100:
-			distribution["50-100ms"]++
-		default:
-			distribution[">100ms"]++
-		}
+	below10 := h.CountBelow(10 * time.Millisecond)
+	below20 := h.CountBelow(20 * time.Millisecond)
+	below50 := h.CountBelow(50 * time.Millisecond)
+	below100 := h.CountBelow(100 * time.Millisecond)
+	counts := map[string]int64{
+		"<10ms":    below10,
+		"10-20ms":  below20 - below10,
+		"20-50ms":  below50 - below20,
+		"50-100ms": below100 - below50,
+		">100ms":   h.Count() - below100,
 	}
 
 	// 计算百分比
-	total := len(sm.latencyData)
-	if total > 0 {
-		for rangeKey := range distribution {
-			distribution[rangeKey] = (distribution[rangeKey] * 100) / total
+	distribution := make(map[string]int, len(counts))
+	total := h.Count()
+	for rangeKey, count := range counts {
+		if total > 0 {
+			distribution[rangeKey] = int(count * 100 / total)
+		} else {
+			distribution[rangeKey] = 0
 		}
 	}
 
@@ -485,25 +489,8 @@ func (sm *StatsManager) GetTopDomains(limit int) []DomainStat {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
-	type domainStat struct {
-		domain string
-		count  int64
-	}
-
-	var stats []domainStat
-	for domain, count := range sm.domainCounters {
-		stats = append(stats, domainStat{domain, count})
-	}
-
-	// 按查询次数排序
-	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].count > stats[j].count
-	})
-
-	// 限制返回数量
-	if len(stats) > limit {
-		stats = stats[:limit]
-	}
+	// 按查询次数降序取前limit项
+	stats := sm.topDomains.Top(limit)
 
 	// 转换为返回格式
 	totalQueries := sm.networkStats.TotalRequests
@@ -511,12 +498,12 @@ func (sm *StatsManager) GetTopDomains(limit int) []DomainStat {
 	for i, stat := range stats {
 		percentage := 0.0
 		if totalQueries > 0 {
-			percentage = round2(float64(stat.count) / float64(totalQueries) * 100)
+			percentage = round2(float64(stat.Count) / float64(totalQueries) * 100)
 		}
 		result[i] = DomainStat{
 			Rank:       i + 1,
-			Domain:     stat.domain,
-			Queries:    int(stat.count),
+			Domain:     stat.Key,
+			Queries:    int(stat.Count),
 			Percentage: percentage,
 		}
 	}
@@ -537,25 +524,8 @@ func (sm *StatsManager) GetTopClients(limit int) []ClientStat {
 	sm.mutex.RLock()
 	defer sm.mutex.RUnlock()
 
-	type clientStat struct {
-		client string
-		count  int64
-	}
-
-	var stats []clientStat
-	for client, count := range sm.clientCounters {
-		stats = append(stats, clientStat{client, count})
-	}
-
-	// 按查询次数排序
-	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].count > stats[j].count
-	})
-
-	// 限制返回数量
-	if len(stats) > limit {
-		stats = stats[:limit]
-	}
+	// 按查询次数降序取前limit项
+	stats := sm.topClients.Top(limit)
 
 	// 转换为返回格式
 	totalQueries := sm.networkStats.TotalRequests
@@ -563,12 +533,12 @@ func (sm *StatsManager) GetTopClients(limit int) []ClientStat {
 	for i, stat := range stats {
 		percentage := 0.0
 		if totalQueries > 0 {
-			percentage = round2(float64(stat.count) / float64(totalQueries) * 100)
+			percentage = round2(float64(stat.Count) / float64(totalQueries) * 100)
 		}
 		result[i] = ClientStat{
 			Rank:       i + 1,
-			IP:         stat.client,
-			Queries:    int(stat.count),
+			IP:         stat.Key,
+			Queries:    int(stat.Count),
 			Percentage: percentage,
 		}
 	}
@@ -694,15 +664,9 @@ func (sm *StatsManager) cleanup() {
 	}
 	sm.resourceHistory = filteredResources
 
-	// 限制延迟数据点数量
-	if len(sm.latencyData) > 10000 {
-		sm.latencyData = sm.latencyData[len(sm.latencyData)-10000:]
-	}
-
 	// 定期重置计数器（每天）
 	if now.Sub(sm.lastCleanupTime) > 24*time.Hour {
-		sm.domainCounters = make(map[string]int64)
-		sm.clientCounters = make(map[string]int64)
+		sm.resetTopN()
 		sm.lastCleanupTime = now
 	}
 }
@@ -835,6 +799,11 @@ func (sm *StatsManager) StartPersistence() {
 			select {
 			case <-ticker.C:
 				sm.persistToDatabase()
+				sm.rollupStats(time.Now())
+				sm.persistServerHealth()
+				sm.persistQueryDimensions()
+				sm.persistTopNSnapshot()
+				sm.persistLatencyHistograms()
 				sm.cleanOldDatabaseRecords()
 			case <-sm.stopPersist:
 				sm.logger.Info("QPS历史数据持久化任务已停止")
@@ -1121,14 +1090,35 @@ func (sm *StatsManager) persistToDatabase() {
 
 // cleanOldDatabaseRecords 清理数据库中的过期记录
 func (sm *StatsManager) cleanOldDatabaseRecords() {
-	if err := database.CleanOldQPSHistory(sm.retentionDays); err != nil {
-		sm.logger.Error("清理过期QPS历史记录失败: %v", err)
+	if sm.rawRollupComplete(time.Now()) {
+		if err := database.CleanOldQPSHistory(sm.rawRetentionDays); err != nil {
+			sm.logger.Error("清理过期QPS历史记录失败: %v", err)
+		}
+		if err := database.CleanOldResourceHistory(sm.rawRetentionDays); err != nil {
+			sm.logger.Error("清理过期资源使用历史记录失败: %v", err)
+		}
+		if err := database.CleanOldNetworkHistory(sm.rawRetentionDays); err != nil {
+			sm.logger.Error("清理过期网络流量历史记录失败: %v", err)
+		}
+	} else {
+		sm.logger.Debug("原始统计数据尚未完成分钟汇总，跳过清理")
+	}
+	for _, spec := range rollupTiers {
+		if err := database.CleanOldStatsRollups(spec.tier, sm.rollupRetentionDays[spec.tier]); err != nil {
+			sm.logger.Error("清理过期%s统计汇总失败: %v", spec.tier, err)
+		}
+	}
+	if err := database.CleanOldServerHealthHistory(sm.retentionDays); err != nil {
+		sm.logger.Error("清理过期服务器健康历史记录失败: %v", err)
 	}
-	if err := database.CleanOldResourceHistory(sm.retentionDays); err != nil {
-		sm.logger.Error("清理过期资源使用历史记录失败: %v", err)
+	if err := database.CleanOldQueryDimensionHistory(sm.retentionDays); err != nil {
+		sm.logger.Error("清理过期查询维度历史记录失败: %v", err)
 	}
-	if err := database.CleanOldNetworkHistory(sm.retentionDays); err != nil {
-		sm.logger.Error("清理过期网络流量历史记录失败: %v", err)
+	if err := database.CleanOldTopNHistory(sm.retentionDays); err != nil {
+		sm.logger.Error("清理过期热门项快照失败: %v", err)
+	}
+	if err := database.CleanOldLatencyHistogramHistory(sm.retentionDays); err != nil {
+		sm.logger.Error("清理过期延迟直方图历史记录失败: %v", err)
 	}
 }
 
@@ -1228,7 +1218,7 @@ func (sm *StatsManager) GetAggregatedQPSTrend(timeRange string, points int) *Agg
 		return cached
 	}
 
-	history := sm.GetQPSHistoryWithDB(timeRange)
+	history, rollups := sm.getQPSHistoryWithRollups(timeRange)
 
 	if len(history) == 0 {
 		return &AggregatedQPSTrend{
@@ -1245,6 +1235,7 @@ func (sm *StatsManager) GetAggregatedQPSTrend(timeRange string, points int) *Agg
 	}
 
 	result := sm.aggregateQPSData(history, points, timeRange)
+	result.Statistics.Min, result.Statistics.Max = widenRollupExtremes(rollups, result.Statistics.Min, result.Statistics.Max)
 
 	sm.cacheAggregatedQPSTrend(cacheKey, result)
 
@@ -1252,27 +1243,21 @@ func (sm *StatsManager) GetAggregatedQPSTrend(timeRange string, points int) *Agg
 }
 
 // GetQPSHistoryWithDB 获取QPS历史数据（优先从内存，不足时从数据库补充）
+// 7d、30d、1y范围先读取对应层级的汇总数据，之后的部分再从内存和原始数据补充
 func (sm *StatsManager) GetQPSHistoryWithDB(timeRange string) []QPSDataPoint {
+	history, _ := sm.getQPSHistoryWithRollups(timeRange)
+	return history
+}
+
+// getQPSHistoryWithRollups 获取QPS历史数据及其中使用的汇总记录
+func (sm *StatsManager) getQPSHistoryWithRollups(timeRange string) ([]QPSDataPoint, []database.StatsRollup) {
 	sm.mutex.RLock()
 	memoryData := make([]QPSDataPoint, len(sm.qpsHistory))
 	copy(memoryData, sm.qpsHistory)
 	sm.mutex.RUnlock()
 
-	var cutoff time.Time
 	now := time.Now()
-
-	switch timeRange {
-	case "1h":
-		cutoff = now.Add(-1 * time.Hour)
-	case "6h":
-		cutoff = now.Add(-6 * time.Hour)
-	case "24h":
-		cutoff = now.Add(-24 * time.Hour)
-	case "7d":
-		cutoff = now.Add(-7 * 24 * time.Hour)
-	default:
-		cutoff = now.Add(-1 * time.Hour)
-	}
+	rollups, cutoff := sm.getRollupSeries(database.RollupMetricQPS, rollupTierForRange(timeRange), now.Add(-timeRangeDuration(timeRange)), now)
 
 	var history []QPSDataPoint
 	for _, point := range memoryData {
@@ -1303,7 +1288,15 @@ func (sm *StatsManager) GetQPSHistoryWithDB(timeRange string) []QPSDataPoint {
 		}
 	}
 
-	return history
+	if len(rollups) > 0 {
+		points := make([]QPSDataPoint, 0, len(rollups)+len(history))
+		for _, r := range rollups {
+			points = append(points, QPSDataPoint{Time: r.Timestamp, QPS: r.Avg})
+		}
+		history = append(points, history...)
+	}
+
+	return history, rollups
 }
 
 // aggregateQPSData 聚合QPS数据
@@ -1317,30 +1310,9 @@ func (sm *StatsManager) aggregateQPSData(data []QPSDataPoint, points int, timeRa
 		return result
 	}
 
-	var timeFormat string
-	switch timeRange {
-	case "1h", "6h", "24h":
-		timeFormat = "15:04"
-	case "7d":
-		timeFormat = "01-02 15:04"
-	default:
-		timeFormat = "15:04"
-	}
-
+	timeFormat := timeRangeLabelFormat(timeRange)
 	now := time.Now()
-	var totalDuration time.Duration
-	switch timeRange {
-	case "1h":
-		totalDuration = time.Hour
-	case "6h":
-		totalDuration = 6 * time.Hour
-	case "24h":
-		totalDuration = 24 * time.Hour
-	case "7d":
-		totalDuration = 7 * 24 * time.Hour
-	default:
-		totalDuration = time.Hour
-	}
+	totalDuration := timeRangeDuration(timeRange)
 
 	if points <= 0 {
 		points = 12
@@ -1486,7 +1458,7 @@ func (sm *StatsManager) GetAggregatedResourceUsage(timeRange string, points int)
 		return cached
 	}
 
-	history := sm.GetResourceHistoryWithDB(timeRange)
+	history, rollups := sm.getResourceHistoryWithRollups(timeRange)
 
 	if len(history) == 0 {
 		return &AggregatedResourceUsage{
@@ -1499,6 +1471,9 @@ func (sm *StatsManager) GetAggregatedResourceUsage(timeRange string, points int)
 	}
 
 	result := sm.aggregateResourceData(history, points, timeRange)
+	widenResourceStatItem(&result.Statistics.CPU, rollups[database.RollupMetricCPU])
+	widenResourceStatItem(&result.Statistics.Memory, rollups[database.RollupMetricMemory])
+	widenResourceStatItem(&result.Statistics.Disk, rollups[database.RollupMetricDisk])
 
 	sm.cacheAggregatedResourceUsage(cacheKey, result)
 
@@ -1506,27 +1481,27 @@ func (sm *StatsManager) GetAggregatedResourceUsage(timeRange string, points int)
 }
 
 // GetResourceHistoryWithDB 获取资源使用历史数据（优先从内存，不足时从数据库补充）
+// 7d、30d、1y范围先读取对应层级的汇总数据，之后的部分再从内存和原始数据补充
 func (sm *StatsManager) GetResourceHistoryWithDB(timeRange string) []ResourceDataPoint {
+	history, _ := sm.getResourceHistoryWithRollups(timeRange)
+	return history
+}
+
+// getResourceHistoryWithRollups 获取资源使用历史数据及其中使用的汇总记录（按指标）
+func (sm *StatsManager) getResourceHistoryWithRollups(timeRange string) ([]ResourceDataPoint, map[string][]database.StatsRollup) {
 	sm.mutex.RLock()
 	memoryData := make([]ResourceDataPoint, len(sm.resourceHistory))
 	copy(memoryData, sm.resourceHistory)
 	sm.mutex.RUnlock()
 
-	var cutoff time.Time
 	now := time.Now()
-
-	switch timeRange {
-	case "1h":
-		cutoff = now.Add(-1 * time.Hour)
-	case "6h":
-		cutoff = now.Add(-6 * time.Hour)
-	case "24h":
-		cutoff = now.Add(-24 * time.Hour)
-	case "7d":
-		cutoff = now.Add(-7 * 24 * time.Hour)
-	default:
-		cutoff = now.Add(-1 * time.Hour)
-	}
+	tier := rollupTierForRange(timeRange)
+	start := now.Add(-timeRangeDuration(timeRange))
+	rollups := make(map[string][]database.StatsRollup)
+	var cutoff time.Time
+	rollups[database.RollupMetricCPU], cutoff = sm.getRollupSeries(database.RollupMetricCPU, tier, start, now)
+	rollups[database.RollupMetricMemory], _ = sm.getRollupSeries(database.RollupMetricMemory, tier, start, now)
+	rollups[database.RollupMetricDisk], _ = sm.getRollupSeries(database.RollupMetricDisk, tier, start, now)
 
 	var history []ResourceDataPoint
 	for _, point := range memoryData {
@@ -1557,7 +1532,28 @@ func (sm *StatsManager) GetResourceHistoryWithDB(timeRange string) []ResourceDat
 		}
 	}
 
-	return history
+	// 同一时间桶的CPU、内存、磁盘汇总合并为一个数据点
+	if cpu := rollups[database.RollupMetricCPU]; len(cpu) > 0 {
+		points := make([]ResourceDataPoint, len(cpu), len(cpu)+len(history))
+		index := make(map[int64]int, len(cpu))
+		for i, r := range cpu {
+			points[i] = ResourceDataPoint{Time: r.Timestamp, CPU: int(math.Round(r.Avg))}
+			index[r.Timestamp.Unix()] = i
+		}
+		for _, r := range rollups[database.RollupMetricMemory] {
+			if i, ok := index[r.Timestamp.Unix()]; ok {
+				points[i].Memory = int(math.Round(r.Avg))
+			}
+		}
+		for _, r := range rollups[database.RollupMetricDisk] {
+			if i, ok := index[r.Timestamp.Unix()]; ok {
+				points[i].Disk = int(math.Round(r.Avg))
+			}
+		}
+		history = append(points, history...)
+	}
+
+	return history, rollups
 }
 
 // aggregateResourceData 聚合资源使用数据
@@ -1573,30 +1569,9 @@ func (sm *StatsManager) aggregateResourceData(data []ResourceDataPoint, points i
 		return result
 	}
 
-	var timeFormat string
-	switch timeRange {
-	case "1h", "6h", "24h":
-		timeFormat = "15:04"
-	case "7d":
-		timeFormat = "01-02 15:04"
-	default:
-		timeFormat = "15:04"
-	}
-
+	timeFormat := timeRangeLabelFormat(timeRange)
 	now := time.Now()
-	var totalDuration time.Duration
-	switch timeRange {
-	case "1h":
-		totalDuration = time.Hour
-	case "6h":
-		totalDuration = 6 * time.Hour
-	case "24h":
-		totalDuration = 24 * time.Hour
-	case "7d":
-		totalDuration = 7 * 24 * time.Hour
-	default:
-		totalDuration = time.Hour
-	}
+	totalDuration := timeRangeDuration(timeRange)
 
 	if points <= 0 {
 		points = 12
@@ -1894,31 +1869,30 @@ func (sm *StatsManager) GetNetworkHistory(timeRange string) []NetworkDataPoint {
 
 // GetNetworkHistoryWithDB 获取网络历史数据（优先从内存，不足时从数据库补充）
 // 参数：
-//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d, 30d, 1y）
 //
 // 返回：
 //   - []NetworkDataPoint: 网络历史数据点数组
 func (sm *StatsManager) GetNetworkHistoryWithDB(timeRange string) []NetworkDataPoint {
+	history, _ := sm.getNetworkHistoryWithRollups(timeRange)
+	return history
+}
+
+// getNetworkHistoryWithRollups 获取网络历史数据及其中使用的汇总记录（按指标）
+// 7d、30d、1y范围先读取对应层级的汇总数据，之后的部分再从内存和原始数据补充
+func (sm *StatsManager) getNetworkHistoryWithRollups(timeRange string) ([]NetworkDataPoint, map[string][]database.StatsRollup) {
 	sm.mutex.RLock()
 	memoryData := make([]NetworkDataPoint, len(sm.networkHistory))
 	copy(memoryData, sm.networkHistory)
 	sm.mutex.RUnlock()
 
-	var cutoff time.Time
 	now := time.Now()
-
-	switch timeRange {
-	case "1h":
-		cutoff = now.Add(-1 * time.Hour)
-	case "6h":
-		cutoff = now.Add(-6 * time.Hour)
-	case "24h":
-		cutoff = now.Add(-24 * time.Hour)
-	case "7d":
-		cutoff = now.Add(-7 * 24 * time.Hour)
-	default:
-		cutoff = now.Add(-1 * time.Hour)
-	}
+	tier := rollupTierForRange(timeRange)
+	start := now.Add(-timeRangeDuration(timeRange))
+	rollups := make(map[string][]database.StatsRollup)
+	var cutoff time.Time
+	rollups[database.RollupMetricInboundBps], cutoff = sm.getRollupSeries(database.RollupMetricInboundBps, tier, start, now)
+	rollups[database.RollupMetricOutboundBps], _ = sm.getRollupSeries(database.RollupMetricOutboundBps, tier, start, now)
 
 	var history []NetworkDataPoint
 	for _, point := range memoryData {
@@ -1950,7 +1924,23 @@ func (sm *StatsManager) GetNetworkHistoryWithDB(timeRange string) []NetworkDataP
 		}
 	}
 
-	return history
+	// 同一时间桶的入站、出站汇总合并为一个数据点
+	if inbound := rollups[database.RollupMetricInboundBps]; len(inbound) > 0 {
+		points := make([]NetworkDataPoint, len(inbound), len(inbound)+len(history))
+		index := make(map[int64]int, len(inbound))
+		for i, r := range inbound {
+			points[i] = NetworkDataPoint{Time: r.Timestamp, InboundBps: uint64(math.Round(r.Avg))}
+			index[r.Timestamp.Unix()] = i
+		}
+		for _, r := range rollups[database.RollupMetricOutboundBps] {
+			if i, ok := index[r.Timestamp.Unix()]; ok {
+				points[i].OutboundBps = uint64(math.Round(r.Avg))
+			}
+		}
+		history = append(points, history...)
+	}
+
+	return history, rollups
 }
 
 // AggregatedNetworkUsage 聚合后的网络使用数据
@@ -1977,7 +1967,7 @@ type NetworkStatItem struct {
 
 // GetAggregatedNetworkUsage 获取聚合后的网络使用数据
 // 参数：
-//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d, 30d, 1y）
 //   - points: 数据点数量
 //
 // 返回：
@@ -1988,7 +1978,7 @@ func (sm *StatsManager) GetAggregatedNetworkUsage(timeRange string, points int)
 		return cached
 	}
 
-	history := sm.GetNetworkHistoryWithDB(timeRange)
+	history, rollups := sm.getNetworkHistoryWithRollups(timeRange)
 
 	if len(history) == 0 {
 		return &AggregatedNetworkUsage{
@@ -2000,6 +1990,8 @@ func (sm *StatsManager) GetAggregatedNetworkUsage(timeRange string, points int)
 	}
 
 	result := sm.aggregateNetworkData(history, points, timeRange)
+	widenNetworkStatItem(&result.Statistics.Inbound, rollups[database.RollupMetricInboundBps])
+	widenNetworkStatItem(&result.Statistics.Outbound, rollups[database.RollupMetricOutboundBps])
 
 	sm.cacheAggregatedNetworkUsage(cacheKey, result)
 
@@ -2025,30 +2017,9 @@ func (sm *StatsManager) aggregateNetworkData(data []NetworkDataPoint, points int
 		return result
 	}
 
-	var timeFormat string
-	switch timeRange {
-	case "1h", "6h", "24h":
-		timeFormat = "15:04"
-	case "7d":
-		timeFormat = "01-02 15:04"
-	default:
-		timeFormat = "15:04"
-	}
-
+	timeFormat := timeRangeLabelFormat(timeRange)
 	now := time.Now()
-	var totalDuration time.Duration
-	switch timeRange {
-	case "1h":
-		totalDuration = time.Hour
-	case "6h":
-		totalDuration = 6 * time.Hour
-	case "24h":
-		totalDuration = 24 * time.Hour
-	case "7d":
-		totalDuration = 7 * 24 * time.Hour
-	default:
-		totalDuration = time.Hour
-	}
+	totalDuration := timeRangeDuration(timeRange)
 
 	if points <= 0 {
 		points = 12
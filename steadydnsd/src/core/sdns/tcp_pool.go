@@ -293,6 +293,8 @@ type TCPConnectionPool struct {
 	closed int32
 	// logger 日志记录器
 	logger *common.Logger
+	// sourceResolver 出站源地址解析器，为nil时由系统选择源地址
+	sourceResolver *SourceAddressResolver
 }
 
 // NewTCPConnectionPool 创建新的TCP连接池
@@ -476,12 +478,14 @@ func (p *TCPConnectionPool) HasHealthyConnection(serverAddr string) bool {
 //   - *PooledConnection: 创建的连接
 //   - error: 错误信息
 func (p *TCPConnectionPool) CreateConnection(serverAddr string) (*PooledConnection, error) {
-	// 建立TCP连接
-	dialer := &net.Dialer{
-		Timeout: p.config.ConnectTimeout,
+	// 建立TCP连接，已绑定源地址时使用指定的本地地址
+	dialer, err := p.sourceResolver.NewDialer("tcp", serverAddr, p.config.ConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source address for %s: %w", serverAddr, err)
 	}
 
-	conn, err := dialer.Dial("tcp", serverAddr)
+	addr, _ := SplitUpstreamEndpoint(serverAddr)
+	conn, err := dialer.Dial("tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to dial %s: %w", serverAddr, err)
 	}
@@ -1354,6 +1358,34 @@ func (p *TCPConnectionPool) Close() error {
 	return nil
 }
 
+// SetSourceResolver 设置出站源地址解析器
+func (p *TCPConnectionPool) SetSourceResolver(resolver *SourceAddressResolver) {
+	p.sourceResolver = resolver
+}
+
+// CloseServerConnections 关闭指定服务器的所有连接并移除其连接池
+// 用于源地址绑定变化后，使后续连接使用新的源地址重新建立
+//
+// 参数:
+//   - serverAddr: 服务器地址（格式：host:port）
+func (p *TCPConnectionPool) CloseServerConnections(serverAddr string) {
+	p.poolsMu.Lock()
+	serverPool, exists := p.pools[serverAddr]
+	delete(p.pools, serverAddr)
+	p.poolsMu.Unlock()
+
+	if !exists {
+		return
+	}
+
+	serverPool.connMu.Lock()
+	for _, conn := range serverPool.connections {
+		conn.Close()
+	}
+	serverPool.connections = nil
+	serverPool.connMu.Unlock()
+}
+
 // GetStats 获取连接池统计信息
 //
 // 返回:
@@ -0,0 +1,251 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/sdns/top_history.go
+
+package sdns
+
+import (
+	"fmt"
+	"time"
+
+	"SteadyDNS/core/database"
+)
+
+const (
+	// heavyHitterCapacity 热门域名/客户端的计数器数量，内存占用与查询的不同域名数无关
+	heavyHitterCapacity = 1000
+	// topNWindowDuration 热门项快照窗口长度
+	topNWindowDuration = 5 * time.Minute
+	// TopNSnapshotSize 每个窗口持久化的热门项数量
+	TopNSnapshotSize = 20
+)
+
+// 热门项快照类型
+const (
+	TopNKindDomain = "domain" // 热门域名
+	TopNKindClient = "client" // 热门客户端
+)
+
+// topNWindow 当前快照窗口内的热门项和去重计数
+type topNWindow struct {
+	start         time.Time
+	queries       int64
+	domains       *SpaceSaving
+	clients       *SpaceSaving
+	uniqueDomains *HyperLogLog
+	uniqueClients *HyperLogLog
+}
+
+// newTopNWindow 创建从start开始的快照窗口
+func newTopNWindow(start time.Time) *topNWindow {
+	return &topNWindow{
+		start:         start,
+		domains:       NewSpaceSaving(heavyHitterCapacity),
+		clients:       NewSpaceSaving(heavyHitterCapacity),
+		uniqueDomains: NewHyperLogLog(),
+		uniqueClients: NewHyperLogLog(),
+	}
+}
+
+// add 记录一次查询
+func (w *topNWindow) add(domain, clientIP string) {
+	w.queries++
+	w.domains.Add(domain)
+	w.clients.Add(clientIP)
+	w.uniqueDomains.Add(domain)
+	w.uniqueClients.Add(clientIP)
+}
+
+// reset 清空计数并从start开始新窗口
+func (w *topNWindow) reset(start time.Time) {
+	w.start = start
+	w.queries = 0
+	w.domains.Reset()
+	w.clients.Reset()
+	w.uniqueDomains.Reset()
+	w.uniqueClients.Reset()
+}
+
+// TopHistoryItem 快照中的热门项
+type TopHistoryItem struct {
+	Rank  int    `json:"rank"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+	Error int64  `json:"error"` // 计数最大高估值
+}
+
+// TopHistorySnapshot 单个窗口的热门项快照
+type TopHistorySnapshot struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Queries   int64            `json:"queries"` // 窗口内查询次数
+	Unique    int64            `json:"unique"`  // 窗口内去重域名数或客户端数估算
+	Items     []TopHistoryItem `json:"items"`
+}
+
+// TopHistory 热门项历史快照
+type TopHistory struct {
+	Kind      string               `json:"kind"`
+	TimeRange string               `json:"timeRange"`
+	Window    int                  `json:"window"` // 窗口长度（秒）
+	Snapshots []TopHistorySnapshot `json:"snapshots"`
+}
+
+// recordTopN 更新热门项和去重计数，调用方需持有sm.mutex写锁
+func (sm *StatsManager) recordTopN(domain, clientIP string) {
+	sm.topDomains.Add(domain)
+	sm.topClients.Add(clientIP)
+	sm.uniqueDomains.Add(domain)
+	sm.uniqueClients.Add(clientIP)
+	sm.topNWindow.add(domain, clientIP)
+}
+
+// resetTopN 重置累计的热门项和去重计数，调用方需持有sm.mutex写锁
+func (sm *StatsManager) resetTopN() {
+	sm.topDomains.Reset()
+	sm.topClients.Reset()
+	sm.uniqueDomains.Reset()
+	sm.uniqueClients.Reset()
+}
+
+// GetUniqueCounts 获取自上次重置以来去重域名数和客户端数的估算值
+func (sm *StatsManager) GetUniqueCounts() (domains, clients int64) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.uniqueDomains.Estimate(), sm.uniqueClients.Estimate()
+}
+
+// takeTopNSnapshot 窗口结束时生成快照并开始新窗口
+// 窗口未结束或窗口内无查询时返回nil
+func (sm *StatsManager) takeTopNSnapshot(now time.Time) ([]database.TopNHistory, *database.UniqueCountHistory) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	w := sm.topNWindow
+	if now.Sub(w.start) < topNWindowDuration {
+		return nil, nil
+	}
+	if w.queries == 0 {
+		w.reset(now)
+		return nil, nil
+	}
+
+	var records []database.TopNHistory
+	for kind, tracker := range map[string]*SpaceSaving{TopNKindDomain: w.domains, TopNKindClient: w.clients} {
+		for i, hh := range tracker.Top(TopNSnapshotSize) {
+			records = append(records, database.TopNHistory{
+				Timestamp: w.start,
+				Kind:      kind,
+				Rank:      i + 1,
+				Name:      hh.Key,
+				Count:     hh.Count,
+				Error:     hh.Error,
+			})
+		}
+	}
+	unique := &database.UniqueCountHistory{
+		Timestamp:     w.start,
+		Queries:       w.queries,
+		UniqueDomains: w.uniqueDomains.Estimate(),
+		UniqueClients: w.uniqueClients.Estimate(),
+	}
+
+	w.reset(now)
+	return records, unique
+}
+
+// persistTopNSnapshot 持久化已结束窗口的热门项快照
+func (sm *StatsManager) persistTopNSnapshot() {
+	records, unique := sm.takeTopNSnapshot(time.Now())
+	if unique == nil {
+		return
+	}
+
+	if err := database.SaveTopNSnapshot(records, unique); err != nil {
+		sm.logger.Error("持久化热门项快照失败: %v", err)
+		return
+	}
+	sm.logger.Debug("持久化了 %d 条热门项快照记录", len(records))
+}
+
+// GetTopHistory 获取时间范围内的热门项历史快照
+// 参数：
+//   - kind: 类型（domain, client）
+//   - timeRange: 时间范围（1h, 6h, 24h, 7d）
+//   - limit: 每个窗口返回的热门项数量，<=0或超过TopNSnapshotSize时取TopNSnapshotSize
+//
+// 返回：
+//   - *TopHistory: 按窗口时间升序排列的快照
+//   - error: 类型无效或查询失败时返回错误
+func (sm *StatsManager) GetTopHistory(kind, timeRange string, limit int) (*TopHistory, error) {
+	if kind != TopNKindDomain && kind != TopNKindClient {
+		return nil, fmt.Errorf("无效的热门项类型: %s", kind)
+	}
+	if limit <= 0 || limit > TopNSnapshotSize {
+		limit = TopNSnapshotSize
+	}
+
+	now := time.Now()
+	startTime := now.Add(-timeRangeDuration(timeRange))
+
+	uniques, err := database.GetUniqueCountHistoryByTimeRange(startTime, now)
+	if err != nil {
+		return nil, err
+	}
+	records, err := database.GetTopNHistoryByTimeRange(kind, startTime, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &TopHistory{
+		Kind:      kind,
+		TimeRange: timeRange,
+		Window:    int(topNWindowDuration / time.Second),
+		Snapshots: make([]TopHistorySnapshot, 0, len(uniques)),
+	}
+
+	// 两张表按窗口起始时间关联
+	index := make(map[int64]int, len(uniques))
+	for _, u := range uniques {
+		snapshot := TopHistorySnapshot{
+			Timestamp: u.Timestamp,
+			Queries:   u.Queries,
+			Unique:    u.UniqueDomains,
+			Items:     make([]TopHistoryItem, 0, limit),
+		}
+		if kind == TopNKindClient {
+			snapshot.Unique = u.UniqueClients
+		}
+		index[u.Timestamp.UnixNano()] = len(history.Snapshots)
+		history.Snapshots = append(history.Snapshots, snapshot)
+	}
+	for _, r := range records {
+		i, ok := index[r.Timestamp.UnixNano()]
+		if !ok {
+			continue
+		}
+		history.Snapshots[i].Items = append(history.Snapshots[i].Items, TopHistoryItem{
+			Rank:  r.Rank,
+			Name:  r.Name,
+			Count: r.Count,
+			Error: r.Error,
+		})
+	}
+
+	return history, nil
+}
@@ -196,6 +196,15 @@ func CreateBindZoneGin(c *gin.Context) {
 		return
 	}
 
+	// 校验记录，结构化字段无效时直接拒绝
+	if err := bind.NormalizeRecords(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	// 创建权威域
 	if err := bindManager.CreateAuthZone(zone); err != nil {
 		errMsg := "创建权威域失败: " + err.Error()
@@ -253,6 +262,15 @@ func UpdateBindZoneGin(c *gin.Context, domain string) {
 	// 确保域名一致
 	zone.Domain = domain
 
+	// 校验记录，结构化字段无效时直接拒绝
+	if err := bind.NormalizeRecords(&zone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
 	// 更新权威域
 	if err := bindManager.UpdateAuthZone(zone); err != nil {
 		errMsg := "更新权威域失败: " + err.Error()
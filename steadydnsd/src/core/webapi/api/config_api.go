@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"SteadyDNS/core/common"
+	"SteadyDNS/core/sdns"
 	"SteadyDNS/core/webapi/middleware"
 
 	"github.com/gin-gonic/gin"
@@ -195,6 +196,9 @@ func handleReloadConfigGin(c *gin.Context, configManager *common.ConfigManager)
 	// 重载速率限制配置
 	middleware.ReloadRateLimitConfig()
 
+	// 重载事件配置
+	sdns.GetEventManager().ReloadConfig()
+
 	// 直接返回成功响应，移除历史记录添加
 	// 原因：reload 操作只是重新读取配置文件，没有实际修改配置，不需要记录历史
 	c.JSON(http.StatusOK, gin.H{
@@ -81,6 +81,36 @@ func dashboardHandlerGin(c *gin.Context) {
 				getDashboardTopGin(c)
 				return
 			}
+		case "server-availability":
+			if c.Request.Method == http.MethodGet {
+				getServerAvailabilityGin(c)
+				return
+			}
+		case "server-trends":
+			if c.Request.Method == http.MethodGet {
+				getServerTrendsGin(c)
+				return
+			}
+		case "latency":
+			if c.Request.Method == http.MethodGet {
+				getLatencyTrendGin(c)
+				return
+			}
+		case "top-history":
+			if c.Request.Method == http.MethodGet {
+				getTopHistoryGin(c)
+				return
+			}
+		case "query-types", "rcodes", "protocols", "cache-status":
+			if c.Request.Method == http.MethodGet {
+				getQueryBreakdownGin(c, queryBreakdownDimensions[endpoint])
+				return
+			}
+		case "anomalies":
+			if c.Request.Method == http.MethodGet {
+				getAnomaliesGin(c)
+				return
+			}
 		default:
 			c.JSON(http.StatusNotFound, gin.H{"error": "无效的dashboard API端点"})
 			return
@@ -106,11 +136,13 @@ type SystemStats struct {
 
 // 转发服务器状态结构
 type ForwardServerStatus struct {
-	ID      int     `json:"id"`
-	Address string  `json:"address"`
-	QPS     float64 `json:"qps"`
-	Latency float64 `json:"latency"`
-	Status  string  `json:"status"`
+	ID              int     `json:"id"`
+	Address         string  `json:"address"`
+	QPS             float64 `json:"qps"`
+	Latency         float64 `json:"latency"`
+	Status          string  `json:"status"`
+	SpoofDetections int64   `json:"spoofDetections"` // 未通过防伪造校验的响应次数
+	CaseMismatches  int64   `json:"caseMismatches"`  // 0x20查询名大小写不匹配次数
 }
 
 // 缓存状态结构
@@ -265,8 +297,10 @@ type TrendRequestInfo struct {
 
 // 排行榜数据响应结构
 type DashboardTopResponse struct {
-	TopDomains []TopDomain `json:"topDomains"`
-	TopClients []TopClient `json:"topClients"`
+	TopDomains    []TopDomain `json:"topDomains"`
+	TopClients    []TopClient `json:"topClients"`
+	UniqueDomains int64       `json:"uniqueDomains"` // 去重域名数估算
+	UniqueClients int64       `json:"uniqueClients"` // 去重客户端数估算
 }
 
 // getDashboardSummaryGin 获取dashboard综合数据（Gin版本）
@@ -322,11 +356,11 @@ func getDashboardTrendsGin(c *gin.Context) {
 		timeRange = "1h"
 	}
 
-	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true}
+	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true, "30d": true, "1y": true}
 	if !validTimeRanges[timeRange] {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d",
+			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d, 30d, 1y",
 		})
 		return
 	}
@@ -345,6 +379,11 @@ func getDashboardTrendsGin(c *gin.Context) {
 		points = p
 	}
 
+	// 30d和1y只能从数据库汇总数据读取，未指定points时使用聚合模式
+	if points == 0 {
+		points = longTrendDefaultPoints[timeRange]
+	}
+
 	response := DashboardTrendsResponse{}
 
 	if dataType == "qps" || dataType == "all" {
@@ -418,6 +457,9 @@ func getDashboardTopGin(c *gin.Context) {
 		TopDomains: topDomains,
 		TopClients: topClients,
 	}
+	if statsManager := sdns.GetStatsManager(); statsManager != nil {
+		response.UniqueDomains, response.UniqueClients = statsManager.GetUniqueCounts()
+	}
 
 	// 发送成功响应
 	c.JSON(http.StatusOK, gin.H{
@@ -427,6 +469,345 @@ func getDashboardTopGin(c *gin.Context) {
 	})
 }
 
+// getServerAvailabilityGin 获取上游服务器可用性报告（Gin版本）
+func getServerAvailabilityGin(c *gin.Context) {
+	timeRange := c.Query("timeRange")
+	if timeRange == "" {
+		timeRange = "24h"
+	}
+
+	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true}
+	if !validTimeRanges[timeRange] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d",
+		})
+		return
+	}
+
+	statsManager := sdns.GetStatsManager()
+	if statsManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "统计管理器不可用",
+		})
+		return
+	}
+
+	report, err := statsManager.GetServerAvailability(timeRange)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("获取服务器可用性报告失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"timeRange": timeRange,
+			"servers":   report,
+		},
+		"message": "获取服务器可用性报告成功",
+	})
+}
+
+// getServerTrendsGin 获取指定上游服务器的健康趋势（Gin版本）
+func getServerTrendsGin(c *gin.Context) {
+	server := strings.TrimSpace(c.Query("server"))
+	if server == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "缺少server参数",
+		})
+		return
+	}
+	// 未指定端口时默认使用53
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	timeRange := c.Query("timeRange")
+	if timeRange == "" {
+		timeRange = "24h"
+	}
+
+	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true}
+	if !validTimeRanges[timeRange] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d",
+		})
+		return
+	}
+
+	points := 0
+	pointsStr := c.Query("points")
+	if pointsStr != "" {
+		p, err := strconv.Atoi(pointsStr)
+		if err != nil || p < 0 || p > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的points参数，必须为0或1-1000之间的正整数",
+			})
+			return
+		}
+		points = p
+	}
+
+	statsManager := sdns.GetStatsManager()
+	if statsManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "统计管理器不可用",
+		})
+		return
+	}
+
+	trend, err := statsManager.GetServerHealthTrend(server, timeRange, points)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("获取服务器健康趋势失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    trend,
+		"message": "获取服务器健康趋势成功",
+	})
+}
+
+// getLatencyTrendGin 获取按缓存结果或上游服务器区分的延迟百分位趋势（Gin版本）
+func getLatencyTrendGin(c *gin.Context) {
+	category := c.Query("category")
+	if category == "" {
+		category = sdns.LatencyCategoryCache
+	}
+	if category != sdns.LatencyCategoryCache && category != sdns.LatencyCategoryUpstream {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的category参数，可选值：cache, upstream",
+		})
+		return
+	}
+
+	timeRange := c.Query("timeRange")
+	if timeRange == "" {
+		timeRange = "1h"
+	}
+
+	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true}
+	if !validTimeRanges[timeRange] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d",
+		})
+		return
+	}
+
+	points := 0
+	pointsStr := c.Query("points")
+	if pointsStr != "" {
+		p, err := strconv.Atoi(pointsStr)
+		if err != nil || p < 0 || p > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的points参数，必须为0或1-1000之间的正整数",
+			})
+			return
+		}
+		points = p
+	}
+
+	statsManager := sdns.GetStatsManager()
+	if statsManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "统计管理器不可用",
+		})
+		return
+	}
+
+	trend, err := statsManager.GetLatencyTrend(category, timeRange, points)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("获取延迟趋势失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    trend,
+		"message": "获取延迟趋势成功",
+	})
+}
+
+// getTopHistoryGin 获取热门域名或客户端的历史窗口快照（Gin版本）
+func getTopHistoryGin(c *gin.Context) {
+	kind := c.Query("type")
+	if kind == "" {
+		kind = sdns.TopNKindDomain
+	}
+	if kind != sdns.TopNKindDomain && kind != sdns.TopNKindClient {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的type参数，可选值：domain, client",
+		})
+		return
+	}
+
+	timeRange := c.Query("timeRange")
+	if timeRange == "" {
+		timeRange = "1h"
+	}
+
+	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true}
+	if !validTimeRanges[timeRange] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d",
+		})
+		return
+	}
+
+	limit := 10
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > sdns.TopNSnapshotSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("无效的limit参数，必须为1-%d之间的正整数", sdns.TopNSnapshotSize),
+			})
+			return
+		}
+		limit = l
+	}
+
+	statsManager := sdns.GetStatsManager()
+	if statsManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "统计管理器不可用",
+		})
+		return
+	}
+
+	history, err := statsManager.GetTopHistory(kind, timeRange, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("获取热门项历史失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    history,
+		"message": "获取热门项历史成功",
+	})
+}
+
+// getAnomaliesGin 获取DNS隧道/DGA异常检测报告：最近告警、异常客户端、异常域名和被隔离的客户端（Gin版本）
+func getAnomaliesGin(c *gin.Context) {
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的limit参数，必须为1-500之间的正整数",
+			})
+			return
+		}
+		limit = l
+	}
+
+	detector := sdns.GlobalAnomalyDetector
+	if detector == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "异常检测未启用，需启用日志分析插件",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    detector.GetReport(limit),
+		"message": "获取异常检测报告成功",
+	})
+}
+
+// queryBreakdownDimensions 查询维度分布端点与统计维度的对应关系
+var queryBreakdownDimensions = map[string]string{
+	"query-types":  sdns.QueryDimensionQType,
+	"rcodes":       sdns.QueryDimensionRCode,
+	"protocols":    sdns.QueryDimensionProtocol,
+	"cache-status": sdns.QueryDimensionCache,
+}
+
+// getQueryBreakdownGin 获取查询类型、响应码、传输协议或缓存结果的分布和趋势（Gin版本）
+func getQueryBreakdownGin(c *gin.Context, dimension string) {
+	timeRange := c.Query("timeRange")
+	if timeRange == "" {
+		timeRange = "24h"
+	}
+
+	validTimeRanges := map[string]bool{"1h": true, "6h": true, "24h": true, "7d": true}
+	if !validTimeRanges[timeRange] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "无效的timeRange参数，可选值：1h, 6h, 24h, 7d",
+		})
+		return
+	}
+
+	points := 0
+	pointsStr := c.Query("points")
+	if pointsStr != "" {
+		p, err := strconv.Atoi(pointsStr)
+		if err != nil || p < 0 || p > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"error":   "无效的points参数，必须为0或1-1000之间的正整数",
+			})
+			return
+		}
+		points = p
+	}
+
+	statsManager := sdns.GetStatsManager()
+	if statsManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "统计管理器不可用",
+		})
+		return
+	}
+
+	breakdown, err := statsManager.GetQueryBreakdown(dimension, timeRange, points)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("获取查询分布失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    breakdown,
+		"message": "获取查询分布成功",
+	})
+}
+
 // getSystemStats 获取系统概览统计
 func getSystemStats() SystemStats {
 	// 获取统计管理器
@@ -523,6 +904,7 @@ func getForwardServerStatus() []ForwardServerStatus {
 		qps := 0.0
 		latency := 0.0
 		status := "healthy"
+		var spoofDetections, caseMismatches int64
 
 		// 从全局DNS转发器获取统计信息
 		if sdns.GlobalDNSForwarder != nil {
@@ -540,6 +922,8 @@ func getForwardServerStatus() []ForwardServerStatus {
 					qps = stats.QPS
 					latency = stats.Latency
 					status = stats.Status
+					spoofDetections = stats.SpoofDetections
+					caseMismatches = stats.CaseMismatches
 					stats.Mu.RUnlock()
 				}
 			case <-time.After(2 * time.Second):
@@ -551,11 +935,13 @@ func getForwardServerStatus() []ForwardServerStatus {
 
 		// 对QPS和Latency进行四舍五入，保留2位小数
 		serverStatuses[i] = ForwardServerStatus{
-			ID:      int(server.ID),
-			Address: server.Address,
-			QPS:     math.Round(qps*100) / 100,
-			Latency: math.Round(latency*100) / 100,
-			Status:  status,
+			ID:              int(server.ID),
+			Address:         server.Address,
+			QPS:             math.Round(qps*100) / 100,
+			Latency:         math.Round(latency*100) / 100,
+			Status:          status,
+			SpoofDetections: spoofDetections,
+			CaseMismatches:  caseMismatches,
 		}
 	}
 
@@ -650,6 +1036,12 @@ func getSystemResources() SystemResources {
 	return resources
 }
 
+// longTrendDefaultPoints 长时间范围趋势的默认数据点数
+var longTrendDefaultPoints = map[string]int{
+	"30d": 120, // 每6小时一个点
+	"1y":  365, // 每天一个点
+}
+
 // getQPSTrend 获取QPS趋势数据
 func getQPSTrend(timeRange string) []QPSTrend {
 	// 获取统计管理器
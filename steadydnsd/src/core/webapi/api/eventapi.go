@@ -0,0 +1,334 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/webapi/api/eventapi.go
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/database"
+	"SteadyDNS/core/sdns"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookTargetRequest 创建/更新Webhook目标请求结构体
+// 更新时未提供的字段保持不变
+type WebhookTargetRequest struct {
+	Name       *string  `json:"name"`
+	URL        *string  `json:"url"`
+	Format     *string  `json:"format"`
+	EventTypes []string `json:"eventTypes"`
+	Secret     *string  `json:"secret"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// WebhookTargetResponse Webhook目标响应结构体（不包含签名密钥）
+type WebhookTargetResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Format     string    `json:"format"`
+	EventTypes []string  `json:"eventTypes"`
+	HasSecret  bool      `json:"hasSecret"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// EventsListResponse 事件列表响应结构体
+type EventsListResponse struct {
+	Events   []database.SystemEvent `json:"events"`
+	Total    int64                  `json:"total"`
+	Page     int                    `json:"page"`
+	PageSize int                    `json:"pageSize"`
+}
+
+// toWebhookTargetResponse 转换为响应格式
+func toWebhookTargetResponse(target *database.WebhookTarget) WebhookTargetResponse {
+	eventTypes := []string{}
+	for _, t := range strings.Split(target.EventTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			eventTypes = append(eventTypes, t)
+		}
+	}
+
+	return WebhookTargetResponse{
+		ID:         target.ID,
+		Name:       target.Name,
+		URL:        target.URL,
+		Format:     target.Format,
+		EventTypes: eventTypes,
+		HasSecret:  target.Secret != "",
+		Enabled:    target.Enabled,
+		CreatedAt:  target.CreatedAt,
+		UpdatedAt:  target.UpdatedAt,
+	}
+}
+
+// applyWebhookTargetRequest 将请求内容应用到Webhook目标并校验
+func applyWebhookTargetRequest(target *database.WebhookTarget, req *WebhookTargetRequest) error {
+	if req.Name != nil {
+		target.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.URL != nil {
+		target.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Format != nil {
+		target.Format = strings.ToLower(strings.TrimSpace(*req.Format))
+	}
+	if req.EventTypes != nil {
+		for _, t := range req.EventTypes {
+			if !sdns.IsValidEventType(t) {
+				return fmt.Errorf("无效的事件类型: %s", t)
+			}
+		}
+		target.EventTypes = strings.Join(req.EventTypes, ",")
+	}
+	if req.Secret != nil {
+		target.Secret = *req.Secret
+	}
+	if req.Enabled != nil {
+		target.Enabled = *req.Enabled
+	}
+
+	if target.Format == "" {
+		target.Format = sdns.WebhookFormatGeneric
+	}
+
+	if target.Name == "" || len(target.Name) > 64 {
+		return fmt.Errorf("名称不能为空且长度不能超过64")
+	}
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("无效的Webhook URL，仅支持http和https")
+	}
+	if !sdns.IsValidWebhookFormat(target.Format) {
+		return fmt.Errorf("无效的消息格式，可选值：%s", strings.Join(sdns.WebhookFormats, ", "))
+	}
+	return nil
+}
+
+// GetEventsHandler 查询系统事件处理器
+// 支持按类型、级别、对象和时间范围过滤，支持分页
+func GetEventsHandler(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize < 1 || pageSize > 500 {
+		pageSize = 20
+	}
+
+	query := database.EventQuery{
+		Type:     c.Query("type"),
+		Severity: c.Query("severity"),
+		Subject:  c.Query("subject"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	if s := c.Query("startTime"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的startTime参数，需为RFC3339格式"})
+			return
+		}
+		query.StartTime = t
+	}
+	if s := c.Query("endTime"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的endTime参数，需为RFC3339格式"})
+			return
+		}
+		query.EndTime = t
+	}
+
+	events, total, err := database.GetSystemEvents(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": EventsListResponse{
+			Events:   events,
+			Total:    total,
+			Page:     page,
+			PageSize: pageSize,
+		},
+	})
+}
+
+// GetEventStatsHandler 获取事件管理器状态处理器
+func GetEventStatsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"stats":          sdns.GetEventManager().GetStats(),
+			"eventTypes":     sdns.AllEventTypes,
+			"webhookFormats": sdns.WebhookFormats,
+		},
+	})
+}
+
+// GetWebhookTargetsHandler 获取Webhook目标列表处理器
+func GetWebhookTargetsHandler(c *gin.Context) {
+	targets, err := database.GetWebhookTargets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	responses := make([]WebhookTargetResponse, len(targets))
+	for i := range targets {
+		responses[i] = toWebhookTargetResponse(&targets[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    responses,
+	})
+}
+
+// CreateWebhookTargetHandler 创建Webhook目标处理器
+func CreateWebhookTargetHandler(c *gin.Context) {
+	var req WebhookTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求体"})
+		return
+	}
+
+	target := &database.WebhookTarget{Enabled: true}
+	if err := applyWebhookTargetRequest(target, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := database.CreateWebhookTarget(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toWebhookTargetResponse(target),
+		"message": "Webhook目标创建成功",
+	})
+}
+
+// UpdateWebhookTargetHandler 更新Webhook目标处理器
+func UpdateWebhookTargetHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的Webhook目标ID"})
+		return
+	}
+
+	target, err := database.GetWebhookTargetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var req WebhookTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的请求体"})
+		return
+	}
+
+	if err := applyWebhookTargetRequest(target, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if err := database.UpdateWebhookTarget(target); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toWebhookTargetResponse(target),
+		"message": "Webhook目标更新成功",
+	})
+}
+
+// DeleteWebhookTargetHandler 删除Webhook目标处理器
+func DeleteWebhookTargetHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的Webhook目标ID"})
+		return
+	}
+
+	if err := database.DeleteWebhookTarget(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Webhook目标删除成功",
+	})
+}
+
+// TestWebhookTargetHandler 发送测试事件到Webhook目标处理器
+// 测试事件只投递一次，不重试也不写入事件表
+func TestWebhookTargetHandler(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的Webhook目标ID"})
+		return
+	}
+
+	target, err := database.GetWebhookTargetByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	event := &sdns.Event{
+		Type:      "test",
+		Severity:  sdns.SeverityInfo,
+		Source:    "api",
+		Subject:   target.Name,
+		Message:   "SteadyDNS Webhook测试消息",
+		Timestamp: time.Now(),
+	}
+
+	if err := sdns.GetEventManager().Deliver(*target, event); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "测试消息发送成功",
+	})
+}
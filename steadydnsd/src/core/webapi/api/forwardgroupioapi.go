@@ -0,0 +1,146 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/webapi/api/forwardgroupioapi.go
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/database"
+	"SteadyDNS/core/sdns"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxForwardGroupImportSize 导入文档最大大小（10MB）
+const maxForwardGroupImportSize = 10 << 20
+
+// ForwardGroupImportResponse 导入响应结构体
+type ForwardGroupImportResponse struct {
+	DryRun   bool                             `json:"dryRun"`
+	Plan     *database.ForwardGroupImportPlan `json:"plan"`
+	Warnings []string                         `json:"warnings"`
+}
+
+// ExportForwardGroupsHandler 导出转发组处理器
+// 查询参数format可选yaml（默认）或json，以附件形式返回
+func ExportForwardGroupsHandler(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "yaml"))
+	if format == "yml" {
+		format = "yaml"
+	}
+
+	doc, err := database.ExportForwardGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	data, err := database.MarshalForwardGroupDocument(doc, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	contentType := "application/x-yaml"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	filename := fmt.Sprintf("steadydns-forward-groups-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ImportForwardGroupsHandler 导入转发组处理器
+// 请求体为原始文档内容，查询参数：
+//   - format: yaml（默认）、json、dnsmasq、unbound
+//   - mode: merge（默认）或replace
+//   - dryRun: 默认true，仅返回差异；为false时在单个事务中应用
+func ImportForwardGroupsHandler(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "yaml"))
+	mode := strings.ToLower(c.DefaultQuery("mode", database.ImportModeMerge))
+	dryRun := c.DefaultQuery("dryRun", "true") != "false"
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxForwardGroupImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "读取请求体失败"})
+		return
+	}
+	if len(data) > maxForwardGroupImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": "导入文档超过10MB限制"})
+		return
+	}
+
+	var doc *database.ForwardGroupDocument
+	warnings := []string{}
+
+	switch format {
+	case "yaml", "yml", "json":
+		doc, err = database.UnmarshalForwardGroupDocument(data, format)
+	case "dnsmasq":
+		var w []string
+		doc, w, err = database.ParseDnsmasqConfig(string(data))
+		warnings = append(warnings, w...)
+	case "unbound":
+		var w []string
+		doc, w, err = database.ParseUnboundConfig(string(data))
+		warnings = append(warnings, w...)
+	default:
+		err = fmt.Errorf("不支持的导入格式: %s，可选值：yaml, json, dnsmasq, unbound", format)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error(), "warnings": warnings})
+		return
+	}
+
+	if dryRun {
+		plan, err := database.PlanForwardGroupImport(doc, mode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error(), "warnings": warnings})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    ForwardGroupImportResponse{DryRun: true, Plan: plan, Warnings: warnings},
+			"message": "导入预览生成成功",
+		})
+		return
+	}
+
+	plan, err := database.ApplyForwardGroupImport(doc, mode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error(), "warnings": warnings})
+		return
+	}
+
+	// 刷新DNS转发器配置
+	if err := sdns.ReloadForwardGroups(); err != nil {
+		fmt.Printf("刷新DNS转发器配置失败: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    ForwardGroupImportResponse{DryRun: false, Plan: plan, Warnings: warnings},
+		"message": fmt.Sprintf("导入成功：新增 %d，更新 %d，删除 %d", plan.Created, plan.Updated, plan.Deleted),
+	})
+}
@@ -23,6 +23,7 @@ import (
 	"SteadyDNS/core/webapi/middleware"
 
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"sync"
@@ -35,6 +36,7 @@ import (
 type HTTPServer struct {
 	server      *http.Server
 	ipv6Server  *http.Server
+	metrics     *http.Server // 独立监听地址的Prometheus指标服务器，未配置时为nil
 	servermu    sync.Mutex
 	httpHandler *gin.Engine
 	handlermu   sync.Mutex
@@ -92,6 +94,33 @@ func (hs *HTTPServer) IsRunning() bool {
 	return hs.running
 }
 
+// shutdownSignalKey 请求上下文中服务器关闭通知channel的键
+type shutdownSignalKey struct{}
+
+// newAPIServer 创建API服务器实例
+// http.Server.Shutdown不会取消进行中请求的上下文，关闭时通过请求上下文中的channel通知实时查询流等长连接处理器结束
+func newAPIServer(addr string, handler http.Handler) *http.Server {
+	done := make(chan struct{})
+	var once sync.Once
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), shutdownSignalKey{}, (<-chan struct{})(done))
+		},
+	}
+	server.RegisterOnShutdown(func() {
+		once.Do(func() { close(done) })
+	})
+	return server
+}
+
+// serverShutdown 返回请求所属服务器的关闭通知channel，请求不来自API服务器时返回nil
+func serverShutdown(ctx context.Context) <-chan struct{} {
+	done, _ := ctx.Value(shutdownSignalKey{}).(<-chan struct{})
+	return done
+}
+
 // Start 启动HTTP服务器
 func (hs *HTTPServer) Start() error {
 	hs.runningmu.Lock()
@@ -155,6 +184,8 @@ func (hs *HTTPServer) Start() error {
 	SetupRoutes(engine)
 	// 注册插件路由
 	SetupPluginRoutes(engine)
+	// 注册Prometheus指标路由（未配置独立监听地址时）
+	SetupMetricsRoutes(engine)
 
 	// 智能创建服务器实例
 	// 情况1：如果IPv6地址为"::"（默认值），则只启动IPv6服务器（双栈监听）
@@ -162,10 +193,7 @@ func (hs *HTTPServer) Start() error {
 		hs.logger.Info("IPv6地址为默认值[::]，启动双栈监听...")
 		// 创建IPv6服务器实例（双栈监听）
 		ipv6AddrWithPort := "[" + ipv6Addr + "]:" + port
-		newIPv6Server := &http.Server{
-			Addr:    ipv6AddrWithPort,
-			Handler: engine,
-		}
+		newIPv6Server := newAPIServer(ipv6AddrWithPort, engine)
 		// 更新服务器实例
 		hs.SetServer(nil)
 		hs.SetIPv6Server(newIPv6Server)
@@ -175,16 +203,10 @@ func (hs *HTTPServer) Start() error {
 			hs.logger.Info("IPv6地址为特定地址，且IPv4地址为特定地址，启动两个服务器...")
 			// 创建IPv4服务器实例
 			addr := ipAddr + ":" + port
-			newServer := &http.Server{
-				Addr:    addr,
-				Handler: engine,
-			}
+			newServer := newAPIServer(addr, engine)
 			// 创建IPv6服务器实例
 			ipv6AddrWithPort := "[" + ipv6Addr + "]:" + port
-			newIPv6Server := &http.Server{
-				Addr:    ipv6AddrWithPort,
-				Handler: engine,
-			}
+			newIPv6Server := newAPIServer(ipv6AddrWithPort, engine)
 			// 更新服务器实例
 			hs.SetServer(newServer)
 			hs.SetIPv6Server(newIPv6Server)
@@ -193,10 +215,7 @@ func (hs *HTTPServer) Start() error {
 			hs.logger.Info("IPv6地址为特定地址，但IPv4地址为默认值0.0.0.0，只启动IPv4服务器...")
 			// 创建IPv4服务器实例
 			addr := ipAddr + ":" + port
-			newServer := &http.Server{
-				Addr:    addr,
-				Handler: engine,
-			}
+			newServer := newAPIServer(addr, engine)
 			// 更新服务器实例
 			hs.SetServer(newServer)
 			hs.SetIPv6Server(nil)
@@ -234,6 +253,18 @@ func (hs *HTTPServer) Start() error {
 		}()
 	}
 
+	// 启动独立的指标服务器（如果已配置）
+	hs.metrics = newMetricsServer()
+	if hs.metrics != nil {
+		metricsServer := hs.metrics
+		hs.logger.Info("启动Prometheus指标服务器，监听地址: %s...", metricsServer.Addr)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				hs.logger.Error("Prometheus指标服务器启动失败: %v", err)
+			}
+		}()
+	}
+
 	hs.running = true
 	hs.logger.Info("API服务器启动成功")
 	return nil
@@ -252,6 +283,8 @@ func (hs *HTTPServer) Stop() error {
 	// 保存服务器实例
 	server := hs.server
 	ipv6Server := hs.ipv6Server
+	metricsServer := hs.metrics
+	hs.metrics = nil
 
 	// 立即标记服务器为停止状态，避免重复调用
 	hs.running = false
@@ -306,6 +339,17 @@ func (hs *HTTPServer) Stop() error {
 		}
 	}()
 
+	// 停止独立的指标服务器
+	if metricsServer != nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := metricsServer.Shutdown(ctx); err != nil {
+				hs.logger.Error("停止Prometheus指标服务器失败: %v", err)
+			}
+		}()
+	}
+
 	return nil
 }
 
@@ -358,6 +402,8 @@ func (hs *HTTPServer) createHTTPServer() *http.Server {
 	SetupRoutes(engine)
 	// 注册插件路由
 	SetupPluginRoutes(engine)
+	// 注册Prometheus指标路由
+	SetupMetricsRoutes(engine)
 
 	// 创建HTTP服务器实例
 	return &http.Server{
@@ -392,6 +438,8 @@ func (hs *HTTPServer) createIPv6HTTPServer() *http.Server {
 	SetupRoutes(engine)
 	// 注册插件路由
 	SetupPluginRoutes(engine)
+	// 注册Prometheus指标路由
+	SetupMetricsRoutes(engine)
 
 	// 创建HTTP服务器实例
 	return &http.Server{
@@ -0,0 +1,81 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/webapi/api/metricsapi.go
+
+package api
+
+import (
+	"net/http"
+
+	"SteadyDNS/core/common"
+	"SteadyDNS/core/sdns"
+	"SteadyDNS/core/webapi/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsContentType Prometheus文本格式的内容类型
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// MetricsHandler 处理Prometheus指标抓取请求
+func MetricsHandler(c *gin.Context) {
+	c.Header("Content-Type", metricsContentType)
+	c.Status(http.StatusOK)
+	if err := sdns.WritePrometheusMetrics(c.Writer); err != nil {
+		common.NewLogger().Warn("输出Prometheus指标失败: %v", err)
+	}
+}
+
+// metricsHandlers 返回/metrics路由的中间件和处理器，认证参数从[Metrics]配置读取
+func metricsHandlers() []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		middleware.MetricsAuthMiddleware(
+			common.GetConfig("Metrics", "METRICS_AUTH_TOKEN"),
+			common.GetConfig("Metrics", "METRICS_BASIC_AUTH_USER"),
+			common.GetConfig("Metrics", "METRICS_BASIC_AUTH_PASSWORD"),
+		),
+		MetricsHandler,
+	}
+}
+
+// SetupMetricsRoutes 在API服务器上注册/metrics路由
+// 未启用指标或配置了独立监听地址时不注册
+func SetupMetricsRoutes(engine *gin.Engine) {
+	if !common.GetConfigBool("Metrics", "METRICS_ENABLED", false) || common.GetConfig("Metrics", "METRICS_LISTEN") != "" {
+		return
+	}
+	engine.GET("/metrics", metricsHandlers()...)
+}
+
+// newMetricsServer 创建独立监听地址的指标服务器
+// 未启用指标或未配置独立监听地址时返回nil
+func newMetricsServer() *http.Server {
+	listen := common.GetConfig("Metrics", "METRICS_LISTEN")
+	if !common.GetConfigBool("Metrics", "METRICS_ENABLED", false) || listen == "" {
+		return nil
+	}
+
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	engine.GET("/metrics", metricsHandlers()...)
+
+	return &http.Server{
+		Addr:    listen,
+		Handler: engine,
+	}
+}
@@ -0,0 +1,119 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// core/webapi/api/querystreamapi.go
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"SteadyDNS/core/sdns"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryStreamHeartbeatInterval 心跳间隔，防止代理断开空闲连接，同时推送丢弃计数
+const queryStreamHeartbeatInterval = 15 * time.Second
+
+// splitQueryList 拆分逗号分隔的查询参数
+func splitQueryList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// QueryStreamHandler 处理实时查询流请求（Server-Sent Events）
+// 查询参数: client（IP或CIDR）、name（子串或*通配符）、rcode、qtype（逗号分隔）、sample（0-1采样率）
+// 事件类型: query（查询事件）、dropped（累计丢弃数，随心跳推送）
+func QueryStreamHandler(c *gin.Context) {
+	filter := sdns.QueryStreamFilter{
+		Client: c.Query("client"),
+		Name:   c.Query("name"),
+		RCodes: splitQueryList(c.Query("rcode")),
+		QTypes: splitQueryList(c.Query("qtype")),
+	}
+	if s := c.Query("sample"); s != "" {
+		sample, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "无效的sample参数"})
+			return
+		}
+		filter.Sample = sample
+	}
+
+	sub, err := sdns.GlobalQueryStream.Subscribe(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(queryStreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	shutdown := serverShutdown(ctx)
+	var lastDropped int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-shutdown:
+			// 服务器关闭，结束流以免阻塞Shutdown
+			return
+		case event := <-sub.Events():
+			c.SSEvent("query", event)
+			// 一次写出已缓冲的事件，减少flush次数
+		batch:
+			for i := 0; i < 100; i++ {
+				select {
+				case event = <-sub.Events():
+					c.SSEvent("query", event)
+				default:
+					break batch
+				}
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if dropped := sub.Dropped(); dropped != lastDropped {
+				lastDropped = dropped
+				c.SSEvent("dropped", gin.H{"dropped": dropped})
+			} else {
+				c.Writer.WriteString(": ping\n\n")
+			}
+			c.Writer.Flush()
+		}
+	}
+}
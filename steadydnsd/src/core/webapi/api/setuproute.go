@@ -67,6 +67,8 @@ func SetupRoutes(engine *gin.Engine) {
 	engine.DELETE("/api/forward-groups/:id", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ForwardGroupAPIHandler)
 	engine.DELETE("/api/forward-groups", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ForwardGroupAPIHandler)
 	engine.GET("/api/forward-groups/test-domain-match", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ForwardGroupAPIHandler)
+	engine.GET("/api/forward-groups/export", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ExportForwardGroupsHandler)
+	engine.POST("/api/forward-groups/import", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ImportForwardGroupsHandler)
 
 	// 服务器API路由 - 需要认证，应用所有中间件
 	engine.GET("/api/forward-servers", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ForwardServerAPIHandlerGin)
@@ -110,6 +112,18 @@ func SetupRoutes(engine *gin.Engine) {
 	engine.DELETE("/api/users/:id", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), DeleteUserHandler)
 	engine.PUT("/api/users/:id/password", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), ChangePasswordHandler)
 
+	// 事件与Webhook告警API路由 - 需要认证，应用所有中间件
+	engine.GET("/api/events", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), GetEventsHandler)
+	engine.GET("/api/events/stats", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), GetEventStatsHandler)
+	engine.GET("/api/events/webhooks", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), GetWebhookTargetsHandler)
+	engine.POST("/api/events/webhooks", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), CreateWebhookTargetHandler)
+	engine.PUT("/api/events/webhooks/:id", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), UpdateWebhookTargetHandler)
+	engine.DELETE("/api/events/webhooks/:id", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), DeleteWebhookTargetHandler)
+	engine.POST("/api/events/webhooks/:id/test", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), middleware.TimeoutMiddlewareWithPathGin(), TestWebhookTargetHandler)
+
+	// 实时查询流API路由 - 长连接，不应用超时中间件
+	engine.GET("/api/query-stream", middleware.LoggerMiddleware(), middleware.RateLimitMiddleware(), middleware.AuthMiddlewareGin(), QueryStreamHandler)
+
 	// BIND相关路由已移至插件系统，由SetupPluginRoutes函数动态注册
 
 	// 设置静态文件路由（前端 SPA）
@@ -20,6 +20,7 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -49,3 +50,34 @@ func AuthMiddlewareGin() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// MetricsAuthMiddleware Prometheus指标抓取认证中间件
+// 与API的JWT认证相互独立，token和basic认证均为空时不做认证；两者都配置时满足其一即可
+func MetricsAuthMiddleware(token, username, password string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" && username == "" && password == "" {
+			c.Next()
+			return
+		}
+
+		if token != "" {
+			if got := GetTokenFromRequest(c.Request); got != "" &&
+				subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+				c.Next()
+				return
+			}
+		}
+
+		if username != "" || password != "" {
+			if u, p, ok := c.Request.BasicAuth(); ok &&
+				subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1 {
+				c.Next()
+				return
+			}
+			c.Header("WWW-Authenticate", `Basic realm="metrics"`)
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "指标访问认证失败"})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+SteadyDNS - DNS服务器实现
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+// core/webapi/middleware/auth_test.go
+// 指标抓取认证中间件测试
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMetricsAuthMiddleware 测试bearer令牌和basic认证
+func TestMetricsAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		token      string
+		user, pass string
+		setup      func(r *http.Request)
+		want       int
+	}{
+		{"未配置认证", "", "", "", func(r *http.Request) {}, http.StatusOK},
+		{"令牌正确", "secret", "", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusOK},
+		{"令牌错误", "secret", "", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"缺少令牌", "secret", "", "", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"basic正确", "", "prom", "pw", func(r *http.Request) { r.SetBasicAuth("prom", "pw") }, http.StatusOK},
+		{"basic错误", "", "prom", "pw", func(r *http.Request) { r.SetBasicAuth("prom", "x") }, http.StatusUnauthorized},
+		{"两者配置时basic可用", "secret", "prom", "pw", func(r *http.Request) { r.SetBasicAuth("prom", "pw") }, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := gin.New()
+			engine.GET("/metrics", MetricsAuthMiddleware(tt.token, tt.user, tt.pass), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			tt.setup(req)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("状态码 = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -89,6 +89,12 @@ func GetTimeoutByPath(path string) time.Duration {
 	case strings.HasPrefix(path, "/api/server/"):
 		// 服务器管理API，特别是重启操作需要较长时间
 		return 30 * time.Second
+	case path == "/api/query-logs/export":
+		// 查询日志导出，可能涉及大量记录
+		return 120 * time.Second
+	case strings.HasPrefix(path, "/api/query-logs"):
+		// 查询日志检索，带条件的计数可能扫描较多记录
+		return 30 * time.Second
 	default:
 		// 默认超时时间
 		return 10 * time.Second